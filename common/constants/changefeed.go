@@ -70,12 +70,84 @@ func ConvertChangeFeedStatus(s string) (status ChangeFeedStatus, err error) {
 type DownstreamType string
 
 const (
-	DownstreamTypeTiDB  DownstreamType = "tidb"
-	DownstreamTypeKafka DownstreamType = "kafka"
-	DownstreamTypeMysql DownstreamType = "mysql"
+	DownstreamTypeTiDB    DownstreamType = "tidb"
+	DownstreamTypeKafka   DownstreamType = "kafka"
+	DownstreamTypeMysql   DownstreamType = "mysql"
+	DownstreamTypePulsar  DownstreamType = "pulsar"
+	DownstreamTypeStorage DownstreamType = "storage"
 )
 
 var DefaultFilterRules = []string{
 	"*.*",
 	"!__TiDB_BR_Temporary*.*",
-}
\ No newline at end of file
+}
+
+// ChangeFeedLagLevel level of checkpoint lag measured by change feed watcher
+type ChangeFeedLagLevel string
+
+const (
+	ChangeFeedLagNormal   ChangeFeedLagLevel = "Normal"
+	ChangeFeedLagWarning  ChangeFeedLagLevel = "Warning"
+	ChangeFeedLagCritical ChangeFeedLagLevel = "Critical"
+)
+
+// Severity greater value means severer lag, unknown level is regarded as normal
+func (l ChangeFeedLagLevel) Severity() int {
+	switch l {
+	case ChangeFeedLagWarning:
+		return 1
+	case ChangeFeedLagCritical:
+		return 2
+	default:
+		return 0
+	}
+}
+
+type ChangeFeedEventType string
+
+const (
+	ChangeFeedEventLagWarning          ChangeFeedEventType = "LagWarning"
+	ChangeFeedEventLagCritical         ChangeFeedEventType = "LagCritical"
+	ChangeFeedEventLagRecovered        ChangeFeedEventType = "LagRecovered"
+	ChangeFeedEventTaskError           ChangeFeedEventType = "TaskError"
+	ChangeFeedEventTaskRecovered       ChangeFeedEventType = "TaskRecovered"
+	ChangeFeedEventAutoResumed         ChangeFeedEventType = "AutoResumed"
+	ChangeFeedEventAutoResumeFailed    ChangeFeedEventType = "AutoResumeFailed"
+	ChangeFeedEventAutoResumeExhausted ChangeFeedEventType = "AutoResumeExhausted"
+)
+
+const (
+	DefaultChangeFeedLagWarningThreshold  string = "60"  // seconds, lag warning is disabled if 0
+	DefaultChangeFeedLagCriticalThreshold string = "600" // seconds, lag critical is disabled if 0
+	DefaultChangeFeedAutoResumeBackoff    string = "30"  // seconds, doubled after every attempt
+	DefaultChangeFeedAutoResumeMaxRetries int    = 5     // used when max retries of auto resume policy is 0
+)
+
+// ConsistencyCheckStatus status of data consistency check between upstream and downstream of change feed
+type ConsistencyCheckStatus string
+
+const (
+	ConsistencyCheckRunning      ConsistencyCheckStatus = "Running"
+	ConsistencyCheckConsistent   ConsistencyCheckStatus = "Consistent"
+	ConsistencyCheckInconsistent ConsistencyCheckStatus = "Inconsistent"
+	ConsistencyCheckFailed       ConsistencyCheckStatus = "Failed"
+)
+
+// ConsistencySnapshotMode how snapshots of upstream and downstream are chosen for consistency check
+type ConsistencySnapshotMode string
+
+const (
+	// ConsistencySnapshotSyncPoint snapshots of the latest sync point recorded by TiCDC in TiDB downstream
+	ConsistencySnapshotSyncPoint ConsistencySnapshotMode = "SyncPoint"
+	// ConsistencySnapshotCheckpoint upstream at checkpoint of change feed and downstream at current data,
+	// rows changed after checkpoint may be reported as mismatched
+	ConsistencySnapshotCheckpoint ConsistencySnapshotMode = "Checkpoint"
+)
+
+// ConsistencyCheckTrigger how a consistency check is started
+type ConsistencyCheckTrigger string
+
+const (
+	ConsistencyCheckTriggerManual   ConsistencyCheckTrigger = "Manual"
+	ConsistencyCheckTriggerSchedule ConsistencyCheckTrigger = "Schedule"
+)
@@ -32,6 +32,7 @@ const (
 	ClusterRunning      ClusterRunningStatus = "Running"
 	ClusterRecovering   ClusterRunningStatus = "Recovering"
 	ClusterFailure      ClusterRunningStatus = "Failure"
+	ClusterHibernated   ClusterRunningStatus = "Hibernated"
 )
 
 type ClusterMaintenanceStatus string
@@ -53,6 +54,8 @@ const (
 	ClusterMaintenanceSwitchoverRollback           ClusterMaintenanceStatus = "SwitchoverRollback"
 	ClusterMaintenanceModifyParameterAndRestarting ClusterMaintenanceStatus = "ModifyParameterRestarting"
 	ClusterMaintenanceTakeover                     ClusterMaintenanceStatus = "Takeover"
+	ClusterMaintenanceHibernating                  ClusterMaintenanceStatus = "Hibernating"
+	ClusterMaintenanceWaking                       ClusterMaintenanceStatus = "Waking"
 	ClusterMaintenanceNone                         ClusterMaintenanceStatus = ""
 )
 
@@ -62,18 +65,28 @@ const (
 	FlowBackupCluster                                   = "BackupCluster"
 	FlowRestoreNewCluster                               = "RestoreNewCluster"
 	FlowRestoreExistCluster                             = "RestoreExistCluster"
+	FlowPointInTimeRestoreCluster                       = "PointInTimeRestoreCluster"
 	FlowModifyParameters                                = "ModifyParameters"
 	FlowExportData                                      = "ExportData"
 	FlowImportData                                      = "ImportData"
 	FlowRestartCluster                                  = "RestartCluster"
 	FlowStopCluster                                     = "StopCluster"
+	FlowHibernateCluster                                = "HibernateCluster"
+	FlowWakeCluster                                     = "WakeCluster"
 	FlowTakeoverCluster                                 = "TakeoverCluster"
+	FlowTakeoverDMCluster                               = "TakeoverDMCluster"
+	FlowCreateDMCluster                                 = "CreateDMCluster"
+	FlowScaleOutDMCluster                               = "ScaleOutDMCluster"
+	FlowDeleteDMCluster                                 = "DeleteDMCluster"
 	FlowBuildLogConfig                                  = "BuildLogConfig"
 	FlowScaleOutCluster                                 = "ScaleOutCluster"
 	FlowScaleInCluster                                  = "ScaleInCluster"
 	FlowCloneCluster                                    = "CloneCluster"
 	FlowOnlineInPlaceUpgradeCluster                     = "OnlineInPlaceUpgradeCluster"
 	FlowOfflineInPlaceUpgradeCluster                    = "OfflineInPlaceUpgradeCluster"
+	FlowApplyClusterSpec                                = "ApplyClusterSpec"
+	FlowVerifyBackup                                    = "VerifyBackup"
+	FlowVerifyConsistency                               = "VerifyConsistency"
 	FlowMasterSlaveSwitchoverNormal                     = "SwitchoverNormal"
 	FlowMasterSlaveSwitchoverForce                      = "SwitchoverForce"
 	FlowMasterSlaveSwitchoverForceWithMasterUnavailable = "SwitchoverForceWithMasterUnavailable"
@@ -89,6 +102,7 @@ const (
 	ClusterInstanceRunning      ClusterInstanceRunningStatus = "Running"
 	ClusterInstanceRecovering   ClusterInstanceRunningStatus = "Recovering"
 	ClusterInstanceFailure      ClusterInstanceRunningStatus = "Failure"
+	ClusterInstanceHibernated   ClusterInstanceRunningStatus = "Hibernated"
 )
 
 type ClusterInstanceMaintenanceStatus string
@@ -113,11 +127,85 @@ const (
 	ClusterBackupFailed       ClusterBackupStatus = "Failed"
 )
 
+type BackupVerifyStatus string
+
+//Definition of backup verification status, empty if the backup has not been verified
+const (
+	BackupVerifyProcessing BackupVerifyStatus = "Processing"
+	BackupVerifyVerified   BackupVerifyStatus = "Verified"
+	BackupVerifyFailed     BackupVerifyStatus = "Failed"
+)
+
+type LogBackupStatus string
+
+//Definition of cluster log backup task status information
+const (
+	LogBackupRunning LogBackupStatus = "Running"
+	LogBackupError   LogBackupStatus = "Error"
+	LogBackupStopped LogBackupStatus = "Stopped"
+)
+
+type BackupKeyStatus string
+
+//Definition of backup data key status, only the active key of a cluster encrypts new backups
+const (
+	BackupKeyActive  BackupKeyStatus = "Active"
+	BackupKeyRetired BackupKeyStatus = "Retired"
+)
+
+type BackupLocationStatus string
+
+//Definition of status of backup copies on storage targets
+const (
+	BackupLocationCopying   BackupLocationStatus = "Copying"
+	BackupLocationAvailable BackupLocationStatus = "Available"
+	BackupLocationFailed    BackupLocationStatus = "Failed"
+)
+
+type BackupEncryptionMethod string
+
+//Definition of BR crypter methods of encrypted backups
+const (
+	BackupEncryptionAES128CTR BackupEncryptionMethod = "aes128-ctr"
+	BackupEncryptionAES192CTR BackupEncryptionMethod = "aes192-ctr"
+	BackupEncryptionAES256CTR BackupEncryptionMethod = "aes256-ctr"
+)
+
 type ClusterRelationType string
 
 //Constants for the relationships between clusters
 const (
+	// object cluster is a read-only copy of subject cluster, which is the writable root of replication chain
 	ClusterRelationStandBy ClusterRelationType = "StandBy"
+	// object cluster is a read-only copy of subject cluster, which is itself a copy of another cluster, e.g. B->C of A->B->C
+	ClusterRelationCascade ClusterRelationType = "Cascade"
+	// both clusters are writable and replicate to each other, each direction carries its own disjoint set of tables
+	ClusterRelationBidirectional ClusterRelationType = "Bidirectional"
+)
+
+// IsOneWay object cluster of standby and cascade relation is read-only and has only one upstream
+func (t ClusterRelationType) IsOneWay() bool {
+	return t == ClusterRelationStandBy || t == ClusterRelationCascade
+}
+
+type TopologyRiskLevel string
+
+// Definition of risk level of cluster instances placement
+const (
+	TopologyRiskWarning  TopologyRiskLevel = "Warning"
+	TopologyRiskCritical TopologyRiskLevel = "Critical"
+)
+
+type TopologyRiskType string
+
+// Definition of risky placement of cluster instances
+const (
+	TopologyRiskTiKVReplicasOnSameHost TopologyRiskType = "TiKVReplicasOnSameHost"
+	TopologyRiskTiKVReplicasOnSameRack TopologyRiskType = "TiKVReplicasOnSameRack"
+	TopologyRiskPDQuorumOnOneRack      TopologyRiskType = "PDQuorumOnOneRack"
+	TopologyRiskPDsInOneZone           TopologyRiskType = "PDsInOneZone"
+	TopologyRiskTiDBsOnOneHost         TopologyRiskType = "TiDBsOnOneHost"
+	TopologyRiskHostNotOnline          TopologyRiskType = "HostNotOnline"
 )
 
 type ClusterCloneStrategy string
@@ -144,8 +232,26 @@ type BackupMode string
 
 //Definition backup data mode
 const (
-	BackupModeAuto   BackupMode = "auto"
-	BackupModeManual BackupMode = "manual"
+	BackupModeAuto     BackupMode = "auto"
+	BackupModeManual   BackupMode = "manual"
+	BackupModeImported BackupMode = "imported" // created outside TiUniManager and registered by import, files are never removed
+)
+
+type BackupMissedRunPolicy string
+
+//Definition policy of backup schedule runs missed during outage
+const (
+	BackupMissedRunPolicyRun    BackupMissedRunPolicy = "Run"    // run one catch-up backup for the missed runs
+	BackupMissedRunPolicyReport BackupMissedRunPolicy = "Report" // only count and report the missed runs
+)
+
+type RestoreCheckStatus string
+
+//Definition result of a pre-restore check item
+const (
+	RestoreCheckPassed  RestoreCheckStatus = "Passed"
+	RestoreCheckFailed  RestoreCheckStatus = "Failed"
+	RestoreCheckSkipped RestoreCheckStatus = "Skipped" // facts needed by the check are unavailable
 )
 
 type StorageType string
@@ -158,14 +264,23 @@ const (
 )
 
 const (
-	DefaultBackupStoragePath       string = "nfs/em/backup"
-	DefaultBackupS3AccessKey       string = "minioadmin"
-	DefaultBackupS3SecretAccessKey string = "minioadmin"
-	DefaultBackupS3Endpoint        string = "http://minio.pingcap.net:9000"
-	DefaultBackupRateLimit         string = ""
-	DefaultRestoreRateLimit        string = ""
-	DefaultBackupConcurrency       string = ""
-	DefaultRestoreConcurrency      string = ""
+	DefaultBackupStoragePath        string = "nfs/em/backup"
+	DefaultBackupS3AccessKey        string = "minioadmin"
+	DefaultBackupS3SecretAccessKey  string = "minioadmin"
+	DefaultBackupS3Endpoint         string = "http://minio.pingcap.net:9000"
+	DefaultBackupRateLimit          string = ""
+	DefaultRestoreRateLimit         string = ""
+	DefaultBackupConcurrency        string = ""
+	DefaultRestoreConcurrency       string = ""
+	DefaultBackupVerifyHosts        string = "" // backup verification is disabled without hosts
+	DefaultBackupVerifySpec         string = "4C8G"
+	DefaultBRStuckTimeout           string = "30"  // minutes, stuck detection is disabled if 0
+	DefaultAutoBackupMaxConcurrency string = "4"   // max running auto backups of all clusters, unlimited if 0
+	DefaultAutoBackupJitter         string = "300" // seconds, auto backups start at a random delay within it
+	DefaultBackupEncryptionMethod   string = ""    // backups are not encrypted if empty
+	DefaultRestoreCompressionFactor string = "3"   // restored data is estimated as backup size * factor per replica, capacity check is disabled if 0
+	DefaultBackupCopyTimeout        string = "24"  // hours, a Copying copy of backup is marked Failed after it, never if 0
+	DefaultBRCommandTimeout         string = "24"  // hours, br command of encrypted backup or restore is killed after it, never if 0
 )
 
 type DBUserRoleType string
@@ -34,4 +34,10 @@ func Test_Config(t *testing.T)  {
 	err := json.Unmarshal([]byte(DefaultRetainedPortRange), &ports)
 	assert.NoError(t, err)
 	assert.Equal(t, []int{11000, 12000}, ports)
-}
\ No newline at end of file
+}
+
+func TestClusterRelationType_IsOneWay(t *testing.T) {
+	assert.True(t, ClusterRelationStandBy.IsOneWay())
+	assert.True(t, ClusterRelationCascade.IsOneWay())
+	assert.False(t, ClusterRelationBidirectional.IsOneWay())
+}
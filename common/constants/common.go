@@ -118,6 +118,12 @@ var ParasiteComponentIDs = []EMProductComponentIDType{
 	ComponentIDAlertManger,
 }
 
+// HibernateComponentIDs stateless components whose compute resource is released when the cluster hibernates,
+// monitoring components share the host resource of PD, so they are only stopped
+var HibernateComponentIDs = []EMProductComponentIDType{
+	ComponentIDTiDB,
+}
+
 var KernelComponentIDs = []EMProductComponentIDType{
 	ComponentIDTiDB,
 	ComponentIDTiKV,
@@ -133,6 +139,9 @@ const (
 	ComponentIDTiFlash EMProductComponentIDType = "TiFlash"
 	ComponentIDCDC     EMProductComponentIDType = "CDC"
 
+	ComponentIDDMMaster EMProductComponentIDType = "DMMaster"
+	ComponentIDDMWorker EMProductComponentIDType = "DMWorker"
+
 	ComponentIDGrafana          EMProductComponentIDType = "Grafana"
 	ComponentIDPrometheus       EMProductComponentIDType = "Prometheus"
 	ComponentIDAlertManger      EMProductComponentIDType = "AlertManger"
@@ -169,6 +178,10 @@ func (p EMProductComponentIDType) SortWeight() int {
 		return 19600
 	case ComponentIDCDC:
 		return 19500
+	case ComponentIDDMMaster:
+		return 19490
+	case ComponentIDDMWorker:
+		return 19480
 	case ComponentIDGrafana:
 		return 19400
 	case ComponentIDPrometheus:
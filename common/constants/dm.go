@@ -0,0 +1,65 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package constants
+
+// DMTaskStatus status of data migration task, maintained by tiunimanager
+type DMTaskStatus string
+
+const (
+	DMTaskStatusCreated   DMTaskStatus = "Created"
+	DMTaskStatusRunning   DMTaskStatus = "Running"
+	DMTaskStatusPaused    DMTaskStatus = "Paused"
+	DMTaskStatusError     DMTaskStatus = "Error"
+	DMTaskStatusStopped   DMTaskStatus = "Stopped"
+	DMTaskStatusHandedOff DMTaskStatus = "HandedOff"
+)
+
+// IsFinal task is removed from dm-master in final status
+func (s DMTaskStatus) IsFinal() bool {
+	return DMTaskStatusStopped == s || DMTaskStatusHandedOff == s
+}
+
+func UnfinishedDMTaskStatus() []DMTaskStatus {
+	return []DMTaskStatus{
+		DMTaskStatusCreated, DMTaskStatusRunning, DMTaskStatusPaused, DMTaskStatusError,
+	}
+}
+
+// DMTaskMode task-mode of DM task config
+type DMTaskMode string
+
+const (
+	DMTaskModeAll         DMTaskMode = "all"
+	DMTaskModeFull        DMTaskMode = "full"
+	DMTaskModeIncremental DMTaskMode = "incremental"
+)
+
+// DMHandoffType what takes over replication after data migration is finished
+type DMHandoffType string
+
+const (
+	// DMHandoffChangeFeed replicate target cluster to a downstream by change feed task
+	DMHandoffChangeFeed DMHandoffType = "ChangeFeed"
+	// DMHandoffSwitchover replicate target cluster to a standby cluster, which is ready for switchover
+	DMHandoffSwitchover DMHandoffType = "Switchover"
+)
+
+const (
+	// DMUnitSync sub task unit of incremental replication
+	DMUnitSync = "Sync"
+	// DMStagePaused stage of sub task which is paused or stopped by error
+	DMStagePaused = "Paused"
+)
@@ -32,6 +32,8 @@ const (
 	MetricsClusterCreate                MetricsType = "cluster/create"
 	MetricsClusterDelete                MetricsType = "cluster/delete"
 	MetricsClusterStop                  MetricsType = "cluster/stop"
+	MetricsClusterHibernate             MetricsType = "cluster/hibernate"
+	MetricsClusterWake                  MetricsType = "cluster/wake"
 	MetricsClusterStart                 MetricsType = "cluster/start"
 	MetricsClusterRestart               MetricsType = "cluster/restart"
 	MetricsClusterScaleIn               MetricsType = "cluster/scale_in"
@@ -39,11 +41,19 @@ const (
 	MetricsClusterScaleOut              MetricsType = "cluster/scale_out"
 	MetricsClusterClone                 MetricsType = "cluster/clone"
 	MetricsClusterSwitchover            MetricsType = "cluster/switchover"
+	MetricsClusterSwitchoverReadiness   MetricsType = "cluster/switchover_readiness"
+	MetricsClusterQueryFailoverPolicy   MetricsType = "cluster/query_failover_policy"
+	MetricsClusterUpdateFailoverPolicy  MetricsType = "cluster/update_failover_policy"
+	MetricsClusterCreateRelation        MetricsType = "cluster/create_relation"
+	MetricsClusterDeleteRelation        MetricsType = "cluster/delete_relation"
+	MetricsClusterReplicationGraph      MetricsType = "cluster/replication_graph"
 	MetricsClusterRestore               MetricsType = "cluster/restore"
+	MetricsClusterRestoreExist          MetricsType = "cluster/restore_exist"
 	MetricsClusterTakeover              MetricsType = "cluster/takeover"
 	MetricsClusterPreview               MetricsType = "cluster/preview"
 	MetricsClusterQuery                 MetricsType = "cluster/query"
 	MetricsClusterDetail                MetricsType = "cluster/detail"
+	MetricsClusterTopologyGraph         MetricsType = "cluster/topology_graph"
 	MetricsClusterQueryMonitorAddress   MetricsType = "cluster/query_monitor_address"
 	MetricsClusterQueryDashboardAddress MetricsType = "cluster/query_dashboard_address"
 	MetricsClusterQueryParameter        MetricsType = "cluster/query_parameter"
@@ -53,6 +63,22 @@ const (
 	MetricsClusterUpgrade               MetricsType = "cluster/upgrade"
 	MetricsClusterUpgradePath           MetricsType = "cluster/upgrade_path"
 	MetricsClusterUpgradeDiff           MetricsType = "cluster/upgrade_diff"
+	MetricsClusterExportSpec            MetricsType = "cluster/export_spec"
+	MetricsClusterPlanSpec              MetricsType = "cluster/plan_spec"
+	MetricsClusterApplySpec             MetricsType = "cluster/apply_spec"
+	MetricsClusterQueryGC               MetricsType = "cluster/query_gc"
+	MetricsClusterCreateGCHold          MetricsType = "cluster/create_gc_hold"
+	MetricsClusterReleaseGCHold         MetricsType = "cluster/release_gc_hold"
+	MetricsClusterRegisterDMSource      MetricsType = "cluster/register_dm_source"
+	MetricsClusterQueryDMSources        MetricsType = "cluster/query_dm_sources"
+	MetricsClusterDeleteDMSource        MetricsType = "cluster/delete_dm_source"
+	MetricsClusterCreateDMTask          MetricsType = "cluster/create_dm_task"
+	MetricsClusterStartDMTask           MetricsType = "cluster/start_dm_task"
+	MetricsClusterPauseDMTask           MetricsType = "cluster/pause_dm_task"
+	MetricsClusterStopDMTask            MetricsType = "cluster/stop_dm_task"
+	MetricsClusterQueryDMTasks          MetricsType = "cluster/query_dm_tasks"
+	MetricsClusterDetailDMTask          MetricsType = "cluster/detail_dm_task"
+	MetricsClusterHandoffDMTask         MetricsType = "cluster/handoff_dm_task"
 
 	MetricsMetadataDeletePhysically MetricsType = "metadata/delete"
 
@@ -72,6 +98,22 @@ const (
 	MetricsBackupQuery          MetricsType = "backup/query"
 	MetricsBackupQueryStrategy  MetricsType = "backup/query_strategy"
 	MetricsBackupModifyStrategy MetricsType = "backup/modify_strategy"
+	MetricsBackupStartLog       MetricsType = "backup/start_log"
+	MetricsBackupStopLog        MetricsType = "backup/stop_log"
+	MetricsBackupQueryLog       MetricsType = "backup/query_log"
+	MetricsBackupSaveSchedule   MetricsType = "backup/save_schedule"
+	MetricsBackupQuerySchedule  MetricsType = "backup/query_schedule"
+	MetricsBackupDeleteSchedule MetricsType = "backup/delete_schedule"
+	MetricsBackupRotateKey      MetricsType = "backup/rotate_key"
+	MetricsBackupQueryKey       MetricsType = "backup/query_key"
+	MetricsBackupExportKey      MetricsType = "backup/export_key"
+	MetricsBackupImportKey      MetricsType = "backup/import_key"
+	MetricsBackupSaveTarget     MetricsType = "backup/save_target"
+	MetricsBackupQueryTarget    MetricsType = "backup/query_target"
+	MetricsBackupDeleteTarget   MetricsType = "backup/delete_target"
+	MetricsBackupCopy           MetricsType = "backup/copy"
+	MetricsBackupImport         MetricsType = "backup/import"
+	MetricsBackupCheckRestore   MetricsType = "backup/restore_check"
 
 	// MetricsDataExport define data export & import metrics
 	MetricsDataExport             MetricsType = "data/export"
@@ -87,6 +129,18 @@ const (
 	MetricsCDCTaskUpdate MetricsType = "cdc/update"
 	MetricsCDCTaskQuery  MetricsType = "cdc/query"
 	MetricsCDCTaskDetail MetricsType = "cdc/detail"
+
+	MetricsCDCConsistencyCheckStart     MetricsType = "cdc/consistency_check/start"
+	MetricsCDCConsistencyCheckQuery     MetricsType = "cdc/consistency_check/query"
+	MetricsCDCConsistencyCheckDetail    MetricsType = "cdc/consistency_check/detail"
+	MetricsCDCConsistencyScheduleSave   MetricsType = "cdc/consistency_schedule/save"
+	MetricsCDCConsistencyScheduleGet    MetricsType = "cdc/consistency_schedule/get"
+	MetricsCDCConsistencyScheduleDelete MetricsType = "cdc/consistency_schedule/delete"
+	MetricsCDCTaskBatchPause            MetricsType = "cdc/batch/pause"
+	MetricsCDCTaskBatchResume           MetricsType = "cdc/batch/resume"
+	MetricsCDCTaskBatchDelete           MetricsType = "cdc/batch/delete"
+	MetricsCDCTaskExport                MetricsType = "cdc/export"
+	MetricsCDCTaskImport                MetricsType = "cdc/import"
 	MetricsCDCDownstream MetricsType = "cdc/downstream/delete"
 
 	// MetricsParameterGroupCreate define parameter group metrics
@@ -127,6 +181,7 @@ const (
 	MetricsTenantQuery                  MetricsType = "tenant/query"
 	MetricsTenantUpdateProfile          MetricsType = "tenant/update_profile"
 	MetricsTenantUpdateOnBoardingStatus MetricsType = "tenant/update_on_boarding_status"
+	MetricsTenantCostReport             MetricsType = "tenant/cost_report"
 
 	// MetricsWorkFlowQuery define workflow metrics
 	MetricsWorkFlowQuery  MetricsType = "workflow/query"
@@ -137,6 +192,7 @@ const (
 	// MetricsResourceQueryHierarchy define resource metrics
 	MetricsResourceQueryHierarchy           MetricsType = "resource/query_hierarchy"
 	MetricsResourceQueryStocks              MetricsType = "resource/query_stocks"
+	MetricsResourceQueryTopologyGraph       MetricsType = "resource/query_topology_graph"
 	MetricsResourceDownloadHostTemplateFile MetricsType = "resource/download_host_template_file"
 	MetricsResourceReservedHost             MetricsType = "resource/reserved_host"
 	MetricsResourceModifyHostStatus         MetricsType = "resource/modify_host_status"
@@ -163,22 +219,48 @@ var EMMetrics = []MetricsType{
 	MetricsClusterCreate,
 	MetricsClusterDelete,
 	MetricsClusterStop,
+	MetricsClusterHibernate,
+	MetricsClusterWake,
 	MetricsClusterStart,
 	MetricsClusterRestart,
 	MetricsClusterScaleIn,
 	MetricsClusterScaleOut,
 	MetricsClusterClone,
 	MetricsClusterRestore,
+	MetricsClusterRestoreExist,
 	MetricsClusterTakeover,
 	MetricsClusterPreview,
 	MetricsClusterQuery,
 	MetricsClusterDetail,
+	MetricsClusterTopologyGraph,
+	MetricsClusterQueryFailoverPolicy,
+	MetricsClusterUpdateFailoverPolicy,
+	MetricsClusterSwitchoverReadiness,
+	MetricsClusterCreateRelation,
+	MetricsClusterDeleteRelation,
+	MetricsClusterReplicationGraph,
 	MetricsClusterQueryMonitorAddress,
 	MetricsClusterQueryDashboardAddress,
 	MetricsClusterQueryParameter,
 	MetricsClusterModifyParameter,
 	MetricsClusterInspectParameter,
 	MetricsClusterQueryLogParameter,
+	MetricsClusterExportSpec,
+	MetricsClusterPlanSpec,
+	MetricsClusterApplySpec,
+	MetricsClusterQueryGC,
+	MetricsClusterCreateGCHold,
+	MetricsClusterReleaseGCHold,
+	MetricsClusterRegisterDMSource,
+	MetricsClusterQueryDMSources,
+	MetricsClusterDeleteDMSource,
+	MetricsClusterCreateDMTask,
+	MetricsClusterStartDMTask,
+	MetricsClusterPauseDMTask,
+	MetricsClusterStopDMTask,
+	MetricsClusterQueryDMTasks,
+	MetricsClusterDetailDMTask,
+	MetricsClusterHandoffDMTask,
 	MetricsMetadataDeletePhysically,
 	// MetricsBackupCreate define backup metrics
 	MetricsBackupCreate,
@@ -186,6 +268,22 @@ var EMMetrics = []MetricsType{
 	MetricsBackupQuery,
 	MetricsBackupQueryStrategy,
 	MetricsBackupModifyStrategy,
+	MetricsBackupStartLog,
+	MetricsBackupStopLog,
+	MetricsBackupQueryLog,
+	MetricsBackupSaveSchedule,
+	MetricsBackupQuerySchedule,
+	MetricsBackupDeleteSchedule,
+	MetricsBackupRotateKey,
+	MetricsBackupQueryKey,
+	MetricsBackupExportKey,
+	MetricsBackupImportKey,
+	MetricsBackupSaveTarget,
+	MetricsBackupQueryTarget,
+	MetricsBackupDeleteTarget,
+	MetricsBackupCopy,
+	MetricsBackupImport,
+	MetricsBackupCheckRestore,
 
 	// MetricsDataExport define data export & import metrics
 	MetricsDataExport,
@@ -201,6 +299,17 @@ var EMMetrics = []MetricsType{
 	MetricsCDCTaskUpdate,
 	MetricsCDCTaskQuery,
 	MetricsCDCTaskDetail,
+	MetricsCDCConsistencyCheckStart,
+	MetricsCDCConsistencyCheckQuery,
+	MetricsCDCConsistencyCheckDetail,
+	MetricsCDCConsistencyScheduleSave,
+	MetricsCDCConsistencyScheduleGet,
+	MetricsCDCConsistencyScheduleDelete,
+	MetricsCDCTaskBatchPause,
+	MetricsCDCTaskBatchResume,
+	MetricsCDCTaskBatchDelete,
+	MetricsCDCTaskExport,
+	MetricsCDCTaskImport,
 	MetricsCDCDownstream,
 
 	// MetricsParameterGroupCreate define parameter group metrics
@@ -224,6 +333,7 @@ var EMMetrics = []MetricsType{
 	// MetricsResourceQueryHierarchy define resource metrics
 	MetricsResourceQueryHierarchy,
 	MetricsResourceQueryStocks,
+	MetricsResourceQueryTopologyGraph,
 	MetricsResourceDownloadHostTemplateFile,
 	MetricsResourceModifyHostStatus,
 	MetricsResourceImportHosts,
@@ -25,15 +25,30 @@ package constants
 
 // System config key
 const (
-	ConfigKeyBackupStorageType       string = "BackupStorageType"
-	ConfigKeyBackupStoragePath       string = "BackupStoragePath"
-	ConfigKeyBackupS3Endpoint        string = "BackupS3Endpoint"
-	ConfigKeyBackupS3AccessKey       string = "BackupS3AccessKey"
-	ConfigKeyBackupS3SecretAccessKey string = "BackupS3SecretAccessKey"
-	ConfigKeyBackupRateLimit         string = "BackupRateLimit"
-	ConfigKeyRestoreRateLimit        string = "RestoreRateLimit"
-	ConfigKeyBackupConcurrency       string = "BackupConcurrency"
-	ConfigKeyRestoreConcurrency      string = "RestoreConcurrency"
+	ConfigKeyBackupStorageType        string = "BackupStorageType"
+	ConfigKeyBackupStoragePath        string = "BackupStoragePath"
+	ConfigKeyBackupS3Endpoint         string = "BackupS3Endpoint"
+	ConfigKeyBackupS3AccessKey        string = "BackupS3AccessKey"
+	ConfigKeyBackupS3SecretAccessKey  string = "BackupS3SecretAccessKey"
+	ConfigKeyBackupRateLimit          string = "BackupRateLimit"
+	ConfigKeyRestoreRateLimit         string = "RestoreRateLimit"
+	ConfigKeyBackupConcurrency        string = "BackupConcurrency"
+	ConfigKeyRestoreConcurrency       string = "RestoreConcurrency"
+	ConfigKeyBackupVerifyHosts        string = "BackupVerifyHosts"
+	ConfigKeyBackupVerifySpec         string = "BackupVerifySpec"
+	ConfigKeyBRStuckTimeout           string = "BRStuckTimeout"
+	ConfigKeyAutoBackupMaxConcurrency string = "AutoBackupMaxConcurrency"
+	ConfigKeyAutoBackupJitter         string = "AutoBackupJitter"
+	ConfigKeyBackupEncryptionMethod   string = "BackupEncryptionMethod"
+	ConfigKeyRestoreCompressionFactor string = "RestoreCompressionFactor"
+	ConfigKeyBackupCopyTimeout        string = "BackupCopyTimeout"
+	ConfigKeyBRCommandTimeout         string = "BRCommandTimeout"
+
+	ConfigKeyChangeFeedLagWarningThreshold  string = "ChangeFeedLagWarningThreshold"
+	ConfigKeyChangeFeedLagCriticalThreshold string = "ChangeFeedLagCriticalThreshold"
+	ConfigKeyChangeFeedAutoResumeBackoff    string = "ChangeFeedAutoResumeBackoff"
+
+	ConfigKeyFailoverCooldown string = "FailoverCooldown"
 
 	ConfigKeyImportShareStoragePath string = "ImportShareStoragePath"
 	ConfigKeyExportShareStoragePath string = "ExportShareStoragePath"
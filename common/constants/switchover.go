@@ -43,3 +43,52 @@ const SwitchoverCancelOpRetryWait = 500 * time.Millisecond
 const SwitchoverCancelOpRunAllStepsEvenOnFail = false
 
 const SwitchoverRollbackSuccessInfoString = "Rollback Successfully."
+
+type FailoverEventType string
+
+const (
+	// master is confirmed unavailable by more than one probe source in a round
+	FailoverEventProbeFailed FailoverEventType = "ProbeFailed"
+	FailoverEventRecovered   FailoverEventType = "Recovered"
+	FailoverEventTriggered   FailoverEventType = "Triggered"
+	FailoverEventFailed      FailoverEventType = "Failed"
+	// failover is required but not triggered, such as in cooldown or without available standby
+	FailoverEventSkipped FailoverEventType = "Skipped"
+)
+
+// probe sources of automatic failover
+const (
+	FailoverProbeReadWrite  = "readWrite"
+	FailoverProbePD         = "pd"
+	FailoverProbeChangeFeed = "changeFeed"
+)
+
+// failover is triggered only if master is confirmed unavailable by this number of probe sources,
+// and at least one of them does not depend on connectivity between TiUniManager and master
+const FailoverMinFailedProbes = 2
+
+const (
+	DefaultFailoverThreshold int    = 3      // used when threshold of failover policy is 0
+	DefaultFailoverCooldown  string = "3600" // seconds, no more automatic failover of involved clusters in cooldown
+)
+
+// sync change feed of standby is regarded as stalled if checkpoint lag exceeds this
+const FailoverProbeChangeFeedMaxLag = 90 * time.Second
+
+// state of sync change feed checked by change feed watcher earlier than this is ignored by failover probe
+const FailoverProbeChangeFeedStaleness = 90 * time.Second
+const FailoverProbePDDialTimeout = 3 * time.Second
+
+// checks of switchover readiness report, the same as pre-checks of switchover
+const (
+	SwitchoverCheckRelation       = "relation"
+	SwitchoverCheckTopology       = "topology"
+	SwitchoverCheckOtherStandbys  = "otherStandbys"
+	SwitchoverCheckTargetCDC      = "targetCDCComponent"
+	SwitchoverCheckTargetReadOnly = "targetReadOnly"
+	SwitchoverCheckSourceWritable = "sourceWritable"
+	SwitchoverCheckSyncChangeFeed = "syncChangeFeed"
+)
+
+// cutover duration is estimated by this number of latest finished switchover workflows
+const SwitchoverCutoverEstimateSamples = 20
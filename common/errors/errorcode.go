@@ -50,8 +50,12 @@ const (
 	TIUNIMANAGER_UNSUPPORT_PRODUCT           EM_ERROR_CODE = 20103
 	TIUNIMANAGER_CLUSTER_RESOURCE_NOT_ENOUGH EM_ERROR_CODE = 20104
 	TIUNIMANAGER_CLUSTER_METADATA_BROKEN     EM_ERROR_CODE = 20105
+	TIUNIMANAGER_CLUSTER_SPEC_INVALID        EM_ERROR_CODE = 20106
+	TIUNIMANAGER_CLUSTER_HIBERNATED          EM_ERROR_CODE = 20107
+	TIUNIMANAGER_CLUSTER_NOT_HIBERNATED      EM_ERROR_CODE = 20108
 
 	TIUNIMANAGER_TAKEOVER_SSH_CONNECT_ERROR     EM_ERROR_CODE = 20201
+	TIUNIMANAGER_TAKEOVER_SSH_AUTH_ERROR        EM_ERROR_CODE = 20202
 	TIUNIMANAGER_TAKEOVER_SFTP_ERROR            EM_ERROR_CODE = 20110
 	TIUNIMANAGER_CLUSTER_GET_CLUSTER_PORT_ERROR EM_ERROR_CODE = 20113
 	TIUNIMANAGER_CLUSTER_MAINTENANCE_CONFLICT   EM_ERROR_CODE = 20114
@@ -69,6 +73,30 @@ const (
 	TIUNIMANAGER_BACKUP_PATH_CREATE_FAILED      EM_ERROR_CODE = 20609
 	TIUNIMANAGER_BACKUP_RECORD_INVALID          EM_ERROR_CODE = 20610
 	TIUNIMANAGER_BACKUP_RECORD_CANCEL_FAILED    EM_ERROR_CODE = 20611
+	TIUNIMANAGER_BACKUP_LOG_TASK_CONFLICT       EM_ERROR_CODE = 20612
+	TIUNIMANAGER_BACKUP_LOG_TASK_NOT_FOUND      EM_ERROR_CODE = 20613
+	TIUNIMANAGER_BACKUP_LOG_TASK_FAILED         EM_ERROR_CODE = 20614
+	TIUNIMANAGER_BACKUP_LOG_TASK_UNSUPPORTED    EM_ERROR_CODE = 20615
+	TIUNIMANAGER_RESTORE_POINT_NOT_COVERED      EM_ERROR_CODE = 20616
+	TIUNIMANAGER_BACKUP_SCHEDULE_INVALID        EM_ERROR_CODE = 20617
+	TIUNIMANAGER_BACKUP_SCHEDULE_NOT_FOUND      EM_ERROR_CODE = 20618
+	TIUNIMANAGER_BACKUP_SCHEDULE_SAVE_FAILED    EM_ERROR_CODE = 20619
+	TIUNIMANAGER_BACKUP_SCHEDULE_QUERY_FAILED   EM_ERROR_CODE = 20620
+	TIUNIMANAGER_BACKUP_SCHEDULE_DELETE_FAILED  EM_ERROR_CODE = 20621
+	TIUNIMANAGER_BACKUP_KEY_NOT_FOUND           EM_ERROR_CODE = 20622
+	TIUNIMANAGER_BACKUP_KEY_FAILED              EM_ERROR_CODE = 20623
+	TIUNIMANAGER_BACKUP_KEY_INVALID             EM_ERROR_CODE = 20624
+	TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND        EM_ERROR_CODE = 20625
+	TIUNIMANAGER_BACKUP_TARGET_SAVE_FAILED      EM_ERROR_CODE = 20626
+	TIUNIMANAGER_BACKUP_TARGET_QUERY_FAILED     EM_ERROR_CODE = 20627
+	TIUNIMANAGER_BACKUP_TARGET_DELETE_FAILED    EM_ERROR_CODE = 20628
+	TIUNIMANAGER_BACKUP_TARGET_IN_USE           EM_ERROR_CODE = 20629
+	TIUNIMANAGER_BACKUP_LOCATION_NOT_FOUND      EM_ERROR_CODE = 20630
+	TIUNIMANAGER_BACKUP_COPY_FAILED             EM_ERROR_CODE = 20631
+	TIUNIMANAGER_BACKUP_COPY_CONFLICT           EM_ERROR_CODE = 20632
+	TIUNIMANAGER_BACKUP_IMPORT_FAILED           EM_ERROR_CODE = 20633
+	TIUNIMANAGER_BACKUP_META_INVALID            EM_ERROR_CODE = 20634
+	TIUNIMANAGER_RESTORE_CHECK_FAILED           EM_ERROR_CODE = 20635
 
 	// upgrade
 	TIUNIMANAGER_UPGRADE_QUERY_PATH_FAILED EM_ERROR_CODE = 21100
@@ -81,6 +109,9 @@ const (
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_CDC_SYNC_TASK_NOT_FOUND EM_ERROR_CODE = 21002
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_SLAVE_NO_CDC_COMPONENT  EM_ERROR_CODE = 21003
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_ROLLBACK_FAILED         EM_ERROR_CODE = 21004
+	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_TOPOLOGY_UNSUPPORTED    EM_ERROR_CODE = 21005
+	TIUNIMANAGER_CLUSTER_RELATION_INVALID                        EM_ERROR_CODE = 21006
+	TIUNIMANAGER_CLUSTER_RELATION_NOT_FOUND                      EM_ERROR_CODE = 21007
 
 	// workflow
 	TIUNIMANAGER_WORKFLOW_CREATE_FAILED         EM_ERROR_CODE = 40100
@@ -208,6 +239,11 @@ const (
 	TIUNIMANAGER_CHANGE_FEED_LOCK_EXPIRED           EM_ERROR_CODE = 21204
 	TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM EM_ERROR_CODE = 21205
 	TIUNIMANAGER_CHANGE_FEED_EXECUTE_ERROR          EM_ERROR_CODE = 21206
+	TIUNIMANAGER_CONSISTENCY_CHECK_NOT_FOUND        EM_ERROR_CODE = 21207
+	TIUNIMANAGER_CONSISTENCY_CHECK_FAILED           EM_ERROR_CODE = 21208
+	TIUNIMANAGER_CONSISTENCY_SCHEDULE_NOT_FOUND     EM_ERROR_CODE = 21209
+	TIUNIMANAGER_CONSISTENCY_SCHEDULE_INVALID       EM_ERROR_CODE = 21210
+	TIUNIMANAGER_CHANGE_FEED_START_BEFORE_GC        EM_ERROR_CODE = 21211
 
 	TIUNIMANAGER_DELETE_INSTANCE_ERROR            EM_ERROR_CODE = 20801
 	TIUNIMANAGER_CHECK_PLACEMENT_RULES_ERROR      EM_ERROR_CODE = 20802
@@ -222,6 +258,18 @@ const (
 	TIUNIMANAGER_CLONE_TIKV_ERROR            EM_ERROR_CODE = 21303
 	TIUNIMANAGER_CLONE_SLAVE_ERROR           EM_ERROR_CODE = 21304
 
+	TIUNIMANAGER_GC_HOLD_NOT_FOUND        EM_ERROR_CODE = 21401
+	TIUNIMANAGER_GC_HOLD_INVALID          EM_ERROR_CODE = 21402
+	TIUNIMANAGER_GC_LIFE_TIME_UPDATE_FAIL EM_ERROR_CODE = 21403
+
+	TIUNIMANAGER_DM_SOURCE_NOT_FOUND     EM_ERROR_CODE = 21501
+	TIUNIMANAGER_DM_SOURCE_IN_USE        EM_ERROR_CODE = 21502
+	TIUNIMANAGER_DM_TASK_NOT_FOUND       EM_ERROR_CODE = 21503
+	TIUNIMANAGER_DM_TASK_INVALID         EM_ERROR_CODE = 21504
+	TIUNIMANAGER_DM_TASK_STATUS_CONFLICT EM_ERROR_CODE = 21505
+	TIUNIMANAGER_DM_EXECUTE_ERROR        EM_ERROR_CODE = 21506
+	TIUNIMANAGER_DM_HANDOFF_NOT_READY    EM_ERROR_CODE = 21507
+
 	CreateZonesError              EM_ERROR_CODE = 70001
 	DeleteZonesError              EM_ERROR_CODE = 70002
 	QueryZoneScanRowError         EM_ERROR_CODE = 70003
@@ -319,10 +367,14 @@ var explanationContainer = map[EM_ERROR_CODE]ErrorCodeExplanation{
 	TIUNIMANAGER_CLUSTER_RESOURCE_NOT_ENOUGH:  {"host resource is not enough", 500},
 	TIUNIMANAGER_CLUSTER_MAINTENANCE_CONFLICT: {"maintenance status conflict", 409},
 	TIUNIMANAGER_CLUSTER_METADATA_BROKEN:      {"cluster meta is incomplete", 400},
+	TIUNIMANAGER_CLUSTER_SPEC_INVALID:         {"invalid cluster spec", 400},
+	TIUNIMANAGER_CLUSTER_HIBERNATED:           {"cluster is hibernated, wake it up first", 409},
+	TIUNIMANAGER_CLUSTER_NOT_HIBERNATED:       {"cluster is not hibernated", 409},
 
 	// cluster management
 	TIUNIMANAGER_TAKEOVER_SSH_CONNECT_ERROR: {"ssh connect failed", 500},
 	TIUNIMANAGER_TAKEOVER_SFTP_ERROR:        {"sftp failed", 500},
+	TIUNIMANAGER_TAKEOVER_SSH_AUTH_ERROR:    {"invalid ssh authentication", 400},
 
 	// dashboard && monitor
 	TIUNIMANAGER_DASHBOARD_NOT_FOUND: {"dashboard is not found", 500},
@@ -362,6 +414,30 @@ var explanationContainer = map[EM_ERROR_CODE]ErrorCodeExplanation{
 	TIUNIMANAGER_BACKUP_PATH_CREATE_FAILED:      {"backup filepath create failed", 500},
 	TIUNIMANAGER_BACKUP_RECORD_INVALID:          {"backup record invalid", 400},
 	TIUNIMANAGER_BACKUP_RECORD_CANCEL_FAILED:    {"cancel backup record failed", 500},
+	TIUNIMANAGER_BACKUP_LOG_TASK_CONFLICT:       {"log backup task of cluster is already running", 409},
+	TIUNIMANAGER_BACKUP_LOG_TASK_NOT_FOUND:      {"log backup task not found", 404},
+	TIUNIMANAGER_BACKUP_LOG_TASK_FAILED:         {"operate log backup task failed", 500},
+	TIUNIMANAGER_BACKUP_LOG_TASK_UNSUPPORTED:    {"log backup is not supported by cluster version", 400},
+	TIUNIMANAGER_RESTORE_POINT_NOT_COVERED:      {"restore point is not covered by any backup", 400},
+	TIUNIMANAGER_BACKUP_SCHEDULE_INVALID:        {"backup schedule invalid", 400},
+	TIUNIMANAGER_BACKUP_SCHEDULE_NOT_FOUND:      {"backup schedule not found", 404},
+	TIUNIMANAGER_BACKUP_SCHEDULE_SAVE_FAILED:    {"save backup schedule failed", 500},
+	TIUNIMANAGER_BACKUP_SCHEDULE_QUERY_FAILED:   {"query backup schedule failed", 500},
+	TIUNIMANAGER_BACKUP_SCHEDULE_DELETE_FAILED:  {"delete backup schedule failed", 500},
+	TIUNIMANAGER_BACKUP_KEY_NOT_FOUND:           {"backup encryption key not found", 404},
+	TIUNIMANAGER_BACKUP_KEY_FAILED:              {"operate backup encryption key failed", 500},
+	TIUNIMANAGER_BACKUP_KEY_INVALID:             {"backup encryption key invalid", 400},
+	TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND:        {"backup storage target not found", 404},
+	TIUNIMANAGER_BACKUP_TARGET_SAVE_FAILED:      {"save backup storage target failed", 500},
+	TIUNIMANAGER_BACKUP_TARGET_QUERY_FAILED:     {"query backup storage target failed", 500},
+	TIUNIMANAGER_BACKUP_TARGET_DELETE_FAILED:    {"delete backup storage target failed", 500},
+	TIUNIMANAGER_BACKUP_TARGET_IN_USE:           {"backup storage target is in use", 409},
+	TIUNIMANAGER_BACKUP_LOCATION_NOT_FOUND:      {"backup location not found", 404},
+	TIUNIMANAGER_BACKUP_COPY_FAILED:             {"copy backup failed", 500},
+	TIUNIMANAGER_BACKUP_COPY_CONFLICT:           {"backup already has a copy on the storage target", 409},
+	TIUNIMANAGER_BACKUP_IMPORT_FAILED:           {"import backup failed", 500},
+	TIUNIMANAGER_BACKUP_META_INVALID:            {"backup meta invalid", 400},
+	TIUNIMANAGER_RESTORE_CHECK_FAILED:           {"restore check failed", 400},
 
 	// resource
 	TIUNIMANAGER_RESOURCE_HOST_NOT_FOUND:            {"host not found", 500},
@@ -439,12 +515,32 @@ var explanationContainer = map[EM_ERROR_CODE]ErrorCodeExplanation{
 	TIUNIMANAGER_CHANGE_FEED_LOCK_EXPIRED:           {"Task status lock expired", 409},
 	TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM: {"Task downstream type not supported", 500},
 	TIUNIMANAGER_CHANGE_FEED_EXECUTE_ERROR:          {"Failed to execute task command", 500},
+	TIUNIMANAGER_CONSISTENCY_CHECK_NOT_FOUND:        {"Consistency check is not found", 404},
+	TIUNIMANAGER_CONSISTENCY_CHECK_FAILED:           {"Failed to check data consistency", 500},
+	TIUNIMANAGER_CONSISTENCY_SCHEDULE_NOT_FOUND:     {"Consistency check schedule is not found", 404},
+	TIUNIMANAGER_CONSISTENCY_SCHEDULE_INVALID:       {"Invalid consistency check schedule", 400},
+	TIUNIMANAGER_CHANGE_FEED_START_BEFORE_GC:        {"Start time of change feed task is before gc safe point", 400},
+
+	TIUNIMANAGER_GC_HOLD_NOT_FOUND:        {"GC hold is not found", 404},
+	TIUNIMANAGER_GC_HOLD_INVALID:          {"Invalid GC hold", 400},
+	TIUNIMANAGER_GC_LIFE_TIME_UPDATE_FAIL: {"Failed to update tidb_gc_life_time", 500},
+
+	TIUNIMANAGER_DM_SOURCE_NOT_FOUND:     {"DM source is not found", 404},
+	TIUNIMANAGER_DM_SOURCE_IN_USE:        {"DM source is used by tasks", 409},
+	TIUNIMANAGER_DM_TASK_NOT_FOUND:       {"DM task is not found", 404},
+	TIUNIMANAGER_DM_TASK_INVALID:         {"Invalid DM task", 400},
+	TIUNIMANAGER_DM_TASK_STATUS_CONFLICT: {"DM task status conflict", 409},
+	TIUNIMANAGER_DM_EXECUTE_ERROR:        {"Failed to execute DM command", 500},
+	TIUNIMANAGER_DM_HANDOFF_NOT_READY:    {"DM task is not ready to hand off", 409},
 
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_NOT_FOUND:               {"master/slave relation not found", 404},
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_FAILED:                  {"master/slave switchover failed", 500},
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_CDC_SYNC_TASK_NOT_FOUND: {"master/slave CDC sync task not found", 400},
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_SLAVE_NO_CDC_COMPONENT:  {"slave has no CDC component", 400},
 	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_ROLLBACK_FAILED:         {"switchover rollback failed", 400},
+	TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_TOPOLOGY_UNSUPPORTED:    {"switchover is not supported in this replication topology", 400},
+	TIUNIMANAGER_CLUSTER_RELATION_INVALID:                        {"invalid cluster relation", 400},
+	TIUNIMANAGER_CLUSTER_RELATION_NOT_FOUND:                      {"cluster relation not found", 404},
 
 	TIUNIMANAGER_LOG_QUERY_FAILED: {"Failed to query cluster log", 500},
 	TIUNIMANAGER_LOG_TIME_AFTER:   {"query log parameter startTime after endTime", 401},
@@ -154,9 +154,89 @@ type ClusterTopologyInfo struct {
 
 // BackupStrategy Timed or scheduled data backup strategy
 type BackupStrategy struct {
-	ClusterID  string `json:"clusterId"`
-	BackupDate string `json:"backupDate"`
-	Period     string `json:"period"`
+	ClusterID  string                `json:"clusterId"`
+	BackupDate string                `json:"backupDate"`
+	Period     string                `json:"period"`
+	Retention  BackupRetentionPolicy `json:"retention"`
+	Filter     BackupFilter          `json:"filter"`
+	// storage target where each finished backup is copied asynchronously, no copy if empty
+	CopyTargetID string `json:"copyTargetId"`
+}
+
+// BackupSchedule Cron schedule of auto backups, a cluster may have several schedules.
+// Runs missed during an outage of the service are caught up once or only reported according to missedRunPolicy
+type BackupSchedule struct {
+	ID                string       `json:"id"`
+	ClusterID         string       `json:"clusterId"`
+	Name              string       `json:"name" example:"daily"`
+	CronSpec          string       `json:"cronSpec" example:"0 2 * * *"`     // standard 5-field cron expression or descriptor such as @daily
+	TimeZone          string       `json:"timeZone" example:"Asia/Shanghai"` // IANA time zone of cronSpec, local time zone of the service if empty
+	Paused            bool         `json:"paused"`
+	MissedRunPolicy   string       `json:"missedRunPolicy" enums:"Run,Report"`
+	Filter            BackupFilter `json:"filter"`
+	NextRunTime       time.Time    `json:"nextRunTime"`
+	LastScheduledTime time.Time    `json:"lastScheduledTime"`
+	LastBackupID      string       `json:"lastBackupId"`
+	MissedRuns        uint32       `json:"missedRuns"`
+	LastMissedTime    time.Time    `json:"lastMissedTime"`
+	CreateTime        time.Time    `json:"createTime"`
+	UpdateTime        time.Time    `json:"updateTime"`
+}
+
+// BackupKeyInfo Data key encrypting backups of a cluster, only the Active key encrypts new backups,
+// Retired keys are kept to restore older backups. The key itself is never returned
+type BackupKeyInfo struct {
+	ID         string    `json:"id"`
+	ClusterID  string    `json:"clusterId"`
+	Method     string    `json:"method" enums:"aes128-ctr,aes192-ctr,aes256-ctr"`
+	Status     string    `json:"status" enums:"Active,Retired"`
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+// BackupStorageTarget Named NFS path or S3 endpoint where copies of backups are kept,
+// the secret access key is never returned
+type BackupStorageTarget struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name" example:"offsite"`
+	StorageType     string    `json:"storageType" enums:"nfs,s3"`
+	FilePath        string    `json:"filePath" example:"bucket/prefix"` // base path of copies, bucket and prefix for s3
+	Endpoint        string    `json:"endpoint" example:"http://127.0.0.1:9000"`
+	AccessKey       string    `json:"accessKey"`
+	SecretAccessKey string    `json:"secretAccessKey,omitempty"`
+	CreateTime      time.Time `json:"createTime"`
+	UpdateTime      time.Time `json:"updateTime"`
+}
+
+// BackupLocation Copy of a backup on a storage target, a backup can be restored from any Available location
+type BackupLocation struct {
+	ID          string    `json:"id"`
+	TargetID    string    `json:"targetId"`
+	StorageType string    `json:"storageType"`
+	FilePath    string    `json:"filePath"`
+	Size        uint64    `json:"size"`     // in bytes
+	Checksum    string    `json:"checksum"` // sha256 over checksums of all backup files, equal to the one of the source
+	Status      string    `json:"status" enums:"Copying,Available,Failed"`
+	Message     string    `json:"message"`
+	CreateTime  time.Time `json:"createTime"`
+	FinishTime  time.Time `json:"finishTime"`
+}
+
+// BackupFilter Databases or tables covered by a backup or restore, the whole cluster is covered if both are empty
+type BackupFilter struct {
+	Databases []string `json:"databases" example:"db1,db2"`    // whole databases
+	Tables    []string `json:"tables" example:"db1.t1,db2.t2"` // tables in format of db.table, exclusive with databases
+}
+
+// BackupRetentionPolicy Rules of keeping backups, a backup is kept if any rule keeps it, all zero means keep all backups.
+// Only auto backups and expirable manual backups are purged, the latest successful full backup of the whole cluster
+// and the earliest base snapshot of each log backup task are always kept
+type BackupRetentionPolicy struct {
+	KeepLast    uint32 `json:"keepLast" example:"3"`     // keep the last n backups
+	KeepDays    uint32 `json:"keepDays" example:"7"`     // keep backups started within n days
+	KeepDaily   uint32 `json:"keepDaily" example:"7"`    // keep the last backup of each of the last n days
+	KeepWeekly  uint32 `json:"keepWeekly" example:"4"`   // keep the last backup of each of the last n weeks
+	KeepMonthly uint32 `json:"keepMonthly" example:"12"` // keep the last backup of each of the last n months
 }
 
 // BackupRecord Single backup file details
@@ -175,6 +255,52 @@ type BackupRecord struct {
 	CreateTime   time.Time `json:"createTime"`
 	UpdateTime   time.Time `json:"updateTime"`
 	DeleteTime   time.Time `json:"deleteTime"`
+	Expirable    bool      `json:"expirable"`
+
+	Filter BackupFilter `json:"filter"` // databases or tables covered by the backup
+
+	EncryptionKeyID string `json:"encryptionKeyId"` // data key encrypting the backup, not encrypted if empty
+
+	StorageTargetID string           `json:"storageTargetId"` // storage target of the backup files, the backup storage of system config if empty
+	Locations       []BackupLocation `json:"locations"`       // copies of the backup on other storage targets
+	ClusterVersion  string           `json:"clusterVersion"`  // version of the backed up cluster
+
+	// result of restoring the backup into a scratch cluster, empty status if never verified
+	VerifyStatus  string    `json:"verifyStatus" enums:"Processing,Verified,Failed"`
+	VerifyTime    time.Time `json:"verifyTime"`
+	VerifyMessage string    `json:"verifyMessage"`
+
+	// progress of the running backup, processed size in MB
+	Progress         float32   `json:"progress"`
+	ProcessedSize    float32   `json:"processedSize"`
+	EstimatedEndTime time.Time `json:"estimatedEndTime"`
+}
+
+// RestoreCheckItem Result of a check before restoring a backup into a cluster
+type RestoreCheckItem struct {
+	Name    string `json:"name" enums:"version,collation,capacity,tableConflict"`
+	Status  string `json:"status" enums:"Passed,Failed,Skipped"`
+	Message string `json:"message"`
+}
+
+// LogBackupTaskInfo Continuous log backup task of a cluster,
+// the cluster can be restored to any point between recoverableFromTso and checkpointTso
+type LogBackupTaskInfo struct {
+	ID                  string    `json:"id"`
+	ClusterID           string    `json:"clusterId"`
+	TaskName            string    `json:"taskName"`
+	Status              string    `json:"status" enums:"Running,Error,Stopped"`
+	StorageType         string    `json:"storageType"`
+	FilePath            string    `json:"filePath"`
+	StartTSO            string    `json:"startTso"`
+	CheckpointTSO       string    `json:"checkpointTso"`
+	CheckpointTime      time.Time `json:"checkpointTime"`
+	RecoverableFromTSO  string    `json:"recoverableFromTso"` // backup tso of the earliest snapshot backup covered by the task, empty if none
+	RecoverableFromTime time.Time `json:"recoverableFromTime"`
+	LastError           string    `json:"lastError"`
+	StopTime            time.Time `json:"stopTime"`
+	CreateTime          time.Time `json:"createTime"`
+	UpdateTime          time.Time `json:"updateTime"`
 }
 
 type ClusterLogItem struct {
@@ -261,3 +387,34 @@ type ResourceStockCheckResult struct {
 	ClusterResourceParameterComputeResource
 	Enough bool `json:"enough"`
 }
+
+// ResourceCostItem Projected monthly cost of instances with the same component, zone and spec
+type ResourceCostItem struct {
+	Type string `json:"componentType"`
+	ClusterResourceParameterComputeResource
+	UnitPrice   float64 `json:"unitPrice"`
+	MonthlyCost float64 `json:"monthlyCost"`
+	// Priced is false if no unit price is configured for the spec
+	Priced bool `json:"priced"`
+}
+
+// ResourceCostEstimate Projected monthly cost of instances, unpriced items are not counted
+type ResourceCostEstimate struct {
+	MonthlyCost float64            `json:"monthlyCost"`
+	Items       []ResourceCostItem `json:"items"`
+}
+
+// ZoneCapacityEstimate Share of the pool capacity in a zone consumed by instances, and the remaining headroom after allocation.
+// Consumed resources are weighted by the resource weight of specs
+type ZoneCapacityEstimate struct {
+	Zone             string  `json:"zoneCode"`
+	TotalCpuCores    int32   `json:"totalCpuCores"`
+	TotalMemory      int32   `json:"totalMemory"`
+	ConsumedCpuCores float64 `json:"consumedCpuCores"`
+	ConsumedMemory   float64 `json:"consumedMemory"`
+	CpuShare         float64 `json:"cpuShare" example:"0.25"`
+	MemoryShare      float64 `json:"memoryShare" example:"0.25"`
+	// HeadroomCpuCores and HeadroomMemory are negative if the pool is not enough
+	HeadroomCpuCores float64 `json:"headroomCpuCores"`
+	HeadroomMemory   float64 `json:"headroomMemory"`
+}
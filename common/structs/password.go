@@ -30,6 +30,9 @@ import (
 
 type SensitiveText string
 
+// SensitiveTextMask replacement of sensitive text in logs and responses
+const SensitiveTextMask = "******"
+
 type SensitiveTextEncoder struct {}
 
 func (p SensitiveTextEncoder) IsEmpty(ptr unsafe.Pointer) bool {
@@ -38,5 +41,5 @@ func (p SensitiveTextEncoder) IsEmpty(ptr unsafe.Pointer) bool {
 }
 
 func (p SensitiveTextEncoder) Encode(ptr unsafe.Pointer, stream *jsoniter.Stream) {
-	stream.WriteString("******")
+	stream.WriteString(SensitiveTextMask)
 }
@@ -35,6 +35,10 @@ type SpecInfo struct {
 	Memory      int    `json:"memory"`      //The amount of memory occupied by the instance, in GiB
 	DiskType    string `json:"diskType"`    //eg: NVMeSSD/SSD/SATA
 	PurposeType string `json:"purposeType"` // eg:Compute/Storage/Schedule
+	// UnitPrice monthly price of an instance of the spec, 0 means the spec is not priced
+	UnitPrice float64 `json:"unitPrice" example:"100"`
+	// ResourceWeight weight of the pool capacity consumed by an instance of the spec, eg. 1.2 for specs with reserved headroom, 0 is treated as 1
+	ResourceWeight float64 `json:"resourceWeight" example:"1"`
 }
 
 // ComponentInstanceResourceSpec Information on the resources required for the product components to run, including: memory, CPU, etc.
@@ -46,6 +50,9 @@ type ComponentInstanceResourceSpec struct {
 	DiskType string `json:"diskType"` //eg: NVMeSSD/SSD/SATA
 	ZoneID   string `json:"zoneId"`
 	ZoneName string `json:"zoneName"`
+	// UnitPrice and ResourceWeight see SpecInfo
+	UnitPrice      float64 `json:"unitPrice"`
+	ResourceWeight float64 `json:"resourceWeight"`
 }
 
 // ProductComponentPropertyWithZones Information about the components of the product, each of which consists of several different types of components
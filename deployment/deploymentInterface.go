@@ -36,6 +36,7 @@ const (
 	TiUPComponentTypeDM      TiUPComponentType = "dm"
 	TiUPComponentTypeEM      TiUPComponentType = "em"
 	TiUPComponentTypeCtrl    TiUPComponentType = "ctl"
+	TiUPComponentTypeBR      TiUPComponentType = "br"
 	TiUPComponentTypeDefault TiUPComponentType = "default"
 )
 
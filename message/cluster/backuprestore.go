@@ -29,9 +29,11 @@ import (
 
 // BackupClusterDataReq Requests for manual data backup
 type BackupClusterDataReq struct {
-	ClusterID  string `json:"clusterId"`
-	BackupType string `json:"backupType"` //full,incr
-	BackupMode string `json:"backupMode"` //auto,manual
+	ClusterID  string               `json:"clusterId"`
+	BackupType string               `json:"backupType"` //full,incr
+	BackupMode string               `json:"backupMode"` //auto,manual
+	Expirable  bool                 `json:"expirable"`  //manual backups are exempt from retention rules unless expirable
+	Filter     structs.BackupFilter `json:"filter"`     //the whole cluster is backed up if empty
 }
 
 // BackupClusterDataResp Cluster backup reply message
@@ -104,3 +106,197 @@ type DeleteBackupStrategyReq struct {
 // DeleteBackupStrategyResp delete backup strategy reply message
 type DeleteBackupStrategyResp struct {
 }
+
+// SaveBackupScheduleReq Request to create a backup schedule of a cluster, or update it if scheduleId is given
+type SaveBackupScheduleReq struct {
+	ClusterID  string                 `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	ScheduleID string                 `json:"scheduleId" swaggerignore:"true"`
+	Schedule   structs.BackupSchedule `json:"schedule"`
+}
+
+// SaveBackupScheduleResp Save backup schedule reply message
+type SaveBackupScheduleResp struct {
+	Schedule structs.BackupSchedule `json:"schedule"`
+}
+
+// QueryBackupSchedulesReq Query backup schedules of a cluster
+type QueryBackupSchedulesReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// QueryBackupSchedulesResp Query backup schedules reply message
+type QueryBackupSchedulesResp struct {
+	Schedules []structs.BackupSchedule `json:"schedules"`
+}
+
+// DeleteBackupScheduleReq Request to delete a backup schedule of a cluster
+type DeleteBackupScheduleReq struct {
+	ClusterID  string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	ScheduleID string `json:"scheduleId" swaggerignore:"true" validate:"required"`
+}
+
+// DeleteBackupScheduleResp Delete backup schedule reply message
+type DeleteBackupScheduleResp struct {
+}
+
+// RotateBackupKeyReq Request to create a new active data key encrypting backups of a cluster,
+// the method of current active key or system config BackupEncryptionMethod is used if method is empty
+type RotateBackupKeyReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Method    string `json:"method" enums:"aes128-ctr,aes192-ctr,aes256-ctr"`
+}
+
+// RotateBackupKeyResp Rotate backup key reply message
+type RotateBackupKeyResp struct {
+	Key structs.BackupKeyInfo `json:"key"`
+}
+
+// QueryBackupKeysReq Query data keys encrypting backups of a cluster
+type QueryBackupKeysReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// QueryBackupKeysResp Query backup keys reply message
+type QueryBackupKeysResp struct {
+	Keys []structs.BackupKeyInfo `json:"keys"`
+}
+
+// ExportBackupKeyReq Request to export a data key of a cluster, the key is sealed with passphrase
+type ExportBackupKeyReq struct {
+	ClusterID  string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	KeyID      string `json:"keyId" validate:"required"`
+	Passphrase string `json:"passphrase" validate:"required,min=8"`
+}
+
+// ExportBackupKeyResp Export backup key reply message
+type ExportBackupKeyResp struct {
+	Key       structs.BackupKeyInfo `json:"key"`
+	SealedKey string                `json:"sealedKey"`
+}
+
+// ImportBackupKeyReq Request to import a data key exported by another TiUniManager,
+// so that backups encrypted by it can be restored into the cluster
+type ImportBackupKeyReq struct {
+	ClusterID  string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	KeyID      string `json:"keyId" validate:"required"`
+	Method     string `json:"method" validate:"required" enums:"aes128-ctr,aes192-ctr,aes256-ctr"`
+	SealedKey  string `json:"sealedKey" validate:"required"`
+	Passphrase string `json:"passphrase" validate:"required,min=8"`
+}
+
+// ImportBackupKeyResp Import backup key reply message
+type ImportBackupKeyResp struct {
+	Key structs.BackupKeyInfo `json:"key"`
+}
+
+// ImportBackupReq Request to register a backup created by BR outside TiUniManager into the backup catalog,
+// backupTso, size, cluster version and covered databases are read from backupmeta of the backup
+type ImportBackupReq struct {
+	ClusterID  string `json:"clusterId"`                                                               // bind the backup to a cluster, unbound if empty
+	StorageURL string `json:"storageUrl" validate:"required" example:"s3://bucket/prefix/backup-full"` // s3://bucket/prefix or local:///path of the backup
+	// endpoint and credentials of s3, the backup s3 storage of system config is used if access key is empty
+	Endpoint        string `json:"endpoint" example:"http://127.0.0.1:9000"`
+	AccessKey       string `json:"accessKey"`
+	SecretAccessKey string `json:"secretAccessKey"`
+}
+
+// ImportBackupResp Import backup reply message
+type ImportBackupResp struct {
+	Record structs.BackupRecord `json:"record"`
+}
+
+// CheckRestoreReq Request to check a backup against the cluster it is restored into without restoring it,
+// the target is a new cluster of targetVersion if clusterId is empty
+type CheckRestoreReq struct {
+	BackupID       string               `json:"backupId" validate:"required,min=8,max=64"`
+	LocationID     string               `json:"locationId"` // check the copy of the backup, the primary location if empty
+	ClusterID      string               `json:"clusterId"`
+	TargetVersion  string               `json:"targetVersion"` // version of the new cluster, only if clusterId is empty
+	Filter         structs.BackupFilter `json:"filter"`
+	TargetDatabase string               `json:"targetDatabase"`
+}
+
+// CheckRestoreResp Restore check reply message, the restore is expected to fail if not passed
+type CheckRestoreResp struct {
+	Passed bool                       `json:"passed"`
+	Items  []structs.RestoreCheckItem `json:"items"`
+}
+
+// SaveBackupStorageTargetReq Request to create a backup storage target, or update it if targetId is given
+type SaveBackupStorageTargetReq struct {
+	TargetID string                      `json:"targetId" swaggerignore:"true"`
+	Target   structs.BackupStorageTarget `json:"target"`
+}
+
+// SaveBackupStorageTargetResp Save backup storage target reply message
+type SaveBackupStorageTargetResp struct {
+	Target structs.BackupStorageTarget `json:"target"`
+}
+
+// QueryBackupStorageTargetsReq Query all backup storage targets
+type QueryBackupStorageTargetsReq struct {
+}
+
+// QueryBackupStorageTargetsResp Query backup storage targets reply message
+type QueryBackupStorageTargetsResp struct {
+	Targets []structs.BackupStorageTarget `json:"targets"`
+}
+
+// DeleteBackupStorageTargetReq Request to delete a backup storage target not used by any strategy or backup copy
+type DeleteBackupStorageTargetReq struct {
+	TargetID string `json:"targetId" swaggerignore:"true" validate:"required"`
+}
+
+// DeleteBackupStorageTargetResp Delete backup storage target reply message
+type DeleteBackupStorageTargetResp struct {
+}
+
+// CopyBackupReq Request to copy a finished backup to a storage target asynchronously,
+// a failed copy on the same target is replaced
+type CopyBackupReq struct {
+	BackupID string `json:"backupId" validate:"required"`
+	TargetID string `json:"targetId" validate:"required"`
+}
+
+// CopyBackupResp Copy backup reply message, the location is Copying until the copy finishes
+type CopyBackupResp struct {
+	Location structs.BackupLocation `json:"location"`
+}
+
+// StartLogBackupReq Request to start continuous log backup of a cluster
+type StartLogBackupReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// StartLogBackupResp Start log backup reply message
+type StartLogBackupResp struct {
+	Task structs.LogBackupTaskInfo `json:"task"`
+}
+
+// StopLogBackupReq Request to stop continuous log backup of a cluster
+type StopLogBackupReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// StopLogBackupResp Stop log backup reply message
+type StopLogBackupResp struct {
+	Task structs.LogBackupTaskInfo `json:"task"`
+}
+
+// QueryLogBackupReq Query log backup tasks of a cluster
+type QueryLogBackupReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// QueryLogBackupResp Query log backup tasks reply message, newest first
+type QueryLogBackupResp struct {
+	Tasks []structs.LogBackupTaskInfo `json:"tasks"`
+}
+
+// PointInTimeRestoreTarget Target of point-in-time recovery using snapshot and log backups of the source cluster,
+// specified by either restoreTso or restoreTime
+type PointInTimeRestoreTarget struct {
+	SourceClusterID string `json:"sourceClusterId"` // cluster whose backups are used, default to the restored cluster itself
+	RestoreTSO      string `json:"restoreTso"`
+	RestoreTime     int64  `json:"restoreTime"` // unix timestamp in seconds, ignored if restoreTso is set
+}
@@ -22,12 +22,15 @@ import (
 )
 
 type CreateChangeFeedTaskReq struct {
-	Name           string      `json:"name" form:"name" example:"my_sync_name" validate:"required,min=4,max=64"`
-	ClusterID      string      `json:"clusterId" form:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22" validate:"required,min=4,max=64"`
-	StartTS        string      `json:"startTS" form:"startTS" example:"415241823337054209"`
-	FilterRules    []string    `json:"rules" form:"rules" example:"*.*"`
-	DownstreamType string      `json:"downstreamType"  form:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql" validate:"required,oneof=tidb kafka mysql"`
-	Downstream     interface{} `json:"downstream" form:"downstream"`
+	Name           string                     `json:"name" form:"name" example:"my_sync_name" validate:"required,min=4,max=64"`
+	ClusterID      string                     `json:"clusterId" form:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22" validate:"required,min=4,max=64"`
+	StartTS        string                     `json:"startTS" form:"startTS" example:"415241823337054209"`
+	FilterRules    []string                   `json:"rules" form:"rules" example:"*.*"`
+	DownstreamType string                     `json:"downstreamType"  form:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql,pulsar,storage" validate:"required,oneof=tidb kafka mysql pulsar storage"`
+	Downstream     interface{}                `json:"downstream" form:"downstream"`
+	AutoResume     ChangeFeedAutoResumePolicy `json:"autoResume" form:"autoResume"`
+	// wall-clock start time, converted to start ts. It must not be set together with StartTS
+	StartTime *time.Time `json:"startTime" form:"startTime" example:"2022-01-14T10:19:29+08:00"`
 }
 
 type CreateChangeFeedTaskResp struct {
@@ -49,6 +52,7 @@ type DetailChangeFeedTaskReq struct {
 
 type DetailChangeFeedTaskResp struct {
 	ChangeFeedTaskInfo
+	Events []ChangeFeedEvent `json:"events" form:"events"`
 }
 
 type PauseChangeFeedTaskReq struct {
@@ -68,11 +72,14 @@ type ResumeChangeFeedTaskResp struct {
 }
 
 type UpdateChangeFeedTaskReq struct {
-	ID             string      `json:"id" form:"id" swaggerignore:"true" validate:"required,min=8,max=64"`
-	Name           string      `json:"name" form:"name" example:"my_sync_name" validate:"required,min=4,max=64"`
-	FilterRules    []string    `json:"rules" form:"rules" example:"*.*"`
-	DownstreamType string      `json:"downstreamType"  form:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql"`
-	Downstream     interface{} `json:"downstream" form:"downstream"`
+	ID             string                     `json:"id" form:"id" swaggerignore:"true" validate:"required,min=8,max=64"`
+	Name           string                     `json:"name" form:"name" example:"my_sync_name" validate:"required,min=4,max=64"`
+	FilterRules    []string                   `json:"rules" form:"rules" example:"*.*"`
+	DownstreamType string                     `json:"downstreamType"  form:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql,pulsar,storage"`
+	Downstream     interface{}                `json:"downstream" form:"downstream"`
+	AutoResume     ChangeFeedAutoResumePolicy `json:"autoResume" form:"autoResume"`
+	// replicate again from this wall-clock time, the task is re-created in cdc and running after update
+	StartTime *time.Time `json:"startTime" form:"startTime" example:"2022-01-14T10:19:29+08:00"`
 }
 
 type UpdateChangeFeedTaskResp struct {
@@ -89,21 +96,40 @@ type DeleteChangeFeedTaskResp struct {
 }
 
 type ChangeFeedTask struct {
-	ID             string      `json:"id" form:"id" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
-	Name           string      `json:"name" form:"name" example:"my_sync_name"`
-	ClusterID      string      `json:"clusterId" form:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
-	StartTS        string      `json:"startTS" form:"startTS" example:"415241823337054209"`
-	FilterRules    []string    `json:"rules" form:"rules" example:"*.*"`
-	Status         string      `json:"status" form:"status" example:"Normal" enums:"Initial,Normal,Stopped,Finished,Error,Failed"`
-	DownstreamType string      `json:"downstreamType"  form:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql"`
-	Downstream     interface{} `json:"downstream" form:"downstream"`
-	CreateTime     time.Time   `json:"createTime" form:"createTime"`
-	UpdateTime     time.Time   `json:"updateTime" form:"updateTime"`
+	ID             string                     `json:"id" form:"id" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	Name           string                     `json:"name" form:"name" example:"my_sync_name"`
+	ClusterID      string                     `json:"clusterId" form:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	StartTS        string                     `json:"startTS" form:"startTS" example:"415241823337054209"`
+	FilterRules    []string                   `json:"rules" form:"rules" example:"*.*"`
+	Status         string                     `json:"status" form:"status" example:"Normal" enums:"Initial,Normal,Stopped,Finished,Error,Failed"`
+	DownstreamType string                     `json:"downstreamType"  form:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql,pulsar,storage"`
+	Downstream     interface{}                `json:"downstream" form:"downstream"`
+	AutoResume     ChangeFeedAutoResumePolicy `json:"autoResume" form:"autoResume"`
+	CreateTime     time.Time                  `json:"createTime" form:"createTime"`
+	UpdateTime     time.Time                  `json:"updateTime" form:"updateTime"`
+}
+
+//
+// ChangeFeedAutoResumePolicy
+// @Description: resume task in error state automatically with exponential backoff,
+// errors which cannot be recovered by resuming, such as gc ttl exceeded, are never retried
+//
+type ChangeFeedAutoResumePolicy struct {
+	Enabled bool `json:"enabled" form:"enabled" example:"true"`
+	// 0 means system default
+	MaxRetries int `json:"maxRetries" form:"maxRetries" example:"5" validate:"min=0"`
+}
+
+type ChangeFeedEvent struct {
+	ID         string    `json:"id" form:"id" example:"EVENT_ID_IN_TIUNIMANAGER__22"`
+	Type       string    `json:"type" form:"type" example:"LagWarning" enums:"LagWarning,LagCritical,LagRecovered,TaskError,TaskRecovered,AutoResumed,AutoResumeFailed,AutoResumeExhausted"`
+	Message    string    `json:"message" form:"message" example:"checkpoint lag 75s exceeds warning threshold 60s"`
+	CreateTime time.Time `json:"createTime" form:"createTime"`
 }
 
 //
 // MysqlDownstream
-// @Description: only for swagger, never use
+// @Description: only for swagger, never use. Masked password in update request keeps the current one
 //
 type MysqlDownstream struct {
 	Ip                string                `json:"ip" form:"ip" example:"127.0.0.1"`
@@ -118,21 +144,59 @@ type MysqlDownstream struct {
 
 //
 // KafkaDownstream
-// @Description: only for swagger, never use
+// @Description: only for swagger, never use. Masked or empty sasl password in update request keeps the current one
 //
 type KafkaDownstream struct {
-	Ip                string       `json:"ip" form:"ip" example:"127.0.0.1"`
-	Port              int          `json:"port" form:"port" example:"9001"`
-	Version           string       `json:"version" form:"version" example:"2.4.0"`
-	ClientID          string       `json:"clientId" form:"clientId" example:"213"`
-	TopicName         string       `json:"topicName" form:"topicName" example:"my_topic"`
-	Protocol          string       `json:"protocol" form:"protocol" example:"default" enums:"default,canal,avro,maxwell"`
-	Partitions        int          `json:"partitions" form:"partitions" example:"1"`
-	ReplicationFactor int          `json:"replicationFactor" form:"replicationFactor" example:"1"`
-	MaxMessageBytes   int          `json:"maxMessageBytes" form:"maxMessageBytes" example:"16"`
-	MaxBatchSize      int          `json:"maxBatchSize" form:"maxBatchSize" example:"5"`
-	Dispatchers       []Dispatcher `json:"dispatchers" form:"dispatchers"`
-	Tls               bool         `json:"tls" form:"tls" example:"false"`
+	Ip                string                `json:"ip" form:"ip" example:"127.0.0.1"`
+	Port              int                   `json:"port" form:"port" example:"9001"`
+	Version           string                `json:"version" form:"version" example:"2.4.0"`
+	ClientID          string                `json:"clientId" form:"clientId" example:"213"`
+	TopicName         string                `json:"topicName" form:"topicName" example:"my_topic"`
+	Protocol          string                `json:"protocol" form:"protocol" example:"default" enums:"default,canal,canal-json,avro,maxwell,open-protocol"`
+	Partitions        int                   `json:"partitions" form:"partitions" example:"1"`
+	ReplicationFactor int                   `json:"replicationFactor" form:"replicationFactor" example:"1"`
+	MaxMessageBytes   int                   `json:"maxMessageBytes" form:"maxMessageBytes" example:"16"`
+	MaxBatchSize      int                   `json:"maxBatchSize" form:"maxBatchSize" example:"5"`
+	Dispatchers       []Dispatcher          `json:"dispatchers" form:"dispatchers"`
+	Tls               bool                  `json:"tls" form:"tls" example:"false"`
+	CAPath            string                `json:"caPath" form:"caPath" example:"/etc/kafka/ca.pem"`
+	CertPath          string                `json:"certPath" form:"certPath" example:"/etc/kafka/client.pem"`
+	KeyPath           string                `json:"keyPath" form:"keyPath" example:"/etc/kafka/client-key.pem"`
+	SaslMechanism     string                `json:"saslMechanism" form:"saslMechanism" example:"scram-sha-256" enums:"plain,scram-sha-256,scram-sha-512"`
+	SaslUser          string                `json:"saslUser" form:"saslUser" example:"cdc"`
+	SaslPassword      structs.SensitiveText `json:"saslPassword" form:"saslPassword" example:"my_password"`
+	Compression       string                `json:"compression" form:"compression" example:"lz4" enums:"none,gzip,snappy,lz4,zstd"`
+	SchemaRegistry    string                `json:"schemaRegistry" form:"schemaRegistry" example:"http://127.0.0.1:8081"`
+	TopicExpression   string                `json:"topicExpression" form:"topicExpression" example:"{schema}_{table}"`
+	ColumnSelectors   []ColumnSelector      `json:"columnSelectors" form:"columnSelectors"`
+}
+
+//
+// PulsarDownstream
+// @Description: only for swagger, never use
+//
+type PulsarDownstream struct {
+	Ip        string                `json:"ip" form:"ip" example:"127.0.0.1"`
+	Port      int                   `json:"port" form:"port" example:"6650"`
+	TopicName string                `json:"topicName" form:"topicName" example:"persistent://public/default/my_topic"`
+	Protocol  string                `json:"protocol" form:"protocol" example:"canal-json" enums:"canal-json"`
+	Token     structs.SensitiveText `json:"token" form:"token" example:"my_token"`
+	Tls       bool                  `json:"tls" form:"tls" example:"false"`
+}
+
+//
+// StorageDownstream
+// @Description: only for swagger, never use. Masked or empty keys in update request keep the current ones
+//
+type StorageDownstream struct {
+	StorageType     string                `json:"storageType" form:"storageType" example:"s3" enums:"s3,local"`
+	Path            string                `json:"path" form:"path" example:"my_bucket/cdc"`
+	Endpoint        string                `json:"endpoint" form:"endpoint" example:"http://127.0.0.1:9000"`
+	AccessKey       structs.SensitiveText `json:"accessKey" form:"accessKey" example:"minioadmin"`
+	SecretAccessKey structs.SensitiveText `json:"secretAccessKey" form:"secretAccessKey" example:"minioadmin"`
+	Protocol        string                `json:"protocol" form:"protocol" example:"csv" enums:"csv,canal-json"`
+	FlushInterval   int                   `json:"flushInterval" form:"flushInterval" example:"5"`
+	FileSize        int                   `json:"fileSize" form:"fileSize" example:"67108864"`
 }
 
 //
@@ -142,12 +206,22 @@ type KafkaDownstream struct {
 type Dispatcher struct {
 	Matcher    string `json:"matcher" form:"matcher" example:"test1.*"`
 	Dispatcher string `json:"dispatcher" form:"dispatcher" example:"ts"`
+	Topic      string `json:"topic" form:"topic" example:"test1_{table}"`
 }
 
 //
-// TiDBDownstream
+// ColumnSelector
 // @Description: only for swagger, never use
 //
+type ColumnSelector struct {
+	Matcher string   `json:"matcher" form:"matcher" example:"test1.t1"`
+	Columns []string `json:"columns" form:"columns" example:"id,name"`
+}
+
+//
+// TiDBDownstream
+// @Description: only for swagger, never use. Masked password in update request keeps the current one
+//
 type TiDBDownstream struct {
 	Ip                string                `json:"ip" form:"ip" example:"127.0.0.1"`
 	Port              int                   `json:"port" form:"port" example:"4534"`
@@ -170,6 +244,12 @@ type ChangeFeedTaskInfo struct {
 	DownstreamFetchUnix int64  `json:"downstreamFetchUnix" form:"downstreamFetchUnix" example:"1642402879000"`
 	DownstreamSyncTS    string `json:"downstreamSyncTs" form:"downstreamSyncTs" example:"415241823337054209"`
 	DownstreamSyncUnix  int64  `json:"downstreamSyncUnix" form:"downstreamSyncUnix" example:"1642402879000"`
+	// checkpoint lag in milliseconds, measured by change feed watcher at CheckedTime
+	CheckpointLag int64     `json:"checkpointLag" form:"checkpointLag" example:"1200"`
+	LagLevel      string    `json:"lagLevel" form:"lagLevel" example:"Normal" enums:"Normal,Warning,Critical"`
+	CheckedTime   time.Time `json:"checkedTime" form:"checkedTime"`
+	LastError     string    `json:"lastError" form:"lastError" example:"[CDC:ErrKafkaNewSaramaProducer]..."`
+	ResumeRetries int       `json:"resumeRetries" form:"resumeRetries" example:"0"`
 }
 
 func (p *ChangeFeedTaskInfo) ConvertStartTS() {
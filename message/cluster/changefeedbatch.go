@@ -0,0 +1,84 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package cluster
+
+import "time"
+
+// ChangeFeedTaskSelector Select change feed tasks by ids, or by cluster with optional filters
+type ChangeFeedTaskSelector struct {
+	// if ids are specified, ClusterID and filters are ignored
+	IDs       []string `json:"ids" form:"ids" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	ClusterID string   `json:"clusterId" form:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22" validate:"max=64"`
+	// empty means all downstream types
+	DownstreamTypes []string `json:"downstreamTypes" form:"downstreamTypes" example:"tidb"`
+	// empty means all status
+	Statuses []string `json:"statuses" form:"statuses" example:"Normal"`
+}
+
+// BatchChangeFeedTasksReq Message for pausing, resuming or deleting change feed tasks in batch
+type BatchChangeFeedTasksReq struct {
+	ChangeFeedTaskSelector
+}
+
+// ChangeFeedTaskResult result of one change feed task in batch operation
+type ChangeFeedTaskResult struct {
+	ID      string `json:"id" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	Name    string `json:"name" example:"my_sync_name"`
+	Status  string `json:"status" example:"Normal" enums:"Initial,Normal,Stopped,Finished,Error,Failed"`
+	Succeed bool   `json:"succeed" example:"true"`
+	Message string `json:"message" example:""`
+}
+
+// BatchChangeFeedTasksResp Reply message for batch operation, one result for each selected task
+type BatchChangeFeedTasksResp struct {
+	Results []ChangeFeedTaskResult `json:"results"`
+}
+
+// ExportChangeFeedTasksReq Message for exporting change feed tasks as json
+type ExportChangeFeedTasksReq struct {
+	ChangeFeedTaskSelector
+}
+
+// ChangeFeedTaskExport exported change feed task, sensitive fields of downstream are masked
+type ChangeFeedTaskExport struct {
+	SourceTaskID   string                     `json:"sourceTaskId" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	Name           string                     `json:"name" example:"my_sync_name" validate:"required,min=4,max=64"`
+	FilterRules    []string                   `json:"rules" example:"*.*"`
+	DownstreamType string                     `json:"downstreamType" example:"tidb" enums:"tidb,kafka,mysql,pulsar,storage" validate:"required,oneof=tidb kafka mysql pulsar storage"`
+	Downstream     interface{}                `json:"downstream"`
+	AutoResume     ChangeFeedAutoResumePolicy `json:"autoResume"`
+}
+
+// ExportChangeFeedTasksResp Reply message for exporting change feed tasks, it can be imported as it is
+type ExportChangeFeedTasksResp struct {
+	ClusterID  string                 `json:"clusterId"`
+	ExportTime time.Time              `json:"exportTime"`
+	Tasks      []ChangeFeedTaskExport `json:"tasks"`
+}
+
+// ImportChangeFeedTasksReq Message for importing exported change feed tasks into a cluster.
+// Masked secrets of downstream are restored from source task if it still exists
+type ImportChangeFeedTasksReq struct {
+	ClusterID string                 `json:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22" validate:"required,min=4,max=64"`
+	Tasks     []ChangeFeedTaskExport `json:"tasks" validate:"required,dive"`
+	// replicate from this wall-clock time, current time if empty
+	StartTime *time.Time `json:"startTime" example:"2022-01-14T10:19:29+08:00"`
+}
+
+// ImportChangeFeedTasksResp Reply message for importing change feed tasks
+type ImportChangeFeedTasksResp struct {
+	Results []ChangeFeedTaskResult `json:"results"`
+}
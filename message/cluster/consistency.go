@@ -0,0 +1,161 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/structs"
+)
+
+// ConsistencyCheckOptions tables and output of a consistency check
+type ConsistencyCheckOptions struct {
+	// all tables replicated by change feed are checked if both databases and tables are empty
+	Databases []string `json:"databases" form:"databases" example:"db1"`
+	// in format of db.table
+	Tables []string `json:"tables" form:"tables" example:"db2.table1"`
+	// generate statements making downstream the same as upstream for mismatched rows, only at a sync point of change feed
+	GenerateFixSQL bool `json:"generateFixSQL" form:"generateFixSQL" example:"false"`
+}
+
+// StartConsistencyCheckReq Message for verifying data consistency between upstream cluster and downstream of a change feed task
+type StartConsistencyCheckReq struct {
+	ChangeFeedTaskID string `json:"changeFeedTaskId" form:"changeFeedTaskId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	ConsistencyCheckOptions
+}
+
+// StartConsistencyCheckResp Reply message for starting a consistency check
+type StartConsistencyCheckResp struct {
+	structs.AsyncTaskWorkFlowInfo
+	CheckID string `json:"checkId" example:"CHECK_ID_IN_TIUNIMANAGER___22"`
+}
+
+// QueryConsistencyChecksReq Message for querying consistency checks, e.g. of a master/slave relation by clusterId and downstreamClusterId
+type QueryConsistencyChecksReq struct {
+	ClusterID           string `json:"clusterId" form:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	DownstreamClusterID string `json:"downstreamClusterId" form:"downstreamClusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	ChangeFeedTaskID    string `json:"changeFeedTaskId" form:"changeFeedTaskId" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	Status              string `json:"status" form:"status" example:"Inconsistent" enums:"Running,Consistent,Inconsistent,Failed"`
+	structs.PageRequest
+}
+
+// DetailConsistencyCheckReq Message for getting result of a consistency check
+type DetailConsistencyCheckReq struct {
+	CheckID string `json:"checkId" form:"checkId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// DetailConsistencyCheckResp Reply message of consistency check detail
+type DetailConsistencyCheckResp struct {
+	ConsistencyCheck
+	// mismatched tables
+	Tables []ConsistencyTableDiff `json:"tables"`
+	FixSQL []string               `json:"fixSQL"`
+}
+
+// ConsistencyCheck summary of a consistency check
+type ConsistencyCheck struct {
+	ID                  string `json:"id" example:"CHECK_ID_IN_TIUNIMANAGER___22"`
+	ChangeFeedTaskID    string `json:"changeFeedTaskId" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	ClusterID           string `json:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	DownstreamClusterID string `json:"downstreamClusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	Status              string `json:"status" example:"Inconsistent" enums:"Running,Consistent,Inconsistent,Failed"`
+	Trigger             string `json:"trigger" example:"Manual" enums:"Manual,Schedule"`
+	WorkFlowID          string `json:"workFlowId"`
+	ConsistencyCheckOptions
+	// SyncPoint: snapshots of the latest sync point of TiDB downstream, Checkpoint: upstream at checkpoint of change feed and
+	// downstream at current data, in which case rows changed after checkpoint may be reported as mismatched
+	SnapshotMode   string    `json:"snapshotMode" example:"SyncPoint" enums:"SyncPoint,Checkpoint"`
+	UpstreamTSO    string    `json:"upstreamTSO" example:"415241823337054209"`
+	DownstreamTSO  string    `json:"downstreamTSO" example:"415241823337054210"`
+	CheckedTables  int       `json:"checkedTables" example:"10"`
+	MismatchTables int       `json:"mismatchTables" example:"1"`
+	MismatchChunks int       `json:"mismatchChunks" example:"2"`
+	Message        string    `json:"message"`
+	StartTime      time.Time `json:"startTime"`
+	EndTime        time.Time `json:"endTime"`
+}
+
+// ConsistencyTableDiff mismatched table of consistency check
+type ConsistencyTableDiff struct {
+	Database string                 `json:"database" example:"db1"`
+	Table    string                 `json:"table" example:"table1"`
+	Message  string                 `json:"message" example:"table does not exist in downstream"`
+	Chunks   int                    `json:"chunks" example:"10"`
+	Mismatch []ConsistencyChunkDiff `json:"mismatch"`
+}
+
+// ConsistencyChunkDiff mismatched chunk of table, the rows in key range differ between upstream and downstream
+type ConsistencyChunkDiff struct {
+	Range              string `json:"range" example:"(id) > (100) AND (id) <= (200)"`
+	UpstreamRows       int64  `json:"upstreamRows" example:"100"`
+	DownstreamRows     int64  `json:"downstreamRows" example:"99"`
+	UpstreamChecksum   uint64 `json:"upstreamChecksum"`
+	DownstreamChecksum uint64 `json:"downstreamChecksum"`
+}
+
+// ConsistencySchedule periodic consistency check of a change feed task
+type ConsistencySchedule struct {
+	// standard 5-field cron expression or descriptor such as @daily
+	CronSpec string `json:"cronSpec" form:"cronSpec" example:"0 2 * * *" validate:"required"`
+	// IANA time zone name, local time zone of server if empty
+	TimeZone string `json:"timeZone" form:"timeZone" example:"Asia/Shanghai"`
+	Paused   bool   `json:"paused" form:"paused" example:"false"`
+	ConsistencyCheckOptions
+}
+
+// ConsistencyScheduleInfo consistency check schedule and its state
+type ConsistencyScheduleInfo struct {
+	ID               string `json:"id"`
+	ChangeFeedTaskID string `json:"changeFeedTaskId"`
+	ClusterID        string `json:"clusterId"`
+	ConsistencySchedule
+	NextRunTime       time.Time `json:"nextRunTime"`
+	LastScheduledTime time.Time `json:"lastScheduledTime"`
+	LastCheckID       string    `json:"lastCheckId"`
+	CreateTime        time.Time `json:"createTime"`
+	UpdateTime        time.Time `json:"updateTime"`
+}
+
+// SaveConsistencyScheduleReq Message for creating or replacing the consistency check schedule of a change feed task
+type SaveConsistencyScheduleReq struct {
+	ChangeFeedTaskID string `json:"changeFeedTaskId" form:"changeFeedTaskId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	ConsistencySchedule
+}
+
+// SaveConsistencyScheduleResp Reply message of saving consistency check schedule
+type SaveConsistencyScheduleResp struct {
+	Schedule ConsistencyScheduleInfo `json:"schedule"`
+}
+
+// GetConsistencyScheduleReq Message for getting the consistency check schedule of a change feed task
+type GetConsistencyScheduleReq struct {
+	ChangeFeedTaskID string `json:"changeFeedTaskId" form:"changeFeedTaskId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// GetConsistencyScheduleResp Reply message of getting consistency check schedule
+type GetConsistencyScheduleResp struct {
+	Schedule ConsistencyScheduleInfo `json:"schedule"`
+}
+
+// DeleteConsistencyScheduleReq Message for deleting the consistency check schedule of a change feed task
+type DeleteConsistencyScheduleReq struct {
+	ChangeFeedTaskID string `json:"changeFeedTaskId" form:"changeFeedTaskId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// DeleteConsistencyScheduleResp Reply message of deleting consistency check schedule
+type DeleteConsistencyScheduleResp struct {
+}
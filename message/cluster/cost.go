@@ -0,0 +1,42 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package cluster
+
+import "github.com/pingcap/tiunimanager/common/structs"
+
+// ClusterCostReport Projected monthly cost of the resources held by a cluster
+type ClusterCostReport struct {
+	ClusterID   string `json:"clusterId"`
+	ClusterName string `json:"clusterName"`
+	Vendor      string `json:"vendor"`
+	Region      string `json:"region"`
+	structs.ResourceCostEstimate
+}
+
+// QueryTenantCostReportReq Message for querying the cost report of clusters of a tenant
+type QueryTenantCostReportReq struct {
+	TenantID string `json:"tenantId" form:"tenantId" swaggerignore:"true" validate:"required"`
+}
+
+// QueryTenantCostReportResp Reply message for querying the cost report of clusters of a tenant
+type QueryTenantCostReportResp struct {
+	TenantID    string              `json:"tenantId"`
+	MonthlyCost float64             `json:"monthlyCost"`
+	Clusters    []ClusterCostReport `json:"clusters"`
+	// CapacityUsages share of the pool capacity consumed by the tenant in each zone, headroom is what remains in the pool
+	CapacityUsages []structs.ZoneCapacityEstimate `json:"capacityUsages"`
+}
@@ -0,0 +1,208 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package cluster
+
+import (
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/structs"
+)
+
+// RegisterDMSourceReq Message for registering an upstream MySQL instance as source of DM cluster
+type RegisterDMSourceReq struct {
+	ClusterID  string                `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Name       string                `json:"name" example:"mysql-01" validate:"required,min=1,max=32"`
+	Host       string                `json:"host" example:"172.16.6.252" validate:"required"`
+	Port       int                   `json:"port" example:"3306" validate:"required,min=1,max=65535"`
+	User       string                `json:"user" example:"root" validate:"required"`
+	Password   structs.SensitiveText `json:"password" example:"my_password"`
+	EnableGTID bool                  `json:"enableGtid" example:"false"`
+}
+
+// DMSourceInfo source of DM cluster, password is never returned
+type DMSourceInfo struct {
+	Name       string    `json:"name" example:"mysql-01"`
+	Host       string    `json:"host" example:"172.16.6.252"`
+	Port       int       `json:"port" example:"3306"`
+	User       string    `json:"user" example:"root"`
+	EnableGTID bool      `json:"enableGtid" example:"false"`
+	CreateTime time.Time `json:"createTime"`
+}
+
+// RegisterDMSourceResp Reply message for registering a source of DM cluster
+type RegisterDMSourceResp struct {
+	DMSourceInfo
+}
+
+// QueryDMSourcesReq Message for querying sources of DM cluster
+type QueryDMSourcesReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// QueryDMSourcesResp Reply message for querying sources of DM cluster
+type QueryDMSourcesResp struct {
+	Sources []DMSourceInfo `json:"sources"`
+}
+
+// DeleteDMSourceReq Message for deleting a source of DM cluster, the source must not be used by unfinished tasks
+type DeleteDMSourceReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Name      string `json:"name" swaggerignore:"true" validate:"required,min=1,max=32"`
+}
+
+// DeleteDMSourceResp Reply message for deleting a source of DM cluster
+type DeleteDMSourceResp struct {
+}
+
+// DMTableRule table matched by block allow list
+type DMTableRule struct {
+	DBName  string `json:"dbName" example:"db1"`
+	TblName string `json:"tblName" example:"t1"`
+}
+
+// DMBlockAllowList schemas and tables to be migrated, all of them are migrated if empty
+type DMBlockAllowList struct {
+	DoDBs        []string      `json:"doDbs" example:"db1"`
+	DoTables     []DMTableRule `json:"doTables"`
+	IgnoreDBs    []string      `json:"ignoreDbs" example:"mysql"`
+	IgnoreTables []DMTableRule `json:"ignoreTables"`
+}
+
+// DMRouteRule migrate matched upstream schemas and tables to target schema and table
+type DMRouteRule struct {
+	SchemaPattern string `json:"schemaPattern" example:"sharding_db_*" validate:"required"`
+	TablePattern  string `json:"tablePattern" example:"sharding_t_*"`
+	TargetSchema  string `json:"targetSchema" example:"db1" validate:"required"`
+	TargetTable   string `json:"targetTable" example:"t1"`
+}
+
+// CreateDMTaskReq Message for creating a data migration task from sources of DM cluster to target cluster
+type CreateDMTaskReq struct {
+	// DM cluster
+	ClusterID       string           `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Name            string           `json:"name" example:"my_migration" validate:"required,min=1,max=32"`
+	TargetClusterID string           `json:"targetClusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22" validate:"required,min=4,max=64"`
+	TaskMode        string           `json:"taskMode" example:"all" enums:"all,full,incremental" validate:"required,oneof=all full incremental"`
+	Sources         []string         `json:"sources" example:"mysql-01" validate:"required,min=1"`
+	BlockAllowList  DMBlockAllowList `json:"blockAllowList"`
+	Routes          []DMRouteRule    `json:"routes"`
+}
+
+// CreateDMTaskResp Reply message for creating a data migration task
+type CreateDMTaskResp struct {
+	ID string `json:"id" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+}
+
+// DMTaskReq Message for starting, pausing or stopping a data migration task
+type DMTaskReq struct {
+	ID string `json:"id" swaggerignore:"true" validate:"required,min=8,max=64"`
+}
+
+// DMTaskResp Reply message for starting, pausing or stopping a data migration task
+type DMTaskResp struct {
+	Status string `json:"status" example:"Running" enums:"Created,Running,Paused,Error,Stopped,HandedOff"`
+}
+
+// QueryDMTasksReq Message for querying data migration tasks of DM cluster
+type QueryDMTasksReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Status    string `json:"status" form:"status" example:"Running" enums:"Created,Running,Paused,Error,Stopped,HandedOff"`
+	structs.PageRequest
+}
+
+// DMTaskInfo data migration task, progress is refreshed by dm task watcher at CheckedTime
+type DMTaskInfo struct {
+	ID              string           `json:"id" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	Name            string           `json:"name" example:"my_migration"`
+	ClusterID       string           `json:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	TargetClusterID string           `json:"targetClusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	TaskMode        string           `json:"taskMode" example:"all" enums:"all,full,incremental"`
+	Sources         []string         `json:"sources" example:"mysql-01"`
+	BlockAllowList  DMBlockAllowList `json:"blockAllowList"`
+	Routes          []DMRouteRule    `json:"routes"`
+	Status          string           `json:"status" example:"Running" enums:"Created,Running,Paused,Error,Stopped,HandedOff"`
+	// unit of the slowest sub task
+	Unit   string `json:"unit" example:"Sync" enums:"Dump,Load,Sync"`
+	Synced bool   `json:"synced" example:"true"`
+	// seconds behind upstream, max of all sources
+	Lag           int64     `json:"lag" example:"3"`
+	LastError     string    `json:"lastError"`
+	CheckedTime   time.Time `json:"checkedTime"`
+	HandoffType   string    `json:"handoffType" example:"ChangeFeed" enums:"ChangeFeed,Switchover"`
+	HandoffTarget string    `json:"handoffTarget" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+	CreateTime    time.Time `json:"createTime"`
+	UpdateTime    time.Time `json:"updateTime"`
+}
+
+// QueryDMTasksResp Reply message for querying data migration tasks
+type QueryDMTasksResp struct {
+	Tasks []DMTaskInfo `json:"tasks"`
+}
+
+// DMSubTaskStatus status of data migration task on one source, reported by dm-master
+type DMSubTaskStatus struct {
+	Source       string `json:"source" example:"mysql-01"`
+	Worker       string `json:"worker" example:"dm-172.16.6.252-8262"`
+	Stage        string `json:"stage" example:"Running" enums:"New,Running,Paused,Stopped,Finished"`
+	Unit         string `json:"unit" example:"Sync" enums:"Dump,Load,Sync"`
+	Synced       bool   `json:"synced" example:"true"`
+	Lag          int64  `json:"lag" example:"3"`
+	MasterBinlog string `json:"masterBinlog" example:"(mysql-bin.000001, 2022)"`
+	SyncerBinlog string `json:"syncerBinlog" example:"(mysql-bin.000001, 2022)"`
+	ErrorMsg     string `json:"errorMsg"`
+}
+
+// DetailDMTaskReq Message for getting a data migration task with live status of sub tasks
+type DetailDMTaskReq struct {
+	ID string `json:"id" swaggerignore:"true" validate:"required,min=8,max=64"`
+}
+
+// DetailDMTaskResp Reply message for getting a data migration task
+type DetailDMTaskResp struct {
+	DMTaskInfo
+	SubTasks []DMSubTaskStatus `json:"subTasks"`
+}
+
+// HandoffDMTaskReq Message for handing off a migrated target cluster to a change feed task or a standby cluster.
+// Writes to upstream sources must be stopped, and every sub task must be synced without lag. The task is stopped after handoff
+type HandoffDMTaskReq struct {
+	ID   string `json:"id" swaggerignore:"true" validate:"required,min=8,max=64"`
+	Type string `json:"type" example:"ChangeFeed" enums:"ChangeFeed,Switchover" validate:"required,oneof=ChangeFeed Switchover"`
+	// confirm that writes to upstream sources have stopped, otherwise changes after the task is stopped are lost
+	UpstreamWritesStopped bool `json:"upstreamWritesStopped" example:"true"`
+	// change feed task created in target cluster, required by ChangeFeed handoff
+	ChangeFeed *DMHandoffChangeFeed `json:"changeFeed"`
+	// standby of target cluster, required by Switchover handoff
+	StandbyClusterID string `json:"standbyClusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	// finished full backup of target cluster which standby cluster is restored from, required by Switchover handoff
+	BackupID string `json:"backupId" example:"BACKUP_ID_IN_TIUNIMANAGER___22"`
+}
+
+// DMHandoffChangeFeed change feed task replicating target cluster after migration, it starts from the time of handoff
+type DMHandoffChangeFeed struct {
+	Name           string                     `json:"name" example:"my_sync_name" validate:"required,min=4,max=64"`
+	FilterRules    []string                   `json:"rules" example:"*.*"`
+	DownstreamType string                     `json:"downstreamType" example:"mysql" enums:"tidb,kafka,mysql,pulsar,storage" validate:"required,oneof=tidb kafka mysql pulsar storage"`
+	Downstream     interface{}                `json:"downstream"`
+	AutoResume     ChangeFeedAutoResumePolicy `json:"autoResume"`
+}
+
+// HandoffDMTaskResp Reply message for handing off a data migration task
+type HandoffDMTaskResp struct {
+	Status        string `json:"status" example:"HandedOff" enums:"Running,HandedOff"`
+	HandoffType   string `json:"handoffType" example:"ChangeFeed" enums:"ChangeFeed,Switchover"`
+	HandoffTarget string `json:"handoffTarget" example:"TASK_ID_IN_TIUNIMANAGER____22"`
+}
@@ -0,0 +1,68 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package cluster
+
+import "time"
+
+// FailoverPolicy automatic failover policy of a standby cluster
+type FailoverPolicy struct {
+	Enabled bool `json:"enabled"`
+	// consecutive rounds in which master is confirmed unavailable by more than one probe source, 3 if 0
+	Threshold int `json:"threshold" example:"3" validate:"min=0,max=100"`
+	// seconds, the standby is never chosen if it lags behind master more than this, 0 means unlimited
+	MaxLag int `json:"maxLag" example:"60" validate:"min=0"`
+}
+
+// FailoverEvent event raised by automatic failover
+type FailoverEvent struct {
+	MasterClusterID  string    `json:"masterClusterId"`
+	StandbyClusterID string    `json:"standbyClusterId"`
+	Type             string    `json:"type" enums:"ProbeFailed,Recovered,Triggered,Failed,Skipped"`
+	Message          string    `json:"message"`
+	WorkFlowID       string    `json:"workFlowId"`
+	CreateTime       time.Time `json:"createTime"`
+}
+
+// QueryFailoverPolicyReq Message for querying automatic failover policy of a standby cluster
+type QueryFailoverPolicyReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// QueryFailoverPolicyResp Reply message for querying automatic failover policy of a standby cluster
+type QueryFailoverPolicyResp struct {
+	ClusterID       string         `json:"clusterId"`
+	MasterClusterID string         `json:"masterClusterId"`
+	Policy          FailoverPolicy `json:"policy"`
+	// consecutive rounds in which master is confirmed unavailable, kept in memory of cluster server
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// latest events of the cluster as master or standby, newest first
+	Events []FailoverEvent `json:"events"`
+}
+
+// UpdateFailoverPolicyReq Message for updating automatic failover policy of a standby cluster.
+// The policy is disarmed by switchover, and it should be enabled again after reviewing the new topology
+type UpdateFailoverPolicyReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	FailoverPolicy
+}
+
+// UpdateFailoverPolicyResp Reply message for updating automatic failover policy of a standby cluster
+type UpdateFailoverPolicyResp struct {
+	ClusterID       string         `json:"clusterId"`
+	MasterClusterID string         `json:"masterClusterId"`
+	Policy          FailoverPolicy `json:"policy"`
+}
@@ -0,0 +1,77 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package cluster
+
+import "time"
+
+// QueryClusterGCReq Message for querying gc status of a cluster
+type QueryClusterGCReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// ServiceGCSafePoint service safepoint registered in PD, such as by TiCDC or BR
+type ServiceGCSafePoint struct {
+	ServiceID     string    `json:"serviceId" example:"ticdc"`
+	SafePoint     uint64    `json:"safePoint" example:"431434047157698561"`
+	SafePointTime time.Time `json:"safePointTime"`
+	ExpiredAt     time.Time `json:"expiredAt"`
+}
+
+// GCHoldInfo gc hold taken on a cluster
+type GCHoldInfo struct {
+	Name       string    `json:"name" example:"clone-CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	Holder     string    `json:"holder" example:"WORKFLOW_ID_IN_TIUNIMANAGER_22"`
+	GCLifeTime string    `json:"gcLifeTime" example:"720h"`
+	ExpireTime time.Time `json:"expireTime"`
+	CreateTime time.Time `json:"createTime"`
+	Expired    bool      `json:"expired" example:"false"`
+}
+
+// QueryClusterGCResp Reply message for querying gc status of a cluster
+type QueryClusterGCResp struct {
+	ClusterID     string    `json:"clusterId" example:"CLUSTER_ID_IN_TIUNIMANAGER__22"`
+	GCLifeTime    string    `json:"gcLifeTime" example:"10m0s"`
+	SafePointTime time.Time `json:"safePointTime"`
+	// tidb_gc_life_time to be restored after all holds are released, empty if no hold has been taken
+	BaselineGCLifeTime string               `json:"baselineGcLifeTime" example:"10m0s"`
+	ServiceSafePoints  []ServiceGCSafePoint `json:"serviceSafePoints"`
+	Holds              []GCHoldInfo         `json:"holds"`
+}
+
+// CreateGCHoldReq Message for taking a gc hold on a cluster, taking a hold with an existing name renews it
+type CreateGCHoldReq struct {
+	ClusterID  string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Name       string `json:"name" example:"my_hold" validate:"required,min=1,max=64"`
+	Holder     string `json:"holder" example:"admin" validate:"max=64"`
+	GCLifeTime string `json:"gcLifeTime" example:"24h" validate:"required"`
+	// the hold expires after ttl if not released or renewed
+	TTL string `json:"ttl" example:"48h" validate:"required"`
+}
+
+// CreateGCHoldResp Reply message for taking a gc hold
+type CreateGCHoldResp struct {
+	GCHoldInfo
+}
+
+// ReleaseGCHoldReq Message for releasing a gc hold of a cluster
+type ReleaseGCHoldReq struct {
+	ClusterID string `json:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Name      string `json:"name" swaggerignore:"true" validate:"required,min=1,max=64"`
+}
+
+// ReleaseGCHoldResp Reply message for releasing a gc hold
+type ReleaseGCHoldResp struct {
+}
@@ -0,0 +1,94 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package cluster
+
+import "time"
+
+// CreateClusterRelationReq Message for creating a replication relation between two existing clusters.
+// Target of standby or cascade relation must be restored from BackupID, and replication starts from tso of the backup.
+// Replication of bidirectional relation starts from current tso, so both clusters should already have the data of replicated tables
+type CreateClusterRelationReq struct {
+	SourceClusterID string `json:"sourceClusterId" validate:"required,min=4,max=64"`
+	TargetClusterID string `json:"targetClusterId" validate:"required,min=4,max=64"`
+	// StandBy if source is the writable root of replication chain, Cascade if source is itself a standby or cascade copy
+	RelationType string `json:"relationType" enums:"StandBy,Cascade,Bidirectional" validate:"required,oneof=StandBy Cascade Bidirectional"`
+	// tables replicated from source to target in bidirectional relation, such as db1.* or db2.t1
+	Tables []string `json:"tables" example:"db1.*"`
+	// tables replicated from target to source in bidirectional relation, they must not overlap with tables
+	ReverseTables []string `json:"reverseTables" example:"db2.*"`
+	// finished full backup of the whole source cluster, which target cluster is restored from. Required by standby and cascade relation
+	BackupID string `json:"backupId" example:"BACKUP_ID_IN_TIUNIMANAGER___22"`
+}
+
+// CreateClusterRelationResp Reply message for creating a replication relation between two existing clusters
+type CreateClusterRelationResp struct {
+	SourceClusterID string `json:"sourceClusterId"`
+	TargetClusterID string `json:"targetClusterId"`
+	RelationType    string `json:"relationType"`
+	// sync change feed task from source to target
+	ChangeFeedTaskID string `json:"changeFeedTaskId"`
+	// sync change feed task from target to source, only for bidirectional relation
+	ReverseChangeFeedTaskID string `json:"reverseChangeFeedTaskId"`
+}
+
+// DeleteClusterRelationReq Message for deleting a replication relation and its sync change feed tasks,
+// both directions of a bidirectional relation are deleted
+type DeleteClusterRelationReq struct {
+	SourceClusterID string `json:"sourceClusterId" validate:"required,min=4,max=64"`
+	TargetClusterID string `json:"targetClusterId" validate:"required,min=4,max=64"`
+}
+
+// DeleteClusterRelationResp Reply message for deleting a replication relation
+type DeleteClusterRelationResp struct {
+	SourceClusterID string `json:"sourceClusterId"`
+	TargetClusterID string `json:"targetClusterId"`
+}
+
+// QueryReplicationGraphReq Message for querying the whole replication graph which the cluster belongs to
+type QueryReplicationGraphReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// ReplicationNode cluster in replication graph
+type ReplicationNode struct {
+	ClusterID string `json:"clusterId"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	// upstream of standby or cascade relation, empty for the writable root
+	UpstreamClusterID string `json:"upstreamClusterId"`
+	// hops from the writable root of replication chain
+	Depth int `json:"depth"`
+}
+
+// ReplicationEdge relation in replication graph
+type ReplicationEdge struct {
+	SourceClusterID  string   `json:"sourceClusterId"`
+	TargetClusterID  string   `json:"targetClusterId"`
+	RelationType     string   `json:"relationType" enums:"StandBy,Cascade,Bidirectional"`
+	ChangeFeedTaskID string   `json:"changeFeedTaskId"`
+	Status           string   `json:"status"`
+	FilterRules      []string `json:"rules"`
+	// milliseconds, -1 if unknown
+	CheckpointLag int64     `json:"checkpointLag"`
+	LagLevel      string    `json:"lagLevel"`
+	CheckedTime   time.Time `json:"checkedTime"`
+}
+
+// QueryReplicationGraphResp Reply message for querying replication graph
+type QueryReplicationGraphResp struct {
+	Nodes []ReplicationNode `json:"nodes"`
+	Edges []ReplicationEdge `json:"edges"`
+}
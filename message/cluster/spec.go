@@ -0,0 +1,133 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package cluster
+
+import "github.com/pingcap/tiunimanager/common/structs"
+
+// ClusterSpec Desired state of a cluster.
+// A nil field means the corresponding part of the cluster is not managed by the spec and will be left unchanged,
+// components and parameters not listed in the spec are left unchanged too,
+// and so are retention, filter and copy target of backup strategy if they are not set
+type ClusterSpec struct {
+	Version        string                                    `json:"version,omitempty" example:"v5.2.2"`
+	Components     []structs.ClusterResourceParameterCompute `json:"components,omitempty"`
+	Parameters     []ClusterSpecParameter                    `json:"parameters,omitempty"`
+	BackupStrategy *structs.BackupStrategy                   `json:"backupStrategy,omitempty"`
+	Tags           []string                                  `json:"tags,omitempty"`
+}
+
+// ClusterSpecParameter Desired cluster value of a parameter
+type ClusterSpecParameter struct {
+	InstanceType string `json:"instanceType" example:"TiDB" validate:"required"`
+	Name         string `json:"name" example:"log.level" validate:"required"`
+	Value        string `json:"value" example:"info"`
+}
+
+// ClusterSpecScaleInItem An instance which will be removed from the cluster
+type ClusterSpecScaleInItem struct {
+	InstanceID string `json:"instanceId"`
+	Type       string `json:"componentType"`
+	Zone       string `json:"zoneCode"`
+	Spec       string `json:"specCode"`
+	HostIP     string `json:"hostIp"`
+}
+
+// ClusterSpecUpgrade Version change of the cluster
+type ClusterSpecUpgrade struct {
+	CurrentVersion string `json:"currentVersion" example:"v5.2.2"`
+	TargetVersion  string `json:"targetVersion" example:"v5.3.0"`
+}
+
+// ClusterSpecParameterChange Cluster value change of a parameter
+type ClusterSpecParameterChange struct {
+	ParamId      string `json:"paramId" example:"1"`
+	InstanceType string `json:"instanceType" example:"TiDB"`
+	Name         string `json:"name" example:"log.level"`
+	CurrentValue string `json:"currentValue" example:"info"`
+	DesiredValue string `json:"desiredValue" example:"warn"`
+	HasReboot    bool   `json:"hasReboot"`
+}
+
+// ClusterSpecBackupStrategyChange Backup strategy change of the cluster
+type ClusterSpecBackupStrategyChange struct {
+	Current structs.BackupStrategy `json:"current"`
+	Desired structs.BackupStrategy `json:"desired"`
+}
+
+// ClusterSpecTagsChange Tags change of the cluster
+type ClusterSpecTagsChange struct {
+	Current []string `json:"current"`
+	Desired []string `json:"desired"`
+}
+
+// ClusterSpecPlan Changes to reconcile a cluster with its spec, they will be applied in the following order:
+// scale out, scale in, upgrade, parameters, backup strategy and tags
+type ClusterSpecPlan struct {
+	ScaleOut       []structs.ClusterResourceParameterCompute `json:"scaleOut"`
+	ScaleIn        []ClusterSpecScaleInItem                  `json:"scaleIn"`
+	Upgrade        *ClusterSpecUpgrade                       `json:"upgrade,omitempty"`
+	Parameters     []ClusterSpecParameterChange              `json:"parameters"`
+	BackupStrategy *ClusterSpecBackupStrategyChange          `json:"backupStrategy,omitempty"`
+	Tags           *ClusterSpecTagsChange                    `json:"tags,omitempty"`
+	Steps          []string                                  `json:"steps"`
+}
+
+// IsEmpty
+// @Description: whether there is nothing to change
+// @Receiver p
+// @return bool
+func (p *ClusterSpecPlan) IsEmpty() bool {
+	return len(p.ScaleOut) == 0 && len(p.ScaleIn) == 0 && p.Upgrade == nil &&
+		len(p.Parameters) == 0 && p.BackupStrategy == nil && p.Tags == nil
+}
+
+// ExportClusterSpecReq Message for exporting the spec of a cluster
+type ExportClusterSpecReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// ExportClusterSpecResp Reply message for exporting the spec of a cluster
+type ExportClusterSpecResp struct {
+	ClusterID string      `json:"clusterId"`
+	Spec      ClusterSpec `json:"spec"`
+}
+
+// PlanClusterSpecReq Message for computing the plan from the current cluster to the spec
+type PlanClusterSpecReq struct {
+	ClusterID string      `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Spec      ClusterSpec `json:"spec"`
+}
+
+// PlanClusterSpecResp Reply message for computing the plan from the current cluster to the spec
+type PlanClusterSpecResp struct {
+	ClusterID string          `json:"clusterId"`
+	Plan      ClusterSpecPlan `json:"plan"`
+}
+
+// ApplyClusterSpecReq Message for applying the spec to a cluster
+type ApplyClusterSpecReq struct {
+	ClusterID  string      `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+	Spec       ClusterSpec `json:"spec"`
+	UpgradeWay string      `json:"upgradeWay" enums:"offline,online" validate:"omitempty,oneof=offline online"`
+}
+
+// ApplyClusterSpecResp Reply message for applying the spec to a cluster
+type ApplyClusterSpecResp struct {
+	structs.AsyncTaskWorkFlowInfo
+	ClusterID string          `json:"clusterId"`
+	Plan      ClusterSpecPlan `json:"plan"`
+}
@@ -0,0 +1,96 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package cluster
+
+import "time"
+
+// SwitchoverReadinessReq Message for reporting readiness of a planned switchover
+type SwitchoverReadinessReq struct {
+	// old master/new slave
+	SourceClusterID string `json:"sourceClusterID" validate:"required,min=4,max=64"`
+	// new master/old slave
+	TargetClusterID string `json:"targetClusterID" validate:"required,min=4,max=64"`
+}
+
+// SwitchoverReadinessResp Reply message of switchover readiness report
+type SwitchoverReadinessResp struct {
+	SourceClusterID string `json:"sourceClusterID"`
+	TargetClusterID string `json:"targetClusterID"`
+	// true if all checks passed, the same as a successful switchover with onlyCheck, checkSlaveReadOnlyFlag and checkMasterWritableFlag
+	Ready  bool                       `json:"ready"`
+	Checks []SwitchoverReadinessCheck `json:"checks"`
+	Source SwitchoverClusterState     `json:"source"`
+	Target SwitchoverClusterState     `json:"target"`
+	// estimated RPO in milliseconds, i.e. checkpoint lag of the sync change feed from source to target, -1 if unknown
+	EstimatedRPO int64 `json:"estimatedRPO" example:"1200"`
+	// change feeds of source cluster, all of them except the sync change feed to target are re-pointed to target by switchover
+	ChangeFeeds    []SwitchoverChangeFeed    `json:"changeFeeds"`
+	ParameterDiffs []SwitchoverParameterDiff `json:"parameterDiffs"`
+	// estimated by durations of latest finished normal switchover workflows
+	EstimatedCutover SwitchoverCutoverEstimate `json:"estimatedCutover"`
+}
+
+// SwitchoverReadinessCheck result of a switchover pre-check
+type SwitchoverReadinessCheck struct {
+	Name    string `json:"name" example:"syncChangeFeed"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// SwitchoverClusterState state of one side of switchover
+type SwitchoverClusterState struct {
+	ClusterID string `json:"clusterId"`
+	Version   string `json:"version" example:"v5.2.2"`
+	Status    string `json:"status" example:"Running"`
+	// restricted read only for normal users, nil if unknown
+	ReadOnly *bool `json:"readOnly"`
+	// connected and queried by change feed user, the probe is read only and writes nothing to the cluster
+	Reachable bool   `json:"reachable"`
+	Message   string `json:"message"`
+}
+
+// SwitchoverChangeFeed change feed of source cluster at switchover
+type SwitchoverChangeFeed struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// cluster id of the standby whose sync change feed it is, empty if it is not a sync change feed
+	StandbyClusterID string `json:"standbyClusterId"`
+	// true if it is re-pointed to target cluster by switchover
+	Repointed      bool      `json:"repointed"`
+	CheckpointTime time.Time `json:"checkpointTime"`
+	// checkpoint lag in milliseconds, -1 if unknown
+	CheckpointLag int64 `json:"checkpointLag" example:"1200"`
+}
+
+// SwitchoverParameterDiff parameter whose cluster values differ between source and target
+type SwitchoverParameterDiff struct {
+	InstanceType string `json:"instanceType" example:"TiDB"`
+	Name         string `json:"name"`
+	SourceValue  string `json:"sourceValue"`
+	TargetValue  string `json:"targetValue"`
+}
+
+// SwitchoverCutoverEstimate estimated duration of switchover
+type SwitchoverCutoverEstimate struct {
+	// number of finished switchover workflows used, the estimate is unknown if 0
+	Samples int `json:"samples"`
+	// median duration in seconds
+	Duration int64 `json:"duration" example:"120"`
+	// max duration in seconds
+	MaxDuration int64 `json:"maxDuration" example:"300"`
+}
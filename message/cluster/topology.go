@@ -0,0 +1,112 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package cluster
+
+import "github.com/pingcap/tiunimanager/common/structs"
+
+// TopologyGraphInstance An instance placed on a host
+type TopologyGraphInstance struct {
+	InstanceID   string  `json:"instanceId"`
+	ClusterID    string  `json:"clusterId"`
+	Type         string  `json:"type" example:"TiKV"`
+	Role         string  `json:"role"`
+	Status       string  `json:"status"`
+	Ports        []int32 `json:"ports"`
+	DiskID       string  `json:"diskId"`
+	DiskPath     string  `json:"diskPath"`
+	DiskType     string  `json:"diskType"`
+	DiskCapacity int32   `json:"diskCapacity"`
+}
+
+// TopologyGraphHost A host with the instances placed on it, only disks used by these instances are listed
+type TopologyGraphHost struct {
+	HostID    string                  `json:"hostId"`
+	HostName  string                  `json:"hostName"`
+	IP        string                  `json:"ip"`
+	Status    string                  `json:"status"`
+	Disks     []structs.DiskInfo      `json:"disks"`
+	Instances []TopologyGraphInstance `json:"instances"`
+}
+
+// TopologyGraphRack Hosts in a rack
+type TopologyGraphRack struct {
+	Name  string              `json:"name"`
+	Hosts []TopologyGraphHost `json:"hosts"`
+}
+
+// TopologyGraphZone Racks in a zone
+type TopologyGraphZone struct {
+	Name  string              `json:"name"`
+	Racks []TopologyGraphRack `json:"racks"`
+}
+
+// TopologyGraphRegion Zones in a region
+type TopologyGraphRegion struct {
+	Name  string              `json:"name"`
+	Zones []TopologyGraphZone `json:"zones"`
+}
+
+// TopologyPlacementRisk A risky placement of cluster instances
+type TopologyPlacementRisk struct {
+	ClusterID     string   `json:"clusterId"`
+	Level         string   `json:"level" enums:"Warning,Critical"`
+	Type          string   `json:"type" example:"TiKVReplicasOnSameRack"`
+	ComponentType string   `json:"componentType" example:"TiKV"`
+	Location      string   `json:"location" example:"Region1/Zone1/Rack1"`
+	Message       string   `json:"message"`
+	InstanceIDs   []string `json:"instanceIds"`
+}
+
+// TopologyComponentLoss Instances of a component lost when a host subtree fails
+type TopologyComponentLoss struct {
+	ComponentType string `json:"componentType" example:"PD"`
+	Lost          int    `json:"lost"`
+	Total         int    `json:"total"`
+}
+
+// TopologyFailureImpact Impact on a cluster when a host subtree fails
+type TopologyFailureImpact struct {
+	ClusterID   string                  `json:"clusterId"`
+	ClusterName string                  `json:"clusterName"`
+	Losses      []TopologyComponentLoss `json:"losses"`
+	Unavailable bool                    `json:"unavailable"`
+	Reasons     []string                `json:"reasons"`
+}
+
+// GetClusterTopologyGraphReq Message for querying the topology graph of a cluster
+type GetClusterTopologyGraphReq struct {
+	ClusterID string `json:"clusterId" form:"clusterId" swaggerignore:"true" validate:"required,min=4,max=64"`
+}
+
+// GetClusterTopologyGraphResp Reply message for querying the topology graph of a cluster
+type GetClusterTopologyGraphResp struct {
+	ClusterID string                  `json:"clusterId"`
+	Regions   []TopologyGraphRegion   `json:"regions"`
+	Risks     []TopologyPlacementRisk `json:"risks"`
+}
+
+// GetHostTopologyGraphReq Message for querying the topology graph of a host subtree,
+// the subtree is specified by region, zone, rack or host ip
+type GetHostTopologyGraphReq struct {
+	structs.Location
+}
+
+// GetHostTopologyGraphResp Reply message for querying the topology graph of a host subtree
+type GetHostTopologyGraphResp struct {
+	Regions []TopologyGraphRegion   `json:"regions"`
+	Impacts []TopologyFailureImpact `json:"impacts"`
+}
@@ -28,6 +28,9 @@ const (
 	FlowStatusLabel     = "flow_status"
 	FlowNodeLabel       = "flow_node"
 	FlowNodeStatusLabel = "flow_node_status"
+	ClusterIDLabel      = "cluster_id"
+	ChangeFeedIDLabel   = "changefeed_id"
+	FailoverEventLabel  = "failover_event"
 
 	OpenApiServer = "openapi-server"
 	ClusterServer = "cluster-server"
@@ -109,4 +112,16 @@ var (
 		Help:       "A counter for work flow node.",
 		LabelNames: []string{ServiceLabel, BizTypeLabel, FlowNameLabel, FlowNodeLabel, FlowNodeStatusLabel},
 	}
+
+	ChangeFeedLagGaugeMetricDef = MetricDef{
+		Name:       "changefeed_checkpoint_lag_seconds",
+		Help:       "A gauge of checkpoint lag of change feed tasks.",
+		LabelNames: []string{ClusterIDLabel, ChangeFeedIDLabel},
+	}
+
+	FailoverCounterMetricDef = MetricDef{
+		Name:       "cluster_failover_total",
+		Help:       "A counter for automatic failover events of master clusters.",
+		LabelNames: []string{ClusterIDLabel, FailoverEventLabel},
+	}
 )
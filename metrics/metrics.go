@@ -51,6 +51,12 @@ type Metrics struct {
 	// work flow metrics
 	WorkFlowCounterMetric     *prometheus.CounterVec
 	WorkFlowNodeCounterMetric *prometheus.CounterVec
+
+	// change feed metrics
+	ChangeFeedLagGaugeMetric *prometheus.GaugeVec
+
+	// automatic failover metrics
+	FailoverCounterMetric *prometheus.CounterVec
 }
 
 func RegisterNewGaugeVec(metricDef MetricDef) *prometheus.GaugeVec {
@@ -121,6 +127,8 @@ func GetMetrics() *Metrics {
 				ServerStartTimeGaugeMetric:     RegisterNewGaugeVec(ServerStartTimeGaugeMetricDef),
 				WorkFlowCounterMetric:          RegisterNewCounterVec(WorkFlowCounterMetricDef),
 				WorkFlowNodeCounterMetric:      RegisterNewCounterVec(WorkFlowNodeCounterMetricDef),
+				ChangeFeedLagGaugeMetric:       RegisterNewGaugeVec(ChangeFeedLagGaugeMetricDef),
+				FailoverCounterMetric:          RegisterNewCounterVec(FailoverCounterMetricDef),
 			}
 		}
 	})
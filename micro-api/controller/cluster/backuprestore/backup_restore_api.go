@@ -163,7 +163,7 @@ func DeleteBackup(c *gin.Context) {
 
 // Restore
 // @Summary restore a new cluster by backup record
-// @Description restore a new cluster by backup record
+// @Description restore a new cluster by backup record, or to a point in time by snapshot and log backups of source cluster
 // @Tags cluster
 // @Accept json
 // @Produce json
@@ -181,3 +181,454 @@ func Restore(c *gin.Context) {
 			controller.DefaultTimeout)
 	}
 }
+
+// RestoreExistCluster
+// @Summary restore an existing cluster by backup record or to a point in time
+// @Description restore an existing cluster by backup record, or to a point in time by snapshot and log backups
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param request body cluster.RestoreExistClusterReq true "restore request"
+// @Success 200 {object} controller.CommonResult{data=cluster.RestoreExistClusterResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/restore [post]
+func RestoreExistCluster(c *gin.Context) {
+	req := cluster.RestoreExistClusterReq{
+		ClusterID: c.Param("clusterId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.RestoreExistCluster, &cluster.RestoreExistClusterResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// StartLogBackup
+// @Summary start continuous log backup of a cluster
+// @Description start continuous log backup of a cluster for point-in-time restore
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Success 200 {object} controller.CommonResult{data=cluster.StartLogBackupResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/log_backup/start [post]
+func StartLogBackup(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.StartLogBackupReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.StartLogBackup, &cluster.StartLogBackupResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// StopLogBackup
+// @Summary stop continuous log backup of a cluster
+// @Description stop continuous log backup of a cluster, the logs backed up are kept for point-in-time restore
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Success 200 {object} controller.CommonResult{data=cluster.StopLogBackupResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/log_backup/stop [post]
+func StopLogBackup(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.StopLogBackupReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.StopLogBackup, &cluster.StopLogBackupResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryLogBackup
+// @Summary query log backup tasks of a cluster
+// @Description query log backup tasks of a cluster with their checkpoints and recoverable ranges
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryLogBackupResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/log_backup [get]
+func QueryLogBackup(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryLogBackupReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryLogBackup, &cluster.QueryLogBackupResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryBackupSchedules
+// @Summary query backup schedules of a cluster
+// @Description query cron backup schedules of a cluster with their next run time and missed runs
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryBackupSchedulesResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/schedules [get]
+func QueryBackupSchedules(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryBackupSchedulesReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryBackupSchedules, &cluster.QueryBackupSchedulesResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CreateBackupSchedule
+// @Summary create a backup schedule of a cluster
+// @Description create a cron backup schedule of a cluster, a cluster may have several schedules
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param createReq body cluster.SaveBackupScheduleReq true "backup schedule request"
+// @Success 200 {object} controller.CommonResult{data=cluster.SaveBackupScheduleResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/schedules [post]
+func CreateBackupSchedule(c *gin.Context) {
+	req := cluster.SaveBackupScheduleReq{
+		ClusterID: c.Param("clusterId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.SaveBackupSchedule, &cluster.SaveBackupScheduleResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// UpdateBackupSchedule
+// @Summary update a backup schedule of a cluster
+// @Description update a cron backup schedule of a cluster, runs are counted from the update time
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param scheduleId path string true "scheduleId"
+// @Param updateReq body cluster.SaveBackupScheduleReq true "backup schedule request"
+// @Success 200 {object} controller.CommonResult{data=cluster.SaveBackupScheduleResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/schedules/{scheduleId} [put]
+func UpdateBackupSchedule(c *gin.Context) {
+	req := cluster.SaveBackupScheduleReq{
+		ClusterID:  c.Param("clusterId"),
+		ScheduleID: c.Param("scheduleId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.SaveBackupSchedule, &cluster.SaveBackupScheduleResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DeleteBackupSchedule
+// @Summary delete a backup schedule of a cluster
+// @Description delete a cron backup schedule of a cluster, backups already taken are kept
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param scheduleId path string true "scheduleId"
+// @Success 200 {object} controller.CommonResult{data=cluster.DeleteBackupScheduleResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/schedules/{scheduleId} [delete]
+func DeleteBackupSchedule(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DeleteBackupScheduleReq{
+		ClusterID:  c.Param("clusterId"),
+		ScheduleID: c.Param("scheduleId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DeleteBackupSchedule, &cluster.DeleteBackupScheduleResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryBackupKeys
+// @Summary query backup keys of a cluster
+// @Description query data keys encrypting backups of a cluster, the keys themselves are not returned
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryBackupKeysResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/backup_keys [get]
+func QueryBackupKeys(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryBackupKeysReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryBackupKeys, &cluster.QueryBackupKeysResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// RotateBackupKey
+// @Summary rotate backup key of a cluster
+// @Description create a new active data key encrypting new backups of a cluster, older backups stay restorable with retired keys
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param rotateReq body cluster.RotateBackupKeyReq true "rotate backup key request"
+// @Success 200 {object} controller.CommonResult{data=cluster.RotateBackupKeyResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/backup_keys/rotate [post]
+func RotateBackupKey(c *gin.Context) {
+	req := cluster.RotateBackupKeyReq{
+		ClusterID: c.Param("clusterId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.RotateBackupKey, &cluster.RotateBackupKeyResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// ExportBackupKey
+// @Summary export backup key of a cluster
+// @Description export a data key of a cluster sealed with passphrase, to restore its backups on another TiUniManager
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param exportReq body cluster.ExportBackupKeyReq true "export backup key request"
+// @Success 200 {object} controller.CommonResult{data=cluster.ExportBackupKeyResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/backup_keys/export [post]
+func ExportBackupKey(c *gin.Context) {
+	req := cluster.ExportBackupKeyReq{
+		ClusterID: c.Param("clusterId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ExportBackupKey, &cluster.ExportBackupKeyResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// ImportBackupKey
+// @Summary import backup key into a cluster
+// @Description import a data key exported by another TiUniManager, so that backups encrypted by it can be restored into the cluster
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "clusterId"
+// @Param importReq body cluster.ImportBackupKeyReq true "import backup key request"
+// @Success 200 {object} controller.CommonResult{data=cluster.ImportBackupKeyResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/backup_keys/import [post]
+func ImportBackupKey(c *gin.Context) {
+	req := cluster.ImportBackupKeyReq{
+		ClusterID: c.Param("clusterId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ImportBackupKey, &cluster.ImportBackupKeyResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CopyBackup
+// @Summary copy a backup to a storage target
+// @Description copy a finished backup to a storage target asynchronously, a failed copy on the same target is replaced
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param copyReq body cluster.CopyBackupReq true "copy backup request"
+// @Success 200 {object} controller.CommonResult{data=cluster.CopyBackupResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backups/copy [post]
+func CopyBackup(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &cluster.CopyBackupReq{}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.CopyBackup, &cluster.CopyBackupResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// ImportBackup
+// @Summary import a backup created outside
+// @Description register a BR backup created outside into the backup catalog by reading its backupmeta, bound to a cluster or left unbound
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param importReq body cluster.ImportBackupReq true "import backup request"
+// @Success 200 {object} controller.CommonResult{data=cluster.ImportBackupResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backups/import [post]
+func ImportBackup(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &cluster.ImportBackupReq{}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ImportBackup, &cluster.ImportBackupResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CheckRestore
+// @Summary check a backup before restoring it
+// @Description check version, new collation, TiKV capacity and table conflicts of a backup against an existing cluster, or a new cluster of target version, without restoring it
+// @Tags cluster backup
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param checkReq body cluster.CheckRestoreReq true "restore check request"
+// @Success 200 {object} controller.CommonResult{data=cluster.CheckRestoreResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backups/restore_check [post]
+func CheckRestore(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &cluster.CheckRestoreReq{}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.CheckRestore, &cluster.CheckRestoreResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryBackupStorageTargets
+// @Summary query backup storage targets
+// @Description query named storage targets where copies of backups are kept, secret access keys are not returned
+// @Tags backup storage target
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryBackupStorageTargetsResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backup_targets/ [get]
+func QueryBackupStorageTargets(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryBackupStorageTargetsReq{}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryBackupStorageTargets, &cluster.QueryBackupStorageTargetsResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CreateBackupStorageTarget
+// @Summary create a backup storage target
+// @Description create a named NFS path or S3 endpoint where copies of backups are kept
+// @Tags backup storage target
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param saveReq body cluster.SaveBackupStorageTargetReq true "save backup storage target request"
+// @Success 200 {object} controller.CommonResult{data=cluster.SaveBackupStorageTargetResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backup_targets/ [post]
+func CreateBackupStorageTarget(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &cluster.SaveBackupStorageTargetReq{}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.SaveBackupStorageTarget, &cluster.SaveBackupStorageTargetResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// UpdateBackupStorageTarget
+// @Summary update a backup storage target
+// @Description update a backup storage target, the secret access key is kept if empty
+// @Tags backup storage target
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param targetId path string true "targetId"
+// @Param saveReq body cluster.SaveBackupStorageTargetReq true "save backup storage target request"
+// @Success 200 {object} controller.CommonResult{data=cluster.SaveBackupStorageTargetResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backup_targets/{targetId} [put]
+func UpdateBackupStorageTarget(c *gin.Context) {
+	req := cluster.SaveBackupStorageTargetReq{
+		TargetID: c.Param("targetId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.SaveBackupStorageTarget, &cluster.SaveBackupStorageTargetResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DeleteBackupStorageTarget
+// @Summary delete a backup storage target
+// @Description delete a backup storage target not used by any backup strategy or backup copy
+// @Tags backup storage target
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param targetId path string true "targetId"
+// @Success 200 {object} controller.CommonResult{data=cluster.DeleteBackupStorageTargetResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /backup_targets/{targetId} [delete]
+func DeleteBackupStorageTarget(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DeleteBackupStorageTargetReq{
+		TargetID: c.Param("targetId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DeleteBackupStorageTarget, &cluster.DeleteBackupStorageTargetResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
@@ -0,0 +1,138 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiunimanager/common/client"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-api/controller"
+)
+
+// BatchPause pause change feed tasks in batch
+// @Summary pause change feed tasks in batch
+// @Description pause change feed tasks selected by ids, or by cluster with optional downstream type and status filters
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param selector body cluster.BatchChangeFeedTasksReq true "change feed tasks selector"
+// @Success 200 {object} controller.CommonResult{data=cluster.BatchChangeFeedTasksResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/batch/pause [post]
+func BatchPause(c *gin.Context) {
+	var req cluster.BatchChangeFeedTasksReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.BatchPauseChangeFeedTasks, &cluster.BatchChangeFeedTasksResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// BatchResume resume change feed tasks in batch
+// @Summary resume change feed tasks in batch
+// @Description resume change feed tasks selected by ids, or by cluster with optional downstream type and status filters
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param selector body cluster.BatchChangeFeedTasksReq true "change feed tasks selector"
+// @Success 200 {object} controller.CommonResult{data=cluster.BatchChangeFeedTasksResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/batch/resume [post]
+func BatchResume(c *gin.Context) {
+	var req cluster.BatchChangeFeedTasksReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.BatchResumeChangeFeedTasks, &cluster.BatchChangeFeedTasksResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// BatchDelete delete change feed tasks in batch
+// @Summary delete change feed tasks in batch
+// @Description delete change feed tasks selected by ids, or by cluster with optional downstream type and status filters
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param selector body cluster.BatchChangeFeedTasksReq true "change feed tasks selector"
+// @Success 200 {object} controller.CommonResult{data=cluster.BatchChangeFeedTasksResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/batch/delete [post]
+func BatchDelete(c *gin.Context) {
+	var req cluster.BatchChangeFeedTasksReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.BatchDeleteChangeFeedTasks, &cluster.BatchChangeFeedTasksResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// Export export change feed tasks as json
+// @Summary export change feed tasks as json
+// @Description export change feed tasks for migrating them to another cluster, secrets of downstream are masked
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param selector query cluster.ExportChangeFeedTasksReq true "change feed tasks selector"
+// @Success 200 {object} controller.CommonResult{data=cluster.ExportChangeFeedTasksResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/export [get]
+func Export(c *gin.Context) {
+	var req cluster.ExportChangeFeedTasksReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromQuery(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ExportChangeFeedTasks, &cluster.ExportChangeFeedTasksResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// Import import exported change feed tasks into a cluster
+// @Summary import exported change feed tasks into a cluster
+// @Description create exported change feed tasks in the cluster, masked secrets are restored from source tasks which still exist
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param importReq body cluster.ImportChangeFeedTasksReq true "exported change feed tasks and target cluster"
+// @Success 200 {object} controller.CommonResult{data=cluster.ImportChangeFeedTasksResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/import [post]
+func Import(c *gin.Context) {
+	var req cluster.ImportChangeFeedTasksReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ImportChangeFeedTasks, &cluster.ImportChangeFeedTasksResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
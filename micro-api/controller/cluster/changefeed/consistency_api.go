@@ -0,0 +1,178 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiunimanager/common/client"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-api/controller"
+)
+
+const paramNameOfConsistencyCheckId = "checkId"
+
+// StartConsistencyCheck verify data consistency between upstream cluster and downstream of a change feed task
+// @Summary verify data consistency between upstream cluster and downstream of a change feed task
+// @Description compare checksums of table chunks at the latest sync point of change feed, only TiDB downstream is supported, and fix SQL is only generated at a sync point
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param changeFeedTaskId path string true "changeFeedTaskId"
+// @Param options body cluster.StartConsistencyCheckReq true "consistency check options"
+// @Success 200 {object} controller.CommonResult{data=cluster.StartConsistencyCheckResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/{changeFeedTaskId}/consistency_checks [post]
+func StartConsistencyCheck(c *gin.Context) {
+	var req cluster.StartConsistencyCheckReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c,
+		&req,
+		// append id in path to request
+		func(c *gin.Context, req interface{}) error {
+			req.(*cluster.StartConsistencyCheckReq).ChangeFeedTaskID = c.Param(paramNameOfChangeFeedTaskId)
+			return nil
+		}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.StartConsistencyCheck, &cluster.StartConsistencyCheckResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryConsistencyChecks query consistency checks
+// @Summary query consistency checks
+// @Description query consistency checks, e.g. of a master/slave relation by clusterId and downstreamClusterId
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param queryReq query cluster.QueryConsistencyChecksReq false "consistency checks query condition"
+// @Success 200 {object} controller.ResultWithPage{data=[]cluster.ConsistencyCheck}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /consistency_checks/ [get]
+func QueryConsistencyChecks(c *gin.Context) {
+	var req cluster.QueryConsistencyChecksReq
+	if requestBody, ok := controller.HandleJsonRequestFromQuery(c, &req); ok {
+		result := make([]cluster.ConsistencyCheck, 0)
+
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryConsistencyChecks, &result,
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DetailConsistencyCheck get consistency check detail
+// @Summary get consistency check detail
+// @Description get consistency check with mismatched tables, chunks and fix SQL
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param checkId path string true "checkId"
+// @Success 200 {object} controller.CommonResult{data=cluster.DetailConsistencyCheckResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /consistency_checks/{checkId} [get]
+func DetailConsistencyCheck(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DetailConsistencyCheckReq{
+		CheckID: c.Param(paramNameOfConsistencyCheckId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DetailConsistencyCheck, &cluster.DetailConsistencyCheckResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// SaveConsistencySchedule create or replace the consistency check schedule of a change feed task
+// @Summary create or replace the consistency check schedule of a change feed task
+// @Description create or replace the consistency check schedule of a change feed task
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param changeFeedTaskId path string true "changeFeedTaskId"
+// @Param schedule body cluster.SaveConsistencyScheduleReq true "consistency check schedule"
+// @Success 200 {object} controller.CommonResult{data=cluster.SaveConsistencyScheduleResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/{changeFeedTaskId}/consistency_schedule [put]
+func SaveConsistencySchedule(c *gin.Context) {
+	var req cluster.SaveConsistencyScheduleReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c,
+		&req,
+		// append id in path to request
+		func(c *gin.Context, req interface{}) error {
+			req.(*cluster.SaveConsistencyScheduleReq).ChangeFeedTaskID = c.Param(paramNameOfChangeFeedTaskId)
+			return nil
+		}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.SaveConsistencySchedule, &cluster.SaveConsistencyScheduleResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// GetConsistencySchedule get the consistency check schedule of a change feed task
+// @Summary get the consistency check schedule of a change feed task
+// @Description get the consistency check schedule of a change feed task
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param changeFeedTaskId path string true "changeFeedTaskId"
+// @Success 200 {object} controller.CommonResult{data=cluster.GetConsistencyScheduleResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/{changeFeedTaskId}/consistency_schedule [get]
+func GetConsistencySchedule(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.GetConsistencyScheduleReq{
+		ChangeFeedTaskID: c.Param(paramNameOfChangeFeedTaskId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.GetConsistencySchedule, &cluster.GetConsistencyScheduleResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DeleteConsistencySchedule delete the consistency check schedule of a change feed task
+// @Summary delete the consistency check schedule of a change feed task
+// @Description delete the consistency check schedule of a change feed task
+// @Tags change feed
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param changeFeedTaskId path string true "changeFeedTaskId"
+// @Success 200 {object} controller.CommonResult{data=cluster.DeleteConsistencyScheduleResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /changefeeds/{changeFeedTaskId}/consistency_schedule [delete]
+func DeleteConsistencySchedule(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DeleteConsistencyScheduleReq{
+		ChangeFeedTaskID: c.Param(paramNameOfChangeFeedTaskId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DeleteConsistencySchedule, &cluster.DeleteConsistencyScheduleResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
@@ -0,0 +1,283 @@
+/******************************************************************************
+ * Copyright (c)  2021 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiunimanager/common/client"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-api/controller"
+)
+
+const (
+	paramNameOfClusterId  = "clusterId"
+	paramNameOfSourceName = "name"
+	paramNameOfDMTaskId   = "taskId"
+)
+
+// RegisterSource register a MySQL source to a DM cluster
+// @Summary register a MySQL source to a DM cluster
+// @Description register a MySQL source to dm-master of a DM cluster, the source can be used by data migration tasks of the cluster
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "DM cluster id"
+// @Param sourceReq body cluster.RegisterDMSourceReq true "MySQL source"
+// @Success 200 {object} controller.CommonResult{data=cluster.RegisterDMSourceResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/dm/sources [post]
+func RegisterSource(c *gin.Context) {
+	req := cluster.RegisterDMSourceReq{
+		ClusterID: c.Param(paramNameOfClusterId),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.RegisterDMSource, &cluster.RegisterDMSourceResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QuerySources query MySQL sources of a DM cluster
+// @Summary query MySQL sources of a DM cluster
+// @Description query MySQL sources of a DM cluster
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "DM cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryDMSourcesResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/dm/sources [get]
+func QuerySources(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryDMSourcesReq{
+		ClusterID: c.Param(paramNameOfClusterId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryDMSources, &cluster.QueryDMSourcesResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DeleteSource delete a MySQL source of a DM cluster
+// @Summary delete a MySQL source of a DM cluster
+// @Description delete a MySQL source of a DM cluster, the source must not be used by unfinished tasks
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "DM cluster id"
+// @Param name path string true "source name"
+// @Success 200 {object} controller.CommonResult{data=cluster.DeleteDMSourceResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/dm/sources/{name} [delete]
+func DeleteSource(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DeleteDMSourceReq{
+		ClusterID: c.Param(paramNameOfClusterId),
+		Name:      c.Param(paramNameOfSourceName),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DeleteDMSource, &cluster.DeleteDMSourceResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CreateTask create a data migration task
+// @Summary create a data migration task
+// @Description create a data migration task from registered MySQL sources to a TiDB cluster, with block/allow list and routes
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "DM cluster id"
+// @Param taskReq body cluster.CreateDMTaskReq true "data migration task"
+// @Success 200 {object} controller.CommonResult{data=cluster.CreateDMTaskResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/dm/tasks [post]
+func CreateTask(c *gin.Context) {
+	req := cluster.CreateDMTaskReq{
+		ClusterID: c.Param(paramNameOfClusterId),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.CreateDMTask, &cluster.CreateDMTaskResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryTasks query data migration tasks of a DM cluster
+// @Summary query data migration tasks of a DM cluster
+// @Description query data migration tasks of a DM cluster, with status and replication lag refreshed from dm-master
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "DM cluster id"
+// @Param queryReq query cluster.QueryDMTasksReq false "data migration tasks query condition"
+// @Success 200 {object} controller.ResultWithPage{data=cluster.QueryDMTasksResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/dm/tasks [get]
+func QueryTasks(c *gin.Context) {
+	var req cluster.QueryDMTasksReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromQuery(c,
+		&req,
+		// append cluster id in path to request
+		func(c *gin.Context, req interface{}) error {
+			req.(*cluster.QueryDMTasksReq).ClusterID = c.Param(paramNameOfClusterId)
+			return nil
+		}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryDMTasks, &cluster.QueryDMTasksResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DetailTask get detail of a data migration task
+// @Summary get detail of a data migration task
+// @Description get detail of a data migration task, including live status of sub tasks reported by dm-master
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param taskId path string true "data migration task id"
+// @Success 200 {object} controller.CommonResult{data=cluster.DetailDMTaskResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/dm/tasks/{taskId} [get]
+func DetailTask(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DetailDMTaskReq{
+		ID: c.Param(paramNameOfDMTaskId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DetailDMTask, &cluster.DetailDMTaskResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// StartTask start a data migration task
+// @Summary start a data migration task
+// @Description start a created or paused data migration task
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param taskId path string true "data migration task id"
+// @Success 200 {object} controller.CommonResult{data=cluster.DMTaskResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/dm/tasks/{taskId}/start [post]
+func StartTask(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DMTaskReq{
+		ID: c.Param(paramNameOfDMTaskId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.StartDMTask, &cluster.DMTaskResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// PauseTask pause a data migration task
+// @Summary pause a data migration task
+// @Description pause a running data migration task, it can be started again later
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param taskId path string true "data migration task id"
+// @Success 200 {object} controller.CommonResult{data=cluster.DMTaskResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/dm/tasks/{taskId}/pause [post]
+func PauseTask(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DMTaskReq{
+		ID: c.Param(paramNameOfDMTaskId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.PauseDMTask, &cluster.DMTaskResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// StopTask stop a data migration task
+// @Summary stop a data migration task
+// @Description stop a data migration task and remove it from dm-master, a stopped task can not be started again
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param taskId path string true "data migration task id"
+// @Success 200 {object} controller.CommonResult{data=cluster.DMTaskResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/dm/tasks/{taskId}/stop [post]
+func StopTask(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.DMTaskReq{
+		ID: c.Param(paramNameOfDMTaskId),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.StopDMTask, &cluster.DMTaskResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// HandoffTask hand off a data migration task
+// @Summary hand off a data migration task to a change feed or switchover
+// @Description after writes to upstream sources are stopped and every sub task is synced without lag, create a change feed or a cluster relation from the target cluster and stop the task.
+// @Description The standby of Switchover handoff must be restored from a full backup of the target cluster
+// @Tags data migration
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param taskId path string true "data migration task id"
+// @Param handoffReq body cluster.HandoffDMTaskReq true "hand off"
+// @Success 200 {object} controller.CommonResult{data=cluster.HandoffDMTaskResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/dm/tasks/{taskId}/handoff [post]
+func HandoffTask(c *gin.Context) {
+	var req cluster.HandoffDMTaskReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c,
+		&req,
+		// append task id in path to request
+		func(c *gin.Context, req interface{}) error {
+			req.(*cluster.HandoffDMTaskReq).ID = c.Param(paramNameOfDMTaskId)
+			return nil
+		}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.HandoffDMTask, &cluster.HandoffDMTaskResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
@@ -67,6 +67,29 @@ func Preview(c *gin.Context) {
 	}
 }
 
+// CostReport show projected monthly cost of clusters of a tenant
+// @Summary show cost report of a tenant
+// @Description show projected monthly cost of clusters of a tenant, and share of the pool capacity consumed by them in each zone
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param tenantId path string true "tenant id"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryTenantCostReportResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /tenants/{tenantId}/cost_report [get]
+func CostReport(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryTenantCostReportReq{
+		TenantID: c.Param("tenantId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryTenantCostReport, &cluster.QueryTenantCostReportResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
 // Query query clusters
 // @Summary query clusters
 // @Description query clusters
@@ -188,6 +211,52 @@ func Stop(c *gin.Context) {
 	}
 }
 
+// Hibernate hibernate a cluster
+// @Summary hibernate a cluster
+// @Description stop a cluster and release compute resource of TiDB servers, storage of PD and TiKV is kept
+// @Tags cluster
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.HibernateClusterResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/hibernate [post]
+func Hibernate(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.HibernateClusterReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.HibernateCluster, &cluster.HibernateClusterResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// Wake wake up a hibernated cluster
+// @Summary wake up a hibernated cluster
+// @Description alloc compute resource for a hibernated cluster, rebuild its topology and start it
+// @Tags cluster
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.WakeClusterResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/wake [post]
+func Wake(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.WakeClusterReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.WakeCluster, &cluster.WakeClusterResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
 // Detail show details of a cluster
 // @Summary show details of a cluster
 // @Description show details of a cluster
@@ -211,6 +280,29 @@ func Detail(c *gin.Context) {
 	}
 }
 
+// TopologyGraph show instances of a cluster mapped to hosts, racks, zones and regions
+// @Summary show topology graph of a cluster
+// @Description show instances of a cluster mapped to hosts, racks, zones and regions, and risky placements of them
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.GetClusterTopologyGraphResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/topology/graph [get]
+func TopologyGraph(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.GetClusterTopologyGraphReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.GetClusterTopologyGraph, &cluster.GetClusterTopologyGraphResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
 // Takeover takeover a cluster
 // @Summary takeover a cluster
 // @Description takeover a cluster
@@ -358,6 +450,79 @@ func ScaleIn(c *gin.Context) {
 	}
 }
 
+// ExportSpec export the spec of a cluster
+// @Summary export the spec of a cluster
+// @Description export the current state of a cluster as a spec, which can be kept and applied later
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.ExportClusterSpecResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/spec [get]
+func ExportSpec(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.ExportClusterSpecReq{
+		ClusterID: c.Param(ParamClusterID),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ExportClusterSpec, &cluster.ExportClusterSpecResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// PlanSpec compute the plan of a cluster spec
+// @Summary compute the plan of a cluster spec
+// @Description compute changes to reconcile a cluster with the spec, nothing will be changed
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Param planReq body cluster.PlanClusterSpecReq true "plan request"
+// @Success 200 {object} controller.CommonResult{data=cluster.PlanClusterSpecResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/spec/plan [post]
+func PlanSpec(c *gin.Context) {
+	if body, ok := controller.HandleJsonRequestFromBody(c, &cluster.PlanClusterSpecReq{},
+		func(c *gin.Context, req interface{}) error {
+			req.(*cluster.PlanClusterSpecReq).ClusterID = c.Param(ParamClusterID)
+			return nil
+		}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.PlanClusterSpec,
+			&cluster.PlanClusterSpecResp{}, body, controller.DefaultTimeout)
+	}
+}
+
+// ApplySpec apply a spec to a cluster
+// @Summary apply a spec to a cluster
+// @Description apply changes in the plan by scale out, scale in, upgrade, parameters, backup strategy and tags in order
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Param applyReq body cluster.ApplyClusterSpecReq true "apply request"
+// @Success 200 {object} controller.CommonResult{data=cluster.ApplyClusterSpecResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/spec/apply [post]
+func ApplySpec(c *gin.Context) {
+	if body, ok := controller.HandleJsonRequestFromBody(c, &cluster.ApplyClusterSpecReq{},
+		func(c *gin.Context, req interface{}) error {
+			req.(*cluster.ApplyClusterSpecReq).ClusterID = c.Param(ParamClusterID)
+			return nil
+		}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ApplyClusterSpec,
+			&cluster.ApplyClusterSpecResp{}, body, controller.DefaultTimeout)
+	}
+}
+
 // Clone clone a cluster
 // @Summary clone a cluster
 // @Description clone a cluster
@@ -0,0 +1,99 @@
+/******************************************************************************
+ * Copyright (c)  2021 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package management
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pingcap/tiunimanager/common/client"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-api/controller"
+)
+
+// QueryGC query gc status of a cluster
+// @Summary query gc status of a cluster
+// @Description query gc safepoint, service safepoints held by TiCDC and BR, tidb_gc_life_time and gc holds of a cluster
+// @Tags cluster
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryClusterGCResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/gc [get]
+func QueryGC(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryClusterGCReq{
+		ClusterID: c.Param(ParamClusterID),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryClusterGC, &cluster.QueryClusterGCResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CreateGCHold take a gc hold on a cluster
+// @Summary take a gc hold on a cluster
+// @Description tidb_gc_life_time of cluster is kept no less than gcLifeTime of every hold until the hold is released or expired.
+// @Description Taking a hold with an existing name renews it. The original tidb_gc_life_time is restored after all holds are released or expired
+// @Tags cluster
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Param holdReq body cluster.CreateGCHoldReq true "gc hold"
+// @Success 200 {object} controller.CommonResult{data=cluster.CreateGCHoldResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/gc/holds [post]
+func CreateGCHold(c *gin.Context) {
+	req := cluster.CreateGCHoldReq{
+		ClusterID: c.Param(ParamClusterID),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.CreateGCHold, &cluster.CreateGCHoldResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// ReleaseGCHold release a gc hold of a cluster
+// @Summary release a gc hold of a cluster
+// @Description release a gc hold of a cluster, the original tidb_gc_life_time is restored if there is no other hold
+// @Tags cluster
+// @Accept application/json
+// @Produce application/json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Param name path string true "hold name"
+// @Success 200 {object} controller.CommonResult{data=cluster.ReleaseGCHoldResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/gc/holds/{name} [delete]
+func ReleaseGCHold(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.ReleaseGCHoldReq{
+		ClusterID: c.Param(ParamClusterID),
+		Name:      c.Param("name"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.ReleaseGCHold, &cluster.ReleaseGCHoldResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
@@ -44,3 +44,153 @@ func Switchover(c *gin.Context) {
 			controller.DefaultTimeout)
 	}
 }
+
+// SwitchoverReadiness report readiness of a planned switchover
+// @Summary report readiness of a planned switchover
+// @Description all pre-checks of switchover are run and reported, together with estimated RPO by checkpoint lag of sync change feed,
+// @Description read only state and reachability of both sides, version and parameter differences, change feeds re-pointed by switchover
+// @Description and estimated cutover duration by latest finished switchover workflows. Only read only probes are used, nothing is written to clusters
+// @Tags switchover
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param switchoverReadinessReq body cluster.SwitchoverReadinessReq true "switchover readiness request"
+// @Success 200 {object} controller.CommonResult{data=cluster.SwitchoverReadinessResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/switchover/readiness [post]
+func SwitchoverReadiness(c *gin.Context) {
+	var req cluster.SwitchoverReadinessReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.SwitchoverReadiness, &cluster.SwitchoverReadinessResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryFailoverPolicy query automatic failover policy of a standby cluster
+// @Summary query automatic failover policy of a standby cluster
+// @Description query automatic failover policy of a standby cluster, with consecutive failures of its master and latest failover events
+// @Tags switchover
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "standby cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryFailoverPolicyResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/failover_policy [get]
+func QueryFailoverPolicy(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryFailoverPolicyReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryFailoverPolicy, &cluster.QueryFailoverPolicyResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// UpdateFailoverPolicy update automatic failover policy of a standby cluster
+// @Summary update automatic failover policy of a standby cluster
+// @Description master is probed by tidb read/write, pd and sync change feeds every 30 seconds. After master is confirmed unavailable by more than one probe for threshold consecutive rounds,
+// @Description including the state of sync change feeds recently reported by TiCDC, which does not depend on network between TiUniManager and master,
+// @Description force switchover to the available standby with smallest lag is triggered, unless any involved cluster failed over in cooldown.
+// @Description The policy is disarmed by switchover
+// @Tags switchover
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "standby cluster id"
+// @Param updateReq body cluster.UpdateFailoverPolicyReq true "failover policy"
+// @Success 200 {object} controller.CommonResult{data=cluster.UpdateFailoverPolicyResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/failover_policy [put]
+func UpdateFailoverPolicy(c *gin.Context) {
+	req := cluster.UpdateFailoverPolicyReq{
+		ClusterID: c.Param("clusterId"),
+	}
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.UpdateFailoverPolicy, &cluster.UpdateFailoverPolicyResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// CreateClusterRelation create replication relation between two existing clusters
+// @Summary create replication relation between two existing clusters
+// @Description StandBy relation replicates from the writable root of replication chain, Cascade relation replicates from a standby or cascade copy, such as B->C of A->B->C.
+// @Description Target of one-way relation is set read only. Bidirectional relation replicates disjoint tables in each direction, so that changes are never replicated back.
+// @Description Target cluster must be standalone. Target of one-way relation must be restored from a finished full backup of source cluster, and replication starts from tso of the backup.
+// @Description Replication of bidirectional relation starts from current tso, and both clusters must already have the data
+// @Tags switchover
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param createReq body cluster.CreateClusterRelationReq true "relation"
+// @Success 200 {object} controller.CommonResult{data=cluster.CreateClusterRelationResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/relations [post]
+func CreateClusterRelation(c *gin.Context) {
+	var req cluster.CreateClusterRelationReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.CreateClusterRelation, &cluster.CreateClusterRelationResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// DeleteClusterRelation delete replication relation between two clusters
+// @Summary delete replication relation between two clusters
+// @Description sync change feed tasks of the relation are deleted, both directions of bidirectional relation are deleted.
+// @Description Target of one-way relation becomes a standalone writable cluster, and it should have no downstream clusters
+// @Tags switchover
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param deleteReq body cluster.DeleteClusterRelationReq true "relation"
+// @Success 200 {object} controller.CommonResult{data=cluster.DeleteClusterRelationResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/relations [delete]
+func DeleteClusterRelation(c *gin.Context) {
+	var req cluster.DeleteClusterRelationReq
+
+	if requestBody, ok := controller.HandleJsonRequestFromBody(c, &req); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.DeleteClusterRelation, &cluster.DeleteClusterRelationResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
+// QueryReplicationGraph query replication graph of a cluster
+// @Summary query replication graph of a cluster
+// @Description all clusters connected with the cluster by relations, with status, filter rules and checkpoint lag of sync change feed of each relation
+// @Tags switchover
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param clusterId path string true "cluster id"
+// @Success 200 {object} controller.CommonResult{data=cluster.QueryReplicationGraphResp}
+// @Failure 401 {object} controller.CommonResult
+// @Failure 403 {object} controller.CommonResult
+// @Failure 500 {object} controller.CommonResult
+// @Router /clusters/{clusterId}/replication_graph [get]
+func QueryReplicationGraph(c *gin.Context) {
+	if requestBody, ok := controller.HandleJsonRequestWithBuiltReq(c, &cluster.QueryReplicationGraphReq{
+		ClusterID: c.Param("clusterId"),
+	}); ok {
+		controller.InvokeRpcMethod(c, client.ClusterClient.QueryReplicationGraph, &cluster.QueryReplicationGraphResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/pingcap/tiunimanager/common/errors"
 	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/message"
+	"github.com/pingcap/tiunimanager/message/cluster"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pingcap/tiunimanager/micro-api/controller"
@@ -72,6 +73,33 @@ func GetHierarchy(c *gin.Context) {
 	}
 }
 
+// GetTopologyGraph godoc
+// @Summary Show instances on hosts of a subtree
+// @Description show instances on hosts of a subtree specified by region, zone, rack or host ip, and which clusters a failure of the subtree would hurt
+// @Tags resource
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param location query cluster.GetHostTopologyGraphReq true "subtree location"
+// @Success 200 {object} controller.CommonResult{data=cluster.GetHostTopologyGraphResp}
+// @Router /resources/hierarchy/graph [get]
+func GetTopologyGraph(c *gin.Context) {
+	var req cluster.GetHostTopologyGraphReq
+
+	requestBody, ok := controller.HandleJsonRequestFromQuery(c, &req)
+	if ok {
+		if (req.Zone != "" && req.Region == "") || (req.Rack != "" && req.Zone == "") {
+			errmsg := fmt.Sprintf("input location %v invalid, zone requires region and rack requires zone", req.Location)
+			setGinContextForInvalidParam(c, errmsg)
+			return
+		}
+
+		controller.InvokeRpcMethod(c, client.ClusterClient.GetHostTopologyGraph, &cluster.GetHostTopologyGraphResp{},
+			requestBody,
+			controller.DefaultTimeout)
+	}
+}
+
 // GetStocks godoc
 // @Summary Show the resources stocks
 // @Description get resource stocks in specified conditions
@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/tiunimanager/metrics"
 	"github.com/pingcap/tiunimanager/micro-api/controller/cluster/backuprestore"
 	"github.com/pingcap/tiunimanager/micro-api/controller/cluster/changefeed"
+	dmApi "github.com/pingcap/tiunimanager/micro-api/controller/cluster/dm"
 	logApi "github.com/pingcap/tiunimanager/micro-api/controller/cluster/log"
 	clusterApi "github.com/pingcap/tiunimanager/micro-api/controller/cluster/management"
 	parameterApi "github.com/pingcap/tiunimanager/micro-api/controller/cluster/parameter"
@@ -113,6 +114,7 @@ func Route(g *gin.Engine) {
 			tenant.POST("/:tenantId/update_on_boarding_status", metrics.HandleMetrics(constants.MetricsTenantUpdateOnBoardingStatus), userApi.UpdateTenantOnBoardingStatus)
 			tenant.GET("/:tenantId", metrics.HandleMetrics(constants.MetricsTenantGet), userApi.GetTenant)
 			tenant.GET("/", metrics.HandleMetrics(constants.MetricsTenantQuery), userApi.QueryTenants)
+			tenant.GET("/:tenantId/cost_report", metrics.HandleMetrics(constants.MetricsTenantCostReport), clusterApi.CostReport)
 		}
 
 		rbac := apiV1.Group("/rbac")
@@ -136,6 +138,7 @@ func Route(g *gin.Engine) {
 			cluster.Use(interceptor.VerifyIdentity)
 			cluster.Use(interceptor.AuditLog)
 			cluster.GET("/:clusterId", metrics.HandleMetrics(constants.MetricsClusterDetail), clusterApi.Detail)
+			cluster.GET("/:clusterId/topology/graph", metrics.HandleMetrics(constants.MetricsClusterTopologyGraph), clusterApi.TopologyGraph)
 			cluster.POST("/", metrics.HandleMetrics(constants.MetricsClusterCreate), clusterApi.Create)
 			cluster.POST("/takeover", metrics.HandleMetrics(constants.MetricsClusterTakeover), clusterApi.Takeover)
 			cluster.POST("/preview", metrics.HandleMetrics(constants.MetricsClusterPreview), clusterApi.Preview)
@@ -144,6 +147,8 @@ func Route(g *gin.Engine) {
 			cluster.DELETE("/:clusterId", metrics.HandleMetrics(constants.MetricsClusterDelete), clusterApi.Delete)
 			cluster.POST("/:clusterId/restart", metrics.HandleMetrics(constants.MetricsClusterRestart), clusterApi.Restart)
 			cluster.POST("/:clusterId/stop", metrics.HandleMetrics(constants.MetricsClusterStop), clusterApi.Stop)
+			cluster.POST("/:clusterId/hibernate", metrics.HandleMetrics(constants.MetricsClusterHibernate), clusterApi.Hibernate)
+			cluster.POST("/:clusterId/wake", metrics.HandleMetrics(constants.MetricsClusterWake), clusterApi.Wake)
 			cluster.POST("/restore", metrics.HandleMetrics(constants.MetricsClusterRestore), backuprestore.Restore)
 			cluster.GET("/:clusterId/dashboard", metrics.HandleMetrics(constants.MetricsClusterQueryDashboardAddress), clusterApi.GetDashboardInfo)
 			cluster.GET("/:clusterId/monitor", metrics.HandleMetrics(constants.MetricsClusterQueryMonitorAddress), clusterApi.GetMonitorInfo)
@@ -155,11 +160,39 @@ func Route(g *gin.Engine) {
 			cluster.POST("/:clusterId/scale-out", metrics.HandleMetrics(constants.MetricsClusterScaleOut), clusterApi.ScaleOut)
 			cluster.POST("/:clusterId/scale-in", metrics.HandleMetrics(constants.MetricsClusterScaleIn), clusterApi.ScaleIn)
 
+			// Cluster spec
+			cluster.GET("/:clusterId/spec", metrics.HandleMetrics(constants.MetricsClusterExportSpec), clusterApi.ExportSpec)
+			cluster.POST("/:clusterId/spec/plan", metrics.HandleMetrics(constants.MetricsClusterPlanSpec), clusterApi.PlanSpec)
+			cluster.POST("/:clusterId/spec/apply", metrics.HandleMetrics(constants.MetricsClusterApplySpec), clusterApi.ApplySpec)
+
+			// Cluster gc
+			cluster.GET("/:clusterId/gc", metrics.HandleMetrics(constants.MetricsClusterQueryGC), clusterApi.QueryGC)
+			cluster.POST("/:clusterId/gc/holds", metrics.HandleMetrics(constants.MetricsClusterCreateGCHold), clusterApi.CreateGCHold)
+			cluster.DELETE("/:clusterId/gc/holds/:name", metrics.HandleMetrics(constants.MetricsClusterReleaseGCHold), clusterApi.ReleaseGCHold)
+
+			// Data migration
+			cluster.GET("/:clusterId/dm/sources", metrics.HandleMetrics(constants.MetricsClusterQueryDMSources), dmApi.QuerySources)
+			cluster.POST("/:clusterId/dm/sources", metrics.HandleMetrics(constants.MetricsClusterRegisterDMSource), dmApi.RegisterSource)
+			cluster.DELETE("/:clusterId/dm/sources/:name", metrics.HandleMetrics(constants.MetricsClusterDeleteDMSource), dmApi.DeleteSource)
+			cluster.GET("/:clusterId/dm/tasks", metrics.HandleMetrics(constants.MetricsClusterQueryDMTasks), dmApi.QueryTasks)
+			cluster.POST("/:clusterId/dm/tasks", metrics.HandleMetrics(constants.MetricsClusterCreateDMTask), dmApi.CreateTask)
+			cluster.GET("/dm/tasks/:taskId", metrics.HandleMetrics(constants.MetricsClusterDetailDMTask), dmApi.DetailTask)
+			cluster.POST("/dm/tasks/:taskId/start", metrics.HandleMetrics(constants.MetricsClusterStartDMTask), dmApi.StartTask)
+			cluster.POST("/dm/tasks/:taskId/pause", metrics.HandleMetrics(constants.MetricsClusterPauseDMTask), dmApi.PauseTask)
+			cluster.POST("/dm/tasks/:taskId/stop", metrics.HandleMetrics(constants.MetricsClusterStopDMTask), dmApi.StopTask)
+			cluster.POST("/dm/tasks/:taskId/handoff", metrics.HandleMetrics(constants.MetricsClusterHandoffDMTask), dmApi.HandoffTask)
+
 			// Clone cluster
 			cluster.POST("/clone", metrics.HandleMetrics(constants.MetricsClusterClone), clusterApi.Clone)
 
 			// Switchover
 			cluster.POST("/switchover", metrics.HandleMetrics(constants.MetricsClusterSwitchover), switchoverApi.Switchover)
+			cluster.POST("/switchover/readiness", metrics.HandleMetrics(constants.MetricsClusterSwitchoverReadiness), switchoverApi.SwitchoverReadiness)
+			cluster.GET("/:clusterId/failover_policy", metrics.HandleMetrics(constants.MetricsClusterQueryFailoverPolicy), switchoverApi.QueryFailoverPolicy)
+			cluster.PUT("/:clusterId/failover_policy", metrics.HandleMetrics(constants.MetricsClusterUpdateFailoverPolicy), switchoverApi.UpdateFailoverPolicy)
+			cluster.POST("/relations", metrics.HandleMetrics(constants.MetricsClusterCreateRelation), switchoverApi.CreateClusterRelation)
+			cluster.DELETE("/relations", metrics.HandleMetrics(constants.MetricsClusterDeleteRelation), switchoverApi.DeleteClusterRelation)
+			cluster.GET("/:clusterId/replication_graph", metrics.HandleMetrics(constants.MetricsClusterReplicationGraph), switchoverApi.QueryReplicationGraph)
 
 			// Params
 			cluster.GET("/:clusterId/params", metrics.HandleMetrics(constants.MetricsClusterQueryParameter), parameterApi.QueryParameters)
@@ -170,6 +203,20 @@ func Route(g *gin.Engine) {
 			cluster.GET("/:clusterId/strategy", metrics.HandleMetrics(constants.MetricsBackupQueryStrategy), backuprestore.GetBackupStrategy)
 			cluster.PUT("/:clusterId/strategy", metrics.HandleMetrics(constants.MetricsBackupModifyStrategy), backuprestore.SaveBackupStrategy)
 
+			// Log Backup && Point-in-time Restore
+			cluster.POST("/:clusterId/restore", metrics.HandleMetrics(constants.MetricsClusterRestoreExist), backuprestore.RestoreExistCluster)
+			cluster.GET("/:clusterId/log_backup", metrics.HandleMetrics(constants.MetricsBackupQueryLog), backuprestore.QueryLogBackup)
+			cluster.POST("/:clusterId/log_backup/start", metrics.HandleMetrics(constants.MetricsBackupStartLog), backuprestore.StartLogBackup)
+			cluster.POST("/:clusterId/log_backup/stop", metrics.HandleMetrics(constants.MetricsBackupStopLog), backuprestore.StopLogBackup)
+			cluster.GET("/:clusterId/schedules", metrics.HandleMetrics(constants.MetricsBackupQuerySchedule), backuprestore.QueryBackupSchedules)
+			cluster.POST("/:clusterId/schedules", metrics.HandleMetrics(constants.MetricsBackupSaveSchedule), backuprestore.CreateBackupSchedule)
+			cluster.PUT("/:clusterId/schedules/:scheduleId", metrics.HandleMetrics(constants.MetricsBackupSaveSchedule), backuprestore.UpdateBackupSchedule)
+			cluster.DELETE("/:clusterId/schedules/:scheduleId", metrics.HandleMetrics(constants.MetricsBackupDeleteSchedule), backuprestore.DeleteBackupSchedule)
+			cluster.GET("/:clusterId/backup_keys", metrics.HandleMetrics(constants.MetricsBackupQueryKey), backuprestore.QueryBackupKeys)
+			cluster.POST("/:clusterId/backup_keys/rotate", metrics.HandleMetrics(constants.MetricsBackupRotateKey), backuprestore.RotateBackupKey)
+			cluster.POST("/:clusterId/backup_keys/export", metrics.HandleMetrics(constants.MetricsBackupExportKey), backuprestore.ExportBackupKey)
+			cluster.POST("/:clusterId/backup_keys/import", metrics.HandleMetrics(constants.MetricsBackupImportKey), backuprestore.ImportBackupKey)
+
 			//Import and Export
 			cluster.POST("/import", metrics.HandleMetrics(constants.MetricsDataImport), importexport.ImportData)
 			cluster.POST("/export", metrics.HandleMetrics(constants.MetricsDataExport), importexport.ExportData)
@@ -200,6 +247,21 @@ func Route(g *gin.Engine) {
 			backup.POST("/cancel", metrics.HandleMetrics(constants.MetricsBackupCancel), backuprestore.CancelBackup)
 			backup.GET("/", metrics.HandleMetrics(constants.MetricsBackupQuery), backuprestore.QueryBackupRecords)
 			backup.DELETE("/:backupId", metrics.HandleMetrics(constants.MetricsBackupDelete), backuprestore.DeleteBackup)
+			backup.POST("/copy", metrics.HandleMetrics(constants.MetricsBackupCopy), backuprestore.CopyBackup)
+			backup.POST("/import", metrics.HandleMetrics(constants.MetricsBackupImport), backuprestore.ImportBackup)
+			backup.POST("/restore_check", metrics.HandleMetrics(constants.MetricsBackupCheckRestore), backuprestore.CheckRestore)
+		}
+
+		backupTarget := apiV1.Group("/backup_targets")
+		{
+			backupTarget.Use(interceptor.SystemRunning)
+			backupTarget.Use(interceptor.VerifyIdentity)
+			backupTarget.Use(interceptor.AuditLog)
+
+			backupTarget.GET("/", metrics.HandleMetrics(constants.MetricsBackupQueryTarget), backuprestore.QueryBackupStorageTargets)
+			backupTarget.POST("/", metrics.HandleMetrics(constants.MetricsBackupSaveTarget), backuprestore.CreateBackupStorageTarget)
+			backupTarget.PUT("/:targetId", metrics.HandleMetrics(constants.MetricsBackupSaveTarget), backuprestore.UpdateBackupStorageTarget)
+			backupTarget.DELETE("/:targetId", metrics.HandleMetrics(constants.MetricsBackupDeleteTarget), backuprestore.DeleteBackupStorageTarget)
 		}
 
 		changeFeeds := apiV1.Group("/changefeeds")
@@ -217,6 +279,27 @@ func Route(g *gin.Engine) {
 
 			changeFeeds.GET("/:changeFeedTaskId/", metrics.HandleMetrics(constants.MetricsCDCTaskDetail), changefeed.Detail)
 			changeFeeds.GET("/", metrics.HandleMetrics(constants.MetricsCDCTaskQuery), changefeed.Query)
+
+			changeFeeds.POST("/:changeFeedTaskId/consistency_checks", metrics.HandleMetrics(constants.MetricsCDCConsistencyCheckStart), changefeed.StartConsistencyCheck)
+			changeFeeds.PUT("/:changeFeedTaskId/consistency_schedule", metrics.HandleMetrics(constants.MetricsCDCConsistencyScheduleSave), changefeed.SaveConsistencySchedule)
+			changeFeeds.GET("/:changeFeedTaskId/consistency_schedule", metrics.HandleMetrics(constants.MetricsCDCConsistencyScheduleGet), changefeed.GetConsistencySchedule)
+			changeFeeds.DELETE("/:changeFeedTaskId/consistency_schedule", metrics.HandleMetrics(constants.MetricsCDCConsistencyScheduleDelete), changefeed.DeleteConsistencySchedule)
+
+			changeFeeds.POST("/batch/pause", metrics.HandleMetrics(constants.MetricsCDCTaskBatchPause), changefeed.BatchPause)
+			changeFeeds.POST("/batch/resume", metrics.HandleMetrics(constants.MetricsCDCTaskBatchResume), changefeed.BatchResume)
+			changeFeeds.POST("/batch/delete", metrics.HandleMetrics(constants.MetricsCDCTaskBatchDelete), changefeed.BatchDelete)
+			changeFeeds.GET("/export", metrics.HandleMetrics(constants.MetricsCDCTaskExport), changefeed.Export)
+			changeFeeds.POST("/import", metrics.HandleMetrics(constants.MetricsCDCTaskImport), changefeed.Import)
+		}
+
+		consistencyChecks := apiV1.Group("/consistency_checks")
+		{
+			consistencyChecks.Use(interceptor.SystemRunning)
+			consistencyChecks.Use(interceptor.VerifyIdentity)
+			consistencyChecks.Use(interceptor.AuditLog)
+
+			consistencyChecks.GET("/", metrics.HandleMetrics(constants.MetricsCDCConsistencyCheckQuery), changefeed.QueryConsistencyChecks)
+			consistencyChecks.GET("/:checkId", metrics.HandleMetrics(constants.MetricsCDCConsistencyCheckDetail), changefeed.DetailConsistencyCheck)
 		}
 
 		flowworks := apiV1.Group("/workflow")
@@ -240,6 +323,7 @@ func Route(g *gin.Engine) {
 			host.DELETE("hosts", metrics.HandleMetrics(constants.MetricsResourceDeleteHosts), resourceApi.RemoveHosts)
 			host.GET("hosts-template", metrics.HandleMetrics(constants.MetricsResourceDownloadHostTemplateFile), resourceApi.DownloadHostTemplateFile)
 			host.GET("hierarchy", metrics.HandleMetrics(constants.MetricsResourceQueryHierarchy), warehouseApi.GetHierarchy)
+			host.GET("hierarchy/graph", metrics.HandleMetrics(constants.MetricsResourceQueryTopologyGraph), warehouseApi.GetTopologyGraph)
 			host.GET("stocks", metrics.HandleMetrics(constants.MetricsResourceQueryStocks), warehouseApi.GetStocks)
 			host.PUT("host-reserved", metrics.HandleMetrics(constants.MetricsResourceReservedHost), resourceApi.UpdateHostReserved)
 			host.PUT("host-status", metrics.HandleMetrics(constants.MetricsResourceModifyHostStatus), resourceApi.UpdateHostStatus)
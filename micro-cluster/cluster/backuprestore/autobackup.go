@@ -18,34 +18,78 @@ package backuprestore
 
 import (
 	"context"
+	"fmt"
 	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
 	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
 	"github.com/robfig/cron"
+	"math/rand"
+	"sync"
 	"time"
 )
 
 type autoBackupManager struct {
-	JobCron *cron.Cron
-	JobSpec string
+	JobCron           *cron.Cron
+	JobSpec           string
+	PurgeJobSpec      string
+	CheckpointJobSpec string
+	ScheduleJobSpec   string
 }
 
 type autoBackupHandler struct {
 }
 
+type backupPurgeHandler struct {
+}
+
+type logBackupCheckpointHandler struct {
+}
+
+type backupScheduleHandler struct {
+}
+
+// autoBackupDispatchInterval interval of retrying to start an auto backup when too many backups are running
+var autoBackupDispatchInterval = 30 * time.Second
+
+// autoBackupDispatchTimeout an auto backup is given up if it cannot be started within it
+var autoBackupDispatchTimeout = time.Hour
+
+// autoBackupDispatchMutex make checking running backups and starting a new one atomic
+var autoBackupDispatchMutex sync.Mutex
+
 func NewAutoBackupManager() *autoBackupManager {
 	mgr := &autoBackupManager{
-		JobCron: cron.New(),
-		JobSpec: "0 0 * * * *", // every integer hour
+		JobCron:           cron.New(),
+		JobSpec:           "0 0 * * * *",  // every integer hour
+		PurgeJobSpec:      "0 30 * * * *", // every half past hour
+		CheckpointJobSpec: "0 * * * * *",  // every minute
+		ScheduleJobSpec:   "30 * * * * *", // every minute at half
 	}
 	err := mgr.JobCron.AddJob(mgr.JobSpec, &autoBackupHandler{})
 	if err != nil {
 		framework.Log().Fatalf("add auto backup cron job failed, %s", err.Error())
 		return nil
 	}
+	err = mgr.JobCron.AddJob(mgr.PurgeJobSpec, &backupPurgeHandler{})
+	if err != nil {
+		framework.Log().Fatalf("add backup purge cron job failed, %s", err.Error())
+		return nil
+	}
+	err = mgr.JobCron.AddJob(mgr.CheckpointJobSpec, &logBackupCheckpointHandler{})
+	if err != nil {
+		framework.Log().Fatalf("add log backup checkpoint cron job failed, %s", err.Error())
+		return nil
+	}
+	err = mgr.JobCron.AddJob(mgr.ScheduleJobSpec, &backupScheduleHandler{})
+	if err != nil {
+		framework.Log().Fatalf("add backup schedule cron job failed, %s", err.Error())
+		return nil
+	}
 	go mgr.start()
 
 	return mgr
@@ -90,12 +134,180 @@ func (auto *autoBackupHandler) doBackup(strategy *backuprestore.BackupStrategy)
 	}
 
 	ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: meta.Cluster.TenantId})
-	_, err = GetBRService().BackupCluster(ctx, cluster.BackupClusterDataReq{
+	_, err = dispatchAutoBackup(ctx, cluster.BackupClusterDataReq{
 		ClusterID:  strategy.ClusterID,
 		BackupMode: string(constants.BackupModeAuto),
-	}, true)
+		Filter: structs.BackupFilter{
+			Databases: splitFilterNames(strategy.Databases),
+			Tables:    splitFilterNames(strategy.Tables),
+		},
+	})
 	if err != nil {
 		framework.LogWithContext(context.Background()).Errorf("do backup for cluster %s failed, %s", strategy.ClusterID, err.Error())
 		return
 	}
 }
+
+func (handler *backupScheduleHandler) Run() {
+	rw := models.GetBRReaderWriter()
+	schedules, err := rw.QueryBackupSchedules(context.TODO(), "")
+	if err != nil {
+		framework.Log().Errorf("query backup schedules failed, %s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, schedule := range schedules {
+		if !schedule.Paused {
+			handler.evaluate(schedule, now)
+		}
+	}
+}
+
+// evaluate
+// @Description: start backup of schedule in background if it is due, and record missed runs
+func (handler *backupScheduleHandler) evaluate(schedule *backuprestore.BackupSchedule, now time.Time) {
+	ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: schedule.TenantId})
+	run, err := evaluateBackupSchedule(schedule, now)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("evaluate backup schedule %s of cluster %s failed, %s", schedule.ID, schedule.ClusterID, err.Error())
+		return
+	}
+	if run.scheduled.Equal(schedule.LastScheduledTime) {
+		return
+	}
+
+	schedule.LastScheduledTime = run.scheduled
+	if run.missed > 0 {
+		framework.LogWithContext(ctx).Warnf("backup schedule %s of cluster %s missed %d runs, the latest at %s, policy %s",
+			schedule.ID, schedule.ClusterID, run.missed, run.lastMissed.Format(time.RFC3339), schedule.MissedRunPolicy)
+		schedule.MissedRuns += uint32(run.missed)
+		schedule.LastMissedTime = run.lastMissed
+	}
+	// save before starting backup, so that a run is never started twice
+	if err = models.GetBRReaderWriter().UpdateBackupScheduleRun(ctx, schedule); err != nil {
+		framework.LogWithContext(ctx).Errorf("update backup schedule %s failed, %s", schedule.ID, err.Error())
+		return
+	}
+	if run.due {
+		go handler.doBackup(ctx, schedule)
+	}
+}
+
+func (handler *backupScheduleHandler) doBackup(ctx context.Context, schedule *backuprestore.BackupSchedule) {
+	framework.LogWithContext(ctx).Infof("begin do scheduled backup %s for cluster %s", schedule.ID, schedule.ClusterID)
+	defer framework.LogWithContext(ctx).Infof("end do scheduled backup %s for cluster %s", schedule.ID, schedule.ClusterID)
+
+	resp, err := dispatchAutoBackup(ctx, cluster.BackupClusterDataReq{
+		ClusterID:  schedule.ClusterID,
+		BackupMode: string(constants.BackupModeAuto),
+		Filter: structs.BackupFilter{
+			Databases: splitFilterNames(schedule.Databases),
+			Tables:    splitFilterNames(schedule.Tables),
+		},
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("do scheduled backup %s for cluster %s failed, %s", schedule.ID, schedule.ClusterID, err.Error())
+		return
+	}
+	if err = models.GetBRReaderWriter().UpdateBackupScheduleLastBackup(ctx, schedule.ID, resp.BackupID); err != nil {
+		framework.LogWithContext(ctx).Warnf("update last backup of schedule %s failed, %s", schedule.ID, err.Error())
+	}
+}
+
+// dispatchAutoBackup
+// @Description: start auto backup after a random delay within configured jitter, so that backups scheduled at
+// the same time do not start together, and wait while the number of running backups reaches the configured limit
+// @Parameter ctx
+// @Parameter request
+// @return cluster.BackupClusterDataResp
+// @return error
+func dispatchAutoBackup(ctx context.Context, request cluster.BackupClusterDataReq) (cluster.BackupClusterDataResp, error) {
+	if jitter := platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyAutoBackupJitter, constants.DefaultAutoBackupJitter); jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(jitter) * int64(time.Second))))
+	}
+
+	deadline := time.Now().Add(autoBackupDispatchTimeout)
+	for {
+		started, resp, err := tryStartAutoBackup(ctx, request)
+		if started || err != nil {
+			return resp, err
+		}
+		if time.Now().After(deadline) {
+			return resp, fmt.Errorf("too many backups are running, not started within %s", autoBackupDispatchTimeout)
+		}
+		time.Sleep(autoBackupDispatchInterval)
+	}
+}
+
+func tryStartAutoBackup(ctx context.Context, request cluster.BackupClusterDataReq) (bool, cluster.BackupClusterDataResp, error) {
+	autoBackupDispatchMutex.Lock()
+	defer autoBackupDispatchMutex.Unlock()
+
+	if limit := platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyAutoBackupMaxConcurrency, constants.DefaultAutoBackupMaxConcurrency); limit > 0 {
+		running, err := models.GetBRReaderWriter().CountBackupRecordsByStatus(ctx, string(constants.ClusterBackupProcessing))
+		if err != nil {
+			return false, cluster.BackupClusterDataResp{}, fmt.Errorf("count running backups failed, %s", err.Error())
+		}
+		if running >= int64(limit) {
+			framework.LogWithContext(ctx).Infof("%d backups are running, reach limit %d, wait to backup cluster %s", running, limit, request.ClusterID)
+			return false, cluster.BackupClusterDataResp{}, nil
+		}
+	}
+	resp, err := GetBRService().BackupCluster(ctx, request, true)
+	return true, resp, err
+}
+
+func (purge *backupPurgeHandler) Run() {
+	framework.Log().Infof("begin BackupPurgeHandler Run")
+	defer framework.Log().Infof("end BackupPurgeHandler Run")
+
+	rw := models.GetBRReaderWriter()
+	strategies, err := rw.QueryRetentionBackupStrategies(context.TODO())
+	if err != nil {
+		framework.Log().Errorf("query backup strategies with retention failed, %s", err.Error())
+		return
+	}
+
+	framework.Log().Infof("need purge expired backups for %d clusters", len(strategies))
+	for _, strategy := range strategies {
+		purge.doPurge(strategy)
+	}
+}
+
+func (purge *backupPurgeHandler) doPurge(strategy *backuprestore.BackupStrategy) {
+	ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: strategy.TenantId})
+	purgedIDs, err := GetBRService().PurgeExpiredBackupRecords(ctx, strategy.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("purge expired backups of cluster %s failed, %s", strategy.ClusterID, err.Error())
+		return
+	}
+	framework.LogWithContext(ctx).Infof("purged %d expired backups of cluster %s: %v", len(purgedIDs), strategy.ClusterID, purgedIDs)
+}
+
+func (checkpoint *logBackupCheckpointHandler) Run() {
+	rw := models.GetBRReaderWriter()
+	tasks, err := rw.QueryLogBackupTasks(context.TODO(), "", "")
+	if err != nil {
+		framework.Log().Errorf("query log backup tasks failed, %s", err.Error())
+		return
+	}
+
+	for _, task := range tasks {
+		if task.Status != string(constants.LogBackupStopped) {
+			checkpoint.doRefresh(task)
+		}
+	}
+}
+
+func (checkpoint *logBackupCheckpointHandler) doRefresh(task *backuprestore.LogBackupTask) {
+	ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: task.TenantId})
+	clusterMeta, err := meta.Get(ctx, task.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", task.ClusterID, err.Error())
+		return
+	}
+	if err = refreshLogBackupTask(ctx, clusterMeta, task); err != nil {
+		framework.LogWithContext(ctx).Errorf("refresh checkpoint of log backup task %s failed, %s", task.TaskName, err.Error())
+	}
+}
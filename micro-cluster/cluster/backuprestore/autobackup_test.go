@@ -16,17 +16,25 @@
 package backuprestore
 
 import (
+	"context"
+	"errors"
 	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/deployment"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
 	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
 	mock_br_service "github.com/pingcap/tiunimanager/test/mockbr"
+	mock_deployment "github.com/pingcap/tiunimanager/test/mockdeployment"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestNewAutoBackupManager(t *testing.T) {
@@ -61,6 +69,10 @@ func Test_AutoBackup_doBackup(t *testing.T) {
 	}, make([]*management.ClusterInstance, 0), make([]*management.DBUser, 0), nil).AnyTimes()
 	clusterRW.EXPECT().SetMaintenanceStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), gomock.Any()).Return(&config.SystemConfig{ConfigValue: "0"}, nil).AnyTimes()
+
 	mockBRService := mock_br_service.NewMockBRService(ctrl)
 	mockBRService.EXPECT().BackupCluster(gomock.Any(), gomock.Any(),
 		gomock.Any()).Return(cluster.BackupClusterDataResp{}, nil).AnyTimes()
@@ -76,3 +88,153 @@ func Test_AutoBackup_doBackup(t *testing.T) {
 	handler := &autoBackupHandler{}
 	handler.doBackup(strategy)
 }
+
+func Test_BackupPurge_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().QueryRetentionBackupStrategies(gomock.Any()).Return([]*backuprestore.BackupStrategy{
+		{ClusterID: "cls-xxxx", KeepLast: 1},
+		{ClusterID: "cls-yyyy", KeepDays: 1},
+	}, nil)
+	models.SetBRReaderWriter(brRW)
+
+	mockBRService := mock_br_service.NewMockBRService(ctrl)
+	mockBRService.EXPECT().PurgeExpiredBackupRecords(gomock.Any(), "cls-xxxx").Return([]string{"record-xxx"}, nil)
+	mockBRService.EXPECT().PurgeExpiredBackupRecords(gomock.Any(), "cls-yyyy").Return(nil, errors.New("purge failed"))
+	MockBRService(mockBRService)
+	defer MockBRService(NewBRManager())
+
+	handler := &backupPurgeHandler{}
+	handler.Run()
+}
+
+func Test_LogBackupCheckpoint_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	cls, instances := mockLogBackupCluster("v6.5.0")
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "", "").Return([]*backuprestore.LogBackupTask{
+		mockLogBackupTask(),
+		{Entity: common.Entity{Status: string(constants.LogBackupStopped)}, ClusterID: "cls-stopped"},
+	}, nil)
+	brRW.EXPECT().UpdateLogBackupTask(gomock.Any(), gomock.Any()).Return(nil)
+	models.SetBRReaderWriter(brRW)
+
+	mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+	mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).Return("[{\"name\":\"em-cls-test\"}]", nil)
+	deployment.M = mockTiupManager
+
+	handler := &logBackupCheckpointHandler{}
+	handler.Run()
+}
+
+func Test_BackupSchedule_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lastScheduledTime := time.Now().Add(-72 * time.Hour)
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().QueryBackupSchedules(gomock.Any(), "").Return([]*backuprestore.BackupSchedule{
+		{Entity: common.Entity{ID: "schedule-paused"}, CronSpec: "@daily", Paused: true, LastScheduledTime: lastScheduledTime},
+		{Entity: common.Entity{ID: "schedule-invalid"}, CronSpec: "invalid", LastScheduledTime: lastScheduledTime},
+		{Entity: common.Entity{ID: "schedule-report"}, CronSpec: "@daily", MissedRunPolicy: string(constants.BackupMissedRunPolicyReport),
+			LastScheduledTime: lastScheduledTime, MissedRuns: 1},
+	}, nil)
+	brRW.EXPECT().UpdateBackupScheduleRun(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, schedule *backuprestore.BackupSchedule) error {
+		assert.Equal(t, "schedule-report", schedule.ID)
+		assert.GreaterOrEqual(t, schedule.MissedRuns, uint32(3))
+		assert.False(t, schedule.LastMissedTime.IsZero())
+		assert.True(t, schedule.LastScheduledTime.After(lastScheduledTime))
+		return nil
+	})
+	models.SetBRReaderWriter(brRW)
+
+	handler := &backupScheduleHandler{}
+	handler.Run()
+}
+
+func Test_BackupSchedule_doBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyAutoBackupJitter).Return(&config.SystemConfig{ConfigValue: "0"}, nil)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyAutoBackupMaxConcurrency).Return(&config.SystemConfig{ConfigValue: "2"}, nil)
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().CountBackupRecordsByStatus(gomock.Any(), string(constants.ClusterBackupProcessing)).Return(int64(1), nil)
+	brRW.EXPECT().UpdateBackupScheduleLastBackup(gomock.Any(), "schedule01", "backup01").Return(nil)
+	models.SetBRReaderWriter(brRW)
+
+	mockBRService := mock_br_service.NewMockBRService(ctrl)
+	mockBRService.EXPECT().BackupCluster(gomock.Any(), gomock.Any(), true).DoAndReturn(
+		func(ctx context.Context, request cluster.BackupClusterDataReq, maintenanceStatusChange bool) (cluster.BackupClusterDataResp, error) {
+			assert.Equal(t, string(constants.BackupModeAuto), request.BackupMode)
+			assert.Equal(t, []string{"db1"}, request.Filter.Databases)
+			return cluster.BackupClusterDataResp{BackupID: "backup01"}, nil
+		})
+	MockBRService(mockBRService)
+	defer MockBRService(NewBRManager())
+
+	handler := &backupScheduleHandler{}
+	handler.doBackup(context.TODO(), &backuprestore.BackupSchedule{Entity: common.Entity{ID: "schedule01"}, ClusterID: "cls-test", Databases: "db1"})
+}
+
+func Test_dispatchAutoBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	interval, timeout := autoBackupDispatchInterval, autoBackupDispatchTimeout
+	autoBackupDispatchInterval = time.Millisecond
+	defer func() {
+		autoBackupDispatchInterval, autoBackupDispatchTimeout = interval, timeout
+	}()
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyAutoBackupJitter).Return(&config.SystemConfig{ConfigValue: "0"}, nil).AnyTimes()
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyAutoBackupMaxConcurrency).Return(&config.SystemConfig{ConfigValue: "2"}, nil).AnyTimes()
+
+	mockBRService := mock_br_service.NewMockBRService(ctrl)
+	MockBRService(mockBRService)
+	defer MockBRService(NewBRManager())
+
+	t.Run("wait for running backups", func(t *testing.T) {
+		autoBackupDispatchTimeout = time.Minute
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		gomock.InOrder(
+			brRW.EXPECT().CountBackupRecordsByStatus(gomock.Any(), gomock.Any()).Return(int64(2), nil).Times(2),
+			brRW.EXPECT().CountBackupRecordsByStatus(gomock.Any(), gomock.Any()).Return(int64(1), nil),
+		)
+		mockBRService.EXPECT().BackupCluster(gomock.Any(), gomock.Any(), true).Return(cluster.BackupClusterDataResp{BackupID: "backup01"}, nil)
+
+		resp, err := dispatchAutoBackup(context.TODO(), cluster.BackupClusterDataReq{ClusterID: "cls-test"})
+		assert.NoError(t, err)
+		assert.Equal(t, "backup01", resp.BackupID)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		autoBackupDispatchTimeout = 0
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().CountBackupRecordsByStatus(gomock.Any(), gomock.Any()).Return(int64(3), nil).AnyTimes()
+
+		_, err := dispatchAutoBackup(context.TODO(), cluster.BackupClusterDataReq{ClusterID: "cls-test"})
+		assert.Error(t, err)
+	})
+	t.Run("count failed", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().CountBackupRecordsByStatus(gomock.Any(), gomock.Any()).Return(int64(0), errors.New("count failed"))
+
+		_, err := dispatchAutoBackup(context.TODO(), cluster.BackupClusterDataReq{ClusterID: "cls-test"})
+		assert.Error(t, err)
+	})
+}
@@ -20,12 +20,20 @@ const (
 	contextBackupRecordKey            string = "backupRecord"
 	contextMaintenanceStatusChangeKey string = "maintenanceStatusChange"
 	contextBRInfoKey                  string = "brInfo"
+	contextLogBackupTaskKey           string = "logBackupTask"
+	contextRestoreTsoKey              string = "restoreTso"
+	contextRestoreFilterKey           string = "restoreFilter"
 )
 
 const (
 	defaultPageSize int = 10
 )
 
+const (
+	logBackupType       string = "log"
+	minLogBackupVersion string = "v6.2.0"
+)
+
 const (
 	Sunday    string = "Sunday"
 	Monday    string = "Monday"
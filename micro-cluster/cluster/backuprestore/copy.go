@@ -0,0 +1,459 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	dbModel "github.com/pingcap/tiunimanager/models/common"
+)
+
+// backupFile file of a backup, name is relative to the backup path
+type backupFile struct {
+	Name string
+	Size int64
+}
+
+// backupFileStore storage of backup files, a NFS path or a bucket and prefix of s3
+type backupFileStore interface {
+	listFiles(ctx context.Context) ([]backupFile, error)
+	openFile(ctx context.Context, name string) (io.ReadCloser, error)
+	writeFile(ctx context.Context, file backupFile, reader io.Reader) error
+}
+
+type nfsFileStore struct {
+	root string
+}
+
+func (store *nfsFileStore) listFiles(ctx context.Context) ([]backupFile, error) {
+	files := make([]backupFile, 0)
+	err := filepath.Walk(store.root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(store.root, filePath)
+		if err != nil {
+			return err
+		}
+		files = append(files, backupFile{Name: filepath.ToSlash(name), Size: info.Size()})
+		return nil
+	})
+	return files, err
+}
+
+func (store *nfsFileStore) openFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(store.root, filepath.FromSlash(name)))
+}
+
+func (store *nfsFileStore) writeFile(ctx context.Context, file backupFile, reader io.Reader) error {
+	filePath := filepath.Join(store.root, filepath.FromSlash(file.Name))
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, reader); err != nil {
+		f.Close()
+		return err
+	}
+	// data may be flushed on close, especially on NFS
+	return f.Close()
+}
+
+type s3FileStore struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func (store *s3FileStore) listFiles(ctx context.Context) ([]backupFile, error) {
+	files := make([]backupFile, 0)
+	for object := range store.client.ListObjects(ctx, store.bucket, minio.ListObjectsOptions{Recursive: true, Prefix: store.prefix + "/"}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		files = append(files, backupFile{Name: strings.TrimPrefix(object.Key, store.prefix+"/"), Size: object.Size})
+	}
+	return files, nil
+}
+
+func (store *s3FileStore) openFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	return store.client.GetObject(ctx, store.bucket, path.Join(store.prefix, name), minio.GetObjectOptions{})
+}
+
+func (store *s3FileStore) writeFile(ctx context.Context, file backupFile, reader io.Reader) error {
+	_, err := store.client.PutObject(ctx, store.bucket, path.Join(store.prefix, file.Name), reader, file.Size, minio.PutObjectOptions{})
+	return err
+}
+
+// newBackupFileStore
+// @Description: create file store of backup files on s3 or NFS
+// @Parameter ctx
+// @Parameter storageType
+// @Parameter filePath bucket and prefix for s3
+// @Parameter storageTargetID the backup storage of system config if empty
+// @return backupFileStore
+// @return error
+func newBackupFileStore(ctx context.Context, storageType string, filePath string, storageTargetID string) (backupFileStore, error) {
+	if string(constants.StorageTypeS3) != storageType {
+		return &nfsFileStore{root: filePath}, nil
+	}
+	if _, _, err := splitS3FilePath(filePath); err != nil {
+		return nil, err
+	}
+	s3Config, err := getS3Config(ctx, storageTargetID)
+	if err != nil {
+		return nil, err
+	}
+	return newS3FileStore(ctx, s3Config, filePath)
+}
+
+// newS3FileStore
+// @Description: create file store of backup files on s3 with the given endpoint and credentials
+// @Parameter ctx
+// @Parameter s3Config
+// @Parameter filePath bucket and prefix
+// @return backupFileStore
+// @return error
+func newS3FileStore(ctx context.Context, s3Config *s3Config, filePath string) (backupFileStore, error) {
+	bucket, prefix, err := splitS3FilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newS3Client(ctx, s3Config)
+	if err != nil {
+		return nil, err
+	}
+	return &s3FileStore{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func splitS3FilePath(filePath string) (bucket string, prefix string, err error) {
+	s3Addr := strings.SplitN(strings.Trim(filePath, "/"), "/", 2)
+	if len(s3Addr) != 2 {
+		return "", "", fmt.Errorf("invalid s3 file path %s, bucket and prefix are required", filePath)
+	}
+	return s3Addr[0], s3Addr[1], nil
+}
+
+// copyBackupFiles
+// @Description: copy all files of a backup, the checksum of each file is compared after it is written
+// @Parameter ctx
+// @Parameter src
+// @Parameter dst
+// @return size total bytes of files
+// @return checksum sha256 over names and checksums of all files
+// @return err
+func copyBackupFiles(ctx context.Context, src backupFileStore, dst backupFileStore) (size uint64, checksum string, err error) {
+	files, err := src.listFiles(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("list backup files failed, %s", err.Error())
+	}
+	if len(files) == 0 {
+		return 0, "", fmt.Errorf("no backup files found")
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	total := sha256.New()
+	for _, file := range files {
+		srcChecksum, err := copyBackupFile(ctx, src, dst, file)
+		if err != nil {
+			return 0, "", fmt.Errorf("copy backup file %s failed, %s", file.Name, err.Error())
+		}
+		dstChecksum, err := backupFileChecksum(ctx, dst, file.Name)
+		if err != nil {
+			return 0, "", fmt.Errorf("read copied backup file %s failed, %s", file.Name, err.Error())
+		}
+		if srcChecksum != dstChecksum {
+			return 0, "", fmt.Errorf("checksum of copied backup file %s mismatch, source %s, copy %s", file.Name, srcChecksum, dstChecksum)
+		}
+		size += uint64(file.Size)
+		fmt.Fprintf(total, "%s %s\n", file.Name, srcChecksum)
+	}
+	return size, hex.EncodeToString(total.Sum(nil)), nil
+}
+
+func copyBackupFile(ctx context.Context, src backupFileStore, dst backupFileStore, file backupFile) (string, error) {
+	reader, err := src.openFile(ctx, file.Name)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	h := sha256.New()
+	if err = dst.writeFile(ctx, file, io.TeeReader(reader, h)); err != nil {
+		return "", err
+	}
+	return sumOf(h), nil
+}
+
+func backupFileChecksum(ctx context.Context, store backupFileStore, name string) (string, error) {
+	reader, err := store.openFile(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return sumOf(h), nil
+}
+
+func sumOf(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (mgr *BRManager) CopyBackup(ctx context.Context, request cluster.CopyBackupReq) (resp cluster.CopyBackupResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin CopyBackup, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End CopyBackup")
+
+	brRW := models.GetBRReaderWriter()
+	record, err := brRW.GetBackupRecord(ctx, request.BackupID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup record %s failed, %s", request.BackupID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_QUERY_FAILED, fmt.Sprintf("get backup record %s failed, %s", request.BackupID, err.Error()), err)
+	}
+	if string(constants.ClusterBackupFinished) != record.Status {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup %s is %s, only finished backups can be copied", request.BackupID, record.Status)
+	}
+	target, err := brRW.GetBackupStorageTarget(ctx, request.TargetID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup storage target %s failed, %s", request.TargetID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, fmt.Sprintf("get backup storage target %s failed, %s", request.TargetID, err.Error()), err)
+	}
+
+	location, err := startBackupCopy(ctx, record, target)
+	if err != nil {
+		return resp, err
+	}
+	resp.Location = convertBackupLocation(location)
+	return resp, nil
+}
+
+// replicateBackup
+// @Description: copy a finished backup to the copy target of the backup strategy of its cluster, nothing to do if no copy target
+// @Parameter ctx
+// @Parameter record
+// @return *backuprestore.BackupLocation nil if no copy target
+// @return error
+func replicateBackup(ctx context.Context, record *backuprestore.BackupRecord) (*backuprestore.BackupLocation, error) {
+	brRW := models.GetBRReaderWriter()
+	strategy, err := brRW.GetBackupStrategy(ctx, record.ClusterID)
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_STRATEGY_QUERY_FAILED, fmt.Sprintf("get backup strategy of cluster %s failed, %s", record.ClusterID, err.Error()), err)
+	}
+	if strategy.CopyTargetID == "" {
+		return nil, nil
+	}
+	target, err := brRW.GetBackupStorageTarget(ctx, strategy.CopyTargetID)
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, fmt.Sprintf("get backup storage target %s failed, %s", strategy.CopyTargetID, err.Error()), err)
+	}
+	return startBackupCopy(ctx, record, target)
+}
+
+// startBackupCopy
+// @Description: create a Copying location of the backup on the target and copy backup files asynchronously,
+// a previous failed copy on the target is replaced
+// @Parameter ctx
+// @Parameter record
+// @Parameter target
+// @return *backuprestore.BackupLocation
+// @return error
+func startBackupCopy(ctx context.Context, record *backuprestore.BackupRecord, target *backuprestore.BackupStorageTarget) (*backuprestore.BackupLocation, error) {
+	brRW := models.GetBRReaderWriter()
+	locations, err := brRW.QueryBackupLocations(ctx, []string{record.ID})
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_COPY_FAILED, fmt.Sprintf("query locations of backup %s failed, %s", record.ID, err.Error()), err)
+	}
+	failStaleBackupCopies(ctx, locations)
+	for _, location := range locations {
+		if location.TargetID != target.ID {
+			continue
+		}
+		if string(constants.BackupLocationFailed) != location.Status {
+			return nil, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_COPY_CONFLICT, "backup %s already has a %s copy %s on storage target %s", record.ID, location.Status, location.ID, target.ID)
+		}
+		if err = removeStorageFiles(ctx, location.StorageType, location.FilePath, location.TargetID); err != nil {
+			framework.LogWithContext(ctx).Warnf("remove files of failed copy %s failed, %s", location.ID, err.Error())
+		}
+		if err = brRW.DeleteBackupLocations(ctx, record.ID, location.ID); err != nil {
+			return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_COPY_FAILED, fmt.Sprintf("delete failed copy %s of backup %s failed, %s", location.ID, record.ID, err.Error()), err)
+		}
+	}
+
+	location, err := brRW.CreateBackupLocation(ctx, &backuprestore.BackupLocation{
+		Entity: dbModel.Entity{
+			TenantId: record.TenantId,
+			Status:   string(constants.BackupLocationCopying),
+		},
+		BackupID:    record.ID,
+		TargetID:    target.ID,
+		StorageType: target.StorageType,
+		FilePath:    fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(target.FilePath, "/"), record.ClusterID, path.Base(record.FilePath)),
+	})
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_COPY_FAILED, fmt.Sprintf("create copy of backup %s on storage target %s failed, %s", record.ID, target.ID, err.Error()), err)
+	}
+
+	copyCtx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: record.TenantId})
+	go runBackupCopy(copyCtx, record, location)
+	return location, nil
+}
+
+// runBackupCopy
+// @Description: copy files of the backup to the location, and mark the location Available or Failed
+// @Parameter ctx
+// @Parameter record
+// @Parameter location
+func runBackupCopy(ctx context.Context, record *backuprestore.BackupRecord, location *backuprestore.BackupLocation) {
+	framework.LogWithContext(ctx).Infof("begin copy backup %s to %s", record.ID, location.FilePath)
+	defer framework.LogWithContext(ctx).Infof("end copy backup %s to %s", record.ID, location.FilePath)
+
+	size, checksum, err := copyBackup(ctx, record, location)
+	location.FinishTime = time.Now()
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("copy backup %s to %s failed, %s", record.ID, location.FilePath, err.Error())
+		location.Status = string(constants.BackupLocationFailed)
+		location.Message = err.Error()
+	} else {
+		location.Status = string(constants.BackupLocationAvailable)
+		location.Size = size
+		location.Checksum = checksum
+	}
+	if err = models.GetBRReaderWriter().UpdateBackupLocation(ctx, location); err != nil {
+		framework.LogWithContext(ctx).Errorf("update copy %s of backup %s failed, %s", location.ID, record.ID, err.Error())
+	}
+}
+
+// failStaleBackupCopies
+// @Description: mark Copying locations not updated within the copy timeout as Failed,
+// the copy is lost if the service restarts while copying, and a failed copy can be replaced
+// @Parameter ctx
+// @Parameter locations
+func failStaleBackupCopies(ctx context.Context, locations []*backuprestore.BackupLocation) {
+	timeout := time.Duration(platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyBackupCopyTimeout, constants.DefaultBackupCopyTimeout)) * time.Hour
+	if timeout == 0 {
+		return
+	}
+	for _, location := range locations {
+		if string(constants.BackupLocationCopying) != location.Status || time.Since(location.UpdatedAt) < timeout {
+			continue
+		}
+		framework.LogWithContext(ctx).Warnf("copy %s of backup %s is not finished in %s, mark it failed", location.ID, location.BackupID, timeout)
+		location.Status = string(constants.BackupLocationFailed)
+		location.Message = fmt.Sprintf("copy is not finished in %s, it may be interrupted by restart of service", timeout)
+		location.FinishTime = time.Now()
+		if err := models.GetBRReaderWriter().UpdateBackupLocation(ctx, location); err != nil {
+			framework.LogWithContext(ctx).Errorf("update copy %s of backup %s failed, %s", location.ID, location.BackupID, err.Error())
+		}
+	}
+}
+
+func copyBackup(ctx context.Context, record *backuprestore.BackupRecord, location *backuprestore.BackupLocation) (uint64, string, error) {
+	src, err := newBackupFileStore(ctx, record.StorageType, record.FilePath, record.StorageTargetID)
+	if err != nil {
+		return 0, "", err
+	}
+	dst, err := newBackupFileStore(ctx, location.StorageType, location.FilePath, location.TargetID)
+	if err != nil {
+		return 0, "", err
+	}
+	return copyBackupFiles(ctx, src, dst)
+}
+
+// restoreFromLocation
+// @Description: get a copy of the backup record pointing to an Available location of the backup
+// @Parameter ctx
+// @Parameter record
+// @Parameter locationID
+// @return *backuprestore.BackupRecord
+// @return error
+func restoreFromLocation(ctx context.Context, record *backuprestore.BackupRecord, locationID string) (*backuprestore.BackupRecord, error) {
+	location, err := models.GetBRReaderWriter().GetBackupLocation(ctx, locationID)
+	if err != nil || location.BackupID != record.ID {
+		framework.LogWithContext(ctx).Errorf("get location %s of backup %s failed, %v", locationID, record.ID, err)
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_LOCATION_NOT_FOUND, "location %s of backup %s not found", locationID, record.ID)
+	}
+	if string(constants.BackupLocationAvailable) != location.Status {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "location %s of backup %s is %s, only available locations can be restored from", locationID, record.ID, location.Status)
+	}
+	copied := *record
+	copied.StorageType = location.StorageType
+	copied.FilePath = location.FilePath
+	copied.StorageTargetID = location.TargetID
+	return &copied, nil
+}
+
+// removeBackupLocations
+// @Description: remove files and locations of all copies of the backup
+// @Parameter ctx
+// @Parameter record
+// @return error
+func removeBackupLocations(ctx context.Context, record *backuprestore.BackupRecord) error {
+	brRW := models.GetBRReaderWriter()
+	locations, err := brRW.QueryBackupLocations(ctx, []string{record.ID})
+	if err != nil {
+		return err
+	}
+	if len(locations) == 0 {
+		return nil
+	}
+	for _, location := range locations {
+		if err = removeStorageFiles(ctx, location.StorageType, location.FilePath, location.TargetID); err != nil {
+			framework.LogWithContext(ctx).Warnf("remove files of copy %s failed, %s", location.ID, err.Error())
+		}
+	}
+	return brRW.DeleteBackupLocations(ctx, record.ID, "")
+}
+
+func convertBackupLocation(location *backuprestore.BackupLocation) structs.BackupLocation {
+	return structs.BackupLocation{
+		ID:          location.ID,
+		TargetID:    location.TargetID,
+		StorageType: location.StorageType,
+		FilePath:    location.FilePath,
+		Size:        location.Size,
+		Checksum:    location.Checksum,
+		Status:      location.Status,
+		Message:     location.Message,
+		CreateTime:  location.CreatedAt,
+		FinishTime:  location.FinishTime,
+	}
+}
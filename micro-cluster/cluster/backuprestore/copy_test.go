@@ -0,0 +1,358 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeBackupFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		filePath := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+	}
+}
+
+// corruptFileStore writes files with an extra byte, to simulate a corrupted copy
+type corruptFileStore struct {
+	nfsFileStore
+}
+
+func (store *corruptFileStore) writeFile(ctx context.Context, file backupFile, reader io.Reader) error {
+	return store.nfsFileStore.writeFile(ctx, file, io.MultiReader(reader, strings.NewReader("x")))
+}
+
+func Test_copyBackupFiles(t *testing.T) {
+	src := t.TempDir()
+	writeBackupFiles(t, src, map[string]string{
+		"backupmeta":    "meta",
+		"1/sst-1.sst":   "sst content 1",
+		"1/sst-2.sst":   "sst content 2",
+		"2/3/sst-3.sst": "sst content 3",
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "copy")
+		size, checksum, err := copyBackupFiles(context.TODO(), &nfsFileStore{root: src}, &nfsFileStore{root: dst})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(43), size)
+		assert.NotEmpty(t, checksum)
+		content, err := ioutil.ReadFile(filepath.Join(dst, "2/3/sst-3.sst"))
+		assert.NoError(t, err)
+		assert.Equal(t, "sst content 3", string(content))
+
+		// the copy has the same checksum as the source
+		_, copyChecksum, err := copyBackupFiles(context.TODO(), &nfsFileStore{root: dst}, &nfsFileStore{root: t.TempDir()})
+		assert.NoError(t, err)
+		assert.Equal(t, checksum, copyChecksum)
+	})
+	t.Run("checksum mismatch", func(t *testing.T) {
+		_, _, err := copyBackupFiles(context.TODO(), &nfsFileStore{root: src}, &corruptFileStore{nfsFileStore{root: t.TempDir()}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "mismatch")
+	})
+	t.Run("no files", func(t *testing.T) {
+		_, _, err := copyBackupFiles(context.TODO(), &nfsFileStore{root: t.TempDir()}, &nfsFileStore{root: t.TempDir()})
+		assert.Error(t, err)
+	})
+	t.Run("source not found", func(t *testing.T) {
+		_, _, err := copyBackupFiles(context.TODO(), &nfsFileStore{root: filepath.Join(src, "not-exist")}, &nfsFileStore{root: t.TempDir()})
+		assert.Error(t, err)
+	})
+}
+
+func Test_newBackupFileStore(t *testing.T) {
+	store, err := newBackupFileStore(context.TODO(), string(constants.StorageTypeNFS), "/tmp/backup", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/backup", store.(*nfsFileStore).root)
+
+	_, err = newBackupFileStore(context.TODO(), string(constants.StorageTypeS3), "bucket", "")
+	assert.Error(t, err)
+}
+
+func Test_runBackupCopy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	src := t.TempDir()
+	writeBackupFiles(t, src, map[string]string{"backupmeta": "meta", "1/sst-1.sst": "sst"})
+	record := &backuprestore.BackupRecord{
+		Entity:      common.Entity{ID: "backup-xxx", Status: string(constants.ClusterBackupFinished)},
+		ClusterID:   "cls-xxx",
+		StorageType: string(constants.StorageTypeNFS),
+		FilePath:    src,
+	}
+
+	t.Run("available", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().UpdateBackupLocation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, location *backuprestore.BackupLocation) error {
+			assert.Equal(t, string(constants.BackupLocationAvailable), location.Status)
+			assert.Equal(t, uint64(7), location.Size)
+			assert.NotEmpty(t, location.Checksum)
+			assert.False(t, location.FinishTime.IsZero())
+			return nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		dst := filepath.Join(t.TempDir(), "cls-xxx", "backup")
+		runBackupCopy(context.TODO(), record, &backuprestore.BackupLocation{
+			Entity:      common.Entity{ID: "location-xxx", Status: string(constants.BackupLocationCopying)},
+			BackupID:    record.ID,
+			TargetID:    "target-xxx",
+			StorageType: string(constants.StorageTypeNFS),
+			FilePath:    dst,
+		})
+		content, err := ioutil.ReadFile(filepath.Join(dst, "1/sst-1.sst"))
+		assert.NoError(t, err)
+		assert.Equal(t, "sst", string(content))
+	})
+	t.Run("failed", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().UpdateBackupLocation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, location *backuprestore.BackupLocation) error {
+			assert.Equal(t, string(constants.BackupLocationFailed), location.Status)
+			assert.NotEmpty(t, location.Message)
+			return nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		missing := *record
+		missing.FilePath = filepath.Join(src, "not-exist")
+		runBackupCopy(context.TODO(), &missing, &backuprestore.BackupLocation{
+			Entity:      common.Entity{ID: "location-xxx", Status: string(constants.BackupLocationCopying)},
+			BackupID:    record.ID,
+			TargetID:    "target-xxx",
+			StorageType: string(constants.StorageTypeNFS),
+			FilePath:    t.TempDir(),
+		})
+	})
+}
+
+func Test_failStaleBackupCopies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+
+	newLocations := func() []*backuprestore.BackupLocation {
+		return []*backuprestore.BackupLocation{
+			{Entity: common.Entity{ID: "copying", Status: string(constants.BackupLocationCopying), UpdatedAt: time.Now().Add(-time.Hour)}},
+			{Entity: common.Entity{ID: "stale", Status: string(constants.BackupLocationCopying), UpdatedAt: time.Now().Add(-3 * time.Hour)}},
+			{Entity: common.Entity{ID: "available", Status: string(constants.BackupLocationAvailable), UpdatedAt: time.Now().Add(-3 * time.Hour)}},
+		}
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupCopyTimeout).Return(&config.SystemConfig{ConfigValue: "2"}, nil)
+		brRW.EXPECT().UpdateBackupLocation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, location *backuprestore.BackupLocation) error {
+			assert.Equal(t, "stale", location.ID)
+			return nil
+		})
+		locations := newLocations()
+		failStaleBackupCopies(context.TODO(), locations)
+		assert.Equal(t, string(constants.BackupLocationCopying), locations[0].Status)
+		assert.Equal(t, string(constants.BackupLocationFailed), locations[1].Status)
+		assert.NotEmpty(t, locations[1].Message)
+		assert.Equal(t, string(constants.BackupLocationAvailable), locations[2].Status)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupCopyTimeout).Return(&config.SystemConfig{ConfigValue: "0"}, nil)
+		locations := newLocations()
+		failStaleBackupCopies(context.TODO(), locations)
+		assert.Equal(t, string(constants.BackupLocationCopying), locations[1].Status)
+	})
+}
+
+func TestBRManager_CopyBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := &backuprestore.BackupRecord{
+		Entity:      common.Entity{ID: "backup-xxx", TenantId: "tid-xxx", Status: string(constants.ClusterBackupFinished)},
+		ClusterID:   "cls-xxx",
+		StorageType: string(constants.StorageTypeNFS),
+		FilePath:    "/backup/cls-xxx/2022-01-01-00-00-00-full",
+	}
+	target := &backuprestore.BackupStorageTarget{
+		Entity:      common.Entity{ID: "target-xxx"},
+		Name:        "offsite",
+		StorageType: string(constants.StorageTypeNFS),
+		FilePath:    "/offsite/",
+	}
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupCopyTimeout).Return(&config.SystemConfig{ConfigValue: "24"}, nil).AnyTimes()
+	models.SetConfigReaderWriter(configRW)
+
+	t.Run("not finished", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		processing := *record
+		processing.Status = string(constants.ClusterBackupProcessing)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(&processing, nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CopyBackup(context.TODO(), cluster.CopyBackupReq{BackupID: "backup-xxx", TargetID: "target-xxx"})
+		assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("target not found", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-yyy").Return(nil, fmt.Errorf("not found"))
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CopyBackup(context.TODO(), cluster.CopyBackupReq{BackupID: "backup-xxx", TargetID: "target-yyy"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, err.(emerr.EMError).GetCode())
+	})
+	t.Run("conflict", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(target, nil)
+		brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"backup-xxx"}).Return([]*backuprestore.BackupLocation{
+			{Entity: common.Entity{ID: "location-xxx", Status: string(constants.BackupLocationCopying), UpdatedAt: time.Now()}, BackupID: "backup-xxx", TargetID: "target-xxx"},
+		}, nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CopyBackup(context.TODO(), cluster.CopyBackupReq{BackupID: "backup-xxx", TargetID: "target-xxx"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_COPY_CONFLICT, err.(emerr.EMError).GetCode())
+	})
+	t.Run("stale copy replaced", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(target, nil)
+		brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"backup-xxx"}).Return([]*backuprestore.BackupLocation{
+			{Entity: common.Entity{ID: "location-xxx", Status: string(constants.BackupLocationCopying), UpdatedAt: time.Now().Add(-25 * time.Hour)}, BackupID: "backup-xxx", TargetID: "target-xxx",
+				StorageType: string(constants.StorageTypeNFS), FilePath: filepath.Join(t.TempDir(), "stale")},
+		}, nil)
+		brRW.EXPECT().UpdateBackupLocation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, location *backuprestore.BackupLocation) error {
+			assert.Equal(t, string(constants.BackupLocationFailed), location.Status)
+			return nil
+		})
+		brRW.EXPECT().DeleteBackupLocations(gomock.Any(), "backup-xxx", "location-xxx").Return(nil)
+		brRW.EXPECT().CreateBackupLocation(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("create failed"))
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CopyBackup(context.TODO(), cluster.CopyBackupReq{BackupID: "backup-xxx", TargetID: "target-xxx"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_COPY_FAILED, err.(emerr.EMError).GetCode())
+	})
+	t.Run("create location failed", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(target, nil)
+		brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"backup-xxx"}).Return([]*backuprestore.BackupLocation{
+			{Entity: common.Entity{ID: "location-xxx", Status: string(constants.BackupLocationFailed)}, BackupID: "backup-xxx", TargetID: "target-xxx",
+				StorageType: string(constants.StorageTypeNFS), FilePath: filepath.Join(t.TempDir(), "failed")},
+		}, nil)
+		brRW.EXPECT().DeleteBackupLocations(gomock.Any(), "backup-xxx", "location-xxx").Return(nil)
+		brRW.EXPECT().CreateBackupLocation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, location *backuprestore.BackupLocation) (*backuprestore.BackupLocation, error) {
+			assert.Equal(t, "/offsite/cls-xxx/2022-01-01-00-00-00-full", location.FilePath)
+			assert.Equal(t, string(constants.BackupLocationCopying), location.Status)
+			assert.Equal(t, "tid-xxx", location.TenantId)
+			return nil, fmt.Errorf("create failed")
+		})
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CopyBackup(context.TODO(), cluster.CopyBackupReq{BackupID: "backup-xxx", TargetID: "target-xxx"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_COPY_FAILED, err.(emerr.EMError).GetCode())
+	})
+}
+
+func Test_replicateBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := &backuprestore.BackupRecord{Entity: common.Entity{ID: "backup-xxx"}, ClusterID: "cls-xxx"}
+	t.Run("no copy target", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStrategy(gomock.Any(), "cls-xxx").Return(&backuprestore.BackupStrategy{}, nil)
+		models.SetBRReaderWriter(brRW)
+
+		location, err := replicateBackup(context.TODO(), record)
+		assert.NoError(t, err)
+		assert.Nil(t, location)
+	})
+	t.Run("copy target not found", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStrategy(gomock.Any(), "cls-xxx").Return(&backuprestore.BackupStrategy{CopyTargetID: "target-xxx"}, nil)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(nil, fmt.Errorf("not found"))
+		models.SetBRReaderWriter(brRW)
+
+		_, err := replicateBackup(context.TODO(), record)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, err.(emerr.EMError).GetCode())
+	})
+}
+
+func Test_restoreFromLocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := &backuprestore.BackupRecord{
+		Entity:      common.Entity{ID: "backup-xxx"},
+		StorageType: string(constants.StorageTypeNFS),
+		FilePath:    "/backup/cls-xxx/full",
+	}
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().GetBackupLocation(gomock.Any(), "location-available").Return(&backuprestore.BackupLocation{
+		Entity:      common.Entity{ID: "location-available", Status: string(constants.BackupLocationAvailable)},
+		BackupID:    "backup-xxx",
+		TargetID:    "target-xxx",
+		StorageType: string(constants.StorageTypeS3),
+		FilePath:    "bucket/cls-xxx/full",
+	}, nil)
+	brRW.EXPECT().GetBackupLocation(gomock.Any(), "location-copying").Return(&backuprestore.BackupLocation{
+		Entity:   common.Entity{ID: "location-copying", Status: string(constants.BackupLocationCopying)},
+		BackupID: "backup-xxx",
+	}, nil)
+	brRW.EXPECT().GetBackupLocation(gomock.Any(), "location-other").Return(&backuprestore.BackupLocation{
+		Entity:   common.Entity{ID: "location-other", Status: string(constants.BackupLocationAvailable)},
+		BackupID: "backup-yyy",
+	}, nil)
+	models.SetBRReaderWriter(brRW)
+
+	restored, err := restoreFromLocation(context.TODO(), record, "location-available")
+	assert.NoError(t, err)
+	assert.Equal(t, string(constants.StorageTypeS3), restored.StorageType)
+	assert.Equal(t, "bucket/cls-xxx/full", restored.FilePath)
+	assert.Equal(t, "target-xxx", restored.StorageTargetID)
+	assert.Equal(t, "/backup/cls-xxx/full", record.FilePath)
+
+	_, err = restoreFromLocation(context.TODO(), record, "location-copying")
+	assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	_, err = restoreFromLocation(context.TODO(), record, "location-other")
+	assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_LOCATION_NOT_FOUND, err.(emerr.EMError).GetCode())
+}
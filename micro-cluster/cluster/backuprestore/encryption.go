@@ -0,0 +1,396 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	dbModel "github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	"github.com/pingcap/tiunimanager/util/encrypt"
+	"gorm.io/gorm"
+)
+
+// backupKeyLength length in bytes of data key of BR crypter methods
+var backupKeyLength = map[constants.BackupEncryptionMethod]int{
+	constants.BackupEncryptionAES128CTR: 16,
+	constants.BackupEncryptionAES192CTR: 24,
+	constants.BackupEncryptionAES256CTR: 32,
+}
+
+func (mgr *BRManager) RotateBackupKey(ctx context.Context, request cluster.RotateBackupKeyReq) (resp cluster.RotateBackupKeyResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin RotateBackupKey, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End RotateBackupKey")
+
+	clusterMeta, err := meta.Get(ctx, request.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+
+	brRW := models.GetBRReaderWriter()
+	method := request.Method
+	if method == "" {
+		activeKeys, err := brRW.QueryBackupKeys(ctx, request.ClusterID, string(constants.BackupKeyActive))
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("query backup keys of cluster %s failed, %s", request.ClusterID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("query backup keys of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+		}
+		if len(activeKeys) > 0 {
+			method = activeKeys[0].Method
+		} else {
+			method = getBackupEncryptionMethod(ctx)
+		}
+	}
+
+	key, err := newBackupKey(clusterMeta.Cluster.TenantId, request.ClusterID, method)
+	if err != nil {
+		return resp, err
+	}
+	key, err = brRW.RotateBackupKey(ctx, key)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("rotate backup key of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("rotate backup key of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+
+	resp.Key = convertBackupKey(key)
+	return resp, nil
+}
+
+func (mgr *BRManager) QueryBackupKeys(ctx context.Context, request cluster.QueryBackupKeysReq) (resp cluster.QueryBackupKeysResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin QueryBackupKeys, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End QueryBackupKeys")
+
+	keys, err := models.GetBRReaderWriter().QueryBackupKeys(ctx, request.ClusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query backup keys of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("query backup keys of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+
+	resp.Keys = make([]structs.BackupKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, convertBackupKey(key))
+	}
+	return resp, nil
+}
+
+func (mgr *BRManager) ExportBackupKey(ctx context.Context, request cluster.ExportBackupKeyReq) (resp cluster.ExportBackupKeyResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin ExportBackupKey, cluster: %s, key: %s", request.ClusterID, request.KeyID)
+	defer framework.LogWithContext(ctx).Infof("End ExportBackupKey")
+
+	key, err := models.GetBRReaderWriter().GetBackupKey(ctx, request.KeyID)
+	if err != nil || key.ClusterID != request.ClusterID {
+		framework.LogWithContext(ctx).Errorf("get backup key %s of cluster %s failed, %v", request.KeyID, request.ClusterID, err)
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_KEY_NOT_FOUND, "backup key %s of cluster %s not found", request.KeyID, request.ClusterID)
+	}
+
+	sealedKey, err := encrypt.AesSealWithPassphrase(string(key.DataKey), request.Passphrase)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("seal backup key %s failed, %s", request.KeyID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("seal backup key %s failed, %s", request.KeyID, err.Error()), err)
+	}
+
+	resp.Key = convertBackupKey(key)
+	resp.SealedKey = sealedKey
+	return resp, nil
+}
+
+func (mgr *BRManager) ImportBackupKey(ctx context.Context, request cluster.ImportBackupKeyReq) (resp cluster.ImportBackupKeyResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin ImportBackupKey, cluster: %s, key: %s", request.ClusterID, request.KeyID)
+	defer framework.LogWithContext(ctx).Infof("End ImportBackupKey")
+
+	clusterMeta, err := meta.Get(ctx, request.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+
+	brRW := models.GetBRReaderWriter()
+	if _, err = brRW.GetBackupKey(ctx, request.KeyID); err == nil {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_KEY_INVALID, "backup key %s already exists", request.KeyID)
+	} else if err != gorm.ErrRecordNotFound {
+		framework.LogWithContext(ctx).Errorf("get backup key %s failed, %s", request.KeyID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("get backup key %s failed, %s", request.KeyID, err.Error()), err)
+	}
+
+	dataKey, err := encrypt.AesOpenWithPassphrase(request.SealedKey, request.Passphrase)
+	if err != nil {
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_INVALID, fmt.Sprintf("open sealed backup key %s failed, %s", request.KeyID, err.Error()), err)
+	}
+	if err = checkBackupKey(request.Method, dataKey); err != nil {
+		return resp, err
+	}
+
+	// imported keys only decrypt backups taken by another TiUniManager, never encrypt new backups
+	key, err := brRW.CreateBackupKey(ctx, &backuprestore.BackupKey{
+		Entity: dbModel.Entity{
+			ID:       request.KeyID,
+			TenantId: clusterMeta.Cluster.TenantId,
+			Status:   string(constants.BackupKeyRetired),
+		},
+		ClusterID: request.ClusterID,
+		Method:    request.Method,
+		DataKey:   dbModel.Password(dataKey),
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("save backup key %s failed, %s", request.KeyID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("save backup key %s failed, %s", request.KeyID, err.Error()), err)
+	}
+
+	resp.Key = convertBackupKey(key)
+	return resp, nil
+}
+
+// getBackupEncryptionMethod
+// @Description: get BR crypter method of new backups from system config, backups are not encrypted if empty
+func getBackupEncryptionMethod(ctx context.Context) string {
+	methodConfig, err := models.GetConfigReaderWriter().GetConfig(ctx, constants.ConfigKeyBackupEncryptionMethod)
+	if err != nil {
+		framework.LogWithContext(ctx).Warnf("get conifg %s failed: %s", constants.ConfigKeyBackupEncryptionMethod, err.Error())
+		return constants.DefaultBackupEncryptionMethod
+	}
+	return strings.TrimSpace(methodConfig.ConfigValue)
+}
+
+// getClusterBackupKey
+// @Description: get active data key encrypting new backups of cluster, it is created on first use
+// @Parameter ctx
+// @Parameter tenantID
+// @Parameter clusterID
+// @return string id of the key, empty if backup encryption is disabled
+// @return error
+func getClusterBackupKey(ctx context.Context, tenantID string, clusterID string) (string, error) {
+	method := getBackupEncryptionMethod(ctx)
+	if method == "" {
+		return "", nil
+	}
+
+	brRW := models.GetBRReaderWriter()
+	activeKeys, err := brRW.QueryBackupKeys(ctx, clusterID, string(constants.BackupKeyActive))
+	if err != nil {
+		return "", errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("query backup keys of cluster %s failed, %s", clusterID, err.Error()), err)
+	}
+	if len(activeKeys) > 0 {
+		return activeKeys[0].ID, nil
+	}
+
+	key, err := newBackupKey(tenantID, clusterID, method)
+	if err != nil {
+		return "", err
+	}
+	key, err = brRW.RotateBackupKey(ctx, key)
+	if err != nil {
+		return "", errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("create backup key of cluster %s failed, %s", clusterID, err.Error()), err)
+	}
+	framework.LogWithContext(ctx).Infof("create backup key %s of cluster %s, method %s", key.ID, clusterID, method)
+	return key.ID, nil
+}
+
+// newBackupKey
+// @Description: generate random data key of BR crypter method
+func newBackupKey(tenantID string, clusterID string, method string) (*backuprestore.BackupKey, error) {
+	length, ok := backupKeyLength[constants.BackupEncryptionMethod(method)]
+	if !ok {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_KEY_INVALID, "invalid backup encryption method %s", method)
+	}
+	dataKey := make([]byte, length)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("generate backup key failed, %s", err.Error()), err)
+	}
+	return &backuprestore.BackupKey{
+		Entity: dbModel.Entity{
+			TenantId: tenantID,
+			Status:   string(constants.BackupKeyActive),
+		},
+		ClusterID: clusterID,
+		Method:    method,
+		DataKey:   dbModel.Password(hex.EncodeToString(dataKey)),
+	}, nil
+}
+
+// checkBackupKey
+// @Description: check hex data key matches BR crypter method
+func checkBackupKey(method string, dataKey string) error {
+	length, ok := backupKeyLength[constants.BackupEncryptionMethod(method)]
+	if !ok {
+		return errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_KEY_INVALID, "invalid backup encryption method %s", method)
+	}
+	if decoded, err := hex.DecodeString(dataKey); err != nil || len(decoded) != length {
+		return errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_KEY_INVALID, "backup key does not match encryption method %s", method)
+	}
+	return nil
+}
+
+func convertBackupKey(key *backuprestore.BackupKey) structs.BackupKeyInfo {
+	return structs.BackupKeyInfo{
+		ID:         key.ID,
+		ClusterID:  key.ClusterID,
+		Method:     key.Method,
+		Status:     key.Status,
+		CreateTime: key.CreatedAt,
+		UpdateTime: key.UpdatedAt,
+	}
+}
+
+// brCrypterArgs
+// @Description: unwrap data key of encrypted backup and write it into a temporary file readable only by the service,
+// so that the key never appears in command line or logs
+// @Parameter ctx
+// @Parameter keyID
+// @return []string crypter arguments of br command
+// @return func() remove the key file when br command finished
+// @return error
+func brCrypterArgs(ctx context.Context, keyID string) ([]string, func(), error) {
+	key, err := models.GetBRReaderWriter().GetBackupKey(ctx, keyID)
+	if err != nil {
+		return nil, nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_NOT_FOUND, fmt.Sprintf("get backup key %s failed, %s", keyID, err.Error()), err)
+	}
+	keyFile, err := os.CreateTemp("", "br-crypter-*.key")
+	if err != nil {
+		return nil, nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("create key file failed, %s", err.Error()), err)
+	}
+	cleanup := func() {
+		if err := os.Remove(keyFile.Name()); err != nil {
+			framework.LogWithContext(ctx).Warnf("remove key file %s failed, %s", keyFile.Name(), err.Error())
+		}
+	}
+	_, err = keyFile.WriteString(string(key.DataKey))
+	if closeErr := keyFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_KEY_FAILED, fmt.Sprintf("write key file failed, %s", err.Error()), err)
+	}
+	return []string{"--crypter.method", key.Method, "--crypter.key-file", keyFile.Name()}, cleanup, nil
+}
+
+// brFilterArgs
+// @Description: table filter arguments of br command, the whole cluster is covered if both are empty
+func brFilterArgs(dbNames []string, tableNames []string) []string {
+	args := make([]string, 0)
+	for _, db := range dbNames {
+		args = append(args, "--filter", fmt.Sprintf("%s.*", db))
+	}
+	for _, table := range tableNames {
+		args = append(args, "--filter", table)
+	}
+	return args
+}
+
+// execEncryptedBackup
+// @Description: BACKUP statement has no encryption options, so encrypted backup is taken by br command
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter record
+// @Parameter request rate limit and filter of the backup
+// @return sql.BRSQLResp size and backup ts of the backup
+// @return error
+func execEncryptedBackup(ctx context.Context, clusterMeta *meta.ClusterMeta, record *backuprestore.BackupRecord, request sql.BackupSQLReq) (resp sql.BRSQLResp, err error) {
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return resp, errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+	storageURL, err := getBRCmdStorageURL(ctx, record.StorageType, record.FilePath, record.StorageTargetID)
+	if err != nil {
+		return resp, err
+	}
+	crypterArgs, cleanup, err := brCrypterArgs(ctx, record.EncryptionKeyID)
+	if err != nil {
+		return resp, err
+	}
+	defer cleanup()
+
+	backupTS := tso.GenerateTSO(time.Now(), 0)
+	args := []string{"full", "--pd", fmt.Sprintf("%s:%d", pdAddress[0].IP, pdAddress[0].Port),
+		"--storage", storageURL, "--backupts", strconv.FormatUint(backupTS, 10)}
+	if request.RateLimitM != "" {
+		args = append(args, "--ratelimit", request.RateLimitM)
+	}
+	args = append(args, brFilterArgs(request.DbNames, request.TableNames)...)
+	args = append(args, crypterArgs...)
+
+	// progress of br command is unknown, so it is killed after a deadline instead of being cancelled when stuck
+	_, err = deployment.M.Ctl(ctx, deployment.TiUPComponentTypeBR, clusterMeta.Cluster.Version, "backup", framework.GetTiupHomePathForTidb(), args, getBRCommandTimeout(ctx))
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Destination = record.FilePath
+	resp.BackupTS = backupTS
+	if resp.Size, err = backupStorageSize(ctx, record); err != nil {
+		framework.LogWithContext(ctx).Warnf("get size of backup %s failed, %s", record.FilePath, err.Error())
+	}
+	return resp, nil
+}
+
+// execEncryptedRestore
+// @Description: RESTORE statement has no encryption options, so encrypted backup is restored by br command
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter record
+// @Parameter request rate limit and filter of the restore
+// @return error
+func execEncryptedRestore(ctx context.Context, clusterMeta *meta.ClusterMeta, record *backuprestore.BackupRecord, request sql.RestoreSQLReq) error {
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+	storageURL, err := getBRCmdStorageURL(ctx, record.StorageType, record.FilePath, record.StorageTargetID)
+	if err != nil {
+		return err
+	}
+	crypterArgs, cleanup, err := brCrypterArgs(ctx, record.EncryptionKeyID)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := []string{"full", "--pd", fmt.Sprintf("%s:%d", pdAddress[0].IP, pdAddress[0].Port), "--storage", storageURL}
+	if request.RateLimitM != "" {
+		args = append(args, "--ratelimit", request.RateLimitM)
+	}
+	args = append(args, brFilterArgs(request.DbNames, request.TableNames)...)
+	args = append(args, crypterArgs...)
+
+	// progress of br command is unknown, so it is killed after a deadline instead of being cancelled when stuck
+	_, err = deployment.M.Ctl(ctx, deployment.TiUPComponentTypeBR, clusterMeta.Cluster.Version, "restore", framework.GetTiupHomePathForTidb(), args, getBRCommandTimeout(ctx))
+	return err
+}
+
+// getBRCommandTimeout
+// @Description: timeout of br command in seconds, no timeout if 0
+func getBRCommandTimeout(ctx context.Context) int {
+	return platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyBRCommandTimeout, constants.DefaultBRCommandTimeout) * int(time.Hour/time.Second)
+}
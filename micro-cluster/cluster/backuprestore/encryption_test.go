@@ -0,0 +1,298 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	mock_deployment "github.com/pingcap/tiunimanager/test/mockdeployment"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func mockBackupKey(id string, status constants.BackupKeyStatus) *backuprestore.BackupKey {
+	return &backuprestore.BackupKey{
+		Entity: common.Entity{
+			ID:       id,
+			TenantId: "tid-xxx",
+			Status:   string(status),
+		},
+		ClusterID: "cls-test",
+		Method:    string(constants.BackupEncryptionAES128CTR),
+		DataKey:   "000102030405060708090a0b0c0d0e0f",
+	}
+}
+
+func Test_newBackupKey(t *testing.T) {
+	for method, length := range backupKeyLength {
+		key, err := newBackupKey("tid-xxx", "cls-test", string(method))
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.BackupKeyActive), key.Status)
+		assert.Equal(t, string(method), key.Method)
+		decoded, err := hex.DecodeString(string(key.DataKey))
+		assert.NoError(t, err)
+		assert.Len(t, decoded, length)
+		assert.NoError(t, checkBackupKey(key.Method, string(key.DataKey)))
+	}
+
+	_, err := newBackupKey("tid-xxx", "cls-test", "sm4-ctr")
+	assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_INVALID, err.(emerr.EMError).GetCode())
+	assert.Error(t, checkBackupKey(string(constants.BackupEncryptionAES256CTR), "000102030405060708090a0b0c0d0e0f"))
+	assert.Error(t, checkBackupKey(string(constants.BackupEncryptionAES128CTR), "not-hex"))
+}
+
+func Test_getClusterBackupKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+
+	t.Run("disabled", func(t *testing.T) {
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupEncryptionMethod).Return(&config.SystemConfig{ConfigValue: ""}, nil)
+		keyID, err := getClusterBackupKey(context.TODO(), "tid-xxx", "cls-test")
+		assert.NoError(t, err)
+		assert.Empty(t, keyID)
+	})
+	t.Run("active", func(t *testing.T) {
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupEncryptionMethod).Return(&config.SystemConfig{ConfigValue: "aes256-ctr"}, nil)
+		brRW.EXPECT().QueryBackupKeys(gomock.Any(), "cls-test", string(constants.BackupKeyActive)).Return([]*backuprestore.BackupKey{
+			mockBackupKey("key-1", constants.BackupKeyActive),
+		}, nil)
+		keyID, err := getClusterBackupKey(context.TODO(), "tid-xxx", "cls-test")
+		assert.NoError(t, err)
+		assert.Equal(t, "key-1", keyID)
+	})
+	t.Run("create", func(t *testing.T) {
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupEncryptionMethod).Return(&config.SystemConfig{ConfigValue: "aes256-ctr"}, nil)
+		brRW.EXPECT().QueryBackupKeys(gomock.Any(), "cls-test", string(constants.BackupKeyActive)).Return(nil, nil)
+		brRW.EXPECT().RotateBackupKey(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key *backuprestore.BackupKey) (*backuprestore.BackupKey, error) {
+			assert.Equal(t, "aes256-ctr", key.Method)
+			assert.Len(t, string(key.DataKey), 64)
+			key.ID = "key-2"
+			return key, nil
+		})
+		keyID, err := getClusterBackupKey(context.TODO(), "tid-xxx", "cls-test")
+		assert.NoError(t, err)
+		assert.Equal(t, "key-2", keyID)
+	})
+	t.Run("invalid method", func(t *testing.T) {
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupEncryptionMethod).Return(&config.SystemConfig{ConfigValue: "aes-cbc"}, nil)
+		brRW.EXPECT().QueryBackupKeys(gomock.Any(), "cls-test", string(constants.BackupKeyActive)).Return(nil, nil)
+		_, err := getClusterBackupKey(context.TODO(), "tid-xxx", "cls-test")
+		assert.Error(t, err)
+	})
+}
+
+func TestBRManager_RotateBackupKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(&management.Cluster{
+		Entity: common.Entity{ID: "cls-test", TenantId: "tid-xxx"},
+	}, make([]*management.ClusterInstance, 0), make([]*management.DBUser, 0), nil).AnyTimes()
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+
+	t.Run("keep method", func(t *testing.T) {
+		brRW.EXPECT().QueryBackupKeys(gomock.Any(), "cls-test", string(constants.BackupKeyActive)).Return([]*backuprestore.BackupKey{
+			mockBackupKey("key-1", constants.BackupKeyActive),
+		}, nil)
+		brRW.EXPECT().RotateBackupKey(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key *backuprestore.BackupKey) (*backuprestore.BackupKey, error) {
+			key.ID = "key-2"
+			return key, nil
+		})
+		resp, err := GetBRService().RotateBackupKey(context.TODO(), cluster.RotateBackupKeyReq{ClusterID: "cls-test"})
+		assert.NoError(t, err)
+		assert.Equal(t, "key-2", resp.Key.ID)
+		assert.Equal(t, string(constants.BackupEncryptionAES128CTR), resp.Key.Method)
+		assert.Equal(t, string(constants.BackupKeyActive), resp.Key.Status)
+	})
+	t.Run("invalid method", func(t *testing.T) {
+		_, err := GetBRService().RotateBackupKey(context.TODO(), cluster.RotateBackupKeyReq{ClusterID: "cls-test", Method: "aes-cbc"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("failed", func(t *testing.T) {
+		brRW.EXPECT().RotateBackupKey(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db error"))
+		_, err := GetBRService().RotateBackupKey(context.TODO(), cluster.RotateBackupKeyReq{ClusterID: "cls-test", Method: "aes192-ctr"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_FAILED, err.(emerr.EMError).GetCode())
+	})
+}
+
+func TestBRManager_ExportImportBackupKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-other").Return(&management.Cluster{
+		Entity: common.Entity{ID: "cls-other", TenantId: "tid-yyy"},
+	}, make([]*management.ClusterInstance, 0), make([]*management.DBUser, 0), nil).AnyTimes()
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+
+	exported := mockBackupKey("key-1", constants.BackupKeyActive)
+	brRW.EXPECT().GetBackupKey(gomock.Any(), "key-1").Return(exported, nil).Times(2)
+	exportResp, err := GetBRService().ExportBackupKey(context.TODO(), cluster.ExportBackupKeyReq{
+		ClusterID: "cls-test", KeyID: "key-1", Passphrase: "passphrase",
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, exportResp.SealedKey, string(exported.DataKey))
+
+	_, err = GetBRService().ExportBackupKey(context.TODO(), cluster.ExportBackupKeyReq{
+		ClusterID: "cls-other", KeyID: "key-1", Passphrase: "passphrase",
+	})
+	assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_NOT_FOUND, err.(emerr.EMError).GetCode())
+
+	importReq := cluster.ImportBackupKeyReq{
+		ClusterID:  "cls-other",
+		KeyID:      "key-1",
+		Method:     exportResp.Key.Method,
+		SealedKey:  exportResp.SealedKey,
+		Passphrase: "passphrase",
+	}
+	t.Run("exists", func(t *testing.T) {
+		brRW.EXPECT().GetBackupKey(gomock.Any(), "key-1").Return(exported, nil)
+		_, err := GetBRService().ImportBackupKey(context.TODO(), importReq)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("get failed", func(t *testing.T) {
+		brRW.EXPECT().GetBackupKey(gomock.Any(), "key-1").Return(nil, fmt.Errorf("database is locked"))
+		_, err := GetBRService().ImportBackupKey(context.TODO(), importReq)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_FAILED, err.(emerr.EMError).GetCode())
+	})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		brRW.EXPECT().GetBackupKey(gomock.Any(), "key-1").Return(nil, gorm.ErrRecordNotFound)
+		req := importReq
+		req.Passphrase = "wrong passphrase"
+		_, err := GetBRService().ImportBackupKey(context.TODO(), req)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_KEY_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("normal", func(t *testing.T) {
+		brRW.EXPECT().GetBackupKey(gomock.Any(), "key-1").Return(nil, gorm.ErrRecordNotFound)
+		brRW.EXPECT().CreateBackupKey(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key *backuprestore.BackupKey) (*backuprestore.BackupKey, error) {
+			assert.Equal(t, "key-1", key.ID)
+			assert.Equal(t, "cls-other", key.ClusterID)
+			assert.Equal(t, "tid-yyy", key.TenantId)
+			assert.Equal(t, string(constants.BackupKeyRetired), key.Status)
+			assert.Equal(t, exported.DataKey, key.DataKey)
+			return key, nil
+		})
+		resp, err := GetBRService().ImportBackupKey(context.TODO(), importReq)
+		assert.NoError(t, err)
+		assert.Equal(t, "key-1", resp.Key.ID)
+	})
+}
+
+func Test_execEncryptedBackupRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	brRW.EXPECT().GetBackupKey(gomock.Any(), "key-1").Return(mockBackupKey("key-1", constants.BackupKeyRetired), nil).AnyTimes()
+	mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+	deployment.M = mockTiupManager
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBRCommandTimeout).Return(&config.SystemConfig{ConfigValue: "2"}, nil).AnyTimes()
+
+	cls, instances := mockLogBackupCluster("v6.5.0")
+	clusterMeta := &meta.ClusterMeta{
+		Cluster:   cls,
+		Instances: map[string][]*management.ClusterInstance{string(constants.ComponentIDPD): instances},
+	}
+	backupPath := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(backupPath, "backupmeta"), make([]byte, 128), 0600))
+	record := &backuprestore.BackupRecord{
+		Entity:          common.Entity{ID: "backup-1"},
+		StorageType:     string(constants.StorageTypeNFS),
+		FilePath:        backupPath,
+		EncryptionKeyID: "key-1",
+	}
+
+	checkCrypterArgs := func(args []string) {
+		joined := strings.Join(args, " ")
+		assert.Contains(t, joined, "--crypter.method aes128-ctr")
+		assert.NotContains(t, joined, "000102030405060708090a0b0c0d0e0f")
+		for i, arg := range args {
+			if arg == "--crypter.key-file" {
+				content, err := os.ReadFile(args[i+1])
+				assert.NoError(t, err)
+				assert.Equal(t, "000102030405060708090a0b0c0d0e0f", string(content))
+			}
+		}
+	}
+
+	var keyFile string
+	t.Run("backup", func(t *testing.T) {
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "backup", gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, componentType deployment.TiUPComponentType, version, component, home string, args []string, timeout int) (string, error) {
+				checkCrypterArgs(args)
+				assert.Contains(t, strings.Join(args, " "), "--filter db1.* --filter db2.t1")
+				assert.Contains(t, strings.Join(args, " "), "--ratelimit 100")
+				assert.Equal(t, 7200, timeout)
+				keyFile = args[len(args)-1]
+				return "", nil
+			})
+		resp, err := execEncryptedBackup(context.TODO(), clusterMeta, record, sql.BackupSQLReq{
+			DbNames:    []string{"db1"},
+			TableNames: []string{"db2.t1"},
+			RateLimitM: "100",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(128), resp.Size)
+		assert.NotZero(t, resp.BackupTS)
+		_, err = os.Stat(keyFile)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("restore", func(t *testing.T) {
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "restore", gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, componentType deployment.TiUPComponentType, version, component, home string, args []string, timeout int) (string, error) {
+				checkCrypterArgs(args)
+				assert.Equal(t, "full", args[0])
+				assert.Equal(t, 7200, timeout)
+				return "", fmt.Errorf("restore failed")
+			})
+		err := execEncryptedRestore(context.TODO(), clusterMeta, record, sql.RestoreSQLReq{})
+		assert.Error(t, err)
+	})
+}
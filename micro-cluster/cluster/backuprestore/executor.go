@@ -28,6 +28,7 @@ import (
 	workflow "github.com/pingcap/tiunimanager/workflow2"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -96,9 +97,9 @@ func backupCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) error
 
 	backupSQLReq := sql.BackupSQLReq{
 		NodeID:         node.ID,
-		DbName:         "", //todo: support db table backup
-		TableName:      "",
-		StorageAddress: fmt.Sprintf("%s://%s", storageType, getBRStoragePath(ctx, record.StorageType, record.FilePath)),
+		DbNames:        splitFilterNames(record.Databases),
+		TableNames:     splitFilterNames(record.Tables),
+		StorageAddress: fmt.Sprintf("%s://%s", storageType, getBRStoragePath(ctx, record.StorageType, record.FilePath, record.StorageTargetID)),
 		DbConnParameter: sql.DbConnParam{
 			Username: tidbUserInfo.Name,
 			Password: tidbUserInfo.Password.Val,
@@ -112,12 +113,30 @@ func backupCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) error
 	if concurrencyConfig != nil && concurrencyConfig.ConfigValue != "" {
 		backupSQLReq.Concurrency = concurrencyConfig.ConfigValue
 	}
-	framework.LogWithContext(ctx).Infof("begin do backup sql, request[%+v]", backupSQLReq)
-	resp, err := sql.ExecBackupSQL(ctx, backupSQLReq, node.ID)
-	if err != nil {
-		framework.LogWithContext(ctx).Errorf("call backup api failed, %s", err.Error())
-		return err
+	watcher := newBRProgressWatcher(ctx, brJobBackup, node, &record, backupSQLReq.DbConnParameter)
+	var resp sql.BRSQLResp
+	if record.EncryptionKeyID != "" {
+		// progress of br command is not polled, it is not shown by SHOW BACKUPS, and the command is killed after BRCommandTimeout
+		framework.LogWithContext(ctx).Infof("begin do encrypted backup by br command, key %s", record.EncryptionKeyID)
+		node.Record(fmt.Sprintf("backup is encrypted by key %s ", record.EncryptionKeyID))
+		resp, err = execEncryptedBackup(ctx, &meta, &record, backupSQLReq)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("call backup command failed, %s", err.Error())
+			return err
+		}
+	} else {
+		framework.LogWithContext(ctx).Infof("begin do backup sql, request[%+v]", backupSQLReq)
+		watcher.start()
+		resp, err = sql.ExecBackupSQL(ctx, backupSQLReq, node.ID)
+		if stuck := watcher.stop(); err != nil {
+			framework.LogWithContext(ctx).Errorf("call backup api failed, %s", err.Error())
+			if stuck {
+				return fmt.Errorf("backup made no progress for %s and was cancelled, %s", watcher.stuckTimeout, err.Error())
+			}
+			return err
+		}
 	}
+	watcher.publish(brProgress{Progress: 100, ProcessedSize: resp.Size, EstimatedEndTime: time.Now()})
 
 	err = ctx.SetData(contextBRInfoKey, &resp)
 	if err != nil {
@@ -155,6 +174,14 @@ func updateBackupRecord(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) e
 	}
 
 	node.Record(fmt.Sprintf("update backup record %s of cluster %s ", record.ID, meta.Cluster.ID))
+	location, err := replicateBackup(ctx, &record)
+	if err != nil {
+		// the backup itself is finished, copy it again manually
+		framework.LogWithContext(ctx).Warnf("copy backup %s of cluster %s failed, %s", record.ID, meta.Cluster.ID, err.Error())
+		node.Record(fmt.Sprintf("copy backup %s failed, %s ", record.ID, err.Error()))
+	} else if location != nil {
+		node.Record(fmt.Sprintf("start copying backup %s to %s ", record.ID, location.FilePath))
+	}
 	return nil
 }
 
@@ -164,6 +191,7 @@ func restoreFromSrcCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext
 
 	var record backuprestore.BackupRecord
 	var meta meta.ClusterMeta
+	var filter restoreFilter
 	err := ctx.GetData(contextBackupRecordKey, &record)
 	if err != nil {
 		return err
@@ -172,6 +200,10 @@ func restoreFromSrcCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext
 	if err != nil {
 		return err
 	}
+	err = ctx.GetData(contextRestoreFilterKey, &filter)
+	if err != nil {
+		return err
+	}
 
 	tidbServers := meta.GetClusterConnectAddresses()
 	if len(tidbServers) == 0 {
@@ -207,17 +239,62 @@ func restoreFromSrcCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext
 		framework.LogWithContext(ctx).Warnf("get conifg %s failed: %s", constants.ConfigKeyRestoreConcurrency, err.Error())
 	}
 
+	dbConnParam := sql.DbConnParam{
+		Username: tidbUserInfo.Name,
+		Password: tidbUserInfo.Password.Val,
+		IP:       tidbServerHost,
+		Port:     strconv.Itoa(tidbServerPort),
+	}
 	restoreSQLReq := sql.RestoreSQLReq{
-		NodeID:         node.ID,
-		DbName:         "", //todo: support db table backup
-		TableName:      "",
-		StorageAddress: fmt.Sprintf("%s://%s", storageType, getBRStoragePath(ctx, record.StorageType, record.FilePath)),
-		DbConnParameter: sql.DbConnParam{
-			Username: tidbUserInfo.Name,
-			Password: tidbUserInfo.Password.Val,
-			IP:       tidbServerHost,
-			Port:     strconv.Itoa(tidbServerPort),
-		},
+		NodeID:          node.ID,
+		DbNames:         filter.Filter.Databases,
+		TableNames:      filter.Filter.Tables,
+		StorageAddress:  fmt.Sprintf("%s://%s", storageType, getBRStoragePath(ctx, record.StorageType, record.FilePath, record.StorageTargetID)),
+		DbConnParameter: dbConnParam,
+	}
+
+	// BR always restores tables into their original database, so existing tables of the source database which would be
+	// overwritten are parked in a temporary database during the restore, the restored tables are renamed into target database,
+	// and the parked tables are moved back afterwards. The parked tables are not accessible until the restore is finished
+	sourceDatabase := singleDatabaseOfFilter(filter.Filter)
+	parkingDatabase := fmt.Sprintf("tiunimanager_restore_%s", node.ID)
+	tableNames := make([]string, 0)
+	for _, table := range filter.Filter.Tables {
+		tableNames = append(tableNames, strings.SplitN(table, ".", 2)[1])
+	}
+	sourceDatabaseExists := false
+	parked := false
+	if filter.TargetDatabase != "" {
+		sourceDatabaseExists, err = sql.DatabaseExists(ctx, dbConnParam, sourceDatabase)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("check database %s of cluster %s failed, %s", sourceDatabase, meta.Cluster.ID, err.Error())
+			return err
+		}
+		if sourceDatabaseExists {
+			// all tables are parked when restoring the whole database
+			existing := make([]string, 0)
+			if len(tableNames) > 0 {
+				existing, err = sql.ExistingTables(ctx, dbConnParam, sourceDatabase, tableNames)
+				if err != nil {
+					framework.LogWithContext(ctx).Errorf("check tables of database %s of cluster %s failed, %s", sourceDatabase, meta.Cluster.ID, err.Error())
+					return err
+				}
+			}
+			if len(tableNames) == 0 || len(existing) > 0 {
+				err = sql.RenameDatabaseTables(ctx, sql.RenameDatabaseTablesReq{
+					DbConnParameter: dbConnParam,
+					SourceDatabase:  sourceDatabase,
+					TargetDatabase:  parkingDatabase,
+					TableNames:      existing,
+				})
+				if err != nil {
+					framework.LogWithContext(ctx).Errorf("park tables of database %s into %s failed, %s", sourceDatabase, parkingDatabase, err.Error())
+					return err
+				}
+				parked = true
+				node.Record(fmt.Sprintf("park existing tables of database %s into %s ", sourceDatabase, parkingDatabase))
+			}
+		}
 	}
 
 	if rateLimitConfig != nil && rateLimitConfig.ConfigValue != "" {
@@ -226,10 +303,41 @@ func restoreFromSrcCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext
 	if concurrencyConfig != nil && concurrencyConfig.ConfigValue != "" {
 		restoreSQLReq.Concurrency = concurrencyConfig.ConfigValue
 	}
-	framework.LogWithContext(ctx).Infof("begin do backup sql, request[%+v]", restoreSQLReq)
-	_, err = sql.ExecRestoreSQL(ctx, restoreSQLReq, node.ID)
+	err = restoreIntoSourceDatabase(ctx, node, &meta, &record, restoreSQLReq)
+	if err == nil && filter.TargetDatabase != "" {
+		err = sql.RenameDatabaseTables(ctx, sql.RenameDatabaseTablesReq{
+			DbConnParameter: dbConnParam,
+			SourceDatabase:  sourceDatabase,
+			TargetDatabase:  filter.TargetDatabase,
+			TableNames:      tableNames,
+			DropSource:      !sourceDatabaseExists,
+		})
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("rename restored database %s to %s failed, %s", sourceDatabase, filter.TargetDatabase, err.Error())
+		} else {
+			node.Record(fmt.Sprintf("rename restored database %s to %s ", sourceDatabase, filter.TargetDatabase))
+		}
+	}
+	if parked {
+		// tables left by a failed restore conflict with parked ones, which are kept in parking database then
+		unparkErr := sql.RenameDatabaseTables(ctx, sql.RenameDatabaseTablesReq{
+			DbConnParameter: dbConnParam,
+			SourceDatabase:  parkingDatabase,
+			TargetDatabase:  sourceDatabase,
+			DropSource:      true,
+		})
+		if unparkErr != nil {
+			framework.LogWithContext(ctx).Errorf("move parked tables of %s back to %s failed, %s", parkingDatabase, sourceDatabase, unparkErr.Error())
+			if err == nil {
+				err = fmt.Errorf("move parked tables of %s back to %s failed, %s", parkingDatabase, sourceDatabase, unparkErr.Error())
+			} else {
+				err = fmt.Errorf("%s, and existing tables of %s are kept in %s", err.Error(), sourceDatabase, parkingDatabase)
+			}
+		} else {
+			node.Record(fmt.Sprintf("move parked tables of %s back to %s ", parkingDatabase, sourceDatabase))
+		}
+	}
 	if err != nil {
-		framework.LogWithContext(ctx).Errorf("call backup api failed, %s", err.Error())
 		return err
 	}
 
@@ -237,6 +345,35 @@ func restoreFromSrcCluster(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext
 	return nil
 }
 
+// restoreIntoSourceDatabase
+// @Description: restore backup by RESTORE statement, or by br command if it is encrypted
+func restoreIntoSourceDatabase(ctx *workflow.FlowContext, node *wfModel.WorkFlowNode, clusterMeta *meta.ClusterMeta, record *backuprestore.BackupRecord,
+	restoreSQLReq sql.RestoreSQLReq) (err error) {
+	watcher := newBRProgressWatcher(ctx, brJobRestore, node, record, restoreSQLReq.DbConnParameter)
+	if record.EncryptionKeyID != "" {
+		// progress of br command is not polled, it is not shown by SHOW RESTORES, and the command is killed after BRCommandTimeout
+		framework.LogWithContext(ctx).Infof("begin do encrypted restore by br command, key %s", record.EncryptionKeyID)
+		node.Record(fmt.Sprintf("backup is encrypted by key %s ", record.EncryptionKeyID))
+		if err = execEncryptedRestore(ctx, clusterMeta, record, restoreSQLReq); err != nil {
+			framework.LogWithContext(ctx).Errorf("call restore command failed, %s", err.Error())
+			return err
+		}
+	} else {
+		framework.LogWithContext(ctx).Infof("begin do backup sql, request[%+v]", restoreSQLReq)
+		watcher.start()
+		_, err = sql.ExecRestoreSQL(ctx, restoreSQLReq, node.ID)
+		if stuck := watcher.stop(); err != nil {
+			framework.LogWithContext(ctx).Errorf("call backup api failed, %s", err.Error())
+			if stuck {
+				return fmt.Errorf("restore made no progress for %s and was cancelled, %s", watcher.stuckTimeout, err.Error())
+			}
+			return err
+		}
+	}
+	watcher.publish(brProgress{Progress: 100, ProcessedSize: record.Size, EstimatedEndTime: time.Now()})
+	return nil
+}
+
 func defaultEnd(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) error {
 	framework.LogWithContext(ctx).Info("begin defaultEnd")
 	defer framework.LogWithContext(ctx).Info("end defaultEnd")
@@ -293,26 +430,22 @@ func restoreFail(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) error {
 	return defaultEnd(node, ctx)
 }
 
-func getBRStoragePath(ctx context.Context, storageType string, filePath string) string {
-	configRW := models.GetConfigReaderWriter()
+// getBRStoragePath
+// @Description: get storage path for br, with endpoint and credentials of the storage target if it is s3
+// @Parameter ctx
+// @Parameter storageType
+// @Parameter filePath
+// @Parameter storageTargetID the backup storage of system config if empty
+// @return string
+func getBRStoragePath(ctx context.Context, storageType string, filePath string, storageTargetID string) string {
 	if string(constants.StorageTypeS3) == storageType {
-		endpointConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3Endpoint)
-		if err != nil {
-			framework.LogWithContext(ctx).Errorf("get conifg %s failed: %s", constants.ConfigKeyBackupS3Endpoint, err.Error())
-			return ""
-		}
-		accessKeyConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3AccessKey)
-		if err != nil {
-			framework.LogWithContext(ctx).Errorf("get conifg %s failed: %s", constants.ConfigKeyBackupS3AccessKey, err.Error())
-			return ""
-		}
-		secretAccessKeyConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3SecretAccessKey)
+		s3Config, err := getS3Config(ctx, storageTargetID)
 		if err != nil {
-			framework.LogWithContext(ctx).Errorf("get conifg %s failed: %s", constants.ConfigKeyBackupS3SecretAccessKey, err.Error())
+			framework.LogWithContext(ctx).Errorf("get s3 config failed: %s", err.Error())
 			return ""
 		}
 		return fmt.Sprintf("%s/?access-key=%s\\&secret-access-key=%s\\&endpoint=%s\\&force-path-style=true",
-			filePath, accessKeyConfig.ConfigValue, secretAccessKeyConfig.ConfigValue, endpointConfig.ConfigValue)
+			filePath, s3Config.AccessKey, s3Config.SecretAccessKey, s3Config.Endpoint)
 	} else {
 		return filePath
 	}
@@ -101,6 +101,7 @@ func TestExecutor_updateBackupRecord(t *testing.T) {
 
 	brRW := mockbr.NewMockReaderWriter(ctrl)
 	brRW.EXPECT().UpdateBackupRecord(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	brRW.EXPECT().GetBackupStrategy(gomock.Any(), "cls-xxxx").Return(&backuprestore.BackupStrategy{}, nil)
 	models.SetBRReaderWriter(brRW)
 
 	flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
@@ -108,6 +109,7 @@ func TestExecutor_updateBackupRecord(t *testing.T) {
 		Entity: common.Entity{
 			ID: "record-xxxx",
 		},
+		ClusterID: "cls-xxxx",
 	})
 	flowContext.SetData(contextClusterMetaKey, &meta.ClusterMeta{
 		Cluster: &management.Cluster{
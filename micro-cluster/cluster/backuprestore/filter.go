@@ -0,0 +1,126 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/tiunimanager/common/structs"
+)
+
+// restoreFilter databases or tables to restore from a backup, and the database they are renamed to
+type restoreFilter struct {
+	Filter         structs.BackupFilter
+	TargetDatabase string
+}
+
+// validateBackupFilter
+// @Description: check names of databases and tables in filter
+// @Parameter filter
+// @return error
+func validateBackupFilter(filter structs.BackupFilter) error {
+	if len(filter.Databases) > 0 && len(filter.Tables) > 0 {
+		return fmt.Errorf("databases and tables of filter can not be specified at the same time")
+	}
+	for _, database := range filter.Databases {
+		if !validSchemaName(database) {
+			return fmt.Errorf("invalid database name %s", database)
+		}
+	}
+	for _, table := range filter.Tables {
+		names := strings.SplitN(table, ".", 2)
+		if len(names) != 2 || !validSchemaName(names[0]) || !validSchemaName(names[1]) {
+			return fmt.Errorf("invalid table name %s, should be in format of db.table", table)
+		}
+	}
+	return nil
+}
+
+func validSchemaName(name string) bool {
+	return strings.TrimSpace(name) != "" && !strings.Contains(name, "`")
+}
+
+// checkRestoreFilter
+// @Description: check databases or tables to restore are covered by the backup
+// @Parameter backup filter of backup record
+// @Parameter restore filter of restore request
+// @return error
+func checkRestoreFilter(backup structs.BackupFilter, restore structs.BackupFilter) error {
+	if err := validateBackupFilter(restore); err != nil {
+		return err
+	}
+	if isFilterEmpty(backup) || isFilterEmpty(restore) {
+		return nil
+	}
+
+	databases := make(map[string]bool)
+	for _, database := range backup.Databases {
+		databases[database] = true
+	}
+	tables := make(map[string]bool)
+	for _, table := range backup.Tables {
+		tables[table] = true
+	}
+	if len(restore.Databases) > 0 && len(backup.Tables) > 0 {
+		return fmt.Errorf("databases can not be restored from a backup of tables")
+	}
+	for _, database := range restore.Databases {
+		if !databases[database] {
+			return fmt.Errorf("database %s is not in the backup", database)
+		}
+	}
+	for _, table := range restore.Tables {
+		if !tables[table] && !databases[strings.SplitN(table, ".", 2)[0]] {
+			return fmt.Errorf("table %s is not in the backup", table)
+		}
+	}
+	return nil
+}
+
+// singleDatabaseOfFilter
+// @Description: get the only database that filter covers
+// @Parameter filter
+// @return string empty if filter covers more than one database or the whole cluster
+func singleDatabaseOfFilter(filter structs.BackupFilter) string {
+	if len(filter.Databases) == 1 {
+		return filter.Databases[0]
+	}
+	database := ""
+	for _, table := range filter.Tables {
+		current := strings.SplitN(table, ".", 2)[0]
+		if database != "" && database != current {
+			return ""
+		}
+		database = current
+	}
+	return database
+}
+
+func isFilterEmpty(filter structs.BackupFilter) bool {
+	return len(filter.Databases) == 0 && len(filter.Tables) == 0
+}
+
+func joinFilterNames(names []string) string {
+	return strings.Join(names, ",")
+}
+
+func splitFilterNames(names string) []string {
+	if names == "" {
+		return make([]string, 0)
+	}
+	return strings.Split(names, ",")
+}
@@ -0,0 +1,104 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateBackupFilter(t *testing.T) {
+	assert.NoError(t, validateBackupFilter(structs.BackupFilter{}))
+	assert.NoError(t, validateBackupFilter(structs.BackupFilter{Databases: []string{"db1", "db2"}}))
+	assert.NoError(t, validateBackupFilter(structs.BackupFilter{Tables: []string{"db1.t1", "db2.t2"}}))
+	assert.Error(t, validateBackupFilter(structs.BackupFilter{Databases: []string{"db1"}, Tables: []string{"db2.t2"}}))
+	assert.Error(t, validateBackupFilter(structs.BackupFilter{Databases: []string{"db`1"}}))
+	assert.Error(t, validateBackupFilter(structs.BackupFilter{Databases: []string{" "}}))
+	assert.Error(t, validateBackupFilter(structs.BackupFilter{Tables: []string{"t1"}}))
+	assert.Error(t, validateBackupFilter(structs.BackupFilter{Tables: []string{"db1."}}))
+}
+
+func Test_checkRestoreFilter(t *testing.T) {
+	full := structs.BackupFilter{}
+	databases := structs.BackupFilter{Databases: []string{"db1", "db2"}}
+	tables := structs.BackupFilter{Tables: []string{"db1.t1", "db2.t2"}}
+
+	assert.NoError(t, checkRestoreFilter(full, structs.BackupFilter{Tables: []string{"db3.t3"}}))
+	assert.NoError(t, checkRestoreFilter(databases, structs.BackupFilter{}))
+	assert.NoError(t, checkRestoreFilter(databases, structs.BackupFilter{Databases: []string{"db2"}}))
+	assert.NoError(t, checkRestoreFilter(databases, structs.BackupFilter{Tables: []string{"db1.t3"}}))
+	assert.Error(t, checkRestoreFilter(databases, structs.BackupFilter{Databases: []string{"db3"}}))
+	assert.Error(t, checkRestoreFilter(databases, structs.BackupFilter{Tables: []string{"db3.t3"}}))
+	assert.NoError(t, checkRestoreFilter(tables, structs.BackupFilter{Tables: []string{"db2.t2"}}))
+	assert.Error(t, checkRestoreFilter(tables, structs.BackupFilter{Tables: []string{"db2.t3"}}))
+	assert.Error(t, checkRestoreFilter(tables, structs.BackupFilter{Databases: []string{"db1"}}))
+	assert.Error(t, checkRestoreFilter(full, structs.BackupFilter{Tables: []string{"t3"}}))
+}
+
+func Test_singleDatabaseOfFilter(t *testing.T) {
+	assert.Equal(t, "", singleDatabaseOfFilter(structs.BackupFilter{}))
+	assert.Equal(t, "db1", singleDatabaseOfFilter(structs.BackupFilter{Databases: []string{"db1"}}))
+	assert.Equal(t, "", singleDatabaseOfFilter(structs.BackupFilter{Databases: []string{"db1", "db2"}}))
+	assert.Equal(t, "db1", singleDatabaseOfFilter(structs.BackupFilter{Tables: []string{"db1.t1", "db1.t2"}}))
+	assert.Equal(t, "", singleDatabaseOfFilter(structs.BackupFilter{Tables: []string{"db1.t1", "db2.t2"}}))
+}
+
+func Test_splitFilterNames(t *testing.T) {
+	assert.Equal(t, 0, len(splitFilterNames("")))
+	assert.Equal(t, []string{"db1", "db2"}, splitFilterNames(joinFilterNames([]string{"db1", "db2"})))
+}
+
+func TestBRManager_restoreFilterPreCheck(t *testing.T) {
+	mgr := &BRManager{}
+	record := &backuprestore.BackupRecord{Databases: "db1,db2"}
+
+	t.Run("default to backup filter", func(t *testing.T) {
+		filter, err := mgr.restoreFilterPreCheck(cluster.RestoreExistClusterReq{}, record)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"db1", "db2"}, filter.Filter.Databases)
+	})
+	t.Run("rename", func(t *testing.T) {
+		filter, err := mgr.restoreFilterPreCheck(cluster.RestoreExistClusterReq{
+			Filter:         structs.BackupFilter{Tables: []string{"db1.t1"}},
+			TargetDatabase: "db1_restored",
+		}, record)
+		assert.NoError(t, err)
+		assert.Equal(t, "db1_restored", filter.TargetDatabase)
+	})
+	t.Run("rename multiple databases", func(t *testing.T) {
+		_, err := mgr.restoreFilterPreCheck(cluster.RestoreExistClusterReq{
+			TargetDatabase: "db_restored",
+		}, record)
+		assert.Error(t, err)
+	})
+	t.Run("rename to itself", func(t *testing.T) {
+		_, err := mgr.restoreFilterPreCheck(cluster.RestoreExistClusterReq{
+			Filter:         structs.BackupFilter{Databases: []string{"db1"}},
+			TargetDatabase: "db1",
+		}, record)
+		assert.Error(t, err)
+	})
+	t.Run("not in backup", func(t *testing.T) {
+		_, err := mgr.restoreFilterPreCheck(cluster.RestoreExistClusterReq{
+			Filter: structs.BackupFilter{Databases: []string{"db3"}},
+		}, record)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,402 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	brpb "github.com/pingcap/kvproto/pkg/brpb"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	dbModel "github.com/pingcap/tiunimanager/models/common"
+)
+
+// backupMetaFileName name of the file BR writes backup meta into, in the root of the backup path
+const backupMetaFileName = "backupmeta"
+
+// maxMetaFileDepth max depth of the multi-level index of backupmeta v2
+const maxMetaFileDepth = 8
+
+// brSystemSchemas schemas never restored as user data
+var brSystemSchemas = map[string]bool{
+	"mysql":              true,
+	"information_schema": true,
+	"performance_schema": true,
+	"metrics_schema":     true,
+	"inspection_schema":  true,
+}
+
+// brTemporarySchemaPrefix prefix of schemas BR backs up system tables into
+const brTemporarySchemaPrefix = "__tidb_br_temporary_"
+
+// backupMetaInfo catalog information read from backupmeta
+type backupMetaInfo struct {
+	BackupType     string
+	BackupTso      uint64
+	Size           uint64
+	ClusterVersion string
+	BRVersion      string
+	Databases      []string
+	Tables         []string // in format of db.table
+	Full           bool     // system tables are only backed up by full backups
+}
+
+func (mgr *BRManager) ImportBackup(ctx context.Context, request cluster.ImportBackupReq) (resp cluster.ImportBackupResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin ImportBackup, cluster: %s, storage: %s", request.ClusterID, request.StorageURL)
+	defer framework.LogWithContext(ctx).Infof("End ImportBackup")
+
+	storageType, filePath, err := parseBackupStorageURL(request.StorageURL)
+	if err != nil {
+		return resp, errors.WrapError(errors.TIUNIMANAGER_PARAMETER_INVALID, err.Error(), err)
+	}
+	withCredentials := request.AccessKey != "" || request.SecretAccessKey != "" || request.Endpoint != ""
+	if withCredentials && string(constants.StorageTypeS3) != storageType {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "endpoint and credentials are only for s3 storage")
+	}
+
+	tenantID := framework.GetTenantIDFromContext(ctx)
+	if request.ClusterID != "" {
+		clusterMeta, err := meta.Get(ctx, request.ClusterID)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+		}
+		tenantID = clusterMeta.Cluster.TenantId
+	}
+
+	var store backupFileStore
+	var s3Cfg *s3Config
+	if withCredentials {
+		s3Cfg = &s3Config{Endpoint: request.Endpoint, AccessKey: request.AccessKey, SecretAccessKey: request.SecretAccessKey}
+		store, err = newS3FileStore(ctx, s3Cfg, filePath)
+	} else {
+		store, err = newBackupFileStore(ctx, storageType, filePath, "")
+	}
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open backup storage %s failed, %s", request.StorageURL, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_IMPORT_FAILED, fmt.Sprintf("open backup storage %s failed, %s", request.StorageURL, err.Error()), err)
+	}
+	info, err := readBackupMeta(ctx, store)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("read backupmeta of %s failed, %s", request.StorageURL, err.Error())
+		return resp, err
+	}
+
+	storageTargetID := ""
+	if s3Cfg != nil {
+		storageTargetID, err = registerImportStorageTarget(ctx, tenantID, s3Cfg, strings.SplitN(filePath, "/", 2)[0])
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	databases, tables := info.backupFilter()
+	backupTime, _ := tso.ParseTS(info.BackupTso)
+	record, err := models.GetBRReaderWriter().CreateBackupRecord(ctx, &backuprestore.BackupRecord{
+		Entity: dbModel.Entity{
+			TenantId: tenantID,
+			Status:   string(constants.ClusterBackupFinished),
+		},
+		ClusterID:       request.ClusterID,
+		StorageType:     storageType,
+		BackupType:      info.BackupType,
+		BackupMethod:    string(constants.BackupMethodPhysics),
+		BackupMode:      string(constants.BackupModeImported),
+		FilePath:        filePath,
+		Size:            info.Size,
+		BackupTso:       info.BackupTso,
+		StartTime:       backupTime,
+		EndTime:         backupTime,
+		Databases:       joinFilterNames(databases),
+		Tables:          joinFilterNames(tables),
+		StorageTargetID: storageTargetID,
+		ClusterVersion:  info.ClusterVersion,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create backup record of %s failed, %s", request.StorageURL, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_IMPORT_FAILED, fmt.Sprintf("create backup record of %s failed, %s", request.StorageURL, err.Error()), err)
+	}
+	framework.LogWithContext(ctx).Infof("import backup %s of %s, backup tso %d, br version %s", record.ID, request.StorageURL, info.BackupTso, info.BRVersion)
+
+	resp.Record = *convertBackupRecord(record)
+	return resp, nil
+}
+
+// backupFilter
+// @Description: get databases and tables covered by the backup, both are empty for a full backup.
+// Whether a partial backup was taken of databases or tables is unknown from backupmeta,
+// so its tables are recorded, and its databases only if it has no table
+// @Receiver info
+// @return databases
+// @return tables
+func (info *backupMetaInfo) backupFilter() (databases []string, tables []string) {
+	if info.Full {
+		return nil, nil
+	}
+	if len(info.Tables) > 0 {
+		return nil, info.Tables
+	}
+	return info.Databases, nil
+}
+
+// parseBackupStorageURL
+// @Description: parse storage url of BR, s3://bucket/prefix for s3, local:///path or an absolute path for NFS
+// @Parameter storageURL
+// @return storageType
+// @return filePath bucket and prefix for s3
+// @return err
+func parseBackupStorageURL(storageURL string) (storageType string, filePath string, err error) {
+	switch {
+	case strings.HasPrefix(storageURL, "s3://"):
+		storageType = string(constants.StorageTypeS3)
+		filePath = strings.Trim(strings.TrimPrefix(storageURL, "s3://"), "/")
+		if !strings.Contains(filePath, "/") {
+			return "", "", fmt.Errorf("invalid storage url %s, bucket and prefix are required", storageURL)
+		}
+	case strings.HasPrefix(storageURL, "local://"):
+		storageType = string(constants.StorageTypeNFS)
+		filePath = strings.TrimPrefix(storageURL, "local://")
+	case strings.HasPrefix(storageURL, "/"):
+		storageType = string(constants.StorageTypeNFS)
+		filePath = storageURL
+	default:
+		return "", "", fmt.Errorf("invalid storage url %s, only s3:// and local:// are supported", storageURL)
+	}
+	if string(constants.StorageTypeNFS) == storageType {
+		filePath = strings.TrimSuffix(filePath, "/")
+		if !strings.HasPrefix(filePath, "/") || filePath == "" {
+			return "", "", fmt.Errorf("invalid storage url %s, absolute path is required", storageURL)
+		}
+	}
+	return storageType, filePath, nil
+}
+
+// readBackupMeta
+// @Description: read catalog information of a backup from its backupmeta, both v1 and the indexed v2 are supported
+// @Parameter ctx
+// @Parameter store
+// @return *backupMetaInfo
+// @return error
+func readBackupMeta(ctx context.Context, store backupFileStore) (*backupMetaInfo, error) {
+	content, err := readBackupFile(ctx, store, backupMetaFileName)
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_META_INVALID, fmt.Sprintf("read %s failed, %s", backupMetaFileName, err.Error()), err)
+	}
+	backupMeta := &brpb.BackupMeta{}
+	if err = backupMeta.Unmarshal(content); err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_META_INVALID, fmt.Sprintf("decode %s failed, the backup may be encrypted, %s", backupMetaFileName, err.Error()), err)
+	}
+	if backupMeta.IsRawKv {
+		return nil, errors.NewError(errors.TIUNIMANAGER_BACKUP_META_INVALID, "raw kv backups are not supported")
+	}
+	if backupMeta.EndVersion == 0 {
+		return nil, errors.NewError(errors.TIUNIMANAGER_BACKUP_META_INVALID, "backup tso not found in backupmeta")
+	}
+
+	info := &backupMetaInfo{
+		BackupType:     string(constants.BackupTypeFull),
+		BackupTso:      backupMeta.EndVersion,
+		ClusterVersion: strings.Trim(backupMeta.ClusterVersion, "\""),
+		BRVersion:      backupMeta.BrVersion,
+	}
+	if backupMeta.StartVersion != 0 && backupMeta.StartVersion < backupMeta.EndVersion {
+		info.BackupType = string(constants.BackupTypeIncrement)
+	}
+
+	dataFiles := backupMeta.Files
+	schemas := backupMeta.Schemas
+	if backupMeta.FileIndex != nil {
+		if err = walkBackupMetaFile(ctx, store, backupMeta.FileIndex, 0, func(metaFile *brpb.MetaFile) {
+			dataFiles = append(dataFiles, metaFile.DataFiles...)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if backupMeta.SchemaIndex != nil {
+		if err = walkBackupMetaFile(ctx, store, backupMeta.SchemaIndex, 0, func(metaFile *brpb.MetaFile) {
+			schemas = append(schemas, metaFile.Schemas...)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range dataFiles {
+		info.Size += file.Size_
+	}
+	if info.Databases, info.Tables, info.Full, err = backupMetaSchemas(schemas); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// walkBackupMetaFile
+// @Description: visit a meta file of backupmeta v2 and the meta files it indexes
+// @Parameter ctx
+// @Parameter store
+// @Parameter metaFile
+// @Parameter depth
+// @Parameter visit
+// @return error
+func walkBackupMetaFile(ctx context.Context, store backupFileStore, metaFile *brpb.MetaFile, depth int, visit func(metaFile *brpb.MetaFile)) error {
+	if depth > maxMetaFileDepth {
+		return errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_META_INVALID, "index of backupmeta is deeper than %d", maxMetaFileDepth)
+	}
+	visit(metaFile)
+	for _, file := range metaFile.MetaFiles {
+		content, err := readBackupFile(ctx, store, file.Name)
+		if err != nil {
+			return errors.WrapError(errors.TIUNIMANAGER_BACKUP_META_INVALID, fmt.Sprintf("read meta file %s failed, %s", file.Name, err.Error()), err)
+		}
+		if len(file.Sha256) > 0 {
+			checksum := sha256.Sum256(content)
+			if hex.EncodeToString(checksum[:]) != hex.EncodeToString(file.Sha256) {
+				return errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_META_INVALID, "checksum of meta file %s mismatch", file.Name)
+			}
+		}
+		child := &brpb.MetaFile{}
+		if err = child.Unmarshal(content); err != nil {
+			return errors.WrapError(errors.TIUNIMANAGER_BACKUP_META_INVALID, fmt.Sprintf("decode meta file %s failed, %s", file.Name, err.Error()), err)
+		}
+		if err = walkBackupMetaFile(ctx, store, child, depth+1, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupMetaSchemas
+// @Description: get sorted names of user databases and tables in schemas of backupmeta
+// @Parameter schemas
+// @return databases
+// @return tables in format of db.table
+// @return full whether system tables are in schemas
+// @return err
+func backupMetaSchemas(schemas []*brpb.Schema) (databases []string, tables []string, full bool, err error) {
+	dbNames := make(map[string]bool)
+	tableNames := make(map[string]bool)
+	for _, schema := range schemas {
+		db := struct {
+			Name struct {
+				O string `json:"O"`
+			} `json:"db_name"`
+		}{}
+		if err = json.Unmarshal(schema.Db, &db); err != nil {
+			return nil, nil, false, errors.WrapError(errors.TIUNIMANAGER_BACKUP_META_INVALID, fmt.Sprintf("decode database info failed, %s", err.Error()), err)
+		}
+		lower := strings.ToLower(db.Name.O)
+		if lower == "mysql" || strings.HasPrefix(lower, brTemporarySchemaPrefix) {
+			full = true
+		}
+		if db.Name.O == "" || brSystemSchemas[lower] || strings.HasPrefix(lower, brTemporarySchemaPrefix) {
+			continue
+		}
+		dbNames[db.Name.O] = true
+
+		// schemas of empty databases have no table
+		if len(schema.Table) == 0 {
+			continue
+		}
+		table := struct {
+			Name struct {
+				O string `json:"O"`
+			} `json:"name"`
+		}{}
+		if err = json.Unmarshal(schema.Table, &table); err != nil {
+			return nil, nil, false, errors.WrapError(errors.TIUNIMANAGER_BACKUP_META_INVALID, fmt.Sprintf("decode table info failed, %s", err.Error()), err)
+		}
+		tableNames[fmt.Sprintf("%s.%s", db.Name.O, table.Name.O)] = true
+	}
+	return sortedNames(dbNames), sortedNames(tableNames), full, nil
+}
+
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func readBackupFile(ctx context.Context, store backupFileStore, name string) ([]byte, error) {
+	reader, err := store.openFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// registerImportStorageTarget
+// @Description: get the storage target holding credentials of an imported s3 backup, it is created on first import
+// and shared by later imports with the same endpoint and access key
+// @Parameter ctx
+// @Parameter tenantID
+// @Parameter s3Cfg
+// @Parameter bucket
+// @return string id of the storage target
+// @return error
+func registerImportStorageTarget(ctx context.Context, tenantID string, s3Cfg *s3Config, bucket string) (string, error) {
+	checksum := sha256.Sum256([]byte(s3Cfg.Endpoint + "\n" + s3Cfg.AccessKey))
+	name := fmt.Sprintf("imported-%s", hex.EncodeToString(checksum[:])[:12])
+
+	brRW := models.GetBRReaderWriter()
+	targets, err := brRW.QueryBackupStorageTargets(ctx)
+	if err != nil {
+		return "", errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_QUERY_FAILED, fmt.Sprintf("query backup storage targets failed, %s", err.Error()), err)
+	}
+	for _, target := range targets {
+		if target.Name != name {
+			continue
+		}
+		if string(target.SecretAccessKey) != s3Cfg.SecretAccessKey {
+			target.SecretAccessKey = dbModel.Password(s3Cfg.SecretAccessKey)
+			if err = brRW.UpdateBackupStorageTarget(ctx, target); err != nil {
+				return "", errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_SAVE_FAILED, fmt.Sprintf("update backup storage target %s failed, %s", name, err.Error()), err)
+			}
+		}
+		return target.ID, nil
+	}
+
+	target, err := brRW.CreateBackupStorageTarget(ctx, &backuprestore.BackupStorageTarget{
+		Entity: dbModel.Entity{
+			TenantId: tenantID,
+		},
+		Name:            name,
+		StorageType:     string(constants.StorageTypeS3),
+		FilePath:        bucket,
+		Endpoint:        s3Cfg.Endpoint,
+		AccessKey:       s3Cfg.AccessKey,
+		SecretAccessKey: dbModel.Password(s3Cfg.SecretAccessKey),
+	})
+	if err != nil {
+		return "", errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_SAVE_FAILED, fmt.Sprintf("create backup storage target %s failed, %s", name, err.Error()), err)
+	}
+	return target.ID, nil
+}
@@ -0,0 +1,300 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	brpb "github.com/pingcap/kvproto/pkg/brpb"
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSchema(db string, table string) *brpb.Schema {
+	return &brpb.Schema{
+		Db:    []byte(fmt.Sprintf(`{"id":1,"db_name":{"O":"%s","L":"%s"}}`, db, db)),
+		Table: []byte(fmt.Sprintf(`{"id":2,"name":{"O":"%s","L":"%s"}}`, table, table)),
+	}
+}
+
+func writeBackupMeta(t *testing.T, root string, name string, meta interface{ Marshal() ([]byte, error) }) []byte {
+	content, err := meta.Marshal()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, name), content, 0644))
+	return content
+}
+
+func Test_parseBackupStorageURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		storageType string
+		filePath    string
+		wantErr     bool
+	}{
+		{"s3://bucket/prefix/full/", string(constants.StorageTypeS3), "bucket/prefix/full", false},
+		{"s3://bucket", "", "", true},
+		{"local:///backup/full/", string(constants.StorageTypeNFS), "/backup/full", false},
+		{"local://backup", "", "", true},
+		{"/backup/full", string(constants.StorageTypeNFS), "/backup/full", false},
+		{"gcs://bucket/prefix", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			storageType, filePath, err := parseBackupStorageURL(tt.url)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.storageType, storageType)
+			assert.Equal(t, tt.filePath, filePath)
+		})
+	}
+}
+
+func Test_readBackupMeta(t *testing.T) {
+	backupTso := tso.ComposeTS(1640995200000, 1)
+
+	t.Run("v1", func(t *testing.T) {
+		root := t.TempDir()
+		writeBackupMeta(t, root, backupMetaFileName, &brpb.BackupMeta{
+			ClusterVersion: `"v5.2.1"`,
+			BrVersion:      "BR v5.2.1",
+			EndVersion:     backupTso,
+			Files:          []*brpb.File{{Name: "1.sst", Size_: 100}, {Name: "2.sst", Size_: 50}},
+			Schemas: []*brpb.Schema{
+				testSchema("shop", "orders"), testSchema("shop", "items"), testSchema("app", "users"),
+				testSchema("mysql", "user"), testSchema("__TiDB_BR_Temporary_mysql", "user"),
+			},
+		})
+		info, err := readBackupMeta(context.TODO(), &nfsFileStore{root: root})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.BackupTypeFull), info.BackupType)
+		assert.Equal(t, backupTso, info.BackupTso)
+		assert.Equal(t, uint64(150), info.Size)
+		assert.Equal(t, "v5.2.1", info.ClusterVersion)
+		assert.Equal(t, []string{"app", "shop"}, info.Databases)
+		assert.Equal(t, []string{"app.users", "shop.items", "shop.orders"}, info.Tables)
+		assert.True(t, info.Full)
+	})
+	t.Run("v2 incremental", func(t *testing.T) {
+		root := t.TempDir()
+		writeBackupMeta(t, root, "backupmeta.schema.000000001", &brpb.MetaFile{Schemas: []*brpb.Schema{testSchema("shop", "orders")}})
+		writeBackupMeta(t, root, "backupmeta.datafile.000000001", &brpb.MetaFile{DataFiles: []*brpb.File{{Name: "1.sst", Size_: 100}}})
+		writeBackupMeta(t, root, "backupmeta.datafile.000000002", &brpb.MetaFile{DataFiles: []*brpb.File{{Name: "2.sst", Size_: 20}}})
+		writeBackupMeta(t, root, backupMetaFileName, &brpb.BackupMeta{
+			StartVersion: backupTso - 100,
+			EndVersion:   backupTso,
+			FileIndex:    &brpb.MetaFile{MetaFiles: []*brpb.File{{Name: "backupmeta.datafile.000000001"}, {Name: "backupmeta.datafile.000000002"}}},
+			SchemaIndex:  &brpb.MetaFile{MetaFiles: []*brpb.File{{Name: "backupmeta.schema.000000001"}}},
+		})
+		info, err := readBackupMeta(context.TODO(), &nfsFileStore{root: root})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.BackupTypeIncrement), info.BackupType)
+		assert.Equal(t, uint64(120), info.Size)
+		assert.Equal(t, []string{"shop"}, info.Databases)
+		assert.False(t, info.Full)
+	})
+	t.Run("meta file checksum mismatch", func(t *testing.T) {
+		root := t.TempDir()
+		writeBackupMeta(t, root, "backupmeta.schema.000000001", &brpb.MetaFile{Schemas: []*brpb.Schema{testSchema("shop", "orders")}})
+		writeBackupMeta(t, root, backupMetaFileName, &brpb.BackupMeta{
+			EndVersion:  backupTso,
+			SchemaIndex: &brpb.MetaFile{MetaFiles: []*brpb.File{{Name: "backupmeta.schema.000000001", Sha256: []byte("wrong")}}},
+		})
+		_, err := readBackupMeta(context.TODO(), &nfsFileStore{root: root})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_META_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("raw kv", func(t *testing.T) {
+		root := t.TempDir()
+		writeBackupMeta(t, root, backupMetaFileName, &brpb.BackupMeta{EndVersion: backupTso, IsRawKv: true})
+		_, err := readBackupMeta(context.TODO(), &nfsFileStore{root: root})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_META_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("not backupmeta", func(t *testing.T) {
+		root := t.TempDir()
+		writeBackupFiles(t, root, map[string]string{backupMetaFileName: "encrypted content"})
+		_, err := readBackupMeta(context.TODO(), &nfsFileStore{root: root})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_META_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("not found", func(t *testing.T) {
+		_, err := readBackupMeta(context.TODO(), &nfsFileStore{root: t.TempDir()})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_META_INVALID, err.(emerr.EMError).GetCode())
+	})
+}
+
+func Test_backupMetaInfo_backupFilter(t *testing.T) {
+	databases, tables := (&backupMetaInfo{Databases: []string{"shop"}, Tables: []string{"shop.orders"}, Full: true}).backupFilter()
+	assert.Empty(t, databases)
+	assert.Empty(t, tables)
+	databases, tables = (&backupMetaInfo{Databases: []string{"shop"}, Tables: []string{"shop.orders"}}).backupFilter()
+	assert.Empty(t, databases)
+	assert.Equal(t, []string{"shop.orders"}, tables)
+	databases, tables = (&backupMetaInfo{Databases: []string{"shop"}}).backupFilter()
+	assert.Equal(t, []string{"shop"}, databases)
+	assert.Empty(t, tables)
+}
+
+func TestBRManager_ImportBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	backupTso := tso.ComposeTS(1640995200000, 1)
+	root := t.TempDir()
+	writeBackupMeta(t, root, backupMetaFileName, &brpb.BackupMeta{
+		ClusterVersion: `"v5.2.1"`,
+		EndVersion:     backupTso,
+		Files:          []*brpb.File{{Name: "1.sst", Size_: 100}},
+		Schemas:        []*brpb.Schema{testSchema("shop", "orders"), testSchema("app", "users")},
+	})
+
+	t.Run("unbound", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().CreateBackupRecord(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, record *backuprestore.BackupRecord) (*backuprestore.BackupRecord, error) {
+			assert.Empty(t, record.ClusterID)
+			assert.Equal(t, string(constants.ClusterBackupFinished), record.Status)
+			assert.Equal(t, string(constants.StorageTypeNFS), record.StorageType)
+			assert.Equal(t, root, record.FilePath)
+			assert.Equal(t, string(constants.BackupModeImported), record.BackupMode)
+			assert.Equal(t, string(constants.BackupTypeFull), record.BackupType)
+			assert.Equal(t, backupTso, record.BackupTso)
+			assert.Equal(t, uint64(100), record.Size)
+			assert.Empty(t, record.Databases)
+			assert.Equal(t, "app.users,shop.orders", record.Tables)
+			assert.Equal(t, "v5.2.1", record.ClusterVersion)
+			assert.Empty(t, record.StorageTargetID)
+			record.ID = "backup-xxx"
+			return record, nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		resp, err := GetBRService().ImportBackup(context.TODO(), cluster.ImportBackupReq{StorageURL: "local://" + root + "/"})
+		assert.NoError(t, err)
+		assert.Equal(t, "backup-xxx", resp.Record.ID)
+		assert.Equal(t, "v5.2.1", resp.Record.ClusterVersion)
+	})
+	t.Run("full", func(t *testing.T) {
+		fullRoot := t.TempDir()
+		writeBackupMeta(t, fullRoot, backupMetaFileName, &brpb.BackupMeta{
+			ClusterVersion: `"v5.2.1"`,
+			EndVersion:     backupTso,
+			Schemas:        []*brpb.Schema{testSchema("shop", "orders"), testSchema("mysql", "user")},
+		})
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().CreateBackupRecord(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, record *backuprestore.BackupRecord) (*backuprestore.BackupRecord, error) {
+			assert.Empty(t, record.Databases)
+			assert.Empty(t, record.Tables)
+			record.ID = "backup-full"
+			return record, nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		resp, err := GetBRService().ImportBackup(context.TODO(), cluster.ImportBackupReq{StorageURL: fullRoot})
+		assert.NoError(t, err)
+		assert.Equal(t, "backup-full", resp.Record.ID)
+	})
+	t.Run("invalid url", func(t *testing.T) {
+		_, err := GetBRService().ImportBackup(context.TODO(), cluster.ImportBackupReq{StorageURL: "hdfs://backup"})
+		assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("credentials for local", func(t *testing.T) {
+		_, err := GetBRService().ImportBackup(context.TODO(), cluster.ImportBackupReq{StorageURL: root, AccessKey: "ak"})
+		assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("meta invalid", func(t *testing.T) {
+		_, err := GetBRService().ImportBackup(context.TODO(), cluster.ImportBackupReq{StorageURL: t.TempDir()})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_META_INVALID, err.(emerr.EMError).GetCode())
+	})
+}
+
+func Test_registerImportStorageTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Cfg := &s3Config{Endpoint: "http://s3.example.com", AccessKey: "ak", SecretAccessKey: "sk"}
+
+	t.Run("create", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().QueryBackupStorageTargets(gomock.Any()).Return(make([]*backuprestore.BackupStorageTarget, 0), nil)
+		brRW.EXPECT().CreateBackupStorageTarget(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, target *backuprestore.BackupStorageTarget) (*backuprestore.BackupStorageTarget, error) {
+			assert.Equal(t, "bucket", target.FilePath)
+			assert.Equal(t, "tid-xxx", target.TenantId)
+			assert.Equal(t, common.Password("sk"), target.SecretAccessKey)
+			assert.Regexp(t, "^imported-[0-9a-f]{12}$", target.Name)
+			target.ID = "target-xxx"
+			return target, nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		id, err := registerImportStorageTarget(context.TODO(), "tid-xxx", s3Cfg, "bucket")
+		assert.NoError(t, err)
+		assert.Equal(t, "target-xxx", id)
+	})
+	t.Run("reuse", func(t *testing.T) {
+		var name string
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().QueryBackupStorageTargets(gomock.Any()).Return(make([]*backuprestore.BackupStorageTarget, 0), nil)
+		brRW.EXPECT().CreateBackupStorageTarget(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, target *backuprestore.BackupStorageTarget) (*backuprestore.BackupStorageTarget, error) {
+			name = target.Name
+			return target, nil
+		})
+		models.SetBRReaderWriter(brRW)
+		_, err := registerImportStorageTarget(context.TODO(), "tid-xxx", s3Cfg, "bucket")
+		assert.NoError(t, err)
+
+		brRW.EXPECT().QueryBackupStorageTargets(gomock.Any()).Return([]*backuprestore.BackupStorageTarget{
+			{Entity: common.Entity{ID: "target-other"}, Name: "offsite"},
+			{Entity: common.Entity{ID: "target-xxx"}, Name: name, SecretAccessKey: "old"},
+		}, nil)
+		brRW.EXPECT().UpdateBackupStorageTarget(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, target *backuprestore.BackupStorageTarget) error {
+			assert.Equal(t, common.Password("sk"), target.SecretAccessKey)
+			return nil
+		})
+		id, err := registerImportStorageTarget(context.TODO(), "tid-xxx", s3Cfg, "bucket")
+		assert.NoError(t, err)
+		assert.Equal(t, "target-xxx", id)
+	})
+}
+
+func TestBRManager_removeBackupFiles_imported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root := t.TempDir()
+	writeBackupFiles(t, root, map[string]string{backupMetaFileName: "meta"})
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"backup-xxx"}).Return(make([]*backuprestore.BackupLocation, 0), nil)
+	models.SetBRReaderWriter(brRW)
+
+	err := GetBRService().(*BRManager).removeBackupFiles(context.TODO(), &backuprestore.BackupRecord{
+		Entity:      common.Entity{ID: "backup-xxx"},
+		StorageType: string(constants.StorageTypeNFS),
+		BackupMode:  string(constants.BackupModeImported),
+		FilePath:    root,
+	})
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(root, backupMetaFileName))
+	assert.NoError(t, err)
+}
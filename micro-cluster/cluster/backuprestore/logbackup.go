@@ -0,0 +1,465 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	dbModel "github.com/pingcap/tiunimanager/models/common"
+	wfModel "github.com/pingcap/tiunimanager/models/workflow"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+)
+
+// logBackupStatus task status printed by `br log status --json`
+type logBackupStatus struct {
+	Name       string `json:"name"`
+	StartTS    uint64 `json:"start_ts"`
+	Checkpoint uint64 `json:"checkpoint"`
+	LastErrors []struct {
+		StoreID   uint64 `json:"store_id"`
+		LastError struct {
+			ErrorCode    string `json:"error_code"`
+			ErrorMessage string `json:"error_message"`
+		} `json:"last_error"`
+	} `json:"last_errors"`
+}
+
+func (mgr *BRManager) StartLogBackup(ctx context.Context, request cluster.StartLogBackupReq) (resp cluster.StartLogBackupResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin StartLogBackup, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End StartLogBackup")
+
+	clusterMeta, err := meta.Get(ctx, request.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+	if supported, _ := meta.CompareTiDBVersion(clusterMeta.Cluster.Version, minLogBackupVersion); !supported {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_LOG_TASK_UNSUPPORTED, "log backup requires cluster version %s or later, current %s", minLogBackupVersion, clusterMeta.Cluster.Version)
+	}
+
+	brRW := models.GetBRReaderWriter()
+	tasks, err := brRW.QueryLogBackupTasks(ctx, request.ClusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query log backup tasks of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("query log backup tasks of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+	for _, task := range tasks {
+		if task.Status != string(constants.LogBackupStopped) {
+			return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_LOG_TASK_CONFLICT, "log backup task %s of cluster %s is %s", task.TaskName, request.ClusterID, task.Status)
+		}
+	}
+
+	configRW := models.GetConfigReaderWriter()
+	storageTypeConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupStorageType)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get conifg %s failed: %s", constants.ConfigKeyBackupStorageType, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SYSTEM_CONFIG_INVAILD, fmt.Sprintf("get conifg %s failed: %s", constants.ConfigKeyBackupStorageType, err.Error()), err)
+	}
+	storagePathConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupStoragePath)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get conifg %s failed: %s", constants.ConfigKeyBackupStoragePath, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SYSTEM_CONFIG_INVAILD, fmt.Sprintf("get conifg %s failed: %s", constants.ConfigKeyBackupStoragePath, err.Error()), err)
+	}
+
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return resp, errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+
+	now := time.Now()
+	task := &backuprestore.LogBackupTask{
+		Entity: dbModel.Entity{
+			TenantId: clusterMeta.Cluster.TenantId,
+			Status:   string(constants.LogBackupRunning),
+		},
+		ClusterID:   request.ClusterID,
+		TaskName:    fmt.Sprintf("em-%s-%d", request.ClusterID, now.Unix()),
+		StorageType: storageTypeConfig.ConfigValue,
+		FilePath:    mgr.getBackupPath(storagePathConfig.ConfigValue, request.ClusterID, now, logBackupType),
+		StartTso:    tso.GenerateTSO(now, 0),
+	}
+	if string(constants.StorageTypeNFS) == task.StorageType {
+		if err = cleanBackupNfsPath(ctx, task.FilePath); err != nil {
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_PATH_CREATE_FAILED, fmt.Sprintf("create log backup path %s failed, %s", task.FilePath, err.Error()), err)
+		}
+	}
+	storageURL, err := getBRCmdStorageURL(ctx, task.StorageType, task.FilePath, "")
+	if err != nil {
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SYSTEM_CONFIG_INVAILD, err.Error(), err)
+	}
+
+	_, err = deployment.M.Ctl(ctx, deployment.TiUPComponentTypeBR, clusterMeta.Cluster.Version, "log", framework.GetTiupHomePathForTidb(), []string{
+		"start", "--task-name", task.TaskName, "--pd", fmt.Sprintf("%s:%d", pdAddress[0].IP, pdAddress[0].Port),
+		"--storage", storageURL, "--start-ts", strconv.FormatUint(task.StartTso, 10),
+	}, meta.DefaultTiupTimeOut)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("start log backup task %s failed, %s", task.TaskName, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("start log backup task %s failed, %s", task.TaskName, err.Error()), err)
+	}
+
+	taskCreate, err := brRW.CreateLogBackupTask(ctx, task)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("save log backup task %s failed, %s", task.TaskName, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("save log backup task %s failed, %s", task.TaskName, err.Error()), err)
+	}
+
+	resp.Task = convertLogBackupTask(taskCreate, nil)
+	return resp, nil
+}
+
+func (mgr *BRManager) StopLogBackup(ctx context.Context, request cluster.StopLogBackupReq) (resp cluster.StopLogBackupResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin StopLogBackup, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End StopLogBackup")
+
+	clusterMeta, err := meta.Get(ctx, request.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+
+	brRW := models.GetBRReaderWriter()
+	tasks, err := brRW.QueryLogBackupTasks(ctx, request.ClusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query log backup tasks of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("query log backup tasks of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+	var task *backuprestore.LogBackupTask
+	for _, t := range tasks {
+		if t.Status != string(constants.LogBackupStopped) {
+			task = t
+			break
+		}
+	}
+	if task == nil {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_LOG_TASK_NOT_FOUND, "no log backup task of cluster %s is running", request.ClusterID)
+	}
+
+	// keep the last checkpoint, the cluster can be restored to it after the task stopped
+	if err = refreshLogBackupTask(ctx, clusterMeta, task); err != nil {
+		framework.LogWithContext(ctx).Warnf("refresh checkpoint of log backup task %s failed, %s", task.TaskName, err.Error())
+	}
+
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return resp, errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+	_, err = deployment.M.Ctl(ctx, deployment.TiUPComponentTypeBR, clusterMeta.Cluster.Version, "log", framework.GetTiupHomePathForTidb(), []string{
+		"stop", "--task-name", task.TaskName, "--pd", fmt.Sprintf("%s:%d", pdAddress[0].IP, pdAddress[0].Port),
+	}, meta.DefaultTiupTimeOut)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("stop log backup task %s failed, %s", task.TaskName, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("stop log backup task %s failed, %s", task.TaskName, err.Error()), err)
+	}
+
+	task.Status = string(constants.LogBackupStopped)
+	task.StopTime = time.Now()
+	if err = brRW.UpdateLogBackupTask(ctx, task); err != nil {
+		framework.LogWithContext(ctx).Errorf("update log backup task %s failed, %s", task.TaskName, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("update log backup task %s failed, %s", task.TaskName, err.Error()), err)
+	}
+
+	resp.Task = convertLogBackupTask(task, nil)
+	return resp, nil
+}
+
+func (mgr *BRManager) QueryLogBackup(ctx context.Context, request cluster.QueryLogBackupReq) (resp cluster.QueryLogBackupResp, err error) {
+	brRW := models.GetBRReaderWriter()
+	tasks, err := brRW.QueryLogBackupTasks(ctx, request.ClusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query log backup tasks of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("query log backup tasks of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+	records, err := queryAllBackupRecords(ctx, request.ClusterID)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Tasks = make([]structs.LogBackupTaskInfo, 0)
+	for _, task := range tasks {
+		resp.Tasks = append(resp.Tasks, convertLogBackupTask(task, selectBaseSnapshot(records, task, task.CheckpointTso, false)))
+	}
+	return resp, nil
+}
+
+func (mgr *BRManager) CheckPointInTimeRestore(ctx context.Context, target cluster.PointInTimeRestoreTarget) error {
+	_, _, _, err := resolvePointInTimeRestore(ctx, target, "")
+	return err
+}
+
+// refreshLogBackupTask
+// @Description: refresh checkpoint and error of log backup task from `br log status`
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter task
+// @return error
+func refreshLogBackupTask(ctx context.Context, clusterMeta *meta.ClusterMeta, task *backuprestore.LogBackupTask) error {
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+	output, err := deployment.M.Ctl(ctx, deployment.TiUPComponentTypeBR, clusterMeta.Cluster.Version, "log", framework.GetTiupHomePathForTidb(), []string{
+		"status", "--task-name", task.TaskName, "--pd", fmt.Sprintf("%s:%d", pdAddress[0].IP, pdAddress[0].Port), "--json",
+	}, meta.DefaultTiupTimeOut)
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("query status of log backup task %s failed, %s", task.TaskName, err.Error()), err)
+	}
+
+	statuses := make([]logBackupStatus, 0)
+	if index := strings.Index(output, "["); index >= 0 {
+		output = output[index:]
+	}
+	if err = json.Unmarshal([]byte(output), &statuses); err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, fmt.Sprintf("parse status of log backup task %s failed, %s", task.TaskName, err.Error()), err)
+	}
+
+	task.Status = string(constants.LogBackupError)
+	task.LastError = fmt.Sprintf("task %s not found in cluster", task.TaskName)
+	for _, status := range statuses {
+		if status.Name != task.TaskName {
+			continue
+		}
+		if status.Checkpoint > task.CheckpointTso {
+			task.CheckpointTso = status.Checkpoint
+			task.CheckpointTime, _ = tso.ParseTS(status.Checkpoint)
+		}
+		task.Status = string(constants.LogBackupRunning)
+		task.LastError = ""
+		storeErrors := make([]string, 0)
+		for _, e := range status.LastErrors {
+			storeErrors = append(storeErrors, fmt.Sprintf("store %d: [%s] %s", e.StoreID, e.LastError.ErrorCode, e.LastError.ErrorMessage))
+		}
+		if len(storeErrors) > 0 {
+			task.Status = string(constants.LogBackupError)
+			task.LastError = strings.Join(storeErrors, "; ")
+		}
+	}
+
+	return models.GetBRReaderWriter().UpdateLogBackupTask(ctx, task)
+}
+
+// resolvePointInTimeRestore
+// @Description: select the base snapshot backup and the log backup task of source cluster to restore to target
+// @Parameter ctx
+// @Parameter target
+// @Parameter defaultSourceClusterID used when source cluster of target is empty
+// @return record base snapshot backup
+// @return task log backup task covering from the snapshot to restore tso
+// @return restoreTso
+// @return err
+func resolvePointInTimeRestore(ctx context.Context, target cluster.PointInTimeRestoreTarget, defaultSourceClusterID string) (record *backuprestore.BackupRecord, task *backuprestore.LogBackupTask, restoreTso uint64, err error) {
+	sourceClusterID := target.SourceClusterID
+	if sourceClusterID == "" {
+		sourceClusterID = defaultSourceClusterID
+	}
+	if sourceClusterID == "" {
+		return nil, nil, 0, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "source cluster of point-in-time restore is empty")
+	}
+
+	if target.RestoreTSO != "" {
+		restoreTso, err = strconv.ParseUint(target.RestoreTSO, 10, 64)
+		if err != nil {
+			return nil, nil, 0, errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid restore tso %s", target.RestoreTSO)
+		}
+	} else if target.RestoreTime > 0 {
+		restoreTso = tso.GenerateTSO(time.Unix(target.RestoreTime, 0), 0)
+	} else {
+		return nil, nil, 0, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "restore tso or restore time of point-in-time restore is required")
+	}
+
+	tasks, err := models.GetBRReaderWriter().QueryLogBackupTasks(ctx, sourceClusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query log backup tasks of cluster %s failed, %s", sourceClusterID, err.Error())
+		return nil, nil, 0, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("query log backup tasks of cluster %s failed, %s", sourceClusterID, err.Error()), err)
+	}
+	records, err := queryAllBackupRecords(ctx, sourceClusterID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	for _, task = range tasks {
+		if task.StartTso > restoreTso || task.CheckpointTso < restoreTso {
+			continue
+		}
+		if record = selectBaseSnapshot(records, task, restoreTso, true); record != nil {
+			return record, task, restoreTso, nil
+		}
+	}
+	return nil, nil, 0, errors.NewErrorf(errors.TIUNIMANAGER_RESTORE_POINT_NOT_COVERED,
+		"restore tso %d is not covered by any snapshot backup and log backup of cluster %s", restoreTso, sourceClusterID)
+}
+
+// selectBaseSnapshot
+// @Description: select a finished full backup of the whole cluster taken during the log backup task and no later than restore tso
+// @Parameter records
+// @Parameter task
+// @Parameter restoreTso
+// @Parameter latest select the latest one if true, otherwise the earliest one
+// @return *backuprestore.BackupRecord
+func selectBaseSnapshot(records []*backuprestore.BackupRecord, task *backuprestore.LogBackupTask, restoreTso uint64, latest bool) *backuprestore.BackupRecord {
+	var selected *backuprestore.BackupRecord
+	for _, record := range records {
+		if record.Status != string(constants.ClusterBackupFinished) || record.BackupType != string(constants.BackupTypeFull) {
+			continue
+		}
+		// backups of selected databases or tables can not be the base of restoring the whole cluster
+		if record.Databases != "" || record.Tables != "" {
+			continue
+		}
+		if record.BackupTso < task.StartTso || record.BackupTso > restoreTso {
+			continue
+		}
+		if selected == nil || (latest && record.BackupTso > selected.BackupTso) || (!latest && record.BackupTso < selected.BackupTso) {
+			selected = record
+		}
+	}
+	return selected
+}
+
+// queryAllBackupRecords
+// @Description: query all backup records of cluster page by page
+// @Parameter ctx
+// @Parameter clusterID
+// @return []*backuprestore.BackupRecord
+// @return error
+func queryAllBackupRecords(ctx context.Context, clusterID string) ([]*backuprestore.BackupRecord, error) {
+	brRW := models.GetBRReaderWriter()
+	records := make([]*backuprestore.BackupRecord, 0)
+	for page, pageSize := 1, defaultPageSize; ; page++ {
+		pageRecords, _, err := brRW.QueryBackupRecords(ctx, clusterID, "", "", 0, 0, page, pageSize)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("query backup records of cluster %s failed, %s", clusterID, err.Error())
+			return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_QUERY_FAILED, fmt.Sprintf("query cluster %s backup records failed %s", clusterID, err.Error()), err)
+		}
+		if len(pageRecords) == 0 {
+			break
+		}
+		records = append(records, pageRecords...)
+	}
+	return records, nil
+}
+
+// getBRCmdStorageURL
+// @Description: get storage url for br command, which is executed without shell so '&' is not escaped
+// @Parameter ctx
+// @Parameter storageType
+// @Parameter filePath
+// @Parameter storageTargetID the backup storage of system config if empty
+// @return string
+// @return error
+func getBRCmdStorageURL(ctx context.Context, storageType string, filePath string, storageTargetID string) (string, error) {
+	brStorageType, err := convertBrStorageType(storageType)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s://%s", brStorageType, strings.ReplaceAll(getBRStoragePath(ctx, storageType, filePath, storageTargetID), "\\&", "&")), nil
+}
+
+func convertLogBackupTask(task *backuprestore.LogBackupTask, baseSnapshot *backuprestore.BackupRecord) structs.LogBackupTaskInfo {
+	info := structs.LogBackupTaskInfo{
+		ID:             task.ID,
+		ClusterID:      task.ClusterID,
+		TaskName:       task.TaskName,
+		Status:         task.Status,
+		StorageType:    task.StorageType,
+		FilePath:       task.FilePath,
+		StartTSO:       strconv.FormatUint(task.StartTso, 10),
+		CheckpointTSO:  strconv.FormatUint(task.CheckpointTso, 10),
+		CheckpointTime: task.CheckpointTime,
+		LastError:      task.LastError,
+		StopTime:       task.StopTime,
+		CreateTime:     task.CreatedAt,
+		UpdateTime:     task.UpdatedAt,
+	}
+	if baseSnapshot != nil {
+		info.RecoverableFromTSO = strconv.FormatUint(baseSnapshot.BackupTso, 10)
+		info.RecoverableFromTime, _ = tso.ParseTS(baseSnapshot.BackupTso)
+	}
+	return info
+}
+
+func restoreClusterToPoint(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) error {
+	framework.LogWithContext(ctx).Info("begin restoreClusterToPoint")
+	defer framework.LogWithContext(ctx).Info("end restoreClusterToPoint")
+
+	var record backuprestore.BackupRecord
+	var task backuprestore.LogBackupTask
+	var clusterMeta meta.ClusterMeta
+	var restoreTso uint64
+	if err := ctx.GetData(contextBackupRecordKey, &record); err != nil {
+		return err
+	}
+	if err := ctx.GetData(contextLogBackupTaskKey, &task); err != nil {
+		return err
+	}
+	if err := ctx.GetData(contextClusterMetaKey, &clusterMeta); err != nil {
+		return err
+	}
+	if err := ctx.GetData(contextRestoreTsoKey, &restoreTso); err != nil {
+		return err
+	}
+
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+	fullBackupStorage, err := getBRCmdStorageURL(ctx, record.StorageType, record.FilePath, record.StorageTargetID)
+	if err != nil {
+		return err
+	}
+	logBackupStorage, err := getBRCmdStorageURL(ctx, task.StorageType, task.FilePath, "")
+	if err != nil {
+		return err
+	}
+	node.Record(fmt.Sprintf("restore cluster %s to tso %d, base snapshot backup %s, log backup task %s ",
+		clusterMeta.Cluster.ID, restoreTso, record.ID, task.TaskName))
+
+	args := []string{
+		"point", "--pd", fmt.Sprintf("%s:%d", pdAddress[0].IP, pdAddress[0].Port),
+		"--full-backup-storage", fullBackupStorage, "--storage", logBackupStorage,
+		"--restored-ts", strconv.FormatUint(restoreTso, 10),
+	}
+	if record.EncryptionKeyID != "" {
+		crypterArgs, cleanup, err := brCrypterArgs(ctx, record.EncryptionKeyID)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		args = append(args, crypterArgs...)
+	}
+
+	// restore takes a long time, no timeout just like restoring by sql
+	_, err = deployment.M.Ctl(ctx, deployment.TiUPComponentTypeBR, clusterMeta.Cluster.Version, "restore", framework.GetTiupHomePathForTidb(), args, 0)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("restore cluster %s to tso %d failed, %s", clusterMeta.Cluster.ID, restoreTso, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	mock_deployment "github.com/pingcap/tiunimanager/test/mockdeployment"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+	"github.com/stretchr/testify/assert"
+)
+
+var logBackupBaseTime = time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)
+
+func mockLogBackupCluster(version string) (*management.Cluster, []*management.ClusterInstance) {
+	return &management.Cluster{
+		Entity: common.Entity{
+			ID:       "cls-test",
+			TenantId: "tid-xxx",
+		},
+		Version: version,
+	}, []*management.ClusterInstance{
+		{
+			Entity: common.Entity{
+				Status: string(constants.ClusterInstanceRunning),
+			},
+			Type:   string(constants.ComponentIDPD),
+			HostIP: []string{"127.0.0.1"},
+			Ports:  []int32{2379},
+		},
+	}
+}
+
+func mockLogBackupRecords() []*backuprestore.BackupRecord {
+	return []*backuprestore.BackupRecord{
+		{
+			Entity:     common.Entity{ID: "record-1", Status: string(constants.ClusterBackupFinished)},
+			BackupType: string(constants.BackupTypeFull),
+			BackupTso:  tso.GenerateTSO(logBackupBaseTime.Add(time.Hour), 0),
+		},
+		{
+			Entity:     common.Entity{ID: "record-2", Status: string(constants.ClusterBackupFinished)},
+			BackupType: string(constants.BackupTypeFull),
+			BackupTso:  tso.GenerateTSO(logBackupBaseTime.Add(3*time.Hour), 0),
+		},
+		{
+			Entity:     common.Entity{ID: "record-3", Status: string(constants.ClusterBackupFailed)},
+			BackupType: string(constants.BackupTypeFull),
+			BackupTso:  tso.GenerateTSO(logBackupBaseTime.Add(4*time.Hour), 0),
+		},
+		{
+			Entity:     common.Entity{ID: "record-4", Status: string(constants.ClusterBackupFinished)},
+			BackupType: string(constants.BackupTypeFull),
+			BackupTso:  tso.GenerateTSO(logBackupBaseTime.Add(-time.Hour), 0),
+		},
+	}
+}
+
+func mockLogBackupTask() *backuprestore.LogBackupTask {
+	return &backuprestore.LogBackupTask{
+		Entity:        common.Entity{ID: "task-1", Status: string(constants.LogBackupRunning)},
+		ClusterID:     "cls-test",
+		TaskName:      "em-cls-test",
+		StorageType:   string(constants.StorageTypeS3),
+		FilePath:      "bucket/cls-test/log",
+		StartTso:      tso.GenerateTSO(logBackupBaseTime, 0),
+		CheckpointTso: tso.GenerateTSO(logBackupBaseTime.Add(5*time.Hour), 0),
+	}
+}
+
+func Test_selectBaseSnapshot(t *testing.T) {
+	records := mockLogBackupRecords()
+	task := mockLogBackupTask()
+
+	t.Run("latest", func(t *testing.T) {
+		record := selectBaseSnapshot(records, task, tso.GenerateTSO(logBackupBaseTime.Add(4*time.Hour), 0), true)
+		assert.Equal(t, "record-2", record.ID)
+	})
+	t.Run("earliest", func(t *testing.T) {
+		record := selectBaseSnapshot(records, task, task.CheckpointTso, false)
+		assert.Equal(t, "record-1", record.ID)
+	})
+	t.Run("skip filtered backup", func(t *testing.T) {
+		filtered := append(mockLogBackupRecords(), &backuprestore.BackupRecord{
+			Entity:     common.Entity{ID: "record-5", Status: string(constants.ClusterBackupFinished)},
+			BackupType: string(constants.BackupTypeFull),
+			BackupTso:  tso.GenerateTSO(logBackupBaseTime.Add(3*time.Hour+time.Minute), 0),
+			Databases:  "db1",
+		})
+		record := selectBaseSnapshot(filtered, task, tso.GenerateTSO(logBackupBaseTime.Add(4*time.Hour), 0), true)
+		assert.Equal(t, "record-2", record.ID)
+	})
+	t.Run("before snapshot", func(t *testing.T) {
+		record := selectBaseSnapshot(records, task, tso.GenerateTSO(logBackupBaseTime.Add(time.Minute), 0), true)
+		assert.Nil(t, record)
+	})
+}
+
+func Test_resolvePointInTimeRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return([]*backuprestore.LogBackupTask{mockLogBackupTask()}, nil).AnyTimes()
+	brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-test", "", "", int64(0), int64(0), 1, gomock.Any()).Return(mockLogBackupRecords(), int64(4), nil).AnyTimes()
+	brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-test", "", "", int64(0), int64(0), 2, gomock.Any()).Return(nil, int64(4), nil).AnyTimes()
+
+	t.Run("by time", func(t *testing.T) {
+		record, task, restoreTso, err := resolvePointInTimeRestore(context.TODO(), cluster.PointInTimeRestoreTarget{
+			RestoreTime: logBackupBaseTime.Add(2 * time.Hour).Unix(),
+		}, "cls-test")
+		assert.NoError(t, err)
+		assert.Equal(t, "record-1", record.ID)
+		assert.Equal(t, "task-1", task.ID)
+		assert.Equal(t, tso.GenerateTSO(logBackupBaseTime.Add(2*time.Hour), 0), restoreTso)
+	})
+	t.Run("by tso", func(t *testing.T) {
+		target := tso.GenerateTSO(logBackupBaseTime.Add(4*time.Hour), 0)
+		record, _, restoreTso, err := resolvePointInTimeRestore(context.TODO(), cluster.PointInTimeRestoreTarget{
+			SourceClusterID: "cls-test",
+			RestoreTSO:      fmt.Sprintf("%d", target),
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "record-2", record.ID)
+		assert.Equal(t, target, restoreTso)
+	})
+	t.Run("not covered", func(t *testing.T) {
+		_, _, _, err := resolvePointInTimeRestore(context.TODO(), cluster.PointInTimeRestoreTarget{
+			RestoreTime: logBackupBaseTime.Add(6 * time.Hour).Unix(),
+		}, "cls-test")
+		assert.Error(t, err)
+		assert.Equal(t, emerr.TIUNIMANAGER_RESTORE_POINT_NOT_COVERED, err.(emerr.EMError).GetCode())
+	})
+	t.Run("invalid tso", func(t *testing.T) {
+		_, _, _, err := resolvePointInTimeRestore(context.TODO(), cluster.PointInTimeRestoreTarget{
+			RestoreTSO: "abc",
+		}, "cls-test")
+		assert.Error(t, err)
+	})
+	t.Run("empty target", func(t *testing.T) {
+		_, _, _, err := resolvePointInTimeRestore(context.TODO(), cluster.PointInTimeRestoreTarget{}, "cls-test")
+		assert.Error(t, err)
+	})
+	t.Run("empty source", func(t *testing.T) {
+		_, _, _, err := resolvePointInTimeRestore(context.TODO(), cluster.PointInTimeRestoreTarget{RestoreTSO: "1"}, "")
+		assert.Error(t, err)
+	})
+}
+
+func Test_refreshLogBackupTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	brRW.EXPECT().UpdateLogBackupTask(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+	deployment.M = mockTiupManager
+
+	cls, instances := mockLogBackupCluster("v6.5.0")
+	clusterMeta := &meta.ClusterMeta{
+		Cluster:   cls,
+		Instances: map[string][]*management.ClusterInstance{string(constants.ComponentIDPD): instances},
+	}
+	checkpoint := tso.GenerateTSO(logBackupBaseTime.Add(6*time.Hour), 0)
+
+	t.Run("running", func(t *testing.T) {
+		task := mockLogBackupTask()
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(fmt.Sprintf("Detail BR log in /tmp/br.log\n[{\"name\":\"em-cls-test\",\"checkpoint\":%d}]", checkpoint), nil)
+		err := refreshLogBackupTask(context.TODO(), clusterMeta, task)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.LogBackupRunning), task.Status)
+		assert.Equal(t, checkpoint, task.CheckpointTso)
+	})
+	t.Run("store error", func(t *testing.T) {
+		task := mockLogBackupTask()
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).
+			Return("[{\"name\":\"em-cls-test\",\"checkpoint\":1,\"last_errors\":[{\"store_id\":1,\"last_error\":{\"error_code\":\"KV:Unknown\",\"error_message\":\"disk full\"}}]}]", nil)
+		err := refreshLogBackupTask(context.TODO(), clusterMeta, task)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.LogBackupError), task.Status)
+		assert.Contains(t, task.LastError, "disk full")
+		assert.Equal(t, mockLogBackupTask().CheckpointTso, task.CheckpointTso)
+	})
+	t.Run("not found", func(t *testing.T) {
+		task := mockLogBackupTask()
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).
+			Return("[]", nil)
+		err := refreshLogBackupTask(context.TODO(), clusterMeta, task)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.LogBackupError), task.Status)
+	})
+	t.Run("failed", func(t *testing.T) {
+		task := mockLogBackupTask()
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).
+			Return("", fmt.Errorf("tiup failed"))
+		err := refreshLogBackupTask(context.TODO(), clusterMeta, task)
+		assert.Error(t, err)
+	})
+}
+
+func TestBRManager_StartLogBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	configRW.EXPECT().GetConfig(gomock.Any(), gomock.Any()).Return(&config.SystemConfig{ConfigValue: string(constants.StorageTypeS3)}, nil).AnyTimes()
+	models.SetConfigReaderWriter(configRW)
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+	deployment.M = mockTiupManager
+
+	t.Run("normal", func(t *testing.T) {
+		cls, instances := mockLogBackupCluster("v6.5.0")
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return([]*backuprestore.LogBackupTask{
+			{Entity: common.Entity{Status: string(constants.LogBackupStopped)}},
+		}, nil)
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).Return("", nil)
+		brRW.EXPECT().CreateLogBackupTask(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, task *backuprestore.LogBackupTask) (*backuprestore.LogBackupTask, error) {
+			task.ID = "task-1"
+			return task, nil
+		})
+
+		resp, err := GetBRService().StartLogBackup(context.TODO(), cluster.StartLogBackupReq{ClusterID: "cls-test"})
+		assert.NoError(t, err)
+		assert.Equal(t, "task-1", resp.Task.ID)
+		assert.Equal(t, string(constants.LogBackupRunning), resp.Task.Status)
+	})
+	t.Run("conflict", func(t *testing.T) {
+		cls, instances := mockLogBackupCluster("v6.5.0")
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return([]*backuprestore.LogBackupTask{mockLogBackupTask()}, nil)
+
+		_, err := GetBRService().StartLogBackup(context.TODO(), cluster.StartLogBackupReq{ClusterID: "cls-test"})
+		assert.Error(t, err)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_LOG_TASK_CONFLICT, err.(emerr.EMError).GetCode())
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		cls, instances := mockLogBackupCluster("v5.4.0")
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+
+		_, err := GetBRService().StartLogBackup(context.TODO(), cluster.StartLogBackupReq{ClusterID: "cls-test"})
+		assert.Error(t, err)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_LOG_TASK_UNSUPPORTED, err.(emerr.EMError).GetCode())
+	})
+	t.Run("start failed", func(t *testing.T) {
+		cls, instances := mockLogBackupCluster("v6.5.0")
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return(nil, nil)
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).Return("", fmt.Errorf("tiup failed"))
+
+		_, err := GetBRService().StartLogBackup(context.TODO(), cluster.StartLogBackupReq{ClusterID: "cls-test"})
+		assert.Error(t, err)
+	})
+}
+
+func TestBRManager_StopLogBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+	deployment.M = mockTiupManager
+
+	t.Run("normal", func(t *testing.T) {
+		cls, instances := mockLogBackupCluster("v6.5.0")
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return([]*backuprestore.LogBackupTask{mockLogBackupTask()}, nil)
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).Return("[{\"name\":\"em-cls-test\"}]", nil)
+		mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "log", gomock.Any(), gomock.Any(), gomock.Any()).Return("", nil)
+		brRW.EXPECT().UpdateLogBackupTask(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		resp, err := GetBRService().StopLogBackup(context.TODO(), cluster.StopLogBackupReq{ClusterID: "cls-test"})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.LogBackupStopped), resp.Task.Status)
+	})
+	t.Run("not found", func(t *testing.T) {
+		cls, instances := mockLogBackupCluster("v6.5.0")
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(cls, instances, make([]*management.DBUser, 0), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return(nil, nil)
+
+		_, err := GetBRService().StopLogBackup(context.TODO(), cluster.StopLogBackupReq{ClusterID: "cls-test"})
+		assert.Error(t, err)
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_LOG_TASK_NOT_FOUND, err.(emerr.EMError).GetCode())
+	})
+}
+
+func TestBRManager_QueryLogBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-test", "").Return([]*backuprestore.LogBackupTask{mockLogBackupTask()}, nil)
+	brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-test", "", "", int64(0), int64(0), 1, gomock.Any()).Return(mockLogBackupRecords(), int64(4), nil)
+	brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-test", "", "", int64(0), int64(0), 2, gomock.Any()).Return(nil, int64(4), nil)
+
+	resp, err := GetBRService().QueryLogBackup(context.TODO(), cluster.QueryLogBackupReq{ClusterID: "cls-test"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Tasks))
+	assert.Equal(t, fmt.Sprintf("%d", mockLogBackupRecords()[0].BackupTso), resp.Tasks[0].RecoverableFromTSO)
+}
+
+func TestExecutor_restoreClusterToPoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	configRW.EXPECT().GetConfig(gomock.Any(), gomock.Any()).Return(&config.SystemConfig{ConfigValue: "test"}, nil).AnyTimes()
+	models.SetConfigReaderWriter(configRW)
+	mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+	deployment.M = mockTiupManager
+	mockTiupManager.EXPECT().Ctl(gomock.Any(), deployment.TiUPComponentTypeBR, "v6.5.0", "restore", gomock.Any(), gomock.Any(), 0).Return("", nil)
+
+	cls, instances := mockLogBackupCluster("v6.5.0")
+	record := mockLogBackupRecords()[0]
+	record.StorageType = string(constants.StorageTypeS3)
+	flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+	flowContext.SetData(contextBackupRecordKey, record)
+	flowContext.SetData(contextLogBackupTaskKey, mockLogBackupTask())
+	flowContext.SetData(contextRestoreTsoKey, tso.GenerateTSO(logBackupBaseTime.Add(2*time.Hour), 0))
+	flowContext.SetData(contextClusterMetaKey, &meta.ClusterMeta{
+		Cluster:   cls,
+		Instances: map[string][]*management.ClusterInstance{string(constants.ComponentIDPD): instances},
+	})
+	err := restoreClusterToPoint(&workflowModel.WorkFlowNode{}, flowContext)
+	assert.NoError(t, err)
+}
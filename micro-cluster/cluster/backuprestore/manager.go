@@ -74,7 +74,17 @@ func NewBRManager() *BRManager {
 	flowManager.RegisterWorkFlow(context.TODO(), constants.FlowRestoreExistCluster, &workflow.WorkFlowDefine{
 		FlowName: constants.FlowRestoreExistCluster,
 		TaskNodes: map[string]*workflow.NodeDefine{
-			"start":       {"restoreFromSrcCluster", "restoreDone", "fail", workflow.SyncFuncNode, restoreFromSrcCluster},
+			"start":       {"checkRestore", "checkDone", "fail", workflow.SyncFuncNode, checkRestore},
+			"checkDone":   {"restoreFromSrcCluster", "restoreDone", "fail", workflow.SyncFuncNode, restoreFromSrcCluster},
+			"restoreDone": {"end", "", "", workflow.SyncFuncNode, defaultEnd},
+			"fail":        {"fail", "", "", workflow.SyncFuncNode, restoreFail},
+		},
+	})
+	flowManager.RegisterWorkFlow(context.TODO(), constants.FlowPointInTimeRestoreCluster, &workflow.WorkFlowDefine{
+		FlowName: constants.FlowPointInTimeRestoreCluster,
+		TaskNodes: map[string]*workflow.NodeDefine{
+			"start":       {"checkRestore", "checkDone", "fail", workflow.SyncFuncNode, checkRestore},
+			"checkDone":   {"restoreClusterToPoint", "restoreDone", "fail", workflow.SyncFuncNode, restoreClusterToPoint},
 			"restoreDone": {"end", "", "", workflow.SyncFuncNode, defaultEnd},
 			"fail":        {"fail", "", "", workflow.SyncFuncNode, restoreFail},
 		},
@@ -113,6 +123,12 @@ func (mgr *BRManager) BackupCluster(ctx context.Context, request cluster.BackupC
 		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
 	}
 
+	encryptionKeyID, err := getClusterBackupKey(ctx, meta.Cluster.TenantId, request.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup key of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, err
+	}
+
 	if maintenanceStatusChange {
 		if err := meta.StartMaintenance(ctx, constants.ClusterMaintenanceBackUp); err != nil {
 			framework.LogWithContext(ctx).Errorf("start maintenance failed, %s", err.Error())
@@ -141,6 +157,12 @@ func (mgr *BRManager) BackupCluster(ctx context.Context, request cluster.BackupC
 		FilePath:     mgr.getBackupPath(storagePathConfig.ConfigValue, request.ClusterID, time.Now(), string(constants.BackupTypeFull)),
 		StartTime:    time.Now(),
 		EndTime:      time.Now(),
+		Expirable:    request.BackupMode == string(constants.BackupModeAuto) || request.Expirable,
+		Databases:    joinFilterNames(request.Filter.Databases),
+		Tables:       joinFilterNames(request.Filter.Tables),
+
+		EncryptionKeyID: encryptionKeyID,
+		ClusterVersion:  meta.Cluster.Version,
 	}
 	brRW := models.GetBRReaderWriter()
 	recordCreate, err := brRW.CreateBackupRecord(ctx, record)
@@ -187,11 +209,39 @@ func (mgr *BRManager) RestoreExistCluster(ctx context.Context, request cluster.R
 		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
 	}
 
-	brRW := models.GetBRReaderWriter()
-	record, err := brRW.GetBackupRecord(ctx, request.BackupID)
-	if err != nil {
-		framework.LogWithContext(ctx).Errorf("get backup record %s failed, %s", request.BackupID, err.Error())
-		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_QUERY_FAILED, fmt.Sprintf("get backup record %s failed, %s", request.BackupID, err.Error()), err)
+	flowName := constants.FlowRestoreExistCluster
+	var record *backuprestore.BackupRecord
+	var logBackupTask *backuprestore.LogBackupTask
+	var restoreTso uint64
+	var filter *restoreFilter
+	if request.BackupID != "" {
+		brRW := models.GetBRReaderWriter()
+		record, err = brRW.GetBackupRecord(ctx, request.BackupID)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("get backup record %s failed, %s", request.BackupID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_QUERY_FAILED, fmt.Sprintf("get backup record %s failed, %s", request.BackupID, err.Error()), err)
+		}
+		filter, err = mgr.restoreFilterPreCheck(request, record)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("restore filter precheck failed, %s", err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_PARAMETER_INVALID, fmt.Sprintf("restore filter precheck failed, %s", err.Error()), err)
+		}
+		if request.LocationID != "" {
+			record, err = restoreFromLocation(ctx, record, request.LocationID)
+			if err != nil {
+				return resp, err
+			}
+		}
+	} else {
+		if !isFilterEmpty(request.Filter) || request.TargetDatabase != "" || request.LocationID != "" {
+			return resp, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "filter, target database and location are not supported by point-in-time restore")
+		}
+		record, logBackupTask, restoreTso, err = resolvePointInTimeRestore(ctx, request.PointInTime, request.ClusterID)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("resolve point-in-time restore %+v failed, %s", request.PointInTime, err.Error())
+			return resp, err
+		}
+		flowName = constants.FlowPointInTimeRestoreCluster
 	}
 
 	if maintenanceStatusChange {
@@ -209,18 +259,25 @@ func (mgr *BRManager) RestoreExistCluster(ctx context.Context, request cluster.R
 	}
 
 	flowManager := workflow.GetWorkFlowService()
-	flowId, err := flowManager.CreateWorkFlow(ctx, request.ClusterID, workflow.BizTypeCluster, constants.FlowRestoreExistCluster)
+	flowId, err := flowManager.CreateWorkFlow(ctx, request.ClusterID, workflow.BizTypeCluster, flowName)
 	if err != nil {
-		framework.LogWithContext(ctx).Errorf("create %s workflow failed, %s", constants.FlowRestoreExistCluster, err.Error())
-		return resp, errors.WrapError(errors.TIUNIMANAGER_WORKFLOW_CREATE_FAILED, fmt.Sprintf("create %s workflow failed, %s", constants.FlowRestoreExistCluster, err.Error()), err)
+		framework.LogWithContext(ctx).Errorf("create %s workflow failed, %s", flowName, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_WORKFLOW_CREATE_FAILED, fmt.Sprintf("create %s workflow failed, %s", flowName, err.Error()), err)
 	}
 
 	flowManager.InitContext(ctx, flowId, contextBackupRecordKey, record)
 	flowManager.InitContext(ctx, flowId, contextClusterMetaKey, meta)
 	flowManager.InitContext(ctx, flowId, contextMaintenanceStatusChangeKey, maintenanceStatusChange)
+	if filter != nil {
+		flowManager.InitContext(ctx, flowId, contextRestoreFilterKey, filter)
+	}
+	if logBackupTask != nil {
+		flowManager.InitContext(ctx, flowId, contextLogBackupTaskKey, logBackupTask)
+		flowManager.InitContext(ctx, flowId, contextRestoreTsoKey, restoreTso)
+	}
 	if err = flowManager.Start(ctx, flowId); err != nil {
-		framework.LogWithContext(ctx).Errorf("async start %s workflow failed, %s", constants.FlowRestoreExistCluster, err.Error())
-		return resp, errors.WrapError(errors.TIUNIMANAGER_WORKFLOW_START_FAILED, fmt.Sprintf("async start %s workflow failed, %s", constants.FlowRestoreExistCluster, err.Error()), err)
+		framework.LogWithContext(ctx).Errorf("async start %s workflow failed, %s", flowName, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_WORKFLOW_START_FAILED, fmt.Sprintf("async start %s workflow failed, %s", flowName, err.Error()), err)
 	}
 
 	resp.WorkFlowID = flowId
@@ -306,27 +363,69 @@ func (mgr *BRManager) QueryClusterBackupRecords(ctx context.Context, request clu
 		BackupRecords: make([]*structs.BackupRecord, len(records)),
 	}
 	for index, record := range records {
-		response.BackupRecords[index] = &structs.BackupRecord{
-			ID:           record.ID,
-			ClusterID:    record.ClusterID,
-			BackupType:   record.BackupType,
-			BackupMethod: record.BackupMethod,
-			BackupMode:   record.BackupMode,
-			FilePath:     record.FilePath,
-			Size:         float32(record.Size) / bytes.MB, //Byte to MByte,
-			BackupTSO:    strconv.FormatUint(record.BackupTso, 10),
-			Status:       record.Status,
-			StartTime:    record.StartTime,
-			EndTime:      record.EndTime,
-			CreateTime:   record.CreatedAt,
-			UpdateTime:   record.UpdatedAt,
-			DeleteTime:   record.DeletedAt.Time,
+		response.BackupRecords[index] = convertBackupRecord(record)
+	}
+
+	if len(records) > 0 {
+		backupIds := make([]string, 0, len(records))
+		indexes := make(map[string]int, len(records))
+		for index, record := range records {
+			backupIds = append(backupIds, record.ID)
+			indexes[record.ID] = index
+		}
+		locations, err := brRW.QueryBackupLocations(ctx, backupIds)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("query locations of backup records %v failed %s", backupIds, err.Error())
+			return resp, page, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_QUERY_FAILED, fmt.Sprintf("query locations of backup records failed %s", err.Error()), err)
+		}
+		failStaleBackupCopies(ctx, locations)
+		for _, location := range locations {
+			if index, ok := indexes[location.BackupID]; ok {
+				response.BackupRecords[index].Locations = append(response.BackupRecords[index].Locations, convertBackupLocation(location))
+			}
 		}
 	}
 
 	return response, structs.Page{Page: request.Page, PageSize: request.PageSize, Total: int(total)}, nil
 }
 
+func convertBackupRecord(record *backuprestore.BackupRecord) *structs.BackupRecord {
+	return &structs.BackupRecord{
+		ID:           record.ID,
+		ClusterID:    record.ClusterID,
+		BackupType:   record.BackupType,
+		BackupMethod: record.BackupMethod,
+		BackupMode:   record.BackupMode,
+		FilePath:     record.FilePath,
+		Size:         float32(record.Size) / bytes.MB, //Byte to MByte,
+		BackupTSO:    strconv.FormatUint(record.BackupTso, 10),
+		Status:       record.Status,
+		StartTime:    record.StartTime,
+		EndTime:      record.EndTime,
+		CreateTime:   record.CreatedAt,
+		UpdateTime:   record.UpdatedAt,
+		DeleteTime:   record.DeletedAt.Time,
+		Expirable:    record.Expirable,
+		Filter: structs.BackupFilter{
+			Databases: splitFilterNames(record.Databases),
+			Tables:    splitFilterNames(record.Tables),
+		},
+		EncryptionKeyID: record.EncryptionKeyID,
+
+		VerifyStatus:  record.VerifyStatus,
+		VerifyTime:    record.VerifyTime,
+		VerifyMessage: record.VerifyMessage,
+
+		Progress:         record.Progress,
+		ProcessedSize:    float32(record.ProcessedSize) / bytes.MB,
+		EstimatedEndTime: record.EstimatedEndTime,
+
+		StorageTargetID: record.StorageTargetID,
+		Locations:       make([]structs.BackupLocation, 0),
+		ClusterVersion:  record.ClusterVersion,
+	}
+}
+
 func (mgr *BRManager) DeleteBackupRecords(ctx context.Context, request cluster.DeleteBackupDataReq) (resp cluster.DeleteBackupDataResp, err error) {
 	framework.LogWithContext(ctx).Infof("Begin DeleteBackupRecords, request: %+v", request)
 	defer framework.LogWithContext(ctx).Infof("End DeleteBackupRecords")
@@ -400,6 +499,18 @@ func (mgr *BRManager) GetBackupStrategy(ctx context.Context, request cluster.Get
 		ClusterID:  request.ClusterID,
 		BackupDate: strategy.BackupDate,
 		Period:     fmt.Sprintf("%d:00-%d:00", strategy.StartHour, strategy.EndHour),
+		Retention: structs.BackupRetentionPolicy{
+			KeepLast:    strategy.KeepLast,
+			KeepDays:    strategy.KeepDays,
+			KeepDaily:   strategy.KeepDaily,
+			KeepWeekly:  strategy.KeepWeekly,
+			KeepMonthly: strategy.KeepMonthly,
+		},
+		Filter: structs.BackupFilter{
+			Databases: splitFilterNames(strategy.Databases),
+			Tables:    splitFilterNames(strategy.Tables),
+		},
+		CopyTargetID: strategy.CopyTargetID,
 	}
 	return resp, nil
 }
@@ -419,21 +530,37 @@ func (mgr *BRManager) SaveBackupStrategy(ctx context.Context, request cluster.Sa
 		return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
 	}
 
+	brRW := models.GetBRReaderWriter()
+	if request.Strategy.CopyTargetID != "" {
+		if _, err = brRW.GetBackupStorageTarget(ctx, request.Strategy.CopyTargetID); err != nil {
+			framework.LogWithContext(ctx).Errorf("get backup storage target %s failed, %s", request.Strategy.CopyTargetID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, fmt.Sprintf("get backup storage target %s failed, %s", request.Strategy.CopyTargetID, err.Error()), err)
+		}
+	}
+
 	period := strings.Split(request.Strategy.Period, "-")
 	starts := strings.Split(period[0], ":")
 	ends := strings.Split(period[1], ":")
 	startHour, _ := strconv.Atoi(starts[0])
 	endHour, _ := strconv.Atoi(ends[0])
 
-	brRW := models.GetBRReaderWriter()
 	strategy, err := brRW.SaveBackupStrategy(ctx, &backuprestore.BackupStrategy{
 		Entity: dbModel.Entity{
 			TenantId: meta.Cluster.TenantId,
 		},
-		ClusterID:  request.ClusterID,
-		BackupDate: request.Strategy.BackupDate,
-		StartHour:  uint32(startHour),
-		EndHour:    uint32(endHour),
+		ClusterID:   request.ClusterID,
+		BackupDate:  request.Strategy.BackupDate,
+		StartHour:   uint32(startHour),
+		EndHour:     uint32(endHour),
+		KeepLast:    request.Strategy.Retention.KeepLast,
+		KeepDays:    request.Strategy.Retention.KeepDays,
+		KeepDaily:   request.Strategy.Retention.KeepDaily,
+		KeepWeekly:  request.Strategy.Retention.KeepWeekly,
+		KeepMonthly: request.Strategy.Retention.KeepMonthly,
+		Databases:   joinFilterNames(request.Strategy.Filter.Databases),
+		Tables:      joinFilterNames(request.Strategy.Filter.Tables),
+
+		CopyTargetID: request.Strategy.CopyTargetID,
 	})
 	if err != nil {
 		framework.LogWithContext(ctx).Errorf("save backup strategy %+v failed %s", strategy, err.Error())
@@ -453,10 +580,55 @@ func (mgr *BRManager) DeleteBackupStrategy(ctx context.Context, request cluster.
 		framework.LogWithContext(ctx).Errorf("delete cluster %s backup strategy failed %s", request.ClusterID, err.Error())
 		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_STRATEGY_DELETE_FAILED, fmt.Sprintf("delete cluster %s backup strategy failed %s", request.ClusterID, err.Error()), err)
 	}
+	err = brRW.DeleteBackupSchedules(ctx, request.ClusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("delete cluster %s backup schedules failed %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_DELETE_FAILED, fmt.Sprintf("delete cluster %s backup schedules failed %s", request.ClusterID, err.Error()), err)
+	}
 
 	return resp, nil
 }
 
+func (mgr *BRManager) PurgeExpiredBackupRecords(ctx context.Context, clusterID string) (purgedIDs []string, err error) {
+	framework.LogWithContext(ctx).Infof("Begin PurgeExpiredBackupRecords, clusterId: %s", clusterID)
+	defer framework.LogWithContext(ctx).Infof("End PurgeExpiredBackupRecords")
+
+	brRW := models.GetBRReaderWriter()
+	strategy, err := brRW.GetBackupStrategy(ctx, clusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup strategy of cluster %s failed %s", clusterID, err.Error())
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_STRATEGY_QUERY_FAILED, fmt.Sprintf("get backup strategy of cluster %s failed %s", clusterID, err.Error()), err)
+	}
+	if !strategy.RetentionEnabled() {
+		return nil, nil
+	}
+
+	records, err := queryAllBackupRecords(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := brRW.QueryLogBackupTasks(ctx, clusterID, "")
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query log backup tasks of cluster %s failed, %s", clusterID, err.Error())
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_LOG_TASK_FAILED, fmt.Sprintf("query log backup tasks of cluster %s failed, %s", clusterID, err.Error()), err)
+	}
+
+	for _, record := range selectExpiredBackupRecords(records, tasks, strategy, time.Now()) {
+		framework.LogWithContext(ctx).Infof("begin purge expired backup record %+v", record)
+		if err = mgr.removeBackupFiles(ctx, record); err != nil {
+			framework.LogWithContext(ctx).Warnf("remove backup files of recordId %s failed, %s", record.ID, err.Error())
+		}
+		if err = brRW.DeleteBackupRecord(ctx, record.ID); err != nil {
+			framework.LogWithContext(ctx).Errorf("delete backup record %s failed, %s", record.ID, err.Error())
+			return purgedIDs, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_DELETE_FAILED, fmt.Sprintf("delete backup record %s failed, %s", record.ID, err.Error()), err)
+		}
+		purgedIDs = append(purgedIDs, record.ID)
+	}
+
+	return purgedIDs, nil
+}
+
 func (mgr *BRManager) backupClusterPreCheck(ctx context.Context, request cluster.BackupClusterDataReq) error {
 	configRW := models.GetConfigReaderWriter()
 	storageTypeCfg, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupStorageType)
@@ -502,14 +674,56 @@ func (mgr *BRManager) backupClusterPreCheck(ctx context.Context, request cluster
 		request.BackupMode != string(constants.BackupModeAuto) {
 		return fmt.Errorf("invalid param backupMode %s", request.BackupMode)
 	}
+	if err = validateBackupFilter(request.Filter); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+func (mgr *BRManager) restoreFilterPreCheck(request cluster.RestoreExistClusterReq, record *backuprestore.BackupRecord) (*restoreFilter, error) {
+	backupFilter := structs.BackupFilter{
+		Databases: splitFilterNames(record.Databases),
+		Tables:    splitFilterNames(record.Tables),
+	}
+	if err := checkRestoreFilter(backupFilter, request.Filter); err != nil {
+		return nil, err
+	}
+
+	filter := &restoreFilter{
+		Filter:         request.Filter,
+		TargetDatabase: request.TargetDatabase,
+	}
+	if isFilterEmpty(filter.Filter) {
+		filter.Filter = backupFilter
+	}
+	if filter.TargetDatabase != "" {
+		if !validSchemaName(filter.TargetDatabase) {
+			return nil, fmt.Errorf("invalid target database name %s", filter.TargetDatabase)
+		}
+		source := singleDatabaseOfFilter(filter.Filter)
+		if source == "" {
+			return nil, fmt.Errorf("target database is only supported when restoring a single database")
+		}
+		if source == filter.TargetDatabase {
+			return nil, fmt.Errorf("target database %s is the same as the restored database", filter.TargetDatabase)
+		}
+	}
+	return filter, nil
+}
+
 func (mgr *BRManager) saveBackupStrategyPreCheck(ctx context.Context, request cluster.SaveBackupStrategyReq) error {
-	period := strings.Split(request.Strategy.Period, "-")
+	return CheckBackupStrategy(request.Strategy)
+}
+
+// CheckBackupStrategy
+// @Description: check period, backup date and filter of backup strategy before it is saved
+// @Parameter strategy
+// @return error
+func CheckBackupStrategy(strategy structs.BackupStrategy) error {
+	period := strings.Split(strategy.Period, "-")
 	if len(period) != 2 {
-		return fmt.Errorf("invalid param period, %s", request.Strategy.Period)
+		return fmt.Errorf("invalid param period, %s", strategy.Period)
 	}
 
 	starts := strings.Split(period[0], ":")
@@ -523,17 +737,20 @@ func (mgr *BRManager) saveBackupStrategyPreCheck(ctx context.Context, request cl
 		return fmt.Errorf("invalid param end hour, %s", err.Error())
 	}
 	if startHour > 23 || startHour < 0 || endHour > 23 || endHour < 0 || startHour >= endHour {
-		return fmt.Errorf("invalid param period, %s", request.Strategy.Period)
+		return fmt.Errorf("invalid param period, %s", strategy.Period)
 	}
 
-	if request.Strategy.BackupDate != "" {
-		backupDates := strings.Split(request.Strategy.BackupDate, ",")
+	if strategy.BackupDate != "" {
+		backupDates := strings.Split(strategy.BackupDate, ",")
 		for _, day := range backupDates {
 			if !checkWeekDayValid(day) {
 				return fmt.Errorf("backupDate contains invalid weekday, %s", day)
 			}
 		}
 	}
+	if err = validateBackupFilter(strategy.Filter); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -551,31 +768,31 @@ func (mgr *BRManager) checkFilePathExists(path string) bool {
 }
 
 func (mgr *BRManager) removeBackupFiles(ctx context.Context, record *backuprestore.BackupRecord) error {
-	if string(constants.StorageTypeS3) == record.StorageType {
-		configRW := models.GetConfigReaderWriter()
-		endpointCfg, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3Endpoint)
-		if err != nil || endpointCfg.ConfigValue == "" {
-			return fmt.Errorf("get and check conifg %s failed", constants.ConfigKeyBackupS3Endpoint)
-		}
-		akCfg, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3AccessKey)
-		if err != nil || akCfg.ConfigValue == "" {
-			return fmt.Errorf("get and check conifg %s failed", constants.ConfigKeyBackupS3AccessKey)
-		}
-		skCfg, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3SecretAccessKey)
-		if err != nil || skCfg.ConfigValue == "" {
-			return fmt.Errorf("get and check conifg %s failed", constants.ConfigKeyBackupS3SecretAccessKey)
+	if err := removeBackupLocations(ctx, record); err != nil {
+		framework.LogWithContext(ctx).Warnf("remove copies of backup %s failed, %s", record.ID, err.Error())
+	}
+	if string(constants.BackupModeImported) == record.BackupMode {
+		framework.LogWithContext(ctx).Infof("files of imported backup %s are kept in %s", record.ID, record.FilePath)
+		return nil
+	}
+	return removeStorageFiles(ctx, record.StorageType, record.FilePath, record.StorageTargetID)
+}
+
+// removeStorageFiles
+// @Description: remove backup files under the file path asynchronously
+// @Parameter ctx
+// @Parameter storageType
+// @Parameter filePath
+// @Parameter storageTargetID the backup storage of system config if empty
+// @return error
+func removeStorageFiles(ctx context.Context, storageType string, filePath string, storageTargetID string) error {
+	if string(constants.StorageTypeS3) == storageType {
+		s3Client, err := getS3Client(ctx, storageTargetID)
+		if err != nil {
+			return err
 		}
 		go func() {
-			endpoint := strings.TrimPrefix(endpointCfg.ConfigValue, "http://")
-			s3Client, err := minio.New(endpoint, &minio.Options{
-				Creds:  credentials.NewStaticV4(akCfg.ConfigValue, skCfg.ConfigValue, ""),
-				Secure: false,
-			})
-			if err != nil {
-				framework.LogWithContext(ctx).Warnf("create s3 client failed: %s", err.Error())
-				return
-			}
-			s3Addr := strings.SplitN(record.FilePath, "/", 2)
+			s3Addr := strings.SplitN(filePath, "/", 2)
 			if len(s3Addr) != 2 {
 				return
 			}
@@ -583,17 +800,47 @@ func (mgr *BRManager) removeBackupFiles(ctx context.Context, record *backupresto
 			objectChan := s3Client.ListObjects(ctx, s3Addr[0], minio.ListObjectsOptions{Recursive: true, Prefix: s3Addr[1]})
 			for object := range objectChan {
 				if err = s3Client.RemoveObject(context.TODO(), s3Addr[0], object.Key, minio.RemoveObjectOptions{ForceDelete: true}); err != nil {
-					framework.LogWithContext(ctx).Warnf("remove bucket %s failed: %v", record.FilePath, err)
+					framework.LogWithContext(ctx).Warnf("remove bucket %s failed: %v", filePath, err)
 				}
 			}
 		}()
 	} else {
 		go func() {
-			if err := os.RemoveAll(record.FilePath); err != nil {
-				framework.LogWithContext(ctx).Warnf("remove backup filePath %s, result %v", record.FilePath, err)
+			if err := os.RemoveAll(filePath); err != nil {
+				framework.LogWithContext(ctx).Warnf("remove backup filePath %s, result %v", filePath, err)
 				return
 			}
 		}()
 	}
 	return nil
 }
+
+// getS3Client
+// @Description: create client of s3 storage target, or the backup s3 storage of system config if storageTargetID is empty
+// @Parameter ctx
+// @Parameter storageTargetID
+// @return *minio.Client
+// @return error
+func getS3Client(ctx context.Context, storageTargetID string) (*minio.Client, error) {
+	s3Config, err := getS3Config(ctx, storageTargetID)
+	if err != nil {
+		return nil, err
+	}
+	return newS3Client(ctx, s3Config)
+}
+
+func newS3Client(ctx context.Context, s3Config *s3Config) (*minio.Client, error) {
+	if s3Config.Endpoint == "" || s3Config.AccessKey == "" || s3Config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("endpoint, access key and secret access key of s3 storage are required")
+	}
+	endpoint := strings.TrimPrefix(s3Config.Endpoint, "http://")
+	s3Client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(s3Config.AccessKey, s3Config.SecretAccessKey, ""),
+		Secure: false,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Warnf("create s3 client failed: %s", err.Error())
+		return nil, err
+	}
+	return s3Client, nil
+}
@@ -34,6 +34,7 @@ import (
 	workflow "github.com/pingcap/tiunimanager/workflow2"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestGetBRService(t *testing.T) {
@@ -64,6 +65,7 @@ func TestBRManager_BackupCluster_case1(t *testing.T) {
 	workflowService.EXPECT().Start(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	configService := mockconfig.NewMockReaderWriter(ctrl)
+	configService.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupEncryptionMethod).Return(&config.SystemConfig{}, nil).AnyTimes()
 	configService.EXPECT().GetConfig(gomock.Any(), gomock.Any()).Return(&config.SystemConfig{ConfigValue: string(constants.StorageTypeNFS)}, nil).AnyTimes()
 	models.SetConfigReaderWriter(configService)
 
@@ -107,6 +109,7 @@ func TestBRManager_BackupCluster_case2(t *testing.T) {
 	workflowService.EXPECT().Start(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	configService := mockconfig.NewMockReaderWriter(ctrl)
+	configService.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupEncryptionMethod).Return(&config.SystemConfig{}, nil).AnyTimes()
 	configService.EXPECT().GetConfig(gomock.Any(), gomock.Any()).Return(&config.SystemConfig{ConfigValue: string(constants.StorageTypeS3)}, nil).AnyTimes()
 	models.SetConfigReaderWriter(configService)
 
@@ -397,6 +400,86 @@ func TestBRManager_RestoreExistCluster_case3(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestBRManager_RestoreExistCluster_case4(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(&management.Cluster{
+		Entity: common.Entity{
+			ID:       "id-xxxx",
+			TenantId: "tid-xxx",
+		},
+	}, make([]*management.ClusterInstance, 0), make([]*management.DBUser, 0), nil).AnyTimes()
+	clusterRW.EXPECT().SetMaintenanceStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+	workflow.MockWorkFlowService(workflowService)
+	defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+	workflowService.EXPECT().RegisterWorkFlow(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	workflowService.EXPECT().CreateWorkFlow(gomock.Any(), gomock.Any(), gomock.Any(), constants.FlowPointInTimeRestoreCluster).Return("flow01", nil)
+	workflowService.EXPECT().InitContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	workflowService.EXPECT().Start(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	brService := mockbr.NewMockReaderWriter(ctrl)
+	brService.EXPECT().QueryLogBackupTasks(gomock.Any(), "test-cls", "").Return([]*backuprestore.LogBackupTask{mockLogBackupTask()}, nil)
+	brService.EXPECT().QueryBackupRecords(gomock.Any(), "test-cls", "", "", int64(0), int64(0), 1, gomock.Any()).Return(mockLogBackupRecords(), int64(4), nil)
+	brService.EXPECT().QueryBackupRecords(gomock.Any(), "test-cls", "", "", int64(0), int64(0), 2, gomock.Any()).Return(nil, int64(4), nil)
+	models.SetBRReaderWriter(brService)
+
+	service := GetBRService()
+	resp, err := service.RestoreExistCluster(context.TODO(), cluster.RestoreExistClusterReq{
+		ClusterID: "test-cls",
+		PointInTime: cluster.PointInTimeRestoreTarget{
+			RestoreTime: logBackupBaseTime.Add(2 * time.Hour).Unix(),
+		},
+	}, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "flow01", resp.WorkFlowID)
+}
+
+func TestBRManager_RestoreExistCluster_case5(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(&management.Cluster{
+		Entity: common.Entity{
+			ID:       "id-xxxx",
+			TenantId: "tid-xxx",
+		},
+	}, make([]*management.ClusterInstance, 0), make([]*management.DBUser, 0), nil).AnyTimes()
+
+	brService := mockbr.NewMockReaderWriter(ctrl)
+	brService.EXPECT().GetBackupRecord(gomock.Any(), gomock.Any()).Return(&backuprestore.BackupRecord{
+		Entity: common.Entity{
+			ID: "xxx",
+		},
+		Databases: "db1",
+	}, nil).AnyTimes()
+	models.SetBRReaderWriter(brService)
+
+	service := GetBRService()
+	_, err := service.RestoreExistCluster(context.TODO(), cluster.RestoreExistClusterReq{
+		ClusterID: "test-cls",
+		BackupID:  "xxx",
+		Filter:    structs.BackupFilter{Databases: []string{"db2"}},
+	}, false)
+	assert.NotNil(t, err)
+
+	_, err = service.RestoreExistCluster(context.TODO(), cluster.RestoreExistClusterReq{
+		ClusterID: "test-cls",
+		PointInTime: cluster.PointInTimeRestoreTarget{
+			RestoreTSO: "434146621381115905",
+		},
+		TargetDatabase: "db1_restored",
+	}, false)
+	assert.NotNil(t, err)
+}
+
 func TestBRManager_DeleteBackupRecords_case1(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -412,6 +495,7 @@ func TestBRManager_DeleteBackupRecords_case1(t *testing.T) {
 	brRW := mockbr.NewMockReaderWriter(ctrl)
 	brRW.EXPECT().QueryBackupRecords(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(records, int64(1), nil)
 	brRW.EXPECT().QueryBackupRecords(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(make([]*backuprestore.BackupRecord, 0), int64(0), nil)
+	brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"record-xxx"}).Return(make([]*backuprestore.BackupLocation, 0), nil)
 	brRW.EXPECT().DeleteBackupRecord(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	models.SetBRReaderWriter(brRW)
 
@@ -438,6 +522,7 @@ func TestBRManager_DeleteBackupRecords_case2(t *testing.T) {
 	brRW := mockbr.NewMockReaderWriter(ctrl)
 	brRW.EXPECT().QueryBackupRecords(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(records, int64(1), nil)
 	brRW.EXPECT().QueryBackupRecords(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(make([]*backuprestore.BackupRecord, 0), int64(0), nil)
+	brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"record-xxx"}).Return(make([]*backuprestore.BackupLocation, 0), nil)
 	brRW.EXPECT().DeleteBackupRecord(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	models.SetBRReaderWriter(brRW)
 
@@ -453,6 +538,87 @@ func TestBRManager_DeleteBackupRecords_case2(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestBRManager_PurgeExpiredBackupRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		records := []*backuprestore.BackupRecord{
+			{
+				Entity:      common.Entity{ID: "record-new", Status: string(constants.ClusterBackupFinished)},
+				StorageType: "nfs",
+				BackupMode:  string(constants.BackupModeAuto),
+				FilePath:    "./testdata",
+				StartTime:   time.Now(),
+			},
+			{
+				Entity:      common.Entity{ID: "record-old", Status: string(constants.ClusterBackupFinished)},
+				StorageType: "nfs",
+				BackupMode:  string(constants.BackupModeAuto),
+				FilePath:    "./testdata",
+				StartTime:   time.Now().AddDate(0, 0, -1),
+			},
+			{
+				Entity:      common.Entity{ID: "record-manual", Status: string(constants.ClusterBackupFinished)},
+				StorageType: "nfs",
+				BackupMode:  string(constants.BackupModeManual),
+				FilePath:    "./testdata",
+				StartTime:   time.Now().AddDate(0, 0, -2),
+			},
+		}
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStrategy(gomock.Any(), "cls-xxxx").Return(&backuprestore.BackupStrategy{ClusterID: "cls-xxxx", KeepLast: 1}, nil)
+		brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-xxxx", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 1, gomock.Any()).Return(records, int64(3), nil)
+		brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-xxxx", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 2, gomock.Any()).Return(make([]*backuprestore.BackupRecord, 0), int64(3), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-xxxx", "").Return(make([]*backuprestore.LogBackupTask, 0), nil)
+		brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"record-old"}).Return(make([]*backuprestore.BackupLocation, 0), nil)
+		brRW.EXPECT().DeleteBackupRecord(gomock.Any(), "record-old").Return(nil)
+		models.SetBRReaderWriter(brRW)
+
+		purged, err := GetBRService().PurgeExpiredBackupRecords(context.TODO(), "cls-xxxx")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"record-old"}, purged)
+	})
+	t.Run("no retention", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStrategy(gomock.Any(), "cls-xxxx").Return(&backuprestore.BackupStrategy{ClusterID: "cls-xxxx"}, nil)
+		models.SetBRReaderWriter(brRW)
+
+		purged, err := GetBRService().PurgeExpiredBackupRecords(context.TODO(), "cls-xxxx")
+		assert.NoError(t, err)
+		assert.Empty(t, purged)
+	})
+	t.Run("delete failed", func(t *testing.T) {
+		records := []*backuprestore.BackupRecord{
+			{
+				Entity:      common.Entity{ID: "record-new", Status: string(constants.ClusterBackupFinished)},
+				StorageType: "nfs",
+				BackupMode:  string(constants.BackupModeAuto),
+				FilePath:    "./testdata",
+				StartTime:   time.Now(),
+			},
+			{
+				Entity:      common.Entity{ID: "record-old", Status: string(constants.ClusterBackupFinished)},
+				StorageType: "nfs",
+				BackupMode:  string(constants.BackupModeAuto),
+				FilePath:    "./testdata",
+				StartTime:   time.Now().AddDate(0, 0, -1),
+			},
+		}
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStrategy(gomock.Any(), "cls-xxxx").Return(&backuprestore.BackupStrategy{ClusterID: "cls-xxxx", KeepLast: 1}, nil)
+		brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-xxxx", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 1, gomock.Any()).Return(records, int64(2), nil)
+		brRW.EXPECT().QueryBackupRecords(gomock.Any(), "cls-xxxx", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 2, gomock.Any()).Return(make([]*backuprestore.BackupRecord, 0), int64(2), nil)
+		brRW.EXPECT().QueryLogBackupTasks(gomock.Any(), "cls-xxxx", "").Return(make([]*backuprestore.LogBackupTask, 0), nil)
+		brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"record-old"}).Return(make([]*backuprestore.BackupLocation, 0), nil)
+		brRW.EXPECT().DeleteBackupRecord(gomock.Any(), "record-old").Return(errors.New("delete failed"))
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().PurgeExpiredBackupRecords(context.TODO(), "cls-xxxx")
+		assert.Error(t, err)
+	})
+}
+
 func TestBRManager_GetBackupStrategy_case1(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -463,6 +629,7 @@ func TestBRManager_GetBackupStrategy_case1(t *testing.T) {
 		BackupDate: "Monday,Friday",
 		StartHour:  0,
 		EndHour:    1,
+		KeepDaily:  7,
 	}, nil)
 	models.SetBRReaderWriter(brRW)
 
@@ -470,6 +637,7 @@ func TestBRManager_GetBackupStrategy_case1(t *testing.T) {
 	resp, err := service.GetBackupStrategy(context.TODO(), cluster.GetBackupStrategyReq{})
 	assert.Nil(t, err)
 	assert.Equal(t, "0:00-1:00", resp.Strategy.Period)
+	assert.Equal(t, uint32(7), resp.Strategy.Retention.KeepDaily)
 }
 
 func TestBRManager_GetBackupStrategy_case2(t *testing.T) {
@@ -491,6 +659,7 @@ func TestBRManager_DeleteBackupStrategy_case1(t *testing.T) {
 
 	brRW := mockbr.NewMockReaderWriter(ctrl)
 	brRW.EXPECT().DeleteBackupStrategy(gomock.Any(), gomock.Any()).Return(nil)
+	brRW.EXPECT().DeleteBackupSchedules(gomock.Any(), gomock.Any(), "").Return(nil)
 	models.SetBRReaderWriter(brRW)
 
 	service := GetBRService()
@@ -511,6 +680,20 @@ func TestBRManager_DeleteBackupStrategy_case2(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestBRManager_DeleteBackupStrategy_case3(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().DeleteBackupStrategy(gomock.Any(), gomock.Any()).Return(nil)
+	brRW.EXPECT().DeleteBackupSchedules(gomock.Any(), gomock.Any(), "").Return(errors.New("error"))
+	models.SetBRReaderWriter(brRW)
+
+	service := GetBRService()
+	_, err := service.DeleteBackupStrategy(context.TODO(), cluster.DeleteBackupStrategyReq{})
+	assert.NotNil(t, err)
+}
+
 func TestBRManager_SaveBackupStrategy_case1(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -567,6 +750,27 @@ func TestBRManager_SaveBackupStrategy_case3(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestBRManager_SaveBackupStrategy_case4(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(nil, errors.New("not found"))
+	models.SetBRReaderWriter(brRW)
+
+	service := GetBRService()
+	_, err := service.SaveBackupStrategy(context.TODO(), cluster.SaveBackupStrategyReq{
+		ClusterID: "cls-xxxx",
+		Strategy: structs.BackupStrategy{
+			ClusterID:    "cls-xxxx",
+			BackupDate:   "Monday",
+			Period:       "0:00-1:00",
+			CopyTargetID: "target-xxx",
+		},
+	})
+	assert.NotNil(t, err)
+}
+
 func TestBRManager_QueryClusterBackupRecords(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -580,10 +784,20 @@ func TestBRManager_QueryClusterBackupRecords(t *testing.T) {
 	}
 	brRW := mockbr.NewMockReaderWriter(ctrl)
 	brRW.EXPECT().QueryBackupRecords(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(records, int64(1), nil)
+	brRW.EXPECT().QueryBackupLocations(gomock.Any(), []string{"record-xxx"}).Return([]*backuprestore.BackupLocation{
+		{
+			Entity:   common.Entity{ID: "location-xxx", Status: string(constants.BackupLocationAvailable)},
+			BackupID: "record-xxx",
+			TargetID: "target-xxx",
+			FilePath: "/offsite/cls-xxx/backup",
+		},
+	}, nil)
 	models.SetBRReaderWriter(brRW)
 
 	service := GetBRService()
 	resp, _, err := service.QueryClusterBackupRecords(context.TODO(), cluster.QueryBackupRecordsReq{})
 	assert.Nil(t, err)
 	assert.Equal(t, records[0].FilePath, resp.BackupRecords[0].FilePath)
+	assert.Equal(t, 1, len(resp.BackupRecords[0].Locations))
+	assert.Equal(t, "target-xxx", resp.BackupRecords[0].Locations[0].TargetID)
 }
@@ -0,0 +1,264 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	dbSql "database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/bytes"
+	"github.com/minio/minio-go/v7"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/library/framework"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	wfModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/util/api/tidb/sql"
+)
+
+type brJobType string
+
+const (
+	brJobBackup  brJobType = "backup"
+	brJobRestore brJobType = "restore"
+)
+
+// brProgressInterval interval of polling progress of running BR job
+var brProgressInterval = 10 * time.Second
+
+// progressRecordStep progress is recorded on workflow node every 10 percent, to keep node result short
+const progressRecordStep = 10
+
+type brProgress struct {
+	Progress         float32
+	ProcessedSize    uint64
+	EstimatedEndTime time.Time
+}
+
+// brProgressWatcher
+// @Description: poll progress of a running BACKUP or RESTORE statement,
+// publish it on backup record and workflow node, and kill the statement if it makes no progress for stuckTimeout
+type brProgressWatcher struct {
+	ctx          context.Context
+	jobType      brJobType
+	node         *wfModel.WorkFlowNode
+	record       *backuprestore.BackupRecord
+	dbConnParam  sql.DbConnParam
+	stuckTimeout time.Duration
+
+	startTime       time.Time
+	progress        float32
+	progressTime    time.Time // last time progress advanced
+	recordedPercent int
+	stuck           bool
+	stopCh          chan struct{}
+	doneCh          chan struct{}
+}
+
+func newBRProgressWatcher(ctx context.Context, jobType brJobType, node *wfModel.WorkFlowNode,
+	record *backuprestore.BackupRecord, dbConnParam sql.DbConnParam) *brProgressWatcher {
+	return &brProgressWatcher{
+		ctx:          ctx,
+		jobType:      jobType,
+		node:         node,
+		record:       record,
+		dbConnParam:  dbConnParam,
+		stuckTimeout: getBRStuckTimeout(ctx),
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+}
+
+func getBRStuckTimeout(ctx context.Context) time.Duration {
+	return time.Duration(platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyBRStuckTimeout, constants.DefaultBRStuckTimeout)) * time.Minute
+}
+
+func (w *brProgressWatcher) start() {
+	w.startTime = time.Now()
+	w.progressTime = w.startTime
+	go func() {
+		defer close(w.doneCh)
+		ticker := time.NewTicker(brProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				w.poll()
+			}
+		}
+	}()
+}
+
+// stop
+// @Description: stop polling and wait for the last poll, the node is only touched by caller afterwards
+// @return bool true if the job has been killed for making no progress
+func (w *brProgressWatcher) stop() bool {
+	close(w.stopCh)
+	<-w.doneCh
+	return w.stuck
+}
+
+func (w *brProgressWatcher) poll() {
+	request := sql.ShowBackupReq{DbConnParameter: w.dbConnParam, Destination: w.record.FilePath}
+	var resp sql.ShowBackupResp
+	var err error
+	if w.jobType == brJobBackup {
+		resp, err = sql.ExecShowBackupSQL(w.ctx, request)
+	} else {
+		resp, err = sql.ExecShowRestoreSQL(w.ctx, request)
+	}
+	if err == dbSql.ErrNoRows {
+		framework.LogWithContext(w.ctx).Infof("%s job of %s is not running yet", w.jobType, w.record.FilePath)
+		return
+	} else if err != nil {
+		framework.LogWithContext(w.ctx).Warnf("show %s job of %s failed, %s", w.jobType, w.record.FilePath, err.Error())
+		return
+	}
+
+	var processedSize uint64
+	if w.jobType == brJobBackup {
+		if processedSize, err = backupStorageSize(w.ctx, w.record); err != nil {
+			framework.LogWithContext(w.ctx).Warnf("get size of backup %s failed, %s", w.record.FilePath, err.Error())
+		}
+	} else {
+		processedSize = uint64(float64(w.record.Size) * float64(resp.Progress) / 100)
+	}
+
+	if w.update(resp.Progress, processedSize, time.Now()) {
+		w.cancel(resp.Connection)
+	}
+}
+
+// update
+// @Description: publish progress and check whether the job is stuck
+// @Parameter progress percent complete reported by BR
+// @Parameter processedSize
+// @Parameter now
+// @return bool true if the job makes no progress for stuckTimeout and should be killed
+func (w *brProgressWatcher) update(progress float32, processedSize uint64, now time.Time) bool {
+	if progress > w.progress {
+		w.progress = progress
+		w.progressTime = now
+	}
+	w.publish(brProgress{
+		Progress:         w.progress,
+		ProcessedSize:    processedSize,
+		EstimatedEndTime: estimateEndTime(w.startTime, w.progress, now),
+	})
+	if w.stuckTimeout <= 0 || w.stuck || now.Sub(w.progressTime) < w.stuckTimeout {
+		return false
+	}
+	w.stuck = true
+	framework.LogWithContext(w.ctx).Errorf("%s job of %s made no progress for %s, stuck at %.1f%%",
+		w.jobType, w.record.FilePath, w.stuckTimeout, w.progress)
+	w.recordOnNode(fmt.Sprintf("%s made no progress for %s, stuck at %.1f%%, cancel it", w.jobType, w.stuckTimeout, w.progress))
+	return true
+}
+
+func (w *brProgressWatcher) publish(progress brProgress) {
+	if w.jobType == brJobBackup {
+		err := models.GetBRReaderWriter().UpdateBackupProgress(w.ctx, w.record.ID, progress.Progress, progress.ProcessedSize, progress.EstimatedEndTime)
+		if err != nil {
+			framework.LogWithContext(w.ctx).Warnf("update progress of backup record %s failed, %s", w.record.ID, err.Error())
+		}
+	}
+
+	percent := int(progress.Progress)
+	if percent < w.recordedPercent+progressRecordStep && (percent < 100 || w.recordedPercent >= 100) {
+		return
+	}
+	w.recordedPercent = percent - percent%progressRecordStep
+	message := fmt.Sprintf("%s progress: %.1f%%, processed %s", w.jobType, progress.Progress, bytes.Format(int64(progress.ProcessedSize)))
+	if !progress.EstimatedEndTime.IsZero() {
+		message = fmt.Sprintf("%s, estimated end time %s", message, progress.EstimatedEndTime.Format(time.RFC3339))
+	}
+	w.recordOnNode(message)
+}
+
+// recordOnNode
+// @Description: record message on workflow node and persist it, nodes are only saved by workflow when finished
+func (w *brProgressWatcher) recordOnNode(message string) {
+	w.node.Record(message)
+	if err := models.GetWorkFlowReaderWriter().UpdateWorkFlowNode(w.ctx, w.node); err != nil {
+		framework.LogWithContext(w.ctx).Warnf("update workflow node %s failed, %s", w.node.ID, err.Error())
+	}
+}
+
+func (w *brProgressWatcher) cancel(connection int) {
+	err := sql.CancelBackupSQL(w.ctx, sql.CancelBackupReq{DbConnParameter: w.dbConnParam, Connection: connection})
+	if err != nil {
+		framework.LogWithContext(w.ctx).Errorf("cancel stuck %s job of %s failed, %s", w.jobType, w.record.FilePath, err.Error())
+	}
+}
+
+// estimateEndTime
+// @Description: estimate end time assuming the job goes on at its average speed, zero if unknown
+func estimateEndTime(startTime time.Time, progress float32, now time.Time) time.Time {
+	if progress <= 0 {
+		return time.Time{}
+	}
+	if progress >= 100 {
+		return now
+	}
+	elapsed := now.Sub(startTime)
+	return now.Add(time.Duration(float64(elapsed) * float64(100-progress) / float64(progress)))
+}
+
+// backupStorageSize
+// @Description: get size of files written to backup storage
+// @Parameter ctx
+// @Parameter record
+// @return uint64 bytes
+// @return error
+func backupStorageSize(ctx context.Context, record *backuprestore.BackupRecord) (uint64, error) {
+	var size uint64
+	if string(constants.StorageTypeS3) == record.StorageType {
+		s3Client, err := getS3Client(ctx, record.StorageTargetID)
+		if err != nil {
+			return 0, err
+		}
+		s3Addr := strings.SplitN(record.FilePath, "/", 2)
+		if len(s3Addr) != 2 {
+			return 0, fmt.Errorf("invalid s3 path %s", record.FilePath)
+		}
+		for object := range s3Client.ListObjects(ctx, s3Addr[0], minio.ListObjectsOptions{Recursive: true, Prefix: s3Addr[1]}) {
+			if object.Err != nil {
+				return 0, object.Err
+			}
+			size += uint64(object.Size)
+		}
+		return size, nil
+	}
+
+	err := filepath.Walk(record.FilePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
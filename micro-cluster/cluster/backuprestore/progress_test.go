@@ -0,0 +1,179 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockworkflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_estimateEndTime(t *testing.T) {
+	now := time.Now()
+	assert.True(t, estimateEndTime(now.Add(-time.Hour), 0, now).IsZero())
+	assert.Equal(t, now, estimateEndTime(now.Add(-time.Hour), 100, now))
+	assert.Equal(t, now.Add(3*time.Hour), estimateEndTime(now.Add(-time.Hour), 25, now))
+}
+
+func Test_getBRStuckTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBRStuckTimeout).Return(&config.SystemConfig{ConfigValue: "10"}, nil)
+		assert.Equal(t, 10*time.Minute, getBRStuckTimeout(context.TODO()))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBRStuckTimeout).Return(&config.SystemConfig{ConfigValue: "0"}, nil)
+		assert.Equal(t, time.Duration(0), getBRStuckTimeout(context.TODO()))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBRStuckTimeout).Return(&config.SystemConfig{ConfigValue: "-1"}, nil)
+		assert.Equal(t, 30*time.Minute, getBRStuckTimeout(context.TODO()))
+	})
+	t.Run("get config failed", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBRStuckTimeout).Return(nil, errors.New("not found"))
+		assert.Equal(t, 30*time.Minute, getBRStuckTimeout(context.TODO()))
+	})
+}
+
+func TestBRProgressWatcher_update(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	flowRW := models.GetWorkFlowReaderWriter()
+	defer models.SetWorkFlowReaderWriter(flowRW)
+
+	now := time.Now()
+	record := &backuprestore.BackupRecord{Entity: common.Entity{ID: "backup01"}, FilePath: "/tmp/backup01"}
+
+	t.Run("backup", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().UpdateBackupProgress(gomock.Any(), "backup01", float32(5), uint64(100), now.Add(19*time.Minute)).Return(nil)
+		brRW.EXPECT().UpdateBackupProgress(gomock.Any(), "backup01", float32(12.5), uint64(300), gomock.Any()).Return(nil)
+		brRW.EXPECT().UpdateBackupProgress(gomock.Any(), "backup01", float32(14), uint64(400), gomock.Any()).Return(errors.New("update failed"))
+		flowRW := mockworkflow.NewMockReaderWriter(ctrl)
+		models.SetWorkFlowReaderWriter(flowRW)
+		flowRW.EXPECT().UpdateWorkFlowNode(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		node := &workflowModel.WorkFlowNode{}
+		watcher := &brProgressWatcher{ctx: context.TODO(), jobType: brJobBackup, node: node, record: record,
+			startTime: now.Add(-time.Minute), progressTime: now.Add(-time.Minute), stuckTimeout: time.Hour}
+		assert.False(t, watcher.update(5, 100, now))
+		assert.Empty(t, node.Result)
+		assert.False(t, watcher.update(12.5, 300, now.Add(time.Minute)))
+		assert.Contains(t, node.Result, "backup progress: 12.5%")
+		assert.Equal(t, 10, watcher.recordedPercent)
+		assert.False(t, watcher.update(14, 400, now.Add(2*time.Minute)))
+		assert.NotContains(t, node.Result, "14.0%")
+	})
+	t.Run("restore stuck", func(t *testing.T) {
+		flowRW := mockworkflow.NewMockReaderWriter(ctrl)
+		models.SetWorkFlowReaderWriter(flowRW)
+		flowRW.EXPECT().UpdateWorkFlowNode(gomock.Any(), gomock.Any()).Return(errors.New("update failed")).Times(2)
+
+		node := &workflowModel.WorkFlowNode{}
+		watcher := &brProgressWatcher{ctx: context.TODO(), jobType: brJobRestore, node: node, record: record,
+			startTime: now, progressTime: now, stuckTimeout: 10 * time.Minute}
+		assert.False(t, watcher.update(20, 200, now.Add(5*time.Minute)))
+		assert.Contains(t, node.Result, "restore progress: 20.0%")
+		assert.False(t, watcher.update(20, 200, now.Add(14*time.Minute)))
+		assert.True(t, watcher.update(20, 200, now.Add(16*time.Minute)))
+		assert.Contains(t, node.Result, "stuck at 20.0%")
+		assert.True(t, watcher.stuck)
+		// cancelled only once
+		assert.False(t, watcher.update(20, 200, now.Add(30*time.Minute)))
+	})
+	t.Run("stuck detection disabled", func(t *testing.T) {
+		node := &workflowModel.WorkFlowNode{}
+		watcher := &brProgressWatcher{ctx: context.TODO(), jobType: brJobRestore, node: node, record: record,
+			startTime: now, progressTime: now}
+		assert.False(t, watcher.update(0, 0, now.Add(24*time.Hour)))
+		assert.Empty(t, node.Result)
+	})
+}
+
+func TestBRProgressWatcher_publishCompleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	flowRW := models.GetWorkFlowReaderWriter()
+	defer models.SetWorkFlowReaderWriter(flowRW)
+
+	mockFlowRW := mockworkflow.NewMockReaderWriter(ctrl)
+	models.SetWorkFlowReaderWriter(mockFlowRW)
+	mockFlowRW.EXPECT().UpdateWorkFlowNode(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	node := &workflowModel.WorkFlowNode{}
+	watcher := &brProgressWatcher{ctx: context.TODO(), jobType: brJobRestore, node: node, record: &backuprestore.BackupRecord{}}
+	watcher.publish(brProgress{Progress: 95})
+	watcher.publish(brProgress{Progress: 100})
+	watcher.publish(brProgress{Progress: 100})
+	assert.Contains(t, node.Result, "restore progress: 95.0%")
+	assert.Contains(t, node.Result, "restore progress: 100.0%")
+}
+
+func TestBRProgressWatcher_startStop(t *testing.T) {
+	interval := brProgressInterval
+	brProgressInterval = time.Millisecond
+	defer func() {
+		brProgressInterval = interval
+	}()
+
+	watcher := &brProgressWatcher{ctx: context.TODO(), jobType: brJobRestore, node: &workflowModel.WorkFlowNode{},
+		record: &backuprestore.BackupRecord{FilePath: "/tmp/backup01"}, stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+	watcher.start()
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, watcher.stop())
+}
+
+func Test_backupStorageSize(t *testing.T) {
+	dir, err := os.MkdirTemp("", "backup-size")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "1"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "backupmeta"), make([]byte, 100), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "1", "1.sst"), make([]byte, 1000), 0644))
+
+	size, err := backupStorageSize(context.TODO(), &backuprestore.BackupRecord{StorageType: string(constants.StorageTypeNFS), FilePath: dir})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1100), size)
+
+	_, err = backupStorageSize(context.TODO(), &backuprestore.BackupRecord{StorageType: string(constants.StorageTypeNFS), FilePath: filepath.Join(dir, "none")})
+	assert.Error(t, err)
+}
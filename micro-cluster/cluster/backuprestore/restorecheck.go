@@ -0,0 +1,457 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	wfModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/util/api/pd"
+	"github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+)
+
+// names of pre-restore check items
+const (
+	restoreCheckVersion       = "version"
+	restoreCheckCollation     = "collation"
+	restoreCheckCapacity      = "capacity"
+	restoreCheckTableConflict = "tableConflict"
+)
+
+// newCollationDefaultVersion new collation is enabled by default for clusters bootstrapped since this version
+const newCollationDefaultVersion = "v6.0.0"
+
+// maxReportedConflicts max conflicting tables listed in the message of table conflict check
+const maxReportedConflicts = 10
+
+// restoreCheckFacts facts of the backup and the target cluster compared by pre-restore checks,
+// a check is skipped if facts it needs are unknown
+type restoreCheckFacts struct {
+	SourceVersion      string
+	SourceNewCollation *bool
+	BackupSize         uint64
+	BackupTables       []string // in format of db.table, nil if unknown
+
+	TargetVersion      string
+	TargetNewCollation *bool
+	TargetAvailable    uint64   // free capacity of TiKV stores in bytes
+	TargetReplicas     uint64   // capacity of target is unknown if 0
+	TargetTables       []string // in format of db.table, nil if unknown
+
+	CompressionFactor int // capacity check is disabled if 0
+}
+
+func (mgr *BRManager) CheckRestore(ctx context.Context, request cluster.CheckRestoreReq) (resp cluster.CheckRestoreResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin CheckRestore, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End CheckRestore")
+
+	record, err := models.GetBRReaderWriter().GetBackupRecord(ctx, request.BackupID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup record %s failed, %s", request.BackupID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_RECORD_QUERY_FAILED, fmt.Sprintf("get backup record %s failed, %s", request.BackupID, err.Error()), err)
+	}
+	if string(constants.ClusterBackupFinished) != record.Status {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_RECORD_INVALID, "backup %s is not finished", request.BackupID)
+	}
+	filter, err := mgr.restoreFilterPreCheck(cluster.RestoreExistClusterReq{Filter: request.Filter, TargetDatabase: request.TargetDatabase}, record)
+	if err != nil {
+		return resp, errors.WrapError(errors.TIUNIMANAGER_PARAMETER_INVALID, fmt.Sprintf("restore filter precheck failed, %s", err.Error()), err)
+	}
+	if request.LocationID != "" {
+		if record, err = restoreFromLocation(ctx, record, request.LocationID); err != nil {
+			return resp, err
+		}
+	}
+
+	var clusterMeta *meta.ClusterMeta
+	if request.ClusterID != "" {
+		clusterMeta, err = meta.Get(ctx, request.ClusterID)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+		}
+	} else if request.TargetVersion == "" {
+		return resp, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "target version is required if cluster id is empty")
+	}
+
+	resp.Items = evaluateRestoreChecks(collectRestoreCheckFacts(ctx, record, clusterMeta, request.TargetVersion), filter)
+	resp.Passed = len(failedRestoreChecks(resp.Items)) == 0
+	return resp, nil
+}
+
+// checkRestore
+// @Description: the first node of restore workflows, the restore is not started if any check failed
+func checkRestore(node *wfModel.WorkFlowNode, ctx *workflow.FlowContext) error {
+	framework.LogWithContext(ctx).Info("begin checkRestore")
+	defer framework.LogWithContext(ctx).Info("end checkRestore")
+
+	var record backuprestore.BackupRecord
+	var meta meta.ClusterMeta
+	var filter restoreFilter
+	if err := ctx.GetData(contextBackupRecordKey, &record); err != nil {
+		return err
+	}
+	if err := ctx.GetData(contextClusterMetaKey, &meta); err != nil {
+		return err
+	}
+	if err := ctx.GetData(contextRestoreFilterKey, &filter); err != nil {
+		return err
+	}
+
+	items := evaluateRestoreChecks(collectRestoreCheckFacts(ctx, &record, &meta, ""), &filter)
+	for _, item := range items {
+		node.Record(fmt.Sprintf("restore check %s %s: %s ", item.Name, item.Status, item.Message))
+	}
+	if failed := failedRestoreChecks(items); len(failed) > 0 {
+		return errors.NewErrorf(errors.TIUNIMANAGER_RESTORE_CHECK_FAILED, "restore check %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func failedRestoreChecks(items []structs.RestoreCheckItem) []string {
+	failed := make([]string, 0)
+	for _, item := range items {
+		if string(constants.RestoreCheckFailed) == item.Status {
+			failed = append(failed, item.Name)
+		}
+	}
+	return failed
+}
+
+// collectRestoreCheckFacts
+// @Description: collect facts of the backup and the target cluster, facts failed to collect are left unknown
+// @Parameter ctx
+// @Parameter record
+// @Parameter clusterMeta target cluster, nil for a new cluster of targetVersion
+// @Parameter targetVersion
+// @return *restoreCheckFacts
+func collectRestoreCheckFacts(ctx context.Context, record *backuprestore.BackupRecord, clusterMeta *meta.ClusterMeta, targetVersion string) *restoreCheckFacts {
+	facts := &restoreCheckFacts{
+		SourceVersion:     record.ClusterVersion,
+		BackupSize:        record.Size,
+		TargetVersion:     targetVersion,
+		CompressionFactor: platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyRestoreCompressionFactor, constants.DefaultRestoreCompressionFactor),
+	}
+
+	if record.ClusterID != "" {
+		if sourceMeta, err := meta.Get(ctx, record.ClusterID); err != nil {
+			framework.LogWithContext(ctx).Warnf("load source cluster meta %s failed, %s", record.ClusterID, err.Error())
+		} else {
+			if facts.SourceVersion == "" {
+				facts.SourceVersion = sourceMeta.Cluster.Version
+			}
+			if info, err := queryRestoreTargetInfo(ctx, sourceMeta); err != nil {
+				framework.LogWithContext(ctx).Warnf("query new collation of source cluster %s failed, %s", record.ClusterID, err.Error())
+			} else {
+				facts.SourceNewCollation = &info.NewCollationEnabled
+			}
+		}
+	}
+
+	// tables can not be listed from encrypted backupmeta
+	if record.EncryptionKeyID == "" {
+		if store, err := newBackupFileStore(ctx, record.StorageType, record.FilePath, record.StorageTargetID); err != nil {
+			framework.LogWithContext(ctx).Warnf("open storage of backup %s failed, %s", record.ID, err.Error())
+		} else if info, err := readBackupMeta(ctx, store); err != nil {
+			framework.LogWithContext(ctx).Warnf("read backupmeta of backup %s failed, %s", record.ID, err.Error())
+		} else {
+			facts.BackupTables = info.Tables
+		}
+	}
+
+	if clusterMeta == nil {
+		// new clusters are bootstrapped with the default setting of their version
+		if enabled, err := meta.CompareTiDBVersion(targetVersion, newCollationDefaultVersion); err == nil {
+			facts.TargetNewCollation = &enabled
+		}
+		return facts
+	}
+
+	facts.TargetVersion = clusterMeta.Cluster.Version
+	if info, err := queryRestoreTargetInfo(ctx, clusterMeta); err != nil {
+		framework.LogWithContext(ctx).Warnf("query tables of cluster %s failed, %s", clusterMeta.Cluster.ID, err.Error())
+	} else {
+		facts.TargetNewCollation = &info.NewCollationEnabled
+		facts.TargetTables = info.Tables
+	}
+	if available, replicas, err := queryTiKVCapacity(ctx, clusterMeta); err != nil {
+		framework.LogWithContext(ctx).Warnf("query capacity of cluster %s failed, %s", clusterMeta.Cluster.ID, err.Error())
+	} else {
+		facts.TargetAvailable, facts.TargetReplicas = available, replicas
+	}
+	return facts
+}
+
+func queryRestoreTargetInfo(ctx context.Context, clusterMeta *meta.ClusterMeta) (sql.RestoreTargetInfo, error) {
+	tidbServers := clusterMeta.GetClusterConnectAddresses()
+	if len(tidbServers) == 0 {
+		return sql.RestoreTargetInfo{}, fmt.Errorf("get tidb servers from meta result empty")
+	}
+	tidbUserInfo, err := clusterMeta.GetDBUserNamePassword(ctx, constants.DBUserBackupRestore)
+	if err != nil {
+		return sql.RestoreTargetInfo{}, err
+	}
+	return sql.QueryRestoreTargetInfo(ctx, sql.DbConnParam{
+		Username: tidbUserInfo.Name,
+		Password: tidbUserInfo.Password.Val,
+		IP:       tidbServers[0].IP,
+		Port:     strconv.Itoa(tidbServers[0].Port),
+	})
+}
+
+// queryTiKVCapacity
+// @Description: query free capacity of TiKV stores and max replicas from PD
+// @Parameter ctx
+// @Parameter clusterMeta
+// @return available bytes available in TiKV stores which are up
+// @return replicas
+// @return err
+func queryTiKVCapacity(ctx context.Context, clusterMeta *meta.ClusterMeta) (available uint64, replicas uint64, err error) {
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return 0, 0, errors.NewError(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster not found pd instance")
+	}
+	showReq := cluster.ApiShowConfigReq{
+		InstanceHost: pdAddress[0].IP,
+		InstancePort: uint(pdAddress[0].Port),
+		Headers:      map[string]string{},
+	}
+
+	storesContent, err := pd.ApiService.ShowStores(ctx, showReq)
+	if err != nil {
+		return 0, 0, err
+	}
+	stores := struct {
+		Stores []struct {
+			Store struct {
+				StateName string `json:"state_name"`
+				Labels    []struct {
+					Key   string `json:"key"`
+					Value string `json:"value"`
+				} `json:"labels"`
+			} `json:"store"`
+			Status struct {
+				Available string `json:"available"`
+			} `json:"status"`
+		} `json:"stores"`
+	}{}
+	if err = json.Unmarshal(storesContent, &stores); err != nil {
+		return 0, 0, errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, fmt.Sprintf("parse stores info error: %s", err.Error()), err)
+	}
+	for _, store := range stores.Stores {
+		if store.Store.StateName != "Up" {
+			continue
+		}
+		tiflash := false
+		for _, label := range store.Store.Labels {
+			tiflash = tiflash || (label.Key == "engine" && label.Value == "tiflash")
+		}
+		if tiflash {
+			continue
+		}
+		size, err := parseStoreSize(store.Status.Available)
+		if err != nil {
+			return 0, 0, err
+		}
+		available += size
+	}
+
+	configContent, err := pd.ApiService.ShowConfig(ctx, showReq)
+	if err != nil {
+		return 0, 0, err
+	}
+	config := struct {
+		Replication struct {
+			MaxReplicas uint64 `json:"max-replicas"`
+		} `json:"replication"`
+	}{}
+	if err = json.Unmarshal(configContent, &config); err != nil {
+		return 0, 0, errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, fmt.Sprintf("parse pd config error: %s", err.Error()), err)
+	}
+	return available, config.Replication.MaxReplicas, nil
+}
+
+// parseStoreSize
+// @Description: parse size reported by PD such as 1.5GiB, units are binary as PD does
+// @Parameter size
+// @return uint64 bytes
+// @return error
+func parseStoreSize(size string) (uint64, error) {
+	size = strings.TrimSpace(size)
+	split := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := size, ""
+	if split >= 0 {
+		number, unit = size[:split], strings.ToLower(strings.TrimSpace(size[split:]))
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "b"), "i")
+	shift := 0
+	if unit != "" {
+		shift = strings.Index("kmgtpe", unit) + 1
+		if len(unit) > 1 || shift == 0 {
+			return 0, fmt.Errorf("invalid unit of size %s", size)
+		}
+	}
+	return uint64(value * float64(uint64(1)<<(10*shift))), nil
+}
+
+// evaluateRestoreChecks
+// @Description: compare facts of the backup with the target cluster
+// @Parameter facts
+// @Parameter filter databases or tables to restore
+// @return []structs.RestoreCheckItem
+func evaluateRestoreChecks(facts *restoreCheckFacts, filter *restoreFilter) []structs.RestoreCheckItem {
+	return []structs.RestoreCheckItem{
+		checkRestoreVersion(facts),
+		checkRestoreCollation(facts),
+		checkRestoreCapacity(facts),
+		checkRestoreTableConflict(facts, filter),
+	}
+}
+
+func restoreCheckItem(name string, status constants.RestoreCheckStatus, format string, args ...interface{}) structs.RestoreCheckItem {
+	return structs.RestoreCheckItem{Name: name, Status: string(status), Message: fmt.Sprintf(format, args...)}
+}
+
+func checkRestoreVersion(facts *restoreCheckFacts) structs.RestoreCheckItem {
+	if facts.SourceVersion == "" || facts.TargetVersion == "" {
+		return restoreCheckItem(restoreCheckVersion, constants.RestoreCheckSkipped, "version of backup or target cluster is unknown")
+	}
+	newer, err := meta.CompareTiDBVersion(facts.TargetVersion, facts.SourceVersion)
+	if err != nil {
+		return restoreCheckItem(restoreCheckVersion, constants.RestoreCheckSkipped, "compare version %s with %s failed, %s", facts.TargetVersion, facts.SourceVersion, err.Error())
+	}
+	if !newer {
+		return restoreCheckItem(restoreCheckVersion, constants.RestoreCheckFailed, "backup of %s can not be restored into older cluster of %s", facts.SourceVersion, facts.TargetVersion)
+	}
+	return restoreCheckItem(restoreCheckVersion, constants.RestoreCheckPassed, "backup of %s is restored into cluster of %s", facts.SourceVersion, facts.TargetVersion)
+}
+
+func checkRestoreCollation(facts *restoreCheckFacts) structs.RestoreCheckItem {
+	if facts.SourceNewCollation == nil || facts.TargetNewCollation == nil {
+		return restoreCheckItem(restoreCheckCollation, constants.RestoreCheckSkipped, "new collation setting of backup or target cluster is unknown")
+	}
+	if *facts.SourceNewCollation != *facts.TargetNewCollation {
+		return restoreCheckItem(restoreCheckCollation, constants.RestoreCheckFailed, "new collation is %s in backup but %s in target cluster",
+			enabledOrDisabled(*facts.SourceNewCollation), enabledOrDisabled(*facts.TargetNewCollation))
+	}
+	return restoreCheckItem(restoreCheckCollation, constants.RestoreCheckPassed, "new collation is %s in both backup and target cluster", enabledOrDisabled(*facts.SourceNewCollation))
+}
+
+func enabledOrDisabled(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func checkRestoreCapacity(facts *restoreCheckFacts) structs.RestoreCheckItem {
+	if facts.CompressionFactor == 0 {
+		return restoreCheckItem(restoreCheckCapacity, constants.RestoreCheckSkipped, "capacity check is disabled")
+	}
+	if facts.TargetReplicas == 0 {
+		return restoreCheckItem(restoreCheckCapacity, constants.RestoreCheckSkipped, "capacity of target cluster is unknown")
+	}
+	required := facts.BackupSize * uint64(facts.CompressionFactor) * facts.TargetReplicas
+	if required > facts.TargetAvailable {
+		return restoreCheckItem(restoreCheckCapacity, constants.RestoreCheckFailed, "restore needs about %s with %d replicas, but only %s is available in TiKV",
+			formatBytes(required), facts.TargetReplicas, formatBytes(facts.TargetAvailable))
+	}
+	return restoreCheckItem(restoreCheckCapacity, constants.RestoreCheckPassed, "restore needs about %s with %d replicas, %s is available in TiKV",
+		formatBytes(required), facts.TargetReplicas, formatBytes(facts.TargetAvailable))
+}
+
+func formatBytes(size uint64) string {
+	return fmt.Sprintf("%.2fGiB", float64(size)/float64(1<<30))
+}
+
+func checkRestoreTableConflict(facts *restoreCheckFacts, filter *restoreFilter) structs.RestoreCheckItem {
+	if facts.BackupTables == nil || facts.TargetTables == nil {
+		return restoreCheckItem(restoreCheckTableConflict, constants.RestoreCheckSkipped, "tables of backup or target cluster are unknown")
+	}
+	existing := make(map[string]bool)
+	for _, table := range facts.TargetTables {
+		existing[strings.ToLower(table)] = true
+	}
+	conflicts := make([]string, 0)
+	for _, table := range restoredTables(facts.BackupTables, filter) {
+		if existing[strings.ToLower(table)] {
+			conflicts = append(conflicts, table)
+		}
+	}
+	if len(conflicts) == 0 {
+		return restoreCheckItem(restoreCheckTableConflict, constants.RestoreCheckPassed, "no restored table exists in target cluster")
+	}
+	listed := conflicts
+	if len(listed) > maxReportedConflicts {
+		listed = append(listed[:maxReportedConflicts:maxReportedConflicts], "...")
+	}
+	return restoreCheckItem(restoreCheckTableConflict, constants.RestoreCheckFailed, "%d restored tables already exist in target cluster: %s",
+		len(conflicts), strings.Join(listed, ", "))
+}
+
+// restoredTables
+// @Description: get names of tables written by restoring the backup after filtered,
+// tables restored as target database are only written under target database, for existing tables of source database are parked during the restore
+// @Parameter tables tables of the backup
+// @Parameter filter
+// @return []string
+func restoredTables(tables []string, filter *restoreFilter) []string {
+	if filter == nil {
+		return tables
+	}
+	databases := make(map[string]bool)
+	for _, database := range filter.Filter.Databases {
+		databases[database] = true
+	}
+	selected := make(map[string]bool)
+	for _, table := range filter.Filter.Tables {
+		selected[table] = true
+	}
+
+	restored := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names := strings.SplitN(table, ".", 2)
+		if !isFilterEmpty(filter.Filter) && !databases[names[0]] && !selected[table] {
+			continue
+		}
+		if filter.TargetDatabase != "" {
+			restored = append(restored, fmt.Sprintf("%s.%s", filter.TargetDatabase, names[1]))
+		} else {
+			restored = append(restored, table)
+		}
+	}
+	return restored
+}
@@ -0,0 +1,270 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	brpb "github.com/pingcap/kvproto/pkg/brpb"
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/pingcap/tiunimanager/test/mockutilpd"
+	"github.com/pingcap/tiunimanager/util/api/pd"
+	"github.com/stretchr/testify/assert"
+)
+
+func boolOf(b bool) *bool {
+	return &b
+}
+
+func Test_parseStoreSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    uint64
+		wantErr bool
+	}{
+		{"0B", 0, false},
+		{"100B", 100, false},
+		{"1KiB", 1 << 10, false},
+		{"1.5GiB", 3 << 29, false},
+		{"2TiB", 2 << 40, false},
+		{"3 MB", 3 << 20, false},
+		{"1024", 1024, false},
+		{"GiB", 0, true},
+		{"1XiB", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := parseStoreSize(tt.size)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_evaluateRestoreChecks(t *testing.T) {
+	facts := &restoreCheckFacts{
+		SourceVersion:      "v5.2.1",
+		SourceNewCollation: boolOf(true),
+		BackupSize:         1 << 30,
+		BackupTables:       []string{"shop.orders", "shop.items"},
+		TargetVersion:      "v5.3.0",
+		TargetNewCollation: boolOf(true),
+		TargetAvailable:    10 << 30,
+		TargetReplicas:     3,
+		TargetTables:       []string{"app.users"},
+		CompressionFactor:  3,
+	}
+	statusOf := func(items []structs.RestoreCheckItem) map[string]string {
+		status := make(map[string]string)
+		for _, item := range items {
+			status[item.Name] = item.Status
+		}
+		return status
+	}
+
+	t.Run("passed", func(t *testing.T) {
+		items := evaluateRestoreChecks(facts, &restoreFilter{})
+		assert.Len(t, items, 4)
+		for _, item := range items {
+			assert.Equal(t, string(constants.RestoreCheckPassed), item.Status, item.Message)
+		}
+		assert.Empty(t, failedRestoreChecks(items))
+	})
+	t.Run("failed", func(t *testing.T) {
+		failed := *facts
+		failed.TargetVersion = "v5.1.0"
+		failed.TargetNewCollation = boolOf(false)
+		failed.TargetAvailable = 8 << 30
+		failed.TargetTables = []string{"SHOP.orders"}
+		items := evaluateRestoreChecks(&failed, &restoreFilter{})
+		assert.Equal(t, []string{restoreCheckVersion, restoreCheckCollation, restoreCheckCapacity, restoreCheckTableConflict}, failedRestoreChecks(items))
+		assert.Contains(t, items[3].Message, "shop.orders")
+	})
+	t.Run("skipped", func(t *testing.T) {
+		items := evaluateRestoreChecks(&restoreCheckFacts{TargetVersion: "v5.3.0", CompressionFactor: 3}, nil)
+		for _, item := range items {
+			assert.Equal(t, string(constants.RestoreCheckSkipped), item.Status, item.Name)
+		}
+
+		disabled := *facts
+		disabled.CompressionFactor = 0
+		assert.Equal(t, string(constants.RestoreCheckSkipped), statusOf(evaluateRestoreChecks(&disabled, nil))[restoreCheckCapacity])
+	})
+	t.Run("renamed", func(t *testing.T) {
+		renamed := *facts
+		renamed.TargetTables = []string{"app.users"}
+		items := evaluateRestoreChecks(&renamed, &restoreFilter{
+			Filter:         structs.BackupFilter{Databases: []string{"shop"}},
+			TargetDatabase: "shop_restored",
+		})
+		assert.Equal(t, string(constants.RestoreCheckPassed), statusOf(items)[restoreCheckTableConflict])
+	})
+	t.Run("renamed source exists", func(t *testing.T) {
+		renamed := *facts
+		renamed.TargetTables = []string{"shop.orders"}
+		items := evaluateRestoreChecks(&renamed, &restoreFilter{
+			Filter:         structs.BackupFilter{Tables: []string{"shop.orders"}},
+			TargetDatabase: "shop_restored",
+		})
+		assert.Equal(t, string(constants.RestoreCheckPassed), statusOf(items)[restoreCheckTableConflict])
+	})
+	t.Run("renamed target exists", func(t *testing.T) {
+		renamed := *facts
+		renamed.TargetTables = []string{"shop_restored.orders"}
+		items := evaluateRestoreChecks(&renamed, &restoreFilter{
+			Filter:         structs.BackupFilter{Tables: []string{"shop.orders"}},
+			TargetDatabase: "shop_restored",
+		})
+		assert.Equal(t, string(constants.RestoreCheckFailed), statusOf(items)[restoreCheckTableConflict])
+		assert.Contains(t, items[3].Message, "shop_restored.orders")
+	})
+}
+
+func Test_restoredTables(t *testing.T) {
+	tables := []string{"app.users", "shop.items", "shop.orders"}
+	assert.Equal(t, tables, restoredTables(tables, nil))
+	assert.Equal(t, tables, restoredTables(tables, &restoreFilter{}))
+	assert.Equal(t, []string{"shop.items", "shop.orders"}, restoredTables(tables, &restoreFilter{Filter: structs.BackupFilter{Databases: []string{"shop"}}}))
+	assert.Equal(t, []string{"shop_bak.orders"}, restoredTables(tables, &restoreFilter{
+		Filter:         structs.BackupFilter{Tables: []string{"shop.orders"}},
+		TargetDatabase: "shop_bak",
+	}))
+}
+
+func Test_queryTiKVCapacity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	pdService := mockutilpd.NewMockPDApiService(ctrl)
+	pd.ApiService = pdService
+	defer func() { pd.ApiService = new(pd.PDApiServiceImpl) }()
+
+	clusterMeta := &meta.ClusterMeta{
+		Instances: map[string][]*management.ClusterInstance{
+			"PD": {
+				{Type: "PD", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{2379}},
+			},
+		},
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		pdService.EXPECT().ShowStores(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req cluster.ApiShowConfigReq) ([]byte, error) {
+			assert.Equal(t, "127.0.0.1", req.InstanceHost)
+			assert.Equal(t, uint(2379), req.InstancePort)
+			return []byte(`{"count": 4, "stores": [
+				{"store": {"id": 1, "state_name": "Up"}, "status": {"capacity": "100GiB", "available": "50GiB"}},
+				{"store": {"id": 2, "state_name": "Up"}, "status": {"capacity": "100GiB", "available": "1.5GiB"}},
+				{"store": {"id": 3, "state_name": "Offline"}, "status": {"capacity": "100GiB", "available": "90GiB"}},
+				{"store": {"id": 4, "state_name": "Up", "labels": [{"key": "engine", "value": "tiflash"}]}, "status": {"available": "90GiB"}}
+			]}`), nil
+		})
+		pdService.EXPECT().ShowConfig(gomock.Any(), gomock.Any()).Return([]byte(`{"replication": {"max-replicas": 3}}`), nil)
+
+		available, replicas, err := queryTiKVCapacity(context.TODO(), clusterMeta)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(51<<30+1<<29), available)
+		assert.Equal(t, uint64(3), replicas)
+	})
+	t.Run("stores failed", func(t *testing.T) {
+		pdService.EXPECT().ShowStores(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("unavailable"))
+		_, _, err := queryTiKVCapacity(context.TODO(), clusterMeta)
+		assert.Error(t, err)
+	})
+	t.Run("no pd", func(t *testing.T) {
+		_, _, err := queryTiKVCapacity(context.TODO(), &meta.ClusterMeta{})
+		assert.Error(t, err)
+	})
+}
+
+func TestBRManager_CheckRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root := t.TempDir()
+	writeBackupMeta(t, root, backupMetaFileName, &brpb.BackupMeta{
+		ClusterVersion: `"v5.2.1"`,
+		EndVersion:     1,
+		Schemas:        []*brpb.Schema{testSchema("shop", "orders")},
+	})
+	record := &backuprestore.BackupRecord{
+		Entity:         common.Entity{ID: "backup-xxx", Status: string(constants.ClusterBackupFinished)},
+		StorageType:    string(constants.StorageTypeNFS),
+		FilePath:       root,
+		BackupMode:     string(constants.BackupModeImported),
+		ClusterVersion: "v5.2.1",
+		Databases:      "shop",
+	}
+
+	t.Run("new cluster", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		models.SetBRReaderWriter(brRW)
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyRestoreCompressionFactor).Return(&config.SystemConfig{ConfigValue: "3"}, nil)
+		models.SetConfigReaderWriter(configRW)
+
+		resp, err := GetBRService().CheckRestore(context.TODO(), cluster.CheckRestoreReq{BackupID: "backup-xxx", TargetVersion: "v5.1.0"})
+		assert.NoError(t, err)
+		assert.False(t, resp.Passed)
+		assert.Equal(t, []structs.RestoreCheckItem{
+			{Name: restoreCheckVersion, Status: string(constants.RestoreCheckFailed), Message: "backup of v5.2.1 can not be restored into older cluster of v5.1.0"},
+			{Name: restoreCheckCollation, Status: string(constants.RestoreCheckSkipped), Message: "new collation setting of backup or target cluster is unknown"},
+			{Name: restoreCheckCapacity, Status: string(constants.RestoreCheckSkipped), Message: "capacity of target cluster is unknown"},
+			{Name: restoreCheckTableConflict, Status: string(constants.RestoreCheckSkipped), Message: "tables of backup or target cluster are unknown"},
+		}, resp.Items)
+	})
+	t.Run("not finished", func(t *testing.T) {
+		processing := *record
+		processing.Status = string(constants.ClusterBackupProcessing)
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(&processing, nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CheckRestore(context.TODO(), cluster.CheckRestoreReq{BackupID: "backup-xxx", TargetVersion: "v5.3.0"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_RECORD_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("no target", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CheckRestore(context.TODO(), cluster.CheckRestoreReq{BackupID: "backup-xxx"})
+		assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("filter invalid", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupRecord(gomock.Any(), "backup-xxx").Return(record, nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().CheckRestore(context.TODO(), cluster.CheckRestoreReq{BackupID: "backup-xxx", TargetVersion: "v5.3.0",
+			Filter: structs.BackupFilter{Databases: []string{"app"}}})
+		assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	})
+}
@@ -0,0 +1,125 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+)
+
+// selectExpiredBackupRecords
+// @Description: select finished backup records which are kept by none of the retention rules of strategy.
+// Manual backups which are not expirable are neither counted nor purged, the latest finished full backup of the whole cluster is never purged,
+// and neither is the earliest base snapshot of each log backup task, from which the whole range of the task is recoverable
+// @Parameter records
+// @Parameter tasks log backup tasks of the cluster
+// @Parameter strategy
+// @Parameter now
+// @return []*backuprestore.BackupRecord
+func selectExpiredBackupRecords(records []*backuprestore.BackupRecord, tasks []*backuprestore.LogBackupTask, strategy *backuprestore.BackupStrategy, now time.Time) []*backuprestore.BackupRecord {
+	if strategy == nil || !strategy.RetentionEnabled() {
+		return nil
+	}
+
+	finished := make([]*backuprestore.BackupRecord, 0)
+	for _, record := range records {
+		if record.Status == string(constants.ClusterBackupFinished) {
+			finished = append(finished, record)
+		}
+	}
+	if len(finished) == 0 {
+		return nil
+	}
+	sort.SliceStable(finished, func(i, j int) bool {
+		return finished[i].StartTime.After(finished[j].StartTime)
+	})
+
+	kept := make(map[string]bool)
+	for _, record := range finished {
+		// backups of selected databases or tables can not replace a full backup of the whole cluster
+		if record.BackupType == string(constants.BackupTypeFull) && record.Databases == "" && record.Tables == "" {
+			kept[record.ID] = true
+			break
+		}
+	}
+	for _, task := range tasks {
+		if base := selectBaseSnapshot(finished, task, math.MaxUint64, false); base != nil {
+			kept[base.ID] = true
+		}
+	}
+
+	candidates := make([]*backuprestore.BackupRecord, 0)
+	for _, record := range finished {
+		if record.Expirable || record.BackupMode == string(constants.BackupModeAuto) {
+			candidates = append(candidates, record)
+		}
+	}
+
+	for i, record := range candidates {
+		if uint32(i) < strategy.KeepLast {
+			kept[record.ID] = true
+		}
+		if strategy.KeepDays > 0 && record.StartTime.After(now.AddDate(0, 0, -int(strategy.KeepDays))) {
+			kept[record.ID] = true
+		}
+	}
+	keepLastOfPeriods(candidates, strategy.KeepDaily, kept, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepLastOfPeriods(candidates, strategy.KeepWeekly, kept, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	keepLastOfPeriods(candidates, strategy.KeepMonthly, kept, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	expired := make([]*backuprestore.BackupRecord, 0)
+	for _, record := range candidates {
+		if !kept[record.ID] {
+			expired = append(expired, record)
+		}
+	}
+	return expired
+}
+
+// keepLastOfPeriods
+// @Description: keep the latest record of each of the latest n periods, records must be sorted from new to old
+// @Parameter records
+// @Parameter n
+// @Parameter kept
+// @Parameter period
+func keepLastOfPeriods(records []*backuprestore.BackupRecord, n uint32, kept map[string]bool, period func(t time.Time) string) {
+	lastPeriod := ""
+	count := uint32(0)
+	for _, record := range records {
+		if count >= n {
+			return
+		}
+		current := period(record.StartTime)
+		if current == lastPeriod {
+			continue
+		}
+		lastPeriod = current
+		kept[record.ID] = true
+		count++
+	}
+}
@@ -0,0 +1,136 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockDailyBackupRecords returns finished auto backups started at noon of each of the last n days, newest first
+func mockDailyBackupRecords(now time.Time, n int) []*backuprestore.BackupRecord {
+	records := make([]*backuprestore.BackupRecord, 0)
+	for i := 0; i < n; i++ {
+		records = append(records, &backuprestore.BackupRecord{
+			Entity: common.Entity{
+				ID:     fmt.Sprintf("record-%d", i),
+				Status: string(constants.ClusterBackupFinished),
+			},
+			BackupMode: string(constants.BackupModeAuto),
+			BackupType: string(constants.BackupTypeFull),
+			BackupTso:  uint64(1000 - i),
+			StartTime:  now.AddDate(0, 0, -i),
+		})
+	}
+	return records
+}
+
+func expiredIDs(records []*backuprestore.BackupRecord) []string {
+	ids := make([]string, 0)
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func Test_selectExpiredBackupRecords(t *testing.T) {
+	now := time.Date(2022, 3, 31, 12, 0, 0, 0, time.Local)
+
+	t.Run("no retention", func(t *testing.T) {
+		expired := selectExpiredBackupRecords(mockDailyBackupRecords(now, 10), nil, &backuprestore.BackupStrategy{}, now)
+		assert.Empty(t, expired)
+		assert.Empty(t, selectExpiredBackupRecords(mockDailyBackupRecords(now, 10), nil, nil, now))
+	})
+	t.Run("keep last", func(t *testing.T) {
+		expired := selectExpiredBackupRecords(mockDailyBackupRecords(now, 5), nil, &backuprestore.BackupStrategy{KeepLast: 3}, now)
+		assert.ElementsMatch(t, []string{"record-3", "record-4"}, expiredIDs(expired))
+	})
+	t.Run("keep days", func(t *testing.T) {
+		expired := selectExpiredBackupRecords(mockDailyBackupRecords(now, 5), nil, &backuprestore.BackupStrategy{KeepDays: 2}, now)
+		assert.ElementsMatch(t, []string{"record-2", "record-3", "record-4"}, expiredIDs(expired))
+	})
+	t.Run("keep daily", func(t *testing.T) {
+		records := mockDailyBackupRecords(now, 3)
+		records = append(records, &backuprestore.BackupRecord{
+			Entity:     common.Entity{ID: "record-early", Status: string(constants.ClusterBackupFinished)},
+			BackupMode: string(constants.BackupModeAuto),
+			StartTime:  now.Add(-time.Hour),
+		})
+		expired := selectExpiredBackupRecords(records, nil, &backuprestore.BackupStrategy{KeepDaily: 2}, now)
+		assert.ElementsMatch(t, []string{"record-early", "record-2"}, expiredIDs(expired))
+	})
+	t.Run("gfs", func(t *testing.T) {
+		records := mockDailyBackupRecords(now, 90)
+		expired := selectExpiredBackupRecords(records, nil, &backuprestore.BackupStrategy{KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 3}, now)
+		kept := make(map[string]bool)
+		for _, record := range records {
+			kept[record.ID] = true
+		}
+		for _, record := range expired {
+			delete(kept, record.ID)
+		}
+		// 7 daily backups, 2 more weekly backups and 2 more monthly backups,
+		// the last backup of the week ended at Mar 27 is kept as a daily one
+		assert.Equal(t, 11, len(kept))
+		assert.True(t, kept["record-0"])
+		assert.True(t, kept["record-11"])
+		assert.True(t, kept["record-18"])
+		// the last backup of February
+		assert.True(t, kept["record-31"])
+		// the last backup of January
+		assert.True(t, kept["record-59"])
+	})
+	t.Run("manual backups", func(t *testing.T) {
+		records := mockDailyBackupRecords(now, 4)
+		records[2].BackupMode = string(constants.BackupModeManual)
+		records[3].BackupMode = string(constants.BackupModeManual)
+		records[3].Expirable = true
+		expired := selectExpiredBackupRecords(records, nil, &backuprestore.BackupStrategy{KeepLast: 1}, now)
+		assert.ElementsMatch(t, []string{"record-1", "record-3"}, expiredIDs(expired))
+	})
+	t.Run("latest finished backup", func(t *testing.T) {
+		records := mockDailyBackupRecords(now, 3)
+		records[0].Status = string(constants.ClusterBackupFailed)
+		records[1].BackupMode = string(constants.BackupModeManual)
+		expired := selectExpiredBackupRecords(records, nil, &backuprestore.BackupStrategy{KeepDays: 1}, now)
+		assert.ElementsMatch(t, []string{"record-2"}, expiredIDs(expired))
+
+		records[1].BackupMode = string(constants.BackupModeAuto)
+		expired = selectExpiredBackupRecords(records, nil, &backuprestore.BackupStrategy{KeepDays: 1}, now.AddDate(0, 0, 10))
+		assert.ElementsMatch(t, []string{"record-2"}, expiredIDs(expired))
+	})
+	t.Run("latest full backup of whole cluster", func(t *testing.T) {
+		records := mockDailyBackupRecords(now, 4)
+		records[0].Tables = "db1.t1"
+		records[1].BackupType = string(constants.BackupTypeIncrement)
+		expired := selectExpiredBackupRecords(records, nil, &backuprestore.BackupStrategy{KeepDays: 1}, now.AddDate(0, 0, 10))
+		assert.ElementsMatch(t, []string{"record-0", "record-1", "record-3"}, expiredIDs(expired))
+	})
+	t.Run("base snapshot of log backup", func(t *testing.T) {
+		records := mockDailyBackupRecords(now, 5)
+		records[4].Databases = "db1"
+		tasks := []*backuprestore.LogBackupTask{{TaskName: "task", StartTso: 995, CheckpointTso: 1000}}
+		expired := selectExpiredBackupRecords(records, tasks, &backuprestore.BackupStrategy{KeepLast: 1}, now)
+		// record-4 is filtered, and record-3 is the earliest full backup of the whole cluster since the log backup started
+		assert.ElementsMatch(t, []string{"record-1", "record-2", "record-4"}, expiredIDs(expired))
+	})
+}
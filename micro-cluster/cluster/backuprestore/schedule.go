@@ -0,0 +1,262 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/robfig/cron"
+	"gorm.io/gorm"
+)
+
+// scheduleGracePeriod a due run older than it when evaluated is regarded as missed,
+// schedules are evaluated every minute so an on-time run is at most about one minute late
+const scheduleGracePeriod = 5 * time.Minute
+
+// maxCountedMissedRuns stop counting missed runs of a frequent schedule after a long outage
+const maxCountedMissedRuns = 1000
+
+func (mgr *BRManager) SaveBackupSchedule(ctx context.Context, request cluster.SaveBackupScheduleReq) (resp cluster.SaveBackupScheduleResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin SaveBackupSchedule, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End SaveBackupSchedule")
+
+	if err = saveBackupSchedulePreCheck(&request); err != nil {
+		framework.LogWithContext(ctx).Errorf("save backup schedule precheck failed, %s", err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_INVALID, fmt.Sprintf("save backup schedule precheck failed, %s", err.Error()), err)
+	}
+
+	brRW := models.GetBRReaderWriter()
+	schedule := &backuprestore.BackupSchedule{
+		ClusterID:       request.ClusterID,
+		Name:            request.Schedule.Name,
+		CronSpec:        request.Schedule.CronSpec,
+		TimeZone:        request.Schedule.TimeZone,
+		Paused:          request.Schedule.Paused,
+		MissedRunPolicy: request.Schedule.MissedRunPolicy,
+		Databases:       joinFilterNames(request.Schedule.Filter.Databases),
+		Tables:          joinFilterNames(request.Schedule.Filter.Tables),
+		// runs are counted from now on, so that a new, changed or resumed schedule has no missed runs
+		LastScheduledTime: time.Now(),
+	}
+
+	if request.ScheduleID == "" {
+		clusterMeta, err := meta.Get(ctx, request.ClusterID)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("load cluster meta %s failed, %s", request.ClusterID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_CLUSTER_NOT_FOUND, fmt.Sprintf("load cluster meta %s failed, %s", request.ClusterID, err.Error()), err)
+		}
+		schedule.TenantId = clusterMeta.Cluster.TenantId
+		if schedule, err = brRW.CreateBackupSchedule(ctx, schedule); err != nil {
+			framework.LogWithContext(ctx).Errorf("create backup schedule of cluster %s failed, %s", request.ClusterID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_SAVE_FAILED, fmt.Sprintf("create backup schedule of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+		}
+	} else {
+		if _, err = getClusterBackupSchedule(ctx, request.ClusterID, request.ScheduleID); err != nil {
+			return resp, err
+		}
+		schedule.ID = request.ScheduleID
+		if err = brRW.UpdateBackupSchedule(ctx, schedule); err != nil {
+			framework.LogWithContext(ctx).Errorf("update backup schedule %s failed, %s", request.ScheduleID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_SAVE_FAILED, fmt.Sprintf("update backup schedule %s failed, %s", request.ScheduleID, err.Error()), err)
+		}
+		if schedule, err = getClusterBackupSchedule(ctx, request.ClusterID, request.ScheduleID); err != nil {
+			return resp, err
+		}
+	}
+
+	resp.Schedule = convertBackupSchedule(schedule, time.Now())
+	return resp, nil
+}
+
+func (mgr *BRManager) QueryBackupSchedules(ctx context.Context, request cluster.QueryBackupSchedulesReq) (resp cluster.QueryBackupSchedulesResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin QueryBackupSchedules, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End QueryBackupSchedules")
+
+	schedules, err := models.GetBRReaderWriter().QueryBackupSchedules(ctx, request.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query backup schedules of cluster %s failed, %s", request.ClusterID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_QUERY_FAILED, fmt.Sprintf("query backup schedules of cluster %s failed, %s", request.ClusterID, err.Error()), err)
+	}
+
+	now := time.Now()
+	resp.Schedules = make([]structs.BackupSchedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		resp.Schedules = append(resp.Schedules, convertBackupSchedule(schedule, now))
+	}
+	return resp, nil
+}
+
+func (mgr *BRManager) DeleteBackupSchedule(ctx context.Context, request cluster.DeleteBackupScheduleReq) (resp cluster.DeleteBackupScheduleResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin DeleteBackupSchedule, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End DeleteBackupSchedule")
+
+	if _, err = getClusterBackupSchedule(ctx, request.ClusterID, request.ScheduleID); err != nil {
+		return resp, err
+	}
+	if err = models.GetBRReaderWriter().DeleteBackupSchedules(ctx, request.ClusterID, request.ScheduleID); err != nil {
+		framework.LogWithContext(ctx).Errorf("delete backup schedule %s failed, %s", request.ScheduleID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_DELETE_FAILED, fmt.Sprintf("delete backup schedule %s failed, %s", request.ScheduleID, err.Error()), err)
+	}
+	return resp, nil
+}
+
+func getClusterBackupSchedule(ctx context.Context, clusterID string, scheduleID string) (*backuprestore.BackupSchedule, error) {
+	schedule, err := models.GetBRReaderWriter().GetBackupSchedule(ctx, scheduleID)
+	if err == gorm.ErrRecordNotFound || (err == nil && schedule.ClusterID != clusterID) {
+		framework.LogWithContext(ctx).Errorf("backup schedule %s of cluster %s not found", scheduleID, clusterID)
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_SCHEDULE_NOT_FOUND, "backup schedule %s of cluster %s not found", scheduleID, clusterID)
+	} else if err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup schedule %s failed, %s", scheduleID, err.Error())
+		return nil, errors.WrapError(errors.TIUNIMANAGER_BACKUP_SCHEDULE_QUERY_FAILED, fmt.Sprintf("get backup schedule %s failed, %s", scheduleID, err.Error()), err)
+	}
+	return schedule, nil
+}
+
+func saveBackupSchedulePreCheck(request *cluster.SaveBackupScheduleReq) error {
+	request.Schedule.CronSpec = strings.TrimSpace(request.Schedule.CronSpec)
+	if _, _, err := parseBackupSchedule(request.Schedule.CronSpec, request.Schedule.TimeZone); err != nil {
+		return err
+	}
+	switch constants.BackupMissedRunPolicy(request.Schedule.MissedRunPolicy) {
+	case "":
+		request.Schedule.MissedRunPolicy = string(constants.BackupMissedRunPolicyRun)
+	case constants.BackupMissedRunPolicyRun, constants.BackupMissedRunPolicyReport:
+	default:
+		return fmt.Errorf("invalid missed run policy %s", request.Schedule.MissedRunPolicy)
+	}
+	return validateBackupFilter(request.Schedule.Filter)
+}
+
+// parseBackupSchedule
+// @Description: parse standard 5-field cron expression or descriptor, and its time zone
+// @Parameter spec
+// @Parameter timeZone IANA time zone name, local time zone if empty
+// @return cron.Schedule
+// @return *time.Location
+// @return error
+func parseBackupSchedule(spec string, timeZone string) (cron.Schedule, *time.Location, error) {
+	if spec == "" {
+		return nil, nil, fmt.Errorf("cron spec is empty")
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron spec %s, %s", spec, err.Error())
+	}
+	location := time.Local
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, nil, fmt.Errorf("invalid time zone %s, %s", timeZone, err.Error())
+		}
+	}
+	return schedule, location, nil
+}
+
+// scheduledRuns
+// @Description: get runs of schedule due in (after, now]
+// @Parameter schedule
+// @Parameter location
+// @Parameter after
+// @Parameter now
+// @return latest time of the latest due run, zero if no run is due
+// @return previous time of the run before the latest one, zero if only one run is due
+// @return count number of due runs, at most maxCountedMissedRuns + 1
+func scheduledRuns(schedule cron.Schedule, location *time.Location, after time.Time, now time.Time) (latest time.Time, previous time.Time, count int) {
+	for next := schedule.Next(after.In(location)); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		previous, latest = latest, next
+		if count++; count > maxCountedMissedRuns {
+			break
+		}
+	}
+	return latest, previous, count
+}
+
+// backupScheduleRun decision of evaluating a backup schedule
+type backupScheduleRun struct {
+	due        bool      // whether a backup should be started now
+	missed     int       // number of runs missed
+	lastMissed time.Time // time of the latest missed run
+	scheduled  time.Time // the new LastScheduledTime
+}
+
+// evaluateBackupSchedule
+// @Description: decide whether to start a backup of the schedule now, runs which are late for more than
+// scheduleGracePeriod have been missed, they are caught up by one backup or only reported according to the policy
+// @Parameter schedule
+// @Parameter now
+// @return backupScheduleRun
+// @return error
+func evaluateBackupSchedule(schedule *backuprestore.BackupSchedule, now time.Time) (backupScheduleRun, error) {
+	run := backupScheduleRun{scheduled: schedule.LastScheduledTime}
+	cronSchedule, location, err := parseBackupSchedule(schedule.CronSpec, schedule.TimeZone)
+	if err != nil {
+		return run, err
+	}
+	if schedule.LastScheduledTime.IsZero() {
+		run.scheduled = now
+		return run, nil
+	}
+
+	latest, previous, count := scheduledRuns(cronSchedule, location, schedule.LastScheduledTime, now)
+	if count == 0 {
+		return run, nil
+	}
+	run.scheduled = now
+	onTime := now.Sub(latest) <= scheduleGracePeriod
+	if onTime {
+		run.missed, run.lastMissed = count-1, previous
+	} else {
+		run.missed, run.lastMissed = count, latest
+	}
+	run.due = onTime || constants.BackupMissedRunPolicy(schedule.MissedRunPolicy) != constants.BackupMissedRunPolicyReport
+	return run, nil
+}
+
+func convertBackupSchedule(schedule *backuprestore.BackupSchedule, now time.Time) structs.BackupSchedule {
+	info := structs.BackupSchedule{
+		ID:              schedule.ID,
+		ClusterID:       schedule.ClusterID,
+		Name:            schedule.Name,
+		CronSpec:        schedule.CronSpec,
+		TimeZone:        schedule.TimeZone,
+		Paused:          schedule.Paused,
+		MissedRunPolicy: schedule.MissedRunPolicy,
+		Filter: structs.BackupFilter{
+			Databases: splitFilterNames(schedule.Databases),
+			Tables:    splitFilterNames(schedule.Tables),
+		},
+		LastScheduledTime: schedule.LastScheduledTime,
+		LastBackupID:      schedule.LastBackupID,
+		MissedRuns:        schedule.MissedRuns,
+		LastMissedTime:    schedule.LastMissedTime,
+		CreateTime:        schedule.CreatedAt,
+		UpdateTime:        schedule.UpdatedAt,
+	}
+	if cronSchedule, location, err := parseBackupSchedule(schedule.CronSpec, schedule.TimeZone); err == nil && !schedule.Paused {
+		info.NextRunTime = cronSchedule.Next(now.In(location))
+	}
+	return info
+}
@@ -0,0 +1,292 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var scheduleBaseTime = time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)
+
+func Test_parseBackupSchedule(t *testing.T) {
+	_, location, err := parseBackupSchedule("0 2 * * *", "")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Local, location)
+	_, location, err = parseBackupSchedule("@daily", "Asia/Shanghai")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Shanghai", location.String())
+
+	_, _, err = parseBackupSchedule("", "")
+	assert.Error(t, err)
+	_, _, err = parseBackupSchedule("0 25 * * *", "")
+	assert.Error(t, err)
+	_, _, err = parseBackupSchedule("0 2 * * *", "Mars/Olympus")
+	assert.Error(t, err)
+}
+
+func Test_scheduledRuns(t *testing.T) {
+	schedule, _, err := parseBackupSchedule("0 2 * * *", "UTC")
+	assert.NoError(t, err)
+
+	latest, previous, count := scheduledRuns(schedule, time.UTC, scheduleBaseTime, scheduleBaseTime.Add(30*time.Minute))
+	assert.True(t, latest.IsZero())
+	assert.True(t, previous.IsZero())
+	assert.Equal(t, 0, count)
+
+	latest, previous, count = scheduledRuns(schedule, time.UTC, scheduleBaseTime, scheduleBaseTime.Add(49*time.Hour))
+	assert.Equal(t, scheduleBaseTime.Add(49*time.Hour), latest)
+	assert.Equal(t, scheduleBaseTime.Add(25*time.Hour), previous)
+	assert.Equal(t, 3, count)
+
+	everyMinute, _, err := parseBackupSchedule("* * * * *", "UTC")
+	assert.NoError(t, err)
+	_, _, count = scheduledRuns(everyMinute, time.UTC, scheduleBaseTime, scheduleBaseTime.Add(48*time.Hour))
+	assert.Equal(t, maxCountedMissedRuns+1, count)
+}
+
+func Test_evaluateBackupSchedule(t *testing.T) {
+	schedule := func(policy constants.BackupMissedRunPolicy, timeZone string) *backuprestore.BackupSchedule {
+		return &backuprestore.BackupSchedule{
+			CronSpec:          "0 2 * * *",
+			TimeZone:          timeZone,
+			MissedRunPolicy:   string(policy),
+			LastScheduledTime: scheduleBaseTime,
+		}
+	}
+
+	t.Run("not due", func(t *testing.T) {
+		run, err := evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyRun, "UTC"), scheduleBaseTime.Add(30*time.Minute))
+		assert.NoError(t, err)
+		assert.False(t, run.due)
+		assert.Equal(t, scheduleBaseTime, run.scheduled)
+	})
+	t.Run("on time", func(t *testing.T) {
+		now := scheduleBaseTime.Add(time.Hour + 30*time.Second)
+		run, err := evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyReport, "UTC"), now)
+		assert.NoError(t, err)
+		assert.True(t, run.due)
+		assert.Equal(t, 0, run.missed)
+		assert.Equal(t, now, run.scheduled)
+	})
+	t.Run("time zone", func(t *testing.T) {
+		// 02:00 in Asia/Shanghai is 18:00 in UTC
+		run, err := evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyRun, "Asia/Shanghai"), scheduleBaseTime.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.False(t, run.due)
+		run, err = evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyRun, "Asia/Shanghai"), scheduleBaseTime.Add(17*time.Hour))
+		assert.NoError(t, err)
+		assert.True(t, run.due)
+	})
+	t.Run("missed before on time run", func(t *testing.T) {
+		run, err := evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyReport, "UTC"), scheduleBaseTime.Add(49*time.Hour+time.Minute))
+		assert.NoError(t, err)
+		assert.True(t, run.due)
+		assert.Equal(t, 2, run.missed)
+		assert.Equal(t, scheduleBaseTime.Add(25*time.Hour), run.lastMissed)
+	})
+	t.Run("missed and caught up", func(t *testing.T) {
+		run, err := evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyRun, "UTC"), scheduleBaseTime.Add(50*time.Hour))
+		assert.NoError(t, err)
+		assert.True(t, run.due)
+		assert.Equal(t, 3, run.missed)
+		assert.Equal(t, scheduleBaseTime.Add(49*time.Hour), run.lastMissed)
+	})
+	t.Run("missed and reported", func(t *testing.T) {
+		run, err := evaluateBackupSchedule(schedule(constants.BackupMissedRunPolicyReport, "UTC"), scheduleBaseTime.Add(50*time.Hour))
+		assert.NoError(t, err)
+		assert.False(t, run.due)
+		assert.Equal(t, 3, run.missed)
+		assert.Equal(t, scheduleBaseTime.Add(50*time.Hour), run.scheduled)
+	})
+	t.Run("never scheduled", func(t *testing.T) {
+		now := scheduleBaseTime.Add(50 * time.Hour)
+		run, err := evaluateBackupSchedule(&backuprestore.BackupSchedule{CronSpec: "0 2 * * *"}, now)
+		assert.NoError(t, err)
+		assert.False(t, run.due)
+		assert.Equal(t, now, run.scheduled)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := evaluateBackupSchedule(&backuprestore.BackupSchedule{CronSpec: "invalid"}, scheduleBaseTime)
+		assert.Error(t, err)
+	})
+}
+
+func Test_saveBackupSchedulePreCheck(t *testing.T) {
+	request := &cluster.SaveBackupScheduleReq{Schedule: structs.BackupSchedule{CronSpec: " 0 2 * * * "}}
+	assert.NoError(t, saveBackupSchedulePreCheck(request))
+	assert.Equal(t, "0 2 * * *", request.Schedule.CronSpec)
+	assert.Equal(t, string(constants.BackupMissedRunPolicyRun), request.Schedule.MissedRunPolicy)
+
+	assert.Error(t, saveBackupSchedulePreCheck(&cluster.SaveBackupScheduleReq{Schedule: structs.BackupSchedule{CronSpec: "0 2 * * *", MissedRunPolicy: "Skip"}}))
+	assert.Error(t, saveBackupSchedulePreCheck(&cluster.SaveBackupScheduleReq{Schedule: structs.BackupSchedule{CronSpec: "0 2 * *"}}))
+	assert.Error(t, saveBackupSchedulePreCheck(&cluster.SaveBackupScheduleReq{Schedule: structs.BackupSchedule{
+		CronSpec: "0 2 * * *",
+		Filter:   structs.BackupFilter{Databases: []string{"db1"}, Tables: []string{"db1.t1"}},
+	}}))
+}
+
+func Test_convertBackupSchedule(t *testing.T) {
+	schedule := &backuprestore.BackupSchedule{
+		Entity:    common.Entity{ID: "schedule01"},
+		ClusterID: "cls-test",
+		CronSpec:  "0 2 * * *",
+		TimeZone:  "UTC",
+		Databases: "db1,db2",
+	}
+	info := convertBackupSchedule(schedule, scheduleBaseTime)
+	assert.Equal(t, "schedule01", info.ID)
+	assert.Equal(t, []string{"db1", "db2"}, info.Filter.Databases)
+	assert.Equal(t, scheduleBaseTime.Add(time.Hour), info.NextRunTime)
+
+	schedule.Paused = true
+	assert.True(t, convertBackupSchedule(schedule, scheduleBaseTime).NextRunTime.IsZero())
+}
+
+func TestBRManager_SaveBackupSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("create", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cls-test").Return(&management.Cluster{
+			Entity: common.Entity{ID: "cls-test", TenantId: "tid-xxx"},
+		}, make([]*management.ClusterInstance, 0), make([]*management.DBUser, 0), nil)
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().CreateBackupSchedule(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, schedule *backuprestore.BackupSchedule) (*backuprestore.BackupSchedule, error) {
+			assert.Equal(t, "tid-xxx", schedule.TenantId)
+			assert.Equal(t, string(constants.BackupMissedRunPolicyRun), schedule.MissedRunPolicy)
+			assert.False(t, schedule.LastScheduledTime.IsZero())
+			schedule.ID = "schedule01"
+			return schedule, nil
+		})
+
+		resp, err := GetBRService().SaveBackupSchedule(context.TODO(), cluster.SaveBackupScheduleReq{
+			ClusterID: "cls-test",
+			Schedule:  structs.BackupSchedule{Name: "daily", CronSpec: "@daily"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "schedule01", resp.Schedule.ID)
+		assert.False(t, resp.Schedule.NextRunTime.IsZero())
+	})
+	t.Run("update", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().GetBackupSchedule(gomock.Any(), "schedule01").Return(&backuprestore.BackupSchedule{
+			Entity: common.Entity{ID: "schedule01"}, ClusterID: "cls-test", CronSpec: "0 3 * * *",
+		}, nil).Times(2)
+		brRW.EXPECT().UpdateBackupSchedule(gomock.Any(), gomock.Any()).Return(nil)
+
+		resp, err := GetBRService().SaveBackupSchedule(context.TODO(), cluster.SaveBackupScheduleReq{
+			ClusterID:  "cls-test",
+			ScheduleID: "schedule01",
+			Schedule:   structs.BackupSchedule{CronSpec: "0 3 * * *"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "0 3 * * *", resp.Schedule.CronSpec)
+	})
+	t.Run("schedule of other cluster", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().GetBackupSchedule(gomock.Any(), "schedule01").Return(&backuprestore.BackupSchedule{ClusterID: "cls-other"}, nil)
+
+		_, err := GetBRService().SaveBackupSchedule(context.TODO(), cluster.SaveBackupScheduleReq{
+			ClusterID:  "cls-test",
+			ScheduleID: "schedule01",
+			Schedule:   structs.BackupSchedule{CronSpec: "0 3 * * *"},
+		})
+		assert.Error(t, err)
+	})
+	t.Run("update failed", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().GetBackupSchedule(gomock.Any(), "schedule01").Return(&backuprestore.BackupSchedule{ClusterID: "cls-test"}, nil)
+		brRW.EXPECT().UpdateBackupSchedule(gomock.Any(), gomock.Any()).Return(errors.New("update failed"))
+
+		_, err := GetBRService().SaveBackupSchedule(context.TODO(), cluster.SaveBackupScheduleReq{
+			ClusterID:  "cls-test",
+			ScheduleID: "schedule01",
+			Schedule:   structs.BackupSchedule{CronSpec: "0 3 * * *"},
+		})
+		assert.Error(t, err)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := GetBRService().SaveBackupSchedule(context.TODO(), cluster.SaveBackupScheduleReq{
+			ClusterID: "cls-test",
+			Schedule:  structs.BackupSchedule{CronSpec: "0 3 * * *", TimeZone: "invalid"},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestBRManager_QueryBackupSchedules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	brRW.EXPECT().QueryBackupSchedules(gomock.Any(), "cls-test").Return([]*backuprestore.BackupSchedule{
+		{Entity: common.Entity{ID: "schedule01"}, ClusterID: "cls-test", CronSpec: "0 2 * * *", MissedRuns: 2},
+	}, nil)
+	brRW.EXPECT().QueryBackupSchedules(gomock.Any(), "cls-fail").Return(nil, errors.New("query failed"))
+
+	resp, err := GetBRService().QueryBackupSchedules(context.TODO(), cluster.QueryBackupSchedulesReq{ClusterID: "cls-test"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Schedules))
+	assert.Equal(t, uint32(2), resp.Schedules[0].MissedRuns)
+
+	_, err = GetBRService().QueryBackupSchedules(context.TODO(), cluster.QueryBackupSchedulesReq{ClusterID: "cls-fail"})
+	assert.Error(t, err)
+}
+
+func TestBRManager_DeleteBackupSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	brRW.EXPECT().GetBackupSchedule(gomock.Any(), "schedule01").Return(&backuprestore.BackupSchedule{ClusterID: "cls-test"}, nil).Times(2)
+	brRW.EXPECT().GetBackupSchedule(gomock.Any(), "schedule02").Return(nil, gorm.ErrRecordNotFound)
+	brRW.EXPECT().GetBackupSchedule(gomock.Any(), "schedule03").Return(nil, errors.New("get failed"))
+	brRW.EXPECT().DeleteBackupSchedules(gomock.Any(), "cls-test", "schedule01").Return(nil)
+	brRW.EXPECT().DeleteBackupSchedules(gomock.Any(), "cls-test", "schedule01").Return(errors.New("delete failed"))
+
+	_, err := GetBRService().DeleteBackupSchedule(context.TODO(), cluster.DeleteBackupScheduleReq{ClusterID: "cls-test", ScheduleID: "schedule01"})
+	assert.NoError(t, err)
+	_, err = GetBRService().DeleteBackupSchedule(context.TODO(), cluster.DeleteBackupScheduleReq{ClusterID: "cls-test", ScheduleID: "schedule01"})
+	assert.Error(t, err)
+	_, err = GetBRService().DeleteBackupSchedule(context.TODO(), cluster.DeleteBackupScheduleReq{ClusterID: "cls-test", ScheduleID: "schedule02"})
+	assert.Error(t, err)
+	_, err = GetBRService().DeleteBackupSchedule(context.TODO(), cluster.DeleteBackupScheduleReq{ClusterID: "cls-test", ScheduleID: "schedule03"})
+	assert.Error(t, err)
+}
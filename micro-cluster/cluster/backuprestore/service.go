@@ -96,4 +96,165 @@ type BRService interface {
 	// @Return cluster.UpdateBackupStrategyResp
 	// @Return error
 	DeleteBackupStrategy(ctx context.Context, request cluster.DeleteBackupStrategyReq) (resp cluster.DeleteBackupStrategyResp, err error)
+
+	// PurgeExpiredBackupRecords
+	// @Description: purge backup records of cluster expired by the retention rules of backup strategy
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterID
+	// @Return purgedIDs
+	// @Return error
+	PurgeExpiredBackupRecords(ctx context.Context, clusterID string) (purgedIDs []string, err error)
+
+	// StartLogBackup
+	// @Description: start continuous log backup of cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.StartLogBackupResp
+	// @Return error
+	StartLogBackup(ctx context.Context, request cluster.StartLogBackupReq) (resp cluster.StartLogBackupResp, err error)
+
+	// StopLogBackup
+	// @Description: stop continuous log backup of cluster, the logs backed up are kept for point-in-time restore
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.StopLogBackupResp
+	// @Return error
+	StopLogBackup(ctx context.Context, request cluster.StopLogBackupReq) (resp cluster.StopLogBackupResp, err error)
+
+	// QueryLogBackup
+	// @Description: query log backup tasks of cluster with their recoverable ranges
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.QueryLogBackupResp
+	// @Return error
+	QueryLogBackup(ctx context.Context, request cluster.QueryLogBackupReq) (resp cluster.QueryLogBackupResp, err error)
+
+	// SaveBackupSchedule
+	// @Description: create backup schedule of cluster, or update it if schedule id is given
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.SaveBackupScheduleResp
+	// @Return error
+	SaveBackupSchedule(ctx context.Context, request cluster.SaveBackupScheduleReq) (resp cluster.SaveBackupScheduleResp, err error)
+
+	// QueryBackupSchedules
+	// @Description: query backup schedules of cluster with their next run time and missed runs
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.QueryBackupSchedulesResp
+	// @Return error
+	QueryBackupSchedules(ctx context.Context, request cluster.QueryBackupSchedulesReq) (resp cluster.QueryBackupSchedulesResp, err error)
+
+	// DeleteBackupSchedule
+	// @Description: delete backup schedule of cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.DeleteBackupScheduleResp
+	// @Return error
+	DeleteBackupSchedule(ctx context.Context, request cluster.DeleteBackupScheduleReq) (resp cluster.DeleteBackupScheduleResp, err error)
+
+	// RotateBackupKey
+	// @Description: create a new active data key encrypting backups of cluster, retired keys are kept to restore older backups
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.RotateBackupKeyResp
+	// @Return error
+	RotateBackupKey(ctx context.Context, request cluster.RotateBackupKeyReq) (resp cluster.RotateBackupKeyResp, err error)
+
+	// QueryBackupKeys
+	// @Description: query data keys encrypting backups of cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.QueryBackupKeysResp
+	// @Return error
+	QueryBackupKeys(ctx context.Context, request cluster.QueryBackupKeysReq) (resp cluster.QueryBackupKeysResp, err error)
+
+	// ExportBackupKey
+	// @Description: export data key of cluster sealed with passphrase, to restore its backups on another TiUniManager
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.ExportBackupKeyResp
+	// @Return error
+	ExportBackupKey(ctx context.Context, request cluster.ExportBackupKeyReq) (resp cluster.ExportBackupKeyResp, err error)
+
+	// ImportBackupKey
+	// @Description: import data key exported by another TiUniManager
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.ImportBackupKeyResp
+	// @Return error
+	ImportBackupKey(ctx context.Context, request cluster.ImportBackupKeyReq) (resp cluster.ImportBackupKeyResp, err error)
+
+	// SaveBackupStorageTarget
+	// @Description: create or update named storage target where copies of backups are kept
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.SaveBackupStorageTargetResp
+	// @Return error
+	SaveBackupStorageTarget(ctx context.Context, request cluster.SaveBackupStorageTargetReq) (resp cluster.SaveBackupStorageTargetResp, err error)
+
+	// QueryBackupStorageTargets
+	// @Description: query all backup storage targets
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.QueryBackupStorageTargetsResp
+	// @Return error
+	QueryBackupStorageTargets(ctx context.Context, request cluster.QueryBackupStorageTargetsReq) (resp cluster.QueryBackupStorageTargetsResp, err error)
+
+	// DeleteBackupStorageTarget
+	// @Description: delete backup storage target not used by any strategy or backup copy
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.DeleteBackupStorageTargetResp
+	// @Return error
+	DeleteBackupStorageTarget(ctx context.Context, request cluster.DeleteBackupStorageTargetReq) (resp cluster.DeleteBackupStorageTargetResp, err error)
+
+	// CopyBackup
+	// @Description: copy finished backup to storage target asynchronously, checksums of copied files are compared with the source
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.CopyBackupResp
+	// @Return error
+	CopyBackup(ctx context.Context, request cluster.CopyBackupReq) (resp cluster.CopyBackupResp, err error)
+
+	// ImportBackup
+	// @Description: register a backup created outside by reading its backupmeta, the imported backup is ready to restore
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.ImportBackupResp
+	// @Return error
+	ImportBackup(ctx context.Context, request cluster.ImportBackupReq) (resp cluster.ImportBackupResp, err error)
+
+	// CheckRestore
+	// @Description: check version, collation, capacity and table conflicts of a backup against the target cluster without restoring it
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter request
+	// @Return cluster.CheckRestoreResp
+	// @Return error
+	CheckRestore(ctx context.Context, request cluster.CheckRestoreReq) (resp cluster.CheckRestoreResp, err error)
+
+	// CheckPointInTimeRestore
+	// @Description: check whether the restore point of target is covered by backups of its source cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter target
+	// @Return error
+	CheckPointInTimeRestore(ctx context.Context, target cluster.PointInTimeRestoreTarget) error
 }
@@ -0,0 +1,207 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	dbModel "github.com/pingcap/tiunimanager/models/common"
+)
+
+type s3Config struct {
+	Endpoint        string
+	AccessKey       string
+	SecretAccessKey string
+}
+
+func (mgr *BRManager) SaveBackupStorageTarget(ctx context.Context, request cluster.SaveBackupStorageTargetReq) (resp cluster.SaveBackupStorageTargetResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin SaveBackupStorageTarget, target: %s, name: %s", request.TargetID, request.Target.Name)
+	defer framework.LogWithContext(ctx).Infof("End SaveBackupStorageTarget")
+
+	if err = saveBackupStorageTargetPreCheck(request); err != nil {
+		framework.LogWithContext(ctx).Errorf("save backup storage target precheck failed, %s", err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_PARAMETER_INVALID, fmt.Sprintf("save backup storage target precheck failed, %s", err.Error()), err)
+	}
+
+	brRW := models.GetBRReaderWriter()
+	var target *backuprestore.BackupStorageTarget
+	if request.TargetID == "" {
+		target, err = brRW.CreateBackupStorageTarget(ctx, &backuprestore.BackupStorageTarget{
+			Entity: dbModel.Entity{
+				TenantId: framework.GetTenantIDFromContext(ctx),
+			},
+			Name:            request.Target.Name,
+			StorageType:     request.Target.StorageType,
+			FilePath:        request.Target.FilePath,
+			Endpoint:        request.Target.Endpoint,
+			AccessKey:       request.Target.AccessKey,
+			SecretAccessKey: dbModel.Password(request.Target.SecretAccessKey),
+		})
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("create backup storage target %s failed, %s", request.Target.Name, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_SAVE_FAILED, fmt.Sprintf("create backup storage target %s failed, %s", request.Target.Name, err.Error()), err)
+		}
+	} else {
+		target, err = brRW.GetBackupStorageTarget(ctx, request.TargetID)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("get backup storage target %s failed, %s", request.TargetID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, fmt.Sprintf("get backup storage target %s failed, %s", request.TargetID, err.Error()), err)
+		}
+		if target.StorageType != request.Target.StorageType {
+			return resp, errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "storage type of backup storage target %s can not be changed", request.TargetID)
+		}
+		target.Name = request.Target.Name
+		target.FilePath = request.Target.FilePath
+		target.Endpoint = request.Target.Endpoint
+		target.AccessKey = request.Target.AccessKey
+		// keep the secret access key unless a new one is given, as it is never returned
+		if request.Target.SecretAccessKey != "" {
+			target.SecretAccessKey = dbModel.Password(request.Target.SecretAccessKey)
+		}
+		if err = brRW.UpdateBackupStorageTarget(ctx, target); err != nil {
+			framework.LogWithContext(ctx).Errorf("update backup storage target %s failed, %s", request.TargetID, err.Error())
+			return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_SAVE_FAILED, fmt.Sprintf("update backup storage target %s failed, %s", request.TargetID, err.Error()), err)
+		}
+	}
+
+	resp.Target = convertBackupStorageTarget(target)
+	return resp, nil
+}
+
+func (mgr *BRManager) QueryBackupStorageTargets(ctx context.Context, request cluster.QueryBackupStorageTargetsReq) (resp cluster.QueryBackupStorageTargetsResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin QueryBackupStorageTargets")
+	defer framework.LogWithContext(ctx).Infof("End QueryBackupStorageTargets")
+
+	targets, err := models.GetBRReaderWriter().QueryBackupStorageTargets(ctx)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query backup storage targets failed, %s", err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_QUERY_FAILED, fmt.Sprintf("query backup storage targets failed, %s", err.Error()), err)
+	}
+
+	resp.Targets = make([]structs.BackupStorageTarget, 0, len(targets))
+	for _, target := range targets {
+		resp.Targets = append(resp.Targets, convertBackupStorageTarget(target))
+	}
+	return resp, nil
+}
+
+func (mgr *BRManager) DeleteBackupStorageTarget(ctx context.Context, request cluster.DeleteBackupStorageTargetReq) (resp cluster.DeleteBackupStorageTargetResp, err error) {
+	framework.LogWithContext(ctx).Infof("Begin DeleteBackupStorageTarget, request: %+v", request)
+	defer framework.LogWithContext(ctx).Infof("End DeleteBackupStorageTarget")
+
+	brRW := models.GetBRReaderWriter()
+	if _, err = brRW.GetBackupStorageTarget(ctx, request.TargetID); err != nil {
+		framework.LogWithContext(ctx).Errorf("get backup storage target %s failed, %s", request.TargetID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, fmt.Sprintf("get backup storage target %s failed, %s", request.TargetID, err.Error()), err)
+	}
+	count, err := brRW.CountBackupStorageTargetUsage(ctx, request.TargetID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("count usage of backup storage target %s failed, %s", request.TargetID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_QUERY_FAILED, fmt.Sprintf("count usage of backup storage target %s failed, %s", request.TargetID, err.Error()), err)
+	}
+	if count > 0 {
+		return resp, errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_TARGET_IN_USE, "backup storage target %s is used by %d backup strategies or copies", request.TargetID, count)
+	}
+	if err = brRW.DeleteBackupStorageTarget(ctx, request.TargetID); err != nil {
+		framework.LogWithContext(ctx).Errorf("delete backup storage target %s failed, %s", request.TargetID, err.Error())
+		return resp, errors.WrapError(errors.TIUNIMANAGER_BACKUP_TARGET_DELETE_FAILED, fmt.Sprintf("delete backup storage target %s failed, %s", request.TargetID, err.Error()), err)
+	}
+
+	return resp, nil
+}
+
+func saveBackupStorageTargetPreCheck(request cluster.SaveBackupStorageTargetReq) error {
+	target := request.Target
+	if target.Name == "" || len(target.Name) > 64 {
+		return fmt.Errorf("invalid param name, %s", target.Name)
+	}
+	if target.FilePath == "" {
+		return fmt.Errorf("file path of backup storage target is required")
+	}
+	switch target.StorageType {
+	case string(constants.StorageTypeNFS):
+	case string(constants.StorageTypeS3):
+		if target.Endpoint == "" || target.AccessKey == "" {
+			return fmt.Errorf("endpoint and access key of s3 backup storage target are required")
+		}
+		if request.TargetID == "" && target.SecretAccessKey == "" {
+			return fmt.Errorf("secret access key of s3 backup storage target is required")
+		}
+	default:
+		return fmt.Errorf("invalid param storage type, %s", target.StorageType)
+	}
+	return nil
+}
+
+// getS3Config
+// @Description: get endpoint and credentials of s3 storage target, or the backup s3 storage of system config if storageTargetID is empty
+// @Parameter ctx
+// @Parameter storageTargetID
+// @return *s3Config
+// @return error
+func getS3Config(ctx context.Context, storageTargetID string) (*s3Config, error) {
+	if storageTargetID != "" {
+		target, err := models.GetBRReaderWriter().GetBackupStorageTarget(ctx, storageTargetID)
+		if err != nil {
+			return nil, fmt.Errorf("get backup storage target %s failed, %s", storageTargetID, err.Error())
+		}
+		return &s3Config{
+			Endpoint:        target.Endpoint,
+			AccessKey:       target.AccessKey,
+			SecretAccessKey: string(target.SecretAccessKey),
+		}, nil
+	}
+
+	configRW := models.GetConfigReaderWriter()
+	endpointConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("get conifg %s failed, %s", constants.ConfigKeyBackupS3Endpoint, err.Error())
+	}
+	accessKeyConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3AccessKey)
+	if err != nil {
+		return nil, fmt.Errorf("get conifg %s failed, %s", constants.ConfigKeyBackupS3AccessKey, err.Error())
+	}
+	secretAccessKeyConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupS3SecretAccessKey)
+	if err != nil {
+		return nil, fmt.Errorf("get conifg %s failed, %s", constants.ConfigKeyBackupS3SecretAccessKey, err.Error())
+	}
+	return &s3Config{
+		Endpoint:        endpointConfig.ConfigValue,
+		AccessKey:       accessKeyConfig.ConfigValue,
+		SecretAccessKey: secretAccessKeyConfig.ConfigValue,
+	}, nil
+}
+
+func convertBackupStorageTarget(target *backuprestore.BackupStorageTarget) structs.BackupStorageTarget {
+	return structs.BackupStorageTarget{
+		ID:          target.ID,
+		Name:        target.Name,
+		StorageType: target.StorageType,
+		FilePath:    target.FilePath,
+		Endpoint:    target.Endpoint,
+		AccessKey:   target.AccessKey,
+		CreateTime:  target.CreatedAt,
+		UpdateTime:  target.UpdatedAt,
+	}
+}
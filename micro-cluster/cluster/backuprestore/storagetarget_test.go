@@ -0,0 +1,194 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockBackupStorageTarget() *backuprestore.BackupStorageTarget {
+	return &backuprestore.BackupStorageTarget{
+		Entity:          common.Entity{ID: "target-xxx"},
+		Name:            "offsite",
+		StorageType:     string(constants.StorageTypeS3),
+		FilePath:        "bucket/prefix",
+		Endpoint:        "http://127.0.0.1:9000",
+		AccessKey:       "ak",
+		SecretAccessKey: "sk",
+	}
+}
+
+func TestBRManager_SaveBackupStorageTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("create", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().CreateBackupStorageTarget(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, target *backuprestore.BackupStorageTarget) (*backuprestore.BackupStorageTarget, error) {
+			assert.Equal(t, common.Password("sk"), target.SecretAccessKey)
+			target.ID = "target-xxx"
+			return target, nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		resp, err := GetBRService().SaveBackupStorageTarget(context.TODO(), cluster.SaveBackupStorageTargetReq{
+			Target: structs.BackupStorageTarget{
+				Name:            "offsite",
+				StorageType:     string(constants.StorageTypeS3),
+				FilePath:        "bucket/prefix",
+				Endpoint:        "http://127.0.0.1:9000",
+				AccessKey:       "ak",
+				SecretAccessKey: "sk",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "target-xxx", resp.Target.ID)
+		assert.Empty(t, resp.Target.SecretAccessKey)
+	})
+	t.Run("update keeps secret access key", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(mockBackupStorageTarget(), nil)
+		brRW.EXPECT().UpdateBackupStorageTarget(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, target *backuprestore.BackupStorageTarget) error {
+			assert.Equal(t, "bucket/another", target.FilePath)
+			assert.Equal(t, common.Password("sk"), target.SecretAccessKey)
+			return nil
+		})
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().SaveBackupStorageTarget(context.TODO(), cluster.SaveBackupStorageTargetReq{
+			TargetID: "target-xxx",
+			Target: structs.BackupStorageTarget{
+				Name:        "offsite",
+				StorageType: string(constants.StorageTypeS3),
+				FilePath:    "bucket/another",
+				Endpoint:    "http://127.0.0.1:9000",
+				AccessKey:   "ak",
+			},
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("storage type changed", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(mockBackupStorageTarget(), nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().SaveBackupStorageTarget(context.TODO(), cluster.SaveBackupStorageTargetReq{
+			TargetID: "target-xxx",
+			Target:   structs.BackupStorageTarget{Name: "offsite", StorageType: string(constants.StorageTypeNFS), FilePath: "/offsite"},
+		})
+		assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, target := range []structs.BackupStorageTarget{
+			{Name: "", StorageType: string(constants.StorageTypeNFS), FilePath: "/offsite"},
+			{Name: "offsite", StorageType: string(constants.StorageTypeNFS)},
+			{Name: "offsite", StorageType: "ftp", FilePath: "/offsite"},
+			{Name: "offsite", StorageType: string(constants.StorageTypeS3), FilePath: "bucket/prefix", Endpoint: "http://127.0.0.1:9000", AccessKey: "ak"},
+		} {
+			_, err := GetBRService().SaveBackupStorageTarget(context.TODO(), cluster.SaveBackupStorageTargetReq{Target: target})
+			assert.Equal(t, emerr.TIUNIMANAGER_PARAMETER_INVALID, err.(emerr.EMError).GetCode())
+		}
+	})
+}
+
+func TestBRManager_QueryBackupStorageTargets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().QueryBackupStorageTargets(gomock.Any()).Return([]*backuprestore.BackupStorageTarget{mockBackupStorageTarget()}, nil)
+	brRW.EXPECT().QueryBackupStorageTargets(gomock.Any()).Return(nil, fmt.Errorf("query failed"))
+	models.SetBRReaderWriter(brRW)
+
+	resp, err := GetBRService().QueryBackupStorageTargets(context.TODO(), cluster.QueryBackupStorageTargetsReq{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Targets))
+	assert.Equal(t, "offsite", resp.Targets[0].Name)
+	assert.Empty(t, resp.Targets[0].SecretAccessKey)
+
+	_, err = GetBRService().QueryBackupStorageTargets(context.TODO(), cluster.QueryBackupStorageTargetsReq{})
+	assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_TARGET_QUERY_FAILED, err.(emerr.EMError).GetCode())
+}
+
+func TestBRManager_DeleteBackupStorageTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(mockBackupStorageTarget(), nil)
+		brRW.EXPECT().CountBackupStorageTargetUsage(gomock.Any(), "target-xxx").Return(int64(0), nil)
+		brRW.EXPECT().DeleteBackupStorageTarget(gomock.Any(), "target-xxx").Return(nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().DeleteBackupStorageTarget(context.TODO(), cluster.DeleteBackupStorageTargetReq{TargetID: "target-xxx"})
+		assert.NoError(t, err)
+	})
+	t.Run("in use", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(mockBackupStorageTarget(), nil)
+		brRW.EXPECT().CountBackupStorageTargetUsage(gomock.Any(), "target-xxx").Return(int64(2), nil)
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().DeleteBackupStorageTarget(context.TODO(), cluster.DeleteBackupStorageTargetReq{TargetID: "target-xxx"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_TARGET_IN_USE, err.(emerr.EMError).GetCode())
+	})
+	t.Run("not found", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-yyy").Return(nil, fmt.Errorf("not found"))
+		models.SetBRReaderWriter(brRW)
+
+		_, err := GetBRService().DeleteBackupStorageTarget(context.TODO(), cluster.DeleteBackupStorageTargetReq{TargetID: "target-yyy"})
+		assert.Equal(t, emerr.TIUNIMANAGER_BACKUP_TARGET_NOT_FOUND, err.(emerr.EMError).GetCode())
+	})
+}
+
+func Test_getS3Config(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	brRW.EXPECT().GetBackupStorageTarget(gomock.Any(), "target-xxx").Return(mockBackupStorageTarget(), nil)
+	models.SetBRReaderWriter(brRW)
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupS3Endpoint).Return(&config.SystemConfig{ConfigValue: "http://minio:9000"}, nil)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupS3AccessKey).Return(&config.SystemConfig{ConfigValue: "system-ak"}, nil)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupS3SecretAccessKey).Return(&config.SystemConfig{ConfigValue: "system-sk"}, nil)
+	models.SetConfigReaderWriter(configRW)
+
+	targetConfig, err := getS3Config(context.TODO(), "target-xxx")
+	assert.NoError(t, err)
+	assert.Equal(t, s3Config{Endpoint: "http://127.0.0.1:9000", AccessKey: "ak", SecretAccessKey: "sk"}, *targetConfig)
+
+	systemConfig, err := getS3Config(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, s3Config{Endpoint: "http://minio:9000", AccessKey: "system-ak", SecretAccessKey: "system-sk"}, *systemConfig)
+}
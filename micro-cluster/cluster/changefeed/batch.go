@@ -0,0 +1,216 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+)
+
+// BatchPause
+// @Description: pause selected change feed tasks one by one, tasks already stopped are skipped
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp result of each task
+// @return err if tasks cannot be selected
+func (p *Manager) BatchPause(ctx context.Context, request cluster.BatchChangeFeedTasksReq) (resp cluster.BatchChangeFeedTasksResp, err error) {
+	return p.batch(ctx, request.ChangeFeedTaskSelector, func(task *changefeed.ChangeFeedTask) (string, error) {
+		if task.Status == constants.ChangeFeedStatusStopped.ToString() {
+			return task.Status, nil
+		}
+		_, err := p.Pause(ctx, cluster.PauseChangeFeedTaskReq{ID: task.ID})
+		return constants.ChangeFeedStatusStopped.ToString(), err
+	})
+}
+
+// BatchResume
+// @Description: resume selected change feed tasks one by one, tasks already running are skipped
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp result of each task
+// @return err if tasks cannot be selected
+func (p *Manager) BatchResume(ctx context.Context, request cluster.BatchChangeFeedTasksReq) (resp cluster.BatchChangeFeedTasksResp, err error) {
+	return p.batch(ctx, request.ChangeFeedTaskSelector, func(task *changefeed.ChangeFeedTask) (string, error) {
+		if task.Status == constants.ChangeFeedStatusNormal.ToString() {
+			return task.Status, nil
+		}
+		_, err := p.Resume(ctx, cluster.ResumeChangeFeedTaskReq{ID: task.ID})
+		return constants.ChangeFeedStatusNormal.ToString(), err
+	})
+}
+
+// BatchDelete
+// @Description: delete selected change feed tasks one by one
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp result of each task
+// @return err if tasks cannot be selected
+func (p *Manager) BatchDelete(ctx context.Context, request cluster.BatchChangeFeedTasksReq) (resp cluster.BatchChangeFeedTasksResp, err error) {
+	return p.batch(ctx, request.ChangeFeedTaskSelector, func(task *changefeed.ChangeFeedTask) (string, error) {
+		_, err := p.Delete(ctx, cluster.DeleteChangeFeedTaskReq{ID: task.ID})
+		return task.Status, err
+	})
+}
+
+// batch
+// @Description: execute operation on each selected task, failure of one task does not stop the others
+func (p *Manager) batch(ctx context.Context, selector cluster.ChangeFeedTaskSelector, operation func(task *changefeed.ChangeFeedTask) (string, error)) (resp cluster.BatchChangeFeedTasksResp, err error) {
+	tasks, err := selectTasks(ctx, selector)
+	if err != nil {
+		return
+	}
+
+	resp.Results = make([]cluster.ChangeFeedTaskResult, 0, len(tasks))
+	for _, task := range tasks {
+		result := cluster.ChangeFeedTaskResult{
+			ID:     task.ID,
+			Name:   task.Name,
+			Status: task.Status,
+		}
+		status, operationErr := operation(task)
+		if operationErr != nil {
+			framework.LogWithContext(ctx).Errorf("batch operation on change feed task %s failed, err = %s", task.ID, operationErr.Error())
+			result.Message = operationErr.Error()
+		} else {
+			result.Succeed = true
+			result.Status = status
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return
+}
+
+// ExportTasks
+// @Description: export selected change feed tasks, secrets of downstream are masked
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) ExportTasks(ctx context.Context, request cluster.ExportChangeFeedTasksReq) (resp cluster.ExportChangeFeedTasksResp, err error) {
+	tasks, err := selectTasks(ctx, request.ChangeFeedTaskSelector)
+	if err != nil {
+		return
+	}
+
+	resp.ClusterID = request.ClusterID
+	resp.ExportTime = time.Now()
+	resp.Tasks = make([]cluster.ChangeFeedTaskExport, 0, len(tasks))
+	for _, task := range tasks {
+		info := parse(*task)
+		resp.Tasks = append(resp.Tasks, cluster.ChangeFeedTaskExport{
+			SourceTaskID:   info.ID,
+			Name:           info.Name,
+			FilterRules:    info.FilterRules,
+			DownstreamType: info.DownstreamType,
+			Downstream:     info.Downstream,
+			AutoResume:     info.AutoResume,
+		})
+	}
+	return
+}
+
+// ImportTasks
+// @Description: create exported change feed tasks in target cluster one by one,
+// masked secrets are copied from source task if it still exists, otherwise they are cleared
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp result of each task
+// @return err
+func (p *Manager) ImportTasks(ctx context.Context, request cluster.ImportChangeFeedTasksReq) (resp cluster.ImportChangeFeedTasksResp, err error) {
+	resp.Results = make([]cluster.ChangeFeedTaskResult, 0, len(request.Tasks))
+	for _, exported := range request.Tasks {
+		result := cluster.ChangeFeedTaskResult{Name: exported.Name}
+
+		var previous changefeed.ChangeFeedDownStream
+		if source, getErr := models.GetChangeFeedReaderWriter().Get(ctx, exported.SourceTaskID); getErr == nil && string(source.Type) == exported.DownstreamType {
+			previous = source.Downstream
+		} else if empty, emptyErr := changefeed.UnmarshalDownstream(constants.DownstreamType(exported.DownstreamType), "{}"); emptyErr == nil {
+			// never create task with masked secrets
+			if _, ok := empty.(changefeed.SensitiveDownstream); ok {
+				framework.LogWithContext(ctx).Warnf("source change feed task %s not found, secrets of downstream are cleared", exported.SourceTaskID)
+				result.Message = "secrets of downstream are not restored, update them if required"
+			}
+			previous = empty
+		}
+
+		created, createErr := p.create(ctx, cluster.CreateChangeFeedTaskReq{
+			Name:           exported.Name,
+			ClusterID:      request.ClusterID,
+			FilterRules:    exported.FilterRules,
+			DownstreamType: exported.DownstreamType,
+			Downstream:     exported.Downstream,
+			AutoResume:     exported.AutoResume,
+			StartTime:      request.StartTime,
+		}, previous)
+		if createErr != nil {
+			framework.LogWithContext(ctx).Errorf("import change feed task %s failed, err = %s", exported.Name, createErr.Error())
+			result.Message = createErr.Error()
+		} else {
+			result.ID = created.ID
+			result.Succeed = true
+			result.Status = constants.ChangeFeedStatusNormal.ToString()
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return
+}
+
+// selectTasks
+// @Description: get tasks by ids, or query tasks of cluster with downstream type and status filters
+func selectTasks(ctx context.Context, selector cluster.ChangeFeedTaskSelector) ([]*changefeed.ChangeFeedTask, error) {
+	if len(selector.IDs) > 0 {
+		tasks := make([]*changefeed.ChangeFeedTask, 0, len(selector.IDs))
+		for _, id := range selector.IDs {
+			task, err := models.GetChangeFeedReaderWriter().Get(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, task)
+		}
+		return tasks, nil
+	}
+
+	if len(selector.ClusterID) == 0 {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "either task ids or cluster id is required")
+	}
+	downstreamTypes := make([]constants.DownstreamType, 0, len(selector.DownstreamTypes))
+	for _, t := range selector.DownstreamTypes {
+		downstreamTypes = append(downstreamTypes, constants.DownstreamType(t))
+	}
+	statuses := make([]constants.ChangeFeedStatus, 0, len(selector.Statuses))
+	for _, s := range selector.Statuses {
+		status, err := constants.ConvertChangeFeedStatus(s)
+		if err != nil {
+			return nil, errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid change feed status %s", s)
+		}
+		statuses = append(statuses, status)
+	}
+
+	tasks, _, err := models.GetChangeFeedReaderWriter().Query(ctx, selector.ClusterID, downstreamTypes, statuses, 0, 0)
+	return tasks, err
+}
@@ -0,0 +1,286 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockchangefeed"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockutilcdc"
+	"github.com/pingcap/tiunimanager/util/api/cdc"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockBatchTask(id string, status constants.ChangeFeedStatus) *changefeed.ChangeFeedTask {
+	return &changefeed.ChangeFeedTask{
+		Entity:    common.Entity{ID: id, Status: status.ToString()},
+		Name:      "name-" + id,
+		ClusterId: "clusterId",
+		Type:      constants.DownstreamTypeStorage,
+		Downstream: &changefeed.StorageDownstream{
+			StorageType:     "s3",
+			Path:            "bucket",
+			AccessKey:       "ak",
+			SecretAccessKey: "sk",
+		},
+	}
+}
+
+func mockBatchCluster(ctrl *gomock.Controller) {
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(&management.Cluster{}, []*management.ClusterInstance{
+		{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{111}},
+	}, []*management.DBUser{
+		{ClusterID: "clusterId", Name: "root", Password: common.PasswordInExpired{Val: "123455678"}, RoleType: string(constants.Root)},
+	}, nil).AnyTimes()
+}
+
+func TestManager_BatchPause(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockBatchCluster(ctrl)
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Get(gomock.Any(), "running").Return(mockBatchTask("running", constants.ChangeFeedStatusNormal), nil).AnyTimes()
+	changefeedRW.EXPECT().Get(gomock.Any(), "stopped").Return(mockBatchTask("stopped", constants.ChangeFeedStatusStopped), nil).AnyTimes()
+	changefeedRW.EXPECT().Get(gomock.Any(), "locked").Return(mockBatchTask("locked", constants.ChangeFeedStatusNormal), nil).AnyTimes()
+	changefeedRW.EXPECT().Get(gomock.Any(), "unknown").Return(nil, errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_NOT_FOUND)).AnyTimes()
+	changefeedRW.EXPECT().LockStatus(gomock.Any(), "running").Return(nil).AnyTimes()
+	changefeedRW.EXPECT().LockStatus(gomock.Any(), "locked").Return(errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_STATUS_CONFLICT)).AnyTimes()
+	changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "running", constants.ChangeFeedStatusStopped).Return(nil).Times(1)
+
+	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+	cdc.CDCService = mockCDCService
+	mockCDCService.EXPECT().PauseChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+		Accepted: true,
+		Succeed:  true,
+	}, nil).Times(1)
+
+	t.Run("by ids", func(t *testing.T) {
+		resp, err := GetManager().BatchPause(context.TODO(), cluster.BatchChangeFeedTasksReq{
+			ChangeFeedTaskSelector: cluster.ChangeFeedTaskSelector{IDs: []string{"running", "stopped", "locked"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(resp.Results))
+		assert.True(t, resp.Results[0].Succeed)
+		assert.Equal(t, constants.ChangeFeedStatusStopped.ToString(), resp.Results[0].Status)
+		assert.True(t, resp.Results[1].Succeed)
+		assert.False(t, resp.Results[2].Succeed)
+		assert.Equal(t, constants.ChangeFeedStatusNormal.ToString(), resp.Results[2].Status)
+		assert.NotEmpty(t, resp.Results[2].Message)
+	})
+	t.Run("task not found", func(t *testing.T) {
+		_, err := GetManager().BatchPause(context.TODO(), cluster.BatchChangeFeedTasksReq{
+			ChangeFeedTaskSelector: cluster.ChangeFeedTaskSelector{IDs: []string{"running", "unknown"}},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CHANGE_FEED_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+	t.Run("without selector", func(t *testing.T) {
+		_, err := GetManager().BatchPause(context.TODO(), cluster.BatchChangeFeedTasksReq{})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+	})
+}
+
+func TestManager_BatchResume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockBatchCluster(ctrl)
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Query(gomock.Any(), "clusterId",
+		[]constants.DownstreamType{constants.DownstreamTypeStorage},
+		[]constants.ChangeFeedStatus{constants.ChangeFeedStatusStopped, constants.ChangeFeedStatusNormal}, 0, 0).
+		Return([]*changefeed.ChangeFeedTask{
+			mockBatchTask("stopped", constants.ChangeFeedStatusStopped),
+			mockBatchTask("running", constants.ChangeFeedStatusNormal),
+		}, int64(2), nil).Times(1)
+	changefeedRW.EXPECT().Get(gomock.Any(), "stopped").Return(mockBatchTask("stopped", constants.ChangeFeedStatusStopped), nil).Times(1)
+	changefeedRW.EXPECT().LockStatus(gomock.Any(), "stopped").Return(nil).Times(1)
+	changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "stopped", constants.ChangeFeedStatusNormal).Return(nil).Times(1)
+
+	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+	cdc.CDCService = mockCDCService
+	mockCDCService.EXPECT().ResumeChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+		Accepted: true,
+		Succeed:  true,
+	}, nil).Times(1)
+
+	t.Run("by cluster", func(t *testing.T) {
+		resp, err := GetManager().BatchResume(context.TODO(), cluster.BatchChangeFeedTasksReq{
+			ChangeFeedTaskSelector: cluster.ChangeFeedTaskSelector{
+				ClusterID:       "clusterId",
+				DownstreamTypes: []string{"storage"},
+				Statuses:        []string{"Stopped", "Normal"},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(resp.Results))
+		for _, r := range resp.Results {
+			assert.True(t, r.Succeed)
+			assert.Equal(t, constants.ChangeFeedStatusNormal.ToString(), r.Status)
+		}
+	})
+	t.Run("invalid status", func(t *testing.T) {
+		_, err := GetManager().BatchResume(context.TODO(), cluster.BatchChangeFeedTasksReq{
+			ChangeFeedTaskSelector: cluster.ChangeFeedTaskSelector{
+				ClusterID: "clusterId",
+				Statuses:  []string{"Running"},
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+	})
+}
+
+func TestManager_BatchDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockBatchCluster(ctrl)
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Query(gomock.Any(), "clusterId", []constants.DownstreamType{}, []constants.ChangeFeedStatus{}, 0, 0).
+		Return([]*changefeed.ChangeFeedTask{
+			mockBatchTask("task1", constants.ChangeFeedStatusStopped),
+			mockBatchTask("task2", constants.ChangeFeedStatusNormal),
+		}, int64(2), nil).Times(1)
+	changefeedRW.EXPECT().Get(gomock.Any(), "task1").Return(mockBatchTask("task1", constants.ChangeFeedStatusStopped), nil).Times(1)
+	changefeedRW.EXPECT().Get(gomock.Any(), "task2").Return(mockBatchTask("task2", constants.ChangeFeedStatusNormal), nil).Times(1)
+	changefeedRW.EXPECT().Delete(gomock.Any(), "task1").Return(nil).Times(1)
+	changefeedRW.EXPECT().Delete(gomock.Any(), "task2").Return(errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_NOT_FOUND)).Times(1)
+
+	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+	cdc.CDCService = mockCDCService
+	mockCDCService.EXPECT().DeleteChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+		Accepted: true,
+		Succeed:  true,
+	}, nil).Times(2)
+
+	resp, err := GetManager().BatchDelete(context.TODO(), cluster.BatchChangeFeedTasksReq{
+		ChangeFeedTaskSelector: cluster.ChangeFeedTaskSelector{ClusterID: "clusterId"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resp.Results))
+	assert.True(t, resp.Results[0].Succeed)
+	assert.False(t, resp.Results[1].Succeed)
+}
+
+func TestManager_ExportTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Query(gomock.Any(), "clusterId", gomock.Any(), gomock.Any(), 0, 0).
+		Return([]*changefeed.ChangeFeedTask{
+			mockBatchTask("task1", constants.ChangeFeedStatusNormal),
+			{
+				Entity:     common.Entity{ID: "task2", Status: constants.ChangeFeedStatusNormal.ToString()},
+				Name:       "name-task2",
+				ClusterId:  "clusterId",
+				Type:       constants.DownstreamTypeTiDB,
+				Downstream: &changefeed.TiDBDownstream{Ip: "127.0.0.1", Port: 4000, Username: "root", Password: "password"},
+			},
+		}, int64(2), nil).Times(1)
+
+	resp, err := GetManager().ExportTasks(context.TODO(), cluster.ExportChangeFeedTasksReq{
+		ChangeFeedTaskSelector: cluster.ChangeFeedTaskSelector{ClusterID: "clusterId"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "clusterId", resp.ClusterID)
+	assert.Equal(t, 2, len(resp.Tasks))
+	assert.Equal(t, "task1", resp.Tasks[0].SourceTaskID)
+	assert.Equal(t, "name-task1", resp.Tasks[0].Name)
+	assert.Equal(t, "storage", resp.Tasks[0].DownstreamType)
+	downstream := resp.Tasks[0].Downstream.(*changefeed.StorageDownstream)
+	assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), downstream.AccessKey)
+	assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), downstream.SecretAccessKey)
+	tidb := resp.Tasks[1].Downstream.(*changefeed.TiDBDownstream)
+	assert.Equal(t, structs.SensitiveTextMask, tidb.Password)
+	assert.Equal(t, "root", tidb.Username)
+}
+
+func TestManager_ImportTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockBatchCluster(ctrl)
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Get(gomock.Any(), "task1").Return(mockBatchTask("task1", constants.ChangeFeedStatusNormal), nil).Times(1)
+	changefeedRW.EXPECT().Get(gomock.Any(), "deleted").Return(nil, errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_NOT_FOUND)).Times(1)
+	created := make([]*changefeed.ChangeFeedTask, 0)
+	changefeedRW.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, task *changefeed.ChangeFeedTask) (*changefeed.ChangeFeedTask, error) {
+		created = append(created, task)
+		task.ID = "newTask"
+		return task, nil
+	}).Times(2)
+	changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "newTask", constants.ChangeFeedStatusNormal).Return(nil).Times(2)
+
+	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+	cdc.CDCService = mockCDCService
+	mockCDCService.EXPECT().CreateChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+		Accepted: true,
+		Succeed:  true,
+	}, nil).Times(2)
+
+	masked := changefeed.StorageDownstream{
+		StorageType:     "s3",
+		Path:            "bucket",
+		AccessKey:       structs.SensitiveTextMask,
+		SecretAccessKey: structs.SensitiveTextMask,
+	}
+	resp, err := GetManager().ImportTasks(context.TODO(), cluster.ImportChangeFeedTasksReq{
+		ClusterID: "targetClusterId",
+		Tasks: []cluster.ChangeFeedTaskExport{
+			{SourceTaskID: "task1", Name: "name-task1", DownstreamType: "storage", Downstream: masked},
+			{SourceTaskID: "deleted", Name: "name-deleted", DownstreamType: "storage", Downstream: masked},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resp.Results))
+	assert.True(t, resp.Results[0].Succeed)
+	assert.Equal(t, "newTask", resp.Results[0].ID)
+	assert.Empty(t, resp.Results[0].Message)
+	assert.True(t, resp.Results[1].Succeed)
+	assert.NotEmpty(t, resp.Results[1].Message)
+
+	assert.Equal(t, 2, len(created))
+	restored := created[0].Downstream.(*changefeed.StorageDownstream)
+	assert.Equal(t, "targetClusterId", created[0].ClusterId)
+	assert.Equal(t, structs.SensitiveText("ak"), restored.AccessKey)
+	assert.Equal(t, structs.SensitiveText("sk"), restored.SecretAccessKey)
+	// source task is deleted, masked secrets are cleared
+	cleared := created[1].Downstream.(*changefeed.StorageDownstream)
+	assert.Empty(t, cleared.AccessKey)
+	assert.Empty(t, cleared.SecretAccessKey)
+}
@@ -0,0 +1,510 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/util/api/cdc"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+)
+
+const (
+	contextConsistencyCheckID = "ConsistencyCheckID"
+	contextConsistencyMessage = "ConsistencyCheckMessage"
+)
+
+// replaced in unit tests, they connect to databases
+var querySyncPoint = utilsql.QuerySyncPoint
+var diffTables = utilsql.DiffTables
+
+var verifyConsistencyFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowVerifyConsistency,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":            {"resolveConsistencySnapshot", "snapshotResolved", "fail", workflow.SyncFuncNode, resolveConsistencySnapshot},
+		"snapshotResolved": {"compareTables", "compared", "fail", workflow.SyncFuncNode, compareConsistencyTables},
+		"compared":         {"end", "", "", workflow.SyncFuncNode, consistencyCheckEnd},
+		"fail":             {"fail", "", "", workflow.SyncFuncNode, consistencyCheckFail},
+	},
+}
+
+var consistencyOnce sync.Once
+
+// InitConsistencyCheck
+// @Description: register workflow of consistency check and evaluate consistency check schedules in background
+func InitConsistencyCheck() {
+	consistencyOnce.Do(func() {
+		workflow.GetWorkFlowService().RegisterWorkFlow(context.TODO(), constants.FlowVerifyConsistency, &verifyConsistencyFlow)
+		startConsistencyScheduler()
+	})
+}
+
+// StartConsistencyCheck
+// @Description: compare data between upstream cluster and TiDB downstream of change feed task asynchronously
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) StartConsistencyCheck(ctx context.Context, request cluster.StartConsistencyCheckReq) (resp cluster.StartConsistencyCheckResp, err error) {
+	check, err := startConsistencyCheck(ctx, request.ChangeFeedTaskID, request.ConsistencyCheckOptions, constants.ConsistencyCheckTriggerManual)
+	if err != nil {
+		return
+	}
+	resp.CheckID = check.ID
+	resp.WorkFlowID = check.WorkFlowId
+	return
+}
+
+// QueryConsistencyChecks
+// @Description: query consistency checks by upstream cluster, downstream cluster or change feed task
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resps
+// @return total
+// @return err
+func (p *Manager) QueryConsistencyChecks(ctx context.Context, request cluster.QueryConsistencyChecksReq) (resps []cluster.ConsistencyCheck, total int64, err error) {
+	statuses := make([]constants.ConsistencyCheckStatus, 0)
+	if request.Status != "" {
+		statuses = append(statuses, constants.ConsistencyCheckStatus(request.Status))
+	}
+	checks, total, err := models.GetChangeFeedReaderWriter().QueryConsistencyChecks(ctx,
+		request.ClusterID, request.DownstreamClusterID, request.ChangeFeedTaskID, statuses, request.GetOffset(), request.PageSize)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query consistency checks failed, %s", err.Error())
+		return
+	}
+	resps = make([]cluster.ConsistencyCheck, 0, len(checks))
+	for _, check := range checks {
+		resps = append(resps, parseConsistencyCheck(check))
+	}
+	return
+}
+
+// DetailConsistencyCheck
+// @Description: get consistency check with mismatched tables and fix SQL
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) DetailConsistencyCheck(ctx context.Context, request cluster.DetailConsistencyCheckReq) (resp cluster.DetailConsistencyCheckResp, err error) {
+	check, err := models.GetChangeFeedReaderWriter().GetConsistencyCheck(ctx, request.CheckID)
+	if err != nil {
+		return
+	}
+	resp.ConsistencyCheck = parseConsistencyCheck(check)
+	resp.Tables = make([]cluster.ConsistencyTableDiff, 0)
+	if check.Result != "" {
+		if err = json.Unmarshal([]byte(check.Result), &resp.Tables); err != nil {
+			err = errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, fmt.Sprintf("unmarshal result of consistency check %s failed", check.ID), err)
+			return
+		}
+	}
+	resp.FixSQL = splitLines(check.FixSQL)
+	return
+}
+
+// startConsistencyCheck
+// @Description: create a consistency check of change feed task and start its workflow
+func startConsistencyCheck(ctx context.Context, taskID string, options cluster.ConsistencyCheckOptions, trigger constants.ConsistencyCheckTrigger) (*changefeed.ConsistencyCheck, error) {
+	rw := models.GetChangeFeedReaderWriter()
+	task, err := rw.Get(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err = consistencyCheckPreCheck(task, options); err != nil {
+		framework.LogWithContext(ctx).Errorf("consistency check of change feed task %s precheck failed, %s", taskID, err.Error())
+		return nil, err
+	}
+	// checkpoint is required to choose snapshot of upstream
+	if task.Status != constants.ChangeFeedStatusNormal.ToString() && task.Status != constants.ChangeFeedStatusStopped.ToString() {
+		framework.LogWithContext(ctx).Errorf("consistency check of change feed task %s in status %s is not supported", taskID, task.Status)
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_CHANGE_FEED_STATUS_CONFLICT,
+			"consistency check of change feed task in status %s is not supported", task.Status)
+	}
+
+	check := &changefeed.ConsistencyCheck{
+		Entity: dbCommon.Entity{
+			TenantId: task.TenantId,
+			Status:   string(constants.ConsistencyCheckRunning),
+		},
+		TaskId:         task.ID,
+		ClusterId:      task.ClusterId,
+		Trigger:        string(trigger),
+		Databases:      strings.Join(options.Databases, ","),
+		Tables:         strings.Join(options.Tables, ","),
+		GenerateFixSQL: options.GenerateFixSQL,
+		StartTime:      time.Now(),
+	}
+	if downstream, ok := task.Downstream.(*changefeed.TiDBDownstream); ok {
+		check.DownstreamClusterId = downstream.TargetClusterId
+	}
+	if check, err = rw.CreateConsistencyCheck(ctx, check); err != nil {
+		framework.LogWithContext(ctx).Errorf("create consistency check of change feed task %s failed, %s", taskID, err.Error())
+		return nil, err
+	}
+
+	flowManager := workflow.GetWorkFlowService()
+	flowID, err := flowManager.CreateWorkFlow(ctx, task.ClusterId, workflow.BizTypeCluster, verifyConsistencyFlow.FlowName)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create workflow %s failed, %s", verifyConsistencyFlow.FlowName, err.Error())
+		finishConsistencyCheck(ctx, check, constants.ConsistencyCheckFailed, err.Error())
+		return nil, err
+	}
+	check.WorkFlowId = flowID
+	if err = rw.UpdateConsistencyCheck(ctx, check); err != nil {
+		framework.LogWithContext(ctx).Errorf("update workflow of consistency check %s failed, %s", check.ID, err.Error())
+		return nil, err
+	}
+	flowManager.InitContext(ctx, flowID, contextConsistencyCheckID, check.ID)
+	if err = flowManager.Start(ctx, flowID); err != nil {
+		framework.LogWithContext(ctx).Errorf("start workflow %s failed, %s", flowID, err.Error())
+		finishConsistencyCheck(ctx, check, constants.ConsistencyCheckFailed, err.Error())
+		return nil, err
+	}
+	return check, nil
+}
+
+// consistencyCheckPreCheck
+// @Description: only TiDB downstream can be compared, for a consistent snapshot of MySQL downstream is not available,
+// and tables should be in format of db.table
+func consistencyCheckPreCheck(task *changefeed.ChangeFeedTask, options cluster.ConsistencyCheckOptions) error {
+	if task.Type == constants.DownstreamTypeMysql {
+		return errors.NewError(errors.TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM,
+			"consistency check of mysql downstream is not supported, for sync point of change feed is only available in TiDB downstream")
+	}
+	if task.Type != constants.DownstreamTypeTiDB {
+		return errors.NewErrorf(errors.TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM,
+			"consistency check of %s downstream is not supported", task.Type)
+	}
+	for _, table := range options.Tables {
+		if parts := strings.Split(table, "."); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid table %s, it should be in format of db.table", table)
+		}
+	}
+	return nil
+}
+
+func getDownstreamConnParam(task *changefeed.ChangeFeedTask) (utilsql.DbConnParam, error) {
+	switch downstream := task.Downstream.(type) {
+	case *changefeed.TiDBDownstream:
+		return utilsql.DbConnParam{
+			Username: downstream.Username,
+			Password: downstream.Password,
+			IP:       downstream.Ip,
+			Port:     strconv.Itoa(downstream.Port),
+		}, nil
+	default:
+		return utilsql.DbConnParam{}, errors.NewErrorf(errors.TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM,
+			"consistency check of %s downstream is not supported", task.Type)
+	}
+}
+
+func getUpstreamConnParam(ctx context.Context, clusterMeta *meta.ClusterMeta) (utilsql.DbConnParam, error) {
+	address := clusterMeta.GetClusterConnectAddresses()
+	if len(address) == 0 {
+		return utilsql.DbConnParam{}, errors.NewErrorf(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, "cluster %s has no tidb address", clusterMeta.Cluster.ID)
+	}
+	user, err := clusterMeta.GetDBUserNamePassword(ctx, constants.Root)
+	if err != nil {
+		return utilsql.DbConnParam{}, err
+	}
+	return utilsql.DbConnParam{
+		Username: user.Name,
+		Password: user.Password.Val,
+		IP:       address[0].IP,
+		Port:     strconv.Itoa(address[0].Port),
+	}, nil
+}
+
+// getConsistencyCheckTask
+// @Description: load consistency check of workflow and its change feed task
+func getConsistencyCheckTask(context *workflow.FlowContext) (*changefeed.ConsistencyCheck, *changefeed.ChangeFeedTask, error) {
+	var checkID string
+	if err := context.GetData(contextConsistencyCheckID, &checkID); err != nil {
+		return nil, nil, err
+	}
+	rw := models.GetChangeFeedReaderWriter()
+	check, err := rw.GetConsistencyCheck(context, checkID)
+	if err != nil {
+		return nil, nil, setConsistencyMessage(context, err)
+	}
+	task, err := rw.Get(context, check.TaskId)
+	if err != nil {
+		return check, nil, setConsistencyMessage(context, err)
+	}
+	return check, task, nil
+}
+
+// resolveConsistencySnapshot
+// @Description: choose snapshots of upstream and downstream, the latest sync point is preferred,
+// otherwise upstream is read at checkpoint of change feed and downstream is read at current data
+func resolveConsistencySnapshot(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	check, task, err := getConsistencyCheckTask(context)
+	if err != nil {
+		return err
+	}
+
+	if task.Type == constants.DownstreamTypeTiDB {
+		downstream, err := getDownstreamConnParam(task)
+		if err != nil {
+			return setConsistencyMessage(context, err)
+		}
+		primaryTS, secondaryTS, err := querySyncPoint(context, downstream, task.ID)
+		if err != nil {
+			return setConsistencyMessage(context, fmt.Errorf("query sync point of change feed task %s failed, %s", task.ID, err.Error()))
+		}
+		if primaryTS > 0 {
+			check.SnapshotMode = string(constants.ConsistencySnapshotSyncPoint)
+			check.UpstreamTSO, check.DownstreamTSO = primaryTS, secondaryTS
+		}
+	}
+
+	if check.SnapshotMode == "" {
+		clusterMeta, err := meta.Get(context, task.ClusterId)
+		if err != nil {
+			return setConsistencyMessage(context, err)
+		}
+		cdcAddress := clusterMeta.GetCDCClientAddresses()
+		if len(cdcAddress) == 0 {
+			return setConsistencyMessage(context, errors.NewErrorf(errors.TIUNIMANAGER_INVALID_TOPOLOGY, "CDC components required, cluster %s", task.ClusterId))
+		}
+		detail, err := cdc.CDCService.DetailChangeFeedTask(context, cdc.ChangeFeedDetailReq{
+			CDCAddress:   cdcAddress[0].ToString(),
+			ChangeFeedID: task.ID,
+		})
+		if err != nil {
+			return setConsistencyMessage(context, fmt.Errorf("query checkpoint of change feed task %s failed, %s", task.ID, err.Error()))
+		}
+		if detail.CheckPointTSO == 0 {
+			return setConsistencyMessage(context, fmt.Errorf("change feed task %s has no checkpoint", task.ID))
+		}
+		check.SnapshotMode = string(constants.ConsistencySnapshotCheckpoint)
+		check.UpstreamTSO, check.DownstreamTSO = detail.CheckPointTSO, 0
+	}
+
+	if err = models.GetChangeFeedReaderWriter().UpdateConsistencyCheck(context, check); err != nil {
+		return setConsistencyMessage(context, err)
+	}
+	node.Record(fmt.Sprintf("snapshot mode %s, upstream %d, downstream %d", check.SnapshotMode, check.UpstreamTSO, check.DownstreamTSO))
+	return nil
+}
+
+// compareConsistencyTables
+// @Description: compare tables replicated by change feed at the snapshots, and save mismatched tables
+func compareConsistencyTables(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	check, task, err := getConsistencyCheckTask(context)
+	if err != nil {
+		return err
+	}
+	clusterMeta, err := meta.Get(context, task.ClusterId)
+	if err != nil {
+		return setConsistencyMessage(context, err)
+	}
+	upstream, err := getUpstreamConnParam(context, clusterMeta)
+	if err != nil {
+		return setConsistencyMessage(context, err)
+	}
+	downstream, err := getDownstreamConnParam(task)
+	if err != nil {
+		return setConsistencyMessage(context, err)
+	}
+	filterRules := task.FilterRules
+	if len(filterRules) == 0 {
+		filterRules = constants.DefaultFilterRules
+	}
+
+	// downstream is read at current data in checkpoint mode, fix SQL might overwrite or delete newer rows of downstream
+	generateFixSQL := check.GenerateFixSQL && check.SnapshotMode == string(constants.ConsistencySnapshotSyncPoint)
+	diffs, err := diffTables(context, utilsql.DiffTablesReq{
+		Upstream:              upstream,
+		Downstream:            downstream,
+		UpstreamSnapshotTSO:   check.UpstreamTSO,
+		DownstreamSnapshotTSO: check.DownstreamTSO,
+		DbNames:               splitNames(check.Databases),
+		TableNames:            splitNames(check.Tables),
+		FilterRules:           filterRules,
+		GenerateFixSQL:        generateFixSQL,
+	})
+	if err != nil {
+		return setConsistencyMessage(context, fmt.Errorf("compare tables failed, %s", err.Error()))
+	}
+
+	mismatches := make([]cluster.ConsistencyTableDiff, 0)
+	fixSQL := make([]string, 0)
+	check.CheckedTables, check.MismatchTables, check.MismatchChunks = len(diffs), 0, 0
+	for _, diff := range diffs {
+		if diff.Equal {
+			continue
+		}
+		mismatch := cluster.ConsistencyTableDiff{
+			Database: diff.Database,
+			Table:    diff.Table,
+			Message:  diff.Message,
+			Chunks:   diff.Chunks,
+			Mismatch: make([]cluster.ConsistencyChunkDiff, 0, len(diff.MismatchChunks)),
+		}
+		for _, chunk := range diff.MismatchChunks {
+			mismatch.Mismatch = append(mismatch.Mismatch, cluster.ConsistencyChunkDiff{
+				Range:              chunk.Range,
+				UpstreamRows:       chunk.UpstreamRows,
+				DownstreamRows:     chunk.DownstreamRows,
+				UpstreamChecksum:   chunk.UpstreamChecksum,
+				DownstreamChecksum: chunk.DownstreamChecksum,
+			})
+		}
+		mismatches = append(mismatches, mismatch)
+		fixSQL = append(fixSQL, diff.FixSQL...)
+		check.MismatchTables++
+		check.MismatchChunks += len(diff.MismatchChunks)
+	}
+	result, err := json.Marshal(mismatches)
+	if err != nil {
+		return setConsistencyMessage(context, err)
+	}
+	check.Result = string(result)
+	check.FixSQL = strings.Join(fixSQL, "\n")
+
+	status, message := constants.ConsistencyCheckConsistent, fmt.Sprintf("%d tables are consistent", check.CheckedTables)
+	if check.MismatchTables > 0 {
+		status = constants.ConsistencyCheckInconsistent
+		message = fmt.Sprintf("%d of %d tables are inconsistent", check.MismatchTables, check.CheckedTables)
+		if check.SnapshotMode == string(constants.ConsistencySnapshotCheckpoint) {
+			message += ", rows changed after checkpoint of change feed may be reported as mismatched since sync point is not available"
+			if check.GenerateFixSQL {
+				message += ", and fix SQL is not generated"
+			}
+		}
+	}
+	if err = finishConsistencyCheck(context, check, status, message); err != nil {
+		return setConsistencyMessage(context, err)
+	}
+	node.Record(message)
+	return nil
+}
+
+func consistencyCheckEnd(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	node.Record("consistency check finished")
+	return nil
+}
+
+// consistencyCheckFail
+// @Description: record consistency check failed
+func consistencyCheckFail(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var checkID, message string
+	if err := context.GetData(contextConsistencyCheckID, &checkID); err != nil {
+		return err
+	}
+	if err := context.GetData(contextConsistencyMessage, &message); err != nil {
+		return err
+	}
+	if message == "" {
+		message = "consistency check failed"
+	}
+	check, err := models.GetChangeFeedReaderWriter().GetConsistencyCheck(context, checkID)
+	if err != nil {
+		return err
+	}
+	if err = finishConsistencyCheck(context, check, constants.ConsistencyCheckFailed, message); err != nil {
+		return err
+	}
+	node.Record(fmt.Sprintf("consistency check %s failed, %s", checkID, message))
+	return nil
+}
+
+func finishConsistencyCheck(ctx context.Context, check *changefeed.ConsistencyCheck, status constants.ConsistencyCheckStatus, message string) error {
+	check.Status = string(status)
+	check.Message = message
+	check.EndTime = time.Now()
+	err := models.GetChangeFeedReaderWriter().UpdateConsistencyCheck(ctx, check)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("update consistency check %s to %s failed, %s", check.ID, status, err.Error())
+	}
+	return err
+}
+
+// setConsistencyMessage
+// @Description: keep failure reason in context for the fail node, which could not get error of the failed node
+func setConsistencyMessage(context *workflow.FlowContext, err error) error {
+	if setErr := context.SetData(contextConsistencyMessage, err.Error()); setErr != nil {
+		framework.LogWithContext(context).Errorf("set consistency check message failed, %s", setErr.Error())
+	}
+	return err
+}
+
+func parseConsistencyCheck(check *changefeed.ConsistencyCheck) cluster.ConsistencyCheck {
+	info := cluster.ConsistencyCheck{
+		ID:                  check.ID,
+		ChangeFeedTaskID:    check.TaskId,
+		ClusterID:           check.ClusterId,
+		DownstreamClusterID: check.DownstreamClusterId,
+		Status:              check.Status,
+		Trigger:             check.Trigger,
+		WorkFlowID:          check.WorkFlowId,
+		ConsistencyCheckOptions: cluster.ConsistencyCheckOptions{
+			Databases:      splitNames(check.Databases),
+			Tables:         splitNames(check.Tables),
+			GenerateFixSQL: check.GenerateFixSQL,
+		},
+		SnapshotMode:   check.SnapshotMode,
+		CheckedTables:  check.CheckedTables,
+		MismatchTables: check.MismatchTables,
+		MismatchChunks: check.MismatchChunks,
+		Message:        check.Message,
+		StartTime:      check.StartTime,
+		EndTime:        check.EndTime,
+	}
+	if check.UpstreamTSO > 0 {
+		info.UpstreamTSO = strconv.FormatUint(check.UpstreamTSO, 10)
+	}
+	if check.DownstreamTSO > 0 {
+		info.DownstreamTSO = strconv.FormatUint(check.DownstreamTSO, 10)
+	}
+	return info
+}
+
+func splitNames(names string) []string {
+	if names == "" {
+		return []string{}
+	}
+	return strings.Split(names, ",")
+}
+
+func splitLines(lines string) []string {
+	if lines == "" {
+		return []string{}
+	}
+	return strings.Split(lines, "\n")
+}
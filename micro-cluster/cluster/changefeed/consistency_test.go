@@ -0,0 +1,539 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockchangefeed"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	mock_workflow_service "github.com/pingcap/tiunimanager/test/mockworkflow"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockConsistencyTask(status constants.ChangeFeedStatus) *changefeed.ChangeFeedTask {
+	return &changefeed.ChangeFeedTask{
+		Entity:    common.Entity{ID: "taskId", TenantId: "tenant", Status: status.ToString()},
+		ClusterId: "clusterId",
+		Type:      constants.DownstreamTypeTiDB,
+		Downstream: &changefeed.TiDBDownstream{
+			Ip:              "127.0.0.2",
+			Port:            4000,
+			Username:        "root",
+			Password:        "123",
+			TargetClusterId: "standbyId",
+		},
+	}
+}
+
+func mockConsistencyFlowContext(checkID string) *workflow.FlowContext {
+	flowContext := workflow.NewFlowContext(context.TODO(), map[string]string{})
+	flowContext.SetData(contextConsistencyCheckID, checkID)
+	return flowContext
+}
+
+func TestManager_StartConsistencyCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+	workflow.MockWorkFlowService(workflowService)
+	defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+
+	t.Run("normal", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().CreateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) (*changefeed.ConsistencyCheck, error) {
+			assert.Equal(t, "standbyId", check.DownstreamClusterId)
+			assert.Equal(t, "db1,db2", check.Databases)
+			assert.Equal(t, string(constants.ConsistencyCheckTriggerManual), check.Trigger)
+			assert.Equal(t, string(constants.ConsistencyCheckRunning), check.Status)
+			check.ID = "checkId"
+			return check, nil
+		})
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).Return(nil)
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), "clusterId", workflow.BizTypeCluster, constants.FlowVerifyConsistency).Return("flowId", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), "flowId", contextConsistencyCheckID, "checkId").Return(nil)
+		workflowService.EXPECT().Start(gomock.Any(), "flowId").Return(nil)
+
+		resp, err := GetManager().StartConsistencyCheck(context.TODO(), cluster.StartConsistencyCheckReq{
+			ChangeFeedTaskID:        "taskId",
+			ConsistencyCheckOptions: cluster.ConsistencyCheckOptions{Databases: []string{"db1", "db2"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "checkId", resp.CheckID)
+		assert.Equal(t, "flowId", resp.WorkFlowID)
+	})
+	t.Run("unsupported downstream", func(t *testing.T) {
+		task := mockConsistencyTask(constants.ChangeFeedStatusNormal)
+		task.Type = constants.DownstreamTypeKafka
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(task, nil)
+		_, err := GetManager().StartConsistencyCheck(context.TODO(), cluster.StartConsistencyCheckReq{ChangeFeedTaskID: "taskId"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM, err.(errors.EMError).GetCode())
+	})
+	t.Run("mysql downstream", func(t *testing.T) {
+		task := mockConsistencyTask(constants.ChangeFeedStatusNormal)
+		task.Type = constants.DownstreamTypeMysql
+		task.Downstream = &changefeed.MysqlDownstream{Ip: "127.0.0.2", Port: 3306}
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(task, nil)
+		_, err := GetManager().StartConsistencyCheck(context.TODO(), cluster.StartConsistencyCheckReq{ChangeFeedTaskID: "taskId"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM, err.(errors.EMError).GetCode())
+	})
+	t.Run("invalid table", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		_, err := GetManager().StartConsistencyCheck(context.TODO(), cluster.StartConsistencyCheckReq{
+			ChangeFeedTaskID:        "taskId",
+			ConsistencyCheckOptions: cluster.ConsistencyCheckOptions{Tables: []string{"table1"}},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("status conflict", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusError), nil)
+		_, err := GetManager().StartConsistencyCheck(context.TODO(), cluster.StartConsistencyCheckReq{ChangeFeedTaskID: "taskId"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CHANGE_FEED_STATUS_CONFLICT, err.(errors.EMError).GetCode())
+	})
+	t.Run("start workflow failed", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusStopped), nil)
+		changefeedRW.EXPECT().CreateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) (*changefeed.ConsistencyCheck, error) {
+			check.ID = "checkId"
+			return check, nil
+		})
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).Return(nil)
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+			assert.Equal(t, string(constants.ConsistencyCheckFailed), check.Status)
+			return nil
+		})
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return("flowId", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		workflowService.EXPECT().Start(gomock.Any(), "flowId").Return(errors.Error(errors.TIUNIMANAGER_WORKFLOW_START_FAILED))
+
+		_, err := GetManager().StartConsistencyCheck(context.TODO(), cluster.StartConsistencyCheckReq{ChangeFeedTaskID: "taskId"})
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_QueryConsistencyChecks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	changefeedRW.EXPECT().QueryConsistencyChecks(gomock.Any(), "clusterId", "standbyId", "", []constants.ConsistencyCheckStatus{constants.ConsistencyCheckInconsistent}, 0, 10).
+		Return([]*changefeed.ConsistencyCheck{
+			{
+				Entity:        common.Entity{ID: "checkId", Status: string(constants.ConsistencyCheckInconsistent)},
+				Databases:     "db1",
+				UpstreamTSO:   431434047157698561,
+				DownstreamTSO: 0,
+			},
+		}, int64(11), nil)
+
+	checks, total, err := GetManager().QueryConsistencyChecks(context.TODO(), cluster.QueryConsistencyChecksReq{
+		ClusterID:           "clusterId",
+		DownstreamClusterID: "standbyId",
+		Status:              string(constants.ConsistencyCheckInconsistent),
+		PageRequest:         structs.PageRequest{Page: 1, PageSize: 10},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), total)
+	assert.Equal(t, "431434047157698561", checks[0].UpstreamTSO)
+	assert.Empty(t, checks[0].DownstreamTSO)
+	assert.Equal(t, []string{"db1"}, checks[0].Databases)
+	assert.Empty(t, checks[0].Tables)
+}
+
+func TestManager_DetailConsistencyCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	t.Run("normal", func(t *testing.T) {
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(&changefeed.ConsistencyCheck{
+			Entity: common.Entity{ID: "checkId", Status: string(constants.ConsistencyCheckInconsistent)},
+			Result: `[{"database":"db1","table":"t1","chunks":2,"mismatch":[{"range":"TRUE","upstreamRows":2,"downstreamRows":1}]}]`,
+			FixSQL: "REPLACE INTO `db1`.`t1` (`id`) VALUES ('1');\nDELETE FROM `db1`.`t1` WHERE `id` = '2' LIMIT 1;",
+		}, nil)
+		resp, err := GetManager().DetailConsistencyCheck(context.TODO(), cluster.DetailConsistencyCheckReq{CheckID: "checkId"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(resp.Tables))
+		assert.Equal(t, int64(1), resp.Tables[0].Mismatch[0].DownstreamRows)
+		assert.Equal(t, 2, len(resp.FixSQL))
+	})
+	t.Run("invalid result", func(t *testing.T) {
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(&changefeed.ConsistencyCheck{
+			Entity: common.Entity{ID: "checkId"},
+			Result: "{",
+		}, nil)
+		_, err := GetManager().DetailConsistencyCheck(context.TODO(), cluster.DetailConsistencyCheckReq{CheckID: "checkId"})
+		assert.Error(t, err)
+	})
+	t.Run("not found", func(t *testing.T) {
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(nil, errors.Error(errors.TIUNIMANAGER_CONSISTENCY_CHECK_NOT_FOUND))
+		_, err := GetManager().DetailConsistencyCheck(context.TODO(), cluster.DetailConsistencyCheckReq{CheckID: "checkId"})
+		assert.Error(t, err)
+	})
+}
+
+func Test_resolveConsistencySnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	mockWatchedCluster(ctrl)
+
+	defer func() { querySyncPoint = utilsql.QuerySyncPoint }()
+
+	t.Run("sync point", func(t *testing.T) {
+		querySyncPoint = func(ctx context.Context, param utilsql.DbConnParam, changeFeedID string) (uint64, uint64, error) {
+			assert.Equal(t, "127.0.0.2", param.IP)
+			assert.Equal(t, "taskId", changeFeedID)
+			return 100, 200, nil
+		}
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(&changefeed.ConsistencyCheck{Entity: common.Entity{ID: "checkId"}, TaskId: "taskId"}, nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+			assert.Equal(t, string(constants.ConsistencySnapshotSyncPoint), check.SnapshotMode)
+			assert.Equal(t, uint64(100), check.UpstreamTSO)
+			assert.Equal(t, uint64(200), check.DownstreamTSO)
+			return nil
+		})
+		err := resolveConsistencySnapshot(&workflowModel.WorkFlowNode{}, mockConsistencyFlowContext("checkId"))
+		assert.NoError(t, err)
+	})
+	t.Run("checkpoint", func(t *testing.T) {
+		querySyncPoint = func(ctx context.Context, param utilsql.DbConnParam, changeFeedID string) (uint64, uint64, error) {
+			return 0, 0, nil
+		}
+		mockChangeFeedDetail(ctrl, cdcStateNormal, time.Second, "")
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(&changefeed.ConsistencyCheck{Entity: common.Entity{ID: "checkId"}, TaskId: "taskId"}, nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+			assert.Equal(t, string(constants.ConsistencySnapshotCheckpoint), check.SnapshotMode)
+			assert.NotZero(t, check.UpstreamTSO)
+			assert.Zero(t, check.DownstreamTSO)
+			return nil
+		})
+		err := resolveConsistencySnapshot(&workflowModel.WorkFlowNode{}, mockConsistencyFlowContext("checkId"))
+		assert.NoError(t, err)
+	})
+	t.Run("query sync point failed", func(t *testing.T) {
+		querySyncPoint = func(ctx context.Context, param utilsql.DbConnParam, changeFeedID string) (uint64, uint64, error) {
+			return 0, 0, fmt.Errorf("access denied")
+		}
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(&changefeed.ConsistencyCheck{Entity: common.Entity{ID: "checkId"}, TaskId: "taskId"}, nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		flowContext := mockConsistencyFlowContext("checkId")
+		err := resolveConsistencySnapshot(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+
+		var message string
+		assert.NoError(t, flowContext.GetData(contextConsistencyMessage, &message))
+		assert.Contains(t, message, "access denied")
+	})
+}
+
+func Test_compareConsistencyTables(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "clusterId").Return(&management.Cluster{}, []*management.ClusterInstance{
+		{Type: "TiDB", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{4000}},
+	}, []*management.DBUser{
+		{ClusterID: "clusterId", Name: "root", Password: common.PasswordInExpired{Val: "123455678"}, RoleType: string(constants.Root)},
+	}, nil).AnyTimes()
+
+	defer func() { diffTables = utilsql.DiffTables }()
+
+	check := func() *changefeed.ConsistencyCheck {
+		return &changefeed.ConsistencyCheck{
+			Entity:         common.Entity{ID: "checkId", Status: string(constants.ConsistencyCheckRunning)},
+			TaskId:         "taskId",
+			Tables:         "db1.t1,db1.t2",
+			GenerateFixSQL: true,
+			SnapshotMode:   string(constants.ConsistencySnapshotCheckpoint),
+			UpstreamTSO:    100,
+		}
+	}
+
+	t.Run("inconsistent", func(t *testing.T) {
+		diffTables = func(ctx context.Context, req utilsql.DiffTablesReq) ([]utilsql.TableDiff, error) {
+			assert.Equal(t, "127.0.0.1", req.Upstream.IP)
+			assert.Equal(t, "127.0.0.2", req.Downstream.IP)
+			assert.Equal(t, uint64(100), req.UpstreamSnapshotTSO)
+			assert.Equal(t, []string{"db1.t1", "db1.t2"}, req.TableNames)
+			assert.Equal(t, constants.DefaultFilterRules, req.FilterRules)
+			assert.False(t, req.GenerateFixSQL)
+			return []utilsql.TableDiff{
+				{Database: "db1", Table: "t1", Equal: true, Chunks: 1},
+				{Database: "db1", Table: "t2", Chunks: 3, MismatchChunks: []utilsql.ChunkDiff{{Range: "TRUE", UpstreamRows: 2, DownstreamRows: 1}}},
+			}, nil
+		}
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(check(), nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+			assert.Equal(t, string(constants.ConsistencyCheckInconsistent), check.Status)
+			assert.Equal(t, 2, check.CheckedTables)
+			assert.Equal(t, 1, check.MismatchTables)
+			assert.Equal(t, 1, check.MismatchChunks)
+			assert.Contains(t, check.Result, `"table":"t2"`)
+			assert.Empty(t, check.FixSQL)
+			assert.Contains(t, check.Message, "sync point is not available")
+			assert.Contains(t, check.Message, "fix SQL is not generated")
+			assert.False(t, check.EndTime.IsZero())
+			return nil
+		})
+		err := compareConsistencyTables(&workflowModel.WorkFlowNode{}, mockConsistencyFlowContext("checkId"))
+		assert.NoError(t, err)
+	})
+	t.Run("inconsistent at sync point", func(t *testing.T) {
+		diffTables = func(ctx context.Context, req utilsql.DiffTablesReq) ([]utilsql.TableDiff, error) {
+			assert.Equal(t, uint64(100), req.UpstreamSnapshotTSO)
+			assert.Equal(t, uint64(99), req.DownstreamSnapshotTSO)
+			assert.True(t, req.GenerateFixSQL)
+			return []utilsql.TableDiff{
+				{Database: "db1", Table: "t2", Chunks: 3, MismatchChunks: []utilsql.ChunkDiff{{Range: "TRUE", UpstreamRows: 2, DownstreamRows: 1}},
+					FixSQL: []string{"REPLACE INTO `db1`.`t2` (`id`) VALUES ('1');"}},
+			}, nil
+		}
+		syncPointCheck := check()
+		syncPointCheck.SnapshotMode = string(constants.ConsistencySnapshotSyncPoint)
+		syncPointCheck.DownstreamTSO = 99
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(syncPointCheck, nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+			assert.Equal(t, string(constants.ConsistencyCheckInconsistent), check.Status)
+			assert.Equal(t, "REPLACE INTO `db1`.`t2` (`id`) VALUES ('1');", check.FixSQL)
+			assert.NotContains(t, check.Message, "sync point")
+			return nil
+		})
+		err := compareConsistencyTables(&workflowModel.WorkFlowNode{}, mockConsistencyFlowContext("checkId"))
+		assert.NoError(t, err)
+	})
+	t.Run("consistent", func(t *testing.T) {
+		diffTables = func(ctx context.Context, req utilsql.DiffTablesReq) ([]utilsql.TableDiff, error) {
+			return []utilsql.TableDiff{{Database: "db1", Table: "t1", Equal: true, Chunks: 1}}, nil
+		}
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(check(), nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+			assert.Equal(t, string(constants.ConsistencyCheckConsistent), check.Status)
+			assert.Equal(t, "[]", check.Result)
+			assert.Empty(t, check.FixSQL)
+			return nil
+		})
+		err := compareConsistencyTables(&workflowModel.WorkFlowNode{}, mockConsistencyFlowContext("checkId"))
+		assert.NoError(t, err)
+	})
+	t.Run("compare failed", func(t *testing.T) {
+		diffTables = func(ctx context.Context, req utilsql.DiffTablesReq) ([]utilsql.TableDiff, error) {
+			return nil, fmt.Errorf("connection refused")
+		}
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(check(), nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		err := compareConsistencyTables(&workflowModel.WorkFlowNode{}, mockConsistencyFlowContext("checkId"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_consistencyCheckFail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "checkId").Return(&changefeed.ConsistencyCheck{Entity: common.Entity{ID: "checkId"}}, nil)
+	changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) error {
+		assert.Equal(t, string(constants.ConsistencyCheckFailed), check.Status)
+		assert.Equal(t, "connection refused", check.Message)
+		return nil
+	})
+	flowContext := mockConsistencyFlowContext("checkId")
+	setConsistencyMessage(flowContext, fmt.Errorf("connection refused"))
+	err := consistencyCheckFail(&workflowModel.WorkFlowNode{}, flowContext)
+	assert.NoError(t, err)
+}
+
+func Test_evaluateConsistencySchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+	workflow.MockWorkFlowService(workflowService)
+	defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+
+	now := time.Now()
+	t.Run("not due", func(t *testing.T) {
+		evaluateConsistencySchedule(context.TODO(), &changefeed.ConsistencySchedule{
+			Entity:            common.Entity{ID: "scheduleId"},
+			TaskId:            "taskId",
+			CronSpec:          "@daily",
+			LastScheduledTime: now,
+		}, now)
+	})
+	t.Run("first evaluation", func(t *testing.T) {
+		changefeedRW.EXPECT().UpdateConsistencyScheduleRun(gomock.Any(), "scheduleId", now, "").Return(nil)
+		evaluateConsistencySchedule(context.TODO(), &changefeed.ConsistencySchedule{
+			Entity:   common.Entity{ID: "scheduleId"},
+			TaskId:   "taskId",
+			CronSpec: "@hourly",
+		}, now)
+	})
+	t.Run("due", func(t *testing.T) {
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "lastCheckId").Return(&changefeed.ConsistencyCheck{
+			Entity: common.Entity{ID: "lastCheckId", Status: string(constants.ConsistencyCheckConsistent)},
+		}, nil)
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().CreateConsistencyCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, check *changefeed.ConsistencyCheck) (*changefeed.ConsistencyCheck, error) {
+			assert.Equal(t, string(constants.ConsistencyCheckTriggerSchedule), check.Trigger)
+			assert.Equal(t, "db1.t1", check.Tables)
+			check.ID = "newCheckId"
+			return check, nil
+		})
+		changefeedRW.EXPECT().UpdateConsistencyCheck(gomock.Any(), gomock.Any()).Return(nil)
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return("flowId", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		workflowService.EXPECT().Start(gomock.Any(), gomock.Any()).Return(nil)
+		changefeedRW.EXPECT().UpdateConsistencyScheduleRun(gomock.Any(), "scheduleId", now, "newCheckId").Return(nil)
+
+		evaluateConsistencySchedule(context.TODO(), &changefeed.ConsistencySchedule{
+			Entity:            common.Entity{ID: "scheduleId"},
+			TaskId:            "taskId",
+			CronSpec:          "@hourly",
+			Tables:            "db1.t1",
+			LastScheduledTime: now.Add(-2 * time.Hour),
+			LastCheckId:       "lastCheckId",
+		}, now)
+	})
+	t.Run("last check running", func(t *testing.T) {
+		changefeedRW.EXPECT().GetConsistencyCheck(gomock.Any(), "lastCheckId").Return(&changefeed.ConsistencyCheck{
+			Entity: common.Entity{ID: "lastCheckId", Status: string(constants.ConsistencyCheckRunning)},
+		}, nil)
+		changefeedRW.EXPECT().UpdateConsistencyScheduleRun(gomock.Any(), "scheduleId", now, "").Return(nil)
+
+		evaluateConsistencySchedule(context.TODO(), &changefeed.ConsistencySchedule{
+			Entity:            common.Entity{ID: "scheduleId"},
+			TaskId:            "taskId",
+			CronSpec:          "@hourly",
+			LastScheduledTime: now.Add(-2 * time.Hour),
+			LastCheckId:       "lastCheckId",
+		}, now)
+	})
+}
+
+func TestManager_SaveConsistencySchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	t.Run("normal", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		changefeedRW.EXPECT().SaveConsistencySchedule(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, schedule *changefeed.ConsistencySchedule) (*changefeed.ConsistencySchedule, error) {
+			assert.Equal(t, "0 2 * * *", schedule.CronSpec)
+			assert.Equal(t, "tenant", schedule.TenantId)
+			assert.False(t, schedule.LastScheduledTime.IsZero())
+			schedule.ID = "scheduleId"
+			return schedule, nil
+		})
+		resp, err := GetManager().SaveConsistencySchedule(context.TODO(), cluster.SaveConsistencyScheduleReq{
+			ChangeFeedTaskID: "taskId",
+			ConsistencySchedule: cluster.ConsistencySchedule{
+				CronSpec: " 0 2 * * * ",
+				TimeZone: "Asia/Shanghai",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "scheduleId", resp.Schedule.ID)
+		assert.False(t, resp.Schedule.NextRunTime.IsZero())
+	})
+	t.Run("invalid cron spec", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		_, err := GetManager().SaveConsistencySchedule(context.TODO(), cluster.SaveConsistencyScheduleReq{
+			ChangeFeedTaskID:    "taskId",
+			ConsistencySchedule: cluster.ConsistencySchedule{CronSpec: "every day"},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CONSISTENCY_SCHEDULE_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("invalid time zone", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), "taskId").Return(mockConsistencyTask(constants.ChangeFeedStatusNormal), nil)
+		_, err := GetManager().SaveConsistencySchedule(context.TODO(), cluster.SaveConsistencyScheduleReq{
+			ChangeFeedTaskID:    "taskId",
+			ConsistencySchedule: cluster.ConsistencySchedule{CronSpec: "@daily", TimeZone: "Mars/Olympus"},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CONSISTENCY_SCHEDULE_INVALID, err.(errors.EMError).GetCode())
+	})
+}
+
+func TestManager_GetConsistencySchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+
+	changefeedRW.EXPECT().GetConsistencySchedule(gomock.Any(), "taskId").Return(&changefeed.ConsistencySchedule{
+		Entity:    common.Entity{ID: "scheduleId"},
+		TaskId:    "taskId",
+		CronSpec:  "@daily",
+		Paused:    true,
+		Databases: "db1,db2",
+	}, nil)
+	resp, err := GetManager().GetConsistencySchedule(context.TODO(), cluster.GetConsistencyScheduleReq{ChangeFeedTaskID: "taskId"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2"}, resp.Schedule.Databases)
+	assert.True(t, resp.Schedule.NextRunTime.IsZero())
+
+	changefeedRW.EXPECT().DeleteConsistencySchedule(gomock.Any(), "taskId").Return(nil)
+	_, err = GetManager().DeleteConsistencySchedule(context.TODO(), cluster.DeleteConsistencyScheduleReq{ChangeFeedTaskID: "taskId"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,271 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"github.com/robfig/cron"
+)
+
+type consistencyScheduler struct {
+	JobCron *cron.Cron
+	JobSpec string
+}
+
+type consistencyScheduleHandler struct {
+	running int32
+}
+
+func startConsistencyScheduler() {
+	scheduler := &consistencyScheduler{
+		JobCron: cron.New(),
+		JobSpec: "45 * * * * *", // every minute
+	}
+	err := scheduler.JobCron.AddJob(scheduler.JobSpec, &consistencyScheduleHandler{})
+	if err != nil {
+		framework.Log().Fatalf("add consistency check schedule cron job failed, %s", err.Error())
+		return
+	}
+	go scheduler.start()
+}
+
+func (s *consistencyScheduler) start() {
+	time.Sleep(5 * time.Second) //wait db client ready
+	s.JobCron.Start()
+	defer s.JobCron.Stop()
+
+	select {}
+}
+
+// SaveConsistencySchedule
+// @Description: create or replace the consistency check schedule of change feed task
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) SaveConsistencySchedule(ctx context.Context, request cluster.SaveConsistencyScheduleReq) (resp cluster.SaveConsistencyScheduleResp, err error) {
+	rw := models.GetChangeFeedReaderWriter()
+	task, err := rw.Get(ctx, request.ChangeFeedTaskID)
+	if err != nil {
+		return
+	}
+	request.CronSpec = strings.TrimSpace(request.CronSpec)
+	if _, _, err = parseConsistencySchedule(request.CronSpec, request.TimeZone); err != nil {
+		err = errors.WrapError(errors.TIUNIMANAGER_CONSISTENCY_SCHEDULE_INVALID, err.Error(), err)
+		return
+	}
+	if err = consistencyCheckPreCheck(task, request.ConsistencyCheckOptions); err != nil {
+		return
+	}
+
+	schedule, err := rw.SaveConsistencySchedule(ctx, &changefeed.ConsistencySchedule{
+		Entity:         dbCommon.Entity{TenantId: task.TenantId},
+		TaskId:         task.ID,
+		ClusterId:      task.ClusterId,
+		CronSpec:       request.CronSpec,
+		TimeZone:       request.TimeZone,
+		Paused:         request.Paused,
+		Databases:      strings.Join(request.Databases, ","),
+		Tables:         strings.Join(request.Tables, ","),
+		GenerateFixSQL: request.GenerateFixSQL,
+		// runs are counted from now on, so that a new, changed or resumed schedule does not run immediately
+		LastScheduledTime: time.Now(),
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("save consistency check schedule of change feed task %s failed, %s", task.ID, err.Error())
+		return
+	}
+	resp.Schedule = parseConsistencySchedule2Info(schedule, time.Now())
+	return
+}
+
+// GetConsistencySchedule
+// @Description: get the consistency check schedule of change feed task
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) GetConsistencySchedule(ctx context.Context, request cluster.GetConsistencyScheduleReq) (resp cluster.GetConsistencyScheduleResp, err error) {
+	schedule, err := models.GetChangeFeedReaderWriter().GetConsistencySchedule(ctx, request.ChangeFeedTaskID)
+	if err != nil {
+		return
+	}
+	resp.Schedule = parseConsistencySchedule2Info(schedule, time.Now())
+	return
+}
+
+// DeleteConsistencySchedule
+// @Description: delete the consistency check schedule of change feed task
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) DeleteConsistencySchedule(ctx context.Context, request cluster.DeleteConsistencyScheduleReq) (resp cluster.DeleteConsistencyScheduleResp, err error) {
+	err = models.GetChangeFeedReaderWriter().DeleteConsistencySchedule(ctx, request.ChangeFeedTaskID)
+	return
+}
+
+func (handler *consistencyScheduleHandler) Run() {
+	if !atomic.CompareAndSwapInt32(&handler.running, 0, 1) {
+		framework.Log().Warnf("last round of consistency check schedules is still running, skip this round")
+		return
+	}
+	defer atomic.StoreInt32(&handler.running, 0)
+
+	schedules, err := models.GetChangeFeedReaderWriter().QueryConsistencySchedules(context.TODO())
+	if err != nil {
+		framework.Log().Errorf("query consistency check schedules failed, %s", err.Error())
+		return
+	}
+	now := time.Now()
+	for _, schedule := range schedules {
+		if schedule.Paused {
+			continue
+		}
+		ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: schedule.TenantId})
+		evaluateConsistencySchedule(ctx, schedule, now)
+	}
+}
+
+// evaluateConsistencySchedule
+// @Description: start a consistency check if a run of schedule is due, runs missed during downtime are caught up by one check,
+// and a run is skipped if the check started by last run is still running
+// @Parameter ctx
+// @Parameter schedule
+// @Parameter now
+func evaluateConsistencySchedule(ctx context.Context, schedule *changefeed.ConsistencySchedule, now time.Time) {
+	cronSchedule, location, err := parseConsistencySchedule(schedule.CronSpec, schedule.TimeZone)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("invalid consistency check schedule %s, %s", schedule.ID, err.Error())
+		return
+	}
+	rw := models.GetChangeFeedReaderWriter()
+	if schedule.LastScheduledTime.IsZero() {
+		// runs are counted from now on
+		if err = rw.UpdateConsistencyScheduleRun(ctx, schedule.ID, now, ""); err != nil {
+			framework.LogWithContext(ctx).Errorf("update consistency check schedule %s failed, %s", schedule.ID, err.Error())
+		}
+		return
+	}
+	if next := cronSchedule.Next(schedule.LastScheduledTime.In(location)); next.IsZero() || next.After(now) {
+		return
+	}
+
+	running, err := isConsistencyCheckRunning(ctx, schedule.LastCheckId)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get last consistency check %s of schedule %s failed, %s", schedule.LastCheckId, schedule.ID, err.Error())
+		return
+	}
+	checkID := ""
+	if running {
+		framework.LogWithContext(ctx).Warnf("last consistency check %s of schedule %s is still running, skip this run", schedule.LastCheckId, schedule.ID)
+	} else {
+		check, err := startConsistencyCheck(ctx, schedule.TaskId, cluster.ConsistencyCheckOptions{
+			Databases:      splitNames(schedule.Databases),
+			Tables:         splitNames(schedule.Tables),
+			GenerateFixSQL: schedule.GenerateFixSQL,
+		}, constants.ConsistencyCheckTriggerSchedule)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("start consistency check of schedule %s failed, %s", schedule.ID, err.Error())
+		} else {
+			checkID = check.ID
+		}
+	}
+
+	if err = rw.UpdateConsistencyScheduleRun(ctx, schedule.ID, now, checkID); err != nil {
+		framework.LogWithContext(ctx).Errorf("update consistency check schedule %s failed, %s", schedule.ID, err.Error())
+	}
+}
+
+func isConsistencyCheckRunning(ctx context.Context, checkID string) (bool, error) {
+	if checkID == "" {
+		return false, nil
+	}
+	check, err := models.GetChangeFeedReaderWriter().GetConsistencyCheck(ctx, checkID)
+	if err != nil {
+		if emError, ok := err.(errors.EMError); ok && emError.GetCode() == errors.TIUNIMANAGER_CONSISTENCY_CHECK_NOT_FOUND {
+			return false, nil
+		}
+		return false, err
+	}
+	return check.Status == string(constants.ConsistencyCheckRunning), nil
+}
+
+// parseConsistencySchedule
+// @Description: parse standard 5-field cron expression or descriptor, and its time zone
+// @Parameter spec
+// @Parameter timeZone IANA time zone name, local time zone if empty
+// @return cron.Schedule
+// @return *time.Location
+// @return error
+func parseConsistencySchedule(spec string, timeZone string) (cron.Schedule, *time.Location, error) {
+	if spec == "" {
+		return nil, nil, fmt.Errorf("cron spec is empty")
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron spec %s, %s", spec, err.Error())
+	}
+	location := time.Local
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, nil, fmt.Errorf("invalid time zone %s, %s", timeZone, err.Error())
+		}
+	}
+	return schedule, location, nil
+}
+
+func parseConsistencySchedule2Info(schedule *changefeed.ConsistencySchedule, now time.Time) cluster.ConsistencyScheduleInfo {
+	info := cluster.ConsistencyScheduleInfo{
+		ID:               schedule.ID,
+		ChangeFeedTaskID: schedule.TaskId,
+		ClusterID:        schedule.ClusterId,
+		ConsistencySchedule: cluster.ConsistencySchedule{
+			CronSpec: schedule.CronSpec,
+			TimeZone: schedule.TimeZone,
+			Paused:   schedule.Paused,
+			ConsistencyCheckOptions: cluster.ConsistencyCheckOptions{
+				Databases:      splitNames(schedule.Databases),
+				Tables:         splitNames(schedule.Tables),
+				GenerateFixSQL: schedule.GenerateFixSQL,
+			},
+		},
+		LastScheduledTime: schedule.LastScheduledTime,
+		LastCheckID:       schedule.LastCheckId,
+		CreateTime:        schedule.CreatedAt,
+		UpdateTime:        schedule.UpdatedAt,
+	}
+	if cronSchedule, location, err := parseConsistencySchedule(schedule.CronSpec, schedule.TimeZone); err == nil && !schedule.Paused {
+		info.NextRunTime = cronSchedule.Next(now.In(location))
+	}
+	return info
+}
@@ -22,12 +22,14 @@ import (
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
 	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/library/util/tso"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
 	dbCommon "github.com/pingcap/tiunimanager/models/common"
 	"github.com/pingcap/tiunimanager/util/api/cdc"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
 	"strconv"
 	"sync"
 	"time"
@@ -38,6 +40,9 @@ var once sync.Once
 var service Service
 var serviceOnce sync.Once
 
+// replaced in unit tests, it connects to upstream cluster
+var queryGCSafePoint = utilsql.QueryGCSafePoint
+
 type Manager struct{}
 
 func GetManager() *Manager {
@@ -57,6 +62,12 @@ func GetManager() *Manager {
 // @return string ID of ChangeFeedTask
 // @return error
 func (p *Manager) Create(ctx context.Context, request cluster.CreateChangeFeedTaskReq) (resp cluster.CreateChangeFeedTaskResp, err error) {
+	return p.create(ctx, request, nil)
+}
+
+// create
+// @Description: create change feed task, empty or masked secrets of downstream are copied from previous downstream if it is not nil
+func (p *Manager) create(ctx context.Context, request cluster.CreateChangeFeedTaskReq, previous changefeed.ChangeFeedDownStream) (resp cluster.CreateChangeFeedTaskResp, err error) {
 	clusterMeta, err := meta.Get(ctx, request.ClusterID)
 	if err != nil {
 		return
@@ -72,16 +83,24 @@ func (p *Manager) Create(ctx context.Context, request cluster.CreateChangeFeedTa
 			TenantId: framework.GetTenantIDFromContext(ctx),
 			Status:   string(constants.ChangeFeedStatusInitial),
 		},
-		StartTS:     0,
-		Name:        request.Name,
-		ClusterId:   request.ClusterID,
-		FilterRules: request.FilterRules,
+		StartTS:              0,
+		Name:                 request.Name,
+		ClusterId:            request.ClusterID,
+		FilterRules:          request.FilterRules,
+		AutoResume:           request.AutoResume.Enabled,
+		AutoResumeMaxRetries: request.AutoResume.MaxRetries,
 	}
 
-	if len(request.StartTS) > 0 {
-		tso, parseError := strconv.ParseInt(request.StartTS, 10, 64)
+	if request.StartTime != nil {
+		if len(request.StartTS) > 0 {
+			err = errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "start ts and start time cannot be specified together")
+			return
+		}
+		task.StartTS = int64(tso.GenerateTSO(*request.StartTime, 0))
+	} else if len(request.StartTS) > 0 {
+		startTS, parseError := strconv.ParseInt(request.StartTS, 10, 64)
 		if parseError == nil {
-			task.StartTS = tso
+			task.StartTS = startTS
 		} else {
 			err = errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, parseError.Error())
 			return
@@ -91,6 +110,15 @@ func (p *Manager) Create(ctx context.Context, request cluster.CreateChangeFeedTa
 	if err = copyDownstreamConfig(task, request.DownstreamType, request.Downstream); err != nil {
 		return
 	}
+	if downstream, ok := task.Downstream.(changefeed.SensitiveDownstream); ok && previous != nil {
+		downstream.KeepSecrets(previous)
+	}
+	if err = validateDownstream(task); err != nil {
+		return
+	}
+	if err = checkStartTS(ctx, clusterMeta, task.StartTS); err != nil {
+		return
+	}
 
 	task, err = models.GetChangeFeedReaderWriter().Create(ctx, task)
 	if err != nil {
@@ -220,12 +248,22 @@ func (p *Manager) Update(ctx context.Context, request cluster.UpdateChangeFeedTa
 
 	running := constants.ChangeFeedStatusNormal.ToString() == task.Status
 
+	previousDownstream := task.Downstream
 	task.Name = request.Name
 	task.FilterRules = request.FilterRules
+	task.AutoResume = request.AutoResume.Enabled
+	task.AutoResumeMaxRetries = request.AutoResume.MaxRetries
 	err = copyDownstreamConfig(task, request.DownstreamType, request.Downstream)
 	if err != nil {
 		return
 	}
+	// masked secrets in responses are submitted again
+	if downstream, ok := task.Downstream.(changefeed.SensitiveDownstream); ok {
+		downstream.KeepSecrets(previousDownstream)
+	}
+	if err = validateDownstream(task); err != nil {
+		return
+	}
 
 	clusterMeta, err := meta.Get(ctx, task.ClusterId)
 	if err != nil {
@@ -236,6 +274,17 @@ func (p *Manager) Update(ctx context.Context, request cluster.UpdateChangeFeedTa
 		err = errors.NewErrorf(errors.TIUNIMANAGER_INVALID_TOPOLOGY, "CDC components required, cluster %s", clusterMeta.Cluster.ID)
 		return
 	}
+	if request.StartTime != nil {
+		startTS := int64(tso.GenerateTSO(*request.StartTime, 0))
+		if err = checkStartTS(ctx, clusterMeta, startTS); err != nil {
+			return
+		}
+		err = p.recreate(ctx, clusterMeta, task, startTS)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("update change feed task %s failed, step = recreate, err = %s", request.ID, err.Error())
+		}
+		return
+	}
 	// pause -> update -> resume
 	if running {
 		err = models.GetChangeFeedReaderWriter().LockStatus(ctx, request.ID)
@@ -271,6 +320,76 @@ func (p *Manager) Update(ctx context.Context, request cluster.UpdateChangeFeedTa
 	return
 }
 
+// recreate
+// @Description: start ts of a cdc change feed cannot be changed, so the task is deleted in cdc
+// and created again with the same id, it is running after recreated no matter what status it was.
+// Config and start ts are saved only after the task is created in cdc
+// @Receiver p
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter task updated config of task
+// @Parameter startTS
+// @return error
+func (p *Manager) recreate(ctx context.Context, clusterMeta *meta.ClusterMeta, task *changefeed.ChangeFeedTask, startTS int64) error {
+	previousStatus := constants.ChangeFeedStatus(task.Status)
+	if err := models.GetChangeFeedReaderWriter().LockStatus(ctx, task.ID); err != nil {
+		return err
+	}
+	result, err := cdc.CDCService.DeleteChangeFeedTask(ctx, cdc.ChangeFeedDeleteReq{
+		CDCAddress:   clusterMeta.GetCDCClientAddresses()[0].ToString(),
+		ChangeFeedID: task.ID,
+	})
+	if err != nil || !result.Accepted || !result.Succeed {
+		errMsg := fmt.Sprintf("failed to delete change feed task before recreating, err = %v, result = %v", err, result)
+		framework.LogWithContext(ctx).Errorf(errMsg)
+		models.GetChangeFeedReaderWriter().UnlockStatus(ctx, task.ID, previousStatus)
+		return errors.NewError(errors.TIUNIMANAGER_CHANGE_FEED_EXECUTE_ERROR, errMsg)
+	}
+
+	previousStartTS := task.StartTS
+	task.StartTS = startTS
+	if err = p.createExecutor(ctx, clusterMeta, task); err != nil {
+		// the task has been deleted in cdc, it is kept with previous config and start ts
+		task.StartTS = previousStartTS
+		models.GetChangeFeedReaderWriter().UnlockStatus(ctx, task.ID, constants.ChangeFeedStatusError)
+		return err
+	}
+
+	if err = models.GetChangeFeedReaderWriter().UpdateConfig(ctx, task); err != nil {
+		return err
+	}
+	return models.GetChangeFeedReaderWriter().UpdateStartTS(ctx, task.ID, startTS)
+}
+
+// checkStartTS
+// @Description: change feed task cannot start before gc safe point of upstream cluster, or after current time
+// @Parameter ctx
+// @Parameter clusterMeta upstream cluster
+// @Parameter startTS 0 means starting from current tso
+// @return error
+func checkStartTS(ctx context.Context, clusterMeta *meta.ClusterMeta, startTS int64) error {
+	if startTS <= 0 {
+		return nil
+	}
+	startTime, _ := tso.ParseTS(uint64(startTS))
+	if startTime.After(time.Now()) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "start time %s is in the future", startTime.Format(time.RFC3339))
+	}
+	param, err := getUpstreamConnParam(ctx, clusterMeta)
+	if err != nil {
+		return err
+	}
+	safePoint, err := queryGCSafePoint(ctx, param)
+	if err != nil {
+		return err
+	}
+	if startTime.Before(safePoint) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_CHANGE_FEED_START_BEFORE_GC, "start time %s is before gc safe point %s of cluster %s",
+			startTime.Format(time.RFC3339), safePoint.Format(time.RFC3339), clusterMeta.Cluster.ID)
+	}
+	return nil
+}
+
 func currentTSO() uint64 {
 	return uint64((time.Now().UnixNano() / int64(time.Millisecond)) << 18)
 }
@@ -330,6 +449,9 @@ func (p *Manager) createExecutor(ctx context.Context, clusterMeta *meta.ClusterM
 		SinkURI:      task.Downstream.GetSinkURI(),
 		StartTS:      uint64(task.StartTS),
 		FilterRules:  task.FilterRules,
+		SinkConfig:   buildSinkConfig(task),
+		// sync points are required by consistency check of TiDB downstream
+		SyncPointEnabled: task.Type == constants.DownstreamTypeTiDB,
 	})
 	if libError != nil || !libResp.Accepted {
 		errMsg := fmt.Sprintf("createExecutor change feed task failed, err = %v, resp = %v", libError, libResp)
@@ -369,6 +491,7 @@ func (p *Manager) updateExecutor(ctx context.Context, clusterMeta *meta.ClusterM
 		SinkURI:      task.Downstream.GetSinkURI(),
 		TargetTS:     task.TargetTS,
 		FilterRules:  task.FilterRules,
+		SinkConfig:   buildSinkConfig(task),
 	})
 
 	if libError != nil || !libResp.Accepted || !libResp.Succeed {
@@ -404,6 +527,52 @@ func copyDownstreamConfig(target *changefeed.ChangeFeedTask, changeFeedType stri
 	return err
 }
 
+func validateDownstream(task *changefeed.ChangeFeedTask) error {
+	if downstream, ok := task.Downstream.(changefeed.ValidatableDownstream); ok {
+		return downstream.Validate()
+	}
+	return nil
+}
+
+// buildSinkConfig
+// @Description: dispatchers, column selectors and schema registry of kafka downstream, nil for other downstreams
+// @Parameter task
+// @return *cdc.SinkConfig
+func buildSinkConfig(task *changefeed.ChangeFeedTask) *cdc.SinkConfig {
+	kafka, ok := task.Downstream.(*changefeed.KafkaDownstream)
+	if !ok {
+		return nil
+	}
+	config := &cdc.SinkConfig{
+		Protocol:       kafka.Protocol,
+		SchemaRegistry: kafka.SchemaRegistry,
+	}
+	if len(config.Protocol) == 0 {
+		config.Protocol = "default"
+	}
+	for _, d := range kafka.Dispatchers {
+		config.DispatchRules = append(config.DispatchRules, cdc.DispatchRule{
+			Matcher:       []string{d.Matcher},
+			PartitionRule: d.Dispatcher,
+			TopicRule:     d.Topic,
+		})
+	}
+	if len(kafka.TopicExpression) > 0 {
+		// rules are matched in order, so the topic expression only applies to tables not matched by dispatchers
+		config.DispatchRules = append(config.DispatchRules, cdc.DispatchRule{
+			Matcher:   []string{"*.*"},
+			TopicRule: kafka.TopicExpression,
+		})
+	}
+	for _, c := range kafka.ColumnSelectors {
+		config.ColumnSelectors = append(config.ColumnSelectors, cdc.ColumnSelector{
+			Matcher: []string{c.Matcher},
+			Columns: c.Columns,
+		})
+	}
+	return config
+}
+
 func parse(task changefeed.ChangeFeedTask) cluster.ChangeFeedTaskInfo {
 	info := cluster.ChangeFeedTaskInfo{
 		ChangeFeedTask: cluster.ChangeFeedTask{
@@ -415,10 +584,22 @@ func parse(task changefeed.ChangeFeedTask) cluster.ChangeFeedTaskInfo {
 			Status:         task.Status,
 			DownstreamType: string(task.Type),
 			Downstream:     task.Downstream,
-			CreateTime:     task.CreatedAt,
-			UpdateTime:     task.UpdatedAt,
+			AutoResume: cluster.ChangeFeedAutoResumePolicy{
+				Enabled:    task.AutoResume,
+				MaxRetries: task.AutoResumeMaxRetries,
+			},
+			CreateTime: task.CreatedAt,
+			UpdateTime: task.UpdatedAt,
 		},
-		UnSteady: task.Locked(),
+		UnSteady:      task.Locked(),
+		CheckpointLag: task.CheckpointLag,
+		LagLevel:      task.LagLevel,
+		CheckedTime:   task.CheckedTime,
+		LastError:     task.LastError,
+		ResumeRetries: task.ResumeRetries,
+	}
+	if downstream, ok := task.Downstream.(changefeed.SensitiveDownstream); ok {
+		info.Downstream = downstream.Masked()
 	}
 	info.ConvertStartTS()
 	return info
@@ -20,6 +20,8 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/util/tso"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
 	"github.com/pingcap/tiunimanager/models"
@@ -30,8 +32,10 @@ import (
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
 	"github.com/pingcap/tiunimanager/test/mockutilcdc"
 	"github.com/pingcap/tiunimanager/util/api/cdc"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -49,6 +53,7 @@ func TestManager_Create(t *testing.T) {
 	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
 	models.SetClusterReaderWriter(clusterRW)
 	clusterRW.EXPECT().GetMeta(gomock.Any(), "clusterId").Return(&management.Cluster{}, []*management.ClusterInstance{
+		{Type: "TiDB", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{4000}},
 		{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{111}},
 		{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.2"}, Ports: []int32{111}},
 	}, []*management.DBUser{
@@ -72,11 +77,17 @@ func TestManager_Create(t *testing.T) {
 		Succeed:  true,
 	}, nil).AnyTimes()
 
+	safePoint := time.Now().Add(-time.Hour)
+	queryGCSafePoint = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (time.Time, error) {
+		return safePoint, nil
+	}
+	defer func() { queryGCSafePoint = utilsql.QueryGCSafePoint }()
+
 	t.Run("normal", func(t *testing.T) {
 		resp, err := GetManager().Create(context.TODO(), cluster.CreateChangeFeedTaskReq{
 			Name:           "aa",
 			ClusterID:      "clusterId",
-			StartTS:        "121212",
+			StartTS:        strconv.FormatUint(tso.GenerateTSO(time.Now().Add(-time.Minute), 0), 10),
 			FilterRules:    []string{"*.*"},
 			DownstreamType: "tidb",
 			Downstream: changefeed.TiDBDownstream{
@@ -87,6 +98,76 @@ func TestManager_Create(t *testing.T) {
 		assert.Equal(t, "11111", resp.ID)
 		time.Sleep(time.Millisecond * 10)
 	})
+	t.Run("start time", func(t *testing.T) {
+		startTime := time.Now().Add(-time.Minute)
+		resp, err := GetManager().Create(context.TODO(), cluster.CreateChangeFeedTaskReq{
+			Name:           "aa",
+			ClusterID:      "clusterId",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "11111", resp.ID)
+	})
+	t.Run("before gc safe point", func(t *testing.T) {
+		startTime := safePoint.Add(-time.Minute)
+		_, err := GetManager().Create(context.TODO(), cluster.CreateChangeFeedTaskReq{
+			Name:           "aa",
+			ClusterID:      "clusterId",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CHANGE_FEED_START_BEFORE_GC, err.(errors.EMError).GetCode())
+	})
+	t.Run("future start time", func(t *testing.T) {
+		startTime := time.Now().Add(time.Hour)
+		_, err := GetManager().Create(context.TODO(), cluster.CreateChangeFeedTaskReq{
+			Name:           "aa",
+			ClusterID:      "clusterId",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("both start ts and start time", func(t *testing.T) {
+		startTime := time.Now()
+		_, err := GetManager().Create(context.TODO(), cluster.CreateChangeFeedTaskReq{
+			Name:           "aa",
+			ClusterID:      "clusterId",
+			StartTS:        "415241823337054209",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("invalid downstream", func(t *testing.T) {
+		_, err := GetManager().Create(context.TODO(), cluster.CreateChangeFeedTaskReq{
+			Name:           "aa",
+			ClusterID:      "clusterId",
+			DownstreamType: "storage",
+			Downstream: changefeed.StorageDownstream{
+				StorageType: "local",
+				Path:        "relative/path",
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+	})
 }
 
 func TestManager_Delete(t *testing.T) {
@@ -290,6 +371,7 @@ func TestManager_Update(t *testing.T) {
 	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
 	models.SetClusterReaderWriter(clusterRW)
 	clusterRW.EXPECT().GetMeta(gomock.Any(), "clusterId").Return(&management.Cluster{}, []*management.ClusterInstance{
+		{Type: "TiDB", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{4000}},
 		{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{111}},
 		{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.2"}, Ports: []int32{111}},
 	}, []*management.DBUser{
@@ -360,7 +442,232 @@ func TestManager_Update(t *testing.T) {
 		})
 		assert.Error(t, err)
 	})
+	t.Run("keep secrets", func(t *testing.T) {
+		task := &changefeed.ChangeFeedTask{
+			Entity: common.Entity{
+				Status: string(constants.ChangeFeedStatusStopped),
+				ID:     "taskId",
+			},
+			ClusterId: "clusterId",
+			Type:      constants.DownstreamTypeStorage,
+			Downstream: &changefeed.StorageDownstream{
+				StorageType:     "s3",
+				Path:            "bucket",
+				AccessKey:       "ak",
+				SecretAccessKey: "sk",
+			},
+		}
+		changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).Return(task, nil).Times(1)
+
+		_, err := GetManager().Update(context.TODO(), cluster.UpdateChangeFeedTaskReq{
+			Name:           "aa",
+			DownstreamType: "storage",
+			Downstream: changefeed.StorageDownstream{
+				StorageType:     "s3",
+				Path:            "bucket/new",
+				AccessKey:       structs.SensitiveTextMask,
+				SecretAccessKey: structs.SensitiveTextMask,
+			},
+		})
+		assert.NoError(t, err)
+		updated := task.Downstream.(*changefeed.StorageDownstream)
+		assert.Equal(t, "bucket/new", updated.Path)
+		assert.Equal(t, structs.SensitiveText("ak"), updated.AccessKey)
+		assert.Equal(t, structs.SensitiveText("sk"), updated.SecretAccessKey)
+	})
+	t.Run("start time", func(t *testing.T) {
+		queryGCSafePoint = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (time.Time, error) {
+			return time.Now().Add(-time.Hour), nil
+		}
+		defer func() { queryGCSafePoint = utilsql.QueryGCSafePoint }()
+
+		task := &changefeed.ChangeFeedTask{
+			Entity: common.Entity{
+				Status: string(constants.ChangeFeedStatusStopped),
+				ID:     "taskId",
+			},
+			ClusterId:  "clusterId",
+			StartTS:    1,
+			Downstream: &changefeed.TiDBDownstream{},
+		}
+		startTime := time.Now().Add(-time.Minute)
+		changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).Return(task, nil).Times(1)
+		changefeedRW.EXPECT().UpdateStartTS(gomock.Any(), "taskId", int64(tso.GenerateTSO(startTime, 0))).Return(nil).Times(1)
+		mockCDCService.EXPECT().DeleteChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+			Accepted: true,
+			Succeed:  true,
+		}, nil).Times(1)
+		mockCDCService.EXPECT().CreateChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+			Accepted: true,
+			Succeed:  true,
+		}, nil).Times(1)
+
+		_, err := GetManager().Update(context.TODO(), cluster.UpdateChangeFeedTaskReq{
+			ID:             "taskId",
+			Name:           "aa",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(tso.GenerateTSO(startTime, 0)), task.StartTS)
+	})
+	t.Run("start time delete failed", func(t *testing.T) {
+		queryGCSafePoint = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (time.Time, error) {
+			return time.Now().Add(-time.Hour), nil
+		}
+		defer func() { queryGCSafePoint = utilsql.QueryGCSafePoint }()
+
+		changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).Return(&changefeed.ChangeFeedTask{
+			Entity: common.Entity{
+				Status: string(constants.ChangeFeedStatusNormal),
+				ID:     "taskId",
+			},
+			ClusterId:  "clusterId",
+			StartTS:    1,
+			Downstream: &changefeed.TiDBDownstream{},
+		}, nil).Times(1)
+		mockCDCService.EXPECT().DeleteChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{}, errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_EXECUTE_ERROR)).Times(1)
+
+		startTime := time.Now().Add(-time.Minute)
+		_, err := GetManager().Update(context.TODO(), cluster.UpdateChangeFeedTaskReq{
+			ID:             "taskId",
+			Name:           "aa",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.Error(t, err)
+	})
+	t.Run("start time create failed", func(t *testing.T) {
+		queryGCSafePoint = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (time.Time, error) {
+			return time.Now().Add(-time.Hour), nil
+		}
+		defer func() { queryGCSafePoint = utilsql.QueryGCSafePoint }()
 
+		task := &changefeed.ChangeFeedTask{
+			Entity: common.Entity{
+				Status: string(constants.ChangeFeedStatusNormal),
+				ID:     "taskId",
+			},
+			ClusterId:  "clusterId",
+			StartTS:    1,
+			Downstream: &changefeed.TiDBDownstream{},
+		}
+		changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).Return(task, nil).Times(1)
+		mockCDCService.EXPECT().DeleteChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+			Accepted: true,
+			Succeed:  true,
+		}, nil).Times(1)
+		mockCDCService.EXPECT().CreateChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+			Accepted: true,
+			Succeed:  false,
+		}, nil).Times(1)
+
+		startTime := time.Now().Add(-time.Minute)
+		_, err := GetManager().Update(context.TODO(), cluster.UpdateChangeFeedTaskReq{
+			ID:             "taskId",
+			Name:           "aa",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), task.StartTS)
+	})
+	t.Run("start time before gc safe point", func(t *testing.T) {
+		queryGCSafePoint = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (time.Time, error) {
+			return time.Now(), nil
+		}
+		defer func() { queryGCSafePoint = utilsql.QueryGCSafePoint }()
+
+		changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).Return(&changefeed.ChangeFeedTask{
+			Entity: common.Entity{
+				Status: string(constants.ChangeFeedStatusNormal),
+				ID:     "taskId",
+			},
+			ClusterId:  "clusterId",
+			Downstream: &changefeed.TiDBDownstream{},
+		}, nil).Times(1)
+		startTime := time.Now().Add(-time.Minute)
+		_, err := GetManager().Update(context.TODO(), cluster.UpdateChangeFeedTaskReq{
+			ID:             "taskId",
+			Name:           "aa",
+			StartTime:      &startTime,
+			DownstreamType: "tidb",
+			Downstream: changefeed.TiDBDownstream{
+				Port: 11,
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CHANGE_FEED_START_BEFORE_GC, err.(errors.EMError).GetCode())
+	})
+	t.Run("invalid downstream", func(t *testing.T) {
+		changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).Return(&changefeed.ChangeFeedTask{
+			Entity: common.Entity{
+				Status: string(constants.ChangeFeedStatusStopped),
+				ID:     "taskId",
+			},
+			ClusterId:  "clusterId",
+			Type:       constants.DownstreamTypePulsar,
+			Downstream: &changefeed.PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic"},
+		}, nil).Times(1)
+
+		_, err := GetManager().Update(context.TODO(), cluster.UpdateChangeFeedTaskReq{
+			Name:           "aa",
+			DownstreamType: "pulsar",
+			Downstream: changefeed.PulsarDownstream{
+				Ip: "127.0.0.1",
+			},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func Test_buildSinkConfig(t *testing.T) {
+	t.Run("not kafka", func(t *testing.T) {
+		assert.Nil(t, buildSinkConfig(&changefeed.ChangeFeedTask{Downstream: &changefeed.TiDBDownstream{}}))
+	})
+	t.Run("default protocol", func(t *testing.T) {
+		config := buildSinkConfig(&changefeed.ChangeFeedTask{Downstream: &changefeed.KafkaDownstream{}})
+		assert.Equal(t, &cdc.SinkConfig{Protocol: "default"}, config)
+	})
+	t.Run("kafka", func(t *testing.T) {
+		config := buildSinkConfig(&changefeed.ChangeFeedTask{Downstream: &changefeed.KafkaDownstream{
+			Protocol:        "avro",
+			SchemaRegistry:  "http://127.0.0.1:8081",
+			Dispatchers:     []changefeed.Dispatcher{{Matcher: "test.*", Dispatcher: "ts", Topic: "test_{table}"}},
+			TopicExpression: "{schema}_{table}",
+			ColumnSelectors: []changefeed.ColumnSelector{{Matcher: "test.t1", Columns: []string{"id", "name"}}},
+		}})
+		assert.Equal(t, &cdc.SinkConfig{
+			Protocol:       "avro",
+			SchemaRegistry: "http://127.0.0.1:8081",
+			DispatchRules: []cdc.DispatchRule{
+				{Matcher: []string{"test.*"}, PartitionRule: "ts", TopicRule: "test_{table}"},
+				{Matcher: []string{"*.*"}, TopicRule: "{schema}_{table}"},
+			},
+			ColumnSelectors: []cdc.ColumnSelector{{Matcher: []string{"test.t1"}, Columns: []string{"id", "name"}}},
+		}, config)
+	})
+}
+
+func Test_parse(t *testing.T) {
+	downstream := &changefeed.PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic", Token: "abc"}
+	info := parse(changefeed.ChangeFeedTask{
+		Entity:     common.Entity{ID: "taskId"},
+		Type:       constants.DownstreamTypePulsar,
+		Downstream: downstream,
+	})
+	assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), info.Downstream.(*changefeed.PulsarDownstream).Token)
+	assert.Equal(t, "topic", info.Downstream.(*changefeed.PulsarDownstream).TopicName)
+	assert.Equal(t, structs.SensitiveText("abc"), downstream.Token)
 }
 
 func TestManager_Query(t *testing.T) {
@@ -440,6 +747,9 @@ func TestManager_Detail(t *testing.T) {
 
 	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
 	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().QueryEvents(gomock.Any(), "taskId", detailEventsLength).Return([]*changefeed.ChangeFeedEvent{
+		{Entity: common.Entity{ID: "eventId"}, TaskId: "taskId", Type: constants.ChangeFeedEventLagWarning},
+	}, nil).AnyTimes()
 
 	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
 	cdc.CDCService = mockCDCService
@@ -464,6 +774,8 @@ func TestManager_Detail(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "taskId", resp.ID)
 		assert.Equal(t, "9999", resp.DownstreamSyncTS)
+		assert.Equal(t, 1, len(resp.Events))
+		assert.Equal(t, string(constants.ChangeFeedEventLagWarning), resp.Events[0].Type)
 
 	})
 	t.Run("error", func(t *testing.T) {
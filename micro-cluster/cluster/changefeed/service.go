@@ -194,6 +194,13 @@ func (p *Manager) Detail(ctx context.Context, request cluster.DetailChangeFeedTa
 	}
 	resp.ChangeFeedTaskInfo = parse(*task)
 
+	events, eventsError := models.GetChangeFeedReaderWriter().QueryEvents(ctx, task.ID, detailEventsLength)
+	if eventsError == nil {
+		resp.Events = parseEvents(events)
+	} else {
+		framework.LogWithContext(ctx).Errorf("query events of change feed task %s err = %s", task.ID, eventsError.Error())
+	}
+
 	taskDetail, detailError := cdc.CDCService.DetailChangeFeedTask(ctx, cdc.ChangeFeedDetailReq{
 		CDCAddress:   clusterMeta.GetCDCClientAddresses()[0].ToString(),
 		ChangeFeedID: task.ID,
@@ -0,0 +1,394 @@
+/******************************************************************************
+ * Copyright (c)  2021 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/metrics"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/api/cdc"
+	"github.com/robfig/cron"
+)
+
+// backoff of auto resume stops doubling at this value
+const maxAutoResumeBackoff = 30 * time.Minute
+
+// number of latest events returned with task detail
+const detailEventsLength = 20
+
+// states of change feed in TiCDC
+const (
+	cdcStateNormal = "normal"
+	cdcStateError  = "error"
+	cdcStateFailed = "failed"
+)
+
+// errors of TiCDC which cannot be recovered by resuming change feed
+var unretryableCDCErrors = []string{
+	"ErrGCTTLExceeded",
+	"ErrSnapshotLostByGC",
+	"ErrStartTsBeforeGC",
+	"ErrSchemaStorageGCed",
+	"ErrSinkURIInvalid",
+}
+
+type changeFeedWatcher struct {
+	JobCron *cron.Cron
+	JobSpec string
+}
+
+type changeFeedWatchHandler struct {
+	running int32
+	// cluster id by task id, for removing lag gauges of tasks no longer watched
+	exported map[string]string
+}
+
+type watchConfig struct {
+	warningThreshold  time.Duration
+	criticalThreshold time.Duration
+	resumeBackoff     time.Duration
+}
+
+var watcher *changeFeedWatcher
+var watcherOnce sync.Once
+
+// StartChangeFeedWatcher
+// @Description: watch lag and state of all unfinished change feed tasks in background
+func StartChangeFeedWatcher() {
+	watcherOnce.Do(func() {
+		watcher = &changeFeedWatcher{
+			JobCron: cron.New(),
+			JobSpec: "*/30 * * * * *", // every 30 seconds
+		}
+		err := watcher.JobCron.AddJob(watcher.JobSpec, &changeFeedWatchHandler{})
+		if err != nil {
+			framework.Log().Fatalf("add change feed watcher cron job failed, %s", err.Error())
+			return
+		}
+		go watcher.start()
+	})
+}
+
+func (w *changeFeedWatcher) start() {
+	time.Sleep(5 * time.Second) //wait db client ready
+	w.JobCron.Start()
+	defer w.JobCron.Stop()
+
+	select {}
+}
+
+func (handler *changeFeedWatchHandler) Run() {
+	if !atomic.CompareAndSwapInt32(&handler.running, 0, 1) {
+		framework.Log().Warnf("last round of change feed watcher is still running, skip this round")
+		return
+	}
+	defer atomic.StoreInt32(&handler.running, 0)
+
+	tasks, err := models.GetChangeFeedReaderWriter().QueryByStatus(context.TODO(), constants.UnfinishedChangeFeedStatus())
+	if err != nil {
+		framework.Log().Errorf("query unfinished change feed tasks failed, %s", err.Error())
+		return
+	}
+
+	config := getWatchConfig(context.TODO())
+	exported := make(map[string]string)
+	for _, task := range tasks {
+		// not created in TiCDC yet
+		if task.Status == constants.ChangeFeedStatusInitial.ToString() {
+			continue
+		}
+		ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: task.TenantId})
+		watchChangeFeedTask(ctx, task, config, time.Now())
+		exported[task.ID] = task.ClusterId
+	}
+
+	for taskID, clusterID := range handler.exported {
+		if _, ok := exported[taskID]; !ok {
+			metrics.GetMetrics().ChangeFeedLagGaugeMetric.DeleteLabelValues(clusterID, taskID)
+		}
+	}
+	handler.exported = exported
+}
+
+// watchChangeFeedTask
+// @Description: measure checkpoint lag of task, raise events and resume task automatically according to its state in TiCDC
+// @Parameter ctx
+// @Parameter task
+// @Parameter config
+// @Parameter now
+func watchChangeFeedTask(ctx context.Context, task *changefeed.ChangeFeedTask, config watchConfig, now time.Time) {
+	clusterMeta, err := meta.Get(ctx, task.ClusterId)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("watch change feed task %s failed, get cluster %s err = %s", task.ID, task.ClusterId, err.Error())
+		return
+	}
+	cdcAddress := clusterMeta.GetCDCClientAddresses()
+	if len(cdcAddress) == 0 {
+		framework.LogWithContext(ctx).Warnf("watch change feed task %s failed, no CDC component in cluster %s", task.ID, task.ClusterId)
+		return
+	}
+	detail, err := cdc.CDCService.DetailChangeFeedTask(ctx, cdc.ChangeFeedDetailReq{
+		CDCAddress:   cdcAddress[0].ToString(),
+		ChangeFeedID: task.ID,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("watch change feed task %s failed, detail err = %s", task.ID, err.Error())
+		return
+	}
+
+	task.CheckedTime = now
+	if detail.CheckPointTSO > 0 {
+		checkpoint, _ := tso.ParseTS(detail.CheckPointTSO)
+		task.CheckpointLag = 0
+		if lag := now.Sub(checkpoint); lag > 0 {
+			task.CheckpointLag = lag.Milliseconds()
+		}
+		metrics.GetMetrics().ChangeFeedLagGaugeMetric.
+			WithLabelValues(task.ClusterId, task.ID).
+			Set(float64(task.CheckpointLag) / 1000)
+	}
+
+	watchLag(ctx, task, config)
+	watchState(ctx, clusterMeta, task, detail.ChangeFeedInfo, config, now)
+
+	if err = models.GetChangeFeedReaderWriter().UpdateHealth(ctx, task); err != nil {
+		framework.LogWithContext(ctx).Errorf("update health of change feed task %s failed, %s", task.ID, err.Error())
+	}
+}
+
+// watchLag
+// @Description: raise an event when lag level of task changes
+// @Parameter ctx
+// @Parameter task
+// @Parameter config
+func watchLag(ctx context.Context, task *changefeed.ChangeFeedTask, config watchConfig) {
+	lag := time.Duration(task.CheckpointLag) * time.Millisecond
+	level := constants.ChangeFeedLagNormal
+	// lag of paused task grows as expected
+	if task.Status != constants.ChangeFeedStatusStopped.ToString() {
+		level = lagLevel(lag, config)
+	}
+	previous := constants.ChangeFeedLagLevel(task.LagLevel)
+	task.LagLevel = string(level)
+	if level.Severity() == previous.Severity() {
+		return
+	}
+
+	switch level {
+	case constants.ChangeFeedLagCritical:
+		raiseEvent(ctx, task, constants.ChangeFeedEventLagCritical,
+			fmt.Sprintf("checkpoint lag %s exceeds critical threshold %s", lag, config.criticalThreshold))
+	case constants.ChangeFeedLagWarning:
+		raiseEvent(ctx, task, constants.ChangeFeedEventLagWarning,
+			fmt.Sprintf("checkpoint lag %s exceeds warning threshold %s", lag, config.warningThreshold))
+	default:
+		raiseEvent(ctx, task, constants.ChangeFeedEventLagRecovered,
+			fmt.Sprintf("checkpoint lag %s recovered from %s", lag, previous))
+	}
+}
+
+func lagLevel(lag time.Duration, config watchConfig) constants.ChangeFeedLagLevel {
+	if config.criticalThreshold > 0 && lag >= config.criticalThreshold {
+		return constants.ChangeFeedLagCritical
+	}
+	if config.warningThreshold > 0 && lag >= config.warningThreshold {
+		return constants.ChangeFeedLagWarning
+	}
+	return constants.ChangeFeedLagNormal
+}
+
+// watchState
+// @Description: keep task status consistent with TiCDC and resume task in error state if auto resume is enabled
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter task
+// @Parameter info state of change feed in TiCDC
+// @Parameter config
+// @Parameter now
+func watchState(ctx context.Context, clusterMeta *meta.ClusterMeta, task *changefeed.ChangeFeedTask, info cdc.ChangeFeedInfo, config watchConfig, now time.Time) {
+	switch info.State {
+	case cdcStateError, cdcStateFailed:
+		task.LastError = info.Error
+		if task.Status == constants.ChangeFeedStatusNormal.ToString() {
+			if err := changeTaskStatus(ctx, task, constants.ChangeFeedStatusError); err != nil {
+				return
+			}
+			raiseEvent(ctx, task, constants.ChangeFeedEventTaskError,
+				fmt.Sprintf("change feed is %s in TiCDC, %s", info.State, info.Error))
+		}
+		if task.Status == constants.ChangeFeedStatusError.ToString() && task.AutoResume {
+			autoResume(ctx, clusterMeta, task, info, config, now)
+		}
+	case cdcStateNormal:
+		if task.Status == constants.ChangeFeedStatusError.ToString() {
+			if err := changeTaskStatus(ctx, task, constants.ChangeFeedStatusNormal); err != nil {
+				return
+			}
+			raiseEvent(ctx, task, constants.ChangeFeedEventTaskRecovered, "change feed is normal in TiCDC")
+		}
+		// retries are counted again once task catches up
+		if task.Status == constants.ChangeFeedStatusNormal.ToString() &&
+			task.LagLevel == string(constants.ChangeFeedLagNormal) {
+			task.LastError = ""
+			task.ResumeRetries = 0
+			task.NextResumeTime = time.Time{}
+		}
+	}
+}
+
+// autoResume
+// @Description: resume task with exponential backoff until max retries of its policy is reached
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter task
+// @Parameter info
+// @Parameter config
+// @Parameter now
+func autoResume(ctx context.Context, clusterMeta *meta.ClusterMeta, task *changefeed.ChangeFeedTask, info cdc.ChangeFeedInfo, config watchConfig, now time.Time) {
+	maxRetries := task.AutoResumeMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = constants.DefaultChangeFeedAutoResumeMaxRetries
+	}
+	if task.ResumeRetries > maxRetries {
+		return
+	}
+
+	if !isRetryableCDCError(info) {
+		raiseEvent(ctx, task, constants.ChangeFeedEventAutoResumeExhausted,
+			fmt.Sprintf("auto resume is abandoned, change feed is %s in TiCDC with unretryable error %s", info.State, info.Error))
+		task.ResumeRetries = maxRetries + 1
+		return
+	}
+	if task.ResumeRetries == maxRetries {
+		raiseEvent(ctx, task, constants.ChangeFeedEventAutoResumeExhausted,
+			fmt.Sprintf("auto resume is abandoned after %d attempts, last error %s", maxRetries, info.Error))
+		task.ResumeRetries = maxRetries + 1
+		return
+	}
+	if now.Before(task.NextResumeTime) {
+		return
+	}
+
+	if err := models.GetChangeFeedReaderWriter().LockStatus(ctx, task.ID); err != nil {
+		framework.LogWithContext(ctx).Warnf("auto resume change feed task %s skipped, %s", task.ID, err.Error())
+		return
+	}
+	task.ResumeRetries++
+	task.NextResumeTime = now.Add(autoResumeBackoff(config.resumeBackoff, task.ResumeRetries))
+	if err := GetManager().resumeExecutor(ctx, clusterMeta, task); err != nil {
+		models.GetChangeFeedReaderWriter().UnlockStatus(ctx, task.ID, constants.ChangeFeedStatusError)
+		raiseEvent(ctx, task, constants.ChangeFeedEventAutoResumeFailed,
+			fmt.Sprintf("auto resume attempt %d/%d failed, %s", task.ResumeRetries, maxRetries, err.Error()))
+		return
+	}
+	task.Status = constants.ChangeFeedStatusNormal.ToString()
+	raiseEvent(ctx, task, constants.ChangeFeedEventAutoResumed,
+		fmt.Sprintf("auto resume attempt %d/%d succeeded, error before resuming %s", task.ResumeRetries, maxRetries, info.Error))
+}
+
+func isRetryableCDCError(info cdc.ChangeFeedInfo) bool {
+	// TiCDC never retries a failed change feed by itself
+	if info.State == cdcStateFailed {
+		return false
+	}
+	for _, e := range unretryableCDCErrors {
+		if strings.Contains(info.Error, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// autoResumeBackoff
+// @Description: interval to wait after the attempt, doubled every attempt
+// @Parameter base
+// @Parameter attempt starts from 1
+// @return time.Duration
+func autoResumeBackoff(base time.Duration, attempt int) time.Duration {
+	backoff := base
+	for i := 1; i < attempt && backoff < maxAutoResumeBackoff; i++ {
+		backoff = backoff * 2
+	}
+	if backoff > maxAutoResumeBackoff {
+		return maxAutoResumeBackoff
+	}
+	return backoff
+}
+
+func changeTaskStatus(ctx context.Context, task *changefeed.ChangeFeedTask, status constants.ChangeFeedStatus) error {
+	rw := models.GetChangeFeedReaderWriter()
+	if err := rw.LockStatus(ctx, task.ID); err != nil {
+		framework.LogWithContext(ctx).Warnf("change status of change feed task %s to %s skipped, %s", task.ID, status, err.Error())
+		return err
+	}
+	if err := rw.UnlockStatus(ctx, task.ID, status); err != nil {
+		framework.LogWithContext(ctx).Errorf("change status of change feed task %s to %s failed, %s", task.ID, status, err.Error())
+		return err
+	}
+	task.Status = status.ToString()
+	return nil
+}
+
+func raiseEvent(ctx context.Context, task *changefeed.ChangeFeedTask, eventType constants.ChangeFeedEventType, message string) {
+	framework.LogWithContext(ctx).Warnf("change feed task %s of cluster %s raised event %s, %s", task.ID, task.ClusterId, eventType, message)
+	_, err := models.GetChangeFeedReaderWriter().CreateEvent(ctx, &changefeed.ChangeFeedEvent{
+		Entity: dbCommon.Entity{
+			TenantId: task.TenantId,
+		},
+		TaskId:    task.ID,
+		ClusterId: task.ClusterId,
+		Type:      eventType,
+		Message:   message,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("record event %s of change feed task %s failed, %s", eventType, task.ID, err.Error())
+	}
+}
+
+func parseEvents(events []*changefeed.ChangeFeedEvent) []cluster.ChangeFeedEvent {
+	result := make([]cluster.ChangeFeedEvent, 0, len(events))
+	for _, e := range events {
+		result = append(result, cluster.ChangeFeedEvent{
+			ID:         e.ID,
+			Type:       string(e.Type),
+			Message:    e.Message,
+			CreateTime: e.CreatedAt,
+		})
+	}
+	return result
+}
+
+func getWatchConfig(ctx context.Context) watchConfig {
+	return watchConfig{
+		warningThreshold:  time.Duration(platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyChangeFeedLagWarningThreshold, constants.DefaultChangeFeedLagWarningThreshold)) * time.Second,
+		criticalThreshold: time.Duration(platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyChangeFeedLagCriticalThreshold, constants.DefaultChangeFeedLagCriticalThreshold)) * time.Second,
+		resumeBackoff:     time.Duration(platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyChangeFeedAutoResumeBackoff, constants.DefaultChangeFeedAutoResumeBackoff)) * time.Second,
+	}
+}
@@ -0,0 +1,430 @@
+/******************************************************************************
+ * Copyright (c)  2021 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/metrics"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockchangefeed"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/pingcap/tiunimanager/test/mockutilcdc"
+	"github.com/pingcap/tiunimanager/util/api/cdc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var testWatchConfig = watchConfig{
+	warningThreshold:  time.Minute,
+	criticalThreshold: 10 * time.Minute,
+	resumeBackoff:     30 * time.Second,
+}
+
+func mockWatchedCluster(ctrl *gomock.Controller) {
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "clusterId").Return(&management.Cluster{}, []*management.ClusterInstance{
+		{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{111}},
+	}, []*management.DBUser{}, nil).AnyTimes()
+}
+
+func mockChangeFeedDetail(ctrl *gomock.Controller, state string, lag time.Duration, cdcError string) *mockutilcdc.MockChangeFeedService {
+	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+	cdc.CDCService = mockCDCService
+	mockCDCService.EXPECT().DetailChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedDetailResp{
+		ChangeFeedInfo: cdc.ChangeFeedInfo{
+			ChangeFeedID:  "taskId",
+			State:         state,
+			CheckPointTSO: tso.GenerateTSO(time.Now().Add(-lag), 0),
+			Error:         cdcError,
+		},
+	}, nil).AnyTimes()
+	return mockCDCService
+}
+
+// expectEvents records types of events raised in order
+func expectEvents(rw *mockchangefeed.MockReaderWriter, events *[]constants.ChangeFeedEventType) {
+	rw.EXPECT().CreateEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event *changefeed.ChangeFeedEvent) (*changefeed.ChangeFeedEvent, error) {
+		*events = append(*events, event.Type)
+		return event, nil
+	}).AnyTimes()
+}
+
+func Test_watchChangeFeedTask(t *testing.T) {
+	t.Run("lag warning", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockChangeFeedDetail(ctrl, cdcStateNormal, 90*time.Second, "")
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:    common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusNormal)},
+			ClusterId: "clusterId",
+			LagLevel:  string(constants.ChangeFeedLagNormal),
+		}
+		now := time.Now()
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, now)
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventLagWarning}, events)
+		assert.Equal(t, string(constants.ChangeFeedLagWarning), task.LagLevel)
+		assert.InDelta(t, 90000, task.CheckpointLag, 1000)
+		assert.Equal(t, now, task.CheckedTime)
+		assert.InDelta(t, 90, testutil.ToFloat64(metrics.GetMetrics().ChangeFeedLagGaugeMetric.WithLabelValues("clusterId", "taskId")), 1)
+
+		// no event if level is not changed
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, now)
+		assert.Equal(t, 1, len(events))
+	})
+	t.Run("lag recovered", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockChangeFeedDetail(ctrl, cdcStateNormal, time.Second, "")
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:         common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusNormal)},
+			ClusterId:      "clusterId",
+			LagLevel:       string(constants.ChangeFeedLagCritical),
+			LastError:      "error",
+			ResumeRetries:  2,
+			NextResumeTime: time.Now(),
+		}
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, time.Now())
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventLagRecovered}, events)
+		assert.Equal(t, string(constants.ChangeFeedLagNormal), task.LagLevel)
+		assert.Empty(t, task.LastError)
+		assert.Equal(t, 0, task.ResumeRetries)
+		assert.True(t, task.NextResumeTime.IsZero())
+	})
+	t.Run("stopped", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockChangeFeedDetail(ctrl, "stopped", time.Hour, "")
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:    common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusStopped)},
+			ClusterId: "clusterId",
+		}
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, time.Now())
+		assert.Equal(t, string(constants.ChangeFeedLagNormal), task.LagLevel)
+		assert.InDelta(t, 3600000, task.CheckpointLag, 1000)
+	})
+	t.Run("error auto resumed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockCDCService := mockChangeFeedDetail(ctrl, cdcStateError, 5*time.Second, "[CDC:ErrKafkaNewSaramaProducer]")
+		mockCDCService.EXPECT().ResumeChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+			Accepted: true,
+			Succeed:  true,
+		}, nil).Times(1)
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+		changefeedRW.EXPECT().LockStatus(gomock.Any(), "taskId").Return(nil).Times(2)
+		changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "taskId", constants.ChangeFeedStatusError).Return(nil).Times(1)
+		changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "taskId", constants.ChangeFeedStatusNormal).Return(nil).Times(1)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:     common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusNormal)},
+			ClusterId:  "clusterId",
+			AutoResume: true,
+		}
+		now := time.Now()
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, now)
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventTaskError, constants.ChangeFeedEventAutoResumed}, events)
+		assert.Equal(t, string(constants.ChangeFeedStatusNormal), task.Status)
+		assert.Equal(t, "[CDC:ErrKafkaNewSaramaProducer]", task.LastError)
+		assert.Equal(t, 1, task.ResumeRetries)
+		assert.Equal(t, now.Add(30*time.Second), task.NextResumeTime)
+	})
+	t.Run("error without auto resume", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockChangeFeedDetail(ctrl, cdcStateError, 5*time.Second, "[CDC:ErrKafkaNewSaramaProducer]")
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+		changefeedRW.EXPECT().LockStatus(gomock.Any(), "taskId").Return(nil).Times(1)
+		changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "taskId", constants.ChangeFeedStatusError).Return(nil).Times(1)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:    common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusNormal)},
+			ClusterId: "clusterId",
+		}
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, time.Now())
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventTaskError}, events)
+		assert.Equal(t, string(constants.ChangeFeedStatusError), task.Status)
+		assert.Equal(t, 0, task.ResumeRetries)
+	})
+	t.Run("task recovered", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockChangeFeedDetail(ctrl, cdcStateNormal, time.Second, "")
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+		changefeedRW.EXPECT().LockStatus(gomock.Any(), "taskId").Return(nil).Times(1)
+		changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "taskId", constants.ChangeFeedStatusNormal).Return(nil).Times(1)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:        common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusError)},
+			ClusterId:     "clusterId",
+			ResumeRetries: 1,
+		}
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, time.Now())
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventTaskRecovered}, events)
+		assert.Equal(t, string(constants.ChangeFeedStatusNormal), task.Status)
+		assert.Equal(t, 0, task.ResumeRetries)
+	})
+	t.Run("task locked", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockChangeFeedDetail(ctrl, cdcStateError, time.Second, "error")
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		changefeedRW.EXPECT().LockStatus(gomock.Any(), "taskId").Return(errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_STATUS_CONFLICT)).Times(1)
+		changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:     common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusNormal)},
+			ClusterId:  "clusterId",
+			AutoResume: true,
+		}
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, time.Now())
+		assert.Equal(t, string(constants.ChangeFeedStatusNormal), task.Status)
+		assert.Equal(t, 0, task.ResumeRetries)
+	})
+	t.Run("detail failed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+		cdc.CDCService = mockCDCService
+		mockCDCService.EXPECT().DetailChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedDetailResp{}, errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_EXECUTE_ERROR)).Times(1)
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+
+		task := &changefeed.ChangeFeedTask{
+			Entity:    common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusNormal)},
+			ClusterId: "clusterId",
+		}
+		watchChangeFeedTask(context.TODO(), task, testWatchConfig, time.Now())
+		assert.True(t, task.CheckedTime.IsZero())
+	})
+}
+
+func Test_autoResume(t *testing.T) {
+	errorTask := func() *changefeed.ChangeFeedTask {
+		return &changefeed.ChangeFeedTask{
+			Entity:               common.Entity{ID: "taskId", Status: string(constants.ChangeFeedStatusError)},
+			ClusterId:            "clusterId",
+			AutoResume:           true,
+			AutoResumeMaxRetries: 3,
+		}
+	}
+	retryable := cdc.ChangeFeedInfo{State: cdcStateError, Error: "[CDC:ErrKafkaNewSaramaProducer]"}
+
+	t.Run("waiting", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		models.SetChangeFeedReaderWriter(mockchangefeed.NewMockReaderWriter(ctrl))
+
+		task := errorTask()
+		task.ResumeRetries = 1
+		task.NextResumeTime = time.Now().Add(time.Minute)
+		autoResume(context.TODO(), nil, task, retryable, testWatchConfig, time.Now())
+		assert.Equal(t, 1, task.ResumeRetries)
+	})
+	t.Run("failed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		mockWatchedCluster(ctrl)
+		mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+		cdc.CDCService = mockCDCService
+		mockCDCService.EXPECT().ResumeChangeFeedTask(gomock.Any(), gomock.Any()).Return(cdc.ChangeFeedCmdAcceptResp{
+			Accepted: false,
+		}, nil).Times(1)
+
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+		changefeedRW.EXPECT().LockStatus(gomock.Any(), "taskId").Return(nil).Times(1)
+		changefeedRW.EXPECT().UnlockStatus(gomock.Any(), "taskId", constants.ChangeFeedStatusError).Return(nil).Times(1)
+
+		task := errorTask()
+		task.ResumeRetries = 2
+		now := time.Now()
+		autoResume(context.TODO(), clusterMetaWithCDC(), task, retryable, testWatchConfig, now)
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventAutoResumeFailed}, events)
+		assert.Equal(t, 3, task.ResumeRetries)
+		assert.Equal(t, now.Add(2*time.Minute), task.NextResumeTime)
+		assert.Equal(t, string(constants.ChangeFeedStatusError), task.Status)
+	})
+	t.Run("exhausted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+
+		task := errorTask()
+		task.ResumeRetries = 3
+		autoResume(context.TODO(), nil, task, retryable, testWatchConfig, time.Now())
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventAutoResumeExhausted}, events)
+		assert.Equal(t, 4, task.ResumeRetries)
+
+		// reported only once
+		autoResume(context.TODO(), nil, task, retryable, testWatchConfig, time.Now())
+		assert.Equal(t, 1, len(events))
+		assert.Equal(t, 4, task.ResumeRetries)
+	})
+	t.Run("unretryable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+		models.SetChangeFeedReaderWriter(changefeedRW)
+		events := make([]constants.ChangeFeedEventType, 0)
+		expectEvents(changefeedRW, &events)
+
+		task := errorTask()
+		task.AutoResumeMaxRetries = 0
+		autoResume(context.TODO(), nil, task, cdc.ChangeFeedInfo{State: cdcStateFailed, Error: "[CDC:ErrGCTTLExceeded]"}, testWatchConfig, time.Now())
+		assert.Equal(t, []constants.ChangeFeedEventType{constants.ChangeFeedEventAutoResumeExhausted}, events)
+		assert.Equal(t, constants.DefaultChangeFeedAutoResumeMaxRetries+1, task.ResumeRetries)
+	})
+}
+
+func clusterMetaWithCDC() *meta.ClusterMeta {
+	return &meta.ClusterMeta{
+		Instances: map[string][]*management.ClusterInstance{
+			"CDC": {
+				{Type: "CDC", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{111}},
+			},
+		},
+	}
+}
+
+func Test_lagLevel(t *testing.T) {
+	assert.Equal(t, constants.ChangeFeedLagNormal, lagLevel(59*time.Second, testWatchConfig))
+	assert.Equal(t, constants.ChangeFeedLagWarning, lagLevel(time.Minute, testWatchConfig))
+	assert.Equal(t, constants.ChangeFeedLagCritical, lagLevel(time.Hour, testWatchConfig))
+	assert.Equal(t, constants.ChangeFeedLagNormal, lagLevel(time.Hour, watchConfig{}))
+	assert.Equal(t, constants.ChangeFeedLagWarning, lagLevel(time.Hour, watchConfig{warningThreshold: time.Minute}))
+}
+
+func Test_autoResumeBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, autoResumeBackoff(30*time.Second, 1))
+	assert.Equal(t, 2*time.Minute, autoResumeBackoff(30*time.Second, 3))
+	assert.Equal(t, maxAutoResumeBackoff, autoResumeBackoff(30*time.Second, 100))
+	assert.Equal(t, time.Duration(0), autoResumeBackoff(0, 3))
+}
+
+func Test_isRetryableCDCError(t *testing.T) {
+	assert.True(t, isRetryableCDCError(cdc.ChangeFeedInfo{State: cdcStateError, Error: "[CDC:ErrKafkaNewSaramaProducer]"}))
+	assert.False(t, isRetryableCDCError(cdc.ChangeFeedInfo{State: cdcStateError, Error: "[CDC:ErrSnapshotLostByGC]"}))
+	assert.False(t, isRetryableCDCError(cdc.ChangeFeedInfo{State: cdcStateFailed, Error: "[CDC:ErrKafkaNewSaramaProducer]"}))
+}
+
+func Test_changeFeedWatchHandler_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockWatchedCluster(ctrl)
+	mockCDCService := mockutilcdc.NewMockChangeFeedService(ctrl)
+	cdc.CDCService = mockCDCService
+	mockCDCService.EXPECT().DetailChangeFeedTask(gomock.Any(), cdc.ChangeFeedDetailReq{CDCAddress: "127.0.0.1:111", ChangeFeedID: "normalTask"}).Return(cdc.ChangeFeedDetailResp{
+		ChangeFeedInfo: cdc.ChangeFeedInfo{
+			State:         cdcStateNormal,
+			CheckPointTSO: tso.GenerateTSO(time.Now().Add(-2*time.Minute), 0),
+		},
+	}, nil).Times(1)
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key string) (*config.SystemConfig, error) {
+		if key == constants.ConfigKeyChangeFeedLagWarningThreshold {
+			return &config.SystemConfig{ConfigKey: key, ConfigValue: "180"}, nil
+		}
+		return nil, errors.Error(errors.TIUNIMANAGER_SYSTEM_MISSING_CONFIG)
+	}).AnyTimes()
+
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().QueryByStatus(gomock.Any(), constants.UnfinishedChangeFeedStatus()).Return([]*changefeed.ChangeFeedTask{
+		{Entity: common.Entity{ID: "initialTask", TenantId: "tenant", Status: string(constants.ChangeFeedStatusInitial)}, ClusterId: "clusterId"},
+		{Entity: common.Entity{ID: "normalTask", TenantId: "tenant", Status: string(constants.ChangeFeedStatusNormal)}, ClusterId: "clusterId"},
+	}, nil).Times(1)
+	changefeedRW.EXPECT().UpdateHealth(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, task *changefeed.ChangeFeedTask) error {
+		assert.Equal(t, "normalTask", task.ID)
+		// below configured warning threshold
+		assert.Equal(t, string(constants.ChangeFeedLagNormal), task.LagLevel)
+		return nil
+	}).Times(1)
+
+	metrics.GetMetrics().ChangeFeedLagGaugeMetric.WithLabelValues("clusterId", "finishedTask").Set(1)
+	handler := &changeFeedWatchHandler{
+		exported: map[string]string{"finishedTask": "clusterId"},
+	}
+	handler.Run()
+	assert.Equal(t, map[string]string{"normalTask": "clusterId"}, handler.exported)
+	assert.False(t, metrics.GetMetrics().ChangeFeedLagGaugeMetric.DeleteLabelValues("clusterId", "finishedTask"))
+	assert.InDelta(t, 120, testutil.ToFloat64(metrics.GetMetrics().ChangeFeedLagGaugeMetric.WithLabelValues("clusterId", "normalTask")), 1)
+}
@@ -0,0 +1,151 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/switchover"
+	"github.com/pingcap/tiunimanager/models"
+	dmapi "github.com/pingcap/tiunimanager/util/api/dm"
+)
+
+// replaced in unit tests, they replicate target cluster
+var createChangeFeedTask = func(ctx context.Context, request cluster.CreateChangeFeedTaskReq) (cluster.CreateChangeFeedTaskResp, error) {
+	return changefeed.GetManager().Create(ctx, request)
+}
+var createClusterRelation = func(ctx context.Context, request cluster.CreateClusterRelationReq) (cluster.CreateClusterRelationResp, error) {
+	return switchover.GetManager().CreateClusterRelation(ctx, request)
+}
+
+// Handoff
+// @Description: hand off target cluster of a caught up task to a change feed task or a standby cluster, then stop the task.
+// Writes to upstream sources must be stopped by caller and every sub task must be synced without lag, so that no change of
+// upstream is dropped. Replication of target cluster starts before the task is stopped, so that no change of target is missed.
+// If the task fails to stop, handoff is still recorded and the task is able to be stopped by StopTask
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) Handoff(ctx context.Context, request cluster.HandoffDMTaskReq) (resp cluster.HandoffDMTaskResp, err error) {
+	task, err := models.GetDMReaderWriter().GetTask(ctx, request.ID)
+	if err != nil {
+		return
+	}
+	resp.Status = task.Status
+	if constants.DMTaskStatus(task.Status) != constants.DMTaskStatusRunning {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, "task %s is %s", task.Name, task.Status)
+		return
+	}
+	if !request.UpstreamWritesStopped {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY,
+			"writes to upstream sources of task %s should be stopped before handoff, otherwise they are lost", task.Name)
+		return
+	}
+
+	status, err := queryTaskStatus(ctx, task)
+	if err != nil {
+		return
+	}
+	if err = checkHandoffReady(status); err != nil {
+		return
+	}
+
+	handoffType := constants.DMHandoffType(request.Type)
+	switch handoffType {
+	case constants.DMHandoffChangeFeed:
+		if request.ChangeFeed == nil {
+			err = errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "change feed task is required")
+			return
+		}
+		created, createErr := createChangeFeedTask(ctx, cluster.CreateChangeFeedTaskReq{
+			Name:           request.ChangeFeed.Name,
+			ClusterID:      task.TargetClusterId,
+			FilterRules:    request.ChangeFeed.FilterRules,
+			DownstreamType: request.ChangeFeed.DownstreamType,
+			Downstream:     request.ChangeFeed.Downstream,
+			AutoResume:     request.ChangeFeed.AutoResume,
+		})
+		if createErr != nil {
+			err = createErr
+			return
+		}
+		resp.HandoffTarget = created.ID
+	case constants.DMHandoffSwitchover:
+		if len(request.StandbyClusterID) == 0 || len(request.BackupID) == 0 {
+			err = errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "standby cluster and the backup of target cluster it is restored from are required")
+			return
+		}
+		// standby is a copy of target cluster only if it is restored from a backup of target cluster, replication starts from the backup
+		if _, err = createClusterRelation(ctx, cluster.CreateClusterRelationReq{
+			SourceClusterID: task.TargetClusterId,
+			TargetClusterID: request.StandbyClusterID,
+			RelationType:    string(constants.ClusterRelationStandBy),
+			BackupID:        request.BackupID,
+		}); err != nil {
+			return
+		}
+		resp.HandoffTarget = request.StandbyClusterID
+	default:
+		err = errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported handoff type %s", request.Type)
+		return
+	}
+	resp.HandoffType = string(handoffType)
+	if err = models.GetDMReaderWriter().UpdateHandoff(ctx, task.ID, handoffType, resp.HandoffTarget); err != nil {
+		return
+	}
+	framework.LogWithContext(ctx).Infof("dm task %s is handed off to %s %s", task.Name, resp.HandoffType, resp.HandoffTarget)
+
+	address, err := getDMMasterAddress(ctx, task.ClusterId)
+	if err != nil {
+		return
+	}
+	if err = dmapi.DMService.DeleteTask(ctx, dmapi.TaskCmdReq{DMMasterAddress: address, TaskName: task.Name}); err != nil {
+		return
+	}
+	if err = models.GetDMReaderWriter().UpdateStatus(ctx, task.ID, constants.DMTaskStatusHandedOff); err != nil {
+		return
+	}
+	resp.Status = string(constants.DMTaskStatusHandedOff)
+	return
+}
+
+// checkHandoffReady
+// @Description: every sub task should be running in incremental replication, and synced to binlog of upstream without lag
+func checkHandoffReady(status dmapi.QueryTaskStatusResp) error {
+	if len(status.Data) == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY, "no sub task is running")
+	}
+	for _, subTask := range status.Data {
+		if subTask.Unit != constants.DMUnitSync || subTask.SyncStatus == nil {
+			return errors.NewErrorf(errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY, "sub task of source %s is in unit %s", subTask.SourceName, subTask.Unit)
+		}
+		if len(subTask.ErrorMsg) > 0 || subTask.Stage == constants.DMStagePaused {
+			return errors.NewErrorf(errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY, "sub task of source %s is %s", subTask.SourceName, subTask.Stage)
+		}
+		if !subTask.SyncStatus.Synced || subTask.SyncStatus.SecondsBehindMaster > 0 {
+			return errors.NewErrorf(errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY, "source %s is not synced, lag is %ds",
+				subTask.SourceName, subTask.SyncStatus.SecondsBehindMaster)
+		}
+	}
+	return nil
+}
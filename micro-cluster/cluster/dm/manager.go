@@ -0,0 +1,481 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/dm"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	dmapi "github.com/pingcap/tiunimanager/util/api/dm"
+)
+
+var manager *Manager
+var once sync.Once
+
+type Manager struct{}
+
+func GetManager() *Manager {
+	once.Do(func() {
+		if manager == nil {
+			manager = &Manager{}
+		}
+	})
+	return manager
+}
+
+// RegisterSource
+// @Description: register an upstream MySQL instance as source of DM cluster
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) RegisterSource(ctx context.Context, request cluster.RegisterDMSourceReq) (resp cluster.RegisterDMSourceResp, err error) {
+	address, err := getDMMasterAddress(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+
+	source, err := models.GetDMReaderWriter().CreateSource(ctx, &dm.DMSource{
+		Entity:     dbCommon.Entity{TenantId: framework.GetTenantIDFromContext(ctx)},
+		ClusterId:  request.ClusterID,
+		Name:       request.Name,
+		Host:       request.Host,
+		Port:       request.Port,
+		User:       request.User,
+		Password:   dbCommon.Password(request.Password),
+		EnableGTID: request.EnableGTID,
+	})
+	if err != nil {
+		return
+	}
+
+	err = dmapi.DMService.CreateSource(ctx, dmapi.CreateSourceReq{
+		DMMasterAddress: address,
+		Source: dmapi.Source{
+			SourceName: request.Name,
+			Host:       request.Host,
+			Port:       request.Port,
+			User:       request.User,
+			Password:   string(request.Password),
+			EnableGTID: request.EnableGTID,
+			Enable:     true,
+		},
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create source %s in dm cluster %s failed, err = %s", request.Name, request.ClusterID, err.Error())
+		if deleteErr := models.GetDMReaderWriter().DeleteSource(ctx, request.ClusterID, request.Name); deleteErr != nil {
+			framework.LogWithContext(ctx).Errorf("delete source %s of dm cluster %s failed, err = %s", request.Name, request.ClusterID, deleteErr.Error())
+		}
+		return
+	}
+
+	resp.DMSourceInfo = convertSource(source)
+	return
+}
+
+// QuerySources
+// @Description: query sources of DM cluster
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) QuerySources(ctx context.Context, request cluster.QueryDMSourcesReq) (resp cluster.QueryDMSourcesResp, err error) {
+	sources, err := models.GetDMReaderWriter().QuerySources(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+	resp.Sources = make([]cluster.DMSourceInfo, 0, len(sources))
+	for _, source := range sources {
+		resp.Sources = append(resp.Sources, convertSource(source))
+	}
+	return
+}
+
+// DeleteSource
+// @Description: delete source of DM cluster, which is not used by any unfinished task
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) DeleteSource(ctx context.Context, request cluster.DeleteDMSourceReq) (resp cluster.DeleteDMSourceResp, err error) {
+	if _, err = models.GetDMReaderWriter().GetSource(ctx, request.ClusterID, request.Name); err != nil {
+		return
+	}
+	tasks, _, err := models.GetDMReaderWriter().QueryTasks(ctx, request.ClusterID, constants.UnfinishedDMTaskStatus(), 0, 0)
+	if err != nil {
+		return
+	}
+	for _, task := range tasks {
+		for _, source := range parseSources(task) {
+			if source == request.Name {
+				err = errors.NewErrorf(errors.TIUNIMANAGER_DM_SOURCE_IN_USE, "source %s is used by task %s", request.Name, task.Name)
+				return
+			}
+		}
+	}
+
+	address, err := getDMMasterAddress(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+	if err = dmapi.DMService.DeleteSource(ctx, dmapi.DeleteSourceReq{DMMasterAddress: address, SourceName: request.Name}); err != nil {
+		return
+	}
+	err = models.GetDMReaderWriter().DeleteSource(ctx, request.ClusterID, request.Name)
+	return
+}
+
+// CreateTask
+// @Description: create a data migration task in dm-master, the task is not started until StartTask
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) CreateTask(ctx context.Context, request cluster.CreateDMTaskReq) (resp cluster.CreateDMTaskResp, err error) {
+	address, err := getDMMasterAddress(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+	for _, source := range request.Sources {
+		if _, err = models.GetDMReaderWriter().GetSource(ctx, request.ClusterID, source); err != nil {
+			return
+		}
+	}
+	unfinished, _, err := models.GetDMReaderWriter().QueryTasks(ctx, request.ClusterID, constants.UnfinishedDMTaskStatus(), 0, 0)
+	if err != nil {
+		return
+	}
+	for _, task := range unfinished {
+		if task.Name == request.Name {
+			err = errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_INVALID, "task %s already exists in dm cluster %s", request.Name, request.ClusterID)
+			return
+		}
+	}
+
+	target, err := getTargetDatabase(ctx, request.TargetClusterID)
+	if err != nil {
+		return
+	}
+	config, err := buildTaskConfig(request, target)
+	if err != nil {
+		return
+	}
+
+	task := &dm.DMTask{
+		Entity: dbCommon.Entity{
+			TenantId: framework.GetTenantIDFromContext(ctx),
+			Status:   string(constants.DMTaskStatusCreated),
+		},
+		ClusterId:       request.ClusterID,
+		TargetClusterId: request.TargetClusterID,
+		Name:            request.Name,
+		TaskMode:        request.TaskMode,
+	}
+	if err = marshalTaskRules(task, request); err != nil {
+		return
+	}
+
+	if err = dmapi.DMService.CreateTask(ctx, dmapi.CreateTaskReq{DMMasterAddress: address, TaskConfigFile: config}); err != nil {
+		return
+	}
+	if _, err = models.GetDMReaderWriter().CreateTask(ctx, task); err != nil {
+		framework.LogWithContext(ctx).Errorf("save dm task %s failed, err = %s", request.Name, err.Error())
+		if deleteErr := dmapi.DMService.DeleteTask(ctx, dmapi.TaskCmdReq{DMMasterAddress: address, TaskName: request.Name}); deleteErr != nil {
+			framework.LogWithContext(ctx).Errorf("delete dm task %s failed, err = %s", request.Name, deleteErr.Error())
+		}
+		return
+	}
+	resp.ID = task.ID
+	return
+}
+
+// StartTask
+// @Description: start a created task, or resume a paused one
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) StartTask(ctx context.Context, request cluster.DMTaskReq) (resp cluster.DMTaskResp, err error) {
+	return p.command(ctx, request.ID, constants.DMTaskStatusRunning,
+		[]constants.DMTaskStatus{constants.DMTaskStatusCreated, constants.DMTaskStatusPaused, constants.DMTaskStatusError},
+		dmapi.DMService.StartTask)
+}
+
+// PauseTask
+// @Description: pause a running task, it is able to be resumed by StartTask
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) PauseTask(ctx context.Context, request cluster.DMTaskReq) (resp cluster.DMTaskResp, err error) {
+	return p.command(ctx, request.ID, constants.DMTaskStatusPaused,
+		[]constants.DMTaskStatus{constants.DMTaskStatusRunning, constants.DMTaskStatusError},
+		dmapi.DMService.StopTask)
+}
+
+// StopTask
+// @Description: stop a task and remove it from dm-master, a stopped task cannot be started again
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) StopTask(ctx context.Context, request cluster.DMTaskReq) (resp cluster.DMTaskResp, err error) {
+	return p.command(ctx, request.ID, constants.DMTaskStatusStopped,
+		constants.UnfinishedDMTaskStatus(),
+		dmapi.DMService.DeleteTask)
+}
+
+// command
+// @Description: execute command of dm-master on task in one of expected statuses, and update status of task
+func (p *Manager) command(ctx context.Context, taskID string, target constants.DMTaskStatus, expected []constants.DMTaskStatus,
+	execute func(ctx context.Context, req dmapi.TaskCmdReq) error) (resp cluster.DMTaskResp, err error) {
+	task, err := models.GetDMReaderWriter().GetTask(ctx, taskID)
+	if err != nil {
+		return
+	}
+	resp.Status = task.Status
+	if !containsStatus(expected, constants.DMTaskStatus(task.Status)) {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, "task %s is %s", task.Name, task.Status)
+		return
+	}
+
+	address, err := getDMMasterAddress(ctx, task.ClusterId)
+	if err != nil {
+		return
+	}
+	if err = execute(ctx, dmapi.TaskCmdReq{DMMasterAddress: address, TaskName: task.Name}); err != nil {
+		return
+	}
+	if err = models.GetDMReaderWriter().UpdateStatus(ctx, task.ID, target); err != nil {
+		return
+	}
+	resp.Status = string(target)
+	return
+}
+
+// QueryTasks
+// @Description: query tasks of DM cluster with progress refreshed by dm task watcher
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return total
+// @return err
+func (p *Manager) QueryTasks(ctx context.Context, request cluster.QueryDMTasksReq) (resp cluster.QueryDMTasksResp, total int, err error) {
+	statuses := make([]constants.DMTaskStatus, 0)
+	if len(request.Status) > 0 {
+		statuses = append(statuses, constants.DMTaskStatus(request.Status))
+	}
+	tasks, count, err := models.GetDMReaderWriter().QueryTasks(ctx, request.ClusterID, statuses, request.GetOffset(), request.PageSize)
+	if err != nil {
+		return
+	}
+	resp.Tasks = make([]cluster.DMTaskInfo, 0, len(tasks))
+	for _, task := range tasks {
+		resp.Tasks = append(resp.Tasks, convertTask(task))
+	}
+	return resp, int(count), nil
+}
+
+// DetailTask
+// @Description: get task with live status of sub tasks from dm-master, live status is absent if dm-master is unavailable
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) DetailTask(ctx context.Context, request cluster.DetailDMTaskReq) (resp cluster.DetailDMTaskResp, err error) {
+	task, err := models.GetDMReaderWriter().GetTask(ctx, request.ID)
+	if err != nil {
+		return
+	}
+	resp.DMTaskInfo = convertTask(task)
+	resp.SubTasks = make([]cluster.DMSubTaskStatus, 0)
+	if constants.DMTaskStatus(task.Status).IsFinal() {
+		return
+	}
+
+	status, statusErr := queryTaskStatus(ctx, task)
+	if statusErr != nil {
+		framework.LogWithContext(ctx).Warnf("query status of dm task %s failed, err = %s", task.Name, statusErr.Error())
+		return
+	}
+	for _, subTask := range status.Data {
+		subTaskStatus := cluster.DMSubTaskStatus{
+			Source:   subTask.SourceName,
+			Worker:   subTask.WorkerName,
+			Stage:    subTask.Stage,
+			Unit:     subTask.Unit,
+			ErrorMsg: subTask.ErrorMsg,
+		}
+		if subTask.SyncStatus != nil {
+			subTaskStatus.Synced = subTask.SyncStatus.Synced
+			subTaskStatus.Lag = subTask.SyncStatus.SecondsBehindMaster
+			subTaskStatus.MasterBinlog = subTask.SyncStatus.MasterBinlog
+			subTaskStatus.SyncerBinlog = subTask.SyncStatus.SyncerBinlog
+		}
+		resp.SubTasks = append(resp.SubTasks, subTaskStatus)
+	}
+	return
+}
+
+func queryTaskStatus(ctx context.Context, task *dm.DMTask) (dmapi.QueryTaskStatusResp, error) {
+	address, err := getDMMasterAddress(ctx, task.ClusterId)
+	if err != nil {
+		return dmapi.QueryTaskStatusResp{}, err
+	}
+	return dmapi.DMService.QueryTaskStatus(ctx, dmapi.QueryTaskStatusReq{DMMasterAddress: address, TaskName: task.Name})
+}
+
+// getDMMasterAddress
+// @Description: get address of a running dm-master of DM cluster
+func getDMMasterAddress(ctx context.Context, clusterID string) (string, error) {
+	clusterMeta, err := meta.Get(ctx, clusterID)
+	if err != nil {
+		return "", err
+	}
+	if clusterMeta.GetTiUPComponentType() != deployment.TiUPComponentTypeDM {
+		return "", errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster %s is not a DM cluster", clusterID)
+	}
+	addresses := clusterMeta.GetDMMasterAddresses()
+	if len(addresses) == 0 {
+		return "", errors.NewErrorf(errors.TIUNIMANAGER_DM_EXECUTE_ERROR, "no running dm-master in cluster %s", clusterID)
+	}
+	return addresses[0].ToString(), nil
+}
+
+// getTargetDatabase
+// @Description: get tidb address and root user of target cluster
+func getTargetDatabase(ctx context.Context, clusterID string) (targetDatabase, error) {
+	clusterMeta, err := meta.Get(ctx, clusterID)
+	if err != nil {
+		return targetDatabase{}, err
+	}
+	if clusterMeta.GetTiUPComponentType() != deployment.TiUPComponentTypeCluster {
+		return targetDatabase{}, errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_INVALID, "target cluster %s is not a TiDB cluster", clusterID)
+	}
+	addresses := clusterMeta.GetClusterConnectAddresses()
+	if len(addresses) == 0 {
+		return targetDatabase{}, errors.NewErrorf(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, "cluster %s has no tidb address", clusterID)
+	}
+	user, err := clusterMeta.GetDBUserNamePassword(ctx, constants.Root)
+	if err != nil {
+		return targetDatabase{}, err
+	}
+	return targetDatabase{
+		Host:     addresses[0].IP,
+		Port:     addresses[0].Port,
+		User:     user.Name,
+		Password: user.Password.Val,
+	}, nil
+}
+
+func marshalTaskRules(task *dm.DMTask, request cluster.CreateDMTaskReq) error {
+	sources, err := json.Marshal(request.Sources)
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_MARSHAL_ERROR, "", err)
+	}
+	bal, err := json.Marshal(request.BlockAllowList)
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_MARSHAL_ERROR, "", err)
+	}
+	routes := request.Routes
+	if routes == nil {
+		routes = make([]cluster.DMRouteRule, 0)
+	}
+	routesBytes, err := json.Marshal(routes)
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_MARSHAL_ERROR, "", err)
+	}
+	task.SourcesConfig = string(sources)
+	task.BlockAllowListConfig = string(bal)
+	task.RoutesConfig = string(routesBytes)
+	return nil
+}
+
+func parseSources(task *dm.DMTask) []string {
+	sources := make([]string, 0)
+	if len(task.SourcesConfig) > 0 {
+		_ = json.Unmarshal([]byte(task.SourcesConfig), &sources)
+	}
+	return sources
+}
+
+func containsStatus(statuses []constants.DMTaskStatus, status constants.DMTaskStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func convertSource(source *dm.DMSource) cluster.DMSourceInfo {
+	return cluster.DMSourceInfo{
+		Name:       source.Name,
+		Host:       source.Host,
+		Port:       source.Port,
+		User:       source.User,
+		EnableGTID: source.EnableGTID,
+		CreateTime: source.CreatedAt,
+	}
+}
+
+func convertTask(task *dm.DMTask) cluster.DMTaskInfo {
+	info := cluster.DMTaskInfo{
+		ID:              task.ID,
+		Name:            task.Name,
+		ClusterID:       task.ClusterId,
+		TargetClusterID: task.TargetClusterId,
+		TaskMode:        task.TaskMode,
+		Sources:         parseSources(task),
+		Routes:          make([]cluster.DMRouteRule, 0),
+		Status:          task.Status,
+		Unit:            task.Unit,
+		Synced:          task.Synced,
+		Lag:             task.Lag,
+		LastError:       task.LastError,
+		CheckedTime:     task.CheckedTime,
+		HandoffType:     task.HandoffType,
+		HandoffTarget:   task.HandoffTarget,
+		CreateTime:      task.CreatedAt,
+		UpdateTime:      task.UpdatedAt,
+	}
+	if len(task.BlockAllowListConfig) > 0 {
+		_ = json.Unmarshal([]byte(task.BlockAllowListConfig), &info.BlockAllowList)
+	}
+	if len(task.RoutesConfig) > 0 {
+		_ = json.Unmarshal([]byte(task.RoutesConfig), &info.Routes)
+	}
+	return info
+}
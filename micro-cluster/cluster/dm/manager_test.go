@@ -0,0 +1,354 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockutildm"
+	dmapi "github.com/pingcap/tiunimanager/util/api/dm"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func tenantContext() context.Context {
+	return framework.NewMicroContextWithKeyValuePairs(context.TODO(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: "tenant01"})
+}
+
+// mockClusters DM cluster dm01 and TiDB cluster tidb01, dm-master api is mocked
+func mockClusters(t *testing.T, ctrl *gomock.Controller) *mockutildm.MockDMApiService {
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	running := common.Entity{Status: string(constants.ClusterInstanceRunning)}
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "dm01").Return(
+		&management.Cluster{Entity: common.Entity{ID: "dm01"}, Type: string(constants.EMProductIDDataMigration)},
+		[]*management.ClusterInstance{
+			{Type: string(constants.ComponentIDDMMaster), Entity: running, HostIP: []string{"127.0.0.1"}, Ports: []int32{8261, 8291}},
+			{Type: string(constants.ComponentIDDMWorker), Entity: running, HostIP: []string{"127.0.0.1"}, Ports: []int32{8262}},
+		}, []*management.DBUser{}, nil).AnyTimes()
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "tidb01").Return(
+		&management.Cluster{Entity: common.Entity{ID: "tidb01"}, Type: string(constants.EMProductIDTiDB)},
+		[]*management.ClusterInstance{
+			{Type: string(constants.ComponentIDTiDB), Entity: running, HostIP: []string{"127.0.0.2"}, Ports: []int32{4000}},
+		}, []*management.DBUser{
+			{ClusterID: "tidb01", Name: "root", Password: common.PasswordInExpired{Val: "123455678"}, RoleType: string(constants.Root)},
+		}, nil).AnyTimes()
+
+	service := mockutildm.NewMockDMApiService(ctrl)
+	dmapi.DMService = service
+	t.Cleanup(func() {
+		dmapi.DMService = new(dmapi.DMApiServiceImpl)
+	})
+	return service
+}
+
+func createTask(t *testing.T, service *mockutildm.MockDMApiService, name string) string {
+	service.EXPECT().CreateSource(gomock.Any(), gomock.Any()).Return(nil).MaxTimes(1)
+	GetManager().RegisterSource(tenantContext(), cluster.RegisterDMSourceReq{ClusterID: "dm01", Name: "mysql-01", Host: "127.0.0.3", Port: 3306, User: "root"})
+
+	service.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(nil)
+	resp, err := GetManager().CreateTask(tenantContext(), cluster.CreateDMTaskReq{
+		ClusterID:       "dm01",
+		Name:            name,
+		TargetClusterID: "tidb01",
+		TaskMode:        string(constants.DMTaskModeAll),
+		Sources:         []string{"mysql-01"},
+	})
+	assert.NoError(t, err)
+	return resp.ID
+}
+
+func TestManager_Sources(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	service := mockClusters(t, ctrl)
+	ctx := tenantContext()
+
+	t.Run("register", func(t *testing.T) {
+		service.EXPECT().CreateSource(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req dmapi.CreateSourceReq) error {
+			assert.Equal(t, "127.0.0.1:8261", req.DMMasterAddress)
+			assert.Equal(t, "my_password", req.Source.Password)
+			assert.True(t, req.Source.Enable)
+			return nil
+		})
+		resp, err := GetManager().RegisterSource(ctx, cluster.RegisterDMSourceReq{
+			ClusterID: "dm01", Name: "source01", Host: "127.0.0.3", Port: 3306, User: "root", Password: "my_password",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "source01", resp.Name)
+	})
+	t.Run("register failed", func(t *testing.T) {
+		service.EXPECT().CreateSource(gomock.Any(), gomock.Any()).Return(errors.Error(errors.TIUNIMANAGER_DM_EXECUTE_ERROR))
+		_, err := GetManager().RegisterSource(ctx, cluster.RegisterDMSourceReq{ClusterID: "dm01", Name: "source02", Host: "127.0.0.4", Port: 3306})
+		assert.Error(t, err)
+
+		// record is removed if dm-master rejects the source
+		_, err = models.GetDMReaderWriter().GetSource(ctx, "dm01", "source02")
+		assert.Error(t, err)
+	})
+	t.Run("not dm cluster", func(t *testing.T) {
+		_, err := GetManager().RegisterSource(ctx, cluster.RegisterDMSourceReq{ClusterID: "tidb01", Name: "source03"})
+		assert.Error(t, err)
+	})
+	t.Run("query", func(t *testing.T) {
+		resp, err := GetManager().QuerySources(ctx, cluster.QueryDMSourcesReq{ClusterID: "dm01"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(resp.Sources))
+		assert.Equal(t, "127.0.0.3", resp.Sources[0].Host)
+	})
+	t.Run("delete", func(t *testing.T) {
+		service.EXPECT().DeleteSource(gomock.Any(), gomock.Any()).Return(nil)
+		_, err := GetManager().DeleteSource(ctx, cluster.DeleteDMSourceReq{ClusterID: "dm01", Name: "source01"})
+		assert.NoError(t, err)
+
+		_, err = GetManager().DeleteSource(ctx, cluster.DeleteDMSourceReq{ClusterID: "dm01", Name: "source01"})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_SOURCE_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+	t.Run("in use", func(t *testing.T) {
+		createTask(t, service, "sourceInUse")
+		_, err := GetManager().DeleteSource(ctx, cluster.DeleteDMSourceReq{ClusterID: "dm01", Name: "mysql-01"})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_SOURCE_IN_USE, err.(errors.EMError).GetCode())
+	})
+}
+
+func TestManager_Tasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	service := mockClusters(t, ctrl)
+	ctx := tenantContext()
+
+	var taskID string
+	t.Run("create", func(t *testing.T) {
+		service.EXPECT().CreateSource(gomock.Any(), gomock.Any()).Return(nil).MaxTimes(1)
+		GetManager().RegisterSource(ctx, cluster.RegisterDMSourceReq{ClusterID: "dm01", Name: "mysql-01", Host: "127.0.0.3", Port: 3306, User: "root"})
+
+		service.EXPECT().CreateTask(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req dmapi.CreateTaskReq) error {
+			config := taskConfig{}
+			assert.NoError(t, yaml.UnmarshalStrict([]byte(req.TaskConfigFile), &config))
+			assert.Equal(t, "task01", config.Name)
+			assert.Equal(t, targetDatabase{Host: "127.0.0.2", Port: 4000, User: "root", Password: "123455678"}, config.TargetDatabase)
+			assert.Equal(t, []string{"db1"}, config.BlockAllowList[balRuleName].DoDBs)
+			assert.Equal(t, "db_*", config.Routes["route-rule-0"].SchemaPattern)
+			assert.Equal(t, mysqlInstance{SourceID: "mysql-01", BlockAllowList: balRuleName, RouteRules: []string{"route-rule-0"}}, config.MySQLInstances[0])
+			return nil
+		})
+		resp, err := GetManager().CreateTask(ctx, cluster.CreateDMTaskReq{
+			ClusterID:       "dm01",
+			Name:            "task01",
+			TargetClusterID: "tidb01",
+			TaskMode:        string(constants.DMTaskModeAll),
+			Sources:         []string{"mysql-01"},
+			BlockAllowList:  cluster.DMBlockAllowList{DoDBs: []string{"db1"}},
+			Routes:          []cluster.DMRouteRule{{SchemaPattern: "db_*", TargetSchema: "db1"}},
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ID)
+		taskID = resp.ID
+	})
+	t.Run("create invalid", func(t *testing.T) {
+		request := cluster.CreateDMTaskReq{ClusterID: "dm01", Name: "task01", TargetClusterID: "tidb01", TaskMode: "all", Sources: []string{"mysql-01"}}
+		_, err := GetManager().CreateTask(ctx, request)
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_INVALID, err.(errors.EMError).GetCode())
+
+		request.Name = "task02"
+		request.Sources = []string{"unknown"}
+		_, err = GetManager().CreateTask(ctx, request)
+		assert.Equal(t, errors.TIUNIMANAGER_DM_SOURCE_NOT_FOUND, err.(errors.EMError).GetCode())
+
+		request.Sources = []string{"mysql-01"}
+		request.TargetClusterID = "dm01"
+		_, err = GetManager().CreateTask(ctx, request)
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_INVALID, err.(errors.EMError).GetCode())
+
+		request.TargetClusterID = "tidb01"
+		request.Routes = []cluster.DMRouteRule{{SchemaPattern: "db_*"}}
+		_, err = GetManager().CreateTask(ctx, request)
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("start pause stop", func(t *testing.T) {
+		service.EXPECT().StartTask(gomock.Any(), dmapi.TaskCmdReq{DMMasterAddress: "127.0.0.1:8261", TaskName: "task01"}).Return(nil).Times(2)
+		service.EXPECT().StopTask(gomock.Any(), gomock.Any()).Return(nil)
+		service.EXPECT().DeleteTask(gomock.Any(), gomock.Any()).Return(nil)
+
+		resp, err := GetManager().StartTask(ctx, cluster.DMTaskReq{ID: taskID})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusRunning), resp.Status)
+
+		_, err = GetManager().StartTask(ctx, cluster.DMTaskReq{ID: taskID})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, err.(errors.EMError).GetCode())
+
+		resp, err = GetManager().PauseTask(ctx, cluster.DMTaskReq{ID: taskID})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusPaused), resp.Status)
+
+		resp, err = GetManager().StartTask(ctx, cluster.DMTaskReq{ID: taskID})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusRunning), resp.Status)
+
+		resp, err = GetManager().StopTask(ctx, cluster.DMTaskReq{ID: taskID})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusStopped), resp.Status)
+
+		_, err = GetManager().PauseTask(ctx, cluster.DMTaskReq{ID: taskID})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, err.(errors.EMError).GetCode())
+	})
+	t.Run("query", func(t *testing.T) {
+		resp, total, err := GetManager().QueryTasks(ctx, cluster.QueryDMTasksReq{ClusterID: "dm01", Status: string(constants.DMTaskStatusStopped)})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, "task01", resp.Tasks[0].Name)
+		assert.Equal(t, []string{"mysql-01"}, resp.Tasks[0].Sources)
+		assert.Equal(t, []string{"db1"}, resp.Tasks[0].BlockAllowList.DoDBs)
+		assert.Equal(t, "db1", resp.Tasks[0].Routes[0].TargetSchema)
+	})
+}
+
+func TestManager_DetailTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	service := mockClusters(t, ctrl)
+	ctx := tenantContext()
+	taskID := createTask(t, service, "detailTask")
+
+	service.EXPECT().QueryTaskStatus(gomock.Any(), gomock.Any()).Return(dmapi.QueryTaskStatusResp{
+		Total: 1,
+		Data: []dmapi.SubTaskStatus{
+			{Name: "detailTask", SourceName: "mysql-01", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{Synced: true, SecondsBehindMaster: 2}},
+		},
+	}, nil)
+	resp, err := GetManager().DetailTask(ctx, cluster.DetailDMTaskReq{ID: taskID})
+	assert.NoError(t, err)
+	assert.Equal(t, "detailTask", resp.Name)
+	assert.Equal(t, 1, len(resp.SubTasks))
+	assert.Equal(t, int64(2), resp.SubTasks[0].Lag)
+	assert.True(t, resp.SubTasks[0].Synced)
+
+	service.EXPECT().QueryTaskStatus(gomock.Any(), gomock.Any()).Return(dmapi.QueryTaskStatusResp{}, errors.Error(errors.TIUNIMANAGER_DM_EXECUTE_ERROR))
+	resp, err = GetManager().DetailTask(ctx, cluster.DetailDMTaskReq{ID: taskID})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.SubTasks)
+}
+
+func TestManager_Handoff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	service := mockClusters(t, ctrl)
+	ctx := tenantContext()
+
+	caughtUp := dmapi.QueryTaskStatusResp{Total: 1, Data: []dmapi.SubTaskStatus{
+		{SourceName: "mysql-01", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{Synced: true}},
+	}}
+	originalCreateChangeFeedTask, originalCreateClusterRelation := createChangeFeedTask, createClusterRelation
+	defer func() {
+		createChangeFeedTask, createClusterRelation = originalCreateChangeFeedTask, originalCreateClusterRelation
+	}()
+
+	t.Run("not running", func(t *testing.T) {
+		taskID := createTask(t, service, "handoffCreated")
+		_, err := GetManager().Handoff(ctx, cluster.HandoffDMTaskReq{ID: taskID, Type: string(constants.DMHandoffChangeFeed)})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, err.(errors.EMError).GetCode())
+	})
+	t.Run("upstream writes not stopped", func(t *testing.T) {
+		taskID := createTask(t, service, "handoffWritable")
+		models.GetDMReaderWriter().UpdateStatus(ctx, taskID, constants.DMTaskStatusRunning)
+		_, err := GetManager().Handoff(ctx, cluster.HandoffDMTaskReq{ID: taskID, Type: string(constants.DMHandoffChangeFeed)})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY, err.(errors.EMError).GetCode())
+	})
+	t.Run("not ready", func(t *testing.T) {
+		taskID := createTask(t, service, "handoffLoading")
+		models.GetDMReaderWriter().UpdateStatus(ctx, taskID, constants.DMTaskStatusRunning)
+		service.EXPECT().QueryTaskStatus(gomock.Any(), gomock.Any()).Return(dmapi.QueryTaskStatusResp{Total: 1, Data: []dmapi.SubTaskStatus{
+			{SourceName: "mysql-01", Stage: "Running", Unit: "Load"},
+		}}, nil)
+		_, err := GetManager().Handoff(ctx, cluster.HandoffDMTaskReq{ID: taskID, Type: string(constants.DMHandoffChangeFeed), UpstreamWritesStopped: true})
+		assert.Equal(t, errors.TIUNIMANAGER_DM_HANDOFF_NOT_READY, err.(errors.EMError).GetCode())
+	})
+	t.Run("change feed", func(t *testing.T) {
+		taskID := createTask(t, service, "handoffChangeFeed")
+		models.GetDMReaderWriter().UpdateStatus(ctx, taskID, constants.DMTaskStatusRunning)
+		service.EXPECT().QueryTaskStatus(gomock.Any(), gomock.Any()).Return(caughtUp, nil)
+		service.EXPECT().DeleteTask(gomock.Any(), gomock.Any()).Return(nil)
+		createChangeFeedTask = func(ctx context.Context, request cluster.CreateChangeFeedTaskReq) (cluster.CreateChangeFeedTaskResp, error) {
+			assert.Equal(t, "tidb01", request.ClusterID)
+			assert.Equal(t, "mysql", request.DownstreamType)
+			return cluster.CreateChangeFeedTaskResp{ID: "changefeed01"}, nil
+		}
+
+		resp, err := GetManager().Handoff(ctx, cluster.HandoffDMTaskReq{
+			ID:                    taskID,
+			Type:                  string(constants.DMHandoffChangeFeed),
+			UpstreamWritesStopped: true,
+			ChangeFeed:            &cluster.DMHandoffChangeFeed{Name: "reverse", DownstreamType: "mysql"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusHandedOff), resp.Status)
+		assert.Equal(t, "changefeed01", resp.HandoffTarget)
+
+		task, err := models.GetDMReaderWriter().GetTask(ctx, taskID)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusHandedOff), task.Status)
+		assert.Equal(t, string(constants.DMHandoffChangeFeed), task.HandoffType)
+		assert.Equal(t, "changefeed01", task.HandoffTarget)
+	})
+	t.Run("switchover", func(t *testing.T) {
+		taskID := createTask(t, service, "handoffSwitchover")
+		models.GetDMReaderWriter().UpdateStatus(ctx, taskID, constants.DMTaskStatusRunning)
+		service.EXPECT().QueryTaskStatus(gomock.Any(), gomock.Any()).Return(caughtUp, nil).Times(2)
+		service.EXPECT().DeleteTask(gomock.Any(), gomock.Any()).Return(errors.Error(errors.TIUNIMANAGER_DM_EXECUTE_ERROR))
+		createClusterRelation = func(ctx context.Context, request cluster.CreateClusterRelationReq) (cluster.CreateClusterRelationResp, error) {
+			assert.Equal(t, "tidb01", request.SourceClusterID)
+			assert.Equal(t, string(constants.ClusterRelationStandBy), request.RelationType)
+			assert.Equal(t, "backup01", request.BackupID)
+			return cluster.CreateClusterRelationResp{}, nil
+		}
+
+		_, err := GetManager().Handoff(ctx, cluster.HandoffDMTaskReq{ID: taskID, Type: string(constants.DMHandoffSwitchover), UpstreamWritesStopped: true,
+			StandbyClusterID: "standby01"})
+		assert.Equal(t, errors.TIUNIMANAGER_PARAMETER_INVALID, err.(errors.EMError).GetCode())
+
+		// handoff is recorded even if task fails to stop
+		_, err = GetManager().Handoff(ctx, cluster.HandoffDMTaskReq{ID: taskID, Type: string(constants.DMHandoffSwitchover), UpstreamWritesStopped: true,
+			StandbyClusterID: "standby01", BackupID: "backup01"})
+		assert.Error(t, err)
+		task, err := models.GetDMReaderWriter().GetTask(ctx, taskID)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusRunning), task.Status)
+		assert.Equal(t, "standby01", task.HandoffTarget)
+	})
+}
+
+func TestCheckHandoffReady(t *testing.T) {
+	subTask := func(unit string, stage string, synced bool, lag int64, errorMsg string) dmapi.SubTaskStatus {
+		return dmapi.SubTaskStatus{SourceName: "mysql-01", Unit: unit, Stage: stage, ErrorMsg: errorMsg, SyncStatus: &dmapi.SyncStatus{Synced: synced, SecondsBehindMaster: lag}}
+	}
+	assert.NoError(t, checkHandoffReady(dmapi.QueryTaskStatusResp{Data: []dmapi.SubTaskStatus{subTask("Sync", "Running", true, 0, "")}}))
+	assert.Error(t, checkHandoffReady(dmapi.QueryTaskStatusResp{}))
+	assert.Error(t, checkHandoffReady(dmapi.QueryTaskStatusResp{Data: []dmapi.SubTaskStatus{subTask("Sync", "Running", true, 1, "")}}))
+	assert.Error(t, checkHandoffReady(dmapi.QueryTaskStatusResp{Data: []dmapi.SubTaskStatus{subTask("Sync", "Running", false, 0, "")}}))
+	assert.Error(t, checkHandoffReady(dmapi.QueryTaskStatusResp{Data: []dmapi.SubTaskStatus{subTask("Sync", "Paused", true, 0, "")}}))
+	assert.Error(t, checkHandoffReady(dmapi.QueryTaskStatusResp{Data: []dmapi.SubTaskStatus{subTask("Sync", "Running", true, 0, "error")}}))
+	assert.Error(t, checkHandoffReady(dmapi.QueryTaskStatusResp{Data: []dmapi.SubTaskStatus{{SourceName: "mysql-01", Unit: "Load", Stage: "Running"}}}))
+}
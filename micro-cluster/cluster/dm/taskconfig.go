@@ -0,0 +1,135 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"fmt"
+
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"gopkg.in/yaml.v2"
+)
+
+const balRuleName = "bal-rule"
+
+// taskConfig task config file of DM, which is converted to task of OpenAPI by dm-master
+type taskConfig struct {
+	Name           string               `yaml:"name"`
+	TaskMode       string               `yaml:"task-mode"`
+	TargetDatabase targetDatabase       `yaml:"target-database"`
+	BlockAllowList map[string]balRule   `yaml:"block-allow-list,omitempty"`
+	Routes         map[string]routeRule `yaml:"routes,omitempty"`
+	MySQLInstances []mysqlInstance      `yaml:"mysql-instances"`
+}
+
+type targetDatabase struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+type tableRule struct {
+	DBName  string `yaml:"db-name"`
+	TblName string `yaml:"tbl-name"`
+}
+
+type balRule struct {
+	DoDBs        []string    `yaml:"do-dbs,omitempty"`
+	DoTables     []tableRule `yaml:"do-tables,omitempty"`
+	IgnoreDBs    []string    `yaml:"ignore-dbs,omitempty"`
+	IgnoreTables []tableRule `yaml:"ignore-tables,omitempty"`
+}
+
+type routeRule struct {
+	SchemaPattern string `yaml:"schema-pattern"`
+	TablePattern  string `yaml:"table-pattern,omitempty"`
+	TargetSchema  string `yaml:"target-schema"`
+	TargetTable   string `yaml:"target-table,omitempty"`
+}
+
+type mysqlInstance struct {
+	SourceID       string   `yaml:"source-id"`
+	BlockAllowList string   `yaml:"block-allow-list,omitempty"`
+	RouteRules     []string `yaml:"route-rules,omitempty"`
+}
+
+func convertTableRules(rules []cluster.DMTableRule) []tableRule {
+	converted := make([]tableRule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, tableRule{DBName: rule.DBName, TblName: rule.TblName})
+	}
+	return converted
+}
+
+// buildTaskConfig
+// @Description: generate task config file of DM, every source shares the same block allow list and route rules
+// @Parameter request
+// @Parameter target
+// @return string task config file in yaml
+// @return error
+func buildTaskConfig(request cluster.CreateDMTaskReq, target targetDatabase) (string, error) {
+	config := taskConfig{
+		Name:           request.Name,
+		TaskMode:       request.TaskMode,
+		TargetDatabase: target,
+		MySQLInstances: make([]mysqlInstance, 0, len(request.Sources)),
+	}
+
+	bal := request.BlockAllowList
+	if len(bal.DoDBs) > 0 || len(bal.DoTables) > 0 || len(bal.IgnoreDBs) > 0 || len(bal.IgnoreTables) > 0 {
+		config.BlockAllowList = map[string]balRule{
+			balRuleName: {
+				DoDBs:        bal.DoDBs,
+				DoTables:     convertTableRules(bal.DoTables),
+				IgnoreDBs:    bal.IgnoreDBs,
+				IgnoreTables: convertTableRules(bal.IgnoreTables),
+			},
+		}
+	}
+
+	routeNames := make([]string, 0, len(request.Routes))
+	if len(request.Routes) > 0 {
+		config.Routes = make(map[string]routeRule)
+	}
+	for i, route := range request.Routes {
+		if len(route.SchemaPattern) == 0 || len(route.TargetSchema) == 0 {
+			return "", errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_INVALID, "schema pattern and target schema of route %d are required", i)
+		}
+		name := fmt.Sprintf("route-rule-%d", i)
+		routeNames = append(routeNames, name)
+		config.Routes[name] = routeRule{
+			SchemaPattern: route.SchemaPattern,
+			TablePattern:  route.TablePattern,
+			TargetSchema:  route.TargetSchema,
+			TargetTable:   route.TargetTable,
+		}
+	}
+
+	for _, source := range request.Sources {
+		instance := mysqlInstance{SourceID: source, RouteRules: routeNames}
+		if config.BlockAllowList != nil {
+			instance.BlockAllowList = balRuleName
+		}
+		config.MySQLInstances = append(config.MySQLInstances, instance)
+	}
+
+	bytes, err := yaml.Marshal(config)
+	if err != nil {
+		return "", errors.WrapError(errors.TIUNIMANAGER_MARSHAL_ERROR, "", err)
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,145 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/dm"
+	dmapi "github.com/pingcap/tiunimanager/util/api/dm"
+	"github.com/robfig/cron"
+)
+
+type dmTaskWatcher struct {
+	JobCron *cron.Cron
+	JobSpec string
+}
+
+type dmTaskWatchHandler struct {
+	running int32
+}
+
+var watcher *dmTaskWatcher
+var watcherOnce sync.Once
+
+// StartDMTaskWatcher
+// @Description: refresh status, unit and lag of unfinished data migration tasks in background
+func StartDMTaskWatcher() {
+	watcherOnce.Do(func() {
+		watcher = &dmTaskWatcher{
+			JobCron: cron.New(),
+			JobSpec: "*/30 * * * * *", // every 30 seconds
+		}
+		err := watcher.JobCron.AddJob(watcher.JobSpec, &dmTaskWatchHandler{})
+		if err != nil {
+			framework.Log().Fatalf("add dm task watcher cron job failed, %s", err.Error())
+			return
+		}
+		go watcher.start()
+	})
+}
+
+func (w *dmTaskWatcher) start() {
+	time.Sleep(5 * time.Second) //wait db client ready
+	w.JobCron.Start()
+	defer w.JobCron.Stop()
+
+	select {}
+}
+
+func (handler *dmTaskWatchHandler) Run() {
+	if !atomic.CompareAndSwapInt32(&handler.running, 0, 1) {
+		framework.Log().Warnf("last round of dm task watcher is still running, skip this round")
+		return
+	}
+	defer atomic.StoreInt32(&handler.running, 0)
+
+	watchAll(context.TODO(), time.Now())
+}
+
+// watchAll
+// @Description: refresh every running task, failure of one task does not stop the others
+func watchAll(ctx context.Context, now time.Time) {
+	tasks, _, err := models.GetDMReaderWriter().QueryTasks(ctx, "",
+		[]constants.DMTaskStatus{constants.DMTaskStatusRunning, constants.DMTaskStatusError}, 0, 0)
+	if err != nil {
+		framework.Log().Errorf("query dm tasks failed, %s", err.Error())
+		return
+	}
+	for _, task := range tasks {
+		status, err := queryTaskStatus(ctx, task)
+		if err != nil {
+			framework.Log().Warnf("query status of dm task %s failed, %s", task.Name, err.Error())
+			continue
+		}
+		refreshProgress(task, status, now)
+		if err = models.GetDMReaderWriter().UpdateProgress(ctx, task); err != nil {
+			framework.Log().Errorf("update progress of dm task %s failed, %s", task.Name, err.Error())
+		}
+	}
+}
+
+// unitOrder sub task proceeds from dump to load, and then to sync
+var unitOrder = map[string]int{
+	"Check": 0,
+	"Dump":  1,
+	"Load":  2,
+	"Sync":  3,
+}
+
+// refreshProgress
+// @Description: summarize sub tasks into task, unit of task is the slowest unit and lag of task is the max lag.
+// Task is Error if any sub task reports error or is paused by error, and it recovers if all sub tasks recover
+func refreshProgress(task *dm.DMTask, status dmapi.QueryTaskStatusResp, now time.Time) {
+	task.CheckedTime = now
+	task.Unit = ""
+	task.Stage = ""
+	task.Synced = len(status.Data) > 0
+	task.Lag = 0
+	task.LastError = ""
+
+	for i, subTask := range status.Data {
+		if i == 0 || unitOrder[subTask.Unit] < unitOrder[task.Unit] {
+			task.Unit = subTask.Unit
+		}
+		if i == 0 || subTask.Stage == constants.DMStagePaused {
+			task.Stage = subTask.Stage
+		}
+		if len(subTask.ErrorMsg) > 0 && len(task.LastError) == 0 {
+			task.LastError = subTask.SourceName + ": " + subTask.ErrorMsg
+		}
+		if subTask.SyncStatus == nil {
+			task.Synced = false
+			continue
+		}
+		task.Synced = task.Synced && subTask.SyncStatus.Synced
+		if subTask.SyncStatus.SecondsBehindMaster > task.Lag {
+			task.Lag = subTask.SyncStatus.SecondsBehindMaster
+		}
+	}
+
+	if len(task.LastError) > 0 || task.Stage == constants.DMStagePaused {
+		task.Status = string(constants.DMTaskStatusError)
+	} else {
+		task.Status = string(constants.DMTaskStatusRunning)
+	}
+}
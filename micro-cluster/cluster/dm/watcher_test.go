@@ -0,0 +1,91 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/dm"
+	dmapi "github.com/pingcap/tiunimanager/util/api/dm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshProgress(t *testing.T) {
+	now := time.Now()
+	t.Run("running", func(t *testing.T) {
+		task := &dm.DMTask{LastError: "old error"}
+		refreshProgress(task, dmapi.QueryTaskStatusResp{Total: 2, Data: []dmapi.SubTaskStatus{
+			{SourceName: "mysql-01", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{Synced: true, SecondsBehindMaster: 3}},
+			{SourceName: "mysql-02", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{Synced: true, SecondsBehindMaster: 7}},
+		}}, now)
+		assert.Equal(t, string(constants.DMTaskStatusRunning), task.Status)
+		assert.Equal(t, "Running", task.Stage)
+		assert.Equal(t, "Sync", task.Unit)
+		assert.True(t, task.Synced)
+		assert.Equal(t, int64(7), task.Lag)
+		assert.Empty(t, task.LastError)
+		assert.Equal(t, now, task.CheckedTime)
+	})
+	t.Run("slowest unit", func(t *testing.T) {
+		task := &dm.DMTask{}
+		refreshProgress(task, dmapi.QueryTaskStatusResp{Total: 2, Data: []dmapi.SubTaskStatus{
+			{SourceName: "mysql-01", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{Synced: true}},
+			{SourceName: "mysql-02", Stage: "Running", Unit: "Load"},
+		}}, now)
+		assert.Equal(t, string(constants.DMTaskStatusRunning), task.Status)
+		assert.Equal(t, "Load", task.Unit)
+		assert.False(t, task.Synced)
+	})
+	t.Run("error", func(t *testing.T) {
+		task := &dm.DMTask{}
+		refreshProgress(task, dmapi.QueryTaskStatusResp{Total: 2, Data: []dmapi.SubTaskStatus{
+			{SourceName: "mysql-01", Stage: "Paused", Unit: "Sync", ErrorMsg: "binlog purged", SyncStatus: &dmapi.SyncStatus{}},
+			{SourceName: "mysql-02", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{}},
+		}}, now)
+		assert.Equal(t, string(constants.DMTaskStatusError), task.Status)
+		assert.Equal(t, "Paused", task.Stage)
+		assert.Equal(t, "mysql-01: binlog purged", task.LastError)
+	})
+}
+
+func TestWatchAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	service := mockClusters(t, ctrl)
+	ctx := tenantContext()
+
+	taskID := createTask(t, service, "watchedTask")
+	models.GetDMReaderWriter().UpdateStatus(ctx, taskID, constants.DMTaskStatusRunning)
+	service.EXPECT().QueryTaskStatus(gomock.Any(), dmapi.QueryTaskStatusReq{DMMasterAddress: "127.0.0.1:8261", TaskName: "watchedTask"}).Return(dmapi.QueryTaskStatusResp{
+		Total: 1,
+		Data: []dmapi.SubTaskStatus{
+			{SourceName: "mysql-01", Stage: "Running", Unit: "Sync", SyncStatus: &dmapi.SyncStatus{Synced: true, SecondsBehindMaster: 4}},
+		},
+	}, nil)
+	service.EXPECT().QueryTaskStatus(gomock.Any(), gomock.Any()).Return(dmapi.QueryTaskStatusResp{}, nil).AnyTimes()
+
+	watchAll(ctx, time.Now())
+	task, err := models.GetDMReaderWriter().GetTask(ctx, taskID)
+	assert.NoError(t, err)
+	assert.Equal(t, string(constants.DMTaskStatusRunning), task.Status)
+	assert.Equal(t, "Sync", task.Unit)
+	assert.Equal(t, int64(4), task.Lag)
+	assert.True(t, task.Synced)
+}
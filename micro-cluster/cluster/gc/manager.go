@@ -0,0 +1,346 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/gc"
+	"github.com/pingcap/tiunimanager/util/api/pd"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
+)
+
+var manager *Manager
+var once sync.Once
+
+// replaced in unit tests, they connect to cluster
+var queryGCLifeTime = utilsql.QueryGCLifeTime
+var updateGCLifeTime = utilsql.UpdateGCLifeTime
+var queryGCSafePoint = utilsql.QueryGCSafePoint
+
+type Manager struct {
+	// serializes changes of holds and tidb_gc_life_time
+	lock sync.Mutex
+}
+
+func GetManager() *Manager {
+	once.Do(func() {
+		if manager == nil {
+			manager = &Manager{}
+		}
+	})
+	return manager
+}
+
+// pdServiceGCSafePoints response of pd api /pd/api/v1/gc/safepoint
+type pdServiceGCSafePoints struct {
+	ServiceGCSafePoints []struct {
+		ServiceID string `json:"service_id"`
+		ExpiredAt int64  `json:"expired_at"`
+		SafePoint uint64 `json:"safe_point"`
+	} `json:"service_gc_safe_points"`
+}
+
+// Query
+// @Description: query gc safepoint, service safepoints, tidb_gc_life_time and gc holds of cluster
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) Query(ctx context.Context, request cluster.QueryClusterGCReq) (resp cluster.QueryClusterGCResp, err error) {
+	clusterMeta, err := meta.Get(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+	connParam, err := getConnParam(ctx, clusterMeta)
+	if err != nil {
+		return
+	}
+
+	resp.ClusterID = request.ClusterID
+	if resp.GCLifeTime, err = queryGCLifeTime(ctx, connParam); err != nil {
+		return
+	}
+	if resp.SafePointTime, err = queryGCSafePoint(ctx, connParam); err != nil {
+		return
+	}
+	if resp.ServiceSafePoints, err = queryServiceSafePoints(ctx, clusterMeta); err != nil {
+		return
+	}
+
+	baselines, err := models.GetGCReaderWriter().QueryBaselines(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+	if len(baselines) > 0 {
+		resp.BaselineGCLifeTime = baselines[0].GCLifeTime
+	}
+
+	holds, err := models.GetGCReaderWriter().QueryHolds(ctx, request.ClusterID)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	resp.Holds = make([]cluster.GCHoldInfo, 0, len(holds))
+	for _, hold := range holds {
+		resp.Holds = append(resp.Holds, convertHold(hold, now))
+	}
+	return
+}
+
+// CreateHold
+// @Description: take or renew a gc hold of cluster by api
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) CreateHold(ctx context.Context, request cluster.CreateGCHoldReq) (resp cluster.CreateGCHoldResp, err error) {
+	ttl, err := time.ParseDuration(request.TTL)
+	if err != nil {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_GC_HOLD_INVALID, "invalid ttl %s", request.TTL)
+		return
+	}
+	hold, err := p.hold(ctx, request.ClusterID, request.Name, request.Holder, request.GCLifeTime, ttl)
+	if err != nil {
+		return
+	}
+	resp.GCHoldInfo = convertHold(hold, time.Now())
+	return
+}
+
+// ReleaseHold
+// @Description: release a gc hold of cluster by api
+// @Receiver p
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err
+func (p *Manager) ReleaseHold(ctx context.Context, request cluster.ReleaseGCHoldReq) (resp cluster.ReleaseGCHoldResp, err error) {
+	err = p.Release(ctx, request.ClusterID, request.Name)
+	return
+}
+
+func (p *Manager) Hold(ctx context.Context, clusterID string, name string, holder string, gcLifeTime string, ttl time.Duration) (baseline string, err error) {
+	if _, err = p.hold(ctx, clusterID, name, holder, gcLifeTime, ttl); err != nil {
+		return
+	}
+	baselines, err := models.GetGCReaderWriter().QueryBaselines(ctx, clusterID)
+	if err != nil {
+		return
+	}
+	if len(baselines) > 0 {
+		baseline = baselines[0].GCLifeTime
+	}
+	return
+}
+
+func (p *Manager) Release(ctx context.Context, clusterID string, name string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := models.GetGCReaderWriter().DeleteHold(ctx, clusterID, name); err != nil {
+		return err
+	}
+	framework.LogWithContext(ctx).Infof("gc hold %s of cluster %s is released", name, clusterID)
+	return p.reconcile(ctx, clusterID, time.Now())
+}
+
+func (p *Manager) hold(ctx context.Context, clusterID string, name string, holder string, gcLifeTime string, ttl time.Duration) (*gc.GCHold, error) {
+	if lifeTime, err := time.ParseDuration(gcLifeTime); err != nil || lifeTime <= 0 {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_GC_HOLD_INVALID, "invalid gc life time %s", gcLifeTime)
+	}
+	if ttl <= 0 {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_GC_HOLD_INVALID, "invalid ttl %s", ttl.String())
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	clusterMeta, err := meta.Get(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	connParam, err := getConnParam(ctx, clusterMeta)
+	if err != nil {
+		return nil, err
+	}
+	current, err := queryGCLifeTime(ctx, connParam)
+	if err != nil {
+		return nil, err
+	}
+	// the first hold records the value to be restored
+	if _, err = models.GetGCReaderWriter().CreateBaselineIfAbsent(ctx, clusterID, current); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	hold, err := models.GetGCReaderWriter().SaveHold(ctx, &gc.GCHold{
+		ClusterId:  clusterID,
+		Name:       name,
+		Holder:     holder,
+		GCLifeTime: gcLifeTime,
+		ExpireTime: now.Add(ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	framework.LogWithContext(ctx).Infof("gc hold %s of cluster %s is taken by %s, gc life time %s, expire time %s",
+		name, clusterID, holder, gcLifeTime, hold.ExpireTime.String())
+
+	return hold, p.reconcile(ctx, clusterID, now)
+}
+
+// reconcile
+// @Description: remove expired holds of cluster, then keep tidb_gc_life_time no less than every active hold,
+// or restore the baseline if there is no active hold
+// @Receiver p
+// @Parameter ctx
+// @Parameter clusterID
+// @Parameter now
+// @return error
+func (p *Manager) reconcile(ctx context.Context, clusterID string, now time.Time) error {
+	rw := models.GetGCReaderWriter()
+	holds, err := rw.QueryHolds(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+	baselines, err := rw.QueryBaselines(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+
+	var target time.Duration
+	targetValue := ""
+	for _, hold := range holds {
+		if hold.Expired(now) {
+			framework.LogWithContext(ctx).Warnf("gc hold %s of cluster %s taken by %s expired at %s",
+				hold.Name, clusterID, hold.Holder, hold.ExpireTime.String())
+			if err = rw.DeleteHold(ctx, clusterID, hold.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if lifeTime, parseErr := time.ParseDuration(hold.GCLifeTime); parseErr == nil && lifeTime > target {
+			target = lifeTime
+			targetValue = hold.GCLifeTime
+		}
+	}
+	if len(targetValue) == 0 && len(baselines) == 0 {
+		return nil
+	}
+
+	clusterMeta, err := meta.Get(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+	connParam, err := getConnParam(ctx, clusterMeta)
+	if err != nil {
+		return err
+	}
+
+	if len(targetValue) > 0 {
+		current, err := queryGCLifeTime(ctx, connParam)
+		if err != nil {
+			return err
+		}
+		if lifeTime, parseErr := time.ParseDuration(current); parseErr == nil && lifeTime >= target {
+			return nil
+		}
+		framework.LogWithContext(ctx).Infof("extend tidb_gc_life_time of cluster %s from %s to %s", clusterID, current, targetValue)
+		return updateGCLifeTime(ctx, connParam, targetValue)
+	}
+
+	baseline := baselines[0]
+	framework.LogWithContext(ctx).Infof("all gc holds of cluster %s are released, restore tidb_gc_life_time to %s", clusterID, baseline.GCLifeTime)
+	if err = updateGCLifeTime(ctx, connParam, baseline.GCLifeTime); err != nil {
+		return err
+	}
+	return rw.DeleteBaseline(ctx, clusterID)
+}
+
+// queryServiceSafePoints
+// @Description: query service safepoints registered in PD by TiCDC, BR and so on
+func queryServiceSafePoints(ctx context.Context, clusterMeta *meta.ClusterMeta) ([]cluster.ServiceGCSafePoint, error) {
+	pdAddress := clusterMeta.GetPDClientAddresses()
+	if len(pdAddress) == 0 {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_PD_NOT_FOUND_ERROR, "cluster %s has no pd address", clusterMeta.Cluster.ID)
+	}
+	content, err := pd.ApiService.ShowGCSafePoint(ctx, cluster.ApiShowConfigReq{
+		InstanceHost: pdAddress[0].IP,
+		InstancePort: uint(pdAddress[0].Port),
+		Headers:      map[string]string{},
+	})
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_UNRECOGNIZED_ERROR, "query service gc safepoints failed", err)
+	}
+	result := &pdServiceGCSafePoints{}
+	if err = json.Unmarshal(content, result); err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, "unmarshal service gc safepoints failed", err)
+	}
+
+	safePoints := make([]cluster.ServiceGCSafePoint, 0, len(result.ServiceGCSafePoints))
+	for _, s := range result.ServiceGCSafePoints {
+		safePointTime, _ := tso.ParseTS(s.SafePoint)
+		safePoints = append(safePoints, cluster.ServiceGCSafePoint{
+			ServiceID:     s.ServiceID,
+			SafePoint:     s.SafePoint,
+			SafePointTime: safePointTime,
+			ExpiredAt:     time.Unix(s.ExpiredAt, 0),
+		})
+	}
+	return safePoints, nil
+}
+
+func getConnParam(ctx context.Context, clusterMeta *meta.ClusterMeta) (utilsql.DbConnParam, error) {
+	address := clusterMeta.GetClusterConnectAddresses()
+	if len(address) == 0 {
+		return utilsql.DbConnParam{}, errors.NewErrorf(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, "cluster %s has no tidb address", clusterMeta.Cluster.ID)
+	}
+	user, err := clusterMeta.GetDBUserNamePassword(ctx, constants.Root)
+	if err != nil {
+		return utilsql.DbConnParam{}, err
+	}
+	return utilsql.DbConnParam{
+		Username: user.Name,
+		Password: user.Password.Val,
+		IP:       address[0].IP,
+		Port:     strconv.Itoa(address[0].Port),
+	}, nil
+}
+
+func convertHold(hold *gc.GCHold, now time.Time) cluster.GCHoldInfo {
+	return cluster.GCHoldInfo{
+		Name:       hold.Name,
+		Holder:     hold.Holder,
+		GCLifeTime: hold.GCLifeTime,
+		ExpireTime: hold.ExpireTime,
+		CreateTime: hold.CreatedAt,
+		Expired:    hold.Expired(now),
+	}
+}
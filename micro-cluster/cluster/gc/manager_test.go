@@ -0,0 +1,190 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/util/tso"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockutilpd"
+	"github.com/pingcap/tiunimanager/util/api/pd"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCluster replaces tidb_gc_life_time of clusters in memory
+type fakeCluster struct {
+	gcLifeTime string
+	updates    []string
+}
+
+func mockCluster(t *testing.T, ctrl *gomock.Controller, clusterID string, gcLifeTime string) *fakeCluster {
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), clusterID).Return(&management.Cluster{Entity: common.Entity{ID: clusterID}}, []*management.ClusterInstance{
+		{Type: "TiDB", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{4000}},
+		{Type: "PD", Entity: common.Entity{Status: string(constants.ClusterInstanceRunning)}, HostIP: []string{"127.0.0.1"}, Ports: []int32{2379}},
+	}, []*management.DBUser{
+		{ClusterID: clusterID, Name: "root", Password: common.PasswordInExpired{Val: "123455678"}, RoleType: string(constants.Root)},
+	}, nil).AnyTimes()
+
+	fake := &fakeCluster{gcLifeTime: gcLifeTime}
+	queryGCLifeTime = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (string, error) {
+		return fake.gcLifeTime, nil
+	}
+	updateGCLifeTime = func(ctx context.Context, dbConnParam utilsql.DbConnParam, gcLifeTime string) error {
+		fake.gcLifeTime = gcLifeTime
+		fake.updates = append(fake.updates, gcLifeTime)
+		return nil
+	}
+	t.Cleanup(func() {
+		queryGCLifeTime = utilsql.QueryGCLifeTime
+		updateGCLifeTime = utilsql.UpdateGCLifeTime
+	})
+	return fake
+}
+
+func TestManager_HoldAndRelease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fake := mockCluster(t, ctrl, "cluster01", "10m0s")
+	ctx := context.TODO()
+
+	baseline, err := GetManager().Hold(ctx, "cluster01", "clone-a", "flow01", "720h", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "10m0s", baseline)
+	assert.Equal(t, "720h", fake.gcLifeTime)
+
+	// a shorter hold does not shorten gc life time, baseline is kept
+	baseline, err = GetManager().Hold(ctx, "cluster01", "backup-b", "flow02", "24h", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "10m0s", baseline)
+	assert.Equal(t, []string{"720h"}, fake.updates)
+
+	assert.NoError(t, GetManager().Release(ctx, "cluster01", "clone-a"))
+	assert.Equal(t, "720h", fake.gcLifeTime)
+
+	assert.NoError(t, GetManager().Release(ctx, "cluster01", "backup-b"))
+	assert.Equal(t, "10m0s", fake.gcLifeTime)
+	baselines, err := models.GetGCReaderWriter().QueryBaselines(ctx, "cluster01")
+	assert.NoError(t, err)
+	assert.Empty(t, baselines)
+
+	err = GetManager().Release(ctx, "cluster01", "backup-b")
+	assert.Error(t, err)
+	assert.Equal(t, errors.TIUNIMANAGER_GC_HOLD_NOT_FOUND, err.(errors.EMError).GetCode())
+}
+
+func TestManager_CreateHold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockCluster(t, ctrl, "cluster02", "10m0s")
+	ctx := context.TODO()
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		_, err := GetManager().CreateHold(ctx, cluster.CreateGCHoldReq{ClusterID: "cluster02", Name: "h", GCLifeTime: "24h", TTL: "one day"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_GC_HOLD_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("invalid gc life time", func(t *testing.T) {
+		_, err := GetManager().CreateHold(ctx, cluster.CreateGCHoldReq{ClusterID: "cluster02", Name: "h", GCLifeTime: "-1h", TTL: "1h"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_GC_HOLD_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("normal", func(t *testing.T) {
+		resp, err := GetManager().CreateHold(ctx, cluster.CreateGCHoldReq{ClusterID: "cluster02", Name: "h", Holder: "admin", GCLifeTime: "24h", TTL: "1h"})
+		assert.NoError(t, err)
+		assert.Equal(t, "h", resp.Name)
+		assert.Equal(t, "admin", resp.Holder)
+		assert.False(t, resp.Expired)
+
+		_, err = GetManager().ReleaseHold(ctx, cluster.ReleaseGCHoldReq{ClusterID: "cluster02", Name: "h"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestManager_Query(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockCluster(t, ctrl, "cluster03", "10m0s")
+	ctx := context.TODO()
+
+	safePoint := time.Now().Add(-10 * time.Minute).Truncate(time.Millisecond)
+	queryGCSafePoint = func(ctx context.Context, dbConnParam utilsql.DbConnParam) (time.Time, error) {
+		return safePoint, nil
+	}
+	defer func() { queryGCSafePoint = utilsql.QueryGCSafePoint }()
+
+	cdcSafePoint := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	pdService := mockutilpd.NewMockPDApiService(ctrl)
+	pd.ApiService = pdService
+	pdService.EXPECT().ShowGCSafePoint(gomock.Any(), gomock.Any()).Return([]byte(
+		`{"service_gc_safe_points":[{"service_id":"ticdc","expired_at":1650000000,"safe_point":`+
+			strconv.FormatUint(tso.GenerateTSO(cdcSafePoint, 0), 10)+`}],"gc_safe_point":0}`), nil)
+
+	_, err := GetManager().Hold(ctx, "cluster03", "clone-c", "flow03", "720h", time.Hour)
+	assert.NoError(t, err)
+	defer GetManager().Release(ctx, "cluster03", "clone-c")
+
+	resp, err := GetManager().Query(ctx, cluster.QueryClusterGCReq{ClusterID: "cluster03"})
+	assert.NoError(t, err)
+	assert.Equal(t, "720h", resp.GCLifeTime)
+	assert.Equal(t, "10m0s", resp.BaselineGCLifeTime)
+	assert.True(t, safePoint.Equal(resp.SafePointTime))
+	assert.Len(t, resp.ServiceSafePoints, 1)
+	assert.Equal(t, "ticdc", resp.ServiceSafePoints[0].ServiceID)
+	assert.True(t, cdcSafePoint.Equal(resp.ServiceSafePoints[0].SafePointTime))
+	assert.Equal(t, int64(1650000000), resp.ServiceSafePoints[0].ExpiredAt.Unix())
+	assert.Len(t, resp.Holds, 1)
+	assert.Equal(t, "clone-c", resp.Holds[0].Name)
+}
+
+func TestReconcileAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fake := mockCluster(t, ctrl, "cluster04", "10m0s")
+	ctx := context.TODO()
+
+	_, err := GetManager().Hold(ctx, "cluster04", "dead-flow", "flow04", "720h", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "720h", fake.gcLifeTime)
+
+	// gc life time is changed manually while holding
+	fake.gcLifeTime = "1h"
+	reconcileAll(ctx, time.Now())
+	assert.Equal(t, "720h", fake.gcLifeTime)
+
+	// the workflow never releases the hold
+	reconcileAll(ctx, time.Now().Add(2*time.Hour))
+	assert.Equal(t, "10m0s", fake.gcLifeTime)
+	holds, err := models.GetGCReaderWriter().QueryHolds(ctx, "cluster04")
+	assert.NoError(t, err)
+	assert.Empty(t, holds)
+	baselines, err := models.GetGCReaderWriter().QueryBaselines(ctx, "cluster04")
+	assert.NoError(t, err)
+	assert.Empty(t, baselines)
+}
@@ -0,0 +1,115 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/robfig/cron"
+)
+
+type gcReconciler struct {
+	JobCron *cron.Cron
+	JobSpec string
+}
+
+type gcReconcileHandler struct {
+	running int32
+}
+
+var reconciler *gcReconciler
+var reconcilerOnce sync.Once
+
+// StartGCReconciler
+// @Description: remove expired gc holds and restore tidb_gc_life_time of clusters in background,
+// so that a gc hold never outlives its ttl even if the workflow taking it dies
+func StartGCReconciler() {
+	reconcilerOnce.Do(func() {
+		reconciler = &gcReconciler{
+			JobCron: cron.New(),
+			JobSpec: "*/30 * * * * *", // every 30 seconds
+		}
+		err := reconciler.JobCron.AddJob(reconciler.JobSpec, &gcReconcileHandler{})
+		if err != nil {
+			framework.Log().Fatalf("add gc reconciler cron job failed, %s", err.Error())
+			return
+		}
+		go reconciler.start()
+	})
+}
+
+func (r *gcReconciler) start() {
+	time.Sleep(5 * time.Second) //wait db client ready
+	r.JobCron.Start()
+	defer r.JobCron.Stop()
+
+	select {}
+}
+
+func (handler *gcReconcileHandler) Run() {
+	if !atomic.CompareAndSwapInt32(&handler.running, 0, 1) {
+		framework.Log().Warnf("last round of gc reconciler is still running, skip this round")
+		return
+	}
+	defer atomic.StoreInt32(&handler.running, 0)
+
+	reconcileAll(context.TODO(), time.Now())
+}
+
+// reconcileAll
+// @Description: reconcile every cluster with gc holds or baseline, failure of one cluster does not stop the others
+func reconcileAll(ctx context.Context, now time.Time) {
+	holds, err := models.GetGCReaderWriter().QueryHolds(ctx, "")
+	if err != nil {
+		framework.Log().Errorf("query gc holds failed, %s", err.Error())
+		return
+	}
+	baselines, err := models.GetGCReaderWriter().QueryBaselines(ctx, "")
+	if err != nil {
+		framework.Log().Errorf("query gc baselines failed, %s", err.Error())
+		return
+	}
+
+	clusterIDs := make([]string, 0)
+	visited := make(map[string]bool)
+	for _, hold := range holds {
+		if !visited[hold.ClusterId] {
+			visited[hold.ClusterId] = true
+			clusterIDs = append(clusterIDs, hold.ClusterId)
+		}
+	}
+	for _, baseline := range baselines {
+		if !visited[baseline.ClusterId] {
+			visited[baseline.ClusterId] = true
+			clusterIDs = append(clusterIDs, baseline.ClusterId)
+		}
+	}
+
+	p := GetManager()
+	for _, clusterID := range clusterIDs {
+		p.lock.Lock()
+		err = p.reconcile(ctx, clusterID, now)
+		p.lock.Unlock()
+		if err != nil {
+			framework.Log().Errorf("reconcile gc of cluster %s failed, %s", clusterID, err.Error())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Service interface {
+	// Hold
+	// @Description: take or renew a named gc hold, tidb_gc_life_time of cluster is kept no less than gcLifeTime until the hold is released or expired
+	// @Parameter ctx
+	// @Parameter clusterID
+	// @Parameter name unique in cluster
+	// @Parameter holder who takes the hold, such as workflow id
+	// @Parameter gcLifeTime
+	// @Parameter ttl
+	// @return baseline tidb_gc_life_time of cluster before any hold is taken
+	// @return err
+	Hold(ctx context.Context, clusterID string, name string, holder string, gcLifeTime string, ttl time.Duration) (baseline string, err error)
+
+	// Release
+	// @Description: release a gc hold, the baseline tidb_gc_life_time is restored if there is no other hold of cluster
+	// @Parameter ctx
+	// @Parameter clusterID
+	// @Parameter name
+	// @return error
+	Release(ctx context.Context, clusterID string, name string) error
+}
+
+var service Service
+var serviceOnce sync.Once
+
+func GetGCService() Service {
+	serviceOnce.Do(func() {
+		if service == nil {
+			service = GetManager()
+		}
+	})
+	return service
+}
+
+func MockGCService(s Service) {
+	service = s
+}
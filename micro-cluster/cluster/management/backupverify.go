@@ -0,0 +1,520 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool"
+	"github.com/pingcap/tiunimanager/models"
+	brModel "github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	"github.com/pingcap/tiunimanager/util/uuidutil"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+	"github.com/robfig/cron"
+)
+
+// backups finished within the window are candidates of verification
+var backupVerifyWindow = 24 * time.Hour
+
+// a verification not finished within the timeout is considered dead and no longer blocks the next one
+var backupVerifyTimeout = 24 * time.Hour
+
+// message of tidb when reading data older than gc safe point
+const snapshotGCMessage = "GC safe point"
+
+const scratchClusterTag = "backup-verify"
+
+var verifyBackupFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowVerifyBackup,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":           {"checksumSourceCluster", "sourceDone", "fail", workflow.SyncFuncNode, checksumSourceCluster},
+		"sourceDone":      {"restoreScratchCluster", "restoreStarted", "fail", workflow.SyncFuncNode, restoreScratchCluster},
+		"restoreStarted":  {"waitRestore", "restoreDone", "fail", workflow.SyncFuncNode, waitWorkFlow},
+		"restoreDone":     {"verifyScratchCluster", "verifyDone", "fail", workflow.SyncFuncNode, verifyScratchCluster},
+		"verifyDone":      {"deleteScratchCluster", "deleteStarted", "failAfterVerify", workflow.SyncFuncNode, deleteScratchCluster},
+		"deleteStarted":   {"end", "", "failAfterVerify", workflow.SyncFuncNode, waitWorkFlow},
+		"fail":            {"fail", "", "", workflow.SyncFuncNode, backupVerifyFail},
+		"failAfterVerify": {"failAfterVerify", "", "", workflow.SyncFuncNode, scratchClusterLeft},
+	},
+}
+
+type backupVerifyManager struct {
+	JobCron *cron.Cron
+	JobSpec string
+}
+
+type backupVerifyHandler struct {
+}
+
+func NewBackupVerifyManager() *backupVerifyManager {
+	mgr := &backupVerifyManager{
+		JobCron: cron.New(),
+		JobSpec: "0 15 * * * *", // every quarter past hour
+	}
+	err := mgr.JobCron.AddJob(mgr.JobSpec, &backupVerifyHandler{})
+	if err != nil {
+		framework.Log().Fatalf("add backup verify cron job failed, %s", err.Error())
+		return nil
+	}
+	go mgr.start()
+
+	return mgr
+}
+
+func (mgr *backupVerifyManager) start() {
+	time.Sleep(5 * time.Second) //wait db client ready
+	mgr.JobCron.Start()
+	defer mgr.JobCron.Stop()
+
+	select {}
+}
+
+func (handler *backupVerifyHandler) Run() {
+	framework.Log().Infof("begin BackupVerifyHandler Run")
+	defer framework.Log().Infof("end BackupVerifyHandler Run")
+
+	record, err := selectBackupToVerify(context.TODO())
+	if err != nil {
+		framework.Log().Errorf("select backup to verify failed, %s", err.Error())
+		return
+	}
+	if record == nil {
+		return
+	}
+
+	ctx := framework.NewMicroContextWithKeyValuePairs(context.Background(), map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: record.TenantId})
+	flowID, err := VerifyBackup(ctx, record)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("verify backup %s failed, %s", record.ID, err.Error())
+		return
+	}
+	framework.LogWithContext(ctx).Infof("verify backup %s of cluster %s by workflow %s", record.ID, record.ClusterID, flowID)
+}
+
+// selectBackupToVerify
+// @Description: select the latest finished backup which has never been verified,
+// scratch hosts are shared so nothing is selected while another verification is processing
+// @Parameter ctx
+// @return *brModel.BackupRecord nil if verification is disabled or no backup need to be verified
+// @return error
+func selectBackupToVerify(ctx context.Context) (*brModel.BackupRecord, error) {
+	hosts, _, err := getBackupVerifyConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		framework.LogWithContext(ctx).Infof("no hosts configured by %s, backup verification is disabled", constants.ConfigKeyBackupVerifyHosts)
+		return nil, nil
+	}
+
+	rw := models.GetBRReaderWriter()
+	processing, err := rw.QueryBackupRecordsByVerifyStatus(ctx, "", string(constants.BackupVerifyProcessing), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range processing {
+		if time.Since(record.VerifyTime) < backupVerifyTimeout {
+			framework.LogWithContext(ctx).Infof("backup %s is being verified, skip", record.ID)
+			return nil, nil
+		}
+	}
+
+	records, err := rw.QueryBackupRecordsByVerifyStatus(ctx, "", "", time.Now().Add(-backupVerifyWindow))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// VerifyBackup
+// @Description: restore backup into a scratch cluster on hosts reserved for verification,
+// then compare admin checksums and row counts with the source cluster at backup tso
+// @Parameter ctx
+// @Parameter record
+// @return string workflow id
+// @return error
+func VerifyBackup(ctx context.Context, record *brModel.BackupRecord) (flowID string, err error) {
+	rw := models.GetBRReaderWriter()
+	// record failure, otherwise the same backup would be selected again and again
+	defer func() {
+		if err != nil {
+			if updateErr := rw.UpdateBackupVerifyStatus(ctx, record.ID, string(constants.BackupVerifyFailed), err.Error(), time.Now()); updateErr != nil {
+				framework.LogWithContext(ctx).Errorf("update verify status of backup %s failed, %s", record.ID, updateErr.Error())
+			}
+		}
+	}()
+
+	clusterMeta, err := meta.Get(ctx, record.ClusterID)
+	if err != nil {
+		return "", err
+	}
+	if clusterMeta.Cluster.Type != string(constants.EMProductIDTiDB) {
+		return "", errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster %s of type %s is not supported to verify backup", clusterMeta.Cluster.ID, clusterMeta.Cluster.Type)
+	}
+	hosts, spec, err := getBackupVerifyConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	request, err := buildScratchClusterRequest(ctx, clusterMeta, record, hosts, spec)
+	if err != nil {
+		return "", err
+	}
+	if err = rw.UpdateBackupVerifyStatus(ctx, record.ID, string(constants.BackupVerifyProcessing), "", time.Now()); err != nil {
+		return "", err
+	}
+
+	flowManager := workflow.GetWorkFlowService()
+	flowID, err = flowManager.CreateWorkFlow(ctx, record.ClusterID, workflow.BizTypeCluster, verifyBackupFlow.FlowName)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create workflow %s failed, %s", verifyBackupFlow.FlowName, err.Error())
+		return "", err
+	}
+	flowManager.InitContext(ctx, flowID, ContextBackupVerifyRecord, record)
+	flowManager.InitContext(ctx, flowID, ContextBackupVerifyRequest, request)
+	if err = flowManager.Start(ctx, flowID); err != nil {
+		framework.LogWithContext(ctx).Errorf("start workflow %s failed, %s", flowID, err.Error())
+		return "", err
+	}
+	return flowID, nil
+}
+
+func getBackupVerifyConfig(ctx context.Context) (hosts []string, spec string, err error) {
+	configRW := models.GetConfigReaderWriter()
+	hostsConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupVerifyHosts)
+	if err != nil {
+		return nil, "", errors.WrapError(errors.TIUNIMANAGER_BACKUP_SYSTEM_CONFIG_INVAILD,
+			fmt.Sprintf("get config %s failed", constants.ConfigKeyBackupVerifyHosts), err)
+	}
+	for _, host := range strings.Split(hostsConfig.ConfigValue, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	spec = constants.DefaultBackupVerifySpec
+	specConfig, err := configRW.GetConfig(ctx, constants.ConfigKeyBackupVerifySpec)
+	if err == nil && specConfig.ConfigValue != "" {
+		spec = specConfig.ConfigValue
+	}
+	return hosts, spec, nil
+}
+
+// buildScratchClusterRequest
+// @Description: build a minimal cluster of one pd, one tikv and one tidb on verification hosts of the same architecture
+func buildScratchClusterRequest(ctx context.Context, clusterMeta *meta.ClusterMeta, record *brModel.BackupRecord,
+	hostIPs []string, spec string) (*cluster.RestoreNewClusterReq, error) {
+	hosts := make([]structs.HostInfo, 0)
+	for _, ip := range hostIPs {
+		list, _, err := resourcepool.GetResourcePool().GetHostProvider().QueryHosts(ctx, &structs.Location{
+			HostIp: ip,
+		}, &structs.HostFilter{}, &structs.PageRequest{
+			Page:     1,
+			PageSize: 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			framework.LogWithContext(ctx).Warnf("backup verify host %s not found", ip)
+			continue
+		}
+		if list[0].Status != string(constants.HostOnline) ||
+			constants.GetArchAlias(constants.ArchType(list[0].Arch)) != constants.GetArchAlias(clusterMeta.Cluster.CpuArchitecture) {
+			continue
+		}
+		hosts = append(hosts, list[0])
+	}
+	if len(hosts) == 0 {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_RESOURCE_NO_ENOUGH_HOST,
+			"no online backup verify host of architecture %s in %v", clusterMeta.Cluster.CpuArchitecture, hostIPs)
+	}
+
+	components := []constants.EMProductComponentIDType{constants.ComponentIDPD, constants.ComponentIDTiKV, constants.ComponentIDTiDB}
+	instanceResource := make([]structs.ClusterResourceParameterCompute, 0)
+	for i, component := range components {
+		host := hosts[i%len(hosts)]
+		instanceResource = append(instanceResource, structs.ClusterResourceParameterCompute{
+			Type:  string(component),
+			Count: 1,
+			Resource: []structs.ClusterResourceParameterComputeResource{
+				{
+					Zone:     structs.GenDomainCodeByName(host.Region, host.AZ),
+					DiskType: host.DiskType,
+					Spec:     spec,
+					Count:    1,
+					HostIP:   host.IP,
+				},
+			},
+		})
+	}
+
+	return &cluster.RestoreNewClusterReq{
+		CreateClusterParameter: structs.CreateClusterParameter{
+			Name:            fmt.Sprintf("verify-%s", record.ID),
+			DBPassword:      structs.SensitiveText(uuidutil.GenerateID()),
+			Type:            clusterMeta.Cluster.Type,
+			Version:         clusterMeta.Cluster.Version,
+			Tags:            []string{scratchClusterTag},
+			Copies:          1,
+			Region:          hosts[0].Region,
+			CpuArchitecture: string(clusterMeta.Cluster.CpuArchitecture),
+		},
+		BackupID: record.ID,
+		ResourceParameter: structs.ClusterResourceInfo{
+			RequestResourceMode: constants.ResourceModeSpecificHost,
+			InstanceResource:    instanceResource,
+		},
+	}, nil
+}
+
+// setVerifyMessage
+// @Description: keep failure reason in context for the fail node, which could not get error of the failed node
+func setVerifyMessage(context *workflow.FlowContext, err error) error {
+	if setErr := context.SetData(ContextBackupVerifyMessage, err.Error()); setErr != nil {
+		framework.LogWithContext(context).Errorf("set verify message failed, %s", setErr.Error())
+	}
+	return err
+}
+
+func getClusterConnParam(clusterMeta *meta.ClusterMeta) (utilsql.DbConnParam, error) {
+	address := clusterMeta.GetClusterConnectAddresses()
+	if len(address) == 0 {
+		return utilsql.DbConnParam{}, errors.NewErrorf(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, "cluster %s has no tidb address", clusterMeta.Cluster.ID)
+	}
+	rootUser, ok := clusterMeta.DBUsers[string(constants.Root)]
+	if !ok {
+		return utilsql.DbConnParam{}, errors.NewErrorf(errors.TIUNIMANAGER_USER_NOT_FOUND, "root user of cluster %s not found", clusterMeta.Cluster.ID)
+	}
+	return utilsql.DbConnParam{
+		Username: rootUser.Name,
+		Password: rootUser.Password.Val,
+		IP:       address[0].IP,
+		Port:     strconv.Itoa(address[0].Port),
+	}, nil
+}
+
+// checksumSourceCluster
+// @Description: checksum tables of source cluster at backup tso,
+// comparison is skipped if the snapshot has been garbage collected and only the restore checksum of br is relied on
+func checksumSourceCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var record brModel.BackupRecord
+	if err := context.GetData(ContextBackupVerifyRecord, &record); err != nil {
+		return err
+	}
+	clusterMeta, err := meta.Get(context, record.ClusterID)
+	if err != nil {
+		return setVerifyMessage(context, err)
+	}
+	connParam, err := getClusterConnParam(clusterMeta)
+	if err != nil {
+		return setVerifyMessage(context, err)
+	}
+
+	checksums, err := utilsql.ChecksumTables(context, utilsql.ChecksumTablesReq{
+		DbConnParameter: connParam,
+		SnapshotTSO:     record.BackupTso,
+		DbNames:         splitNames(record.Databases),
+		TableNames:      splitNames(record.Tables),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), snapshotGCMessage) {
+			node.Record(fmt.Sprintf("snapshot %d of cluster %s has been garbage collected, skip comparing with source", record.BackupTso, record.ClusterID))
+			return nil
+		}
+		return setVerifyMessage(context, fmt.Errorf("checksum source cluster %s at %d failed, %s", record.ClusterID, record.BackupTso, err.Error()))
+	}
+	node.Record(fmt.Sprintf("checksum %d tables of source cluster %s at %d", len(checksums), record.ClusterID, record.BackupTso))
+	return context.SetData(ContextSourceChecksums, checksums)
+}
+
+// restoreScratchCluster
+// @Description: restore backup into a new scratch cluster
+func restoreScratchCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var request cluster.RestoreNewClusterReq
+	if err := context.GetData(ContextBackupVerifyRequest, &request); err != nil {
+		return err
+	}
+	resp, err := (&Manager{}).restoreNewCluster(context, request, true)
+	if err != nil {
+		return setVerifyMessage(context, fmt.Errorf("restore scratch cluster failed, %s", err.Error()))
+	}
+	if err = context.SetData(ContextScratchClusterID, resp.ClusterID); err != nil {
+		return err
+	}
+	node.Record(fmt.Sprintf("restore backup %s into scratch cluster %s", request.BackupID, resp.ClusterID))
+	return context.SetData(ContextWorkflowID, resp.WorkFlowID)
+}
+
+// verifyScratchCluster
+// @Description: checksum tables of scratch cluster, compare them with source cluster and record verified
+func verifyScratchCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var record brModel.BackupRecord
+	if err := context.GetData(ContextBackupVerifyRecord, &record); err != nil {
+		return err
+	}
+	var scratchClusterID string
+	if err := context.GetData(ContextScratchClusterID, &scratchClusterID); err != nil {
+		return err
+	}
+	var sourceChecksums []utilsql.TableChecksum
+	if err := context.GetData(ContextSourceChecksums, &sourceChecksums); err != nil {
+		return err
+	}
+
+	clusterMeta, err := meta.Get(context, scratchClusterID)
+	if err != nil {
+		return setVerifyMessage(context, err)
+	}
+	connParam, err := getClusterConnParam(clusterMeta)
+	if err != nil {
+		return setVerifyMessage(context, err)
+	}
+	checksums, err := utilsql.ChecksumTables(context, utilsql.ChecksumTablesReq{
+		DbConnParameter: connParam,
+		DbNames:         splitNames(record.Databases),
+		TableNames:      splitNames(record.Tables),
+	})
+	if err != nil {
+		return setVerifyMessage(context, fmt.Errorf("checksum scratch cluster %s failed, %s", scratchClusterID, err.Error()))
+	}
+
+	message := fmt.Sprintf("%d tables restored and checksummed", len(checksums))
+	if sourceChecksums == nil {
+		message = fmt.Sprintf("%s, source snapshot garbage collected and not compared", message)
+	} else if err = compareChecksums(sourceChecksums, checksums); err != nil {
+		return setVerifyMessage(context, err)
+	}
+	node.Record(message)
+
+	if err = models.GetBRReaderWriter().UpdateBackupVerifyStatus(context, record.ID, string(constants.BackupVerifyVerified), message, time.Now()); err != nil {
+		framework.LogWithContext(context).Errorf("update verify status of backup %s failed, %s", record.ID, err.Error())
+		return err
+	}
+	return nil
+}
+
+// compareChecksums
+// @Description: compare tables of source and restored cluster,
+// crc64 checksum is not compared because it covers table ids, which are reallocated by restore
+func compareChecksums(source []utilsql.TableChecksum, restored []utilsql.TableChecksum) error {
+	restoredTables := make(map[string]utilsql.TableChecksum)
+	for _, table := range restored {
+		restoredTables[table.Name()] = table
+	}
+	for _, table := range source {
+		restoredTable, ok := restoredTables[table.Name()]
+		if !ok {
+			return fmt.Errorf("table %s is not restored", table.Name())
+		}
+		if restoredTable.RowCount != table.RowCount {
+			return fmt.Errorf("row count of table %s mismatch, source %d, restored %d", table.Name(), table.RowCount, restoredTable.RowCount)
+		}
+		if restoredTable.TotalKvs != table.TotalKvs || restoredTable.TotalBytes != table.TotalBytes {
+			return fmt.Errorf("checksum of table %s mismatch, source %d kvs %d bytes, restored %d kvs %d bytes",
+				table.Name(), table.TotalKvs, table.TotalBytes, restoredTable.TotalKvs, restoredTable.TotalBytes)
+		}
+		delete(restoredTables, table.Name())
+	}
+	for name := range restoredTables {
+		return fmt.Errorf("table %s is not in source cluster", name)
+	}
+	return nil
+}
+
+// deleteScratchCluster
+// @Description: destroy scratch cluster and free its resource
+func deleteScratchCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var scratchClusterID string
+	if err := context.GetData(ContextScratchClusterID, &scratchClusterID); err != nil {
+		return err
+	}
+	resp, err := (&Manager{}).DeleteCluster(context, cluster.DeleteClusterReq{
+		ClusterID: scratchClusterID,
+		Force:     true,
+	})
+	if err != nil {
+		framework.LogWithContext(context).Errorf("delete scratch cluster %s failed, %s", scratchClusterID, err.Error())
+		return err
+	}
+	node.Record(fmt.Sprintf("delete scratch cluster %s", scratchClusterID))
+	return context.SetData(ContextWorkflowID, resp.WorkFlowID)
+}
+
+// backupVerifyFail
+// @Description: record backup verification failed, and delete scratch cluster if it has been created
+func backupVerifyFail(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var record brModel.BackupRecord
+	if err := context.GetData(ContextBackupVerifyRecord, &record); err != nil {
+		return err
+	}
+	var message string
+	if err := context.GetData(ContextBackupVerifyMessage, &message); err != nil {
+		return err
+	}
+	if message == "" {
+		message = "verify backup failed"
+	}
+	if err := models.GetBRReaderWriter().UpdateBackupVerifyStatus(context, record.ID, string(constants.BackupVerifyFailed), message, time.Now()); err != nil {
+		framework.LogWithContext(context).Errorf("update verify status of backup %s failed, %s", record.ID, err.Error())
+		return err
+	}
+	node.Record(fmt.Sprintf("verify backup %s failed, %s", record.ID, message))
+
+	var scratchClusterID string
+	if err := context.GetData(ContextScratchClusterID, &scratchClusterID); err != nil {
+		return err
+	}
+	if scratchClusterID != "" {
+		if err := deleteScratchCluster(node, context); err != nil {
+			return scratchClusterLeft(node, context)
+		}
+	}
+	return nil
+}
+
+// scratchClusterLeft
+// @Description: scratch cluster could not be deleted, it is left for manual cleanup
+func scratchClusterLeft(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var scratchClusterID string
+	if err := context.GetData(ContextScratchClusterID, &scratchClusterID); err != nil {
+		return err
+	}
+	framework.LogWithContext(context).Warnf("scratch cluster %s is not deleted, please delete it manually", scratchClusterID)
+	node.Record(fmt.Sprintf("scratch cluster %s is not deleted, please delete it manually", scratchClusterID))
+	return nil
+}
+
+func splitNames(names string) []string {
+	if names == "" {
+		return nil
+	}
+	return strings.Split(names, ",")
+}
@@ -0,0 +1,353 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool/hostprovider"
+	"github.com/pingcap/tiunimanager/models"
+	brModel "github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	rp "github.com/pingcap/tiunimanager/models/resource/resourcepool"
+	wfModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockresource"
+	mock_workflow_service "github.com/pingcap/tiunimanager/test/mockworkflow"
+	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockBackupVerifyConfig(configRW *mockconfig.MockReaderWriter, hosts string) {
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupVerifyHosts).Return(&config.SystemConfig{ConfigValue: hosts}, nil).AnyTimes()
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyBackupVerifySpec).Return(&config.SystemConfig{ConfigValue: "8C16G"}, nil).AnyTimes()
+}
+
+func mockBackupVerifyHosts() map[string]rp.Host {
+	return map[string]rp.Host{
+		"127.0.0.1": {ID: "host1", IP: "127.0.0.1", Arch: string(constants.ArchX8664), Status: string(constants.HostOnline),
+			Region: "Region1", AZ: "Region1,Zone1", DiskType: string(constants.SSD), Reserved: true},
+		"127.0.0.2": {ID: "host2", IP: "127.0.0.2", Arch: string(constants.ArchArm64), Status: string(constants.HostOnline),
+			Region: "Region1", AZ: "Region1,Zone1", DiskType: string(constants.SSD), Reserved: true},
+		"127.0.0.3": {ID: "host3", IP: "127.0.0.3", Arch: string(constants.ArchX8664), Status: string(constants.HostOffline),
+			Region: "Region1", AZ: "Region1,Zone1", DiskType: string(constants.SSD), Reserved: true},
+	}
+}
+
+// keepReaderWriters restores the global reader writers replaced by mocks
+func keepReaderWriters() (restore func()) {
+	configRW := models.GetConfigReaderWriter()
+	clusterRW := models.GetClusterReaderWriter()
+	brRW := models.GetBRReaderWriter()
+	resourceRW := models.GetResourceReaderWriter()
+	return func() {
+		models.SetConfigReaderWriter(configRW)
+		models.SetClusterReaderWriter(clusterRW)
+		models.SetBRReaderWriter(brRW)
+		models.SetResourceReaderWriter(resourceRW)
+		resourcepool.GetResourcePool().GetHostProvider().(*hostprovider.FileHostProvider).SetResourceReaderWriter(resourceRW)
+	}
+}
+
+func mockBackupVerifyResource(ctrl *gomock.Controller) {
+	resourceRW := mockresource.NewMockReaderWriter(ctrl)
+	models.SetResourceReaderWriter(resourceRW)
+	provider := resourcepool.GetResourcePool().GetHostProvider().(*hostprovider.FileHostProvider)
+	provider.SetResourceReaderWriter(resourceRW)
+	hosts := mockBackupVerifyHosts()
+	resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, location *structs.Location, filter *structs.HostFilter, offset int, limit int) ([]rp.Host, int64, error) {
+			if host, ok := hosts[location.HostIp]; ok {
+				return []rp.Host{host}, 1, nil
+			}
+			return []rp.Host{}, 0, nil
+		}).AnyTimes()
+}
+
+func mockBackupVerifyCluster() *meta.ClusterMeta {
+	return &meta.ClusterMeta{
+		Cluster: &management.Cluster{
+			Entity:          common.Entity{ID: "cluster01", TenantId: "tenant01"},
+			Type:            string(constants.EMProductIDTiDB),
+			Version:         "v5.4.0",
+			CpuArchitecture: constants.ArchX86,
+		},
+	}
+}
+
+func Test_compareChecksums(t *testing.T) {
+	source := []utilsql.TableChecksum{
+		{Database: "db1", Table: "t1", Checksum: 1, TotalKvs: 10, TotalBytes: 100, RowCount: 5},
+		{Database: "db1", Table: "t2", Checksum: 2, TotalKvs: 0, TotalBytes: 0, RowCount: 0},
+	}
+	t.Run("equal", func(t *testing.T) {
+		restored := []utilsql.TableChecksum{
+			{Database: "db1", Table: "t2", Checksum: 4, TotalKvs: 0, TotalBytes: 0, RowCount: 0},
+			{Database: "db1", Table: "t1", Checksum: 3, TotalKvs: 10, TotalBytes: 100, RowCount: 5},
+		}
+		assert.NoError(t, compareChecksums(source, restored))
+	})
+	t.Run("table missing", func(t *testing.T) {
+		restored := []utilsql.TableChecksum{
+			{Database: "db1", Table: "t1", TotalKvs: 10, TotalBytes: 100, RowCount: 5},
+		}
+		err := compareChecksums(source, restored)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "db1.t2")
+	})
+	t.Run("row count mismatch", func(t *testing.T) {
+		restored := []utilsql.TableChecksum{
+			{Database: "db1", Table: "t1", TotalKvs: 10, TotalBytes: 100, RowCount: 4},
+			{Database: "db1", Table: "t2"},
+		}
+		err := compareChecksums(source, restored)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "row count")
+	})
+	t.Run("kvs mismatch", func(t *testing.T) {
+		restored := []utilsql.TableChecksum{
+			{Database: "db1", Table: "t1", TotalKvs: 9, TotalBytes: 100, RowCount: 5},
+			{Database: "db1", Table: "t2"},
+		}
+		err := compareChecksums(source, restored)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum")
+	})
+	t.Run("extra table", func(t *testing.T) {
+		restored := []utilsql.TableChecksum{
+			{Database: "db1", Table: "t1", TotalKvs: 10, TotalBytes: 100, RowCount: 5},
+			{Database: "db1", Table: "t2"},
+			{Database: "db2", Table: "t1"},
+		}
+		err := compareChecksums(source, restored)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "db2.t1")
+	})
+}
+
+func Test_selectBackupToVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer keepReaderWriters()()
+
+	t.Run("disabled", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		mockBackupVerifyConfig(configRW, "")
+
+		record, err := selectBackupToVerify(context.TODO())
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+	t.Run("processing", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		mockBackupVerifyConfig(configRW, "127.0.0.1")
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), "", string(constants.BackupVerifyProcessing), gomock.Any()).
+			Return([]*brModel.BackupRecord{{Entity: common.Entity{ID: "backup01"}, VerifyTime: time.Now().Add(-time.Hour)}}, nil)
+
+		record, err := selectBackupToVerify(context.TODO())
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+	})
+	t.Run("normal", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		mockBackupVerifyConfig(configRW, "127.0.0.1, 127.0.0.2")
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), "", string(constants.BackupVerifyProcessing), gomock.Any()).
+			Return([]*brModel.BackupRecord{{Entity: common.Entity{ID: "backup01"}, VerifyTime: time.Now().Add(-48 * time.Hour)}}, nil)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), "", "", gomock.Any()).
+			Return([]*brModel.BackupRecord{{Entity: common.Entity{ID: "backup03"}}, {Entity: common.Entity{ID: "backup02"}}}, nil)
+
+		record, err := selectBackupToVerify(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, "backup03", record.ID)
+	})
+	t.Run("query failed", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		mockBackupVerifyConfig(configRW, "127.0.0.1")
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("query failed"))
+
+		_, err := selectBackupToVerify(context.TODO())
+		assert.Error(t, err)
+	})
+}
+
+func Test_buildScratchClusterRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer keepReaderWriters()()
+	mockBackupVerifyResource(ctrl)
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	record := &brModel.BackupRecord{Entity: common.Entity{ID: "backup01"}}
+	t.Run("normal", func(t *testing.T) {
+		request, err := buildScratchClusterRequest(context.TODO(), mockBackupVerifyCluster(), record,
+			[]string{"127.0.0.2", "127.0.0.3", "127.0.0.9", "127.0.0.1"}, "8C16G")
+		assert.NoError(t, err)
+		assert.Equal(t, "backup01", request.BackupID)
+		assert.Equal(t, "verify-backup01", request.Name)
+		assert.Equal(t, "v5.4.0", request.Version)
+		assert.Equal(t, "Region1", request.Region)
+		assert.Equal(t, 1, request.Copies)
+		assert.Equal(t, constants.ResourceModeSpecificHost, request.ResourceParameter.RequestResourceMode)
+		assert.Equal(t, 3, len(request.ResourceParameter.InstanceResource))
+		for _, compute := range request.ResourceParameter.InstanceResource {
+			assert.Equal(t, 1, compute.Count)
+			assert.Equal(t, "127.0.0.1", compute.Resource[0].HostIP)
+			assert.Equal(t, "Region1,Zone1", compute.Resource[0].Zone)
+			assert.Equal(t, "8C16G", compute.Resource[0].Spec)
+		}
+	})
+	t.Run("no host", func(t *testing.T) {
+		_, err := buildScratchClusterRequest(context.TODO(), mockBackupVerifyCluster(), record, []string{"127.0.0.2", "127.0.0.3"}, "8C16G")
+		assert.Error(t, err)
+	})
+}
+
+func TestVerifyBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer keepReaderWriters()()
+	mockBackupVerifyResource(ctrl)
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	cluster := mockBackupVerifyCluster()
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(cluster.Cluster, []*management.ClusterInstance{}, []*management.DBUser{}, nil).AnyTimes()
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	mockBackupVerifyConfig(configRW, "127.0.0.1")
+
+	record := &brModel.BackupRecord{Entity: common.Entity{ID: "backup01"}, ClusterID: "cluster01"}
+	t.Run("normal", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().UpdateBackupVerifyStatus(gomock.Any(), "backup01", string(constants.BackupVerifyProcessing), "", gomock.Any()).Return(nil)
+		workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+		workflow.MockWorkFlowService(workflowService)
+		defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), "cluster01", workflow.BizTypeCluster, constants.FlowVerifyBackup).Return("flow01", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), "flow01", gomock.Any(), gomock.Any()).Return(nil).Times(2)
+		workflowService.EXPECT().Start(gomock.Any(), "flow01").Return(nil)
+
+		flowID, err := VerifyBackup(context.TODO(), record)
+		assert.NoError(t, err)
+		assert.Equal(t, "flow01", flowID)
+	})
+	t.Run("start failed", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().UpdateBackupVerifyStatus(gomock.Any(), "backup01", string(constants.BackupVerifyProcessing), "", gomock.Any()).Return(nil)
+		brRW.EXPECT().UpdateBackupVerifyStatus(gomock.Any(), "backup01", string(constants.BackupVerifyFailed), gomock.Any(), gomock.Any()).Return(nil)
+		workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+		workflow.MockWorkFlowService(workflowService)
+		defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return("flow01", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		workflowService.EXPECT().Start(gomock.Any(), "flow01").Return(errors.New("start failed"))
+
+		_, err := VerifyBackup(context.TODO(), record)
+		assert.Error(t, err)
+	})
+	t.Run("no host", func(t *testing.T) {
+		configRW := mockconfig.NewMockReaderWriter(ctrl)
+		models.SetConfigReaderWriter(configRW)
+		mockBackupVerifyConfig(configRW, "127.0.0.2")
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().UpdateBackupVerifyStatus(gomock.Any(), "backup01", string(constants.BackupVerifyFailed), gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := VerifyBackup(context.TODO(), record)
+		assert.Error(t, err)
+	})
+}
+
+func Test_verifyScratchCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer keepReaderWriters()()
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMeta(gomock.Any(), "scratch01").Return(&management.Cluster{
+		Entity: common.Entity{ID: "scratch01"},
+	}, []*management.ClusterInstance{}, []*management.DBUser{}, nil)
+
+	flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+	flowContext.SetData(ContextBackupVerifyRecord, &brModel.BackupRecord{Entity: common.Entity{ID: "backup01"}})
+	flowContext.SetData(ContextScratchClusterID, "scratch01")
+	err := verifyScratchCluster(&wfModel.WorkFlowNode{}, flowContext)
+	assert.Error(t, err)
+
+	var message string
+	flowContext.GetData(ContextBackupVerifyMessage, &message)
+	assert.Contains(t, message, "no tidb address")
+}
+
+func Test_backupVerifyFail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer keepReaderWriters()()
+
+	t.Run("before scratch cluster created", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().UpdateBackupVerifyStatus(gomock.Any(), "backup01", string(constants.BackupVerifyFailed), "row count mismatch", gomock.Any()).Return(nil)
+
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextBackupVerifyRecord, &brModel.BackupRecord{Entity: common.Entity{ID: "backup01"}})
+		flowContext.SetData(ContextBackupVerifyMessage, "row count mismatch")
+		assert.NoError(t, backupVerifyFail(&wfModel.WorkFlowNode{}, flowContext))
+	})
+	t.Run("scratch cluster left", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().UpdateBackupVerifyStatus(gomock.Any(), "backup01", string(constants.BackupVerifyFailed), "verify backup failed", gomock.Any()).Return(nil)
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "scratch01").Return(nil, nil, nil, errors.New("not found"))
+
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextBackupVerifyRecord, &brModel.BackupRecord{Entity: common.Entity{ID: "backup01"}})
+		flowContext.SetData(ContextScratchClusterID, "scratch01")
+		node := &wfModel.WorkFlowNode{}
+		assert.NoError(t, backupVerifyFail(node, flowContext))
+		assert.Contains(t, node.Result, "scratch cluster scratch01 is not deleted")
+	})
+}
@@ -0,0 +1,333 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/platform/product"
+)
+
+// specPricing unit prices and resource weights of vendor specs for components of a product
+type specPricing struct {
+	// purposes purpose type of each component, eg. TiKV -> Storage
+	purposes map[string]string
+	specs    []*product.VendorSpec
+}
+
+// zoneConsumption weighted cpu cores and memory consumed in a zone
+type zoneConsumption struct {
+	cpuCores float64
+	memory   float64
+}
+
+// loadSpecPricing
+// @Description: load specs of the vendor and purpose types of the product components, nothing is priced if vendor is empty
+// @Parameter ctx
+// @Parameter vendorID
+// @Parameter productID
+// @return *specPricing
+// @return error
+func loadSpecPricing(ctx context.Context, vendorID string, productID string) (*specPricing, error) {
+	pricing := &specPricing{
+		purposes: make(map[string]string),
+		specs:    make([]*product.VendorSpec, 0),
+	}
+	if len(vendorID) == 0 || len(productID) == 0 {
+		return pricing, nil
+	}
+
+	_, _, components, err := models.GetProductReaderWriter().GetProduct(ctx, productID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get product %s error: %s", productID, err.Error())
+		return nil, err
+	}
+	for _, component := range components {
+		pricing.purposes[component.ComponentID] = component.PurposeType
+	}
+
+	_, _, specs, err := models.GetProductReaderWriter().GetVendor(ctx, vendorID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get vendor %s error: %s", vendorID, err.Error())
+		return nil, err
+	}
+	pricing.specs = specs
+	return pricing, nil
+}
+
+// lookup returns the vendor spec of the resource, a spec with the same disk type is preferred, nil if not found
+func (p *specPricing) lookup(componentType string, resource structs.ClusterResourceParameterComputeResource) *product.VendorSpec {
+	purpose, ok := p.purposes[componentType]
+	if !ok {
+		return nil
+	}
+	cpu, memory := structs.ParseCpu(resource.Spec), structs.ParseMemory(resource.Spec)
+	var found *product.VendorSpec
+	for _, spec := range p.specs {
+		if spec.PurposeType != purpose || spec.CPU != cpu || spec.Memory != memory {
+			continue
+		}
+		if spec.DiskType == resource.DiskType {
+			return spec
+		}
+		if found == nil {
+			found = spec
+		}
+	}
+	return found
+}
+
+func (p *specPricing) weight(componentType string, resource structs.ClusterResourceParameterComputeResource) float64 {
+	if spec := p.lookup(componentType, resource); spec != nil && spec.ResourceWeight > 0 {
+		return spec.ResourceWeight
+	}
+	return 1
+}
+
+// estimateCost
+// @Description: projected monthly cost of instances, resources without unit price are listed but not counted
+// @Receiver p
+// @Parameter instanceResource
+// @return structs.ResourceCostEstimate
+func (p *specPricing) estimateCost(instanceResource []structs.ClusterResourceParameterCompute) structs.ResourceCostEstimate {
+	estimate := structs.ResourceCostEstimate{
+		Items: make([]structs.ResourceCostItem, 0),
+	}
+	for _, instance := range instanceResource {
+		for _, resource := range instance.Resource {
+			item := structs.ResourceCostItem{
+				Type:                                    instance.Type,
+				ClusterResourceParameterComputeResource: resource,
+			}
+			if spec := p.lookup(instance.Type, resource); spec != nil && spec.UnitPrice > 0 {
+				item.Priced = true
+				item.UnitPrice = spec.UnitPrice
+				item.MonthlyCost = spec.UnitPrice * float64(resource.Count)
+				estimate.MonthlyCost += item.MonthlyCost
+			}
+			estimate.Items = append(estimate.Items, item)
+		}
+	}
+	return estimate
+}
+
+// consume adds weighted cpu cores and memory of instances to consumed, keyed by zone code
+func (p *specPricing) consume(instanceResource []structs.ClusterResourceParameterCompute, consumed map[string]*zoneConsumption) {
+	for _, instance := range instanceResource {
+		for _, resource := range instance.Resource {
+			if _, ok := consumed[resource.Zone]; !ok {
+				consumed[resource.Zone] = &zoneConsumption{}
+			}
+			weight := p.weight(instance.Type, resource)
+			consumed[resource.Zone].cpuCores += weight * float64(structs.ParseCpu(resource.Spec)*resource.Count)
+			consumed[resource.Zone].memory += weight * float64(structs.ParseMemory(resource.Spec)*resource.Count)
+		}
+	}
+}
+
+// estimateCapacity
+// @Description: share of the pool capacity consumed in each zone, and the headroom left in the pool
+// @Parameter ctx
+// @Parameter location location of the pool
+// @Parameter filter filter of hosts in the pool
+// @Parameter consumed weighted resources keyed by zone code
+// @Parameter allocated whether consumed resources are already allocated from the pool, eg. by running clusters
+// @return []structs.ZoneCapacityEstimate sorted by zone code
+// @return error
+func estimateCapacity(ctx context.Context, location *structs.Location, filter *structs.HostFilter,
+	consumed map[string]*zoneConsumption, allocated bool) ([]structs.ZoneCapacityEstimate, error) {
+	hosts, err := queryHostsInLocation(ctx, location, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make(map[string]*structs.ZoneCapacityEstimate)
+	for _, host := range hosts {
+		zone := structs.GenDomainCodeByName(host.Region, host.AZ)
+		if _, ok := pools[zone]; !ok {
+			pools[zone] = &structs.ZoneCapacityEstimate{Zone: zone}
+		}
+		pool := pools[zone]
+		pool.TotalCpuCores += host.CpuCores
+		pool.TotalMemory += host.Memory
+		pool.HeadroomCpuCores += float64(host.CpuCores - host.UsedCpuCores)
+		pool.HeadroomMemory += float64(host.Memory - host.UsedMemory)
+	}
+	for zone := range consumed {
+		if _, ok := pools[zone]; !ok {
+			pools[zone] = &structs.ZoneCapacityEstimate{Zone: zone}
+		}
+	}
+	zones := make([]string, 0, len(pools))
+	for zone := range pools {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	result := make([]structs.ZoneCapacityEstimate, 0, len(zones))
+	for _, zone := range zones {
+		pool := pools[zone]
+		if usage, ok := consumed[zone]; ok {
+			pool.ConsumedCpuCores = usage.cpuCores
+			pool.ConsumedMemory = usage.memory
+			if !allocated {
+				pool.HeadroomCpuCores -= usage.cpuCores
+				pool.HeadroomMemory -= usage.memory
+			}
+		}
+		if pool.TotalCpuCores > 0 {
+			pool.CpuShare = pool.ConsumedCpuCores / float64(pool.TotalCpuCores)
+		}
+		if pool.TotalMemory > 0 {
+			pool.MemoryShare = pool.ConsumedMemory / float64(pool.TotalMemory)
+		}
+		result = append(result, *pool)
+	}
+	return result, nil
+}
+
+// estimatePreview
+// @Description: fill projected cost and capacity consumption of the instances to be created in preview response
+// @Parameter ctx
+// @Parameter vendorID
+// @Parameter productID
+// @Parameter instanceResource
+// @Parameter resp
+// @return error
+func estimatePreview(ctx context.Context, vendorID string, productID string, instanceResource []structs.ClusterResourceParameterCompute, resp *cluster.PreviewClusterResp) error {
+	pricing, err := loadSpecPricing(ctx, vendorID, productID)
+	if err != nil {
+		return err
+	}
+	resp.CostEstimate = pricing.estimateCost(instanceResource)
+
+	consumed := make(map[string]*zoneConsumption)
+	pricing.consume(instanceResource, consumed)
+	resp.CapacityEstimates, err = estimateCapacity(ctx, &structs.Location{Region: resp.Region}, &structs.HostFilter{
+		Arch:   resp.CpuArchitecture,
+		Status: string(constants.HostOnline),
+	}, consumed, false)
+	return err
+}
+
+// allocatedInstanceResource
+// @Description: group instances holding resources by component, zone, spec and disk, zones are converted to zone codes
+// @Parameter clusterInfo
+// @Parameter instances
+// @return []structs.ClusterResourceParameterCompute
+func allocatedInstanceResource(clusterInfo *management.Cluster, instances []*management.ClusterInstance) []structs.ClusterResourceParameterCompute {
+	result := make([]structs.ClusterResourceParameterCompute, 0)
+	for _, instance := range instances {
+		// hibernated instances have released their hosts,
+		// and monitoring instances share hosts with other components without compute resources
+		if len(instance.HostID) == 0 || instance.Status == string(constants.ClusterInstanceHibernated) ||
+			(instance.CpuCores == 0 && instance.Memory == 0) {
+			continue
+		}
+		index := -1
+		for i := range result {
+			if result[i].Type == instance.Type {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			result = append(result, structs.ClusterResourceParameterCompute{
+				Type:     instance.Type,
+				Resource: make([]structs.ClusterResourceParameterComputeResource, 0),
+			})
+			index = len(result) - 1
+		}
+		compute := &result[index]
+		compute.Count++
+
+		zone := structs.GenDomainCodeByName(clusterInfo.Region, instance.Zone)
+		spec := structs.GenSpecCode(int32(instance.CpuCores), int32(instance.Memory))
+		newResource := true
+		for i := range compute.Resource {
+			if compute.Resource[i].Equal(zone, spec, instance.DiskType, int(instance.DiskCapacity)) {
+				compute.Resource[i].Count++
+				newResource = false
+			}
+		}
+		if newResource {
+			compute.Resource = append(compute.Resource, structs.ClusterResourceParameterComputeResource{
+				Zone:         zone,
+				Spec:         spec,
+				DiskType:     instance.DiskType,
+				DiskCapacity: int(instance.DiskCapacity),
+				Count:        1,
+			})
+		}
+	}
+	return result
+}
+
+// QueryTenantCostReport
+// @Description: projected monthly cost of clusters of a tenant, and share of the pool capacity consumed by them in each zone
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) QueryTenantCostReport(ctx context.Context, req cluster.QueryTenantCostReportReq) (resp cluster.QueryTenantCostReportResp, err error) {
+	results, err := models.GetClusterReaderWriter().QueryClusters(ctx, req.TenantID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query clusters of tenant %s error: %s", req.TenantID, err.Error())
+		return
+	}
+
+	resp.TenantID = req.TenantID
+	resp.Clusters = make([]cluster.ClusterCostReport, 0)
+	pricings := make(map[string]*specPricing)
+	consumed := make(map[string]*zoneConsumption)
+	for _, result := range results {
+		clusterInfo := result.Cluster
+		key := clusterInfo.Vendor + "/" + clusterInfo.Type
+		pricing, ok := pricings[key]
+		if !ok {
+			pricing, err = loadSpecPricing(ctx, clusterInfo.Vendor, clusterInfo.Type)
+			if err != nil {
+				return
+			}
+			pricings[key] = pricing
+		}
+
+		instanceResource := allocatedInstanceResource(clusterInfo, result.Instances)
+		estimate := pricing.estimateCost(instanceResource)
+		pricing.consume(instanceResource, consumed)
+		resp.MonthlyCost += estimate.MonthlyCost
+		resp.Clusters = append(resp.Clusters, cluster.ClusterCostReport{
+			ClusterID:            clusterInfo.ID,
+			ClusterName:          clusterInfo.Name,
+			Vendor:               clusterInfo.Vendor,
+			Region:               clusterInfo.Region,
+			ResourceCostEstimate: estimate,
+		})
+	}
+
+	resp.CapacityUsages, err = estimateCapacity(ctx, &structs.Location{}, &structs.HostFilter{
+		Status: string(constants.HostOnline),
+	}, consumed, true)
+	return
+}
@@ -0,0 +1,298 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool/hostprovider"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/product"
+	rp "github.com/pingcap/tiunimanager/models/resource/resourcepool"
+	mock_product "github.com/pingcap/tiunimanager/test/mockmodels"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockresource"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockSpecPricing() *specPricing {
+	return &specPricing{
+		purposes: map[string]string{"TiDB": "Compute", "TiKV": "Storage", "PD": "Schedule"},
+		specs: []*product.VendorSpec{
+			{VendorID: "Local", SpecID: "c.large", SpecName: "4C8G", CPU: 4, Memory: 8, DiskType: "SATA", PurposeType: "Compute", UnitPrice: 100},
+			{VendorID: "Local", SpecID: "st.large", SpecName: "4C8G", CPU: 4, Memory: 8, DiskType: "SATA", PurposeType: "Storage", UnitPrice: 150, ResourceWeight: 1.5},
+			{VendorID: "Local", SpecID: "st.large.ssd", SpecName: "4C8G", CPU: 4, Memory: 8, DiskType: "SSD", PurposeType: "Storage", UnitPrice: 200, ResourceWeight: 2},
+			{VendorID: "Local", SpecID: "sc.large", SpecName: "4C8G", CPU: 4, Memory: 8, DiskType: "SATA", PurposeType: "Schedule"},
+		},
+	}
+}
+
+func mockPreviewResource() []structs.ClusterResourceParameterCompute {
+	return []structs.ClusterResourceParameterCompute{
+		{Type: "TiDB", Count: 2, Resource: []structs.ClusterResourceParameterComputeResource{
+			{Zone: "Region1,Zone1", Count: 2, Spec: "4C8G", DiskType: "SATA"},
+		}},
+		{Type: "TiKV", Count: 3, Resource: []structs.ClusterResourceParameterComputeResource{
+			{Zone: "Region1,Zone1", Count: 1, Spec: "4C8G", DiskType: "SSD"},
+			{Zone: "Region1,Zone2", Count: 2, Spec: "4C8G", DiskType: "NVMeSSD"},
+		}},
+		{Type: "PD", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+			{Zone: "Region1,Zone2", Count: 1, Spec: "4C8G", DiskType: "SATA"},
+		}},
+	}
+}
+
+func Test_specPricing_lookup(t *testing.T) {
+	pricing := mockSpecPricing()
+	t.Run("same disk type", func(t *testing.T) {
+		spec := pricing.lookup("TiKV", structs.ClusterResourceParameterComputeResource{Spec: "4C8G", DiskType: "SSD"})
+		assert.Equal(t, "st.large.ssd", spec.SpecID)
+	})
+	t.Run("other disk type", func(t *testing.T) {
+		spec := pricing.lookup("TiKV", structs.ClusterResourceParameterComputeResource{Spec: "4C8G", DiskType: "NVMeSSD"})
+		assert.Equal(t, "st.large", spec.SpecID)
+	})
+	t.Run("spec not found", func(t *testing.T) {
+		assert.Nil(t, pricing.lookup("TiKV", structs.ClusterResourceParameterComputeResource{Spec: "8C16G", DiskType: "SSD"}))
+	})
+	t.Run("component not found", func(t *testing.T) {
+		assert.Nil(t, pricing.lookup("TiFlash", structs.ClusterResourceParameterComputeResource{Spec: "4C8G", DiskType: "SSD"}))
+	})
+}
+
+func Test_specPricing_estimateCost(t *testing.T) {
+	estimate := mockSpecPricing().estimateCost(mockPreviewResource())
+	assert.Equal(t, float64(2*100+200+2*150), estimate.MonthlyCost)
+	assert.Len(t, estimate.Items, 4)
+	assert.Equal(t, "TiDB", estimate.Items[0].Type)
+	assert.True(t, estimate.Items[0].Priced)
+	assert.Equal(t, float64(200), estimate.Items[0].MonthlyCost)
+	assert.Equal(t, float64(200), estimate.Items[1].UnitPrice)
+	// no price is configured for PD spec
+	assert.False(t, estimate.Items[3].Priced)
+	assert.Equal(t, float64(0), estimate.Items[3].MonthlyCost)
+
+	empty := (&specPricing{}).estimateCost(mockPreviewResource())
+	assert.Equal(t, float64(0), empty.MonthlyCost)
+	assert.Len(t, empty.Items, 4)
+}
+
+func Test_specPricing_consume(t *testing.T) {
+	consumed := make(map[string]*zoneConsumption)
+	mockSpecPricing().consume(mockPreviewResource(), consumed)
+	assert.Len(t, consumed, 2)
+	// TiDB 2 * 4 * 1 + TiKV 1 * 4 * 2
+	assert.Equal(t, float64(16), consumed["Region1,Zone1"].cpuCores)
+	assert.Equal(t, float64(32), consumed["Region1,Zone1"].memory)
+	// TiKV 2 * 4 * 1.5 + PD 1 * 4 * 1
+	assert.Equal(t, float64(16), consumed["Region1,Zone2"].cpuCores)
+	assert.Equal(t, float64(32), consumed["Region1,Zone2"].memory)
+}
+
+func mockCapacityHosts() []rp.Host {
+	return []rp.Host{
+		{ID: "host1", Region: "Region1", AZ: "Region1,Zone1", Status: string(constants.HostOnline),
+			CpuCores: 32, Memory: 64, FreeCpuCores: 24, FreeMemory: 48},
+		{ID: "host2", Region: "Region1", AZ: "Region1,Zone1", Status: string(constants.HostOnline),
+			CpuCores: 32, Memory: 64, FreeCpuCores: 32, FreeMemory: 64},
+		{ID: "host3", Region: "Region1", AZ: "Region1,Zone3", Status: string(constants.HostOnline),
+			CpuCores: 16, Memory: 32, FreeCpuCores: 16, FreeMemory: 32},
+	}
+}
+
+func Test_estimateCapacity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resourceRW := mockresource.NewMockReaderWriter(ctrl)
+	models.SetResourceReaderWriter(resourceRW)
+	provider := resourcepool.GetResourcePool().GetHostProvider().(*hostprovider.FileHostProvider)
+	provider.SetResourceReaderWriter(resourceRW)
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	consumed := map[string]*zoneConsumption{
+		"Region1,Zone1": {cpuCores: 16, memory: 32},
+		"Region1,Zone2": {cpuCores: 8, memory: 16},
+	}
+	t.Run("to be allocated", func(t *testing.T) {
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, location *structs.Location, filter *structs.HostFilter, offset int, limit int) ([]rp.Host, int64, error) {
+				assert.Equal(t, "Region1", location.Region)
+				assert.Equal(t, string(constants.HostOnline), filter.Status)
+				return mockCapacityHosts(), 3, nil
+			})
+		result, err := estimateCapacity(context.TODO(), &structs.Location{Region: "Region1"}, &structs.HostFilter{Status: string(constants.HostOnline)}, consumed, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []structs.ZoneCapacityEstimate{
+			{Zone: "Region1,Zone1", TotalCpuCores: 64, TotalMemory: 128, ConsumedCpuCores: 16, ConsumedMemory: 32,
+				CpuShare: 0.25, MemoryShare: 0.25, HeadroomCpuCores: 40, HeadroomMemory: 80},
+			{Zone: "Region1,Zone2", ConsumedCpuCores: 8, ConsumedMemory: 16, HeadroomCpuCores: -8, HeadroomMemory: -16},
+			{Zone: "Region1,Zone3", TotalCpuCores: 16, TotalMemory: 32, HeadroomCpuCores: 16, HeadroomMemory: 32},
+		}, result)
+	})
+	t.Run("allocated", func(t *testing.T) {
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockCapacityHosts(), int64(3), nil)
+		result, err := estimateCapacity(context.TODO(), &structs.Location{}, &structs.HostFilter{}, consumed, true)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(56), result[0].HeadroomCpuCores)
+		assert.Equal(t, float64(112), result[0].HeadroomMemory)
+		assert.Equal(t, float64(0), result[1].HeadroomCpuCores)
+	})
+	t.Run("query failed", func(t *testing.T) {
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), errors.New("query failed"))
+		_, err := estimateCapacity(context.TODO(), &structs.Location{}, &structs.HostFilter{}, consumed, true)
+		assert.Error(t, err)
+	})
+}
+
+func Test_loadSpecPricing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	productRW := mock_product.NewMockReaderWriter(ctrl)
+	models.SetProductReaderWriter(productRW)
+
+	t.Run("normal", func(t *testing.T) {
+		productRW.EXPECT().GetProduct(gomock.Any(), "TiDB").Return(&product.ProductInfo{ProductID: "TiDB"}, nil, []*product.ProductComponentInfo{
+			{ProductID: "TiDB", ComponentID: "TiDB", PurposeType: "Compute"},
+			{ProductID: "TiDB", ComponentID: "TiKV", PurposeType: "Storage"},
+		}, nil)
+		productRW.EXPECT().GetVendor(gomock.Any(), "Local").Return(&product.Vendor{VendorID: "Local"}, nil, mockSpecPricing().specs, nil)
+		pricing, err := loadSpecPricing(context.TODO(), "Local", "TiDB")
+		assert.NoError(t, err)
+		assert.Equal(t, "Storage", pricing.purposes["TiKV"])
+		assert.Len(t, pricing.specs, 4)
+	})
+	t.Run("no vendor", func(t *testing.T) {
+		pricing, err := loadSpecPricing(context.TODO(), "", "TiDB")
+		assert.NoError(t, err)
+		assert.Empty(t, pricing.specs)
+	})
+	t.Run("product error", func(t *testing.T) {
+		productRW.EXPECT().GetProduct(gomock.Any(), "TiDB").Return(nil, nil, nil, errors.New("product not found"))
+		_, err := loadSpecPricing(context.TODO(), "Local", "TiDB")
+		assert.Error(t, err)
+	})
+	t.Run("vendor error", func(t *testing.T) {
+		productRW.EXPECT().GetProduct(gomock.Any(), "TiDB").Return(&product.ProductInfo{ProductID: "TiDB"}, nil, []*product.ProductComponentInfo{}, nil)
+		productRW.EXPECT().GetVendor(gomock.Any(), "Local").Return(nil, nil, nil, errors.New("vendor not found"))
+		_, err := loadSpecPricing(context.TODO(), "Local", "TiDB")
+		assert.Error(t, err)
+	})
+}
+
+func mockCostInstances() []*management.ClusterInstance {
+	return []*management.ClusterInstance{
+		{Entity: common.Entity{ID: "tidb01", Status: string(constants.ClusterInstanceRunning)}, Type: "TiDB", HostID: "host1",
+			Zone: "Zone1", CpuCores: 4, Memory: 8, DiskType: "SATA"},
+		{Entity: common.Entity{ID: "tidb02", Status: string(constants.ClusterInstanceRunning)}, Type: "TiDB", HostID: "host2",
+			Zone: "Zone1", CpuCores: 4, Memory: 8, DiskType: "SATA"},
+		{Entity: common.Entity{ID: "tidb03", Status: string(constants.ClusterInstanceHibernated)}, Type: "TiDB", HostID: "host2",
+			Zone: "Zone1", CpuCores: 4, Memory: 8, DiskType: "SATA"},
+		{Entity: common.Entity{ID: "tikv01", Status: string(constants.ClusterInstanceRunning)}, Type: "TiKV", HostID: "host1",
+			Zone: "Zone1", CpuCores: 4, Memory: 8, DiskType: "SSD", DiskCapacity: 100},
+		{Entity: common.Entity{ID: "tikv02", Status: string(constants.ClusterInstanceInitializing)}, Type: "TiKV",
+			Zone: "Zone1", CpuCores: 4, Memory: 8, DiskType: "SSD", DiskCapacity: 100},
+		{Entity: common.Entity{ID: "grafana01", Status: string(constants.ClusterInstanceRunning)}, Type: "Grafana", HostID: "host1",
+			Zone: "Zone1"},
+	}
+}
+
+func Test_allocatedInstanceResource(t *testing.T) {
+	result := allocatedInstanceResource(&management.Cluster{Region: "Region1"}, mockCostInstances())
+	assert.Equal(t, []structs.ClusterResourceParameterCompute{
+		{Type: "TiDB", Count: 2, Resource: []structs.ClusterResourceParameterComputeResource{
+			{Zone: "Region1,Zone1", Spec: "4C8G", DiskType: "SATA", Count: 2},
+		}},
+		{Type: "TiKV", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+			{Zone: "Region1,Zone1", Spec: "4C8G", DiskType: "SSD", DiskCapacity: 100, Count: 1},
+		}},
+	}, result)
+}
+
+func TestManager_QueryTenantCostReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resourceRW := mockresource.NewMockReaderWriter(ctrl)
+	models.SetResourceReaderWriter(resourceRW)
+	provider := resourcepool.GetResourcePool().GetHostProvider().(*hostprovider.FileHostProvider)
+	provider.SetResourceReaderWriter(resourceRW)
+	productRW := mock_product.NewMockReaderWriter(ctrl)
+	models.SetProductReaderWriter(productRW)
+
+	manager := &Manager{}
+	t.Run("normal", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().QueryClusters(gomock.Any(), "tenant01").Return([]*management.Result{
+			{Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster01"}, Name: "cluster01", Type: "TiDB", Vendor: "Local", Region: "Region1"},
+				Instances: mockCostInstances()},
+			{Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster02"}, Name: "cluster02", Type: "TiDB", Vendor: "Local", Region: "Region1"},
+				Instances: mockCostInstances()[:1]},
+		}, nil)
+		clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+		// specs are loaded once for clusters of the same vendor and product
+		productRW.EXPECT().GetProduct(gomock.Any(), "TiDB").Return(&product.ProductInfo{ProductID: "TiDB"}, nil, []*product.ProductComponentInfo{
+			{ProductID: "TiDB", ComponentID: "TiDB", PurposeType: "Compute"},
+			{ProductID: "TiDB", ComponentID: "TiKV", PurposeType: "Storage"},
+		}, nil).Times(1)
+		productRW.EXPECT().GetVendor(gomock.Any(), "Local").Return(&product.Vendor{VendorID: "Local"}, nil, mockSpecPricing().specs, nil).Times(1)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(mockCapacityHosts(), int64(3), nil)
+
+		resp, err := manager.QueryTenantCostReport(context.TODO(), cluster.QueryTenantCostReportReq{TenantID: "tenant01"})
+		assert.NoError(t, err)
+		assert.Equal(t, "tenant01", resp.TenantID)
+		assert.Len(t, resp.Clusters, 2)
+		assert.Equal(t, float64(2*100+200), resp.Clusters[0].MonthlyCost)
+		assert.Equal(t, float64(100), resp.Clusters[1].MonthlyCost)
+		assert.Equal(t, float64(2*100+200+100), resp.MonthlyCost)
+		assert.Len(t, resp.CapacityUsages, 2)
+		// TiDB 3 * 4 + TiKV 1 * 4 * 2
+		assert.Equal(t, float64(20), resp.CapacityUsages[0].ConsumedCpuCores)
+		assert.Equal(t, float64(56), resp.CapacityUsages[0].HeadroomCpuCores)
+	})
+	t.Run("query clusters failed", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().QueryClusters(gomock.Any(), "tenant01").Return(nil, errors.New("query failed"))
+
+		_, err := manager.QueryTenantCostReport(context.TODO(), cluster.QueryTenantCostReportReq{TenantID: "tenant01"})
+		assert.Error(t, err)
+	})
+	t.Run("load specs failed", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().QueryClusters(gomock.Any(), "tenant01").Return([]*management.Result{
+			{Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster01"}, Type: "TiDB", Vendor: "Local"}},
+		}, nil)
+		productRW.EXPECT().GetProduct(gomock.Any(), "TiDB").Return(nil, nil, nil, errors.New("product not found"))
+
+		_, err := manager.QueryTenantCostReport(context.TODO(), cluster.QueryTenantCostReportReq{TenantID: "tenant01"})
+		assert.Error(t, err)
+	})
+}
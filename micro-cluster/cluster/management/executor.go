@@ -42,6 +42,7 @@ import (
 	"github.com/pingcap/tiunimanager/library/util"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/gc"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/log"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/parameter"
@@ -53,6 +54,7 @@ import (
 	utilsql "github.com/pingcap/tiunimanager/util/api/tidb/sql"
 	"github.com/pingcap/tiunimanager/util/uuidutil"
 	workflow "github.com/pingcap/tiunimanager/workflow2"
+	dmSpec "github.com/pingcap/tiup/components/dm/spec"
 	tiupMgr "github.com/pingcap/tiup/pkg/cluster/manager"
 	"github.com/pingcap/tiup/pkg/cluster/spec"
 	"gopkg.in/yaml.v2"
@@ -67,6 +69,12 @@ func prepareResource(node *workflowModel.WorkFlowNode, context *workflow.FlowCon
 		return err
 	}
 
+	var reservedHostsAllowed bool
+	err = context.GetData(ContextReservedHostsAllowed, &reservedHostsAllowed)
+	if err != nil {
+		return err
+	}
+
 	globalAllocId := uuidutil.GenerateID()
 	instanceAllocId := uuidutil.GenerateID()
 
@@ -84,9 +92,10 @@ func prepareResource(node *workflowModel.WorkFlowNode, context *workflow.FlowCon
 		BatchRequests: []resourceStructs.AllocReq{
 			{
 				Applicant: resourceStructs.Applicant{
-					HolderId:          clusterMeta.Cluster.ID,
-					RequestId:         instanceAllocId,
-					TakeoverOperation: false,
+					HolderId:             clusterMeta.Cluster.ID,
+					RequestId:            instanceAllocId,
+					TakeoverOperation:    false,
+					ReservedHostsAllowed: reservedHostsAllowed,
 				},
 				Requires: instanceRequirement,
 			},
@@ -192,7 +201,7 @@ func scaleOutCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowCon
 		"scale out cluster %s, version %s, yamlConfig %s", cluster.ID, cluster.Version, yamlConfig)
 	args := framework.GetTiupAuthorizaitonFlag()
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
-	operationID, err := deployment.M.ScaleOut(context.Context, deployment.TiUPComponentTypeCluster, cluster.ID, yamlConfig,
+	operationID, err := deployment.M.ScaleOut(context.Context, clusterMeta.GetTiUPComponentType(), cluster.ID, yamlConfig,
 		tiupHomeForTidb, node.ParentID, args, meta.DefaultTiupTimeOut)
 	if err != nil {
 		framework.LogWithContext(context.Context).Errorf(
@@ -230,7 +239,7 @@ func scaleInCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowCont
 	framework.LogWithContext(context.Context).Infof(
 		"scale in cluster %s, delete instance %s", clusterMeta.Cluster.ID, instanceID)
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
-	operationID, err := deployment.M.ScaleIn(context.Context, deployment.TiUPComponentTypeCluster, clusterMeta.Cluster.ID,
+	operationID, err := deployment.M.ScaleIn(context.Context, clusterMeta.GetTiUPComponentType(), clusterMeta.Cluster.ID,
 		strings.Join([]string{instance.HostIP[0], strconv.Itoa(int(instance.Ports[0]))}, ":"), tiupHomeForTidb,
 		node.ParentID, []string{}, meta.DefaultTiupTimeOut)
 	if err != nil {
@@ -579,7 +588,17 @@ func restoreNewCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowC
 	if err != nil {
 		return err
 	}
-	if backupID == "" {
+	var locationID string
+	err = context.GetData(ContextBackupLocationID, &locationID)
+	if err != nil {
+		return err
+	}
+	var pointInTime cluster.PointInTimeRestoreTarget
+	err = context.GetData(ContextPointInTime, &pointInTime)
+	if err != nil {
+		return err
+	}
+	if backupID == "" && pointInTime.RestoreTSO == "" && pointInTime.RestoreTime == 0 {
 		framework.LogWithContext(context.Context).Infof(
 			"when restore new cluster, not found backup id")
 		return nil
@@ -587,18 +606,20 @@ func restoreNewCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowC
 
 	restoreResponse, err := backuprestore.GetBRService().RestoreExistCluster(context.Context,
 		cluster.RestoreExistClusterReq{
-			ClusterID: clusterMeta.Cluster.ID,
-			BackupID:  backupID,
+			ClusterID:   clusterMeta.Cluster.ID,
+			BackupID:    backupID,
+			LocationID:  locationID,
+			PointInTime: pointInTime,
 		}, false)
 	if err != nil {
-		framework.LogWithContext(context.Context).Errorf("do restore for cluster %s by backup id %s error: %s", clusterMeta.Cluster.ID, backupID, err.Error())
-		return fmt.Errorf("do restore for cluster %s by backup id %s error: %s", clusterMeta.Cluster.ID, backupID, err.Error())
+		framework.LogWithContext(context.Context).Errorf("do restore for cluster %s by backup id %s, point in time %+v error: %s", clusterMeta.Cluster.ID, backupID, pointInTime, err.Error())
+		return fmt.Errorf("do restore for cluster %s by backup id %s, point in time %+v error: %s", clusterMeta.Cluster.ID, backupID, pointInTime, err.Error())
 	}
 
 	if err = context.SetData(ContextWorkflowID, restoreResponse.WorkFlowID); err != nil {
 		return err
 	}
-	node.Record(fmt.Sprintf("do restore for cluster %s, backup ID: %s ", clusterMeta.Cluster.ID, backupID))
+	node.Record(fmt.Sprintf("do restore for cluster %s, backup ID: %s, point in time: %+v ", clusterMeta.Cluster.ID, backupID, pointInTime))
 	return nil
 }
 
@@ -818,7 +839,7 @@ func deployCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowConte
 	args := framework.GetTiupAuthorizaitonFlag()
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
 	// todo: use SystemConfig to store home
-	operationID, err := deployment.M.Deploy(context.Context, deployment.TiUPComponentTypeCluster, cluster.ID, cluster.Version, yamlConfig,
+	operationID, err := deployment.M.Deploy(context.Context, clusterMeta.GetTiUPComponentType(), cluster.ID, cluster.Version, yamlConfig,
 		tiupHomeForTidb, node.ParentID, args, meta.LongTiupTimeOut)
 	if err != nil {
 		framework.LogWithContext(context.Context).Errorf(
@@ -846,7 +867,7 @@ func startCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowContex
 		"start cluster %s, version %s", cluster.ID, cluster.Version)
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
 	// todo: use SystemConfig to store home
-	operationID, err := deployment.M.Start(context.Context, deployment.TiUPComponentTypeCluster, cluster.ID,
+	operationID, err := deployment.M.Start(context.Context, clusterMeta.GetTiUPComponentType(), cluster.ID,
 		tiupHomeForTidb, node.ParentID, []string{}, meta.DefaultTiupTimeOut)
 	if err != nil {
 		framework.LogWithContext(context.Context).Errorf(
@@ -875,7 +896,7 @@ func restartCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowCont
 		"restart cluster %s, version %s", cluster.ID, cluster.Version)
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
 	// todo: use SystemConfig to store home
-	operationID, err := deployment.M.Restart(context.Context, deployment.TiUPComponentTypeCluster, cluster.ID,
+	operationID, err := deployment.M.Restart(context.Context, clusterMeta.GetTiUPComponentType(), cluster.ID,
 		tiupHomeForTidb, node.ParentID, []string{}, meta.DefaultTiupTimeOut)
 	if err != nil {
 		framework.LogWithContext(context.Context).Errorf(
@@ -1148,6 +1169,11 @@ func modifySourceClusterGCTime(node *workflowModel.WorkFlowNode, context *workfl
 	if err != nil {
 		return err
 	}
+	var clusterMeta meta.ClusterMeta
+	err = context.GetData(ContextClusterMeta, &clusterMeta)
+	if err != nil {
+		return err
+	}
 	var cloneStrategy string
 	err = context.GetData(ContextCloneStrategy, &cloneStrategy)
 	if err != nil {
@@ -1158,27 +1184,15 @@ func modifySourceClusterGCTime(node *workflowModel.WorkFlowNode, context *workfl
 		return nil
 	}
 
-	db, err := meta.CreateSQLLink(context.Context, &sourceClusterMeta)
-	if err != nil {
-		return errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
-	}
-	defer db.Close()
-
-	var GCLifeTime sql.NullString
-	err = db.QueryRow(meta.GetGCLifeTimeCmd).Scan(&GCLifeTime)
-	if err != nil {
-		return err
-	}
-	if !GCLifeTime.Valid {
-		return errors.NewErrorf(errors.TIUNIMANAGER_UNRECOGNIZED_ERROR,
-			"cluster %s not found tidb_gc_life_time", sourceClusterMeta.Cluster.ID)
-	}
-	_, err = db.ExecContext(context.Context, "set global tidb_gc_life_time=?;", meta.DefaultMaxGCLifeTime)
+	// the hold expires even if the workflow dies before releasing it
+	baseline, err := gc.GetGCService().Hold(context.Context, sourceClusterMeta.Cluster.ID,
+		cloneGCHoldName(clusterMeta.Cluster.ID), node.ParentID, meta.DefaultMaxGCLifeTime, meta.CloneGCHoldTTL)
 	if err != nil {
 		return err
 	}
+	node.Record(fmt.Sprintf("hold tidb_gc_life_time of source cluster %s to %s", sourceClusterMeta.Cluster.ID, meta.DefaultMaxGCLifeTime))
 
-	if err = context.SetData(ContextGCLifeTime, GCLifeTime.String); err != nil {
+	if err = context.SetData(ContextGCLifeTime, baseline); err != nil {
 		return err
 	}
 
@@ -1191,6 +1205,11 @@ func recoverSourceClusterGCTime(node *workflowModel.WorkFlowNode, context *workf
 	if err != nil {
 		return err
 	}
+	var clusterMeta meta.ClusterMeta
+	err = context.GetData(ContextClusterMeta, &clusterMeta)
+	if err != nil {
+		return err
+	}
 	var gcLifeTime string
 	err = context.GetData(ContextGCLifeTime, &gcLifeTime)
 	if err != nil {
@@ -1202,20 +1221,24 @@ func recoverSourceClusterGCTime(node *workflowModel.WorkFlowNode, context *workf
 		return nil
 	}
 
-	db, err := meta.CreateSQLLink(context.Context, &sourceClusterMeta)
-	if err != nil {
-		return errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
-	}
-	defer db.Close()
-
-	_, err = db.ExecContext(context.Context, "set global tidb_gc_life_time=?;", gcLifeTime)
+	err = gc.GetGCService().Release(context.Context, sourceClusterMeta.Cluster.ID, cloneGCHoldName(clusterMeta.Cluster.ID))
 	if err != nil {
+		// the hold has expired and been removed by gc reconciler
+		if emErr, ok := err.(errors.EMError); ok && emErr.GetCode() == errors.TIUNIMANAGER_GC_HOLD_NOT_FOUND {
+			framework.LogWithContext(context.Context).Warnf(
+				"gc hold of cluster %s not found, err = %s", sourceClusterMeta.Cluster.ID, err.Error())
+			return nil
+		}
 		return err
 	}
 
 	return nil
 }
 
+func cloneGCHoldName(targetClusterID string) string {
+	return "clone-" + targetClusterID
+}
+
 func syncIncrData(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
 	var sourceClusterMeta meta.ClusterMeta
 	err := context.GetData(ContextSourceClusterMeta, &sourceClusterMeta)
@@ -1251,18 +1274,30 @@ func syncIncrData(node *workflowModel.WorkFlowNode, context *workflow.FlowContex
 	if err != nil {
 		return err
 	}
+	// a clone of standby is a cascade of the replication chain
+	relationType := constants.ClusterRelationStandBy
+	masters, err := models.GetClusterReaderWriter().GetMasters(context.Context, sourceClusterMeta.Cluster.ID)
+	if err != nil {
+		return err
+	}
+	for _, master := range masters {
+		if master.RelationType.IsOneWay() {
+			relationType = constants.ClusterRelationCascade
+		}
+	}
+
 	// create cdc sync and wait for syncing ready
 	taskID, err := changefeed.GetChangeFeedService().CreateBetweenClusters(context.Context,
-		sourceClusterMeta.Cluster.ID, clusterMeta.Cluster.ID, int64(record.BackupTso), constants.ClusterRelationStandBy)
+		sourceClusterMeta.Cluster.ID, clusterMeta.Cluster.ID, int64(record.BackupTso), relationType)
 	if err != nil {
 		return err
 	}
 
-	// create standby relation
+	// create standby or cascade relation
 	if err := models.GetClusterReaderWriter().CreateRelation(context.Context, &management.ClusterRelation{
 		ObjectClusterID:      clusterMeta.Cluster.ID,
 		SubjectClusterID:     sourceClusterMeta.Cluster.ID,
-		RelationType:         constants.ClusterRelationStandBy,
+		RelationType:         relationType,
 		SyncChangeFeedTaskID: taskID,
 	}); err != nil {
 		return err
@@ -1301,8 +1336,14 @@ func syncIncrData(node *workflowModel.WorkFlowNode, context *workflow.FlowContex
 }
 
 func getClusterSpaceInTiUP(ctx context.Context, clusterID string) string {
-	tiupHome := util.GetTiUPHomeForComponent(ctx, deployment.TiUPComponentTypeCluster)
-	return fmt.Sprintf("%s/storage/cluster/clusters/%s/", tiupHome, clusterID)
+	return getComponentClusterSpaceInTiUP(ctx, deployment.TiUPComponentTypeCluster, clusterID)
+}
+
+// getComponentClusterSpaceInTiUP
+// @Description: get the working space of cluster managed by the TiUP component, e.g. tiup cluster or tiup dm
+func getComponentClusterSpaceInTiUP(ctx context.Context, componentType deployment.TiUPComponentType, clusterID string) string {
+	tiupHome := util.GetTiUPHomeForComponent(ctx, componentType)
+	return fmt.Sprintf("%s/storage/%s/clusters/%s/", tiupHome, componentType, clusterID)
 }
 
 // syncConnectionKey
@@ -1349,7 +1390,7 @@ func syncTopology(node *workflowModel.WorkFlowNode, context *workflow.FlowContex
 		return err
 	}
 	metaYaml, err := readTiUPFile(context,
-		getClusterSpaceInTiUP(context, clusterMeta.Cluster.ID),
+		getComponentClusterSpaceInTiUP(context, clusterMeta.GetTiUPComponentType(), clusterMeta.Cluster.ID),
 		"meta.yaml")
 	if err != nil {
 		err = errors.NewErrorf(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, "read meta.yaml failed for cluster %s, err = %s", clusterMeta.Cluster.ID, err)
@@ -1391,7 +1432,7 @@ func stopCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowContext
 	framework.LogWithContext(context.Context).Infof(
 		"stop cluster %s, version = %s", cluster.ID, cluster.Version)
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
-	operationID, err := deployment.M.Stop(context.Context, deployment.TiUPComponentTypeCluster, cluster.ID,
+	operationID, err := deployment.M.Stop(context.Context, clusterMeta.GetTiUPComponentType(), cluster.ID,
 		tiupHomeForTidb, node.ParentID, []string{}, meta.DefaultTiupTimeOut)
 
 	if err != nil {
@@ -1425,7 +1466,7 @@ func destroyCluster(node *workflowModel.WorkFlowNode, context *workflow.FlowCont
 	}
 
 	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
-	operationID, err := deployment.M.Destroy(context.Context, deployment.TiUPComponentTypeCluster, cluster.ID,
+	operationID, err := deployment.M.Destroy(context.Context, clusterMeta.GetTiUPComponentType(), cluster.ID,
 		tiupHomeForTidb, node.ParentID, []string{}, meta.DefaultTiupTimeOut)
 
 	if err != nil {
@@ -1478,6 +1519,47 @@ func clearCDCLinks(node *workflowModel.WorkFlowNode, context *workflow.FlowConte
 	return nil
 }
 
+// clearDMTasks
+// @Description: mark unfinished tasks of deleted DM cluster as stopped and delete its sources
+func clearDMTasks(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	err := context.GetData(ContextClusterMeta, &clusterMeta)
+	if err != nil {
+		return err
+	}
+	dmRW := models.GetDMReaderWriter()
+	tasks, _, err := dmRW.QueryTasks(context, clusterMeta.Cluster.ID, constants.UnfinishedDMTaskStatus(), 0, 0)
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf(
+			"query dm tasks of cluster %s error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	for _, task := range tasks {
+		if err = dmRW.UpdateStatus(context, task.ID, constants.DMTaskStatusStopped); err != nil {
+			framework.LogWithContext(context.Context).Errorf(
+				"stop dm task %s of cluster %s error: %s", task.Name, clusterMeta.Cluster.ID, err.Error())
+			return err
+		}
+		node.Record(fmt.Sprintf("stop dm task %s, status = %s", task.Name, task.Status))
+	}
+
+	sources, err := dmRW.QuerySources(context, clusterMeta.Cluster.ID)
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf(
+			"query dm sources of cluster %s error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	for _, source := range sources {
+		if err = dmRW.DeleteSource(context, clusterMeta.Cluster.ID, source.Name); err != nil {
+			framework.LogWithContext(context.Context).Errorf(
+				"delete dm source %s of cluster %s error: %s", source.Name, clusterMeta.Cluster.ID, err.Error())
+			return err
+		}
+		node.Record(fmt.Sprintf("delete dm source %s", source.Name))
+	}
+	return nil
+}
+
 // takeoverRevertMeta
 // @Description: delete cluster physically, If you don't know why you should use it, then don't use it
 func takeoverRevertMeta(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
@@ -1865,7 +1947,7 @@ func fetchTopologyFile(node *workflowModel.WorkFlowNode, context *workflow.FlowC
 	if err != nil {
 		return err
 	}
-	clusterHome := fmt.Sprintf("%s/storage/cluster/clusters/%s/", req.TiUPPath, clusterMeta.Cluster.ID)
+	clusterHome := fmt.Sprintf("%s/storage/%s/clusters/%s/", req.TiUPPath, req.GetTiUPComponent(), clusterMeta.Cluster.ID)
 
 	node.Record("try to get meta.yaml from " + clusterHome)
 
@@ -2044,18 +2126,40 @@ func rebuildTopologyFromConfig(node *workflowModel.WorkFlowNode, context *workfl
 		return err
 	}
 
-	metadata := &spec.ClusterMeta{}
-	err = yaml.Unmarshal(dataByte, metadata)
+	var req cluster.TakeoverClusterReq
+	err = context.GetData(ContextTakeoverRequest, &req)
 	if err != nil {
-		err = errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, "rebuild topology config failed", err)
 		return err
 	}
 
-	clusterMeta.Cluster.Type = string(constants.EMProductIDTiDB)
-	clusterMeta.Cluster.Version = metadata.Version
-	clusterSpec := metadata.GetTopology().(*spec.Specification)
-	clusterMeta.Cluster.CpuArchitecture = constants.ConvertAlias(clusterSpec.GlobalOptions.Arch)
-	err = clusterMeta.ParseTopologyFromConfig(context, clusterSpec)
+	if req.IsDM() {
+		metadata := &dmSpec.Metadata{}
+		err = yaml.Unmarshal(dataByte, metadata)
+		if err != nil {
+			err = errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, "rebuild dm topology config failed", err)
+			return err
+		}
+		if metadata.Topology == nil {
+			return errors.NewError(errors.TIUNIMANAGER_INVALID_TOPOLOGY, "dm topology is empty")
+		}
+		clusterMeta.Cluster.Type = string(constants.EMProductIDDataMigration)
+		clusterMeta.Cluster.Version = metadata.Version
+		clusterMeta.Cluster.CpuArchitecture = constants.ConvertAlias(metadata.Topology.GlobalOptions.Arch)
+		err = clusterMeta.ParseDMTopologyFromConfig(context, metadata.Topology)
+	} else {
+		metadata := &spec.ClusterMeta{}
+		err = yaml.Unmarshal(dataByte, metadata)
+		if err != nil {
+			err = errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, "rebuild topology config failed", err)
+			return err
+		}
+
+		clusterMeta.Cluster.Type = string(constants.EMProductIDTiDB)
+		clusterMeta.Cluster.Version = metadata.Version
+		clusterSpec := metadata.GetTopology().(*spec.Specification)
+		clusterMeta.Cluster.CpuArchitecture = constants.ConvertAlias(clusterSpec.GlobalOptions.Arch)
+		err = clusterMeta.ParseTopologyFromConfig(context, clusterSpec)
+	}
 	if err != nil {
 		framework.LogWithContext(context).Errorf(
 			"add instances into cluster %s topology error: %s", clusterMeta.Cluster.ID, err.Error())
@@ -2082,7 +2186,7 @@ func rebuildTiupSpaceForCluster(node *workflowModel.WorkFlowNode, context *workf
 		return err
 	}
 
-	home := getClusterSpaceInTiUP(context, clusterMeta.Cluster.ID)
+	home := getComponentClusterSpaceInTiUP(context, clusterMeta.GetTiUPComponentType(), clusterMeta.Cluster.ID)
 	err = os.MkdirAll(home+"ssh", 0750)
 	if err != nil {
 		framework.LogWithContext(context).Errorf("mkdir for cluster %s failed, err = %s", clusterMeta.Cluster.ID, err.Error())
@@ -27,7 +27,9 @@ import (
 
 	"github.com/pingcap/tiunimanager/deployment"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/gc"
 	"github.com/pingcap/tiunimanager/test/mockchangefeed"
+	"github.com/pingcap/tiunimanager/test/mockgc"
 	mock_product "github.com/pingcap/tiunimanager/test/mockmodels"
 
 	"reflect"
@@ -58,6 +60,7 @@ import (
 	resourceManagement "github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management"
 	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management/structs"
 	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/dm"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
 	"github.com/pingcap/tiunimanager/models/cluster/parameter"
 	"github.com/pingcap/tiunimanager/models/common"
@@ -67,9 +70,11 @@ import (
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockclusterparameter"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockdm"
 	mock_allocator_recycler "github.com/pingcap/tiunimanager/test/mockresource"
 	mock_workflow_service "github.com/pingcap/tiunimanager/test/mockworkflow"
 	workflow "github.com/pingcap/tiunimanager/workflow2"
+	dmSpec "github.com/pingcap/tiup/components/dm/spec"
 	"github.com/pingcap/tiup/pkg/cluster/spec"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
@@ -744,6 +749,38 @@ func TestRestoreNewCluster(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("point in time", func(t *testing.T) {
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, &meta.ClusterMeta{
+			Cluster: &management.Cluster{
+				Entity: common.Entity{
+					ID: "testCluster",
+				},
+			},
+		})
+		flowContext.SetData(ContextBackupID, "")
+		flowContext.SetData(ContextPointInTime, cluster.PointInTimeRestoreTarget{
+			SourceClusterID: "sourceCluster",
+			RestoreTime:     1654041600,
+		})
+		brService := mock_br_service.NewMockBRService(ctrl)
+		backuprestore.MockBRService(brService)
+		brService.EXPECT().RestoreExistCluster(gomock.Any(), cluster.RestoreExistClusterReq{
+			ClusterID: "testCluster",
+			PointInTime: cluster.PointInTimeRestoreTarget{
+				SourceClusterID: "sourceCluster",
+				RestoreTime:     1654041600,
+			},
+		}, false).Return(
+			cluster.RestoreExistClusterResp{
+				AsyncTaskWorkFlowInfo: structs2.AsyncTaskWorkFlowInfo{
+					WorkFlowID: "111",
+				},
+			}, nil)
+		err := restoreNewCluster(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+
 	t.Run("no backup id", func(t *testing.T) {
 		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
 		flowContext.SetData(ContextClusterMeta, &meta.ClusterMeta{
@@ -1560,6 +1597,42 @@ func TestClearCDCLinks(t *testing.T) {
 	})
 }
 
+func TestClearDMTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dmRW := mockdm.NewMockReaderWriter(ctrl)
+	models.SetDMReaderWriter(dmRW)
+
+	flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+	flowContext.SetData(ContextClusterMeta, &meta.ClusterMeta{
+		Cluster: &management.Cluster{Entity: common.Entity{ID: "dm01"}},
+	})
+
+	t.Run("normal", func(t *testing.T) {
+		dmRW.EXPECT().QueryTasks(gomock.Any(), "dm01", constants.UnfinishedDMTaskStatus(), 0, 0).Return([]*dm.DMTask{
+			{Entity: common.Entity{ID: "task01", Status: string(constants.DMTaskStatusRunning)}, Name: "t1"},
+			{Entity: common.Entity{ID: "task02", Status: string(constants.DMTaskStatusError)}, Name: "t2"},
+		}, int64(2), nil)
+		dmRW.EXPECT().UpdateStatus(gomock.Any(), "task01", constants.DMTaskStatusStopped).Return(nil)
+		dmRW.EXPECT().UpdateStatus(gomock.Any(), "task02", constants.DMTaskStatusStopped).Return(nil)
+		dmRW.EXPECT().QuerySources(gomock.Any(), "dm01").Return([]*dm.DMSource{{Name: "s1"}}, nil)
+		dmRW.EXPECT().DeleteSource(gomock.Any(), "dm01", "s1").Return(nil)
+
+		err := clearDMTasks(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("stop failed", func(t *testing.T) {
+		dmRW.EXPECT().QueryTasks(gomock.Any(), "dm01", constants.UnfinishedDMTaskStatus(), 0, 0).Return([]*dm.DMTask{
+			{Entity: common.Entity{ID: "task01", Status: string(constants.DMTaskStatusRunning)}, Name: "t1"},
+		}, int64(1), nil)
+		dmRW.EXPECT().UpdateStatus(gomock.Any(), "task01", constants.DMTaskStatusStopped).Return(errors.Error(errors.TIUNIMANAGER_DM_TASK_NOT_FOUND))
+
+		err := clearDMTasks(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+	})
+}
+
 func TestDeleteClusterPhysically(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1989,6 +2062,41 @@ func Test_testRebuildTopologyFromConfig(t *testing.T) {
 		assert.NotEmpty(t, clusterMeta.Instances)
 
 	})
+	t.Run("dm", func(t *testing.T) {
+		ctx := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		clusterMeta := &meta.ClusterMeta{
+			Cluster: &management.Cluster{
+				Entity: common.Entity{
+					ID:       "clusterId",
+					TenantId: "tenantId",
+				},
+			},
+		}
+		ctx.SetData(ContextClusterMeta, clusterMeta)
+		ctx.SetData(ContextTakeoverRequest, cluster.TakeoverClusterReq{TiUPComponent: "dm"})
+
+		metadata := &dmSpec.Metadata{
+			Version: "v5.3.0",
+			Topology: &dmSpec.Specification{
+				Masters: []*dmSpec.MasterSpec{
+					{Host: "127.0.0.1", Port: 8261, PeerPort: 8291},
+				},
+				Workers: []*dmSpec.WorkerSpec{
+					{Host: "127.0.0.2", Port: 8262},
+				},
+			},
+		}
+		bytes, err := yaml.Marshal(metadata)
+		assert.NoError(t, err)
+		ctx.SetData(ContextTopologyConfig, bytes)
+
+		err = rebuildTopologyFromConfig(&workflowModel.WorkFlowNode{}, ctx)
+		ctx.GetData(ContextClusterMeta, &clusterMeta)
+		assert.NoError(t, err)
+		assert.Equal(t, "v5.3.0", clusterMeta.Cluster.Version)
+		assert.Equal(t, string(constants.EMProductIDDataMigration), clusterMeta.Cluster.Type)
+		assert.Equal(t, 1, len(clusterMeta.Instances[string(constants.ComponentIDDMWorker)]))
+	})
 }
 
 func TestTakeoverResource(t *testing.T) {
@@ -2394,6 +2502,93 @@ func Test_validateHostsStatus(t *testing.T) {
 
 }
 
+func Test_modifySourceClusterGCTime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+	flowContext.SetData(ContextClusterMeta, &meta.ClusterMeta{
+		Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster01"}},
+	})
+	flowContext.SetData(ContextSourceClusterMeta, &meta.ClusterMeta{
+		Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster02"}},
+	})
+
+	t.Run("topology clone", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		flowContext.SetData(ContextCloneStrategy, string(constants.ClusterTopologyClone))
+		err := modifySourceClusterGCTime(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("normal", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		service.EXPECT().Hold(gomock.Any(), "cluster02", "clone-cluster01", "flow01",
+			meta.DefaultMaxGCLifeTime, meta.CloneGCHoldTTL).Return("10m0s", nil)
+		flowContext.SetData(ContextCloneStrategy, string(constants.CDCSyncClone))
+		err := modifySourceClusterGCTime(&workflowModel.WorkFlowNode{ParentID: "flow01"}, flowContext)
+		assert.NoError(t, err)
+		var gcLifeTime string
+		assert.NoError(t, flowContext.GetData(ContextGCLifeTime, &gcLifeTime))
+		assert.Equal(t, "10m0s", gcLifeTime)
+	})
+	t.Run("error", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		service.EXPECT().Hold(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any(), gomock.Any()).Return("", errors.Error(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR))
+		flowContext.SetData(ContextCloneStrategy, string(constants.SnapShotClone))
+		err := modifySourceClusterGCTime(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+	})
+}
+
+func Test_recoverSourceClusterGCTime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+	flowContext.SetData(ContextClusterMeta, &meta.ClusterMeta{
+		Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster01"}},
+	})
+	flowContext.SetData(ContextSourceClusterMeta, &meta.ClusterMeta{
+		Cluster: &management.Cluster{Entity: common.Entity{ID: "cluster02"}},
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		flowContext.SetData(ContextGCLifeTime, "")
+		err := recoverSourceClusterGCTime(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("normal", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		service.EXPECT().Release(gomock.Any(), "cluster02", "clone-cluster01").Return(nil)
+		flowContext.SetData(ContextGCLifeTime, "10m0s")
+		err := recoverSourceClusterGCTime(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("expired", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		service.EXPECT().Release(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.Error(errors.TIUNIMANAGER_GC_HOLD_NOT_FOUND))
+		flowContext.SetData(ContextGCLifeTime, "10m0s")
+		err := recoverSourceClusterGCTime(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("error", func(t *testing.T) {
+		service := mockgc.NewMockService(ctrl)
+		gc.MockGCService(service)
+		service.EXPECT().Release(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.Error(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR))
+		flowContext.SetData(ContextGCLifeTime, "10m0s")
+		err := recoverSourceClusterGCTime(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+	})
+}
+
 func Test_syncIncrData(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -2429,7 +2624,41 @@ func Test_syncIncrData(t *testing.T) {
 		rw := mockclustermanagement.NewMockReaderWriter(ctrl)
 		models.SetClusterReaderWriter(rw)
 
-		rw.EXPECT().CreateRelation(gomock.Any(), gomock.Any()).Return(nil)
+		rw.EXPECT().GetMasters(gomock.Any(), "cluster02").Return([]*management.ClusterRelation{}, nil)
+		rw.EXPECT().CreateRelation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, relation *management.ClusterRelation) error {
+			assert.Equal(t, constants.ClusterRelationStandBy, relation.RelationType)
+			return nil
+		})
+		flowContext.SetData(ContextGCLifeTime, "10m0s")
+		service.EXPECT().Detail(gomock.Any(), gomock.Any()).Return(
+			cluster.DetailChangeFeedTaskResp{
+				ChangeFeedTaskInfo: cluster.ChangeFeedTaskInfo{
+					UpstreamUpdateUnix: 12000,
+					DownstreamSyncUnix: 11000,
+				}}, nil)
+		err := syncIncrData(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("clone of standby", func(t *testing.T) {
+		service := mockchangefeed.NewMockService(ctrl)
+		changefeed.MockChangeFeedService(service)
+
+		service.EXPECT().CreateBetweenClusters(gomock.Any(), "cluster02", "cluster01",
+			int64(123), constants.ClusterRelationCascade).Return("task01", nil)
+		backupRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(backupRW)
+		backupRW.EXPECT().GetBackupRecord(gomock.Any(), gomock.Any()).Return(&backuprestore2.BackupRecord{BackupTso: 123}, nil)
+
+		rw := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(rw)
+
+		rw.EXPECT().GetMasters(gomock.Any(), "cluster02").Return([]*management.ClusterRelation{
+			{SubjectClusterID: "cluster03", ObjectClusterID: "cluster02", RelationType: constants.ClusterRelationStandBy},
+		}, nil)
+		rw.EXPECT().CreateRelation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, relation *management.ClusterRelation) error {
+			assert.Equal(t, constants.ClusterRelationCascade, relation.RelationType)
+			return nil
+		})
 		flowContext.SetData(ContextGCLifeTime, "10m0s")
 		service.EXPECT().Detail(gomock.Any(), gomock.Any()).Return(
 			cluster.DetailChangeFeedTaskResp{
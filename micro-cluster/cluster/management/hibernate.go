@@ -0,0 +1,289 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	resourceManagement "github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management"
+	resourceStructs "github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management/structs"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+)
+
+// hibernateClusterFlow stop the cluster, then remove stateless instances from the topology and release their compute resource,
+// the instances are kept in the metadata with status Hibernated, so that they can be rebuilt when the cluster wakes up
+var hibernateClusterFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowHibernateCluster,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":       {"clusterStop", "stopDone", "fail", workflow.PollingNode, stopCluster},
+		"stopDone":    {"setClusterOffline", "offlineDone", "fail", workflow.SyncFuncNode, setClusterOffline},
+		"offlineDone": {"scaleInStatelessInstances", "scaleInDone", "fail", workflow.PollingNode, scaleInStatelessInstances},
+		"scaleInDone": {"freeStatelessResource", "freeDone", "fail", workflow.SyncFuncNode, freeStatelessResource},
+		"freeDone":    {"syncTopology", "syncDone", "fail", workflow.SyncFuncNode, syncTopology},
+		"syncDone":    {"end", "", "fail", workflow.SyncFuncNode, workflow.CompositeExecutor(setClusterHibernated, persistCluster, endMaintenance)},
+		"fail":        {"fail", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(setClusterFailure, endMaintenance)},
+	},
+}
+
+// wakeClusterFlow alloc compute resource for hibernated instances, possibly on different hosts,
+// then start the cluster and scale out these instances
+var wakeClusterFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowWakeCluster,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":            {"prepareResource", "resourceDone", "revert", workflow.SyncFuncNode, prepareResource},
+		"resourceDone":     {"buildConfig", "configDone", "revert", workflow.SyncFuncNode, buildConfig},
+		"configDone":       {"startCluster", "startDone", "revert", workflow.PollingNode, startCluster},
+		"startDone":        {"scaleOutCluster", "scaleOutDone", "revert", workflow.PollingNode, scaleOutCluster},
+		"scaleOutDone":     {"syncTopology", "syncTopologyDone", "failAfterScale", workflow.SyncFuncNode, syncTopology},
+		"syncTopologyDone": {"setClusterOnline", "onlineDone", "failAfterScale", workflow.SyncFuncNode, setClusterOnline},
+		"onlineDone":       {"end", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(persistCluster, endMaintenance, asyncBuildLog)},
+		"revert":           {"revert", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(revertResourceAfterFailure, endMaintenance)},
+		"failAfterScale":   {"failAfterScale", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(persistCluster, setClusterFailure, endMaintenance)},
+	},
+}
+
+// HibernateCluster
+// @Description: stop a cluster and release compute resource of its stateless components, storage of PD and TiKV is kept
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) HibernateCluster(ctx context.Context, req cluster.HibernateClusterReq) (resp cluster.HibernateClusterResp, err error) {
+	clusterMeta, err := meta.Get(ctx, req.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"load cluster %s meta from db error: %s", req.ClusterID, err.Error())
+		return
+	}
+
+	if clusterMeta.Cluster.Status == string(constants.ClusterHibernated) {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_HIBERNATED, "cluster %s is already hibernated", req.ClusterID)
+		return
+	}
+	if clusterMeta.Cluster.Type != string(constants.EMProductIDTiDB) {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_UNSUPPORT_PRODUCT, "hibernating %s cluster is not supported", clusterMeta.Cluster.Type)
+		return
+	}
+	if len(getStatelessInstances(clusterMeta)) == 0 {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_INSTANCE_NOT_FOUND, "cluster %s has no stateless instance to hibernate", req.ClusterID)
+		return
+	}
+
+	data := map[string]interface{}{
+		ContextClusterMeta: clusterMeta,
+	}
+	flowID, err := asyncMaintenance(ctx, clusterMeta, constants.ClusterMaintenanceHibernating, hibernateClusterFlow.FlowName, data)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"cluster %s async maintenance error: %s", clusterMeta.Cluster.ID, err.Error())
+		return
+	}
+
+	resp.ClusterID = clusterMeta.Cluster.ID
+	resp.WorkFlowID = flowID
+	return
+}
+
+// WakeCluster
+// @Description: alloc compute resource for a hibernated cluster, rebuild its topology and start it
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) WakeCluster(ctx context.Context, req cluster.WakeClusterReq) (resp cluster.WakeClusterResp, err error) {
+	clusterMeta, err := meta.Get(ctx, req.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"load cluster %s meta from db error: %s", req.ClusterID, err.Error())
+		return
+	}
+
+	if clusterMeta.Cluster.Status != string(constants.ClusterHibernated) {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_NOT_HIBERNATED, "cluster %s is %s", req.ClusterID, clusterMeta.Cluster.Status)
+		return
+	}
+
+	// hibernated instances will be allocated again as new instances, hosts are chosen by zone
+	for _, instance := range clusterMeta.GetInstanceByStatus(ctx, constants.ClusterInstanceHibernated) {
+		instance.Status = string(constants.ClusterInstanceInitializing)
+		instance.HostID = ""
+		instance.HostIP = []string{}
+		instance.Ports = []int32{}
+		instance.DiskID = ""
+		instance.DiskPath = ""
+		instance.Rack = ""
+	}
+
+	data := map[string]interface{}{
+		ContextClusterMeta: clusterMeta,
+	}
+	flowID, err := asyncMaintenance(ctx, clusterMeta, constants.ClusterMaintenanceWaking, wakeClusterFlow.FlowName, data)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"cluster %s async maintenance error: %s", clusterMeta.Cluster.ID, err.Error())
+		return
+	}
+
+	resp.ClusterID = clusterMeta.Cluster.ID
+	resp.WorkFlowID = flowID
+	return
+}
+
+// getStatelessInstances
+// @Description: get instances of components in constants.HibernateComponentIDs, which have not been hibernated
+// @Parameter clusterMeta
+// @return []*management.ClusterInstance
+func getStatelessInstances(clusterMeta *meta.ClusterMeta) []*management.ClusterInstance {
+	instances := make([]*management.ClusterInstance, 0)
+	for _, componentType := range constants.HibernateComponentIDs {
+		for _, instance := range clusterMeta.Instances[string(componentType)] {
+			if instance.Status == string(constants.ClusterInstanceHibernated) {
+				continue
+			}
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// scaleInStatelessInstances
+// @Description: execute command, scale in all stateless instances of the stopped cluster
+func scaleInStatelessInstances(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	err := context.GetData(ContextClusterMeta, &clusterMeta)
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]string, 0)
+	for _, instance := range getStatelessInstances(&clusterMeta) {
+		if len(instance.HostIP) == 0 || len(instance.Ports) == 0 {
+			continue
+		}
+		nodes = append(nodes, strings.Join([]string{instance.HostIP[0], strconv.Itoa(int(instance.Ports[0]))}, ":"))
+	}
+	if len(nodes) == 0 {
+		node.Record(fmt.Sprintf("no stateless instance of cluster %s need to be scaled in ", clusterMeta.Cluster.ID))
+		return nil
+	}
+
+	framework.LogWithContext(context.Context).Infof(
+		"hibernate cluster %s, scale in instances %v", clusterMeta.Cluster.ID, nodes)
+	tiupHomeForTidb := framework.GetTiupHomePathForTidb()
+	operationID, err := deployment.M.ScaleIn(context.Context, deployment.TiUPComponentTypeCluster, clusterMeta.Cluster.ID,
+		strings.Join(nodes, ","), tiupHomeForTidb, node.ParentID, []string{}, meta.DefaultTiupTimeOut)
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf(
+			"cluster %s scale in error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	framework.LogWithContext(context.Context).Infof(
+		"get scale in cluster %s operation id: %s", clusterMeta.Cluster.ID, operationID)
+
+	node.Record(fmt.Sprintf("scale in stateless instances %s of cluster %s ", strings.Join(nodes, ", "), clusterMeta.Cluster.ID))
+	node.OperationID = operationID
+	return nil
+}
+
+// freeStatelessResource
+// @Description: recycle compute resource of stateless instances, and set them hibernated
+func freeStatelessResource(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	err := context.GetData(ContextClusterMeta, &clusterMeta)
+	if err != nil {
+		return err
+	}
+
+	instances := getStatelessInstances(&clusterMeta)
+	request := &resourceStructs.RecycleRequest{
+		RecycleReqs: make([]resourceStructs.RecycleRequire, 0),
+	}
+	for _, instance := range instances {
+		if len(instance.HostID) == 0 {
+			continue
+		}
+		request.RecycleReqs = append(request.RecycleReqs, resourceStructs.RecycleRequire{
+			RecycleType: resourceStructs.RecycleHost,
+			HolderID:    instance.ClusterID,
+			HostID:      instance.HostID,
+			ComputeReq: resourceStructs.ComputeRequirement{
+				ComputeResource: resourceStructs.ComputeResource{
+					CpuCores: int32(instance.CpuCores),
+					Memory:   int32(instance.Memory),
+				},
+			},
+			DiskReq: []resourceStructs.DiskResource{
+				{
+					DiskId: instance.DiskID,
+				},
+			},
+			PortReq: []resourceStructs.PortResource{
+				{
+					Ports: instance.Ports,
+				},
+			},
+		})
+	}
+
+	if len(request.RecycleReqs) > 0 {
+		err = resourceManagement.GetManagement().GetAllocatorRecycler().RecycleResources(context, request)
+		if err != nil {
+			framework.LogWithContext(context.Context).Errorf(
+				"cluster %s recycle stateless resource error: %s", clusterMeta.Cluster.ID, err.Error())
+			return err
+		}
+	}
+
+	for _, instance := range instances {
+		instance.Status = string(constants.ClusterInstanceHibernated)
+		node.Record(fmt.Sprintf("type: %s, zone: %s, host IP: %s; ", instance.Type, instance.Zone, strings.Join(instance.HostIP, ", ")))
+	}
+	context.SetData(ContextClusterMeta, &clusterMeta)
+	node.Record(fmt.Sprintf("cluster %s recycle resource of %d stateless instances ", clusterMeta.Cluster.ID, len(instances)))
+	return nil
+}
+
+// setClusterHibernated
+// @Description: set cluster running status to constants.ClusterHibernated
+func setClusterHibernated(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	err := context.GetData(ContextClusterMeta, &clusterMeta)
+	if err != nil {
+		return err
+	}
+
+	if err := clusterMeta.UpdateClusterStatus(context.Context, constants.ClusterHibernated); err != nil {
+		framework.LogWithContext(context.Context).Errorf(
+			"update cluster %s status into hibernated error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	context.SetData(ContextClusterMeta, &clusterMeta)
+	node.Record(fmt.Sprintf("set cluster %s status into %v ", clusterMeta.Cluster.ID, constants.ClusterHibernated))
+	return nil
+}
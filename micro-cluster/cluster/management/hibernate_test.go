@@ -0,0 +1,304 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	em_errors "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	resourceManagement "github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management/structs"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	mock_deployment "github.com/pingcap/tiunimanager/test/mockdeployment"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	mock_allocator_recycler "github.com/pingcap/tiunimanager/test/mockresource"
+	mock_workflow_service "github.com/pingcap/tiunimanager/test/mockworkflow"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHibernateClusterMeta(status constants.ClusterRunningStatus, tidbStatus constants.ClusterInstanceRunningStatus) *meta.ClusterMeta {
+	return &meta.ClusterMeta{
+		Cluster: &management.Cluster{
+			Entity: common.Entity{
+				ID:     "cluster01",
+				Status: string(status),
+			},
+			Type:    string(constants.EMProductIDTiDB),
+			Version: "v5.2.2",
+		},
+		Instances: map[string][]*management.ClusterInstance{
+			string(constants.ComponentIDTiDB): {
+				{
+					Entity:    common.Entity{ID: "tidb01", Status: string(tidbStatus)},
+					Type:      string(constants.ComponentIDTiDB),
+					ClusterID: "cluster01",
+					HostID:    "host01",
+					HostIP:    []string{"127.0.0.1"},
+					Ports:     []int32{4000, 10080},
+					CpuCores:  4,
+					Memory:    8,
+					DiskID:    "disk01",
+					Zone:      "zone1",
+				},
+				{
+					Entity:    common.Entity{ID: "tidb02", Status: string(tidbStatus)},
+					Type:      string(constants.ComponentIDTiDB),
+					ClusterID: "cluster01",
+					HostID:    "host02",
+					HostIP:    []string{"127.0.0.2"},
+					Ports:     []int32{4000, 10080},
+					CpuCores:  4,
+					Memory:    8,
+					DiskID:    "disk02",
+					Zone:      "zone1",
+				},
+			},
+			string(constants.ComponentIDTiKV): {
+				{
+					Entity:    common.Entity{ID: "tikv01", Status: string(constants.ClusterInstanceStopped)},
+					Type:      string(constants.ComponentIDTiKV),
+					ClusterID: "cluster01",
+					HostID:    "host03",
+					HostIP:    []string{"127.0.0.3"},
+					Ports:     []int32{20160, 20180},
+				},
+			},
+		},
+	}
+}
+
+func mockGetMeta(clusterRW *mockclustermanagement.MockReaderWriter, clusterMeta *meta.ClusterMeta) {
+	instances := make([]*management.ClusterInstance, 0)
+	for _, components := range clusterMeta.Instances {
+		instances = append(instances, components...)
+	}
+	clusterRW.EXPECT().GetMeta(gomock.Any(), clusterMeta.Cluster.ID).Return(clusterMeta.Cluster, instances, make([]*management.DBUser, 0), nil)
+}
+
+func TestManager_HibernateCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := Manager{}
+	t.Run("normal", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		mockGetMeta(clusterRW, mockHibernateClusterMeta(constants.ClusterRunning, constants.ClusterInstanceRunning))
+		clusterRW.EXPECT().SetMaintenanceStatus(gomock.Any(), "cluster01", constants.ClusterMaintenanceHibernating).Return(nil)
+
+		workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+		workflow.MockWorkFlowService(workflowService)
+		defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), "cluster01", gomock.Any(), constants.FlowHibernateCluster).Return("flow01", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		workflowService.EXPECT().Start(gomock.Any(), "flow01").Return(nil)
+
+		resp, err := manager.HibernateCluster(context.TODO(), cluster.HibernateClusterReq{ClusterID: "cluster01"})
+		assert.NoError(t, err)
+		assert.Equal(t, "flow01", resp.WorkFlowID)
+	})
+	t.Run("not found", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(nil, nil, nil, fmt.Errorf("not found"))
+
+		_, err := manager.HibernateCluster(context.TODO(), cluster.HibernateClusterReq{ClusterID: "cluster01"})
+		assert.Error(t, err)
+	})
+	t.Run("hibernated", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		mockGetMeta(clusterRW, mockHibernateClusterMeta(constants.ClusterHibernated, constants.ClusterInstanceHibernated))
+
+		_, err := manager.HibernateCluster(context.TODO(), cluster.HibernateClusterReq{ClusterID: "cluster01"})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_HIBERNATED, err.(em_errors.EMError).GetCode())
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterMeta := mockHibernateClusterMeta(constants.ClusterRunning, constants.ClusterInstanceRunning)
+		clusterMeta.Cluster.Type = string(constants.EMProductIDDataMigration)
+		mockGetMeta(clusterRW, clusterMeta)
+
+		_, err := manager.HibernateCluster(context.TODO(), cluster.HibernateClusterReq{ClusterID: "cluster01"})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_UNSUPPORT_PRODUCT, err.(em_errors.EMError).GetCode())
+	})
+}
+
+func TestManager_WakeCluster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := Manager{}
+	t.Run("normal", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		mockGetMeta(clusterRW, mockHibernateClusterMeta(constants.ClusterHibernated, constants.ClusterInstanceHibernated))
+		clusterRW.EXPECT().SetMaintenanceStatus(gomock.Any(), "cluster01", constants.ClusterMaintenanceWaking).Return(nil)
+
+		workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+		workflow.MockWorkFlowService(workflowService)
+		defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), "cluster01", gomock.Any(), constants.FlowWakeCluster).Return("flow01", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), "flow01", ContextClusterMeta, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, flowId string, key string, value interface{}) error {
+				clusterMeta := value.(*meta.ClusterMeta)
+				for _, instance := range clusterMeta.Instances[string(constants.ComponentIDTiDB)] {
+					assert.Equal(t, string(constants.ClusterInstanceInitializing), instance.Status)
+					assert.Empty(t, instance.HostIP)
+					assert.Empty(t, instance.HostID)
+					assert.Empty(t, instance.Ports)
+				}
+				assert.Equal(t, "host03", clusterMeta.Instances[string(constants.ComponentIDTiKV)][0].HostID)
+				return nil
+			})
+		workflowService.EXPECT().Start(gomock.Any(), "flow01").Return(nil)
+
+		resp, err := manager.WakeCluster(context.TODO(), cluster.WakeClusterReq{ClusterID: "cluster01"})
+		assert.NoError(t, err)
+		assert.Equal(t, "flow01", resp.WorkFlowID)
+	})
+	t.Run("not hibernated", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		mockGetMeta(clusterRW, mockHibernateClusterMeta(constants.ClusterRunning, constants.ClusterInstanceRunning))
+
+		_, err := manager.WakeCluster(context.TODO(), cluster.WakeClusterReq{ClusterID: "cluster01"})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_NOT_HIBERNATED, err.(em_errors.EMError).GetCode())
+	})
+}
+
+func TestAsyncMaintenance_Hibernated(t *testing.T) {
+	clusterMeta := mockHibernateClusterMeta(constants.ClusterHibernated, constants.ClusterInstanceHibernated)
+	_, err := asyncMaintenance(context.TODO(), clusterMeta, constants.ClusterMaintenanceRestarting, constants.FlowRestartCluster, nil)
+	assert.Error(t, err)
+	assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_HIBERNATED, err.(em_errors.EMError).GetCode())
+}
+
+func TestScaleInStatelessInstances(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+		mockTiupManager.EXPECT().ScaleIn(gomock.Any(), gomock.Any(), "cluster01", "127.0.0.1:4000,127.0.0.2:4000",
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return("task01", nil)
+		deployment.M = mockTiupManager
+
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceStopped))
+		node := &workflowModel.WorkFlowNode{}
+		err := scaleInStatelessInstances(node, flowContext)
+		assert.NoError(t, err)
+		assert.Equal(t, "task01", node.OperationID)
+	})
+	t.Run("nothing to scale in", func(t *testing.T) {
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceHibernated))
+		node := &workflowModel.WorkFlowNode{}
+		err := scaleInStatelessInstances(node, flowContext)
+		assert.NoError(t, err)
+		assert.Empty(t, node.OperationID)
+	})
+	t.Run("scale in fail", func(t *testing.T) {
+		mockTiupManager := mock_deployment.NewMockInterface(ctrl)
+		mockTiupManager.EXPECT().ScaleIn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return("", fmt.Errorf("fail"))
+		deployment.M = mockTiupManager
+
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceStopped))
+		err := scaleInStatelessInstances(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+	})
+}
+
+func TestFreeStatelessResource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		resourceManager := mock_allocator_recycler.NewMockAllocatorRecycler(ctrl)
+		resourceManager.EXPECT().RecycleResources(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, request *structs.RecycleRequest) error {
+				assert.Len(t, request.RecycleReqs, 2)
+				assert.Equal(t, structs.RecycleHost, request.RecycleReqs[0].RecycleType)
+				assert.Equal(t, "host01", request.RecycleReqs[0].HostID)
+				assert.Equal(t, int32(4), request.RecycleReqs[0].ComputeReq.ComputeResource.CpuCores)
+				return nil
+			})
+		resourceManagement.GetManagement().SetAllocatorRecycler(resourceManager)
+
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceStopped))
+		err := freeStatelessResource(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+
+		var clusterMeta meta.ClusterMeta
+		flowContext.GetData(ContextClusterMeta, &clusterMeta)
+		for _, instance := range clusterMeta.Instances[string(constants.ComponentIDTiDB)] {
+			assert.Equal(t, string(constants.ClusterInstanceHibernated), instance.Status)
+		}
+		assert.Equal(t, string(constants.ClusterInstanceStopped), clusterMeta.Instances[string(constants.ComponentIDTiKV)][0].Status)
+	})
+	t.Run("recycle fail", func(t *testing.T) {
+		resourceManager := mock_allocator_recycler.NewMockAllocatorRecycler(ctrl)
+		resourceManager.EXPECT().RecycleResources(gomock.Any(), gomock.Any()).Return(fmt.Errorf("recycle fail"))
+		resourceManagement.GetManagement().SetAllocatorRecycler(resourceManager)
+
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceStopped))
+		err := freeStatelessResource(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+	})
+}
+
+func TestSetClusterHibernated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+
+	t.Run("normal", func(t *testing.T) {
+		clusterRW.EXPECT().UpdateStatus(gomock.Any(), "cluster01", constants.ClusterHibernated).Return(nil)
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceHibernated))
+		err := setClusterHibernated(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.NoError(t, err)
+	})
+	t.Run("update fail", func(t *testing.T) {
+		clusterRW.EXPECT().UpdateStatus(gomock.Any(), "cluster01", constants.ClusterHibernated).Return(fmt.Errorf("fail"))
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockHibernateClusterMeta(constants.ClusterStopped, constants.ClusterInstanceHibernated))
+		err := setClusterHibernated(&workflowModel.WorkFlowNode{}, flowContext)
+		assert.Error(t, err)
+	})
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
 	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/deployment"
 	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	"github.com/pingcap/tiunimanager/micro-cluster/cluster/backuprestore"
@@ -46,6 +47,7 @@ import (
 	workflow "github.com/pingcap/tiunimanager/workflow2"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 const (
@@ -57,6 +59,8 @@ const (
 	ContextSourceClusterMaintenanceStatus = "SourceClusterMaintenanceStatus"
 	ContextCloneStrategy                  = "CloneStrategy"
 	ContextBackupID                       = "BackupID"
+	ContextBackupLocationID               = "BackupLocationID"
+	ContextPointInTime                    = "PointInTime"
 	ContextOriginalParamGroupId           = "OriginalParamGroupId"
 	ContextOriginalVersion                = "OriginalVersion"
 	ContextUpgradeVersion                 = "UpgradeVersion"
@@ -70,9 +74,18 @@ const (
 	ContextTakeoverRequest                = "TakeoverRequest"
 	ContextGCLifeTime                     = "GCLifeTime"
 	ContextInstanceTypes                  = "InstanceTypes"
+	ContextClusterSpecPlan                = "ClusterSpecPlan"
+	ContextReservedHostsAllowed           = "ReservedHostsAllowed"
+	ContextBackupVerifyRecord             = "BackupVerifyRecord"
+	ContextBackupVerifyRequest            = "BackupVerifyRequest"
+	ContextBackupVerifyMessage            = "BackupVerifyMessage"
+	ContextSourceChecksums                = "SourceChecksums"
+	ContextScratchClusterID               = "ScratchClusterID"
 )
 
-type Manager struct{}
+type Manager struct {
+	backupVerifyMgr *backupVerifyManager
+}
 
 func NewClusterManager() *Manager {
 	workflowManager := workflow.GetWorkFlowService()
@@ -83,12 +96,22 @@ func NewClusterManager() *Manager {
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowDeleteCluster, &deleteClusterFlow)
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowRestartCluster, &restartClusterFlow)
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowStopCluster, &stopClusterFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowHibernateCluster, &hibernateClusterFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowWakeCluster, &wakeClusterFlow)
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowOnlineInPlaceUpgradeCluster, &onlineInPlaceUpgradeClusterFlow)
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowOfflineInPlaceUpgradeCluster, &offlineInPlaceUpgradeClusterFlow)
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowCloneCluster, &cloneDefine)
 	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowTakeoverCluster, &takeoverClusterFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowTakeoverDMCluster, &takeoverDMClusterFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowCreateDMCluster, &createDMClusterFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowScaleOutDMCluster, &scaleOutDMDefine)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowDeleteDMCluster, &deleteDMClusterFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowApplyClusterSpec, &applyClusterSpecFlow)
+	workflowManager.RegisterWorkFlow(context.TODO(), constants.FlowVerifyBackup, &verifyBackupFlow)
 
-	return &Manager{}
+	return &Manager{
+		backupVerifyMgr: NewBackupVerifyManager(),
+	}
 }
 
 var scaleOutDefine = workflow.WorkFlowDefine{
@@ -107,6 +130,20 @@ var scaleOutDefine = workflow.WorkFlowDefine{
 	},
 }
 
+// scaleOutDMDefine scale out DM cluster by tiup dm, there is no parameter of DM cluster to be updated
+var scaleOutDMDefine = workflow.WorkFlowDefine{
+	FlowName: constants.FlowScaleOutDMCluster,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":            {"prepareResource", "resourceDone", "fail", workflow.SyncFuncNode, prepareResource},
+		"resourceDone":     {"buildConfig", "configDone", "fail", workflow.SyncFuncNode, buildConfig},
+		"configDone":       {"scaleOutCluster", "scaleOutDone", "fail", workflow.PollingNode, scaleOutCluster},
+		"scaleOutDone":     {"syncTopology", "syncTopologyDone", "fail", workflow.SyncFuncNode, syncTopology},
+		"syncTopologyDone": {"setClusterOnline", "onlineDone", "fail", workflow.SyncFuncNode, setClusterOnline},
+		"onlineDone":       {"end", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(persistCluster, endMaintenance)},
+		"fail":             {"fail", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(revertResourceAfterFailure, endMaintenance)},
+	},
+}
+
 // ScaleOut
 // @Description scale out a cluster
 // @Parameter	request
@@ -140,7 +177,11 @@ func (p *Manager) ScaleOut(ctx context.Context, request cluster.ScaleOutClusterR
 	data := map[string]interface{}{
 		ContextClusterMeta: clusterMeta,
 	}
-	flowID, err := asyncMaintenance(ctx, clusterMeta, constants.ClusterMaintenanceScaleOut, scaleOutDefine.FlowName, data)
+	flowName := scaleOutDefine.FlowName
+	if clusterMeta.GetTiUPComponentType() == deployment.TiUPComponentTypeDM {
+		flowName = scaleOutDMDefine.FlowName
+	}
+	flowID, err := asyncMaintenance(ctx, clusterMeta, constants.ClusterMaintenanceScaleOut, flowName, data)
 	if err != nil {
 		framework.LogWithContext(ctx).Errorf(
 			"cluster %s async maintenance error: %s", clusterMeta.Cluster.ID, err.Error())
@@ -308,8 +349,24 @@ var createClusterFlow = workflow.WorkFlowDefine{
 	},
 }
 
+// createDMClusterFlow create DM cluster by tiup dm, DM cluster has no database account or parameter group
+var createDMClusterFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowCreateDMCluster,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":            {"prepareResource", "resourceDone", "fail", workflow.SyncFuncNode, prepareResource},
+		"resourceDone":     {"buildConfig", "configDone", "fail", workflow.SyncFuncNode, buildConfig},
+		"configDone":       {"deployCluster", "deployDone", "fail", workflow.PollingNode, deployCluster},
+		"deployDone":       {"syncTopology", "syncTopologyDone", "failAfterDeploy", workflow.SyncFuncNode, syncTopology},
+		"syncTopologyDone": {"startupCluster", "startupDone", "failAfterDeploy", workflow.PollingNode, startCluster},
+		"startupDone":      {"setClusterOnline", "success", "failAfterDeploy", workflow.SyncFuncNode, setClusterOnline},
+		"success":          {"end", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(persistCluster, endMaintenance, asyncBuildLog)},
+		"fail":             {"fail", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(setClusterFailure, revertResourceAfterFailure, endMaintenance)},
+		"failAfterDeploy":  {"failAfterDeploy", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(setClusterFailure, endMaintenance)},
+	},
+}
+
 // CreateCluster
-// @Description: See createClusterFlow
+// @Description: See createClusterFlow, and createDMClusterFlow for DataMigration cluster
 // @Receiver p
 // @Parameter ctx
 // @Parameter req
@@ -337,7 +394,11 @@ func (p *Manager) CreateCluster(ctx context.Context, req cluster.CreateClusterRe
 	data := map[string]interface{}{
 		ContextClusterMeta: meta,
 	}
-	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceCreating, createClusterFlow.FlowName, data)
+	flowName := createClusterFlow.FlowName
+	if meta.GetTiUPComponentType() == deployment.TiUPComponentTypeDM {
+		flowName = createDMClusterFlow.FlowName
+	}
+	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceCreating, flowName, data)
 	if err != nil {
 		framework.LogWithContext(ctx).Errorf(
 			"cluster %s async maintenance error: %s", meta.Cluster.ID, err.Error())
@@ -357,6 +418,12 @@ func (p *Manager) CreateCluster(ctx context.Context, req cluster.CreateClusterRe
 // @Return cluster.RestoreNewClusterResp
 // @Return error
 func (p *Manager) RestoreNewCluster(ctx context.Context, req cluster.RestoreNewClusterReq) (resp cluster.RestoreNewClusterResp, err error) {
+	return p.restoreNewCluster(ctx, req, false)
+}
+
+// restoreNewCluster
+// @Description: restore a new cluster by backup record, hosts reserved for platform use are allocatable if reservedHostsAllowed
+func (p *Manager) restoreNewCluster(ctx context.Context, req cluster.RestoreNewClusterReq, reservedHostsAllowed bool) (resp cluster.RestoreNewClusterResp, err error) {
 	meta := &meta.ClusterMeta{}
 
 	if err = p.restoreNewClusterPreCheck(ctx, req); err != nil {
@@ -378,8 +445,11 @@ func (p *Manager) RestoreNewCluster(ctx context.Context, req cluster.RestoreNewC
 	}
 
 	data := map[string]interface{}{
-		ContextClusterMeta: meta,
-		ContextBackupID:    req.BackupID,
+		ContextClusterMeta:          meta,
+		ContextBackupID:             req.BackupID,
+		ContextBackupLocationID:     req.LocationID,
+		ContextPointInTime:          req.PointInTime,
+		ContextReservedHostsAllowed: reservedHostsAllowed,
 	}
 	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceCreating, createClusterFlow.FlowName, data)
 	if err != nil {
@@ -434,6 +504,12 @@ func (p *Manager) PreviewCluster(ctx context.Context, req cluster.CreateClusterR
 		resp.StockCheckResult = checkResult
 	}
 
+	// estimate is for reference only, the preview is returned without it if failed
+	if estimateErr := estimatePreview(ctx, req.Vendor, req.Type, req.ResourceParameter.InstanceResource, &resp); estimateErr != nil {
+		framework.LogWithContext(ctx).Errorf("estimate cost and capacity failed, err = %s", estimateErr.Error())
+		resp.CostEstimate = structs.ResourceCostEstimate{}
+		resp.CapacityEstimates = nil
+	}
 	return
 }
 
@@ -511,6 +587,12 @@ func (p *Manager) PreviewScaleOutCluster(ctx context.Context, req cluster.ScaleO
 		resp.StockCheckResult = checkResult
 	}
 
+	// estimate is for reference only, the preview is returned without it if failed
+	if estimateErr := estimatePreview(ctx, clusterMeta.Cluster.Vendor, clusterMeta.Cluster.Type, req.InstanceResource, &resp); estimateErr != nil {
+		framework.LogWithContext(ctx).Errorf("estimate cost and capacity failed, err = %s", estimateErr.Error())
+		resp.CostEstimate = structs.ResourceCostEstimate{}
+		resp.CapacityEstimates = nil
+	}
 	return
 }
 
@@ -561,6 +643,19 @@ var deleteClusterFlow = workflow.WorkFlowDefine{
 	},
 }
 
+// deleteDMClusterFlow delete DM cluster by tiup dm, DM cluster has no backup or change feed task,
+// but its tasks and sources are cleared, otherwise unfinished tasks are watched forever
+var deleteDMClusterFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowDeleteDMCluster,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":              {"destroyCluster", "destroyClusterDone", "fail", workflow.PollingNode, destroyCluster},
+		"destroyClusterDone": {"freedClusterResource", "freedResourceDone", "fail", workflow.SyncFuncNode, freedClusterResource},
+		"freedResourceDone":  {"clearDMTasks", "clearDMTasksDone", "fail", workflow.SyncFuncNode, clearDMTasks},
+		"clearDMTasksDone":   {"end", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(deleteCluster)},
+		"fail":               {"fail", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(setClusterFailure, endMaintenance)},
+	},
+}
+
 func (p *Manager) DeleteCluster(ctx context.Context, req cluster.DeleteClusterReq) (resp cluster.DeleteClusterResp, err error) {
 	meta, err := meta.Get(ctx, req.ClusterID)
 	if err != nil {
@@ -577,11 +672,25 @@ func (p *Manager) DeleteCluster(ctx context.Context, req cluster.DeleteClusterRe
 		return
 	}
 
+	flowName := deleteClusterFlow.FlowName
+	if meta.GetTiUPComponentType() == deployment.TiUPComponentTypeDM {
+		flowName = deleteDMClusterFlow.FlowName
+		_, total, queryErr := models.GetDMReaderWriter().QueryTasks(ctx, req.ClusterID, constants.UnfinishedDMTaskStatus(), 0, 0)
+		if queryErr != nil {
+			err = queryErr
+			return
+		}
+		if total > 0 && !req.Force {
+			err = errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, "dm cluster has %d unfinished tasks", total)
+			return
+		}
+	}
+
 	data := map[string]interface{}{
 		ContextClusterMeta:   meta,
 		ContextDeleteRequest: req,
 	}
-	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceDeleting, deleteClusterFlow.FlowName, data)
+	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceDeleting, flowName, data)
 
 	if err != nil {
 		framework.LogWithContext(ctx).Errorf(
@@ -660,11 +769,80 @@ var takeoverClusterFlow = workflow.WorkFlowDefine{
 	},
 }
 
+// takeoverDMClusterFlow take over a cluster managed by tiup dm, there is no TiDB server to connect or parameter group to apply
+var takeoverDMClusterFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowTakeoverDMCluster,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":              {"fetchTopologyFile", "fetched", "revert", workflow.SyncFuncNode, fetchTopologyFile},
+		"fetched":            {"rebuildTopologyFromConfig", "built", "revert", workflow.SyncFuncNode, rebuildTopologyFromConfig},
+		"built":              {"validateHostsStatus", "hostReady", "revert", workflow.SyncFuncNode, validateHostsStatus},
+		"hostReady":          {"takeoverResource", "resourceDone", "revert", workflow.SyncFuncNode, takeoverResource},
+		"resourceDone":       {"rebuildTiupSpaceForCluster", "success", "revertWithResource", workflow.SyncFuncNode, rebuildTiupSpaceForCluster},
+		"success":            {"end", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(persistCluster, endMaintenance)},
+		"revert":             {"revert", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(takeoverRevertMeta)},
+		"revertWithResource": {"revertWithResource", "", "", workflow.SyncFuncNode, workflow.CompositeExecutor(revertResourceAfterFailure, takeoverRevertMeta)},
+	},
+}
+
+// getTakeoverAuthMethods
+// @Description: build ssh auth methods from password, private key and ssh agent in takeover request
+// @Parameter ctx
+// @Parameter req
+// @return []ssh.AuthMethod
+// @return closer, release the connection to ssh agent after dial
+// @return error
+func getTakeoverAuthMethods(ctx context.Context, req cluster.TakeoverClusterReq) ([]ssh.AuthMethod, func(), error) {
+	authMethods := make([]ssh.AuthMethod, 0)
+	closer := func() {}
+
+	if len(req.TiUPUserPrivateKey) > 0 {
+		var signer ssh.Signer
+		var err error
+		if len(req.TiUPUserPassphrase) > 0 {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(req.TiUPUserPrivateKey), []byte(req.TiUPUserPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(req.TiUPUserPrivateKey))
+		}
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("parse private key failed, error: %s", err.Error())
+			return nil, closer, errors.WrapError(errors.TIUNIMANAGER_TAKEOVER_SSH_AUTH_ERROR, "parse private key failed", err)
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+
+	if len(req.TiUPSSHAgentSocket) > 0 {
+		conn, err := net.Dial("unix", req.TiUPSSHAgentSocket)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("connect ssh agent %s failed, error: %s", req.TiUPSSHAgentSocket, err.Error())
+			return nil, closer, errors.WrapError(errors.TIUNIMANAGER_TAKEOVER_SSH_AUTH_ERROR, "connect ssh agent failed", err)
+		}
+		closer = func() {
+			conn.Close()
+		}
+		authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if len(req.TiUPUserPassword) > 0 {
+		authMethods = append(authMethods, ssh.Password(string(req.TiUPUserPassword)))
+	}
+
+	if len(authMethods) == 0 {
+		return nil, closer, errors.NewError(errors.TIUNIMANAGER_TAKEOVER_SSH_AUTH_ERROR, "password, private key or ssh agent socket is required")
+	}
+	return authMethods, closer, nil
+}
+
 type openSftpClientFunc func(ctx context.Context, req cluster.TakeoverClusterReq) (*ssh.Client, *sftp.Client, error)
 
 var openSftpClient openSftpClientFunc = func(ctx context.Context, req cluster.TakeoverClusterReq) (*ssh.Client, *sftp.Client, error) {
+	authMethods, closer, err := getTakeoverAuthMethods(ctx, req)
+	defer closer()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	conf := ssh.ClientConfig{User: req.TiUPUserName,
-		Auth: []ssh.AuthMethod{ssh.Password(string(req.TiUPUserPassword))},
+		Auth: authMethods,
 		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
 			return nil
 		},
@@ -691,6 +869,10 @@ func (p *Manager) Takeover(ctx context.Context, req cluster.TakeoverClusterReq)
 		err = errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster name required")
 		return
 	}
+	if !req.IsDM() && len(req.DBPassword) == 0 {
+		err = errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "db password required")
+		return
+	}
 
 	client, sftpClient, err := openSftpClient(ctx, req)
 
@@ -715,7 +897,11 @@ func (p *Manager) Takeover(ctx context.Context, req cluster.TakeoverClusterReq)
 		ContextClusterMeta:     meta,
 		ContextTakeoverRequest: req,
 	}
-	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceTakeover, takeoverClusterFlow.FlowName, data)
+	flowName := takeoverClusterFlow.FlowName
+	if req.IsDM() {
+		flowName = takeoverDMClusterFlow.FlowName
+	}
+	flowID, err := asyncMaintenance(ctx, meta, constants.ClusterMaintenanceTakeover, flowName, data)
 	if err != nil {
 		framework.LogWithContext(ctx).Errorf(
 			"cluster %s async maintenance error: %s", meta.Cluster.ID, err.Error())
@@ -737,6 +923,12 @@ func (p *Manager) Takeover(ctx context.Context, req cluster.TakeoverClusterReq)
 // @return err
 func asyncMaintenance(ctx context.Context, clusterMeta *meta.ClusterMeta,
 	status constants.ClusterMaintenanceStatus, flowName string, data map[string]interface{}) (flowID string, err error) {
+	// instances of a hibernated cluster are incomplete, it can only be woken up or deleted
+	if clusterMeta.Cluster.Status == string(constants.ClusterHibernated) &&
+		status != constants.ClusterMaintenanceWaking && status != constants.ClusterMaintenanceDeleting {
+		err = errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_HIBERNATED, "cluster %s is hibernated", clusterMeta.Cluster.ID)
+		return
+	}
 
 	err = models.Transaction(ctx, func(transactionCtx context.Context) error {
 		return errors.OfNullable(nil).BreakIf(func() error {
@@ -865,11 +1057,17 @@ func (p *Manager) GetMonitorInfo(ctx context.Context, req cluster.QueryMonitorIn
 }
 
 func (p *Manager) restoreNewClusterPreCheck(ctx context.Context, req cluster.RestoreNewClusterReq) error {
+	brService := backuprestore.GetBRService()
 	if req.BackupID == "" {
-		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "restore new cluster input backupId empty")
+		if req.PointInTime.RestoreTSO == "" && req.PointInTime.RestoreTime == 0 {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "restore new cluster input backupId and pointInTime empty")
+		}
+		if req.PointInTime.SourceClusterID == "" {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "restore new cluster to point in time input sourceClusterId empty")
+		}
+		return brService.CheckPointInTimeRestore(ctx, req.PointInTime)
 	}
 
-	brService := backuprestore.GetBRService()
 	resp, _, err := brService.QueryClusterBackupRecords(ctx, cluster.QueryBackupRecordsReq{
 		BackupID: req.BackupID,
 		PageRequest: structs.PageRequest{
@@ -887,6 +1085,25 @@ func (p *Manager) restoreNewClusterPreCheck(ctx context.Context, req cluster.Res
 		return errors.NewErrorf(errors.TIUNIMANAGER_BACKUP_RECORD_INVALID, "backup record status invalid")
 	}
 
+	// capacity and table conflicts are checked again when the new cluster is ready to restore
+	checkResp, err := brService.CheckRestore(ctx, cluster.CheckRestoreReq{
+		BackupID:      req.BackupID,
+		LocationID:    req.LocationID,
+		TargetVersion: req.Version,
+	})
+	if err != nil {
+		return err
+	}
+	if !checkResp.Passed {
+		failed := make([]string, 0)
+		for _, item := range checkResp.Items {
+			if item.Status == string(constants.RestoreCheckFailed) {
+				failed = append(failed, item.Message)
+			}
+		}
+		return errors.NewErrorf(errors.TIUNIMANAGER_RESTORE_CHECK_FAILED, "restore check failed, %s", strings.Join(failed, "; "))
+	}
+
 	return nil
 }
 
@@ -17,6 +17,10 @@ package management
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"github.com/pingcap/tiunimanager/message"
@@ -41,11 +45,13 @@ import (
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
 	"github.com/pingcap/tiunimanager/models/common"
+	rp "github.com/pingcap/tiunimanager/models/resource/resourcepool"
 	wfModel "github.com/pingcap/tiunimanager/models/workflow"
 	mock_br_service "github.com/pingcap/tiunimanager/test/mockbr"
 	mock_deployment "github.com/pingcap/tiunimanager/test/mockdeployment"
 	mock_product "github.com/pingcap/tiunimanager/test/mockmodels"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockdm"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockresource"
 	mock_workflow_service "github.com/pingcap/tiunimanager/test/mockworkflow"
 	workflow "github.com/pingcap/tiunimanager/workflow2"
@@ -854,6 +860,21 @@ func TestManager_DeleteCluster(t *testing.T) {
 		})
 		assert.Error(t, err)
 	})
+	t.Run("dm cluster with unfinished tasks", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "dm01").Return(&management.Cluster{Type: string(constants.EMProductIDDataMigration)},
+			[]*management.ClusterInstance{}, make([]*management.DBUser, 0), nil)
+		dmRW := mockdm.NewMockReaderWriter(ctrl)
+		models.SetDMReaderWriter(dmRW)
+		dmRW.EXPECT().QueryTasks(gomock.Any(), "dm01", constants.UnfinishedDMTaskStatus(), 0, 0).Return(nil, int64(1), nil)
+
+		_, err := manager.DeleteCluster(context.TODO(), cluster.DeleteClusterReq{
+			ClusterID: "dm01",
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_DM_TASK_STATUS_CONFLICT, err.(em_errors.EMError).GetCode())
+	})
 }
 
 func TestManager_DetailCluster(t *testing.T) {
@@ -1018,6 +1039,7 @@ func TestManager_RestoreNewCluster(t *testing.T) {
 				},
 			},
 		}, structs.Page{}, nil).AnyTimes()
+	brService.EXPECT().CheckRestore(gomock.Any(), gomock.Any()).Return(cluster.CheckRestoreResp{Passed: true}, nil).AnyTimes()
 	backuprestore.MockBRService(brService)
 	defer backuprestore.MockBRService(backuprestore.NewBRManager())
 
@@ -1045,6 +1067,74 @@ func TestManager_RestoreNewCluster(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("restore check failed", func(t *testing.T) {
+		failedService := mock_br_service.NewMockBRService(ctrl)
+		failedService.EXPECT().QueryClusterBackupRecords(gomock.Any(), gomock.Any()).Return(
+			cluster.QueryBackupRecordsResp{
+				BackupRecords: []*structs.BackupRecord{{Status: string(constants.ClusterBackupFinished)}},
+			}, structs.Page{}, nil)
+		failedService.EXPECT().CheckRestore(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req cluster.CheckRestoreReq) (cluster.CheckRestoreResp, error) {
+			assert.Equal(t, "backup123", req.BackupID)
+			assert.Equal(t, "v5.0.0", req.TargetVersion)
+			return cluster.CheckRestoreResp{Items: []structs.RestoreCheckItem{
+				{Name: "version", Status: string(constants.RestoreCheckFailed), Message: "backup of v5.2.0 can not be restored into older cluster of v5.0.0"},
+			}}, nil
+		})
+		backuprestore.MockBRService(failedService)
+		defer backuprestore.MockBRService(brService)
+
+		_, err := manager.RestoreNewCluster(context.TODO(), cluster.RestoreNewClusterReq{
+			CreateClusterParameter: structs.CreateClusterParameter{Version: "v5.0.0"},
+			BackupID:               "backup123",
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_RESTORE_CHECK_FAILED, err.(em_errors.EMError).GetCode())
+	})
+
+	t.Run("point in time", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&management.Cluster{Entity: common.Entity{ID: "cluster"}}, nil).AnyTimes()
+		clusterRW.EXPECT().SetMaintenanceStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		brService.EXPECT().CheckPointInTimeRestore(gomock.Any(), gomock.Any()).Return(nil)
+		_, err := manager.RestoreNewCluster(context.TODO(), cluster.RestoreNewClusterReq{
+			ResourceParameter: structs.ClusterResourceInfo{
+				InstanceResource: []structs.ClusterResourceParameterCompute{
+					{Type: "TiDB", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+						{Zone: "Test_Zone1", DiskType: "SATA", DiskCapacity: 0, Spec: "4C8G", Count: 1},
+					}},
+					{Type: "TiKV", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+						{Zone: "Test_Zone1", DiskType: "SATA", DiskCapacity: 0, Spec: "4C8G", Count: 1},
+					}},
+					{Type: "PD", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+						{Zone: "Test_Zone1", DiskType: "SATA", DiskCapacity: 0, Spec: "4C8G", Count: 1},
+					}},
+				},
+			},
+			PointInTime: cluster.PointInTimeRestoreTarget{
+				SourceClusterID: "source",
+				RestoreTSO:      "434146621381115905",
+			},
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("point in time without source", func(t *testing.T) {
+		_, err := manager.RestoreNewCluster(context.TODO(), cluster.RestoreNewClusterReq{
+			ResourceParameter: structs.ClusterResourceInfo{
+				InstanceResource: []structs.ClusterResourceParameterCompute{
+					{Type: "TiDB", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+						{Zone: "Test_Zone1", DiskType: "SATA", DiskCapacity: 0, Spec: "4C8G", Count: 1},
+					}},
+				},
+			},
+			PointInTime: cluster.PointInTimeRestoreTarget{
+				RestoreTSO: "434146621381115905",
+			},
+		})
+		assert.Error(t, err)
+	})
+
 	t.Run("build cluster fail", func(t *testing.T) {
 		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
 		models.SetClusterReaderWriter(clusterRW)
@@ -1352,6 +1442,8 @@ func TestPreviewCluster(t *testing.T) {
 			{Zone: "Zone1", FreeHostCount: 8, FreeCpuCores: 8, FreeMemory: 8, FreeDiskCount: 8, FreeDiskCapacity: 8},
 			{Zone: "Zone2", FreeHostCount: 8, FreeCpuCores: 8, FreeMemory: 8, FreeDiskCount: 8, FreeDiskCapacity: 8},
 		}, nil).Times(1)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]rp.Host{}, int64(0), nil).Times(1)
+		clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 		manager := &Manager{}
 		resp, err := manager.PreviewCluster(context.TODO(), cluster.CreateClusterReq{
@@ -1378,6 +1470,8 @@ func TestPreviewCluster(t *testing.T) {
 		assert.False(t, resp.StockCheckResult[1].Enough)
 		assert.False(t, resp.StockCheckResult[2].Enough)
 		assert.True(t, resp.StockCheckResult[3].Enough)
+		assert.Equal(t, 4, len(resp.CostEstimate.Items))
+		assert.Equal(t, 3, len(resp.CapacityEstimates))
 	})
 
 	t.Run("stock error", func(t *testing.T) {
@@ -1402,6 +1496,44 @@ func TestPreviewCluster(t *testing.T) {
 		_, err := manager.PreviewCluster(context.TODO(), cluster.CreateClusterReq{})
 		assert.Error(t, err)
 	})
+
+	t.Run("estimate error", func(t *testing.T) {
+		validator = func(ctx context.Context, req *cluster.CreateClusterReq) error {
+			return nil
+		}
+		defer func() {
+			validator = validateCreating
+		}()
+		clusterRW.EXPECT().QueryMetas(gomock.Any(), gomock.Any(), gomock.Any()).Return([]*management.Result{}, structs.Page{
+			Page:     1,
+			Total:    0,
+			PageSize: 1,
+		}, nil).Times(1)
+		resourceRW.EXPECT().GetHostStocks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]structs.Stocks{
+			{Zone: "Zone1", FreeHostCount: 8, FreeCpuCores: 8, FreeMemory: 8, FreeDiskCount: 8, FreeDiskCapacity: 8},
+		}, nil).Times(1)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), errors.New("query hosts failed")).Times(1)
+
+		manager := &Manager{}
+		resp, err := manager.PreviewCluster(context.TODO(), cluster.CreateClusterReq{
+			CreateClusterParameter: structs.CreateClusterParameter{
+				Region:          "111",
+				CpuArchitecture: "111",
+			},
+			ResourceParameter: structs.ClusterResourceInfo{
+				InstanceResource: []structs.ClusterResourceParameterCompute{
+					{Type: "TiKV", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+						{Zone: "Zone1", Count: 1, Spec: "4C8G", DiskCapacity: 1, DiskType: "SATA"},
+					}},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(resp.StockCheckResult))
+		assert.True(t, resp.StockCheckResult[0].Enough)
+		assert.Empty(t, resp.CostEstimate.Items)
+		assert.Empty(t, resp.CapacityEstimates)
+	})
 }
 
 func TestPreviewScaleOutCluster(t *testing.T) {
@@ -1424,6 +1556,8 @@ func TestPreviewScaleOutCluster(t *testing.T) {
 			{Zone: "Zone1", FreeHostCount: 8, FreeCpuCores: 8, FreeMemory: 8, FreeDiskCount: 8, FreeDiskCapacity: 8},
 			{Zone: "Zone2", FreeHostCount: 8, FreeCpuCores: 8, FreeMemory: 8, FreeDiskCount: 8, FreeDiskCapacity: 8},
 		}, nil).Times(1)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]rp.Host{}, int64(0), nil).Times(1)
+		clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
 
 		manager := &Manager{}
 		resp, err := manager.PreviewScaleOutCluster(context.TODO(), cluster.ScaleOutClusterReq{
@@ -1447,6 +1581,8 @@ func TestPreviewScaleOutCluster(t *testing.T) {
 		assert.False(t, resp.StockCheckResult[1].Enough)
 		assert.False(t, resp.StockCheckResult[2].Enough)
 		assert.True(t, resp.StockCheckResult[3].Enough)
+		assert.Equal(t, 4, len(resp.CostEstimate.Items))
+		assert.Equal(t, 3, len(resp.CapacityEstimates))
 	})
 	t.Run("cluster is not existed", func(t *testing.T) {
 		clusterRW.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(&management.Cluster{Entity: common.Entity{ID: "cluster01"}}, []*management.ClusterInstance{}, make([]*management.DBUser, 0), errors.New("")).Times(1)
@@ -1467,6 +1603,30 @@ func TestPreviewScaleOutCluster(t *testing.T) {
 		_, err := manager.PreviewScaleOutCluster(context.TODO(), cluster.ScaleOutClusterReq{})
 		assert.Error(t, err)
 	})
+
+	t.Run("estimate error", func(t *testing.T) {
+		clusterRW.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(&management.Cluster{Entity: common.Entity{ID: "cluster01"}}, []*management.ClusterInstance{}, make([]*management.DBUser, 0), nil).Times(1)
+		resourceRW.EXPECT().GetHostStocks(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]structs.Stocks{
+			{Zone: "Zone1", FreeHostCount: 8, FreeCpuCores: 8, FreeMemory: 8, FreeDiskCount: 8, FreeDiskCapacity: 8},
+		}, nil).Times(1)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), errors.New("query hosts failed")).Times(1)
+
+		manager := &Manager{}
+		resp, err := manager.PreviewScaleOutCluster(context.TODO(), cluster.ScaleOutClusterReq{
+			ClusterID: "111",
+			ClusterResourceInfo: structs.ClusterResourceInfo{
+				InstanceResource: []structs.ClusterResourceParameterCompute{
+					{Type: "TiKV", Count: 1, Resource: []structs.ClusterResourceParameterComputeResource{
+						{Zone: "Zone1", Count: 1, Spec: "4C8G", DiskCapacity: 1, DiskType: "SATA"},
+					}},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(resp.StockCheckResult))
+		assert.Empty(t, resp.CostEstimate.Items)
+		assert.Empty(t, resp.CapacityEstimates)
+	})
 }
 
 func TestManager_openSftpClient(t *testing.T) {
@@ -1474,6 +1634,68 @@ func TestManager_openSftpClient(t *testing.T) {
 		_, _, err := openSftpClient(context.TODO(), cluster.TakeoverClusterReq{})
 		assert.Error(t, err)
 	})
+	t.Run("invalid private key", func(t *testing.T) {
+		_, _, err := openSftpClient(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPIp:             "127.0.0.1",
+			TiUPPort:           22,
+			TiUPUserPrivateKey: "invalid key",
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_TAKEOVER_SSH_AUTH_ERROR, err.(em_errors.EMError).GetCode())
+	})
+}
+
+func Test_getTakeoverAuthMethods(t *testing.T) {
+	t.Run("no auth", func(t *testing.T) {
+		_, closer, err := getTakeoverAuthMethods(context.TODO(), cluster.TakeoverClusterReq{})
+		defer closer()
+		assert.Error(t, err)
+	})
+	t.Run("password", func(t *testing.T) {
+		methods, closer, err := getTakeoverAuthMethods(context.TODO(), cluster.TakeoverClusterReq{TiUPUserPassword: "aaa"})
+		defer closer()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(methods))
+	})
+	t.Run("private key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		methods, closer, err := getTakeoverAuthMethods(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPUserPrivateKey: structs.SensitiveText(pem.EncodeToMemory(block)),
+			TiUPUserPassword:   "aaa",
+		})
+		defer closer()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(methods))
+	})
+	t.Run("encrypted private key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), []byte("passphrase"), x509.PEMCipherAES256)
+		assert.NoError(t, err)
+		methods, closer, err := getTakeoverAuthMethods(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPUserPrivateKey: structs.SensitiveText(pem.EncodeToMemory(block)),
+			TiUPUserPassphrase: "passphrase",
+		})
+		defer closer()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(methods))
+
+		_, closer, err = getTakeoverAuthMethods(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPUserPrivateKey: structs.SensitiveText(pem.EncodeToMemory(block)),
+			TiUPUserPassphrase: "wrong",
+		})
+		defer closer()
+		assert.Error(t, err)
+	})
+	t.Run("agent socket not found", func(t *testing.T) {
+		_, closer, err := getTakeoverAuthMethods(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPSSHAgentSocket: "/tmp/not-existed-agent.sock",
+		})
+		defer closer()
+		assert.Error(t, err)
+	})
 }
 
 func TestManager_TakeoverCluster(t *testing.T) {
@@ -1485,6 +1707,7 @@ func TestManager_TakeoverCluster(t *testing.T) {
 	defer ctrl.Finish()
 
 	workflow.GetWorkFlowService().RegisterWorkFlow(context.TODO(), constants.FlowTakeoverCluster, getEmptyFlow(constants.FlowTakeoverCluster))
+	workflow.GetWorkFlowService().RegisterWorkFlow(context.TODO(), constants.FlowTakeoverDMCluster, getEmptyFlow(constants.FlowTakeoverDMCluster))
 	manager := Manager{}
 
 	workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
@@ -1536,6 +1759,39 @@ func TestManager_TakeoverCluster(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("dm", func(t *testing.T) {
+		openSftpClient = func(ctx context.Context, req cluster.TakeoverClusterReq) (*ssh.Client, *sftp.Client, error) {
+			return nil, nil, nil
+		}
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&management.Cluster{Entity: common.Entity{ID: "cluster"}}, nil).AnyTimes()
+		clusterRW.EXPECT().SetMaintenanceStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		clusterRW.EXPECT().CreateDBUser(gomock.Any(), gomock.Any()).Times(0)
+		_, err := manager.Takeover(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPIp:             "127.0.0.1",
+			TiUPPath:           ".tiup/",
+			TiUPUserName:       "root",
+			TiUPUserPrivateKey: "key",
+			TiUPPort:           22,
+			TiUPComponent:      "dm",
+			ClusterName:        "takeoverCluster",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("no db password", func(t *testing.T) {
+		_, err := manager.Takeover(context.TODO(), cluster.TakeoverClusterReq{
+			TiUPIp:           "127.0.0.1",
+			TiUPPath:         ".tiup/",
+			TiUPUserName:     "root",
+			TiUPUserPassword: "aaa",
+			TiUPPort:         22,
+			ClusterName:      "takeoverCluster",
+		})
+		assert.Error(t, err)
+	})
+
 	t.Run("no name", func(t *testing.T) {
 		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
 		models.SetClusterReaderWriter(clusterRW)
@@ -48,6 +48,7 @@ const CheckInstanceStatusTimeout = 30 * 24 * time.Hour
 const CheckInstanceStatusInterval = 10 * time.Second
 const GetGCLifeTimeCmd = `SELECT VARIABLE_VALUE as gc_life_time FROM mysql.GLOBAL_VARIABLES WHERE VARIABLE_NAME="tidb_gc_life_time";`
 const DefaultMaxGCLifeTime = "720h"
+const CloneGCHoldTTL = 72 * time.Hour
 
 type PlacementRules struct {
 	EnablePlacementRules string `json:"enable-placement-rules"`
@@ -18,11 +18,14 @@ package meta
 import (
 	"context"
 	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
 	"github.com/pingcap/tiunimanager/message/cluster"
 	resource "github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/management/structs"
 	"github.com/pingcap/tiunimanager/models/platform/config"
 	mock_product "github.com/pingcap/tiunimanager/test/mockmodels"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	dmSpec "github.com/pingcap/tiup/components/dm/spec"
+	"gopkg.in/yaml.v2"
 	"github.com/pingcap/tiup/pkg/cluster/spec"
 	"testing"
 	"time"
@@ -506,6 +509,46 @@ func TestClusterMeta_GenerateTopologyConfig(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotEmpty(t, config)
 	})
+	t.Run("dm", func(t *testing.T) {
+		rw.EXPECT().GetConfig(gomock.Any(), gomock.Any()).Return(&config.SystemConfig{ConfigValue: "22"}, nil)
+		meta := &ClusterMeta{
+			Cluster: &management.Cluster{
+				Entity: common.Entity{
+					ID:     "111",
+					Status: string(constants.ClusterInitializing),
+				},
+				Type:    string(constants.EMProductIDDataMigration),
+				Version: "v5.4.0",
+			},
+			Instances: map[string][]*management.ClusterInstance{
+				"DMMaster": {
+					{
+						Entity:   common.Entity{ID: "master01", Status: string(constants.ClusterInstanceInitializing)},
+						HostIP:   []string{"127.0.0.1"},
+						Ports:    []int32{8261, 8291},
+						DiskPath: "/mnt/sda",
+					},
+				},
+				"DMWorker": {
+					{
+						Entity:   common.Entity{ID: "worker01", Status: string(constants.ClusterInstanceInitializing)},
+						HostIP:   []string{"127.0.0.2"},
+						Ports:    []int32{8262},
+						DiskPath: "/mnt/sda",
+					},
+				},
+			},
+		}
+		config, err := meta.GenerateTopologyConfig(context.TODO())
+		assert.NoError(t, err)
+
+		topology := &dmSpec.Specification{}
+		assert.NoError(t, yaml.UnmarshalStrict([]byte(config), topology))
+		assert.Len(t, topology.Masters, 1)
+		assert.Equal(t, 8291, topology.Masters[0].PeerPort)
+		assert.Len(t, topology.Workers, 1)
+		assert.Equal(t, "127.0.0.2", topology.Workers[0].Host)
+	})
 	t.Run("error", func(t *testing.T) {
 		empty := &ClusterMeta{
 			Cluster: &management.Cluster{},
@@ -1626,6 +1669,57 @@ func TestClusterMeta_ParseTopologyFromConfig(t *testing.T) {
 	})
 }
 
+func TestClusterMeta_ParseDMTopologyFromConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rw := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(rw)
+
+	rw.EXPECT().UpdateInstance(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	t.Run("normal", func(t *testing.T) {
+		meta := &ClusterMeta{
+			Cluster: &management.Cluster{
+				Entity: common.Entity{
+					ID:       "clusterId",
+					TenantId: "tenantId",
+				},
+				Version: "v5.3.0",
+			},
+		}
+		err := meta.ParseDMTopologyFromConfig(context.TODO(), &dmSpec.Specification{
+			Masters: []*dmSpec.MasterSpec{
+				{Host: "127.0.0.1", Port: 8261, PeerPort: 8291},
+				{Host: "127.0.0.2", Port: 8261, PeerPort: 8291},
+			},
+			Workers: []*dmSpec.WorkerSpec{
+				{Host: "127.0.0.3", Port: 8262},
+			},
+			Grafanas: []*spec.GrafanaSpec{
+				{Host: "127.0.0.4", Port: 3000},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(meta.Instances))
+		assert.Equal(t, 2, len(meta.Instances[string(constants.ComponentIDDMMaster)]))
+		assert.Equal(t, []int32{8261, 8291}, meta.Instances[string(constants.ComponentIDDMMaster)][1].Ports)
+		assert.Equal(t, "127.0.0.3", meta.Instances[string(constants.ComponentIDDMWorker)][0].HostIP[0])
+		assert.Equal(t, "v5.3.0", meta.Instances[string(constants.ComponentIDGrafana)][0].Version)
+	})
+	t.Run("empty", func(t *testing.T) {
+		meta := &ClusterMeta{Cluster: &management.Cluster{}}
+		err := meta.ParseDMTopologyFromConfig(context.TODO(), nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestClusterMeta_GetTiUPComponentType(t *testing.T) {
+	meta := &ClusterMeta{Cluster: &management.Cluster{Type: string(constants.EMProductIDTiDB)}}
+	assert.Equal(t, deployment.TiUPComponentTypeCluster, meta.GetTiUPComponentType())
+	meta.Cluster.Type = string(constants.EMProductIDDataMigration)
+	assert.Equal(t, deployment.TiUPComponentTypeDM, meta.GetTiUPComponentType())
+}
+
 func TestClusterMeta_GenerateTakeoverResourceRequirements(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
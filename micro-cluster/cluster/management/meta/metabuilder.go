@@ -35,6 +35,7 @@ import (
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
 	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	dmSpec "github.com/pingcap/tiup/components/dm/spec"
 	"github.com/pingcap/tiup/pkg/cluster/spec"
 )
 
@@ -201,9 +202,10 @@ func (p *ClusterMeta) BuildForTakeover(ctx context.Context, name string, dbPassw
 		MaintainWindow: "",
 	}
 	got, err := models.GetClusterReaderWriter().Create(ctx, p.Cluster)
-	if err == nil {
+	p.DBUsers = make(map[string]*management.DBUser)
+	// there is no db user for clusters without TiDB server, e.g. DM cluster
+	if err == nil && len(dbPassword) > 0 {
 		// set root user in clusterMeta
-		p.DBUsers = make(map[string]*management.DBUser)
 		rootUser := &management.DBUser{
 			ClusterID: got.ID,
 			Name:      constants.DBUserName[constants.Root],
@@ -296,9 +298,59 @@ func (p *ClusterMeta) ParseTopologyFromConfig(ctx context.Context, specs *spec.S
 			}).SetPresetDir(server.DeployDir, server.DataDir, server.LogDir))
 		}
 	}
-	if len(specs.Grafanas) > 0 {
-		for _, server := range specs.Grafanas {
-			instances = append(instances, parseInstanceFromSpec(p.Cluster, constants.ComponentIDGrafana, func() string {
+	instances = append(instances, parseMonitorInstancesFromSpec(p.Cluster, specs.Grafanas, specs.Alertmanagers, specs.Monitors)...)
+
+	p.acceptInstances(instances)
+
+	err := models.GetClusterReaderWriter().UpdateInstance(ctx, instances...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseDMTopologyFromConfig
+// @Description: parse topology from yaml config of tiup dm
+// @Receiver p
+// @Parameter ctx
+// @Parameter specs
+// @return error
+func (p *ClusterMeta) ParseDMTopologyFromConfig(ctx context.Context, specs *dmSpec.Specification) error {
+	if specs == nil {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cannot parse empty specification")
+	}
+	instances := make([]*management.ClusterInstance, 0)
+	for _, server := range specs.Masters {
+		instances = append(instances, parseInstanceFromSpec(p.Cluster, constants.ComponentIDDMMaster, func() string {
+			return server.Host
+		}, func() []int32 {
+			return []int32{
+				int32(server.Port),
+				int32(server.PeerPort),
+			}
+		}).SetPresetDir(server.DeployDir, server.DataDir, server.LogDir))
+	}
+	for _, server := range specs.Workers {
+		instances = append(instances, parseInstanceFromSpec(p.Cluster, constants.ComponentIDDMWorker, func() string {
+			return server.Host
+		}, func() []int32 {
+			return []int32{
+				int32(server.Port),
+			}
+		}).SetPresetDir(server.DeployDir, server.DataDir, server.LogDir))
+	}
+	instances = append(instances, parseMonitorInstancesFromSpec(p.Cluster, specs.Grafanas, specs.Alertmanagers, specs.Monitors)...)
+
+	p.acceptInstances(instances)
+
+	return models.GetClusterReaderWriter().UpdateInstance(ctx, instances...)
+}
+
+func parseMonitorInstancesFromSpec(cluster *management.Cluster, grafanas []*spec.GrafanaSpec, alertmanagers []*spec.AlertmanagerSpec, monitors []*spec.PrometheusSpec) []*management.ClusterInstance {
+	instances := make([]*management.ClusterInstance, 0)
+	if len(grafanas) > 0 {
+		for _, server := range grafanas {
+			instances = append(instances, parseInstanceFromSpec(cluster, constants.ComponentIDGrafana, func() string {
 				return server.Host
 			}, func() []int32 {
 				return []int32{
@@ -307,9 +359,9 @@ func (p *ClusterMeta) ParseTopologyFromConfig(ctx context.Context, specs *spec.S
 			}).SetPresetDir(server.DeployDir, "", ""))
 		}
 	}
-	if len(specs.Alertmanagers) > 0 {
-		for _, server := range specs.Alertmanagers {
-			instances = append(instances, parseInstanceFromSpec(p.Cluster, constants.ComponentIDAlertManger, func() string {
+	if len(alertmanagers) > 0 {
+		for _, server := range alertmanagers {
+			instances = append(instances, parseInstanceFromSpec(cluster, constants.ComponentIDAlertManger, func() string {
 				return server.Host
 			}, func() []int32 {
 				return []int32{
@@ -319,9 +371,9 @@ func (p *ClusterMeta) ParseTopologyFromConfig(ctx context.Context, specs *spec.S
 			}).SetPresetDir(server.DeployDir, server.DataDir, server.LogDir))
 		}
 	}
-	if len(specs.Monitors) > 0 {
-		for _, server := range specs.Monitors {
-			instances = append(instances, parseInstanceFromSpec(p.Cluster, constants.ComponentIDPrometheus, func() string {
+	if len(monitors) > 0 {
+		for _, server := range monitors {
+			instances = append(instances, parseInstanceFromSpec(cluster, constants.ComponentIDPrometheus, func() string {
 				return server.Host
 			}, func() []int32 {
 				return []int32{
@@ -331,13 +383,7 @@ func (p *ClusterMeta) ParseTopologyFromConfig(ctx context.Context, specs *spec.S
 		}
 	}
 
-	p.acceptInstances(instances)
-
-	err := models.GetClusterReaderWriter().UpdateInstance(ctx, instances...)
-	if err != nil {
-		return err
-	}
-	return nil
+	return instances
 }
 
 func parseInstanceFromSpec(cluster *management.Cluster, componentType constants.EMProductComponentIDType, getHost func() string, getPort func() []int32) *management.ClusterInstance {
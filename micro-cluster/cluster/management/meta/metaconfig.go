@@ -28,6 +28,7 @@ import (
 	"context"
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/deployment"
 	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/platform/config"
@@ -44,7 +45,11 @@ func (p *ClusterMeta) GenerateTopologyConfig(ctx context.Context) (string, error
 		return "", errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster topology is empty, please check it!")
 	}
 
-	t, err := template.New("topology").Parse(resourceTemplate.ClusterTopology)
+	topologyTemplate := resourceTemplate.ClusterTopology
+	if p.GetTiUPComponentType() == deployment.TiUPComponentTypeDM {
+		topologyTemplate = resourceTemplate.DMClusterTopology
+	}
+	t, err := template.New("topology").Parse(topologyTemplate)
 	if err != nil {
 		return "", errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, err.Error())
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
 	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/deployment"
 	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
@@ -132,6 +133,25 @@ func (p *ClusterMeta) GetCDCClientAddresses() []ComponentAddress {
 	return address
 }
 
+// GetDMMasterAddresses
+// @Description: communication address for DM masters to connect.
+// @Receiver p
+// @return []ComponentAddress
+func (p *ClusterMeta) GetDMMasterAddresses() []ComponentAddress {
+	instances := p.Instances[string(constants.ComponentIDDMMaster)]
+	address := make([]ComponentAddress, 0)
+
+	for _, instance := range instances {
+		if instance.Status == string(constants.ClusterInstanceRunning) {
+			address = append(address, ComponentAddress{
+				IP:   instance.HostIP[0],
+				Port: int(instance.Ports[0]),
+			})
+		}
+	}
+	return address
+}
+
 // GetTiFlashClientAddresses
 // @Description: communication address for TiFlash Servers to connect.
 // @Receiver p
@@ -296,6 +316,17 @@ func (p *ClusterMeta) IsTakenOver() bool {
 	return false
 }
 
+// GetTiUPComponentType
+// @Description: get the TiUP component which manages the cluster, tiup dm for DataMigration cluster, otherwise tiup cluster
+// @Receiver p
+// @return deployment.TiUPComponentType
+func (p *ClusterMeta) GetTiUPComponentType() deployment.TiUPComponentType {
+	if p.Cluster != nil && p.Cluster.Type == string(constants.EMProductIDDataMigration) {
+		return deployment.TiUPComponentTypeDM
+	}
+	return deployment.TiUPComponentTypeCluster
+}
+
 func (p *ClusterMeta) GetRelations(ctx context.Context) ([]*management.ClusterRelation, error) {
 	return models.GetClusterReaderWriter().GetRelations(ctx, p.Cluster.ID)
 }
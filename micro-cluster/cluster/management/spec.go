@@ -0,0 +1,832 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/parameter"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+)
+
+var applyClusterSpecFlow = workflow.WorkFlowDefine{
+	FlowName: constants.FlowApplyClusterSpec,
+	TaskNodes: map[string]*workflow.NodeDefine{
+		"start":              {"scaleOutForSpec", "scaleOutDone", "fail", workflow.SyncFuncNode, scaleOutForSpec},
+		"scaleOutDone":       {"scaleInForSpec", "scaleInDone", "fail", workflow.SyncFuncNode, scaleInForSpec},
+		"scaleInDone":        {"upgradeForSpec", "upgradeDone", "fail", workflow.SyncFuncNode, upgradeForSpec},
+		"upgradeDone":        {"updateParametersForSpec", "parametersDone", "fail", workflow.SyncFuncNode, updateParametersForSpec},
+		"parametersDone":     {"updateBackupStrategyForSpec", "backupStrategyDone", "fail", workflow.SyncFuncNode, updateBackupStrategyForSpec},
+		"backupStrategyDone": {"updateTagsForSpec", "success", "fail", workflow.SyncFuncNode, updateTagsForSpec},
+		"success":            {"end", "", "", workflow.SyncFuncNode, endApplySpec},
+		"fail":               {"fail", "", "", workflow.SyncFuncNode, endApplySpec},
+	},
+}
+
+// ExportClusterSpec
+// @Description: export the current state of a cluster as a spec
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) ExportClusterSpec(ctx context.Context, req cluster.ExportClusterSpecReq) (resp cluster.ExportClusterSpecResp, err error) {
+	clusterMeta, err := meta.Get(ctx, req.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"load cluster %s meta from db error: %s", req.ClusterID, err.Error())
+		return
+	}
+
+	resp.ClusterID = clusterMeta.Cluster.ID
+	resp.Spec, err = exportClusterSpec(ctx, clusterMeta)
+	return
+}
+
+// PlanClusterSpec
+// @Description: compute changes to reconcile a cluster with the spec, nothing will be changed
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) PlanClusterSpec(ctx context.Context, req cluster.PlanClusterSpecReq) (resp cluster.PlanClusterSpecResp, err error) {
+	clusterMeta, err := meta.Get(ctx, req.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"load cluster %s meta from db error: %s", req.ClusterID, err.Error())
+		return
+	}
+
+	resp.ClusterID = clusterMeta.Cluster.ID
+	resp.Plan, err = planClusterSpec(ctx, clusterMeta, req.Spec)
+	return
+}
+
+// ApplyClusterSpec
+// @Description: compute the plan again and apply it by existing workflows one by one, see applyClusterSpecFlow.
+// Every step takes the maintenance status of the cluster by itself, so the flow fails if another maintenance is running
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) ApplyClusterSpec(ctx context.Context, req cluster.ApplyClusterSpecReq) (resp cluster.ApplyClusterSpecResp, err error) {
+	clusterMeta, err := meta.Get(ctx, req.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"load cluster %s meta from db error: %s", req.ClusterID, err.Error())
+		return
+	}
+	resp.ClusterID = clusterMeta.Cluster.ID
+
+	if len(clusterMeta.Cluster.MaintenanceStatus) > 0 {
+		msg := fmt.Sprintf("cluster maintenance status is '%s'", string(clusterMeta.Cluster.MaintenanceStatus))
+		err = errors.NewError(errors.TIUNIMANAGER_CLUSTER_MAINTENANCE_CONFLICT, msg)
+		return
+	}
+
+	resp.Plan, err = planClusterSpec(ctx, clusterMeta, req.Spec)
+	if err != nil {
+		return
+	}
+	if resp.Plan.IsEmpty() {
+		framework.LogWithContext(ctx).Infof("cluster %s is consistent with the spec, nothing to apply", clusterMeta.Cluster.ID)
+		return
+	}
+
+	data := map[string]interface{}{
+		ContextClusterMeta:     clusterMeta,
+		ContextClusterSpecPlan: resp.Plan,
+		ContextUpgradeWay:      req.UpgradeWay,
+	}
+	resp.WorkFlowID, err = asyncApplyClusterSpec(ctx, clusterMeta, data)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"cluster %s apply spec error: %s", clusterMeta.Cluster.ID, err.Error())
+	}
+	return
+}
+
+// asyncApplyClusterSpec
+// @Description: create and start the apply flow, unlike asyncMaintenance, the maintenance status is left to every step
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter data
+// @return flowID
+// @return err
+func asyncApplyClusterSpec(ctx context.Context, clusterMeta *meta.ClusterMeta, data map[string]interface{}) (flowID string, err error) {
+	err = models.Transaction(ctx, func(transactionCtx context.Context) error {
+		return errors.OfNullable(nil).BreakIf(func() error {
+			newFlowID, flowError := workflow.GetWorkFlowService().
+				CreateWorkFlow(transactionCtx, clusterMeta.Cluster.ID, workflow.BizTypeCluster, applyClusterSpecFlow.FlowName)
+			if flowError != nil {
+				return flowError
+			}
+			flowID = newFlowID
+			for key, value := range data {
+				if initError := workflow.GetWorkFlowService().InitContext(transactionCtx, flowID, key, value); initError != nil {
+					return initError
+				}
+			}
+			return nil
+		}).BreakIf(func() error {
+			return workflow.GetWorkFlowService().Start(transactionCtx, flowID)
+		}).Present()
+	})
+	return
+}
+
+// specResourceKey identifies instances with the same zone, spec and disk
+type specResourceKey struct {
+	zone         string
+	spec         string
+	diskType     string
+	diskCapacity int
+}
+
+func newSpecResourceKey(resource structs.ClusterResourceParameterComputeResource) specResourceKey {
+	return specResourceKey{
+		zone:         structs.GetDomainNameFromCode(resource.Zone),
+		spec:         resource.Spec,
+		diskType:     resource.DiskType,
+		diskCapacity: resource.DiskCapacity,
+	}
+}
+
+func instanceResourceKey(instance *management.ClusterInstance) specResourceKey {
+	return specResourceKey{
+		zone:         instance.Zone,
+		spec:         structs.GenSpecCode(int32(instance.CpuCores), int32(instance.Memory)),
+		diskType:     instance.DiskType,
+		diskCapacity: int(instance.DiskCapacity),
+	}
+}
+
+func isParasiteComponent(componentType string) bool {
+	for _, t := range constants.ParasiteComponentIDs {
+		if string(t) == componentType {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedComponentTypes returns component types in the same order as the cluster topology
+func sortedComponentTypes(types []string) []string {
+	sort.Slice(types, func(i, j int) bool {
+		wi := constants.EMProductComponentIDType(types[i]).SortWeight()
+		wj := constants.EMProductComponentIDType(types[j]).SortWeight()
+		if wi != wj {
+			return wi > wj
+		}
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// exportClusterSpec
+// @Description: build spec from cluster meta, parameters which equal to default value are not exported
+// @Parameter ctx
+// @Parameter clusterMeta
+// @return cluster.ClusterSpec
+// @return error
+func exportClusterSpec(ctx context.Context, clusterMeta *meta.ClusterMeta) (cluster.ClusterSpec, error) {
+	spec := cluster.ClusterSpec{
+		Version:    clusterMeta.Cluster.Version,
+		Components: make([]structs.ClusterResourceParameterCompute, 0),
+		Parameters: make([]cluster.ClusterSpecParameter, 0),
+		Tags:       make([]string, 0),
+	}
+
+	types := make([]string, 0)
+	for componentType := range clusterMeta.Instances {
+		if !isParasiteComponent(componentType) {
+			types = append(types, componentType)
+		}
+	}
+	for _, componentType := range sortedComponentTypes(types) {
+		component := structs.ClusterResourceParameterCompute{
+			Type:     componentType,
+			Resource: make([]structs.ClusterResourceParameterComputeResource, 0),
+		}
+		counts := make(map[specResourceKey]int)
+		for _, instance := range clusterMeta.Instances[componentType] {
+			key := instanceResourceKey(instance)
+			if _, ok := counts[key]; !ok {
+				component.Resource = append(component.Resource, structs.ClusterResourceParameterComputeResource{
+					Zone:         structs.GenDomainCodeByName(clusterMeta.Cluster.Region, key.zone),
+					Spec:         key.spec,
+					DiskType:     key.diskType,
+					DiskCapacity: key.diskCapacity,
+				})
+			}
+			counts[key] = counts[key] + 1
+		}
+		for i := range component.Resource {
+			component.Resource[i].Count = counts[newSpecResourceKey(component.Resource[i])]
+			component.Count = component.Count + component.Resource[i].Count
+		}
+		sort.Slice(component.Resource, func(i, j int) bool {
+			return fmt.Sprintf("%+v", newSpecResourceKey(component.Resource[i])) < fmt.Sprintf("%+v", newSpecResourceKey(component.Resource[j]))
+		})
+		spec.Components = append(spec.Components, component)
+	}
+
+	_, params, _, err := models.GetClusterParameterReaderWriter().QueryClusterParameter(ctx, clusterMeta.Cluster.ID, "", "", 0, 0)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query cluster %s parameters error: %s", clusterMeta.Cluster.ID, err.Error())
+		return spec, err
+	}
+	for _, param := range params {
+		if param.ReadOnly == int(parameter.ReadOnly) {
+			continue
+		}
+		realValue, err := parseParameterRealValue(param.RealValue)
+		if err != nil {
+			return spec, err
+		}
+		if realValue.ClusterValue == "" || realValue.ClusterValue == param.DefaultValue {
+			continue
+		}
+		spec.Parameters = append(spec.Parameters, cluster.ClusterSpecParameter{
+			InstanceType: param.InstanceType,
+			Name:         param.Name,
+			Value:        realValue.ClusterValue,
+		})
+	}
+	sort.Slice(spec.Parameters, func(i, j int) bool {
+		if spec.Parameters[i].InstanceType != spec.Parameters[j].InstanceType {
+			return spec.Parameters[i].InstanceType < spec.Parameters[j].InstanceType
+		}
+		return spec.Parameters[i].Name < spec.Parameters[j].Name
+	})
+
+	strategy, err := getBackupStrategyForSpec(ctx, clusterMeta.Cluster.ID)
+	if err != nil {
+		return spec, err
+	}
+	spec.BackupStrategy = strategy
+
+	spec.Tags = append(spec.Tags, clusterMeta.Cluster.Tags...)
+	return spec, nil
+}
+
+// getBackupStrategyForSpec
+// @Description: get backup strategy of the cluster, return nil if there is no strategy
+func getBackupStrategyForSpec(ctx context.Context, clusterID string) (*structs.BackupStrategy, error) {
+	resp, err := backuprestore.GetBRService().GetBackupStrategy(ctx, cluster.GetBackupStrategyReq{ClusterID: clusterID})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get cluster %s backup strategy error: %s", clusterID, err.Error())
+		return nil, err
+	}
+	strategy := resp.Strategy
+	strategy.ClusterID = ""
+	if isSameBackupStrategy(strategy, structs.BackupStrategy{}) {
+		return nil, nil
+	}
+	return &strategy, nil
+}
+
+// mergeBackupStrategy
+// @Description: backup date and period are taken from spec, retention, filter and copy target of current strategy are kept
+// unless they are set in spec, for the whole strategy is overwritten when it is saved
+func mergeBackupStrategy(current structs.BackupStrategy, desired structs.BackupStrategy) structs.BackupStrategy {
+	merged := current
+	merged.BackupDate, merged.Period = desired.BackupDate, desired.Period
+	if desired.Retention != (structs.BackupRetentionPolicy{}) {
+		merged.Retention = desired.Retention
+	}
+	if len(desired.Filter.Databases) > 0 || len(desired.Filter.Tables) > 0 {
+		merged.Filter = desired.Filter
+	}
+	if desired.CopyTargetID != "" {
+		merged.CopyTargetID = desired.CopyTargetID
+	}
+	return merged
+}
+
+func isSameBackupStrategy(a structs.BackupStrategy, b structs.BackupStrategy) bool {
+	return a.BackupDate == b.BackupDate && a.Period == b.Period && a.Retention == b.Retention &&
+		strings.Join(a.Filter.Databases, ",") == strings.Join(b.Filter.Databases, ",") &&
+		strings.Join(a.Filter.Tables, ",") == strings.Join(b.Filter.Tables, ",") &&
+		a.CopyTargetID == b.CopyTargetID
+}
+
+// planClusterSpec
+// @Description: diff the spec against cluster meta
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter spec
+// @return cluster.ClusterSpecPlan
+// @return error
+func planClusterSpec(ctx context.Context, clusterMeta *meta.ClusterMeta, spec cluster.ClusterSpec) (cluster.ClusterSpecPlan, error) {
+	plan := cluster.ClusterSpecPlan{
+		ScaleOut:   make([]structs.ClusterResourceParameterCompute, 0),
+		ScaleIn:    make([]cluster.ClusterSpecScaleInItem, 0),
+		Parameters: make([]cluster.ClusterSpecParameterChange, 0),
+		Steps:      make([]string, 0),
+	}
+
+	if err := planComponents(ctx, clusterMeta, spec.Components, &plan); err != nil {
+		return plan, err
+	}
+	if err := planVersion(clusterMeta, spec.Version, &plan); err != nil {
+		return plan, err
+	}
+	if err := planParameters(ctx, clusterMeta, spec.Parameters, &plan); err != nil {
+		return plan, err
+	}
+	if err := planBackupStrategy(ctx, clusterMeta, spec.BackupStrategy, &plan); err != nil {
+		return plan, err
+	}
+	planTags(clusterMeta, spec.Tags, &plan)
+
+	framework.LogWithContext(ctx).Infof("plan of cluster %s spec: %v", clusterMeta.Cluster.ID, plan.Steps)
+	return plan, nil
+}
+
+func planComponents(ctx context.Context, clusterMeta *meta.ClusterMeta, components []structs.ClusterResourceParameterCompute, plan *cluster.ClusterSpecPlan) error {
+	desiredComponents := make(map[string]structs.ClusterResourceParameterCompute)
+	types := make([]string, 0)
+	for _, component := range components {
+		if isParasiteComponent(component.Type) {
+			return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "component %s is managed by the cluster itself", component.Type)
+		}
+		if _, ok := desiredComponents[component.Type]; ok {
+			return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "component %s is duplicated", component.Type)
+		}
+		desiredComponents[component.Type] = component
+		types = append(types, component.Type)
+	}
+
+	for _, componentType := range sortedComponentTypes(types) {
+		current := make(map[specResourceKey][]*management.ClusterInstance)
+		currentKeys := make([]specResourceKey, 0)
+		for _, instance := range clusterMeta.Instances[componentType] {
+			key := instanceResourceKey(instance)
+			if _, ok := current[key]; !ok {
+				currentKeys = append(currentKeys, key)
+			}
+			current[key] = append(current[key], instance)
+		}
+
+		scaleOut := structs.ClusterResourceParameterCompute{Type: componentType}
+		scaleIn := make([]*management.ClusterInstance, 0)
+		desiredTotal := 0
+		desiredKeys := make(map[specResourceKey]bool)
+		for _, resource := range desiredComponents[componentType].Resource {
+			if resource.Count < 0 || structs.ParseCpu(resource.Spec) <= 0 || structs.ParseMemory(resource.Spec) <= 0 {
+				return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "invalid resource %+v of component %s", resource, componentType)
+			}
+			key := newSpecResourceKey(resource)
+			if desiredKeys[key] {
+				return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "resource %+v of component %s is duplicated", resource, componentType)
+			}
+			desiredKeys[key] = true
+			desiredTotal = desiredTotal + resource.Count
+
+			instances := current[key]
+			if resource.Count > len(instances) {
+				item := resource
+				item.Count = resource.Count - len(instances)
+				scaleOut.Resource = append(scaleOut.Resource, item)
+				scaleOut.Count = scaleOut.Count + item.Count
+			} else if resource.Count < len(instances) {
+				scaleIn = append(scaleIn, newestInstances(instances, len(instances)-resource.Count)...)
+			}
+		}
+		for _, key := range currentKeys {
+			if !desiredKeys[key] {
+				scaleIn = append(scaleIn, newestInstances(current[key], len(current[key]))...)
+			}
+		}
+
+		if err := checkComponentCount(ctx, clusterMeta, componentType, len(clusterMeta.Instances[componentType]), scaleOut.Count, desiredTotal); err != nil {
+			return err
+		}
+
+		if scaleOut.Count > 0 {
+			plan.ScaleOut = append(plan.ScaleOut, scaleOut)
+			for _, resource := range scaleOut.Resource {
+				plan.Steps = append(plan.Steps, fmt.Sprintf("scale out %d %s with %s in zone %s",
+					resource.Count, componentType, resource.Spec, resource.Zone))
+			}
+		}
+		for _, instance := range scaleIn {
+			item := cluster.ClusterSpecScaleInItem{
+				InstanceID: instance.ID,
+				Type:       componentType,
+				Zone:       structs.GenDomainCodeByName(clusterMeta.Cluster.Region, instance.Zone),
+				Spec:       structs.GenSpecCode(int32(instance.CpuCores), int32(instance.Memory)),
+				HostIP:     strings.Join(instance.HostIP, ","),
+			}
+			plan.ScaleIn = append(plan.ScaleIn, item)
+			plan.Steps = append(plan.Steps, fmt.Sprintf("scale in %s instance %s with %s on %s",
+				componentType, item.InstanceID, item.Spec, item.HostIP))
+		}
+	}
+	return nil
+}
+
+// newestInstances returns the last created count instances, which will be scaled in first
+func newestInstances(instances []*management.ClusterInstance, count int) []*management.ClusterInstance {
+	sorted := make([]*management.ClusterInstance, len(instances))
+	copy(sorted, instances)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+	return sorted[:count]
+}
+
+// checkComponentCount
+// @Description: check count of a component during and after applying, scale out is always applied before scale in,
+// and instances are scaled in one by one, so every count between them will be reached
+func checkComponentCount(ctx context.Context, clusterMeta *meta.ClusterMeta, componentType string, current, scaleOut, desired int) error {
+	if current == desired && scaleOut == 0 {
+		return nil
+	}
+	if desired == 0 && clusterMeta.IsComponentRequired(ctx, componentType) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "component %s is required", componentType)
+	}
+	switch componentType {
+	case string(constants.ComponentIDPD):
+		for count := current + scaleOut; count >= desired; count-- {
+			if count%2 == 0 || count > meta.DefaultPDMaxCount {
+				return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID,
+					"count of PD will be %d when applying the spec, suggest PD instances [1, 3, 5, 7]", count)
+			}
+		}
+	case string(constants.ComponentIDTiKV):
+		if desired < clusterMeta.Cluster.Copies {
+			return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID,
+				"count of TiKV %d is less than the copies %d", desired, clusterMeta.Cluster.Copies)
+		}
+	}
+	return nil
+}
+
+func planVersion(clusterMeta *meta.ClusterMeta, version string, plan *cluster.ClusterSpecPlan) error {
+	if version == "" || version == clusterMeta.Cluster.Version {
+		return nil
+	}
+	newer, err := meta.CompareTiDBVersion(version, clusterMeta.Cluster.Version)
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, err.Error(), err)
+	}
+	if !newer {
+		return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID,
+			"version %s is older than %s, downgrade is not supported", version, clusterMeta.Cluster.Version)
+	}
+	plan.Upgrade = &cluster.ClusterSpecUpgrade{
+		CurrentVersion: clusterMeta.Cluster.Version,
+		TargetVersion:  version,
+	}
+	plan.Steps = append(plan.Steps, fmt.Sprintf("upgrade cluster from %s to %s", clusterMeta.Cluster.Version, version))
+	return nil
+}
+
+func planParameters(ctx context.Context, clusterMeta *meta.ClusterMeta, desired []cluster.ClusterSpecParameter, plan *cluster.ClusterSpecPlan) error {
+	if len(desired) == 0 {
+		return nil
+	}
+	_, params, _, err := models.GetClusterParameterReaderWriter().QueryClusterParameter(ctx, clusterMeta.Cluster.ID, "", "", 0, 0)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query cluster %s parameters error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+
+	planned := make(map[string]bool)
+	for _, want := range desired {
+		key := fmt.Sprintf("%s.%s", want.InstanceType, want.Name)
+		if planned[key] {
+			return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "parameter %s is duplicated", key)
+		}
+		planned[key] = true
+
+		found := false
+		for _, param := range params {
+			if param.InstanceType != want.InstanceType || param.Name != want.Name {
+				continue
+			}
+			found = true
+			if param.ReadOnly == int(parameter.ReadOnly) {
+				return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "parameter %s is read-only", key)
+			}
+			realValue, err := parseParameterRealValue(param.RealValue)
+			if err != nil {
+				return err
+			}
+			if realValue.ClusterValue == want.Value {
+				break
+			}
+			change := cluster.ClusterSpecParameterChange{
+				ParamId:      param.ID,
+				InstanceType: param.InstanceType,
+				Name:         param.Name,
+				CurrentValue: realValue.ClusterValue,
+				DesiredValue: want.Value,
+				HasReboot:    param.HasReboot == int(parameter.Reboot),
+			}
+			plan.Parameters = append(plan.Parameters, change)
+			plan.Steps = append(plan.Steps, fmt.Sprintf("update parameter %s from '%s' to '%s'", key, change.CurrentValue, change.DesiredValue))
+			break
+		}
+		if !found {
+			return errors.NewErrorf(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, "parameter %s is not found in cluster %s", key, clusterMeta.Cluster.ID)
+		}
+	}
+	return nil
+}
+
+func parseParameterRealValue(value string) (structs.ParameterRealValue, error) {
+	realValue := structs.ParameterRealValue{}
+	if len(value) > 0 {
+		if err := json.Unmarshal([]byte(value), &realValue); err != nil {
+			return realValue, errors.WrapError(errors.TIUNIMANAGER_CONVERT_OBJ_FAILED, "parse parameter real value failed", err)
+		}
+	}
+	return realValue, nil
+}
+
+func planBackupStrategy(ctx context.Context, clusterMeta *meta.ClusterMeta, desired *structs.BackupStrategy, plan *cluster.ClusterSpecPlan) error {
+	if desired == nil {
+		return nil
+	}
+	current, err := getBackupStrategyForSpec(ctx, clusterMeta.Cluster.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		current = &structs.BackupStrategy{}
+	}
+	merged := mergeBackupStrategy(*current, *desired)
+	if isSameBackupStrategy(*current, merged) {
+		return nil
+	}
+	// invalid strategy would fail the apply flow after components have been changed
+	if err = backuprestore.CheckBackupStrategy(merged); err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, fmt.Sprintf("invalid backup strategy, %s", err.Error()), err)
+	}
+	merged.ClusterID = clusterMeta.Cluster.ID
+
+	plan.BackupStrategy = &cluster.ClusterSpecBackupStrategyChange{
+		Current: *current,
+		Desired: merged,
+	}
+	plan.Steps = append(plan.Steps, fmt.Sprintf("update backup strategy from '%s %s' to '%s %s'",
+		current.BackupDate, current.Period, merged.BackupDate, merged.Period))
+	return nil
+}
+
+func planTags(clusterMeta *meta.ClusterMeta, tags []string, plan *cluster.ClusterSpecPlan) {
+	if tags == nil {
+		return
+	}
+	desired := make([]string, 0)
+	existed := make(map[string]bool)
+	for _, tag := range tags {
+		if !existed[tag] {
+			existed[tag] = true
+			desired = append(desired, tag)
+		}
+	}
+	// the takeover tag is kept by the system
+	if clusterMeta.IsTakenOver() && !existed[meta.TagTakeover] {
+		desired = append(desired, meta.TagTakeover)
+	}
+
+	current := append(make([]string, 0), clusterMeta.Cluster.Tags...)
+	if strings.Join(current, ",") == strings.Join(desired, ",") {
+		return
+	}
+	plan.Tags = &cluster.ClusterSpecTagsChange{
+		Current: current,
+		Desired: desired,
+	}
+	plan.Steps = append(plan.Steps, fmt.Sprintf("update tags from %v to %v", current, desired))
+}
+
+// waitSpecStep waits for the workflow started by a step of applyClusterSpecFlow
+var waitSpecStep = func(ctx context.Context, workflowID string) error {
+	return meta.WaitWorkflow(ctx, workflowID, 10*time.Second, 30*24*time.Hour)
+}
+
+func scaleOutForSpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	var plan cluster.ClusterSpecPlan
+	if err := getSpecContext(context, &clusterMeta, &plan); err != nil {
+		return err
+	}
+	if len(plan.ScaleOut) == 0 {
+		node.Record("no instance to scale out")
+		return nil
+	}
+
+	resp, err := (&Manager{}).ScaleOut(context.Context, cluster.ScaleOutClusterReq{
+		ClusterID: clusterMeta.Cluster.ID,
+		ClusterResourceInfo: structs.ClusterResourceInfo{
+			InstanceResource: plan.ScaleOut,
+		},
+	})
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf("scale out cluster %s error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	if err = waitSpecStep(context.Context, resp.WorkFlowID); err != nil {
+		return err
+	}
+	node.Record(fmt.Sprintf("scale out cluster %s, workflow %s", clusterMeta.Cluster.ID, resp.WorkFlowID))
+	return nil
+}
+
+func scaleInForSpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	var plan cluster.ClusterSpecPlan
+	if err := getSpecContext(context, &clusterMeta, &plan); err != nil {
+		return err
+	}
+	if len(plan.ScaleIn) == 0 {
+		node.Record("no instance to scale in")
+		return nil
+	}
+
+	// scale in instances one by one
+	for _, item := range plan.ScaleIn {
+		resp, err := (&Manager{}).ScaleIn(context.Context, cluster.ScaleInClusterReq{
+			ClusterID:  clusterMeta.Cluster.ID,
+			InstanceID: item.InstanceID,
+		})
+		if err != nil {
+			framework.LogWithContext(context.Context).Errorf("scale in instance %s of cluster %s error: %s",
+				item.InstanceID, clusterMeta.Cluster.ID, err.Error())
+			return err
+		}
+		if err = waitSpecStep(context.Context, resp.WorkFlowID); err != nil {
+			return err
+		}
+		node.Record(fmt.Sprintf("scale in %s instance %s, workflow %s", item.Type, item.InstanceID, resp.WorkFlowID))
+	}
+	return nil
+}
+
+func upgradeForSpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	var plan cluster.ClusterSpecPlan
+	if err := getSpecContext(context, &clusterMeta, &plan); err != nil {
+		return err
+	}
+	if plan.Upgrade == nil {
+		node.Record("no need to upgrade")
+		return nil
+	}
+	var upgradeWay string
+	if err := context.GetData(ContextUpgradeWay, &upgradeWay); err != nil {
+		return err
+	}
+
+	resp, err := (&Manager{}).InPlaceUpgradeCluster(context.Context, cluster.UpgradeClusterReq{
+		ClusterID:     clusterMeta.Cluster.ID,
+		TargetVersion: plan.Upgrade.TargetVersion,
+		UpgradeType:   string(constants.UpgradeTypeInPlace),
+		UpgradeWay:    upgradeWay,
+	})
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf("upgrade cluster %s error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	if err = waitSpecStep(context.Context, resp.WorkFlowID); err != nil {
+		return err
+	}
+	node.Record(fmt.Sprintf("upgrade cluster %s to %s, workflow %s", clusterMeta.Cluster.ID, plan.Upgrade.TargetVersion, resp.WorkFlowID))
+	return nil
+}
+
+func updateParametersForSpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	var plan cluster.ClusterSpecPlan
+	if err := getSpecContext(context, &clusterMeta, &plan); err != nil {
+		return err
+	}
+	if len(plan.Parameters) == 0 {
+		node.Record("no parameter to update")
+		return nil
+	}
+
+	params := make([]structs.ClusterParameterSampleInfo, 0)
+	reboot := false
+	for _, change := range plan.Parameters {
+		params = append(params, structs.ClusterParameterSampleInfo{
+			ParamId:   change.ParamId,
+			RealValue: structs.ParameterRealValue{ClusterValue: change.DesiredValue},
+		})
+		reboot = reboot || change.HasReboot
+	}
+	resp, err := parameter.NewManager().UpdateClusterParameters(context.Context, cluster.UpdateClusterParametersReq{
+		ClusterID: clusterMeta.Cluster.ID,
+		Params:    params,
+		Reboot:    reboot,
+	}, true)
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf("update cluster %s parameters error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	if err = waitSpecStep(context.Context, resp.WorkFlowID); err != nil {
+		return err
+	}
+	node.Record(fmt.Sprintf("update %d parameters of cluster %s, workflow %s", len(params), clusterMeta.Cluster.ID, resp.WorkFlowID))
+	return nil
+}
+
+func updateBackupStrategyForSpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	var plan cluster.ClusterSpecPlan
+	if err := getSpecContext(context, &clusterMeta, &plan); err != nil {
+		return err
+	}
+	if plan.BackupStrategy == nil {
+		node.Record("no need to update backup strategy")
+		return nil
+	}
+
+	_, err := backuprestore.GetBRService().SaveBackupStrategy(context.Context, cluster.SaveBackupStrategyReq{
+		ClusterID: clusterMeta.Cluster.ID,
+		Strategy:  plan.BackupStrategy.Desired,
+	})
+	if err != nil {
+		framework.LogWithContext(context.Context).Errorf("save cluster %s backup strategy error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	node.Record(fmt.Sprintf("update backup strategy of cluster %s", clusterMeta.Cluster.ID))
+	return nil
+}
+
+func updateTagsForSpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	var plan cluster.ClusterSpecPlan
+	if err := getSpecContext(context, &clusterMeta, &plan); err != nil {
+		return err
+	}
+	if plan.Tags == nil {
+		node.Record("no need to update tags")
+		return nil
+	}
+
+	// load cluster again, it has been changed by previous steps
+	current, err := models.GetClusterReaderWriter().Get(context.Context, clusterMeta.Cluster.ID)
+	if err != nil {
+		return err
+	}
+	current.Tags = plan.Tags.Desired
+	if err = models.GetClusterReaderWriter().UpdateClusterInfo(context.Context, current); err != nil {
+		framework.LogWithContext(context.Context).Errorf("update cluster %s tags error: %s", clusterMeta.Cluster.ID, err.Error())
+		return err
+	}
+	node.Record(fmt.Sprintf("update tags of cluster %s to %v", clusterMeta.Cluster.ID, plan.Tags.Desired))
+	return nil
+}
+
+func endApplySpec(node *workflowModel.WorkFlowNode, context *workflow.FlowContext) error {
+	var clusterMeta meta.ClusterMeta
+	if err := context.GetData(ContextClusterMeta, &clusterMeta); err != nil {
+		return err
+	}
+	framework.LogWithContext(context.Context).Infof("end applying spec to cluster %s", clusterMeta.Cluster.ID)
+	return nil
+}
+
+func getSpecContext(context *workflow.FlowContext, clusterMeta *meta.ClusterMeta, plan *cluster.ClusterSpecPlan) error {
+	if err := context.GetData(ContextClusterMeta, clusterMeta); err != nil {
+		return err
+	}
+	return context.GetData(ContextClusterSpecPlan, plan)
+}
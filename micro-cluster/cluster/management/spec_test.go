@@ -0,0 +1,501 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	em_errors "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	modelParameter "github.com/pingcap/tiunimanager/models/cluster/parameter"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/parametergroup"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	mock_br_service "github.com/pingcap/tiunimanager/test/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclusterparameter"
+	mock_workflow_service "github.com/pingcap/tiunimanager/test/mockworkflow"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockSpecInstance(id string, componentType string, spec string, createdAt time.Time) *management.ClusterInstance {
+	return &management.ClusterInstance{
+		Entity: common.Entity{
+			ID:        id,
+			CreatedAt: createdAt,
+			Status:    string(constants.ClusterInstanceRunning),
+		},
+		Type:         componentType,
+		CpuCores:     int8(structs.ParseCpu(spec)),
+		Memory:       int8(structs.ParseMemory(spec)),
+		Zone:         "Zone1",
+		DiskType:     "SSD",
+		DiskCapacity: 100,
+		HostIP:       []string{"127.0.0." + id},
+	}
+}
+
+func mockSpecClusterMeta() *meta.ClusterMeta {
+	now := time.Now()
+	return &meta.ClusterMeta{
+		Cluster: &management.Cluster{
+			Entity: common.Entity{
+				ID: "cluster01",
+			},
+			Region:  "Region1",
+			Version: "v5.2.2",
+			Copies:  3,
+			Tags:    []string{"tag1"},
+		},
+		Instances: map[string][]*management.ClusterInstance{
+			"TiDB": {
+				mockSpecInstance("1", "TiDB", "4C8G", now.Add(-time.Hour)),
+				mockSpecInstance("2", "TiDB", "4C8G", now),
+			},
+			"TiKV": {
+				mockSpecInstance("3", "TiKV", "8C16G", now),
+				mockSpecInstance("4", "TiKV", "8C16G", now),
+				mockSpecInstance("5", "TiKV", "8C16G", now),
+			},
+			"PD": {
+				mockSpecInstance("6", "PD", "4C8G", now),
+			},
+			"Grafana": {
+				mockSpecInstance("7", "Grafana", "4C8G", now),
+			},
+		},
+	}
+}
+
+func mockSpecParameters(parameterRW *mockclusterparameter.MockReaderWriter) {
+	parameterRW.EXPECT().QueryClusterParameter(gomock.Any(), "cluster01", "", "", 0, 0).
+		Return("pg01", []*modelParameter.ClusterParamDetail{
+			{
+				Parameter:    parametergroup.Parameter{ID: "p1", Name: "log.level", InstanceType: "TiDB"},
+				DefaultValue: "info",
+				RealValue:    "{\"clusterValue\":\"warn\"}",
+			},
+			{
+				Parameter:    parametergroup.Parameter{ID: "p2", Name: "mem-quota-query", InstanceType: "TiDB", HasReboot: 1},
+				DefaultValue: "1024",
+				RealValue:    "{\"clusterValue\":\"1024\"}",
+			},
+			{
+				Parameter:    parametergroup.Parameter{ID: "p3", Name: "data-dir", InstanceType: "TiKV", ReadOnly: 1},
+				DefaultValue: "",
+				RealValue:    "{\"clusterValue\":\"/data\"}",
+			},
+		}, int64(3), nil).AnyTimes()
+}
+
+func TestManager_ExportClusterSpec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		clusterMeta := mockSpecClusterMeta()
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		instances := make([]*management.ClusterInstance, 0)
+		for _, v := range clusterMeta.Instances {
+			instances = append(instances, v...)
+		}
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(clusterMeta.Cluster, instances, []*management.DBUser{}, nil)
+
+		parameterRW := mockclusterparameter.NewMockReaderWriter(ctrl)
+		models.SetClusterParameterReaderWriter(parameterRW)
+		mockSpecParameters(parameterRW)
+
+		brService := mock_br_service.NewMockBRService(ctrl)
+		backuprestore.MockBRService(brService)
+		brService.EXPECT().GetBackupStrategy(gomock.Any(), gomock.Any()).Return(cluster.GetBackupStrategyResp{
+			Strategy: structs.BackupStrategy{ClusterID: "cluster01", BackupDate: "Monday", Period: "0:00-1:00",
+				Retention: structs.BackupRetentionPolicy{KeepLast: 3}, CopyTargetID: "target01"},
+		}, nil)
+
+		resp, err := (&Manager{}).ExportClusterSpec(context.TODO(), cluster.ExportClusterSpecReq{ClusterID: "cluster01"})
+		assert.NoError(t, err)
+		assert.Equal(t, "v5.2.2", resp.Spec.Version)
+		assert.Equal(t, 3, len(resp.Spec.Components))
+		assert.Equal(t, "PD", resp.Spec.Components[0].Type)
+		assert.Equal(t, "TiDB", resp.Spec.Components[1].Type)
+		assert.Equal(t, 2, resp.Spec.Components[1].Count)
+		assert.Equal(t, "Region1,Zone1", resp.Spec.Components[1].Resource[0].Zone)
+		assert.Equal(t, "TiKV", resp.Spec.Components[2].Type)
+		assert.Equal(t, 3, resp.Spec.Components[2].Resource[0].Count)
+		assert.Equal(t, "8C16G", resp.Spec.Components[2].Resource[0].Spec)
+		assert.Equal(t, []cluster.ClusterSpecParameter{{InstanceType: "TiDB", Name: "log.level", Value: "warn"}}, resp.Spec.Parameters)
+		assert.Equal(t, "Monday", resp.Spec.BackupStrategy.BackupDate)
+		assert.Equal(t, uint32(3), resp.Spec.BackupStrategy.Retention.KeepLast)
+		assert.Equal(t, "target01", resp.Spec.BackupStrategy.CopyTargetID)
+		assert.Empty(t, resp.Spec.BackupStrategy.ClusterID)
+		assert.Equal(t, []string{"tag1"}, resp.Spec.Tags)
+	})
+
+	t.Run("cluster not found", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster02").Return(nil, nil, nil, em_errors.Error(em_errors.TIUNIMANAGER_CLUSTER_NOT_FOUND))
+
+		_, err := (&Manager{}).ExportClusterSpec(context.TODO(), cluster.ExportClusterSpecReq{ClusterID: "cluster02"})
+		assert.Error(t, err)
+	})
+}
+
+func Test_planClusterSpec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parameterRW := mockclusterparameter.NewMockReaderWriter(ctrl)
+	models.SetClusterParameterReaderWriter(parameterRW)
+	mockSpecParameters(parameterRW)
+
+	brService := mock_br_service.NewMockBRService(ctrl)
+	backuprestore.MockBRService(brService)
+	brService.EXPECT().GetBackupStrategy(gomock.Any(), gomock.Any()).Return(cluster.GetBackupStrategyResp{
+		Strategy: structs.BackupStrategy{ClusterID: "cluster01", BackupDate: "Monday", Period: "0:00-1:00",
+			Retention: structs.BackupRetentionPolicy{KeepDays: 7}, Filter: structs.BackupFilter{Databases: []string{"db1"}}, CopyTargetID: "target01"},
+	}, nil).AnyTimes()
+
+	t.Run("empty", func(t *testing.T) {
+		plan, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{})
+		assert.NoError(t, err)
+		assert.True(t, plan.IsEmpty())
+	})
+
+	t.Run("exported", func(t *testing.T) {
+		clusterMeta := mockSpecClusterMeta()
+		spec, err := exportClusterSpec(context.TODO(), clusterMeta)
+		assert.NoError(t, err)
+		plan, err := planClusterSpec(context.TODO(), clusterMeta, spec)
+		assert.NoError(t, err)
+		assert.True(t, plan.IsEmpty())
+	})
+
+	t.Run("scale", func(t *testing.T) {
+		plan, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "TiDB", Resource: []structs.ClusterResourceParameterComputeResource{
+					{Zone: "Region1,Zone1", Spec: "4C8G", DiskType: "SSD", DiskCapacity: 100, Count: 1},
+				}},
+				{Type: "TiKV", Resource: []structs.ClusterResourceParameterComputeResource{
+					{Zone: "Region1,Zone1", Spec: "16C32G", DiskType: "SSD", DiskCapacity: 100, Count: 3},
+				}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(plan.ScaleOut))
+		assert.Equal(t, "TiKV", plan.ScaleOut[0].Type)
+		assert.Equal(t, 3, plan.ScaleOut[0].Count)
+		assert.Equal(t, 4, len(plan.ScaleIn))
+		// the newest TiDB instance is scaled in
+		assert.Equal(t, "2", plan.ScaleIn[0].InstanceID)
+		assert.Equal(t, "TiKV", plan.ScaleIn[1].Type)
+		assert.Equal(t, 5, len(plan.Steps))
+	})
+
+	t.Run("invalid component", func(t *testing.T) {
+		_, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "Grafana"},
+			},
+		})
+		assert.Error(t, err)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "TiDB"}, {Type: "TiDB"},
+			},
+		})
+		assert.Error(t, err)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "TiDB", Resource: []structs.ClusterResourceParameterComputeResource{
+					{Zone: "Region1,Zone1", Spec: "invalid", Count: 1},
+				}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("pd count", func(t *testing.T) {
+		_, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "PD", Resource: []structs.ClusterResourceParameterComputeResource{
+					{Zone: "Region1,Zone1", Spec: "4C8G", DiskType: "SSD", DiskCapacity: 100, Count: 2},
+				}},
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, err.(em_errors.EMError).GetCode())
+
+		// scaling in PD from 5 to 3 passes through 4
+		clusterMeta := mockSpecClusterMeta()
+		now := time.Now()
+		for _, id := range []string{"8", "9", "10", "11"} {
+			clusterMeta.Instances["PD"] = append(clusterMeta.Instances["PD"], mockSpecInstance(id, "PD", "4C8G", now))
+		}
+		_, err = planClusterSpec(context.TODO(), clusterMeta, cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "PD", Resource: []structs.ClusterResourceParameterComputeResource{
+					{Zone: "Region1,Zone1", Spec: "4C8G", DiskType: "SSD", DiskCapacity: 100, Count: 3},
+				}},
+			},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, err.(em_errors.EMError).GetCode())
+		assert.Contains(t, err.Error(), "count of PD will be 4")
+	})
+
+	t.Run("tikv copies", func(t *testing.T) {
+		_, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Components: []structs.ClusterResourceParameterCompute{
+				{Type: "TiKV", Resource: []structs.ClusterResourceParameterComputeResource{
+					{Zone: "Region1,Zone1", Spec: "8C16G", DiskType: "SSD", DiskCapacity: 100, Count: 2},
+				}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("version", func(t *testing.T) {
+		plan, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{Version: "v5.3.0"})
+		assert.NoError(t, err)
+		assert.Equal(t, "v5.3.0", plan.Upgrade.TargetVersion)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{Version: "v5.1.0"})
+		assert.Error(t, err)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{Version: "5.3"})
+		assert.Error(t, err)
+	})
+
+	t.Run("parameters", func(t *testing.T) {
+		plan, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Parameters: []cluster.ClusterSpecParameter{
+				{InstanceType: "TiDB", Name: "log.level", Value: "warn"},
+				{InstanceType: "TiDB", Name: "mem-quota-query", Value: "2048"},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(plan.Parameters))
+		assert.Equal(t, "p2", plan.Parameters[0].ParamId)
+		assert.True(t, plan.Parameters[0].HasReboot)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Parameters: []cluster.ClusterSpecParameter{{InstanceType: "TiKV", Name: "data-dir", Value: "/data2"}},
+		})
+		assert.Error(t, err)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			Parameters: []cluster.ClusterSpecParameter{{InstanceType: "TiKV", Name: "unknown", Value: "1"}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("backup strategy", func(t *testing.T) {
+		plan, err := planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			BackupStrategy: &structs.BackupStrategy{BackupDate: "Monday,Friday", Period: "0:00-1:00"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Monday,Friday", plan.BackupStrategy.Desired.BackupDate)
+		assert.Equal(t, "cluster01", plan.BackupStrategy.Desired.ClusterID)
+		assert.Equal(t, uint32(7), plan.BackupStrategy.Desired.Retention.KeepDays)
+		assert.Equal(t, []string{"db1"}, plan.BackupStrategy.Desired.Filter.Databases)
+		assert.Equal(t, "target01", plan.BackupStrategy.Desired.CopyTargetID)
+
+		plan, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			BackupStrategy: &structs.BackupStrategy{BackupDate: "Monday", Period: "0:00-1:00"},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, plan.BackupStrategy)
+
+		plan, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			BackupStrategy: &structs.BackupStrategy{BackupDate: "Monday", Period: "0:00-1:00", Retention: structs.BackupRetentionPolicy{KeepLast: 5}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, structs.BackupRetentionPolicy{KeepLast: 5}, plan.BackupStrategy.Desired.Retention)
+		assert.Equal(t, "target01", plan.BackupStrategy.Desired.CopyTargetID)
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			BackupStrategy: &structs.BackupStrategy{BackupDate: "Someday", Period: "0:00-1:00"},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_SPEC_INVALID, err.(em_errors.EMError).GetCode())
+
+		_, err = planClusterSpec(context.TODO(), mockSpecClusterMeta(), cluster.ClusterSpec{
+			BackupStrategy: &structs.BackupStrategy{BackupDate: "Monday", Period: "2:00-1:00"},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		clusterMeta := mockSpecClusterMeta()
+		clusterMeta.Cluster.Tags = []string{meta.TagTakeover}
+		plan, err := planClusterSpec(context.TODO(), clusterMeta, cluster.ClusterSpec{
+			Tags: []string{"tag2", "tag2"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"tag2", meta.TagTakeover}, plan.Tags.Desired)
+	})
+}
+
+func TestManager_ApplyClusterSpec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	workflowService := mock_workflow_service.NewMockWorkFlowService(ctrl)
+	workflow.MockWorkFlowService(workflowService)
+	defer workflow.MockWorkFlowService(workflow.NewWorkFlowManager())
+
+	mockMeta := func(maintenanceStatus constants.ClusterMaintenanceStatus) {
+		clusterMeta := mockSpecClusterMeta()
+		clusterMeta.Cluster.MaintenanceStatus = maintenanceStatus
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(clusterMeta.Cluster, clusterMeta.Instances["TiDB"], []*management.DBUser{}, nil)
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		mockMeta(constants.ClusterMaintenanceNone)
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), "cluster01", workflow.BizTypeCluster, constants.FlowApplyClusterSpec).Return("flow01", nil)
+		workflowService.EXPECT().InitContext(gomock.Any(), "flow01", gomock.Any(), gomock.Any()).Return(nil).Times(3)
+		workflowService.EXPECT().Start(gomock.Any(), "flow01").Return(nil)
+
+		resp, err := (&Manager{}).ApplyClusterSpec(context.TODO(), cluster.ApplyClusterSpecReq{
+			ClusterID: "cluster01",
+			Spec:      cluster.ClusterSpec{Tags: []string{"tag2"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "flow01", resp.WorkFlowID)
+		assert.NotNil(t, resp.Plan.Tags)
+	})
+
+	t.Run("nothing to apply", func(t *testing.T) {
+		mockMeta(constants.ClusterMaintenanceNone)
+		resp, err := (&Manager{}).ApplyClusterSpec(context.TODO(), cluster.ApplyClusterSpecReq{
+			ClusterID: "cluster01",
+			Spec:      cluster.ClusterSpec{Tags: []string{"tag1"}},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, resp.WorkFlowID)
+	})
+
+	t.Run("maintenance", func(t *testing.T) {
+		mockMeta(constants.ClusterMaintenanceScaleOut)
+		_, err := (&Manager{}).ApplyClusterSpec(context.TODO(), cluster.ApplyClusterSpecReq{
+			ClusterID: "cluster01",
+			Spec:      cluster.ClusterSpec{Tags: []string{"tag2"}},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, em_errors.TIUNIMANAGER_CLUSTER_MAINTENANCE_CONFLICT, err.(em_errors.EMError).GetCode())
+	})
+
+	t.Run("create flow failed", func(t *testing.T) {
+		mockMeta(constants.ClusterMaintenanceNone)
+		workflowService.EXPECT().CreateWorkFlow(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return("", fmt.Errorf("create failed"))
+
+		_, err := (&Manager{}).ApplyClusterSpec(context.TODO(), cluster.ApplyClusterSpecReq{
+			ClusterID: "cluster01",
+			Spec:      cluster.ClusterSpec{Tags: []string{"tag2"}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestApplySpecExecutors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newContext := func(plan cluster.ClusterSpecPlan) *workflow.FlowContext {
+		flowContext := workflow.NewFlowContext(context.TODO(), make(map[string]string))
+		flowContext.SetData(ContextClusterMeta, mockSpecClusterMeta())
+		flowContext.SetData(ContextClusterSpecPlan, plan)
+		flowContext.SetData(ContextUpgradeWay, string(constants.UpgradeWayOnline))
+		return flowContext
+	}
+
+	t.Run("nothing to do", func(t *testing.T) {
+		flowContext := newContext(cluster.ClusterSpecPlan{})
+		for _, executor := range []workflow.NodeExecutor{scaleOutForSpec, scaleInForSpec, upgradeForSpec,
+			updateParametersForSpec, updateBackupStrategyForSpec, updateTagsForSpec, endApplySpec} {
+			assert.NoError(t, executor(&workflowModel.WorkFlowNode{}, flowContext))
+		}
+	})
+
+	t.Run("scale in", func(t *testing.T) {
+		waitSpecStep = func(ctx context.Context, workflowID string) error {
+			return nil
+		}
+		defer func() {
+			waitSpecStep = func(ctx context.Context, workflowID string) error {
+				return meta.WaitWorkflow(ctx, workflowID, 10*time.Second, 30*24*time.Hour)
+			}
+		}()
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(nil, nil, nil, em_errors.Error(em_errors.TIUNIMANAGER_CLUSTER_NOT_FOUND))
+
+		flowContext := newContext(cluster.ClusterSpecPlan{
+			ScaleIn: []cluster.ClusterSpecScaleInItem{{InstanceID: "2", Type: "TiDB"}},
+		})
+		assert.Error(t, scaleInForSpec(&workflowModel.WorkFlowNode{}, flowContext))
+	})
+
+	t.Run("backup strategy", func(t *testing.T) {
+		brService := mock_br_service.NewMockBRService(ctrl)
+		backuprestore.MockBRService(brService)
+		brService.EXPECT().SaveBackupStrategy(gomock.Any(), cluster.SaveBackupStrategyReq{
+			ClusterID: "cluster01",
+			Strategy:  structs.BackupStrategy{ClusterID: "cluster01", BackupDate: "Monday", Period: "0:00-1:00"},
+		}).Return(cluster.SaveBackupStrategyResp{}, nil)
+
+		flowContext := newContext(cluster.ClusterSpecPlan{
+			BackupStrategy: &cluster.ClusterSpecBackupStrategyChange{
+				Desired: structs.BackupStrategy{ClusterID: "cluster01", BackupDate: "Monday", Period: "0:00-1:00"},
+			},
+		})
+		assert.NoError(t, updateBackupStrategyForSpec(&workflowModel.WorkFlowNode{}, flowContext))
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "cluster01").Return(&management.Cluster{Entity: common.Entity{ID: "cluster01"}}, nil)
+		clusterRW.EXPECT().UpdateClusterInfo(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, template *management.Cluster) error {
+			assert.Equal(t, []string{"tag2"}, template.Tags)
+			return nil
+		})
+
+		flowContext := newContext(cluster.ClusterSpecPlan{
+			Tags: &cluster.ClusterSpecTagsChange{Current: []string{"tag1"}, Desired: []string{"tag2"}},
+		})
+		assert.NoError(t, updateTagsForSpec(&workflowModel.WorkFlowNode{}, flowContext))
+	})
+}
@@ -0,0 +1,497 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+)
+
+const hostQueryPageSize = 100
+
+// topologyPlacement an instance joined with the location of its host
+type topologyPlacement struct {
+	instance *management.ClusterInstance
+	// host is nil if the host is not found in resource pool, location of the instance is used instead
+	host   *structs.HostInfo
+	region string
+	zone   string
+	rack   string
+	hostID string
+}
+
+// domainKey returns the failure domain of the placement at the level
+func (p topologyPlacement) domainKey(level constants.HierarchyTreeNodeLevel) string {
+	switch level {
+	case constants.REGION:
+		return p.region
+	case constants.ZONE:
+		return strings.Join([]string{p.region, p.zone}, "/")
+	case constants.RACK:
+		return strings.Join([]string{p.region, p.zone, p.rack}, "/")
+	case constants.HOST:
+		return p.hostID
+	default:
+		return ""
+	}
+}
+
+// GetClusterTopologyGraph
+// @Description: get instances of a cluster mapped to hosts, racks, zones and regions, and risky placements of them
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) GetClusterTopologyGraph(ctx context.Context, req cluster.GetClusterTopologyGraphReq) (resp cluster.GetClusterTopologyGraphResp, err error) {
+	clusterMeta, err := meta.Get(ctx, req.ClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf(
+			"load cluster %s meta from db error: %s", req.ClusterID, err.Error())
+		return
+	}
+
+	hosts := make(map[string]*structs.HostInfo)
+	placements, err := getClusterPlacements(ctx, clusterMeta, hosts)
+	if err != nil {
+		return
+	}
+
+	resp.ClusterID = clusterMeta.Cluster.ID
+	resp.Regions = buildTopologyGraph(placements)
+	resp.Risks = evaluatePlacementRisks(clusterMeta, placements)
+	return
+}
+
+// GetHostTopologyGraph
+// @Description: get instances on hosts of a subtree specified by region, zone, rack or host ip,
+// and the impact on each cluster if the subtree fails
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) GetHostTopologyGraph(ctx context.Context, req cluster.GetHostTopologyGraphReq) (resp cluster.GetHostTopologyGraphResp, err error) {
+	subtree, err := queryHostsInLocation(ctx, &req.Location, &structs.HostFilter{})
+	if err != nil {
+		return
+	}
+	resp.Regions = make([]cluster.TopologyGraphRegion, 0)
+	resp.Impacts = make([]cluster.TopologyFailureImpact, 0)
+	if len(subtree) == 0 {
+		return
+	}
+
+	hosts := make(map[string]*structs.HostInfo)
+	subtreeHosts := make(map[string]bool)
+	hostIDs := make([]string, 0)
+	for i := range subtree {
+		hosts[subtree[i].ID] = &subtree[i]
+		subtreeHosts[subtree[i].ID] = true
+		hostIDs = append(hostIDs, subtree[i].ID)
+	}
+	items, err := models.GetClusterReaderWriter().QueryHostInstances(ctx, hostIDs)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query instances on hosts error: %s", err.Error())
+		return
+	}
+	clusterIDs := make([]string, 0)
+	for _, item := range items {
+		if !meta.Contain(clusterIDs, item.ClusterID) {
+			clusterIDs = append(clusterIDs, item.ClusterID)
+		}
+	}
+	sort.Strings(clusterIDs)
+
+	level := locationLevel(&req.Location)
+	failed := make([]topologyPlacement, 0)
+	for _, clusterID := range clusterIDs {
+		clusterMeta, metaErr := meta.Get(ctx, clusterID)
+		if metaErr != nil {
+			framework.LogWithContext(ctx).Warnf("load cluster %s meta from db error: %s", clusterID, metaErr.Error())
+			continue
+		}
+		placements, placementErr := getClusterPlacements(ctx, clusterMeta, hosts)
+		if placementErr != nil {
+			err = placementErr
+			return
+		}
+		lost := make([]topologyPlacement, 0)
+		for _, placement := range placements {
+			if subtreeHosts[placement.hostID] {
+				lost = append(lost, placement)
+			}
+		}
+		if len(lost) == 0 {
+			continue
+		}
+		failed = append(failed, lost...)
+		resp.Impacts = append(resp.Impacts, evaluateFailureImpact(clusterMeta, placements, lost, level))
+	}
+	resp.Regions = buildTopologyGraph(failed)
+	return
+}
+
+// locationLevel returns the level of the subtree specified by location
+func locationLevel(location *structs.Location) constants.HierarchyTreeNodeLevel {
+	switch {
+	case len(location.HostIp) > 0:
+		return constants.HOST
+	case len(location.Rack) > 0:
+		return constants.RACK
+	case len(location.Zone) > 0:
+		return constants.ZONE
+	case len(location.Region) > 0:
+		return constants.REGION
+	default:
+		return constants.ROOT
+	}
+}
+
+// queryHostsInLocation
+// @Description: query all hosts of a subtree page by page
+func queryHostsInLocation(ctx context.Context, location *structs.Location, filter *structs.HostFilter) ([]structs.HostInfo, error) {
+	result := make([]structs.HostInfo, 0)
+	for page := 1; ; page++ {
+		hosts, total, err := resourcepool.GetResourcePool().GetHostProvider().QueryHosts(ctx, location, filter, &structs.PageRequest{
+			Page:     page,
+			PageSize: hostQueryPageSize,
+		})
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("query hosts in location %v error: %s", *location, err.Error())
+			return nil, err
+		}
+		result = append(result, hosts...)
+		if len(hosts) < hostQueryPageSize || int64(len(result)) >= total {
+			return result, nil
+		}
+	}
+}
+
+// getClusterPlacements
+// @Description: join instances of the cluster with their hosts, hosts already queried are cached in hosts
+// @Parameter ctx
+// @Parameter clusterMeta
+// @Parameter hosts
+// @return []topologyPlacement
+// @return error
+func getClusterPlacements(ctx context.Context, clusterMeta *meta.ClusterMeta, hosts map[string]*structs.HostInfo) ([]topologyPlacement, error) {
+	placements := make([]topologyPlacement, 0)
+	for _, componentType := range sortedComponentTypes(clusterComponentTypes(clusterMeta)) {
+		for _, instance := range clusterMeta.Instances[componentType] {
+			// hibernated or initializing instances are not placed on any host
+			if len(instance.HostID) == 0 || instance.Status == string(constants.ClusterInstanceHibernated) {
+				continue
+			}
+			if _, ok := hosts[instance.HostID]; !ok {
+				list, _, err := resourcepool.GetResourcePool().GetHostProvider().QueryHosts(ctx, &structs.Location{}, &structs.HostFilter{
+					HostID: instance.HostID,
+				}, &structs.PageRequest{Page: 1, PageSize: 1})
+				if err != nil {
+					framework.LogWithContext(ctx).Errorf("query host %s error: %s", instance.HostID, err.Error())
+					return nil, err
+				}
+				if len(list) > 0 {
+					hosts[instance.HostID] = &list[0]
+				} else {
+					framework.LogWithContext(ctx).Warnf("host %s of instance %s is not found", instance.HostID, instance.ID)
+					hosts[instance.HostID] = nil
+				}
+			}
+			placement := topologyPlacement{
+				instance: instance,
+				host:     hosts[instance.HostID],
+				region:   clusterMeta.Cluster.Region,
+				zone:     instance.Zone,
+				rack:     instance.Rack,
+				hostID:   instance.HostID,
+			}
+			if placement.host != nil {
+				placement.region = placement.host.Region
+				placement.zone = placement.host.AZ
+				placement.rack = placement.host.Rack
+			}
+			placements = append(placements, placement)
+		}
+	}
+	return placements, nil
+}
+
+func clusterComponentTypes(clusterMeta *meta.ClusterMeta) []string {
+	types := make([]string, 0)
+	for componentType := range clusterMeta.Instances {
+		types = append(types, componentType)
+	}
+	return types
+}
+
+// buildTopologyGraph
+// @Description: build region-zone-rack-host tree of placements, nodes are sorted by name
+func buildTopologyGraph(placements []topologyPlacement) []cluster.TopologyGraphRegion {
+	sorted := make([]topologyPlacement, len(placements))
+	copy(sorted, placements)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].domainKey(constants.RACK)+"/"+sorted[i].hostID < sorted[j].domainKey(constants.RACK)+"/"+sorted[j].hostID
+	})
+
+	regions := make([]cluster.TopologyGraphRegion, 0)
+	for _, placement := range sorted {
+		if len(regions) == 0 || regions[len(regions)-1].Name != placement.region {
+			regions = append(regions, cluster.TopologyGraphRegion{Name: placement.region, Zones: make([]cluster.TopologyGraphZone, 0)})
+		}
+		region := &regions[len(regions)-1]
+		if len(region.Zones) == 0 || region.Zones[len(region.Zones)-1].Name != placement.zone {
+			region.Zones = append(region.Zones, cluster.TopologyGraphZone{Name: placement.zone, Racks: make([]cluster.TopologyGraphRack, 0)})
+		}
+		zone := &region.Zones[len(region.Zones)-1]
+		if len(zone.Racks) == 0 || zone.Racks[len(zone.Racks)-1].Name != placement.rack {
+			zone.Racks = append(zone.Racks, cluster.TopologyGraphRack{Name: placement.rack, Hosts: make([]cluster.TopologyGraphHost, 0)})
+		}
+		rack := &zone.Racks[len(zone.Racks)-1]
+		if len(rack.Hosts) == 0 || rack.Hosts[len(rack.Hosts)-1].HostID != placement.hostID {
+			host := cluster.TopologyGraphHost{
+				HostID:    placement.hostID,
+				Disks:     make([]structs.DiskInfo, 0),
+				Instances: make([]cluster.TopologyGraphInstance, 0),
+			}
+			if placement.host != nil {
+				host.HostName = placement.host.HostName
+				host.IP = placement.host.IP
+				host.Status = placement.host.Status
+			} else if len(placement.instance.HostIP) > 0 {
+				host.IP = placement.instance.HostIP[0]
+			}
+			rack.Hosts = append(rack.Hosts, host)
+		}
+		host := &rack.Hosts[len(rack.Hosts)-1]
+
+		instance := placement.instance
+		host.Instances = append(host.Instances, cluster.TopologyGraphInstance{
+			InstanceID:   instance.ID,
+			ClusterID:    instance.ClusterID,
+			Type:         instance.Type,
+			Role:         instance.Role,
+			Status:       instance.Status,
+			Ports:        instance.Ports,
+			DiskID:       instance.DiskID,
+			DiskPath:     instance.DiskPath,
+			DiskType:     instance.DiskType,
+			DiskCapacity: instance.DiskCapacity,
+		})
+		if placement.host != nil && len(instance.DiskID) > 0 {
+			for _, disk := range placement.host.Disks {
+				if disk.ID == instance.DiskID && !containsDisk(host.Disks, disk.ID) {
+					host.Disks = append(host.Disks, disk)
+				}
+			}
+		}
+	}
+	return regions
+}
+
+func containsDisk(disks []structs.DiskInfo, diskID string) bool {
+	for _, disk := range disks {
+		if disk.ID == diskID {
+			return true
+		}
+	}
+	return false
+}
+
+// groupPlacements groups placements by their failure domain at the level, keys are sorted
+func groupPlacements(placements []topologyPlacement, level constants.HierarchyTreeNodeLevel) (map[string][]topologyPlacement, []string) {
+	groups := make(map[string][]topologyPlacement)
+	for _, placement := range placements {
+		key := placement.domainKey(level)
+		groups[key] = append(groups[key], placement)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return groups, keys
+}
+
+func placementsOfComponent(placements []topologyPlacement, componentType constants.EMProductComponentIDType) []topologyPlacement {
+	result := make([]topologyPlacement, 0)
+	for _, placement := range placements {
+		if placement.instance.Type == string(componentType) {
+			result = append(result, placement)
+		}
+	}
+	return result
+}
+
+func placementInstanceIDs(placements []topologyPlacement) []string {
+	ids := make([]string, 0)
+	for _, placement := range placements {
+		ids = append(ids, placement.instance.ID)
+	}
+	return ids
+}
+
+// evaluatePlacementRisks
+// @Description: find risky placements of cluster instances, eg. two TiKV replicas on one rack, or all PDs in one zone
+// @Parameter clusterMeta
+// @Parameter placements
+// @return []cluster.TopologyPlacementRisk
+func evaluatePlacementRisks(clusterMeta *meta.ClusterMeta, placements []topologyPlacement) []cluster.TopologyPlacementRisk {
+	risks := make([]cluster.TopologyPlacementRisk, 0)
+	newRisk := func(level constants.TopologyRiskLevel, riskType constants.TopologyRiskType, componentType string,
+		location string, instances []topologyPlacement, message string) {
+		risks = append(risks, cluster.TopologyPlacementRisk{
+			ClusterID:     clusterMeta.Cluster.ID,
+			Level:         string(level),
+			Type:          string(riskType),
+			ComponentType: componentType,
+			Location:      location,
+			Message:       message,
+			InstanceIDs:   placementInstanceIDs(instances),
+		})
+	}
+
+	// replicas of a region are isolated by location labels region, zone, rack and host,
+	// two replicas share a host or a rack if there are not enough of them
+	copies := clusterMeta.Cluster.Copies
+	tikv := placementsOfComponent(placements, constants.ComponentIDTiKV)
+	if copies > 1 && len(tikv) > 0 {
+		_, hostKeys := groupPlacements(tikv, constants.HOST)
+		rackGroups, rackKeys := groupPlacements(tikv, constants.RACK)
+		if len(hostKeys) < copies {
+			newRisk(constants.TopologyRiskCritical, constants.TopologyRiskTiKVReplicasOnSameHost, string(constants.ComponentIDTiKV), "", tikv,
+				fmt.Sprintf("TiKV stores are on %d hosts, at least two of the %d replicas of a region are on one host", len(hostKeys), copies))
+		} else if len(rackKeys) < copies {
+			for _, key := range rackKeys {
+				if len(rackGroups[key]) > 1 {
+					newRisk(constants.TopologyRiskWarning, constants.TopologyRiskTiKVReplicasOnSameRack, string(constants.ComponentIDTiKV), key, rackGroups[key],
+						fmt.Sprintf("TiKV stores are on %d racks, at least two of the %d replicas of a region may be on rack %s", len(rackKeys), copies, key))
+				}
+			}
+		}
+	}
+
+	pd := placementsOfComponent(placements, constants.ComponentIDPD)
+	if len(pd) > 1 {
+		quorum := len(pd)/2 + 1
+		rackGroups, rackKeys := groupPlacements(pd, constants.RACK)
+		for _, key := range rackKeys {
+			if len(rackGroups[key]) >= quorum {
+				newRisk(constants.TopologyRiskCritical, constants.TopologyRiskPDQuorumOnOneRack, string(constants.ComponentIDPD), key, rackGroups[key],
+					fmt.Sprintf("%d of %d PD servers are on rack %s, PD loses its quorum if the rack fails", len(rackGroups[key]), len(pd), key))
+			}
+		}
+		zoneGroups, zoneKeys := groupPlacements(pd, constants.ZONE)
+		if len(zoneKeys) == 1 {
+			newRisk(constants.TopologyRiskWarning, constants.TopologyRiskPDsInOneZone, string(constants.ComponentIDPD), zoneKeys[0], zoneGroups[zoneKeys[0]],
+				fmt.Sprintf("all %d PD servers are in zone %s", len(pd), zoneKeys[0]))
+		}
+	}
+
+	tidb := placementsOfComponent(placements, constants.ComponentIDTiDB)
+	if len(tidb) > 1 {
+		hostGroups, hostKeys := groupPlacements(tidb, constants.HOST)
+		if len(hostKeys) == 1 {
+			newRisk(constants.TopologyRiskWarning, constants.TopologyRiskTiDBsOnOneHost, string(constants.ComponentIDTiDB), hostKeys[0], hostGroups[hostKeys[0]],
+				fmt.Sprintf("all %d TiDB servers are on host %s", len(tidb), hostKeys[0]))
+		}
+	}
+
+	hostGroups, hostKeys := groupPlacements(placements, constants.HOST)
+	for _, key := range hostKeys {
+		host := hostGroups[key][0].host
+		if host != nil && host.Status != string(constants.HostOnline) {
+			newRisk(constants.TopologyRiskWarning, constants.TopologyRiskHostNotOnline, "", key, hostGroups[key],
+				fmt.Sprintf("host %s is %s", host.IP, host.Status))
+		}
+	}
+	return risks
+}
+
+// evaluateFailureImpact
+// @Description: evaluate the impact on a cluster if instances in lost fail
+// @Parameter clusterMeta
+// @Parameter placements all placements of the cluster
+// @Parameter lost placements in the failed subtree
+// @Parameter level level of the failed subtree
+// @return cluster.TopologyFailureImpact
+func evaluateFailureImpact(clusterMeta *meta.ClusterMeta, placements []topologyPlacement, lost []topologyPlacement,
+	level constants.HierarchyTreeNodeLevel) cluster.TopologyFailureImpact {
+	impact := cluster.TopologyFailureImpact{
+		ClusterID:   clusterMeta.Cluster.ID,
+		ClusterName: clusterMeta.Cluster.Name,
+		Losses:      make([]cluster.TopologyComponentLoss, 0),
+		Reasons:     make([]string, 0),
+	}
+
+	lostCount := make(map[string]int)
+	totalCount := make(map[string]int)
+	for _, placement := range lost {
+		lostCount[placement.instance.Type]++
+	}
+	for _, placement := range placements {
+		totalCount[placement.instance.Type]++
+	}
+	lostTypes := make([]string, 0)
+	for componentType := range lostCount {
+		lostTypes = append(lostTypes, componentType)
+	}
+	for _, componentType := range sortedComponentTypes(lostTypes) {
+		impact.Losses = append(impact.Losses, cluster.TopologyComponentLoss{
+			ComponentType: componentType,
+			Lost:          lostCount[componentType],
+			Total:         totalCount[componentType],
+		})
+	}
+
+	tidbType, pdType, tikvType := string(constants.ComponentIDTiDB), string(constants.ComponentIDPD), string(constants.ComponentIDTiKV)
+	if lostCount[tidbType] > 0 && lostCount[tidbType] == totalCount[tidbType] {
+		impact.Reasons = append(impact.Reasons, fmt.Sprintf("all %d TiDB servers are lost", totalCount[tidbType]))
+	}
+	if lostCount[pdType] > 0 && lostCount[pdType] >= totalCount[pdType]/2+1 {
+		impact.Reasons = append(impact.Reasons,
+			fmt.Sprintf("%d of %d PD servers are lost, PD loses its quorum", lostCount[pdType], totalCount[pdType]))
+	}
+	copies := clusterMeta.Cluster.Copies
+	if lostCount[tikvType] > 0 && copies > 0 {
+		// replicas of a region are placed in different failure domains as far as possible
+		_, domains := groupPlacements(placementsOfComponent(placements, constants.ComponentIDTiKV), level)
+		maxLostReplicas := 1
+		if len(domains) < copies {
+			maxLostReplicas = copies - len(domains) + 1
+		}
+		if maxLostReplicas > lostCount[tikvType] {
+			maxLostReplicas = lostCount[tikvType]
+		}
+		if maxLostReplicas >= copies/2+1 {
+			impact.Reasons = append(impact.Reasons,
+				fmt.Sprintf("up to %d of %d replicas of a region are lost, some regions become unavailable", maxLostReplicas, copies))
+		}
+	}
+	impact.Unavailable = len(impact.Reasons) > 0
+	return impact
+}
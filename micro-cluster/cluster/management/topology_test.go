@@ -0,0 +1,351 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package management
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool"
+	"github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/resourcepool/hostprovider"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	rp "github.com/pingcap/tiunimanager/models/resource/resourcepool"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockresource"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockPlacement(id string, componentType constants.EMProductComponentIDType, zone, rack, hostID string) topologyPlacement {
+	return topologyPlacement{
+		instance: &management.ClusterInstance{
+			Entity:    common.Entity{ID: id, Status: string(constants.ClusterInstanceRunning)},
+			Type:      string(componentType),
+			ClusterID: "cluster01",
+			HostID:    hostID,
+		},
+		host:   &structs.HostInfo{ID: hostID, IP: hostID, Status: string(constants.HostOnline)},
+		region: "Region1",
+		zone:   zone,
+		rack:   rack,
+		hostID: hostID,
+	}
+}
+
+func mockTopologyMeta(copies int) *meta.ClusterMeta {
+	return &meta.ClusterMeta{
+		Cluster: &management.Cluster{
+			Entity: common.Entity{ID: "cluster01"},
+			Name:   "cluster01",
+			Region: "Region1",
+			Copies: copies,
+		},
+	}
+}
+
+func findRisk(risks []cluster.TopologyPlacementRisk, riskType constants.TopologyRiskType) *cluster.TopologyPlacementRisk {
+	for i := range risks {
+		if risks[i].Type == string(riskType) {
+			return &risks[i]
+		}
+	}
+	return nil
+}
+
+func Test_evaluatePlacementRisks(t *testing.T) {
+	t.Run("well placed", func(t *testing.T) {
+		risks := evaluatePlacementRisks(mockTopologyMeta(3), []topologyPlacement{
+			mockPlacement("pd1", constants.ComponentIDPD, "Zone1", "Rack1", "host1"),
+			mockPlacement("pd2", constants.ComponentIDPD, "Zone2", "Rack1", "host2"),
+			mockPlacement("pd3", constants.ComponentIDPD, "Zone3", "Rack1", "host3"),
+			mockPlacement("tikv1", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1"),
+			mockPlacement("tikv2", constants.ComponentIDTiKV, "Zone2", "Rack1", "host2"),
+			mockPlacement("tikv3", constants.ComponentIDTiKV, "Zone3", "Rack1", "host3"),
+			mockPlacement("tidb1", constants.ComponentIDTiDB, "Zone1", "Rack1", "host1"),
+			mockPlacement("tidb2", constants.ComponentIDTiDB, "Zone2", "Rack1", "host2"),
+		})
+		assert.Empty(t, risks)
+	})
+	t.Run("tikv on same rack", func(t *testing.T) {
+		risks := evaluatePlacementRisks(mockTopologyMeta(3), []topologyPlacement{
+			mockPlacement("tikv1", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1"),
+			mockPlacement("tikv2", constants.ComponentIDTiKV, "Zone1", "Rack1", "host2"),
+			mockPlacement("tikv3", constants.ComponentIDTiKV, "Zone1", "Rack2", "host3"),
+		})
+		assert.Len(t, risks, 1)
+		assert.Equal(t, string(constants.TopologyRiskTiKVReplicasOnSameRack), risks[0].Type)
+		assert.Equal(t, string(constants.TopologyRiskWarning), risks[0].Level)
+		assert.Equal(t, "Region1/Zone1/Rack1", risks[0].Location)
+		assert.ElementsMatch(t, []string{"tikv1", "tikv2"}, risks[0].InstanceIDs)
+	})
+	t.Run("tikv on same host", func(t *testing.T) {
+		risks := evaluatePlacementRisks(mockTopologyMeta(3), []topologyPlacement{
+			mockPlacement("tikv1", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1"),
+			mockPlacement("tikv2", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1"),
+			mockPlacement("tikv3", constants.ComponentIDTiKV, "Zone1", "Rack2", "host3"),
+		})
+		assert.Len(t, risks, 1)
+		assert.Equal(t, string(constants.TopologyRiskTiKVReplicasOnSameHost), risks[0].Type)
+		assert.Equal(t, string(constants.TopologyRiskCritical), risks[0].Level)
+	})
+	t.Run("pd in one zone", func(t *testing.T) {
+		risks := evaluatePlacementRisks(mockTopologyMeta(3), []topologyPlacement{
+			mockPlacement("pd1", constants.ComponentIDPD, "Zone1", "Rack1", "host1"),
+			mockPlacement("pd2", constants.ComponentIDPD, "Zone1", "Rack1", "host2"),
+			mockPlacement("pd3", constants.ComponentIDPD, "Zone1", "Rack2", "host3"),
+		})
+		assert.Len(t, risks, 2)
+		quorum := findRisk(risks, constants.TopologyRiskPDQuorumOnOneRack)
+		assert.NotNil(t, quorum)
+		assert.Equal(t, "Region1/Zone1/Rack1", quorum.Location)
+		zone := findRisk(risks, constants.TopologyRiskPDsInOneZone)
+		assert.NotNil(t, zone)
+		assert.Equal(t, "Region1/Zone1", zone.Location)
+	})
+	t.Run("tidb on one host and host offline", func(t *testing.T) {
+		tidb2 := mockPlacement("tidb2", constants.ComponentIDTiDB, "Zone1", "Rack1", "host1")
+		tidb2.host.Status = string(constants.HostOffline)
+		risks := evaluatePlacementRisks(mockTopologyMeta(1), []topologyPlacement{
+			mockPlacement("tidb1", constants.ComponentIDTiDB, "Zone1", "Rack1", "host1"),
+			tidb2,
+		})
+		assert.NotNil(t, findRisk(risks, constants.TopologyRiskTiDBsOnOneHost))
+	})
+	t.Run("host not online", func(t *testing.T) {
+		tikv := mockPlacement("tikv1", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1")
+		tikv.host.Status = string(constants.HostFailed)
+		risks := evaluatePlacementRisks(mockTopologyMeta(1), []topologyPlacement{tikv})
+		assert.Len(t, risks, 1)
+		assert.Equal(t, string(constants.TopologyRiskHostNotOnline), risks[0].Type)
+		assert.Equal(t, "host1", risks[0].Location)
+	})
+}
+
+func Test_evaluateFailureImpact(t *testing.T) {
+	placements := []topologyPlacement{
+		mockPlacement("pd1", constants.ComponentIDPD, "Zone1", "Rack1", "host1"),
+		mockPlacement("pd2", constants.ComponentIDPD, "Zone1", "Rack1", "host2"),
+		mockPlacement("pd3", constants.ComponentIDPD, "Zone2", "Rack1", "host3"),
+		mockPlacement("tikv1", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1"),
+		mockPlacement("tikv2", constants.ComponentIDTiKV, "Zone1", "Rack2", "host4"),
+		mockPlacement("tikv3", constants.ComponentIDTiKV, "Zone2", "Rack1", "host3"),
+		mockPlacement("tidb1", constants.ComponentIDTiDB, "Zone2", "Rack1", "host3"),
+	}
+	lostIn := func(level constants.HierarchyTreeNodeLevel, key string) []topologyPlacement {
+		lost := make([]topologyPlacement, 0)
+		for _, placement := range placements {
+			if placement.domainKey(level) == key {
+				lost = append(lost, placement)
+			}
+		}
+		return lost
+	}
+
+	t.Run("rack", func(t *testing.T) {
+		impact := evaluateFailureImpact(mockTopologyMeta(3), placements, lostIn(constants.RACK, "Region1/Zone1/Rack1"), constants.RACK)
+		assert.Equal(t, "cluster01", impact.ClusterID)
+		assert.Equal(t, []cluster.TopologyComponentLoss{
+			{ComponentType: "PD", Lost: 2, Total: 3},
+			{ComponentType: "TiKV", Lost: 1, Total: 3},
+		}, impact.Losses)
+		// TiKV stores are on 3 racks, a rack failure loses at most one replica of a region
+		assert.True(t, impact.Unavailable)
+		assert.Len(t, impact.Reasons, 1)
+	})
+	t.Run("zone", func(t *testing.T) {
+		impact := evaluateFailureImpact(mockTopologyMeta(3), placements, lostIn(constants.ZONE, "Region1/Zone1"), constants.ZONE)
+		// TiKV stores are in 2 zones, a zone may hold 2 of 3 replicas
+		assert.True(t, impact.Unavailable)
+		assert.Len(t, impact.Reasons, 2)
+	})
+	t.Run("host", func(t *testing.T) {
+		impact := evaluateFailureImpact(mockTopologyMeta(3), placements, lostIn(constants.HOST, "host3"), constants.HOST)
+		assert.True(t, impact.Unavailable)
+		assert.Equal(t, []string{"all 1 TiDB servers are lost"}, impact.Reasons)
+	})
+	t.Run("available", func(t *testing.T) {
+		impact := evaluateFailureImpact(mockTopologyMeta(3), placements, lostIn(constants.HOST, "host4"), constants.HOST)
+		assert.False(t, impact.Unavailable)
+		assert.Empty(t, impact.Reasons)
+	})
+}
+
+func Test_buildTopologyGraph(t *testing.T) {
+	placements := []topologyPlacement{
+		mockPlacement("tikv2", constants.ComponentIDTiKV, "Zone2", "Rack1", "host2"),
+		mockPlacement("pd1", constants.ComponentIDPD, "Zone1", "Rack1", "host1"),
+		mockPlacement("tikv1", constants.ComponentIDTiKV, "Zone1", "Rack1", "host1"),
+	}
+	placements[2].instance.DiskID = "disk01"
+	placements[2].host.Disks = []structs.DiskInfo{{ID: "disk01", Path: "/data1"}, {ID: "disk02", Path: "/data2"}}
+
+	regions := buildTopologyGraph(placements)
+	assert.Len(t, regions, 1)
+	assert.Equal(t, "Region1", regions[0].Name)
+	assert.Len(t, regions[0].Zones, 2)
+	assert.Equal(t, "Zone1", regions[0].Zones[0].Name)
+	host := regions[0].Zones[0].Racks[0].Hosts[0]
+	assert.Equal(t, "host1", host.HostID)
+	assert.Len(t, host.Instances, 2)
+	assert.Equal(t, "pd1", host.Instances[0].InstanceID)
+	assert.Equal(t, []structs.DiskInfo{{ID: "disk01", Path: "/data1"}}, host.Disks)
+	assert.Equal(t, "tikv2", regions[0].Zones[1].Racks[0].Hosts[0].Instances[0].InstanceID)
+}
+
+func mockTopologyHosts() []rp.Host {
+	return []rp.Host{
+		{ID: "host1", IP: "127.0.0.1", Status: string(constants.HostOnline), Region: "Region1",
+			AZ: structs.GenDomainCodeByName("Region1", "Zone1"), Rack: structs.GenDomainCodeByName(structs.GenDomainCodeByName("Region1", "Zone1"), "Rack1")},
+		{ID: "host2", IP: "127.0.0.2", Status: string(constants.HostOnline), Region: "Region1",
+			AZ: structs.GenDomainCodeByName("Region1", "Zone1"), Rack: structs.GenDomainCodeByName(structs.GenDomainCodeByName("Region1", "Zone1"), "Rack2")},
+	}
+}
+
+func mockTopologyInstances() []*management.ClusterInstance {
+	return []*management.ClusterInstance{
+		{Entity: common.Entity{ID: "pd1", Status: string(constants.ClusterInstanceRunning)}, Type: "PD", ClusterID: "cluster01", HostID: "host1"},
+		{Entity: common.Entity{ID: "tikv1", Status: string(constants.ClusterInstanceRunning)}, Type: "TiKV", ClusterID: "cluster01", HostID: "host1"},
+		{Entity: common.Entity{ID: "tikv2", Status: string(constants.ClusterInstanceRunning)}, Type: "TiKV", ClusterID: "cluster01", HostID: "host2"},
+		{Entity: common.Entity{ID: "tidb1", Status: string(constants.ClusterInstanceRunning)}, Type: "TiDB", ClusterID: "cluster01", HostID: "host2"},
+		{Entity: common.Entity{ID: "tidb2", Status: string(constants.ClusterInstanceHibernated)}, Type: "TiDB", ClusterID: "cluster01", HostID: "host1"},
+	}
+}
+
+func TestManager_GetClusterTopologyGraph(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resourceRW := mockresource.NewMockReaderWriter(ctrl)
+	models.SetResourceReaderWriter(resourceRW)
+	provider := resourcepool.GetResourcePool().GetHostProvider().(*hostprovider.FileHostProvider)
+	provider.SetResourceReaderWriter(resourceRW)
+
+	manager := &Manager{}
+	t.Run("normal", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(&management.Cluster{
+			Entity: common.Entity{ID: "cluster01"},
+			Region: "Region1",
+			Copies: 3,
+		}, mockTopologyInstances(), make([]*management.DBUser, 0), nil)
+		clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, location *structs.Location, filter *structs.HostFilter, offset int, limit int) ([]rp.Host, int64, error) {
+				for _, host := range mockTopologyHosts() {
+					if host.ID == filter.HostID {
+						return []rp.Host{host}, 1, nil
+					}
+				}
+				return []rp.Host{}, 0, nil
+			}).Times(2)
+
+		resp, err := manager.GetClusterTopologyGraph(context.TODO(), cluster.GetClusterTopologyGraphReq{ClusterID: "cluster01"})
+		assert.NoError(t, err)
+		assert.Equal(t, "cluster01", resp.ClusterID)
+		assert.Len(t, resp.Regions, 1)
+		assert.Equal(t, "Zone1", resp.Regions[0].Zones[0].Name)
+		racks := resp.Regions[0].Zones[0].Racks
+		assert.Len(t, racks, 2)
+		assert.Equal(t, "127.0.0.1", racks[0].Hosts[0].IP)
+		// the hibernated TiDB is not placed on any host
+		assert.Len(t, racks[0].Hosts[0].Instances, 2)
+		// 2 TiKV stores for 3 replicas
+		assert.NotNil(t, findRisk(resp.Risks, constants.TopologyRiskTiKVReplicasOnSameHost))
+	})
+	t.Run("query host failed", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(&management.Cluster{
+			Entity: common.Entity{ID: "cluster01"},
+		}, mockTopologyInstances(), make([]*management.DBUser, 0), nil)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), fmt.Errorf("query failed"))
+
+		_, err := manager.GetClusterTopologyGraph(context.TODO(), cluster.GetClusterTopologyGraphReq{ClusterID: "cluster01"})
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_GetHostTopologyGraph(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resourceRW := mockresource.NewMockReaderWriter(ctrl)
+	models.SetResourceReaderWriter(resourceRW)
+	provider := resourcepool.GetResourcePool().GetHostProvider().(*hostprovider.FileHostProvider)
+	provider.SetResourceReaderWriter(resourceRW)
+
+	manager := &Manager{}
+	t.Run("rack", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, location *structs.Location, filter *structs.HostFilter, offset int, limit int) ([]rp.Host, int64, error) {
+				hosts := mockTopologyHosts()
+				if filter.HostID == "host2" {
+					return hosts[1:], 1, nil
+				}
+				assert.Equal(t, "Rack1", location.Rack)
+				return hosts[:1], 1, nil
+			}).Times(2)
+		clusterRW.EXPECT().QueryHostInstances(gomock.Any(), gomock.Any()).Return([]management.HostInstanceItem{
+			{HostID: "host1", ClusterID: "cluster01", Component: "PD"},
+			{HostID: "host1", ClusterID: "cluster01", Component: "TiKV"},
+		}, nil).AnyTimes()
+		clusterRW.EXPECT().GetMeta(gomock.Any(), "cluster01").Return(&management.Cluster{
+			Entity: common.Entity{ID: "cluster01"},
+			Name:   "cluster01",
+			Region: "Region1",
+			Copies: 3,
+		}, mockTopologyInstances(), make([]*management.DBUser, 0), nil)
+
+		resp, err := manager.GetHostTopologyGraph(context.TODO(), cluster.GetHostTopologyGraphReq{
+			Location: structs.Location{Region: "Region1", Zone: "Zone1", Rack: "Rack1"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Regions, 1)
+		assert.Len(t, resp.Regions[0].Zones[0].Racks, 1)
+		assert.Len(t, resp.Regions[0].Zones[0].Racks[0].Hosts[0].Instances, 2)
+		assert.Len(t, resp.Impacts, 1)
+		assert.Equal(t, "cluster01", resp.Impacts[0].ClusterID)
+		// the only PD is lost, only one TiKV store is lost
+		assert.True(t, resp.Impacts[0].Unavailable)
+		assert.Len(t, resp.Impacts[0].Reasons, 1)
+	})
+	t.Run("empty", func(t *testing.T) {
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]rp.Host{}, int64(0), nil)
+
+		resp, err := manager.GetHostTopologyGraph(context.TODO(), cluster.GetHostTopologyGraphReq{
+			Location: structs.Location{Region: "Region2"},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, resp.Regions)
+		assert.Empty(t, resp.Impacts)
+	})
+	t.Run("query failed", func(t *testing.T) {
+		resourceRW.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), fmt.Errorf("query failed"))
+
+		_, err := manager.GetHostTopologyGraph(context.TODO(), cluster.GetHostTopologyGraphReq{})
+		assert.Error(t, err)
+	})
+}
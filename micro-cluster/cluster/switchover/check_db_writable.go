@@ -153,3 +153,22 @@ func (p *Manager) checkClusterWritable(ctx context.Context, clusterID, userName,
 	}
 	return nil
 }
+
+func (p *Manager) checkClusterReadable(ctx context.Context, clusterID, userName, pwd, addr string) error {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/?charset=utf8mb4&parseTime=True&loc=Local", userName, pwd, addr)
+	safeDSN := fmt.Sprintf("%s:%s@tcp(%s)/?charset=utf8mb4&parseTime=True&loc=Local", userName, "?", addr)
+	mylog := framework.LogWithContext(ctx).WithField("clusterID", clusterID)
+	mylog.Info("checkClusterReadable gorm.Open with dsn:", safeDSN)
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		mylog.Warn("checkClusterReadable gorm.Open err:", err)
+		return err
+	}
+	var retValue int
+	if err = db.Raw("SELECT 1").Scan(&retValue).Error; err != nil {
+		mylog.Warn("checkClusterReadable query err:", err)
+		return err
+	}
+	mylog.Info("checkClusterReadable query success")
+	return nil
+}
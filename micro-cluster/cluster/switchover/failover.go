@@ -0,0 +1,538 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/metrics"
+	"github.com/pingcap/tiunimanager/micro-cluster/cluster/management/meta"
+	platformConfig "github.com/pingcap/tiunimanager/micro-cluster/platform/config"
+	"github.com/pingcap/tiunimanager/models"
+	clusterMgr "github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/robfig/cron"
+)
+
+// number of latest failover events returned with failover policy
+const failoverEventsLength = 20
+
+type failoverWatcher struct {
+	JobCron *cron.Cron
+	JobSpec string
+}
+
+// failoverProbe probe source of master health, returns error if master is regarded as unavailable
+type failoverProbe struct {
+	name string
+	// master is observed by another party instead of connecting to it from this host,
+	// so that its failure is not caused by network between TiUniManager and master
+	independent bool
+	probe       func(ctx context.Context, masterID string, standbys []*clusterMgr.ClusterRelation, now time.Time) error
+}
+
+type failoverState struct {
+	// consecutive rounds in which master is confirmed unavailable
+	failures int
+	// last round in which master is not confirmed unavailable, lag of standbys is measured against it
+	lastHealthy time.Time
+}
+
+type failoverWatchHandler struct {
+	running int32
+
+	lock   sync.Mutex
+	states map[string]*failoverState
+
+	probes       []failoverProbe
+	checkStandby func(ctx context.Context, clusterID string) error
+	switchover   func(ctx context.Context, req *cluster.MasterSlaveClusterSwitchoverReq) (*cluster.MasterSlaveClusterSwitchoverResp, error)
+}
+
+type failoverCandidate struct {
+	clusterID string
+	lag       time.Duration
+}
+
+var failoverWatcherOnce sync.Once
+var failoverHandler = newFailoverWatchHandler()
+
+func newFailoverWatchHandler() *failoverWatchHandler {
+	return &failoverWatchHandler{
+		states: make(map[string]*failoverState),
+		probes: []failoverProbe{
+			{name: constants.FailoverProbeReadWrite, probe: probeReadWrite},
+			{name: constants.FailoverProbePD, probe: probePD},
+			{name: constants.FailoverProbeChangeFeed, independent: true, probe: probeSyncChangeFeeds},
+		},
+		checkStandby: mgr.checkClusterReadWriteHealth,
+		switchover:   mgr.Switchover,
+	}
+}
+
+// StartFailoverWatcher
+// @Description: probe masters of standby relations with automatic failover enabled in background
+func StartFailoverWatcher() {
+	failoverWatcherOnce.Do(func() {
+		watcher := &failoverWatcher{
+			JobCron: cron.New(),
+			JobSpec: "*/30 * * * * *", // every 30 seconds
+		}
+		err := watcher.JobCron.AddJob(watcher.JobSpec, failoverHandler)
+		if err != nil {
+			framework.Log().Fatalf("add failover watcher cron job failed, %s", err.Error())
+			return
+		}
+		go watcher.start()
+	})
+}
+
+func (w *failoverWatcher) start() {
+	time.Sleep(5 * time.Second) //wait db client ready
+	w.JobCron.Start()
+	defer w.JobCron.Stop()
+
+	select {}
+}
+
+func (handler *failoverWatchHandler) Run() {
+	if !atomic.CompareAndSwapInt32(&handler.running, 0, 1) {
+		framework.Log().Warnf("last round of failover watcher is still running, skip this round")
+		return
+	}
+	defer atomic.StoreInt32(&handler.running, 0)
+
+	relations, err := models.GetClusterReaderWriter().QueryFailoverRelations(context.TODO())
+	if err != nil {
+		framework.Log().Errorf("query relations with automatic failover failed, %s", err.Error())
+		return
+	}
+
+	standbysByMaster := make(map[string][]*clusterMgr.ClusterRelation)
+	for _, relation := range relations {
+		standbysByMaster[relation.SubjectClusterID] = append(standbysByMaster[relation.SubjectClusterID], relation)
+	}
+	handler.forget(standbysByMaster)
+
+	for masterID, standbys := range standbysByMaster {
+		handler.watchMaster(context.Background(), masterID, standbys, time.Now())
+	}
+}
+
+// watchMaster
+// @Description: probe master, and fail over to the best standby if master is confirmed unavailable for enough rounds.
+// Master is confirmed unavailable only if at least one failed probe is independent of this host
+// @Parameter ctx
+// @Parameter masterID
+// @Parameter standbys
+// @Parameter now
+func (handler *failoverWatchHandler) watchMaster(ctx context.Context, masterID string, standbys []*clusterMgr.ClusterRelation, now time.Time) {
+	master, err := models.GetClusterReaderWriter().Get(ctx, masterID)
+	if err != nil {
+		framework.Log().Errorf("watch master %s failed, %s", masterID, err.Error())
+		return
+	}
+	ctx = framework.NewMicroContextWithKeyValuePairs(ctx, map[string]string{framework.TiUniManager_X_TENANT_ID_KEY: master.TenantId})
+	// stopped, hibernated or under maintenance, such as switching over
+	if master.Status != string(constants.ClusterRunning) || master.MaintenanceStatus != constants.ClusterMaintenanceNone {
+		handler.reset(masterID, time.Time{})
+		return
+	}
+
+	failed := make([]string, 0)
+	confirmed := false
+	for _, p := range handler.probes {
+		if err := p.probe(ctx, masterID, standbys, now); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", p.name, err.Error()))
+			confirmed = confirmed || p.independent
+		}
+	}
+	if len(failed) < constants.FailoverMinFailedProbes {
+		if handler.getState(masterID).failures > 0 {
+			raiseFailoverEvent(ctx, masterID, "", constants.FailoverEventRecovered,
+				fmt.Sprintf("master is available again, failed probes: [%s]", strings.Join(failed, "; ")), "")
+		}
+		handler.reset(masterID, now)
+		return
+	}
+	if !confirmed {
+		// master may be healthy while network between TiUniManager and master is broken, never fail over to avoid split brain.
+		// Master is not regarded as healthy either, so lag of standbys is still measured against the last healthy round
+		framework.LogWithContext(ctx).Warnf("master %s is unavailable from this host but not confirmed by independent probes, failed probes: [%s]",
+			masterID, strings.Join(failed, "; "))
+		handler.reset(masterID, handler.getState(masterID).lastHealthy)
+		return
+	}
+
+	state := handler.fail(masterID)
+	message := fmt.Sprintf("master is unavailable for %d consecutive rounds, failed probes: [%s]", state.failures, strings.Join(failed, "; "))
+	if state.failures == 1 {
+		raiseFailoverEvent(ctx, masterID, "", constants.FailoverEventProbeFailed, message, "")
+	}
+	if state.failures < failoverThreshold(standbys) {
+		framework.LogWithContext(ctx).Warnf(message)
+		return
+	}
+
+	// count again whether failover is triggered or not
+	handler.reset(masterID, state.lastHealthy)
+	handler.failover(ctx, masterID, standbys, state.lastHealthy, now, message)
+}
+
+// failover
+// @Description: run force switchover to the standby with smallest lag, unless any involved cluster failed over in cooldown
+// @Parameter ctx
+// @Parameter masterID
+// @Parameter standbys
+// @Parameter lastHealthy
+// @Parameter now
+// @Parameter reason
+func (handler *failoverWatchHandler) failover(ctx context.Context, masterID string, standbys []*clusterMgr.ClusterRelation, lastHealthy time.Time, now time.Time, reason string) {
+	clusterIDs := []string{masterID}
+	for _, relation := range standbys {
+		clusterIDs = append(clusterIDs, relation.ObjectClusterID)
+	}
+	if cooldown := getFailoverCooldown(ctx); cooldown > 0 {
+		count, err := models.GetClusterReaderWriter().CountFailovers(ctx, clusterIDs, now.Add(-cooldown))
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("count failovers of clusters %v failed, %s", clusterIDs, err.Error())
+			return
+		}
+		if count > 0 {
+			raiseFailoverEvent(ctx, masterID, "", constants.FailoverEventSkipped,
+				fmt.Sprintf("%s, but clusters %v failed over in cooldown %s", reason, clusterIDs, cooldown), "")
+			return
+		}
+	}
+
+	if lastHealthy.IsZero() {
+		lastHealthy = now
+	}
+	standby, err := handler.chooseStandby(ctx, standbys, lastHealthy)
+	if err != nil {
+		raiseFailoverEvent(ctx, masterID, "", constants.FailoverEventSkipped, fmt.Sprintf("%s, but %s", reason, err.Error()), "")
+		return
+	}
+
+	resp, err := handler.switchover(ctx, &cluster.MasterSlaveClusterSwitchoverReq{
+		SourceClusterID: masterID,
+		TargetClusterID: standby.clusterID,
+		Force:           true,
+	})
+	if err != nil {
+		raiseFailoverEvent(ctx, masterID, standby.clusterID, constants.FailoverEventFailed,
+			fmt.Sprintf("%s, switchover to standby %s failed, %s", reason, standby.clusterID, err.Error()), "")
+		return
+	}
+	raiseFailoverEvent(ctx, masterID, standby.clusterID, constants.FailoverEventTriggered,
+		fmt.Sprintf("%s, switchover to standby %s with lag %s", reason, standby.clusterID, standby.lag), resp.WorkFlowID)
+}
+
+// chooseStandby
+// @Description: choose the available standby with smallest lag, which is measured against the last time master is healthy
+// @Parameter ctx
+// @Parameter standbys
+// @Parameter lastHealthy
+// @return failoverCandidate
+// @return error
+func (handler *failoverWatchHandler) chooseStandby(ctx context.Context, standbys []*clusterMgr.ClusterRelation, lastHealthy time.Time) (failoverCandidate, error) {
+	candidates := make([]failoverCandidate, 0)
+	reasons := make([]string, 0)
+	for _, relation := range standbys {
+		lag, err := standbyLag(ctx, relation, lastHealthy)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", relation.ObjectClusterID, err.Error()))
+			continue
+		}
+		if maxLag := time.Duration(relation.FailoverMaxLag) * time.Second; maxLag > 0 && lag > maxLag {
+			reasons = append(reasons, fmt.Sprintf("%s: lag %s exceeds %s", relation.ObjectClusterID, lag, maxLag))
+			continue
+		}
+		candidates = append(candidates, failoverCandidate{clusterID: relation.ObjectClusterID, lag: lag})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].lag < candidates[j].lag
+	})
+	for _, candidate := range candidates {
+		if err := handler.checkStandby(ctx, candidate.clusterID); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", candidate.clusterID, err.Error()))
+			continue
+		}
+		return candidate, nil
+	}
+	return failoverCandidate{}, fmt.Errorf("no standby is available, [%s]", strings.Join(reasons, "; "))
+}
+
+// standbyLag
+// @Description: lag of checkpoint of sync change feed behind the specified time, according to change feed watcher
+// @Parameter ctx
+// @Parameter relation
+// @Parameter since
+// @return time.Duration
+// @return error
+func standbyLag(ctx context.Context, relation *clusterMgr.ClusterRelation, since time.Time) (time.Duration, error) {
+	task, err := models.GetChangeFeedReaderWriter().Get(ctx, relation.SyncChangeFeedTaskID)
+	if err != nil {
+		return 0, fmt.Errorf("get sync change feed %s failed, %s", relation.SyncChangeFeedTaskID, err.Error())
+	}
+	if task.CheckedTime.IsZero() {
+		return 0, fmt.Errorf("checkpoint of sync change feed %s is unknown", task.ID)
+	}
+	checkpoint := task.CheckedTime.Add(-time.Duration(task.CheckpointLag) * time.Millisecond)
+	if lag := since.Sub(checkpoint); lag > 0 {
+		return lag, nil
+	}
+	return 0, nil
+}
+
+func probeReadWrite(ctx context.Context, masterID string, standbys []*clusterMgr.ClusterRelation, now time.Time) error {
+	return mgr.checkClusterReadWriteHealth(ctx, masterID)
+}
+
+func probePD(ctx context.Context, masterID string, standbys []*clusterMgr.ClusterRelation, now time.Time) error {
+	clusterMeta, err := meta.Get(ctx, masterID)
+	if err != nil {
+		return err
+	}
+	addresses := clusterMeta.GetPDClientAddresses()
+	if len(addresses) == 0 {
+		return fmt.Errorf("no PD address")
+	}
+	errs := make([]string, 0)
+	for _, address := range addresses {
+		conn, err := net.DialTimeout("tcp", address.ToString(), constants.FailoverProbePDDialTimeout)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("no PD is reachable, [%s]", strings.Join(errs, "; "))
+}
+
+// probeSyncChangeFeeds
+// @Description: master is regarded as unavailable if all running sync change feeds to standbys are in error or stalled,
+// according to TiCDC. State not refreshed by change feed watcher recently is ignored, because watcher of this host
+// may fail to reach TiCDC for the same reason as other probes
+func probeSyncChangeFeeds(ctx context.Context, masterID string, standbys []*clusterMgr.ClusterRelation, now time.Time) error {
+	checked := 0
+	errs := make([]string, 0)
+	for _, relation := range standbys {
+		task, err := models.GetChangeFeedReaderWriter().Get(ctx, relation.SyncChangeFeedTaskID)
+		if err != nil || task.CheckedTime.IsZero() || now.Sub(task.CheckedTime) > constants.FailoverProbeChangeFeedStaleness {
+			continue
+		}
+		checked++
+		switch constants.ChangeFeedStatus(task.Status) {
+		case constants.ChangeFeedStatusError, constants.ChangeFeedStatusFailed:
+			errs = append(errs, fmt.Sprintf("%s is %s", task.ID, task.Status))
+		case constants.ChangeFeedStatusNormal:
+			lag := time.Duration(task.CheckpointLag)*time.Millisecond + now.Sub(task.CheckedTime)
+			if lag > constants.FailoverProbeChangeFeedMaxLag {
+				errs = append(errs, fmt.Sprintf("%s lags %s", task.ID, lag))
+			}
+		default:
+			// lag of paused task grows as expected
+			checked--
+		}
+	}
+	if checked > 0 && len(errs) == checked {
+		return fmt.Errorf("sync change feeds are unhealthy, [%s]", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func raiseFailoverEvent(ctx context.Context, masterID string, standbyID string, eventType constants.FailoverEventType, message string, workFlowID string) {
+	switch eventType {
+	case constants.FailoverEventTriggered, constants.FailoverEventFailed, constants.FailoverEventSkipped:
+		framework.LogWithContext(ctx).Errorf("automatic failover of master %s %s, %s", masterID, eventType, message)
+	default:
+		framework.LogWithContext(ctx).Warnf("automatic failover of master %s %s, %s", masterID, eventType, message)
+	}
+	metrics.GetMetrics().FailoverCounterMetric.WithLabelValues(masterID, string(eventType)).Inc()
+
+	err := models.GetClusterReaderWriter().CreateFailoverEvent(ctx, &clusterMgr.FailoverEvent{
+		MasterClusterID:  masterID,
+		StandbyClusterID: standbyID,
+		Type:             eventType,
+		Message:          message,
+		WorkFlowID:       workFlowID,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create failover event of master %s failed, %s", masterID, err.Error())
+	}
+}
+
+// failoverThreshold
+// @Description: the smallest threshold of standbys
+func failoverThreshold(standbys []*clusterMgr.ClusterRelation) int {
+	threshold := 0
+	for _, relation := range standbys {
+		if relation.FailoverThreshold > 0 && (threshold == 0 || relation.FailoverThreshold < threshold) {
+			threshold = relation.FailoverThreshold
+		}
+	}
+	if threshold == 0 {
+		return constants.DefaultFailoverThreshold
+	}
+	return threshold
+}
+
+func getFailoverCooldown(ctx context.Context) time.Duration {
+	return time.Duration(platformConfig.GetNonNegativeIntConfig(ctx, constants.ConfigKeyFailoverCooldown, constants.DefaultFailoverCooldown)) * time.Second
+}
+
+func (handler *failoverWatchHandler) getState(masterID string) failoverState {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	if state, ok := handler.states[masterID]; ok {
+		return *state
+	}
+	return failoverState{}
+}
+
+func (handler *failoverWatchHandler) fail(masterID string) failoverState {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	state, ok := handler.states[masterID]
+	if !ok {
+		state = &failoverState{}
+		handler.states[masterID] = state
+	}
+	state.failures++
+	return *state
+}
+
+func (handler *failoverWatchHandler) reset(masterID string, lastHealthy time.Time) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	handler.states[masterID] = &failoverState{lastHealthy: lastHealthy}
+}
+
+// forget states of masters no longer watched
+func (handler *failoverWatchHandler) forget(watched map[string][]*clusterMgr.ClusterRelation) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	for masterID := range handler.states {
+		if _, ok := watched[masterID]; !ok {
+			delete(handler.states, masterID)
+		}
+	}
+}
+
+// getStandbyRelation
+// @Description: get relation of standby cluster to its master
+func (p *Manager) getStandbyRelation(ctx context.Context, clusterID string) (*clusterMgr.ClusterRelation, error) {
+	relations, err := models.GetClusterReaderWriter().GetMasters(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range relations {
+		if relation.RelationType == constants.ClusterRelationStandBy {
+			return relation, nil
+		}
+	}
+	return nil, emerr.NewErrorf(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_NOT_FOUND, "cluster %s is not a standby cluster", clusterID)
+}
+
+// QueryFailoverPolicy
+// @Description: get automatic failover policy of standby cluster, with current state and latest events
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) QueryFailoverPolicy(ctx context.Context, req cluster.QueryFailoverPolicyReq) (resp cluster.QueryFailoverPolicyResp, err error) {
+	relation, err := p.getStandbyRelation(ctx, req.ClusterID)
+	if err != nil {
+		return
+	}
+	events, err := models.GetClusterReaderWriter().QueryFailoverEvents(ctx, req.ClusterID, failoverEventsLength)
+	if err != nil {
+		return
+	}
+
+	resp = cluster.QueryFailoverPolicyResp{
+		ClusterID:           req.ClusterID,
+		MasterClusterID:     relation.SubjectClusterID,
+		Policy:              convertFailoverPolicy(relation),
+		ConsecutiveFailures: failoverHandler.getState(relation.SubjectClusterID).failures,
+		Events:              make([]cluster.FailoverEvent, 0, len(events)),
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, cluster.FailoverEvent{
+			MasterClusterID:  event.MasterClusterID,
+			StandbyClusterID: event.StandbyClusterID,
+			Type:             string(event.Type),
+			Message:          event.Message,
+			WorkFlowID:       event.WorkFlowID,
+			CreateTime:       event.CreatedAt,
+		})
+	}
+	return
+}
+
+// UpdateFailoverPolicy
+// @Description: update automatic failover policy of standby cluster
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) UpdateFailoverPolicy(ctx context.Context, req cluster.UpdateFailoverPolicyReq) (resp cluster.UpdateFailoverPolicyResp, err error) {
+	relation, err := p.getStandbyRelation(ctx, req.ClusterID)
+	if err != nil {
+		return
+	}
+	if req.Enabled && len(relation.SyncChangeFeedTaskID) == 0 {
+		err = emerr.NewErrorf(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_CDC_SYNC_TASK_NOT_FOUND, "no sync change feed from master %s to standby %s", relation.SubjectClusterID, req.ClusterID)
+		return
+	}
+
+	relation.AutoFailover = req.Enabled
+	relation.FailoverThreshold = req.Threshold
+	relation.FailoverMaxLag = req.MaxLag
+	if err = models.GetClusterReaderWriter().UpdateFailoverPolicy(ctx, relation); err != nil {
+		return
+	}
+	framework.LogWithContext(ctx).Infof("update failover policy of standby %s to master %s, %+v", req.ClusterID, relation.SubjectClusterID, req.FailoverPolicy)
+
+	resp = cluster.UpdateFailoverPolicyResp{
+		ClusterID:       req.ClusterID,
+		MasterClusterID: relation.SubjectClusterID,
+		Policy:          convertFailoverPolicy(relation),
+	}
+	return
+}
+
+func convertFailoverPolicy(relation *clusterMgr.ClusterRelation) cluster.FailoverPolicy {
+	return cluster.FailoverPolicy{
+		Enabled:   relation.AutoFailover,
+		Threshold: relation.FailoverThreshold,
+		MaxLag:    relation.FailoverMaxLag,
+	}
+}
@@ -0,0 +1,351 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/platform/config"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockchangefeed"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockconfig"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// stubFailoverHandler handler whose first failedProbes probes fail, and the first probe is independent
+func stubFailoverHandler(failedProbes *int, switched *[]string, switchoverErr error) *failoverWatchHandler {
+	handler := newFailoverWatchHandler()
+	handler.probes = make([]failoverProbe, 0)
+	for i := 0; i < 3; i++ {
+		index := i
+		handler.probes = append(handler.probes, failoverProbe{
+			name:        fmt.Sprintf("probe%d", index),
+			independent: index == 0,
+			probe: func(ctx context.Context, masterID string, standbys []*management.ClusterRelation, now time.Time) error {
+				if index < *failedProbes {
+					return fmt.Errorf("unavailable")
+				}
+				return nil
+			},
+		})
+	}
+	handler.checkStandby = func(ctx context.Context, clusterID string) error {
+		if clusterID == "brokenStandby" {
+			return fmt.Errorf("unavailable")
+		}
+		return nil
+	}
+	handler.switchover = func(ctx context.Context, req *cluster.MasterSlaveClusterSwitchoverReq) (*cluster.MasterSlaveClusterSwitchoverResp, error) {
+		if !req.Force {
+			return nil, fmt.Errorf("failover must be forced")
+		}
+		*switched = append(*switched, req.TargetClusterID)
+		if switchoverErr != nil {
+			return nil, switchoverErr
+		}
+		resp := &cluster.MasterSlaveClusterSwitchoverResp{}
+		resp.WorkFlowID = "flow01"
+		return resp, nil
+	}
+	return handler
+}
+
+func mockFailoverConfig(ctrl *gomock.Controller, cooldown string) {
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), constants.ConfigKeyFailoverCooldown).
+		Return(&config.SystemConfig{ConfigKey: constants.ConfigKeyFailoverCooldown, ConfigValue: cooldown}, nil).AnyTimes()
+}
+
+func TestFailoverWatchHandler_watchMaster(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	standbys := []*management.ClusterRelation{
+		{SubjectClusterID: "master", ObjectClusterID: "standby1", SyncChangeFeedTaskID: "task1", AutoFailover: true, FailoverThreshold: 2},
+		{SubjectClusterID: "master", ObjectClusterID: "standby2", SyncChangeFeedTaskID: "task2", AutoFailover: true},
+	}
+	running := &management.Cluster{Entity: common.Entity{ID: "master", TenantId: "tenant", Status: string(constants.ClusterRunning)}}
+
+	mockFailoverConfig(ctrl, "3600")
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Get(gomock.Any(), "task1").Return(&changefeed.ChangeFeedTask{Entity: common.Entity{ID: "task1"}, CheckedTime: now, CheckpointLag: 5000}, nil).AnyTimes()
+	changefeedRW.EXPECT().Get(gomock.Any(), "task2").Return(&changefeed.ChangeFeedTask{Entity: common.Entity{ID: "task2"}, CheckedTime: now, CheckpointLag: 1000}, nil).AnyTimes()
+
+	t.Run("not running", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "master").Return(&management.Cluster{
+			Entity: common.Entity{ID: "master", Status: string(constants.ClusterStopped)},
+		}, nil).Times(1)
+
+		failed, switched := 3, make([]string, 0)
+		handler := stubFailoverHandler(&failed, &switched, nil)
+		handler.states["master"] = &failoverState{failures: 1}
+		handler.watchMaster(context.TODO(), "master", standbys, now)
+		assert.Equal(t, 0, handler.getState("master").failures)
+		assert.Empty(t, switched)
+	})
+	t.Run("one probe failed", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "master").Return(running, nil).Times(1)
+		clusterRW.EXPECT().CreateFailoverEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event *management.FailoverEvent) error {
+			assert.Equal(t, constants.FailoverEventRecovered, event.Type)
+			return nil
+		}).Times(1)
+
+		failed, switched := 1, make([]string, 0)
+		handler := stubFailoverHandler(&failed, &switched, nil)
+		handler.states["master"] = &failoverState{failures: 1}
+		handler.watchMaster(context.TODO(), "master", standbys, now)
+		assert.Equal(t, 0, handler.getState("master").failures)
+		assert.Equal(t, now, handler.getState("master").lastHealthy)
+	})
+	t.Run("failover", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "master").Return(running, nil).Times(2)
+		clusterRW.EXPECT().CountFailovers(gomock.Any(), []string{"master", "standby1", "standby2"}, gomock.Any()).Return(int64(0), nil).Times(1)
+		events := make([]*management.FailoverEvent, 0)
+		clusterRW.EXPECT().CreateFailoverEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event *management.FailoverEvent) error {
+			events = append(events, event)
+			return nil
+		}).Times(2)
+
+		failed, switched := 2, make([]string, 0)
+		handler := stubFailoverHandler(&failed, &switched, nil)
+		handler.watchMaster(context.TODO(), "master", standbys, now)
+		assert.Equal(t, 1, handler.getState("master").failures)
+		assert.Empty(t, switched)
+
+		handler.watchMaster(context.TODO(), "master", standbys, now.Add(30*time.Second))
+		assert.Equal(t, 0, handler.getState("master").failures)
+		// smallest lag
+		assert.Equal(t, []string{"standby2"}, switched)
+		assert.Equal(t, constants.FailoverEventProbeFailed, events[0].Type)
+		assert.Equal(t, constants.FailoverEventTriggered, events[1].Type)
+		assert.Equal(t, "standby2", events[1].StandbyClusterID)
+		assert.Equal(t, "flow01", events[1].WorkFlowID)
+	})
+	t.Run("not confirmed", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "master").Return(running, nil).Times(1)
+
+		failed, switched := 3, make([]string, 0)
+		handler := stubFailoverHandler(&failed, &switched, nil)
+		// only probes from this host fail, such as network between TiUniManager and master is broken
+		handler.probes[0].independent = false
+		lastHealthy := now.Add(-time.Minute)
+		handler.states["master"] = &failoverState{failures: 1, lastHealthy: lastHealthy}
+		handler.watchMaster(context.TODO(), "master", standbys, now)
+		assert.Equal(t, 0, handler.getState("master").failures)
+		assert.Equal(t, lastHealthy, handler.getState("master").lastHealthy)
+		assert.Empty(t, switched)
+	})
+	t.Run("in cooldown", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "master").Return(running, nil).Times(1)
+		clusterRW.EXPECT().CountFailovers(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(1), nil).Times(1)
+		clusterRW.EXPECT().CreateFailoverEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event *management.FailoverEvent) error {
+			assert.Equal(t, constants.FailoverEventSkipped, event.Type)
+			return nil
+		}).Times(1)
+
+		failed, switched := 3, make([]string, 0)
+		handler := stubFailoverHandler(&failed, &switched, nil)
+		handler.states["master"] = &failoverState{failures: 1}
+		handler.watchMaster(context.TODO(), "master", standbys, now)
+		assert.Empty(t, switched)
+	})
+	t.Run("switchover failed", func(t *testing.T) {
+		clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+		models.SetClusterReaderWriter(clusterRW)
+		clusterRW.EXPECT().Get(gomock.Any(), "master").Return(running, nil).Times(1)
+		clusterRW.EXPECT().CountFailovers(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(0), nil).Times(1)
+		clusterRW.EXPECT().CreateFailoverEvent(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, event *management.FailoverEvent) error {
+			assert.Equal(t, constants.FailoverEventFailed, event.Type)
+			return nil
+		}).Times(1)
+
+		failed, switched := 2, make([]string, 0)
+		handler := stubFailoverHandler(&failed, &switched, fmt.Errorf("slave is unavailable"))
+		handler.states["master"] = &failoverState{failures: 1}
+		handler.watchMaster(context.TODO(), "master", standbys, now)
+		assert.Equal(t, []string{"standby2"}, switched)
+		assert.Equal(t, 0, handler.getState("master").failures)
+	})
+}
+
+func TestFailoverWatchHandler_chooseStandby(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	changefeedRW.EXPECT().Get(gomock.Any(), "task1").Return(&changefeed.ChangeFeedTask{CheckedTime: now, CheckpointLag: 30000}, nil).AnyTimes()
+	changefeedRW.EXPECT().Get(gomock.Any(), "task2").Return(&changefeed.ChangeFeedTask{CheckedTime: now, CheckpointLag: 1000}, nil).AnyTimes()
+	changefeedRW.EXPECT().Get(gomock.Any(), "task3").Return(&changefeed.ChangeFeedTask{}, nil).AnyTimes()
+
+	failed, switched := 0, make([]string, 0)
+	handler := stubFailoverHandler(&failed, &switched, nil)
+
+	t.Run("skip broken standby", func(t *testing.T) {
+		got, err := handler.chooseStandby(context.TODO(), []*management.ClusterRelation{
+			{ObjectClusterID: "standby1", SyncChangeFeedTaskID: "task1"},
+			{ObjectClusterID: "brokenStandby", SyncChangeFeedTaskID: "task2"},
+		}, now)
+		assert.NoError(t, err)
+		assert.Equal(t, "standby1", got.clusterID)
+		assert.Equal(t, 30*time.Second, got.lag)
+	})
+	t.Run("max lag", func(t *testing.T) {
+		_, err := handler.chooseStandby(context.TODO(), []*management.ClusterRelation{
+			{ObjectClusterID: "standby1", SyncChangeFeedTaskID: "task1", FailoverMaxLag: 10},
+			{ObjectClusterID: "standby3", SyncChangeFeedTaskID: "task3"},
+		}, now)
+		assert.Error(t, err)
+	})
+	t.Run("measured against last healthy time", func(t *testing.T) {
+		got, err := handler.chooseStandby(context.TODO(), []*management.ClusterRelation{
+			{ObjectClusterID: "standby1", SyncChangeFeedTaskID: "task1", FailoverMaxLag: 10},
+		}, now.Add(-25*time.Second))
+		assert.NoError(t, err)
+		assert.Equal(t, 5*time.Second, got.lag)
+	})
+}
+
+func Test_probeSyncChangeFeeds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	changefeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changefeedRW)
+	tasks := map[string]*changefeed.ChangeFeedTask{
+		"normal":    {Entity: common.Entity{ID: "normal", Status: string(constants.ChangeFeedStatusNormal)}, CheckedTime: now, CheckpointLag: 1000},
+		"stalled":   {Entity: common.Entity{ID: "stalled", Status: string(constants.ChangeFeedStatusNormal)}, CheckedTime: now.Add(-30 * time.Second), CheckpointLag: 100000},
+		"stale":     {Entity: common.Entity{ID: "stale", Status: string(constants.ChangeFeedStatusError)}, CheckedTime: now.Add(-10 * time.Minute)},
+		"error":     {Entity: common.Entity{ID: "error", Status: string(constants.ChangeFeedStatusError)}, CheckedTime: now},
+		"stopped":   {Entity: common.Entity{ID: "stopped", Status: string(constants.ChangeFeedStatusStopped)}, CheckedTime: now, CheckpointLag: 3600000},
+		"unchecked": {Entity: common.Entity{ID: "unchecked", Status: string(constants.ChangeFeedStatusNormal)}},
+	}
+	changefeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, id string) (*changefeed.ChangeFeedTask, error) {
+		if task, ok := tasks[id]; ok {
+			return task, nil
+		}
+		return nil, errors.Error(errors.TIUNIMANAGER_CHANGE_FEED_NOT_FOUND)
+	}).AnyTimes()
+
+	relations := func(taskIDs ...string) []*management.ClusterRelation {
+		result := make([]*management.ClusterRelation, 0)
+		for _, id := range taskIDs {
+			result = append(result, &management.ClusterRelation{SyncChangeFeedTaskID: id})
+		}
+		return result
+	}
+	tests := []struct {
+		name    string
+		tasks   []string
+		wantErr bool
+	}{
+		{"normal", []string{"normal", "stalled"}, false},
+		{"all unhealthy", []string{"stalled", "error", "stopped"}, true},
+		{"nothing checked", []string{"stopped", "unchecked", "notFound"}, false},
+		{"stale state ignored", []string{"stale", "stopped"}, false},
+		{"stale state ignored with unhealthy", []string{"stale", "error"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := probeSyncChangeFeeds(context.TODO(), "master", relations(tt.tasks...), now)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func Test_failoverThreshold(t *testing.T) {
+	assert.Equal(t, constants.DefaultFailoverThreshold, failoverThreshold([]*management.ClusterRelation{{}}))
+	assert.Equal(t, 2, failoverThreshold([]*management.ClusterRelation{{FailoverThreshold: 5}, {}, {FailoverThreshold: 2}}))
+}
+
+func TestManager_FailoverPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().GetMasters(gomock.Any(), "standby").Return([]*management.ClusterRelation{
+		{Model: gorm.Model{ID: 1}, RelationType: constants.ClusterRelationStandBy, SubjectClusterID: "master", ObjectClusterID: "standby", SyncChangeFeedTaskID: "task1"},
+	}, nil).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), "noTask").Return([]*management.ClusterRelation{
+		{Model: gorm.Model{ID: 2}, RelationType: constants.ClusterRelationStandBy, SubjectClusterID: "master", ObjectClusterID: "noTask"},
+	}, nil).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), "master").Return([]*management.ClusterRelation{}, nil).AnyTimes()
+
+	t.Run("update", func(t *testing.T) {
+		clusterRW.EXPECT().UpdateFailoverPolicy(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, relation *management.ClusterRelation) error {
+			assert.Equal(t, uint(1), relation.ID)
+			assert.True(t, relation.AutoFailover)
+			assert.Equal(t, 5, relation.FailoverThreshold)
+			return nil
+		}).Times(1)
+		resp, err := GetManager().UpdateFailoverPolicy(context.TODO(), cluster.UpdateFailoverPolicyReq{
+			ClusterID:      "standby",
+			FailoverPolicy: cluster.FailoverPolicy{Enabled: true, Threshold: 5, MaxLag: 60},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "master", resp.MasterClusterID)
+		assert.Equal(t, 60, resp.Policy.MaxLag)
+	})
+	t.Run("not standby", func(t *testing.T) {
+		_, err := GetManager().UpdateFailoverPolicy(context.TODO(), cluster.UpdateFailoverPolicyReq{ClusterID: "master"})
+		assert.Equal(t, errors.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+	t.Run("without sync task", func(t *testing.T) {
+		_, err := GetManager().UpdateFailoverPolicy(context.TODO(), cluster.UpdateFailoverPolicyReq{
+			ClusterID:      "noTask",
+			FailoverPolicy: cluster.FailoverPolicy{Enabled: true},
+		})
+		assert.Equal(t, errors.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_CDC_SYNC_TASK_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+	t.Run("query", func(t *testing.T) {
+		clusterRW.EXPECT().QueryFailoverEvents(gomock.Any(), "standby", failoverEventsLength).Return([]*management.FailoverEvent{
+			{MasterClusterID: "master", Type: constants.FailoverEventProbeFailed, Message: "unavailable"},
+		}, nil).Times(1)
+		resp, err := GetManager().QueryFailoverPolicy(context.TODO(), cluster.QueryFailoverPolicyReq{ClusterID: "standby"})
+		assert.NoError(t, err)
+		assert.Equal(t, "master", resp.MasterClusterID)
+		assert.Equal(t, 1, len(resp.Events))
+		assert.Equal(t, string(constants.FailoverEventProbeFailed), resp.Events[0].Type)
+	})
+}
@@ -0,0 +1,303 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package switchover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	clusterMgr "github.com/pingcap/tiunimanager/models/cluster/management"
+	workflow "github.com/pingcap/tiunimanager/workflow2"
+)
+
+// SwitchoverReadiness
+// @Description: report readiness of a planned switchover. Unlike OnlyCheck, all checks are run and reported,
+// together with estimated RPO, states of both sides, re-pointed change feeds, parameter differences and estimated cutover duration
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) SwitchoverReadiness(ctx context.Context, req cluster.SwitchoverReadinessReq) (resp cluster.SwitchoverReadinessResp, err error) {
+	framework.LogWithContext(ctx).Infof("Manager.SwitchoverReadiness, source %s, target %s", req.SourceClusterID, req.TargetClusterID)
+	source, err := models.GetClusterReaderWriter().Get(ctx, req.SourceClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get cluster %s failed, err: %s", req.SourceClusterID, err)
+		return
+	}
+	target, err := models.GetClusterReaderWriter().Get(ctx, req.TargetClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("get cluster %s failed, err: %s", req.TargetClusterID, err)
+		return
+	}
+
+	resp = cluster.SwitchoverReadinessResp{
+		SourceClusterID: req.SourceClusterID,
+		TargetClusterID: req.TargetClusterID,
+		Checks:          make([]cluster.SwitchoverReadinessCheck, 0),
+		Source:          p.getSwitchoverClusterState(ctx, source),
+		Target:          p.getSwitchoverClusterState(ctx, target),
+		EstimatedRPO:    -1,
+	}
+
+	syncTaskID, checkErr := p.getOldSyncChangeFeedTaskId(ctx, "-", "SwitchoverReadiness", req.SourceClusterID, req.TargetClusterID)
+	if checkErr == nil && len(syncTaskID) <= 0 {
+		checkErr = emerr.Error(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_CDC_SYNC_TASK_NOT_FOUND)
+	}
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckRelation, checkErr)
+
+	checkErr = p.checkSwitchoverTopology(ctx, req.SourceClusterID, req.TargetClusterID)
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckTopology, checkErr)
+
+	otherStandbys, checkErr := p.clusterGetOtherSlavesMapToOldSyncCDCTask(ctx, req.SourceClusterID, req.TargetClusterID)
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckOtherStandbys, checkErr)
+
+	checkErr = p.clusterCheckHasCDCComponent(ctx, req.TargetClusterID, emerr.Error(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_SLAVE_NO_CDC_COMPONENT))
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckTargetCDC, checkErr)
+
+	checkErr = checkSwitchoverReadOnlyState(resp.Target, true)
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckTargetReadOnly, checkErr)
+
+	checkErr = checkSwitchoverReadOnlyState(resp.Source, false)
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckSourceWritable, checkErr)
+
+	resp.ChangeFeeds, checkErr = p.getSwitchoverChangeFeeds(ctx, req.SourceClusterID, req.TargetClusterID, syncTaskID, otherStandbys)
+	if checkErr == nil {
+		checkErr = fmt.Errorf("sync change feed %s of target cluster is not found on source cluster", syncTaskID)
+		for _, changeFeed := range resp.ChangeFeeds {
+			if changeFeed.ID != syncTaskID {
+				continue
+			}
+			resp.EstimatedRPO = changeFeed.CheckpointLag
+			if changeFeed.Status != constants.ChangeFeedStatusNormal.ToString() {
+				checkErr = fmt.Errorf("sync change feed %s status is %s instead of Normal", syncTaskID, changeFeed.Status)
+			} else {
+				checkErr = nil
+			}
+		}
+	}
+	addSwitchoverReadinessCheck(&resp, constants.SwitchoverCheckSyncChangeFeed, checkErr)
+
+	resp.ParameterDiffs, err = getSwitchoverParameterDiffs(ctx, req.SourceClusterID, req.TargetClusterID)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("compare parameters of cluster %s and %s failed, err: %s", req.SourceClusterID, req.TargetClusterID, err)
+		return
+	}
+	resp.EstimatedCutover, err = estimateSwitchoverCutover(ctx)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("estimate cutover duration failed, err: %s", err)
+		return
+	}
+
+	resp.Ready = true
+	for _, check := range resp.Checks {
+		resp.Ready = resp.Ready && check.Passed
+	}
+	framework.LogWithContext(ctx).Infof("switchover from %s to %s ready: %v", req.SourceClusterID, req.TargetClusterID, resp.Ready)
+	return
+}
+
+func addSwitchoverReadinessCheck(resp *cluster.SwitchoverReadinessResp, name string, err error) {
+	check := cluster.SwitchoverReadinessCheck{
+		Name:   name,
+		Passed: err == nil,
+	}
+	if err != nil {
+		check.Message = err.Error()
+	}
+	resp.Checks = append(resp.Checks, check)
+}
+
+// checkSwitchoverReadOnlyState the same as CheckSlaveReadOnlyFlag and CheckMasterWritableFlag of switchover
+func checkSwitchoverReadOnlyState(state cluster.SwitchoverClusterState, expectReadOnly bool) error {
+	if state.ReadOnly == nil {
+		return fmt.Errorf("read only state of cluster %s is unknown, %s", state.ClusterID, state.Message)
+	}
+	if *state.ReadOnly != expectReadOnly {
+		return fmt.Errorf("cluster %s readonlyFlag:%v but expect %v", state.ClusterID, *state.ReadOnly, expectReadOnly)
+	}
+	return nil
+}
+
+func (p *Manager) getSwitchoverClusterState(ctx context.Context, c *clusterMgr.Cluster) cluster.SwitchoverClusterState {
+	state := cluster.SwitchoverClusterState{
+		ClusterID: c.ID,
+		Version:   c.Version,
+		Status:    c.Status,
+	}
+	messages := make([]string, 0)
+	if readOnly, err := p.clusterGetReadWriteMode(ctx, c.ID); err != nil {
+		framework.LogWithContext(ctx).Warnf("get read write mode of cluster %s failed, err: %s", c.ID, err)
+		messages = append(messages, fmt.Sprintf("get read only state failed: %s", err))
+	} else {
+		state.ReadOnly = &readOnly
+	}
+	// readiness is only a report, so use the read only probe instead of checkClusterReadWriteHealth which writes to the cluster
+	if err := p.checkClusterReadHealth(ctx, c.ID); err != nil {
+		framework.LogWithContext(ctx).Warnf("check read health of cluster %s failed, err: %s", c.ID, err)
+		messages = append(messages, fmt.Sprintf("check read health failed: %s", err))
+	} else {
+		state.Reachable = true
+	}
+	state.Message = strings.Join(messages, "; ")
+	return state
+}
+
+// getSwitchoverChangeFeeds
+// @Description: change feeds of source cluster, all of them except the sync change feed to target cluster are re-pointed by switchover
+func (p *Manager) getSwitchoverChangeFeeds(ctx context.Context, sourceClusterID, targetClusterID, syncTaskID string,
+	otherStandbys map[string]string) ([]cluster.SwitchoverChangeFeed, error) {
+	standbyOfTask := map[string]string{
+		syncTaskID: targetClusterID,
+	}
+	for standbyID, taskID := range otherStandbys {
+		standbyOfTask[taskID] = standbyID
+	}
+	tasks, _, err := p.changefeedMgr.Query(ctx, cluster.QueryChangeFeedTaskReq{
+		ClusterId: sourceClusterID,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query change feeds of cluster %s failed, err: %s", sourceClusterID, err)
+		return nil, err
+	}
+	changeFeeds := make([]cluster.SwitchoverChangeFeed, 0)
+	for _, task := range tasks {
+		changeFeed := cluster.SwitchoverChangeFeed{
+			ID:               task.ID,
+			Name:             task.Name,
+			Status:           task.Status,
+			StandbyClusterID: standbyOfTask[task.ID],
+			Repointed:        task.ID != syncTaskID,
+			CheckpointLag:    -1,
+		}
+		if task.DownstreamSyncUnix > 0 {
+			changeFeed.CheckpointTime = time.Unix(0, task.DownstreamSyncUnix*int64(time.Millisecond))
+			if task.UpstreamUpdateUnix > 0 {
+				changeFeed.CheckpointLag = task.UpstreamUpdateUnix - task.DownstreamSyncUnix
+				if changeFeed.CheckpointLag < 0 {
+					changeFeed.CheckpointLag = 0
+				}
+			}
+		} else if !task.CheckedTime.IsZero() {
+			// checkpoint of TiCDC is unavailable, use the latest lag measured by change feed watcher
+			changeFeed.CheckpointLag = task.CheckpointLag
+		}
+		changeFeeds = append(changeFeeds, changeFeed)
+	}
+	return changeFeeds, nil
+}
+
+// getSwitchoverParameterDiffs
+// @Description: parameters whose cluster values differ between source and target, a parameter of only one side is also reported
+func getSwitchoverParameterDiffs(ctx context.Context, sourceClusterID, targetClusterID string) ([]cluster.SwitchoverParameterDiff, error) {
+	sourceValues, err := getClusterParameterValues(ctx, sourceClusterID)
+	if err != nil {
+		return nil, err
+	}
+	targetValues, err := getClusterParameterValues(ctx, targetClusterID)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]cluster.SwitchoverParameterDiff, 0)
+	for key, sourceValue := range sourceValues {
+		targetValue := targetValues[key]
+		if sourceValue != targetValue {
+			diffs = append(diffs, cluster.SwitchoverParameterDiff{
+				InstanceType: key.instanceType,
+				Name:         key.name,
+				SourceValue:  sourceValue,
+				TargetValue:  targetValue,
+			})
+		}
+	}
+	for key, targetValue := range targetValues {
+		if _, ok := sourceValues[key]; !ok && targetValue != "" {
+			diffs = append(diffs, cluster.SwitchoverParameterDiff{
+				InstanceType: key.instanceType,
+				Name:         key.name,
+				TargetValue:  targetValue,
+			})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].InstanceType != diffs[j].InstanceType {
+			return diffs[i].InstanceType < diffs[j].InstanceType
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs, nil
+}
+
+type parameterKey struct {
+	instanceType string
+	name         string
+}
+
+func getClusterParameterValues(ctx context.Context, clusterID string) (map[parameterKey]string, error) {
+	_, params, _, err := models.GetClusterParameterReaderWriter().QueryClusterParameter(ctx, clusterID, "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[parameterKey]string)
+	for _, param := range params {
+		realValue := structs.ParameterRealValue{}
+		if len(param.RealValue) > 0 {
+			if err = json.Unmarshal([]byte(param.RealValue), &realValue); err != nil {
+				return nil, emerr.WrapError(emerr.TIUNIMANAGER_CONVERT_OBJ_FAILED, fmt.Sprintf("parse real value of parameter %s failed", param.Name), err)
+			}
+		}
+		values[parameterKey{instanceType: param.InstanceType, name: param.Name}] = realValue.ClusterValue
+	}
+	return values, nil
+}
+
+// estimateSwitchoverCutover
+// @Description: estimate cutover duration by latest finished normal switchover workflows of all clusters
+func estimateSwitchoverCutover(ctx context.Context) (cluster.SwitchoverCutoverEstimate, error) {
+	estimate := cluster.SwitchoverCutoverEstimate{}
+	flows, _, err := models.GetWorkFlowReaderWriter().QueryWorkFlows(ctx, "", workflow.BizTypeCluster,
+		constants.FlowMasterSlaveSwitchoverNormal, constants.WorkFlowStatusFinished, 1, constants.SwitchoverCutoverEstimateSamples)
+	if err != nil {
+		return estimate, err
+	}
+	durations := make([]int64, 0)
+	for _, flow := range flows {
+		if flow.Name != constants.FlowMasterSlaveSwitchoverNormal || flow.UpdatedAt.Before(flow.CreatedAt) {
+			continue
+		}
+		durations = append(durations, int64(flow.UpdatedAt.Sub(flow.CreatedAt).Seconds()))
+	}
+	if len(durations) == 0 {
+		return estimate, nil
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	estimate.Samples = len(durations)
+	estimate.Duration = durations[len(durations)/2]
+	estimate.MaxDuration = durations[len(durations)-1]
+	return estimate, nil
+}
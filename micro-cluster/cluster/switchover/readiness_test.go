@@ -0,0 +1,223 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/cluster/parameter"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/models/parametergroup"
+	workflowModel "github.com/pingcap/tiunimanager/models/workflow"
+	"github.com/pingcap/tiunimanager/test/mockcdcmanager"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclusterparameter"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockworkflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockClusterParameter(instanceType, name, clusterValue string) *parameter.ClusterParamDetail {
+	return &parameter.ClusterParamDetail{
+		Parameter: parametergroup.Parameter{InstanceType: instanceType, Name: name},
+		RealValue: fmt.Sprintf("{\"clusterValue\":\"%s\"}", clusterValue),
+	}
+}
+
+func mockSwitchoverWorkFlow(name string, duration time.Duration) *workflowModel.WorkFlow {
+	now := time.Now()
+	return &workflowModel.WorkFlow{
+		Entity: common.Entity{CreatedAt: now.Add(-duration), UpdatedAt: now, Status: constants.WorkFlowStatusFinished},
+		Name:   name,
+	}
+}
+
+func TestManager_SwitchoverReadiness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	clusterRW.EXPECT().Get(gomock.Any(), "master").Return(&management.Cluster{
+		Entity: common.Entity{ID: "master", Status: string(constants.ClusterRunning)}, Version: "v5.2.2",
+	}, nil).AnyTimes()
+	clusterRW.EXPECT().Get(gomock.Any(), "standby").Return(&management.Cluster{
+		Entity: common.Entity{ID: "standby", Status: string(constants.ClusterRunning)}, Version: "v5.3.0",
+	}, nil).AnyTimes()
+	clusterRW.EXPECT().Get(gomock.Any(), "unknown").Return(nil, fmt.Errorf("not found")).AnyTimes()
+	clusterRW.EXPECT().GetMeta(gomock.Any(), gomock.Any()).Return(nil, nil, nil, fmt.Errorf("meta unavailable")).AnyTimes()
+	clusterRW.EXPECT().GetRelations(gomock.Any(), "standby").Return([]*management.ClusterRelation{
+		{RelationType: constants.ClusterRelationStandBy, SubjectClusterID: "master", ObjectClusterID: "standby", SyncChangeFeedTaskID: "task1"},
+	}, nil).AnyTimes()
+	clusterRW.EXPECT().GetRelations(gomock.Any(), "master").Return([]*management.ClusterRelation{}, nil).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), "standby").Return([]*management.ClusterRelation{
+		{RelationType: constants.ClusterRelationStandBy, SubjectClusterID: "master", ObjectClusterID: "standby", SyncChangeFeedTaskID: "task1"},
+	}, nil).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), "master").Return([]*management.ClusterRelation{}, nil).AnyTimes()
+	clusterRW.EXPECT().GetSlaves(gomock.Any(), "standby").Return([]*management.ClusterRelation{}, nil).AnyTimes()
+	clusterRW.EXPECT().GetSlaves(gomock.Any(), "master").Return([]*management.ClusterRelation{
+		{RelationType: constants.ClusterRelationStandBy, SubjectClusterID: "master", ObjectClusterID: "standby", SyncChangeFeedTaskID: "task1"},
+		{RelationType: constants.ClusterRelationStandBy, SubjectClusterID: "master", ObjectClusterID: "standby2", SyncChangeFeedTaskID: "task2"},
+	}, nil).AnyTimes()
+
+	cdcAPI := mockcdcmanager.NewMockCDCManagerAPI(ctrl)
+	cdcAPI.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, req cluster.QueryChangeFeedTaskReq) ([]cluster.QueryChangeFeedTaskResp, int, error) {
+		if req.ClusterId != "master" {
+			return []cluster.QueryChangeFeedTaskResp{}, 0, nil
+		}
+		tasks := []cluster.QueryChangeFeedTaskResp{{}, {}, {}}
+		tasks[0].ID, tasks[0].Status = "task1", constants.ChangeFeedStatusNormal.ToString()
+		tasks[0].UpstreamUpdateUnix, tasks[0].DownstreamSyncUnix = 1642402881200, 1642402880000
+		tasks[1].ID, tasks[1].Status = "task2", constants.ChangeFeedStatusNormal.ToString()
+		tasks[1].CheckedTime, tasks[1].CheckpointLag = time.Now(), 3000
+		tasks[2].ID, tasks[2].Status = "kafka", constants.ChangeFeedStatusStopped.ToString()
+		return tasks, 3, nil
+	}).AnyTimes()
+	service := GetManager()
+	origin := service.changefeedMgr
+	service.changefeedMgr = cdcAPI
+	defer func() {
+		service.changefeedMgr = origin
+	}()
+
+	parameterRW := mockclusterparameter.NewMockReaderWriter(ctrl)
+	models.SetClusterParameterReaderWriter(parameterRW)
+	parameterRW.EXPECT().QueryClusterParameter(gomock.Any(), "master", "", "", 0, 0).Return("", []*parameter.ClusterParamDetail{
+		mockClusterParameter("TiDB", "mem-quota-query", "1073741824"),
+		mockClusterParameter("TiKV", "storage.reserve-space", "5GB"),
+	}, int64(2), nil).AnyTimes()
+	parameterRW.EXPECT().QueryClusterParameter(gomock.Any(), "standby", "", "", 0, 0).Return("", []*parameter.ClusterParamDetail{
+		mockClusterParameter("TiDB", "mem-quota-query", "2147483648"),
+		mockClusterParameter("TiKV", "storage.reserve-space", "5GB"),
+		mockClusterParameter("PD", "schedule.leader-schedule-limit", "4"),
+	}, int64(3), nil).AnyTimes()
+
+	workflowRW := mockworkflow.NewMockReaderWriter(ctrl)
+	models.SetWorkFlowReaderWriter(workflowRW)
+	workflowRW.EXPECT().QueryWorkFlows(gomock.Any(), "", "cluster", constants.FlowMasterSlaveSwitchoverNormal, constants.WorkFlowStatusFinished, 1, constants.SwitchoverCutoverEstimateSamples).
+		Return([]*workflowModel.WorkFlow{
+			mockSwitchoverWorkFlow(constants.FlowMasterSlaveSwitchoverNormal, 300*time.Second),
+			mockSwitchoverWorkFlow(constants.FlowMasterSlaveSwitchoverNormal, 60*time.Second),
+			mockSwitchoverWorkFlow(constants.FlowMasterSlaveSwitchoverNormal, 120*time.Second),
+		}, int64(3), nil).AnyTimes()
+
+	t.Run("normal", func(t *testing.T) {
+		resp, err := service.SwitchoverReadiness(context.TODO(), cluster.SwitchoverReadinessReq{
+			SourceClusterID: "master",
+			TargetClusterID: "standby",
+		})
+		assert.NoError(t, err)
+		// read only state is unknown without meta
+		assert.False(t, resp.Ready)
+		checks := make(map[string]bool)
+		for _, check := range resp.Checks {
+			checks[check.Name] = check.Passed
+		}
+		assert.Equal(t, map[string]bool{
+			constants.SwitchoverCheckRelation:       true,
+			constants.SwitchoverCheckTopology:       true,
+			constants.SwitchoverCheckOtherStandbys:  true,
+			constants.SwitchoverCheckTargetCDC:      false,
+			constants.SwitchoverCheckTargetReadOnly: false,
+			constants.SwitchoverCheckSourceWritable: false,
+			constants.SwitchoverCheckSyncChangeFeed: true,
+		}, checks)
+
+		assert.Equal(t, "v5.2.2", resp.Source.Version)
+		assert.Equal(t, "v5.3.0", resp.Target.Version)
+		assert.Nil(t, resp.Target.ReadOnly)
+		assert.False(t, resp.Target.Reachable)
+		assert.NotEmpty(t, resp.Target.Message)
+
+		assert.Equal(t, int64(1200), resp.EstimatedRPO)
+		assert.Equal(t, 3, len(resp.ChangeFeeds))
+		assert.False(t, resp.ChangeFeeds[0].Repointed)
+		assert.Equal(t, "standby", resp.ChangeFeeds[0].StandbyClusterID)
+		assert.True(t, resp.ChangeFeeds[1].Repointed)
+		assert.Equal(t, "standby2", resp.ChangeFeeds[1].StandbyClusterID)
+		assert.Equal(t, int64(3000), resp.ChangeFeeds[1].CheckpointLag)
+		assert.True(t, resp.ChangeFeeds[2].Repointed)
+		assert.Empty(t, resp.ChangeFeeds[2].StandbyClusterID)
+		assert.Equal(t, int64(-1), resp.ChangeFeeds[2].CheckpointLag)
+
+		assert.Equal(t, []cluster.SwitchoverParameterDiff{
+			{InstanceType: "PD", Name: "schedule.leader-schedule-limit", TargetValue: "4"},
+			{InstanceType: "TiDB", Name: "mem-quota-query", SourceValue: "1073741824", TargetValue: "2147483648"},
+		}, resp.ParameterDiffs)
+
+		assert.Equal(t, cluster.SwitchoverCutoverEstimate{Samples: 3, Duration: 120, MaxDuration: 300}, resp.EstimatedCutover)
+	})
+	t.Run("not standby", func(t *testing.T) {
+		resp, err := service.SwitchoverReadiness(context.TODO(), cluster.SwitchoverReadinessReq{
+			SourceClusterID: "standby",
+			TargetClusterID: "master",
+		})
+		assert.NoError(t, err)
+		assert.False(t, resp.Ready)
+		assert.Equal(t, constants.SwitchoverCheckRelation, resp.Checks[0].Name)
+		assert.False(t, resp.Checks[0].Passed)
+		// source is a copy of master
+		assert.Equal(t, constants.SwitchoverCheckTopology, resp.Checks[1].Name)
+		assert.False(t, resp.Checks[1].Passed)
+		assert.Equal(t, int64(-1), resp.EstimatedRPO)
+	})
+	t.Run("cluster not found", func(t *testing.T) {
+		_, err := service.SwitchoverReadiness(context.TODO(), cluster.SwitchoverReadinessReq{
+			SourceClusterID: "master",
+			TargetClusterID: "unknown",
+		})
+		assert.Error(t, err)
+	})
+}
+
+func Test_estimateSwitchoverCutover(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	workflowRW := mockworkflow.NewMockReaderWriter(ctrl)
+	models.SetWorkFlowReaderWriter(workflowRW)
+
+	t.Run("no history", func(t *testing.T) {
+		workflowRW.EXPECT().QueryWorkFlows(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return([]*workflowModel.WorkFlow{}, int64(0), nil).Times(1)
+		got, err := estimateSwitchoverCutover(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, got.Samples)
+	})
+	t.Run("fuzzy matched", func(t *testing.T) {
+		workflowRW.EXPECT().QueryWorkFlows(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return([]*workflowModel.WorkFlow{
+				mockSwitchoverWorkFlow(constants.FlowMasterSlaveSwitchoverNormal, 90*time.Second),
+				mockSwitchoverWorkFlow("SwitchoverNormalXXX", 900*time.Second),
+			}, int64(2), nil).Times(1)
+		got, err := estimateSwitchoverCutover(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, cluster.SwitchoverCutoverEstimate{Samples: 1, Duration: 90, MaxDuration: 90}, got)
+	})
+	t.Run("error", func(t *testing.T) {
+		workflowRW.EXPECT().QueryWorkFlows(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, int64(0), fmt.Errorf("db error")).Times(1)
+		_, err := estimateSwitchoverCutover(context.TODO())
+		assert.Error(t, err)
+	})
+}
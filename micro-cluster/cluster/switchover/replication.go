@@ -0,0 +1,482 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	emerr "github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	changefeedModel "github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	clusterMgr "github.com/pingcap/tiunimanager/models/cluster/management"
+)
+
+// CreateClusterRelation
+// @Description: create a standby, cascade or bidirectional relation between two existing clusters.
+// Target cluster must be standalone, so that no cycle of one-way relations is introduced.
+// Target of one-way relation must be restored from a full backup of source cluster, and replication starts from tso of the backup
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) CreateClusterRelation(ctx context.Context, req cluster.CreateClusterRelationReq) (resp cluster.CreateClusterRelationResp, err error) {
+	framework.LogWithContext(ctx).Infof("Manager.CreateClusterRelation, source %s, target %s, type %s",
+		req.SourceClusterID, req.TargetClusterID, req.RelationType)
+	relationType := constants.ClusterRelationType(req.RelationType)
+	if req.SourceClusterID == req.TargetClusterID {
+		err = emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "source and target are the same cluster %s", req.SourceClusterID)
+		return
+	}
+	source, err := models.GetClusterReaderWriter().Get(ctx, req.SourceClusterID)
+	if err != nil {
+		return
+	}
+	target, err := models.GetClusterReaderWriter().Get(ctx, req.TargetClusterID)
+	if err != nil {
+		return
+	}
+	if err = p.clusterCheckNoRelation(ctx, req.TargetClusterID); err != nil {
+		err = emerr.WrapError(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "target cluster should be standalone", err)
+		return
+	}
+	upstream, err := p.getOneWayUpstream(ctx, req.SourceClusterID)
+	if err != nil {
+		return
+	}
+
+	resp = cluster.CreateClusterRelationResp{
+		SourceClusterID: req.SourceClusterID,
+		TargetClusterID: req.TargetClusterID,
+		RelationType:    req.RelationType,
+	}
+	switch relationType {
+	case constants.ClusterRelationStandBy, constants.ClusterRelationCascade:
+		if relationType == constants.ClusterRelationStandBy && upstream != nil {
+			err = emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID,
+				"source cluster %s is a copy of cluster %s, create cascade relation instead", req.SourceClusterID, upstream.SubjectClusterID)
+			return
+		}
+		if relationType == constants.ClusterRelationCascade && upstream == nil {
+			err = emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID,
+				"source cluster %s is not a copy of any cluster, create standby relation instead", req.SourceClusterID)
+			return
+		}
+		startTS, checkErr := checkRelationBackup(ctx, req.BackupID, req.SourceClusterID)
+		if checkErr != nil {
+			err = checkErr
+			return
+		}
+		resp.ChangeFeedTaskID, err = p.createOneWayRelation(ctx, source, target, relationType, startTS)
+	case constants.ClusterRelationBidirectional:
+		if upstream != nil {
+			err = emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID,
+				"source cluster %s is a read only copy of cluster %s", req.SourceClusterID, upstream.SubjectClusterID)
+			return
+		}
+		if err = checkBidirectionalTables(req.Tables, req.ReverseTables); err != nil {
+			return
+		}
+		resp.ChangeFeedTaskID, resp.ReverseChangeFeedTaskID, err = p.createBidirectionalRelation(ctx, source, target, req.Tables, req.ReverseTables)
+	default:
+		err = emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "unsupported relation type %s", req.RelationType)
+	}
+	return
+}
+
+// checkRelationBackup
+// @Description: target of one-way relation must be a copy of source cluster, the same as a clone synchronized by change feed.
+// So it is required to be restored from a finished full backup of the whole source cluster, whose tso replication starts from
+// @Parameter ctx
+// @Parameter backupID
+// @Parameter sourceClusterID
+// @return string start ts of sync change feed
+// @return error
+func checkRelationBackup(ctx context.Context, backupID string, sourceClusterID string) (string, error) {
+	if len(backupID) == 0 {
+		return "", emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID,
+			"target cluster should be restored from a full backup of source cluster %s, and the backup is required", sourceClusterID)
+	}
+	record, err := models.GetBRReaderWriter().GetBackupRecord(ctx, backupID)
+	if err != nil {
+		return "", emerr.WrapError(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, fmt.Sprintf("get backup %s failed", backupID), err)
+	}
+	if record.ClusterID != sourceClusterID {
+		return "", emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "backup %s is not taken from source cluster %s", backupID, sourceClusterID)
+	}
+	if record.Status != string(constants.ClusterBackupFinished) || record.BackupType != string(constants.BackupTypeFull) || record.BackupTso == 0 {
+		return "", emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "backup %s is not a finished full backup", backupID)
+	}
+	// backups of selected databases or tables miss data of other tables
+	if record.Databases != "" || record.Tables != "" {
+		return "", emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "backup %s does not cover the whole source cluster", backupID)
+	}
+	return strconv.FormatUint(record.BackupTso, 10), nil
+}
+
+// getOneWayUpstream
+// @Description: get standby or cascade relation of the cluster to its upstream, nil if the cluster is not a copy
+func (p *Manager) getOneWayUpstream(ctx context.Context, clusterID string) (*clusterMgr.ClusterRelation, error) {
+	masters, err := models.GetClusterReaderWriter().GetMasters(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range masters {
+		if relation.RelationType.IsOneWay() {
+			return relation, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Manager) createOneWayRelation(ctx context.Context, source, target *clusterMgr.Cluster,
+	relationType constants.ClusterRelationType, startTS string) (taskID string, err error) {
+	if err = p.clusterSetReadonly(ctx, target.ID); err != nil {
+		framework.LogWithContext(ctx).Errorf("set cluster %s read only failed, err: %s", target.ID, err)
+		return
+	}
+	taskID, err = p.createRelationChangeFeedTask(ctx, source, target, constants.DefaultFilterRules, startTS)
+	if err != nil {
+		return
+	}
+	err = models.GetClusterReaderWriter().CreateRelation(ctx, &clusterMgr.ClusterRelation{
+		RelationType:         relationType,
+		SubjectClusterID:     source.ID,
+		ObjectClusterID:      target.ID,
+		SyncChangeFeedTaskID: taskID,
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create relation from %s to %s failed, err: %s", source.ID, target.ID, err)
+		p.removeRelationChangeFeedTask(ctx, taskID)
+	}
+	return
+}
+
+// createBidirectionalRelation
+// @Description: each direction only replicates its own tables, so that changes are never replicated back
+func (p *Manager) createBidirectionalRelation(ctx context.Context, source, target *clusterMgr.Cluster,
+	tables, reverseTables []string) (taskID string, reverseTaskID string, err error) {
+	taskID, err = p.createRelationChangeFeedTask(ctx, source, target, bidirectionalFilterRules(tables), "0")
+	if err != nil {
+		return
+	}
+	reverseTaskID, err = p.createRelationChangeFeedTask(ctx, target, source, bidirectionalFilterRules(reverseTables), "0")
+	if err != nil {
+		p.removeRelationChangeFeedTask(ctx, taskID)
+		return
+	}
+	err = models.GetClusterReaderWriter().CreateRelation(ctx, &clusterMgr.ClusterRelation{
+		RelationType:         constants.ClusterRelationBidirectional,
+		SubjectClusterID:     source.ID,
+		ObjectClusterID:      target.ID,
+		SyncChangeFeedTaskID: taskID,
+	})
+	if err == nil {
+		err = models.GetClusterReaderWriter().CreateRelation(ctx, &clusterMgr.ClusterRelation{
+			RelationType:         constants.ClusterRelationBidirectional,
+			SubjectClusterID:     target.ID,
+			ObjectClusterID:      source.ID,
+			SyncChangeFeedTaskID: reverseTaskID,
+		})
+		if err != nil {
+			p.deleteRelationsBetween(ctx, source.ID, target.ID)
+		}
+	}
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create bidirectional relation between %s and %s failed, err: %s", source.ID, target.ID, err)
+		p.removeRelationChangeFeedTask(ctx, taskID)
+		p.removeRelationChangeFeedTask(ctx, reverseTaskID)
+	}
+	return
+}
+
+// createRelationChangeFeedTask
+// @Description: create sync change feed task from source to target, starting from startTS, or current tso if startTS is "0"
+func (p *Manager) createRelationChangeFeedTask(ctx context.Context, source, target *clusterMgr.Cluster, filterRules []string, startTS string) (string, error) {
+	userName, password, err := p.clusterGetCDCUserNameAndPwd(ctx, target.ID)
+	if err != nil {
+		return "", err
+	}
+	ip, port, err := p.clusterGetOneConnectIPPort(ctx, target.ID)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.changefeedMgr.Create(ctx, cluster.CreateChangeFeedTaskReq{
+		Name:           fmt.Sprintf("from-%s-to-%s", source.Name, target.Name),
+		ClusterID:      source.ID,
+		StartTS:        startTS,
+		FilterRules:    filterRules,
+		DownstreamType: string(constants.DownstreamTypeTiDB),
+		Downstream: &changefeedModel.TiDBDownstream{
+			Ip:              ip,
+			Port:            port,
+			Username:        userName,
+			Password:        password,
+			Tls:             target.TLS,
+			TargetClusterId: target.ID,
+		},
+	})
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("create change feed task from %s to %s failed, err: %s", source.ID, target.ID, err)
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (p *Manager) removeRelationChangeFeedTask(ctx context.Context, taskID string) {
+	if len(taskID) == 0 {
+		return
+	}
+	if err := p.removeChangeFeedTask(ctx, taskID); err != nil {
+		framework.LogWithContext(ctx).Errorf("remove change feed task %s failed, err: %s", taskID, err)
+	}
+}
+
+// bidirectionalFilterRules tables of one direction, excluding the same tables as DefaultFilterRules
+func bidirectionalFilterRules(tables []string) []string {
+	rules := make([]string, 0)
+	for _, table := range tables {
+		rules = append(rules, strings.TrimSpace(table))
+	}
+	for _, rule := range constants.DefaultFilterRules {
+		if strings.HasPrefix(rule, "!") {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkBidirectionalTables
+// @Description: tables are in form of db.table or db.*, and tables of two directions must not overlap
+func checkBidirectionalTables(tables, reverseTables []string) error {
+	if len(tables) == 0 || len(reverseTables) == 0 {
+		return emerr.NewError(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "tables of both directions are required for bidirectional relation")
+	}
+	parse := func(table string) (string, string, error) {
+		units := strings.Split(strings.TrimSpace(table), ".")
+		if len(units) != 2 || len(units[0]) == 0 || len(units[1]) == 0 ||
+			strings.ContainsAny(units[0], "*?![]") || units[1] != "*" && strings.ContainsAny(units[1], "*?![]") {
+			return "", "", emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID, "table %s should be in form of db.table or db.*", table)
+		}
+		return strings.ToLower(units[0]), strings.ToLower(units[1]), nil
+	}
+	for _, table := range tables {
+		db, name, err := parse(table)
+		if err != nil {
+			return err
+		}
+		for _, reverseTable := range reverseTables {
+			reverseDB, reverseName, err := parse(reverseTable)
+			if err != nil {
+				return err
+			}
+			if db == reverseDB && (name == "*" || reverseName == "*" || name == reverseName) {
+				return emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID,
+					"table %s overlaps with %s of reverse direction", table, reverseTable)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteClusterRelation
+// @Description: delete relation and its sync change feed tasks, target of one-way relation becomes a standalone writable cluster
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) DeleteClusterRelation(ctx context.Context, req cluster.DeleteClusterRelationReq) (resp cluster.DeleteClusterRelationResp, err error) {
+	framework.LogWithContext(ctx).Infof("Manager.DeleteClusterRelation, source %s, target %s", req.SourceClusterID, req.TargetClusterID)
+	relation, err := p.getRelationBetween(ctx, req.SourceClusterID, req.TargetClusterID)
+	if err != nil {
+		return
+	}
+	resp = cluster.DeleteClusterRelationResp{
+		SourceClusterID: req.SourceClusterID,
+		TargetClusterID: req.TargetClusterID,
+	}
+
+	if relation.RelationType.IsOneWay() {
+		slaves, getErr := p.clusterGetRelationsByMasterClusterId(ctx, req.TargetClusterID)
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		if len(slaves) > 0 {
+			err = emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_INVALID,
+				"cluster %s has %d downstream clusters, delete their relations first", req.TargetClusterID, len(slaves))
+			return
+		}
+		if err = p.deleteRelationWithTask(ctx, relation); err != nil {
+			return
+		}
+		err = p.clusterSetReadWrite(ctx, req.TargetClusterID)
+		return
+	}
+
+	reverse, err := p.getRelationBetween(ctx, req.TargetClusterID, req.SourceClusterID)
+	if err != nil {
+		return
+	}
+	if err = p.deleteRelationWithTask(ctx, relation); err != nil {
+		return
+	}
+	err = p.deleteRelationWithTask(ctx, reverse)
+	return
+}
+
+func (p *Manager) getRelationBetween(ctx context.Context, sourceClusterID, targetClusterID string) (*clusterMgr.ClusterRelation, error) {
+	relations, err := models.GetClusterReaderWriter().GetMasters(ctx, targetClusterID)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range relations {
+		if relation.SubjectClusterID == sourceClusterID {
+			return relation, nil
+		}
+	}
+	return nil, emerr.NewErrorf(emerr.TIUNIMANAGER_CLUSTER_RELATION_NOT_FOUND,
+		"relation from %s to %s is not found", sourceClusterID, targetClusterID)
+}
+
+func (p *Manager) deleteRelationWithTask(ctx context.Context, relation *clusterMgr.ClusterRelation) error {
+	if len(relation.SyncChangeFeedTaskID) > 0 {
+		if err := p.removeChangeFeedTask(ctx, relation.SyncChangeFeedTaskID); err != nil {
+			framework.LogWithContext(ctx).Errorf("remove change feed task %s failed, err: %s", relation.SyncChangeFeedTaskID, err)
+			return err
+		}
+	}
+	return models.GetClusterReaderWriter().DeleteRelation(ctx, relation.ID)
+}
+
+// deleteRelationsBetween rollback relations created between clusters
+func (p *Manager) deleteRelationsBetween(ctx context.Context, sourceClusterID, targetClusterID string) {
+	for _, ids := range [][]string{{sourceClusterID, targetClusterID}, {targetClusterID, sourceClusterID}} {
+		if relation, err := p.getRelationBetween(ctx, ids[0], ids[1]); err == nil {
+			if err = models.GetClusterReaderWriter().DeleteRelation(ctx, relation.ID); err != nil {
+				framework.LogWithContext(ctx).Errorf("delete relation %d failed, err: %s", relation.ID, err)
+			}
+		}
+	}
+}
+
+// QueryReplicationGraph
+// @Description: get all clusters connected with the cluster by relations, with sync change feed state of each relation
+// @Receiver p
+// @Parameter ctx
+// @Parameter req
+// @return resp
+// @return err
+func (p *Manager) QueryReplicationGraph(ctx context.Context, req cluster.QueryReplicationGraphReq) (resp cluster.QueryReplicationGraphResp, err error) {
+	rw := models.GetClusterReaderWriter()
+	relations := make(map[uint]*clusterMgr.ClusterRelation)
+	visited := map[string]bool{req.ClusterID: true}
+	for queue := []string{req.ClusterID}; len(queue) > 0; queue = queue[1:] {
+		masters, getErr := rw.GetMasters(ctx, queue[0])
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		slaves, getErr := rw.GetSlaves(ctx, queue[0])
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		for _, relation := range append(masters, slaves...) {
+			relations[relation.ID] = relation
+			for _, id := range []string{relation.SubjectClusterID, relation.ObjectClusterID} {
+				if !visited[id] {
+					visited[id] = true
+					queue = append(queue, id)
+				}
+			}
+		}
+	}
+
+	upstreams := make(map[string]string)
+	resp.Edges = make([]cluster.ReplicationEdge, 0)
+	for _, relation := range relations {
+		if relation.RelationType.IsOneWay() {
+			upstreams[relation.ObjectClusterID] = relation.SubjectClusterID
+		}
+		resp.Edges = append(resp.Edges, p.getReplicationEdge(ctx, relation))
+	}
+	sort.Slice(resp.Edges, func(i, j int) bool {
+		if resp.Edges[i].SourceClusterID != resp.Edges[j].SourceClusterID {
+			return resp.Edges[i].SourceClusterID < resp.Edges[j].SourceClusterID
+		}
+		return resp.Edges[i].TargetClusterID < resp.Edges[j].TargetClusterID
+	})
+
+	resp.Nodes = make([]cluster.ReplicationNode, 0)
+	for id := range visited {
+		node := cluster.ReplicationNode{
+			ClusterID:         id,
+			UpstreamClusterID: upstreams[id],
+		}
+		for upstream := upstreams[id]; len(upstream) > 0 && node.Depth < len(visited); upstream = upstreams[upstream] {
+			node.Depth++
+		}
+		if got, getErr := rw.Get(ctx, id); getErr == nil {
+			node.Name = got.Name
+			node.Status = got.Status
+		} else {
+			framework.LogWithContext(ctx).Warnf("get cluster %s failed, err: %s", id, getErr)
+		}
+		resp.Nodes = append(resp.Nodes, node)
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool {
+		if resp.Nodes[i].Depth != resp.Nodes[j].Depth {
+			return resp.Nodes[i].Depth < resp.Nodes[j].Depth
+		}
+		return resp.Nodes[i].ClusterID < resp.Nodes[j].ClusterID
+	})
+	return
+}
+
+func (p *Manager) getReplicationEdge(ctx context.Context, relation *clusterMgr.ClusterRelation) cluster.ReplicationEdge {
+	edge := cluster.ReplicationEdge{
+		SourceClusterID:  relation.SubjectClusterID,
+		TargetClusterID:  relation.ObjectClusterID,
+		RelationType:     string(relation.RelationType),
+		ChangeFeedTaskID: relation.SyncChangeFeedTaskID,
+		CheckpointLag:    -1,
+	}
+	if len(relation.SyncChangeFeedTaskID) == 0 {
+		return edge
+	}
+	task, err := models.GetChangeFeedReaderWriter().Get(ctx, relation.SyncChangeFeedTaskID)
+	if err != nil {
+		framework.LogWithContext(ctx).Warnf("get change feed task %s failed, err: %s", relation.SyncChangeFeedTaskID, err)
+		return edge
+	}
+	edge.Status = task.Status
+	edge.FilterRules = task.FilterRules
+	edge.LagLevel = task.LagLevel
+	edge.CheckedTime = task.CheckedTime
+	if !task.CheckedTime.IsZero() {
+		edge.CheckpointLag = task.CheckpointLag
+	}
+	return edge
+}
@@ -0,0 +1,285 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/message/cluster"
+	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
+	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/models/cluster/management"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/test/mockcdcmanager"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockchangefeed"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// mockReplicationRelations A->B->C and A<->D
+func mockReplicationRelations(clusterRW *mockclustermanagement.MockReaderWriter) {
+	relation := func(id uint, relationType constants.ClusterRelationType, subject, object, taskID string) *management.ClusterRelation {
+		return &management.ClusterRelation{
+			Model:                gorm.Model{ID: id},
+			RelationType:         relationType,
+			SubjectClusterID:     subject,
+			ObjectClusterID:      object,
+			SyncChangeFeedTaskID: taskID,
+		}
+	}
+	ab := relation(1, constants.ClusterRelationStandBy, "A", "B", "task-ab")
+	bc := relation(2, constants.ClusterRelationCascade, "B", "C", "task-bc")
+	ad := relation(3, constants.ClusterRelationBidirectional, "A", "D", "task-ad")
+	da := relation(4, constants.ClusterRelationBidirectional, "D", "A", "task-da")
+	masters := map[string][]*management.ClusterRelation{"A": {da}, "B": {ab}, "C": {bc}, "D": {ad}, "E": {}}
+	slaves := map[string][]*management.ClusterRelation{"A": {ab, ad}, "B": {bc}, "C": {}, "D": {da}, "E": {}}
+	clusterRW.EXPECT().GetMasters(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, clusterID string) ([]*management.ClusterRelation, error) {
+		return masters[clusterID], nil
+	}).AnyTimes()
+	clusterRW.EXPECT().GetSlaves(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, clusterID string) ([]*management.ClusterRelation, error) {
+		return slaves[clusterID], nil
+	}).AnyTimes()
+	clusterRW.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, clusterID string) (*management.Cluster, error) {
+		return &management.Cluster{
+			Entity: common.Entity{ID: clusterID, Status: string(constants.ClusterRunning)},
+			Name:   "name-" + clusterID,
+		}, nil
+	}).AnyTimes()
+}
+
+func TestManager_checkSwitchoverTopology(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	mockReplicationRelations(clusterRW)
+
+	service := GetManager()
+	t.Run("middle node", func(t *testing.T) {
+		err := service.checkSwitchoverTopology(context.TODO(), "B", "C")
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_TOPOLOGY_UNSUPPORTED, err.(errors.EMError).GetCode())
+	})
+	t.Run("bidirectional", func(t *testing.T) {
+		err := service.checkSwitchoverTopology(context.TODO(), "A", "B")
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_TOPOLOGY_UNSUPPORTED, err.(errors.EMError).GetCode())
+	})
+	t.Run("normal", func(t *testing.T) {
+		err := service.checkSwitchoverTopology(context.TODO(), "E", "B")
+		assert.NoError(t, err)
+	})
+}
+
+func Test_checkBidirectionalTables(t *testing.T) {
+	tests := []struct {
+		name          string
+		tables        []string
+		reverseTables []string
+		wantErr       bool
+	}{
+		{"normal", []string{"db1.*", "db2.t1"}, []string{"db2.t2", "db3.*"}, false},
+		{"empty", []string{"db1.*"}, []string{}, true},
+		{"same table", []string{"db1.t1"}, []string{"DB1.T1"}, true},
+		{"all tables of db", []string{"db1.t1"}, []string{"db1.*"}, true},
+		{"wildcard db", []string{"*.t1"}, []string{"db1.t2"}, true},
+		{"pattern", []string{"db1.t*"}, []string{"db1.a1"}, true},
+		{"invalid", []string{"db1"}, []string{"db2.*"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBidirectionalTables(tt.tables, tt.reverseTables)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func Test_bidirectionalFilterRules(t *testing.T) {
+	assert.Equal(t, []string{"db1.*", "db2.t1", "!__TiDB_BR_Temporary*.*"}, bidirectionalFilterRules([]string{"db1.*", " db2.t1"}))
+}
+
+func TestManager_CreateClusterRelation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	mockReplicationRelations(clusterRW)
+	brRW := mockbr.NewMockReaderWriter(ctrl)
+	models.SetBRReaderWriter(brRW)
+	backups := map[string]*backuprestore.BackupRecord{
+		"other":    {Entity: common.Entity{ID: "other", Status: string(constants.ClusterBackupFinished)}, ClusterID: "B", BackupType: string(constants.BackupTypeFull), BackupTso: 1000},
+		"running":  {Entity: common.Entity{ID: "running", Status: string(constants.ClusterBackupProcessing)}, ClusterID: "A", BackupType: string(constants.BackupTypeFull)},
+		"full":     {Entity: common.Entity{ID: "full", Status: string(constants.ClusterBackupFinished)}, ClusterID: "A", BackupType: string(constants.BackupTypeFull), BackupTso: 1000},
+		"filtered": {Entity: common.Entity{ID: "filtered", Status: string(constants.ClusterBackupFinished)}, ClusterID: "A", BackupType: string(constants.BackupTypeFull), BackupTso: 1000, Databases: "db1"},
+	}
+	brRW.EXPECT().GetBackupRecord(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, backupID string) (*backuprestore.BackupRecord, error) {
+		if record, ok := backups[backupID]; ok {
+			return record, nil
+		}
+		return nil, gorm.ErrRecordNotFound
+	}).AnyTimes()
+
+	service := GetManager()
+	tests := []struct {
+		name string
+		req  cluster.CreateClusterRelationReq
+	}{
+		{"same cluster", cluster.CreateClusterRelationReq{SourceClusterID: "E", TargetClusterID: "E", RelationType: "StandBy"}},
+		{"target not standalone", cluster.CreateClusterRelationReq{SourceClusterID: "E", TargetClusterID: "C", RelationType: "StandBy"}},
+		{"standby of copy", cluster.CreateClusterRelationReq{SourceClusterID: "B", TargetClusterID: "E", RelationType: "StandBy"}},
+		{"cascade of root", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "Cascade"}},
+		{"standby without backup", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "StandBy"}},
+		{"backup not found", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "StandBy", BackupID: "unknown"}},
+		{"backup of other cluster", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "StandBy", BackupID: "other"}},
+		{"backup not finished", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "StandBy", BackupID: "running"}},
+		{"filtered backup", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "StandBy", BackupID: "filtered"}},
+		{"bidirectional of copy", cluster.CreateClusterRelationReq{SourceClusterID: "C", TargetClusterID: "E", RelationType: "Bidirectional",
+			Tables: []string{"db1.*"}, ReverseTables: []string{"db2.*"}}},
+		{"bidirectional overlapped", cluster.CreateClusterRelationReq{SourceClusterID: "A", TargetClusterID: "E", RelationType: "Bidirectional",
+			Tables: []string{"db1.*"}, ReverseTables: []string{"db1.t1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateClusterRelation(context.TODO(), tt.req)
+			assert.Error(t, err)
+			assert.Equal(t, errors.TIUNIMANAGER_CLUSTER_RELATION_INVALID, err.(errors.EMError).GetCode())
+		})
+	}
+	t.Run("start from backup", func(t *testing.T) {
+		startTS, err := checkRelationBackup(context.TODO(), "full", "A")
+		assert.NoError(t, err)
+		assert.Equal(t, "1000", startTS)
+	})
+}
+
+func TestManager_DeleteClusterRelation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	mockReplicationRelations(clusterRW)
+
+	cdcAPI := mockcdcmanager.NewMockCDCManagerAPI(ctrl)
+	service := GetManager()
+	origin := service.changefeedMgr
+	service.changefeedMgr = cdcAPI
+	defer func() {
+		service.changefeedMgr = origin
+	}()
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := service.DeleteClusterRelation(context.TODO(), cluster.DeleteClusterRelationReq{SourceClusterID: "A", TargetClusterID: "C"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CLUSTER_RELATION_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+	t.Run("with downstream", func(t *testing.T) {
+		_, err := service.DeleteClusterRelation(context.TODO(), cluster.DeleteClusterRelationReq{SourceClusterID: "A", TargetClusterID: "B"})
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_CLUSTER_RELATION_INVALID, err.(errors.EMError).GetCode())
+	})
+	t.Run("bidirectional", func(t *testing.T) {
+		cdcAPI.EXPECT().Delete(gomock.Any(), cluster.DeleteChangeFeedTaskReq{ID: "task-ad"}).Return(cluster.DeleteChangeFeedTaskResp{}, nil)
+		cdcAPI.EXPECT().Delete(gomock.Any(), cluster.DeleteChangeFeedTaskReq{ID: "task-da"}).Return(cluster.DeleteChangeFeedTaskResp{}, nil)
+		clusterRW.EXPECT().DeleteRelation(gomock.Any(), uint(3)).Return(nil)
+		clusterRW.EXPECT().DeleteRelation(gomock.Any(), uint(4)).Return(nil)
+		_, err := service.DeleteClusterRelation(context.TODO(), cluster.DeleteClusterRelationReq{SourceClusterID: "D", TargetClusterID: "A"})
+		assert.NoError(t, err)
+	})
+	t.Run("delete task failed", func(t *testing.T) {
+		cdcAPI.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(cluster.DeleteChangeFeedTaskResp{}, fmt.Errorf("cdc unavailable"))
+		_, err := service.DeleteClusterRelation(context.TODO(), cluster.DeleteClusterRelationReq{SourceClusterID: "A", TargetClusterID: "D"})
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_QueryReplicationGraph(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterRW := mockclustermanagement.NewMockReaderWriter(ctrl)
+	models.SetClusterReaderWriter(clusterRW)
+	mockReplicationRelations(clusterRW)
+
+	checkedTime := time.Now()
+	changeFeedRW := mockchangefeed.NewMockReaderWriter(ctrl)
+	models.SetChangeFeedReaderWriter(changeFeedRW)
+	changeFeedRW.EXPECT().Get(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, taskID string) (*changefeed.ChangeFeedTask, error) {
+		switch taskID {
+		case "task-ab":
+			return &changefeed.ChangeFeedTask{
+				Entity:        common.Entity{ID: taskID, Status: constants.ChangeFeedStatusNormal.ToString()},
+				FilterRules:   constants.DefaultFilterRules,
+				CheckpointLag: 1200,
+				LagLevel:      string(constants.ChangeFeedLagNormal),
+				CheckedTime:   checkedTime,
+			}, nil
+		case "task-bc":
+			return &changefeed.ChangeFeedTask{
+				Entity: common.Entity{ID: taskID, Status: constants.ChangeFeedStatusNormal.ToString()},
+			}, nil
+		default:
+			return nil, fmt.Errorf("not found")
+		}
+	}).AnyTimes()
+
+	service := GetManager()
+	t.Run("from leaf", func(t *testing.T) {
+		resp, err := service.QueryReplicationGraph(context.TODO(), cluster.QueryReplicationGraphReq{ClusterID: "C"})
+		assert.NoError(t, err)
+		assert.Equal(t, []cluster.ReplicationNode{
+			{ClusterID: "A", Name: "name-A", Status: string(constants.ClusterRunning)},
+			{ClusterID: "D", Name: "name-D", Status: string(constants.ClusterRunning)},
+			{ClusterID: "B", Name: "name-B", Status: string(constants.ClusterRunning), UpstreamClusterID: "A", Depth: 1},
+			{ClusterID: "C", Name: "name-C", Status: string(constants.ClusterRunning), UpstreamClusterID: "B", Depth: 2},
+		}, resp.Nodes)
+		assert.Equal(t, 4, len(resp.Edges))
+		assert.Equal(t, cluster.ReplicationEdge{
+			SourceClusterID:  "A",
+			TargetClusterID:  "B",
+			RelationType:     string(constants.ClusterRelationStandBy),
+			ChangeFeedTaskID: "task-ab",
+			Status:           constants.ChangeFeedStatusNormal.ToString(),
+			FilterRules:      constants.DefaultFilterRules,
+			CheckpointLag:    1200,
+			LagLevel:         string(constants.ChangeFeedLagNormal),
+			CheckedTime:      checkedTime,
+		}, resp.Edges[0])
+		assert.Equal(t, "D", resp.Edges[1].TargetClusterID)
+		assert.Equal(t, int64(-1), resp.Edges[1].CheckpointLag)
+		assert.Empty(t, resp.Edges[1].Status)
+		assert.Equal(t, string(constants.ClusterRelationCascade), resp.Edges[2].RelationType)
+		assert.Equal(t, int64(-1), resp.Edges[2].CheckpointLag)
+		assert.Equal(t, "D", resp.Edges[3].SourceClusterID)
+	})
+	t.Run("standalone", func(t *testing.T) {
+		resp, err := service.QueryReplicationGraph(context.TODO(), cluster.QueryReplicationGraphReq{ClusterID: "E"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(resp.Nodes))
+		assert.Empty(t, resp.Edges)
+	})
+}
@@ -209,6 +209,11 @@ func (p *Manager) Switchover(ctx context.Context, req *cluster.MasterSlaveCluste
 	if len(oldSyncChangeFeedTaskId) <= 0 {
 		return resp, emerr.Error(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_CDC_SYNC_TASK_NOT_FOUND)
 	}
+	err = mgr.checkSwitchoverTopology(ctx, oldMasterId, oldSlaveId)
+	framework.LogWithContext(ctx).Infof("req:%s checkSwitchoverTopology err:%v", reqJson, err)
+	if err != nil {
+		return resp, err
+	}
 	otherSlavesMapToOldSyncCDCTask, err := mgr.clusterGetOtherSlavesMapToOldSyncCDCTask(ctx, oldMasterId, oldSlaveId)
 	if err != nil {
 		return resp, err
@@ -608,6 +613,20 @@ func (p *Manager) checkClusterReadWriteHealth(ctx context.Context, clusterID str
 	return p.checkClusterWritable(ctx, clusterID, userName, password, addr)
 }
 
+// checkClusterReadHealth the same as checkClusterReadWriteHealth, but nothing is written to the cluster
+func (p *Manager) checkClusterReadHealth(ctx context.Context, clusterID string) error {
+	userName, password, err := p.clusterGetCDCUserNameAndPwd(ctx, clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster's mysql userName and password, err:%s", err)
+	}
+	var addr string
+	addr, err = p.clusterGetOneConnectAddress(ctx, clusterID)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster's mysql access addr, err:%s", err)
+	}
+	return p.checkClusterReadable(ctx, clusterID, userName, password, addr)
+}
+
 // with special `RESTRICTED_REPLICA_WRITER_ADMIN` privilege already set
 func (p *Manager) clusterGetCDCUserNameAndPwd(ctx context.Context, clusterID string) (userName, password string, err error) {
 	framework.LogWithContext(ctx).Info("clusterGetCDCUserNameAndPwd clusterID:", clusterID)
@@ -809,7 +828,7 @@ func (m *Manager) clusterGetRelationByMasterSlaveClusterId(ctx context.Context,
 		return nil, err
 	}
 	for _, v := range relations {
-		if v.SubjectClusterID == masterClusterId && v.RelationType == constants.ClusterRelationStandBy {
+		if v.SubjectClusterID == masterClusterId && v.RelationType.IsOneWay() {
 			relation = v
 			break
 		}
@@ -828,13 +847,37 @@ func (m *Manager) clusterGetRelationsByMasterClusterId(ctx context.Context, mast
 	}
 	var ret []*clusterMgr.ClusterRelation
 	for _, v := range relations {
-		if v.RelationType == constants.ClusterRelationStandBy {
+		if v.RelationType.IsOneWay() {
 			ret = append(ret, v)
 		}
 	}
 	return ret, err
 }
 
+// checkSwitchoverTopology
+// @Description: switchover is only supported between the writable root of a replication chain and one of its direct slaves.
+// Promoting a middle node of A->B->C is done by switching over A and B, after which B->C becomes a standby relation of B
+func (m *Manager) checkSwitchoverTopology(ctx context.Context, oldMasterClusterId, oldSlaveClusterId string) error {
+	for _, clusterId := range []string{oldMasterClusterId, oldSlaveClusterId} {
+		masters, err := models.GetClusterReaderWriter().GetMasters(ctx, clusterId)
+		if err != nil {
+			return err
+		}
+		for _, v := range masters {
+			if v.RelationType == constants.ClusterRelationBidirectional {
+				return emerr.NewErrorf(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_TOPOLOGY_UNSUPPORTED,
+					"cluster %s has bidirectional relation with cluster %s", clusterId, v.SubjectClusterID)
+			}
+			if clusterId == oldMasterClusterId && v.RelationType.IsOneWay() {
+				return emerr.NewErrorf(emerr.TIUNIMANAGER_MASTER_SLAVE_SWITCHOVER_TOPOLOGY_UNSUPPORTED,
+					"master cluster %s is a copy of cluster %s, switchover from the root of replication chain instead",
+					oldMasterClusterId, v.SubjectClusterID)
+			}
+		}
+	}
+	return nil
+}
+
 func (m *Manager) clusterGetOtherSlavesMapToOldSyncCDCTask(ctx context.Context, oldMasterClusterId, oldSlaveClusterId string) (map[string]string, error) {
 	relations, err := m.clusterGetRelationsByMasterClusterId(ctx, oldMasterClusterId)
 	if err != nil {
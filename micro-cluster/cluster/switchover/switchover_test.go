@@ -106,6 +106,19 @@ func TestSwitchover(t *testing.T) {
 			},
 		}, nil,
 	).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), gomock.Eq("2")).Return(
+		[]*clusterMgr.ClusterRelation{
+			{
+				RelationType:         constants.ClusterRelationStandBy,
+				SubjectClusterID:     "1",
+				ObjectClusterID:      "2",
+				SyncChangeFeedTaskID: "1",
+			},
+		}, nil,
+	).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), gomock.Eq("1")).Return(
+		[]*clusterMgr.ClusterRelation{}, nil,
+	).AnyTimes()
 	clusterRW.EXPECT().GetSlaves(gomock.Any(), gomock.Eq("1")).Return(
 		[]*clusterMgr.ClusterRelation{
 			{
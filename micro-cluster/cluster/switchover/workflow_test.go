@@ -105,6 +105,19 @@ func TestWorkFlowToDO(t *testing.T) {
 			},
 		}, nil,
 	).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), gomock.Eq("2")).Return(
+		[]*clusterMgr.ClusterRelation{
+			{
+				RelationType:         constants.ClusterRelationStandBy,
+				SubjectClusterID:     "1",
+				ObjectClusterID:      "2",
+				SyncChangeFeedTaskID: "1",
+			},
+		}, nil,
+	).AnyTimes()
+	clusterRW.EXPECT().GetMasters(gomock.Any(), gomock.Eq("1")).Return(
+		[]*clusterMgr.ClusterRelation{}, nil,
+	).AnyTimes()
 	clusterRW.EXPECT().GetSlaves(gomock.Any(), gomock.Eq("1")).Return(
 		[]*clusterMgr.ClusterRelation{
 			{
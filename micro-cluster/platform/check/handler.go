@@ -39,6 +39,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const GetClusterInfoCmd = "SELECT TYPE as type, count(TYPE) as count FROM information_schema.cluster_info GROUP BY TYPE;"
@@ -346,6 +347,26 @@ func (p *Report) GetClusterRegionStatus(ctx context.Context, clusterID string) (
 	return regionStatus, nil
 }
 
+// GetClusterBackupRecordValid
+// @Description: get verification results of cluster backups, true if the backup has been restored and verified
+// @Parameter ctx
+// @Parameter clusterID
+// @return map[string]bool backup id as key, backups never verified or being verified are not included
+// @return error
+func (p *Report) GetClusterBackupRecordValid(ctx context.Context, clusterID string) (map[string]bool, error) {
+	backupRecordValid := make(map[string]bool)
+	for _, status := range []constants.BackupVerifyStatus{constants.BackupVerifyVerified, constants.BackupVerifyFailed} {
+		records, err := models.GetBRReaderWriter().QueryBackupRecordsByVerifyStatus(ctx, clusterID, string(status), time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			backupRecordValid[record.ID] = status == constants.BackupVerifyVerified
+		}
+	}
+	return backupRecordValid, nil
+}
+
 func (p *Report) GetClusterHealthStatus(ctx context.Context, clusterID string) (structs.CheckStatus, error) {
 	healthStatus := structs.CheckStatus{}
 
@@ -469,6 +490,10 @@ func (p *Report) CheckClusters(ctx context.Context, clusterMetas []*management.R
 			if err != nil {
 				return clusterChecks, err
 			}
+			backupRecordValid, err := p.GetClusterBackupRecordValid(ctx, meta.Cluster.ID)
+			if err != nil {
+				return clusterChecks, err
+			}
 			clusterChecks = append(clusterChecks, structs.ClusterCheck{
 				ID:                meta.Cluster.ID,
 				MaintenanceStatus: meta.Cluster.MaintenanceStatus,
@@ -486,6 +511,8 @@ func (p *Report) CheckClusters(ctx context.Context, clusterMetas []*management.R
 				Topology:      topologyCheck,
 				RegionStatus:  regionStatus,
 				Instances:     instanceChecks,
+
+				BackupRecordValid: backupRecordValid,
 			})
 		} else {
 			clusterChecks = append(clusterChecks, structs.ClusterCheck{
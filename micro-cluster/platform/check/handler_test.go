@@ -24,6 +24,7 @@ import (
 	"github.com/pingcap/tiunimanager/deployment"
 	hostInspector "github.com/pingcap/tiunimanager/micro-cluster/resourcemanager/inspect"
 	"github.com/pingcap/tiunimanager/models"
+	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
 	"github.com/pingcap/tiunimanager/models/common"
 	"github.com/pingcap/tiunimanager/models/resource/resourcepool"
@@ -31,6 +32,7 @@ import (
 	mock_deployment "github.com/pingcap/tiunimanager/test/mockdeployment"
 	mock_hosts_inspect "github.com/pingcap/tiunimanager/test/mockhostsinspect"
 	mock_account "github.com/pingcap/tiunimanager/test/mockmodels/mockaccount"
+	"github.com/pingcap/tiunimanager/test/mockmodels/mockbr"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockclustermanagement"
 	"github.com/pingcap/tiunimanager/test/mockmodels/mockresource"
 	"github.com/stretchr/testify/assert"
@@ -695,3 +697,33 @@ func TestReport_GetClusterHealthStatus(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestReport_GetClusterBackupRecordValid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("normal", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), "111", string(constants.BackupVerifyVerified), gomock.Any()).
+			Return([]*backuprestore.BackupRecord{{Entity: common.Entity{ID: "backup01"}}}, nil)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), "111", string(constants.BackupVerifyFailed), gomock.Any()).
+			Return([]*backuprestore.BackupRecord{{Entity: common.Entity{ID: "backup02"}}}, nil)
+
+		report := &Report{}
+		valid, err := report.GetClusterBackupRecordValid(ctx.TODO(), "111")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{"backup01": true, "backup02": false}, valid)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		brRW := mockbr.NewMockReaderWriter(ctrl)
+		models.SetBRReaderWriter(brRW)
+		brRW.EXPECT().QueryBackupRecordsByVerifyStatus(gomock.Any(), "111", gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("query error"))
+
+		report := &Report{}
+		_, err := report.GetClusterBackupRecordValid(ctx.TODO(), "111")
+		assert.Error(t, err)
+	})
+}
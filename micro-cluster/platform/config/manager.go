@@ -17,7 +17,11 @@ package config
 
 import (
 	"context"
+	"strconv"
+	"strings"
+
 	"github.com/pingcap/tiunimanager/common/structs"
+	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/message"
 	"github.com/pingcap/tiunimanager/models"
 	"github.com/pingcap/tiunimanager/models/platform/config"
@@ -50,3 +54,25 @@ func (mgr *SystemConfigManager) UpdateSystemConfig(ctx context.Context, request
 	}
 	return resp, nil
 }
+
+// GetNonNegativeIntConfig
+// @Description: get non-negative integer system config, defaultValue is used if the config is missing or invalid
+// @Parameter ctx
+// @Parameter key
+// @Parameter defaultValue
+// @return int
+func GetNonNegativeIntConfig(ctx context.Context, key string, defaultValue string) int {
+	value, err := strconv.Atoi(defaultValue)
+	if err != nil {
+		return 0
+	}
+	valueConfig, err := models.GetConfigReaderWriter().GetConfig(ctx, key)
+	if err != nil {
+		framework.LogWithContext(ctx).Warnf("get config %s failed: %s", key, err.Error())
+	} else if configValue, err := strconv.Atoi(strings.TrimSpace(valueConfig.ConfigValue)); err != nil || configValue < 0 {
+		framework.LogWithContext(ctx).Warnf("invalid config %s: %s, use default %s", key, valueConfig.ConfigValue, defaultValue)
+	} else {
+		value = configValue
+	}
+	return value
+}
@@ -17,6 +17,7 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/pingcap/tiunimanager/message"
 	"github.com/pingcap/tiunimanager/models"
@@ -44,3 +45,23 @@ func TestSystemConfigManager_GetSystemConfig(t *testing.T) {
 	})
 	assert.Nil(t, err)
 }
+
+func TestGetNonNegativeIntConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	configRW := mockconfig.NewMockReaderWriter(ctrl)
+	models.SetConfigReaderWriter(configRW)
+	configRW.EXPECT().GetConfig(gomock.Any(), "valid").Return(&config.SystemConfig{ConfigKey: "valid", ConfigValue: " 30 "}, nil).AnyTimes()
+	configRW.EXPECT().GetConfig(gomock.Any(), "zero").Return(&config.SystemConfig{ConfigKey: "zero", ConfigValue: "0"}, nil).AnyTimes()
+	configRW.EXPECT().GetConfig(gomock.Any(), "negative").Return(&config.SystemConfig{ConfigKey: "negative", ConfigValue: "-1"}, nil).AnyTimes()
+	configRW.EXPECT().GetConfig(gomock.Any(), "invalid").Return(&config.SystemConfig{ConfigKey: "invalid", ConfigValue: "abc"}, nil).AnyTimes()
+	configRW.EXPECT().GetConfig(gomock.Any(), "missing").Return(nil, fmt.Errorf("not found")).AnyTimes()
+
+	assert.Equal(t, 30, GetNonNegativeIntConfig(context.TODO(), "valid", "10"))
+	assert.Equal(t, 0, GetNonNegativeIntConfig(context.TODO(), "zero", "10"))
+	assert.Equal(t, 10, GetNonNegativeIntConfig(context.TODO(), "negative", "10"))
+	assert.Equal(t, 10, GetNonNegativeIntConfig(context.TODO(), "invalid", "10"))
+	assert.Equal(t, 10, GetNonNegativeIntConfig(context.TODO(), "missing", "10"))
+	assert.Equal(t, 0, GetNonNegativeIntConfig(context.TODO(), "missing", "invalid default"))
+}
@@ -86,6 +86,9 @@ func validateVendorData(vendor structs.VendorConfigInfo) error {
 		} else {
 			specInfos[code] = 1
 		}
+		if s.UnitPrice < 0 || s.ResourceWeight < 0 {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unit price and resource weight of spec %s should not be negative", s.ID)
+		}
 	}
 	return nil
 }
@@ -339,6 +342,9 @@ func convertComponentInstanceResourceSpecs(vendor structs.VendorConfigInfo, comp
 				DiskType: spec.DiskType,
 				ZoneID:   zoneID,
 				ZoneName: zoneName,
+
+				UnitPrice:      spec.UnitPrice,
+				ResourceWeight: spec.ResourceWeight,
 			})
 		}
 	}
@@ -373,6 +379,9 @@ func convertVendorRequest(reqConfig structs.VendorConfigInfo) (*product.Vendor,
 			Memory:      spec.Memory,
 			DiskType:    spec.DiskType,
 			PurposeType: spec.PurposeType,
+
+			UnitPrice:      spec.UnitPrice,
+			ResourceWeight: spec.ResourceWeight,
 		})
 	}
 	return vendorInfo, zones, specs
@@ -426,6 +435,9 @@ func convertToSpecResponse(specs []*product.VendorSpec) []structs.SpecInfo {
 			Memory:      spec.Memory,
 			DiskType:    spec.DiskType,
 			PurposeType: spec.PurposeType,
+
+			UnitPrice:      spec.UnitPrice,
+			ResourceWeight: spec.ResourceWeight,
 		})
 	}
 	return result
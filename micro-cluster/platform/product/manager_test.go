@@ -161,6 +161,31 @@ func TestManager_UpdateVendors(t *testing.T) {
 		})
 		assert.Error(t, err)
 	})
+
+	t.Run("negative price", func(t *testing.T) {
+		_, err := NewManager().UpdateVendors(context.TODO(), message.UpdateVendorInfoReq{
+			Vendors: []structs.VendorConfigInfo{
+				{
+					VendorInfo: structs.VendorInfo{
+						ID:   "Local",
+						Name: "local",
+					},
+					Specs: []structs.SpecInfo{
+						{
+							ID:          "c.large",
+							Name:        "c.large",
+							CPU:         4,
+							Memory:      8,
+							DiskType:    "SATA",
+							PurposeType: "Compute",
+							UnitPrice:   -1,
+						},
+					},
+				},
+			},
+		})
+		assert.Error(t, err)
+	})
 }
 
 func TestManager_QueryAvailableVendors(t *testing.T) {
@@ -35,6 +35,8 @@ type Applicant struct {
 	HolderId          string
 	RequestId         string
 	TakeoverOperation bool
+	// hosts reserved for platform use are allowed to be allocated, e.g. by scratch clusters verifying backups
+	ReservedHostsAllowed bool
 }
 
 type AllocRequirement struct {
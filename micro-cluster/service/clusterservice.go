@@ -52,6 +52,8 @@ import (
 	clusterLog "github.com/pingcap/tiunimanager/micro-cluster/cluster/log"
 	clusterManager "github.com/pingcap/tiunimanager/micro-cluster/cluster/management"
 	clusterParameter "github.com/pingcap/tiunimanager/micro-cluster/cluster/parameter"
+	dmManager "github.com/pingcap/tiunimanager/micro-cluster/cluster/dm"
+	gcManager "github.com/pingcap/tiunimanager/micro-cluster/cluster/gc"
 	switchoverManager "github.com/pingcap/tiunimanager/micro-cluster/cluster/switchover"
 	"github.com/pingcap/tiunimanager/micro-cluster/datatransfer/importexport"
 	"github.com/pingcap/tiunimanager/micro-cluster/parametergroup"
@@ -67,6 +69,8 @@ type ClusterServiceHandler struct {
 	resourceManager         *resourcemanager.ResourceManager
 	changeFeedManager       *changefeed.Manager
 	switchoverManager       *switchoverManager.Manager
+	gcManager               *gcManager.Manager
+	dmManager               *dmManager.Manager
 	parameterGroupManager   *parametergroup.Manager
 	clusterParameterManager *clusterParameter.Manager
 	clusterManager          *clusterManager.Manager
@@ -174,10 +178,17 @@ func NewClusterServiceHandler(fw *framework.BaseFramework) *ClusterServiceHandle
 	handler := new(ClusterServiceHandler)
 	handler.resourceManager = resourcemanager.NewResourceManager()
 	handler.changeFeedManager = changefeed.GetManager()
+	changefeed.StartChangeFeedWatcher()
+	changefeed.InitConsistencyCheck()
 	handler.parameterGroupManager = parametergroup.NewManager()
 	handler.clusterParameterManager = clusterParameter.NewManager()
 	handler.clusterManager = clusterManager.NewClusterManager()
 	handler.switchoverManager = switchoverManager.GetManager()
+	switchoverManager.StartFailoverWatcher()
+	handler.gcManager = gcManager.GetManager()
+	gcManager.StartGCReconciler()
+	handler.dmManager = dmManager.GetManager()
+	dmManager.StartDMTaskWatcher()
 	handler.systemConfigManager = config.NewSystemConfigManager()
 	handler.systemManager = system.GetSystemManager()
 	handler.brManager = backuprestore.GetBRService()
@@ -212,6 +223,295 @@ func (handler *ClusterServiceHandler) MasterSlaveSwitchover(ctx context.Context,
 	return nil
 }
 
+func (handler *ClusterServiceHandler) QueryFailoverPolicy(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryFailoverPolicy", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryFailoverPolicy", resp)
+
+	request := cluster.QueryFailoverPolicyReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.switchoverManager.QueryFailoverPolicy(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) UpdateFailoverPolicy(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "UpdateFailoverPolicy", int(resp.GetCode()))
+	defer handlePanic(ctx, "UpdateFailoverPolicy", resp)
+
+	request := cluster.UpdateFailoverPolicyReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.switchoverManager.UpdateFailoverPolicy(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) SwitchoverReadiness(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "SwitchoverReadiness", int(resp.GetCode()))
+	defer handlePanic(ctx, "SwitchoverReadiness", resp)
+
+	request := cluster.SwitchoverReadinessReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.switchoverManager.SwitchoverReadiness(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) CreateClusterRelation(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "CreateClusterRelation", int(resp.GetCode()))
+	defer handlePanic(ctx, "CreateClusterRelation", resp)
+
+	request := cluster.CreateClusterRelationReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionCreate)}}) {
+		result, err := handler.switchoverManager.CreateClusterRelation(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) DeleteClusterRelation(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DeleteClusterRelation", int(resp.GetCode()))
+	defer handlePanic(ctx, "DeleteClusterRelation", resp)
+
+	request := cluster.DeleteClusterRelationReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionDelete)}}) {
+		result, err := handler.switchoverManager.DeleteClusterRelation(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) QueryReplicationGraph(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryReplicationGraph", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryReplicationGraph", resp)
+
+	request := cluster.QueryReplicationGraphReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.switchoverManager.QueryReplicationGraph(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) QueryClusterGC(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryClusterGC", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryClusterGC", resp)
+
+	request := cluster.QueryClusterGCReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.gcManager.Query(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) CreateGCHold(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "CreateGCHold", int(resp.GetCode()))
+	defer handlePanic(ctx, "CreateGCHold", resp)
+
+	request := cluster.CreateGCHoldReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.gcManager.CreateHold(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) ReleaseGCHold(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ReleaseGCHold", int(resp.GetCode()))
+	defer handlePanic(ctx, "ReleaseGCHold", resp)
+
+	request := cluster.ReleaseGCHoldReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.gcManager.ReleaseHold(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) RegisterDMSource(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "RegisterDMSource", int(resp.GetCode()))
+	defer handlePanic(ctx, "RegisterDMSource", resp)
+
+	request := cluster.RegisterDMSourceReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionCreate)}}) {
+		result, err := handler.dmManager.RegisterSource(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) QueryDMSources(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryDMSources", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryDMSources", resp)
+
+	request := cluster.QueryDMSourcesReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.dmManager.QuerySources(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) DeleteDMSource(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DeleteDMSource", int(resp.GetCode()))
+	defer handlePanic(ctx, "DeleteDMSource", resp)
+
+	request := cluster.DeleteDMSourceReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionDelete)}}) {
+		result, err := handler.dmManager.DeleteSource(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) CreateDMTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "CreateDMTask", int(resp.GetCode()))
+	defer handlePanic(ctx, "CreateDMTask", resp)
+
+	request := cluster.CreateDMTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionCreate)}}) {
+		result, err := handler.dmManager.CreateTask(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) StartDMTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "StartDMTask", int(resp.GetCode()))
+	defer handlePanic(ctx, "StartDMTask", resp)
+
+	request := cluster.DMTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.dmManager.StartTask(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) PauseDMTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "PauseDMTask", int(resp.GetCode()))
+	defer handlePanic(ctx, "PauseDMTask", resp)
+
+	request := cluster.DMTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.dmManager.PauseTask(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) StopDMTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "StopDMTask", int(resp.GetCode()))
+	defer handlePanic(ctx, "StopDMTask", resp)
+
+	request := cluster.DMTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.dmManager.StopTask(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) QueryDMTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryDMTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryDMTasks", resp)
+
+	request := cluster.QueryDMTasksReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, total, err := handler.dmManager.QueryTasks(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, &clusterservices.RpcPage{
+			Page:     int32(request.Page),
+			PageSize: int32(request.PageSize),
+			Total:    int32(total),
+		})
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) DetailDMTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DetailDMTask", int(resp.GetCode()))
+	defer handlePanic(ctx, "DetailDMTask", resp)
+
+	request := cluster.DetailDMTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.dmManager.DetailTask(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) HandoffDMTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "HandoffDMTask", int(resp.GetCode()))
+	defer handlePanic(ctx, "HandoffDMTask", resp)
+
+	request := cluster.HandoffDMTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.dmManager.Handoff(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (handler *ClusterServiceHandler) CreateChangeFeedTask(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
 	start := time.Now()
 	defer metrics.HandleClusterMetrics(start, "CreateChangeFeedTask", int(resp.GetCode()))
@@ -298,20 +598,189 @@ func (handler *ClusterServiceHandler) UpdateChangeFeedTask(ctx context.Context,
 	return nil
 }
 
-func (handler *ClusterServiceHandler) QueryChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+func (handler *ClusterServiceHandler) QueryChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryChangeFeedTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryChangeFeedTasks", resp)
+
+	request := cluster.QueryChangeFeedTaskReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionRead)}}) {
+		result, total, err := handler.changeFeedManager.Query(ctx, request)
+		handleResponse(ctx, resp, err, result, &clusterservices.RpcPage{
+			Page:     int32(request.Page),
+			PageSize: int32(request.PageSize),
+			Total:    int32(total),
+		})
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) StartConsistencyCheck(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "StartConsistencyCheck", int(resp.GetCode()))
+	defer handlePanic(ctx, "StartConsistencyCheck", resp)
+
+	request := &cluster.StartConsistencyCheckReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionCreate)}}) {
+		result, err := handler.changeFeedManager.StartConsistencyCheck(framework.NewBackgroundMicroCtx(ctx, false), *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) QueryConsistencyChecks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryConsistencyChecks", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryConsistencyChecks", resp)
+
+	request := cluster.QueryConsistencyChecksReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionRead)}}) {
+		result, total, err := handler.changeFeedManager.QueryConsistencyChecks(ctx, request)
+		handleResponse(ctx, resp, err, result, &clusterservices.RpcPage{
+			Page:     int32(request.Page),
+			PageSize: int32(request.PageSize),
+			Total:    int32(total),
+		})
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) DetailConsistencyCheck(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DetailConsistencyCheck", int(resp.GetCode()))
+	defer handlePanic(ctx, "DetailConsistencyCheck", resp)
+
+	request := &cluster.DetailConsistencyCheckReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.changeFeedManager.DetailConsistencyCheck(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) SaveConsistencySchedule(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "SaveConsistencySchedule", int(resp.GetCode()))
+	defer handlePanic(ctx, "SaveConsistencySchedule", resp)
+
+	request := &cluster.SaveConsistencyScheduleReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.changeFeedManager.SaveConsistencySchedule(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) GetConsistencySchedule(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "GetConsistencySchedule", int(resp.GetCode()))
+	defer handlePanic(ctx, "GetConsistencySchedule", resp)
+
+	request := &cluster.GetConsistencyScheduleReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.changeFeedManager.GetConsistencySchedule(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) DeleteConsistencySchedule(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DeleteConsistencySchedule", int(resp.GetCode()))
+	defer handlePanic(ctx, "DeleteConsistencySchedule", resp)
+
+	request := &cluster.DeleteConsistencyScheduleReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.changeFeedManager.DeleteConsistencySchedule(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) BatchPauseChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "BatchPauseChangeFeedTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "BatchPauseChangeFeedTasks", resp)
+
+	request := &cluster.BatchChangeFeedTasksReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.changeFeedManager.BatchPause(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) BatchResumeChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "BatchResumeChangeFeedTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "BatchResumeChangeFeedTasks", resp)
+
+	request := &cluster.BatchChangeFeedTasksReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.changeFeedManager.BatchResume(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) BatchDeleteChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "BatchDeleteChangeFeedTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "BatchDeleteChangeFeedTasks", resp)
+
+	request := &cluster.BatchChangeFeedTasksReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionDelete)}}) {
+		result, err := handler.changeFeedManager.BatchDelete(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) ExportChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ExportChangeFeedTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "ExportChangeFeedTasks", resp)
+
+	request := &cluster.ExportChangeFeedTasksReq{}
+
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.changeFeedManager.ExportTasks(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) ImportChangeFeedTasks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
 	start := time.Now()
-	defer metrics.HandleClusterMetrics(start, "QueryChangeFeedTasks", int(resp.GetCode()))
-	defer handlePanic(ctx, "QueryChangeFeedTasks", resp)
+	defer metrics.HandleClusterMetrics(start, "ImportChangeFeedTasks", int(resp.GetCode()))
+	defer handlePanic(ctx, "ImportChangeFeedTasks", resp)
 
-	request := cluster.QueryChangeFeedTaskReq{}
+	request := &cluster.ImportChangeFeedTasksReq{}
 
-	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionRead)}}) {
-		result, total, err := handler.changeFeedManager.Query(ctx, request)
-		handleResponse(ctx, resp, err, result, &clusterservices.RpcPage{
-			Page:     int32(request.Page),
-			PageSize: int32(request.PageSize),
-			Total:    int32(total),
-		})
+	if handleRequest(ctx, req, resp, request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCDC), Action: string(constants.RbacActionCreate)}}) {
+		result, err := handler.changeFeedManager.ImportTasks(ctx, *request)
+		handleResponse(ctx, resp, err, result, nil)
 	}
 
 	return nil
@@ -531,6 +1000,21 @@ func (c ClusterServiceHandler) PreviewScaleOutCluster(ctx context.Context, req *
 	return nil
 }
 
+func (c ClusterServiceHandler) QueryTenantCostReport(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) (err error) {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryTenantCostReport", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryTenantCostReport", resp)
+
+	request := cluster.QueryTenantCostReportReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.clusterManager.QueryTenantCostReport(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (c ClusterServiceHandler) RestoreNewCluster(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) (err error) {
 	start := time.Now()
 	defer metrics.HandleClusterMetrics(start, "RestoreNewCluster", int(resp.GetCode()))
@@ -546,6 +1030,51 @@ func (c ClusterServiceHandler) RestoreNewCluster(ctx context.Context, req *clust
 	return nil
 }
 
+func (handler *ClusterServiceHandler) ExportClusterSpec(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ExportClusterSpec", int(resp.GetCode()))
+	defer handlePanic(ctx, "ExportClusterSpec", resp)
+
+	request := cluster.ExportClusterSpecReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.clusterManager.ExportClusterSpec(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) PlanClusterSpec(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "PlanClusterSpec", int(resp.GetCode()))
+	defer handlePanic(ctx, "PlanClusterSpec", resp)
+
+	request := cluster.PlanClusterSpecReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.clusterManager.PlanClusterSpec(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (handler *ClusterServiceHandler) ApplyClusterSpec(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ApplyClusterSpec", int(resp.GetCode()))
+	defer handlePanic(ctx, "ApplyClusterSpec", resp)
+
+	request := cluster.ApplyClusterSpecReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := handler.clusterManager.ApplyClusterSpec(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (handler *ClusterServiceHandler) ScaleOutCluster(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
 	start := time.Now()
 	defer metrics.HandleClusterMetrics(start, "ScaleOutCluster", int(resp.GetCode()))
@@ -690,6 +1219,36 @@ func (c ClusterServiceHandler) StopCluster(ctx context.Context, req *clusterserv
 	return nil
 }
 
+func (c ClusterServiceHandler) HibernateCluster(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) (err error) {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "HibernateCluster", int(resp.GetCode()))
+	defer handlePanic(ctx, "HibernateCluster", resp)
+
+	request := cluster.HibernateClusterReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.clusterManager.HibernateCluster(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) WakeCluster(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) (err error) {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "WakeCluster", int(resp.GetCode()))
+	defer handlePanic(ctx, "WakeCluster", resp)
+
+	request := cluster.WakeClusterReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.clusterManager.WakeCluster(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (c ClusterServiceHandler) DetailCluster(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) (err error) {
 	start := time.Now()
 	defer metrics.HandleClusterMetrics(start, "DetailCluster", int(resp.GetCode()))
@@ -705,6 +1264,22 @@ func (c ClusterServiceHandler) DetailCluster(ctx context.Context, req *clusterse
 	return nil
 }
 
+
+func (c ClusterServiceHandler) GetClusterTopologyGraph(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) (err error) {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "GetClusterTopologyGraph", int(resp.GetCode()))
+	defer handlePanic(ctx, "GetClusterTopologyGraph", resp)
+
+	request := cluster.GetClusterTopologyGraphReq{}
+
+	if handleRequest(ctx, req, resp, &request, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.clusterManager.GetClusterTopologyGraph(framework.NewBackgroundMicroCtx(ctx, false), request)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (c ClusterServiceHandler) ExportData(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
 	start := time.Now()
 	defer metrics.HandleClusterMetrics(start, "ExportData", int(resp.GetCode()))
@@ -844,6 +1419,261 @@ func (c ClusterServiceHandler) CancelBackup(ctx context.Context, req *clusterser
 	return nil
 }
 
+func (c ClusterServiceHandler) RestoreExistCluster(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "RestoreExistCluster", int(resp.GetCode()))
+	defer handlePanic(ctx, "RestoreExistCluster", resp)
+
+	restoreReq := cluster.RestoreExistClusterReq{}
+
+	if handleRequest(ctx, req, resp, &restoreReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.RestoreExistCluster(framework.NewBackgroundMicroCtx(ctx, false), restoreReq, true)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) StartLogBackup(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "StartLogBackup", int(resp.GetCode()))
+	defer handlePanic(ctx, "StartLogBackup", resp)
+
+	startReq := cluster.StartLogBackupReq{}
+
+	if handleRequest(ctx, req, resp, &startReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.StartLogBackup(framework.NewBackgroundMicroCtx(ctx, false), startReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) StopLogBackup(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "StopLogBackup", int(resp.GetCode()))
+	defer handlePanic(ctx, "StopLogBackup", resp)
+
+	stopReq := cluster.StopLogBackupReq{}
+
+	if handleRequest(ctx, req, resp, &stopReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.StopLogBackup(framework.NewBackgroundMicroCtx(ctx, false), stopReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) QueryLogBackup(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryLogBackup", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryLogBackup", resp)
+
+	queryReq := cluster.QueryLogBackupReq{}
+
+	if handleRequest(ctx, req, resp, &queryReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.brManager.QueryLogBackup(framework.NewBackgroundMicroCtx(ctx, false), queryReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) SaveBackupSchedule(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "SaveBackupSchedule", int(resp.GetCode()))
+	defer handlePanic(ctx, "SaveBackupSchedule", resp)
+
+	saveReq := cluster.SaveBackupScheduleReq{}
+
+	if handleRequest(ctx, req, resp, &saveReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.SaveBackupSchedule(framework.NewBackgroundMicroCtx(ctx, false), saveReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) QueryBackupSchedules(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryBackupSchedules", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryBackupSchedules", resp)
+
+	queryReq := cluster.QueryBackupSchedulesReq{}
+
+	if handleRequest(ctx, req, resp, &queryReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.brManager.QueryBackupSchedules(framework.NewBackgroundMicroCtx(ctx, false), queryReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) DeleteBackupSchedule(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DeleteBackupSchedule", int(resp.GetCode()))
+	defer handlePanic(ctx, "DeleteBackupSchedule", resp)
+
+	deleteReq := cluster.DeleteBackupScheduleReq{}
+
+	if handleRequest(ctx, req, resp, &deleteReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.DeleteBackupSchedule(framework.NewBackgroundMicroCtx(ctx, false), deleteReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) RotateBackupKey(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "RotateBackupKey", int(resp.GetCode()))
+	defer handlePanic(ctx, "RotateBackupKey", resp)
+
+	rotateReq := cluster.RotateBackupKeyReq{}
+
+	if handleRequest(ctx, req, resp, &rotateReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.RotateBackupKey(framework.NewBackgroundMicroCtx(ctx, false), rotateReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) QueryBackupKeys(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryBackupKeys", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryBackupKeys", resp)
+
+	queryReq := cluster.QueryBackupKeysReq{}
+
+	if handleRequest(ctx, req, resp, &queryReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.brManager.QueryBackupKeys(framework.NewBackgroundMicroCtx(ctx, false), queryReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) ExportBackupKey(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ExportBackupKey", int(resp.GetCode()))
+	defer handlePanic(ctx, "ExportBackupKey", resp)
+
+	exportReq := cluster.ExportBackupKeyReq{}
+
+	if handleRequest(ctx, req, resp, &exportReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.ExportBackupKey(framework.NewBackgroundMicroCtx(ctx, false), exportReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) ImportBackupKey(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ImportBackupKey", int(resp.GetCode()))
+	defer handlePanic(ctx, "ImportBackupKey", resp)
+
+	importReq := cluster.ImportBackupKeyReq{}
+
+	if handleRequest(ctx, req, resp, &importReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.ImportBackupKey(framework.NewBackgroundMicroCtx(ctx, false), importReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) SaveBackupStorageTarget(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "SaveBackupStorageTarget", int(resp.GetCode()))
+	defer handlePanic(ctx, "SaveBackupStorageTarget", resp)
+
+	saveReq := cluster.SaveBackupStorageTargetReq{}
+
+	if handleRequest(ctx, req, resp, &saveReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceSystem), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.SaveBackupStorageTarget(framework.NewBackgroundMicroCtx(ctx, false), saveReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) QueryBackupStorageTargets(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "QueryBackupStorageTargets", int(resp.GetCode()))
+	defer handlePanic(ctx, "QueryBackupStorageTargets", resp)
+
+	queryReq := cluster.QueryBackupStorageTargetsReq{}
+
+	if handleRequest(ctx, req, resp, &queryReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceSystem), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.brManager.QueryBackupStorageTargets(framework.NewBackgroundMicroCtx(ctx, false), queryReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) DeleteBackupStorageTarget(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "DeleteBackupStorageTarget", int(resp.GetCode()))
+	defer handlePanic(ctx, "DeleteBackupStorageTarget", resp)
+
+	deleteReq := cluster.DeleteBackupStorageTargetReq{}
+
+	if handleRequest(ctx, req, resp, &deleteReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceSystem), Action: string(constants.RbacActionDelete)}}) {
+		result, err := c.brManager.DeleteBackupStorageTarget(framework.NewBackgroundMicroCtx(ctx, false), deleteReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) CopyBackup(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "CopyBackup", int(resp.GetCode()))
+	defer handlePanic(ctx, "CopyBackup", resp)
+
+	copyReq := cluster.CopyBackupReq{}
+
+	if handleRequest(ctx, req, resp, &copyReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionUpdate)}}) {
+		result, err := c.brManager.CopyBackup(framework.NewBackgroundMicroCtx(ctx, false), copyReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) ImportBackup(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "ImportBackup", int(resp.GetCode()))
+	defer handlePanic(ctx, "ImportBackup", resp)
+
+	importReq := cluster.ImportBackupReq{}
+
+	if handleRequest(ctx, req, resp, &importReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionCreate)}}) {
+		result, err := c.brManager.ImportBackup(framework.NewBackgroundMicroCtx(ctx, false), importReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
+func (c ClusterServiceHandler) CheckRestore(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	defer metrics.HandleClusterMetrics(start, "CheckRestore", int(resp.GetCode()))
+	defer handlePanic(ctx, "CheckRestore", resp)
+
+	checkReq := cluster.CheckRestoreReq{}
+
+	if handleRequest(ctx, req, resp, &checkReq, []structs.RbacPermission{{Resource: string(constants.RbacResourceCluster), Action: string(constants.RbacActionRead)}}) {
+		result, err := c.brManager.CheckRestore(framework.NewBackgroundMicroCtx(ctx, false), checkReq)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (c ClusterServiceHandler) DeleteBackupRecords(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
 	start := time.Now()
 	defer metrics.HandleClusterMetrics(start, "DeleteBackupRecord", int(resp.GetCode()))
@@ -1369,6 +2199,22 @@ func (handler *ClusterServiceHandler) GetHierarchy(ctx context.Context, req *clu
 	return nil
 }
 
+func (handler *ClusterServiceHandler) GetHostTopologyGraph(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
+	start := time.Now()
+	metricsFuncName := "GetHostTopologyGraph"
+	defer metrics.HandleClusterMetrics(start, metricsFuncName, int(resp.GetCode()))
+	defer handlePanic(ctx, metricsFuncName, resp)
+
+	reqStruct := cluster.GetHostTopologyGraphReq{}
+
+	if handleRequest(ctx, req, resp, &reqStruct, []structs.RbacPermission{{Resource: string(constants.RbacResourceResource), Action: string(constants.RbacActionRead)}}) {
+		result, err := handler.clusterManager.GetHostTopologyGraph(framework.NewBackgroundMicroCtx(ctx, false), reqStruct)
+		handleResponse(ctx, resp, err, result, nil)
+	}
+
+	return nil
+}
+
 func (handler *ClusterServiceHandler) GetStocks(ctx context.Context, req *clusterservices.RpcRequest, resp *clusterservices.RpcResponse) error {
 	start := time.Now()
 	metricsFuncName := "GetStocks"
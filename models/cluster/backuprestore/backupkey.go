@@ -0,0 +1,31 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"github.com/pingcap/tiunimanager/models/common"
+)
+
+// BackupKey data key of encrypted backups, status is Active for the key encrypting new backups of the cluster,
+// retired keys are kept to restore older backups
+type BackupKey struct {
+	common.Entity
+	ClusterID string `gorm:"not null;type:varchar(22);default:null"`
+	// BR crypter method, aes128-ctr, aes192-ctr or aes256-ctr
+	Method string `gorm:"not null"`
+	// hex data key, it is wrapped by the platform key when stored
+	DataKey common.Password `gorm:"not null"`
+}
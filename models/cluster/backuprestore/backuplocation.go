@@ -0,0 +1,37 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"time"
+
+	"github.com/pingcap/tiunimanager/models/common"
+)
+
+// BackupLocation copy of a backup on a storage target, status is Copying, Available or Failed.
+// The backup record itself is the primary location of the backup
+type BackupLocation struct {
+	common.Entity
+	BackupID    string `gorm:"not null;index;type:varchar(22);default:null"`
+	TargetID    string `gorm:"not null;index;type:varchar(22);default:null"`
+	StorageType string
+	FilePath    string
+	Size        uint64
+	// sha256 over checksums of all files of the copy, equal to the checksum of the source files
+	Checksum   string
+	Message    string
+	FinishTime time.Time
+}
@@ -23,8 +23,9 @@ import (
 // BackupRecord backup record information
 type BackupRecord struct {
 	common.Entity
-	StorageType  string `gorm:"not null"`
-	ClusterID    string `gorm:"not null;type:varchar(22);default:null"`
+	StorageType string `gorm:"not null"`
+	// empty for imported backups not bound to any cluster
+	ClusterID    string `gorm:"type:varchar(22);default:null"`
 	BackupType   string
 	BackupMethod string
 	BackupMode   string
@@ -33,4 +34,23 @@ type BackupRecord struct {
 	BackupTso    uint64
 	StartTime    time.Time
 	EndTime      time.Time
+	// manual backups are purged by retention rules only when expirable, auto backups are always expirable
+	Expirable bool `gorm:"default:false"`
+	// databases or tables covered by the backup, separated by comma, the whole cluster if both are empty
+	Databases string
+	Tables    string
+	// result of restoring the backup into a scratch cluster, empty status if never verified
+	VerifyStatus  string
+	VerifyTime    time.Time
+	VerifyMessage string
+	// progress of the running backup job, refreshed while BR is running
+	Progress         float32
+	ProcessedSize    uint64
+	EstimatedEndTime time.Time
+	// id of the data key encrypting the backup, empty if not encrypted
+	EncryptionKeyID string
+	// storage target of the backup files, the backup storage of system config if empty
+	StorageTargetID string
+	// version of the backed up cluster
+	ClusterVersion string
 }
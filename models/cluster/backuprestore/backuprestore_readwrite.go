@@ -17,6 +17,7 @@ package backuprestore
 
 import (
 	"context"
+	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
 	dbCommon "github.com/pingcap/tiunimanager/models/common"
 	"gorm.io/gorm"
@@ -100,6 +101,49 @@ func (m *BRReadWrite) QueryBackupRecords(ctx context.Context, clusterId, backupI
 	return records, total, err
 }
 
+func (m *BRReadWrite) UpdateBackupVerifyStatus(ctx context.Context, backupId string, status string, message string, verifyTime time.Time) (err error) {
+	if "" == backupId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["verify_status"] = status
+	columnMap["verify_message"] = message
+	columnMap["verify_time"] = verifyTime
+	return m.DB(ctx).Model(&BackupRecord{}).Where("id = ?", backupId).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) UpdateBackupProgress(ctx context.Context, backupId string, progress float32, processedSize uint64, estimatedEndTime time.Time) (err error) {
+	if "" == backupId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["progress"] = progress
+	columnMap["processed_size"] = processedSize
+	columnMap["estimated_end_time"] = estimatedEndTime
+	return m.DB(ctx).Model(&BackupRecord{}).Where("id = ?", backupId).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) QueryBackupRecordsByVerifyStatus(ctx context.Context, clusterId string, verifyStatus string, finishedAfter time.Time) (records []*BackupRecord, err error) {
+	query := m.DB(ctx).Model(&BackupRecord{}).Where("status = ?", constants.ClusterBackupFinished)
+	if verifyStatus == "" {
+		// records created before verification was introduced have null status
+		query = query.Where("verify_status = '' OR verify_status IS NULL")
+	} else {
+		query = query.Where("verify_status = ?", verifyStatus)
+	}
+	if clusterId != "" {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	if !finishedAfter.IsZero() {
+		query = query.Where("end_time >= ?", finishedAfter)
+	}
+	err = query.Order("end_time desc").Find(&records).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return records, nil
+}
+
 func (m *BRReadWrite) DeleteBackupRecord(ctx context.Context, backupId string) (err error) {
 	if "" == backupId {
 		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup id cannot be empty")
@@ -125,6 +169,14 @@ func (m *BRReadWrite) UpdateBackupStrategy(ctx context.Context, strategy *Backup
 	columnMap["backup_date"] = strategy.BackupDate
 	columnMap["start_hour"] = strategy.StartHour
 	columnMap["end_hour"] = strategy.EndHour
+	columnMap["keep_last"] = strategy.KeepLast
+	columnMap["keep_days"] = strategy.KeepDays
+	columnMap["keep_daily"] = strategy.KeepDaily
+	columnMap["keep_weekly"] = strategy.KeepWeekly
+	columnMap["keep_monthly"] = strategy.KeepMonthly
+	columnMap["databases"] = strategy.Databases
+	columnMap["tables"] = strategy.Tables
+	columnMap["copy_target_id"] = strategy.CopyTargetID
 	return m.DB(ctx).Model(strategy).Where("cluster_id = ?", strategy.ClusterID).Updates(columnMap).Error
 }
 
@@ -166,6 +218,16 @@ func (m *BRReadWrite) QueryBackupStrategy(ctx context.Context, weekDay string, s
 	}
 }
 
+func (m *BRReadWrite) QueryRetentionBackupStrategies(ctx context.Context) (strategies []*BackupStrategy, err error) {
+	err = m.DB(ctx).Model(&BackupStrategy{}).
+		Where("keep_last > 0 OR keep_days > 0 OR keep_daily > 0 OR keep_weekly > 0 OR keep_monthly > 0").
+		Find(&strategies).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return strategies, nil
+}
+
 func (m *BRReadWrite) DeleteBackupStrategy(ctx context.Context, clusterId string) (err error) {
 	if "" == clusterId {
 		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster id cannot be empty")
@@ -180,3 +242,267 @@ func (m *BRReadWrite) DeleteBackupStrategy(ctx context.Context, clusterId string
 
 	return m.DB(ctx).First(strategy, "cluster_id = ?", clusterId).Unscoped().Delete(strategy).Error
 }
+
+func (m *BRReadWrite) CreateLogBackupTask(ctx context.Context, task *LogBackupTask) (*LogBackupTask, error) {
+	return task, m.DB(ctx).Create(task).Error
+}
+
+func (m *BRReadWrite) UpdateLogBackupTask(ctx context.Context, task *LogBackupTask) (err error) {
+	if "" == task.ID {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "log backup task id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["status"] = task.Status
+	columnMap["checkpoint_tso"] = task.CheckpointTso
+	columnMap["checkpoint_time"] = task.CheckpointTime
+	columnMap["stop_time"] = task.StopTime
+	columnMap["last_error"] = task.LastError
+	return m.DB(ctx).Model(&LogBackupTask{}).Where("id = ?", task.ID).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) QueryLogBackupTasks(ctx context.Context, clusterId string, status string) (tasks []*LogBackupTask, err error) {
+	query := m.DB(ctx).Model(&LogBackupTask{})
+	if clusterId != "" {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Order("created_at desc").Find(&tasks).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (m *BRReadWrite) CreateBackupSchedule(ctx context.Context, schedule *BackupSchedule) (*BackupSchedule, error) {
+	return schedule, m.DB(ctx).Create(schedule).Error
+}
+
+func (m *BRReadWrite) UpdateBackupSchedule(ctx context.Context, schedule *BackupSchedule) (err error) {
+	if "" == schedule.ID {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup schedule id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["name"] = schedule.Name
+	columnMap["cron_spec"] = schedule.CronSpec
+	columnMap["time_zone"] = schedule.TimeZone
+	columnMap["paused"] = schedule.Paused
+	columnMap["missed_run_policy"] = schedule.MissedRunPolicy
+	columnMap["databases"] = schedule.Databases
+	columnMap["tables"] = schedule.Tables
+	columnMap["last_scheduled_time"] = schedule.LastScheduledTime
+	return m.DB(ctx).Model(&BackupSchedule{}).Where("id = ?", schedule.ID).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) UpdateBackupScheduleRun(ctx context.Context, schedule *BackupSchedule) (err error) {
+	if "" == schedule.ID {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup schedule id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["last_scheduled_time"] = schedule.LastScheduledTime
+	columnMap["missed_runs"] = schedule.MissedRuns
+	columnMap["last_missed_time"] = schedule.LastMissedTime
+	return m.DB(ctx).Model(&BackupSchedule{}).Where("id = ?", schedule.ID).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) UpdateBackupScheduleLastBackup(ctx context.Context, scheduleId string, backupId string) (err error) {
+	if "" == scheduleId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup schedule id cannot be empty")
+	}
+	return m.DB(ctx).Model(&BackupSchedule{}).Where("id = ?", scheduleId).Update("last_backup_id", backupId).Error
+}
+
+func (m *BRReadWrite) GetBackupSchedule(ctx context.Context, scheduleId string) (schedule *BackupSchedule, err error) {
+	if "" == scheduleId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup schedule id cannot be empty")
+	}
+	schedule = &BackupSchedule{}
+	err = m.DB(ctx).First(schedule, "id = ?", scheduleId).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (m *BRReadWrite) QueryBackupSchedules(ctx context.Context, clusterId string) (schedules []*BackupSchedule, err error) {
+	query := m.DB(ctx).Model(&BackupSchedule{})
+	if clusterId != "" {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	err = query.Order("created_at").Find(&schedules).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (m *BRReadWrite) DeleteBackupSchedules(ctx context.Context, clusterId string, scheduleId string) (err error) {
+	if "" == clusterId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster id cannot be empty")
+	}
+	query := m.DB(ctx).Where("cluster_id = ?", clusterId)
+	if scheduleId != "" {
+		query = query.Where("id = ?", scheduleId)
+	}
+	return query.Unscoped().Delete(&BackupSchedule{}).Error
+}
+
+func (m *BRReadWrite) CountBackupRecordsByStatus(ctx context.Context, status string) (count int64, err error) {
+	err = m.DB(ctx).Model(&BackupRecord{}).Where("status = ?", status).Count(&count).Error
+	return count, err
+}
+
+func (m *BRReadWrite) CreateBackupKey(ctx context.Context, key *BackupKey) (*BackupKey, error) {
+	return key, m.DB(ctx).Create(key).Error
+}
+
+func (m *BRReadWrite) RotateBackupKey(ctx context.Context, key *BackupKey) (*BackupKey, error) {
+	if "" == key.ClusterID {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster id cannot be empty")
+	}
+	err := m.DB(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&BackupKey{}).Where("cluster_id = ? AND status = ?", key.ClusterID, string(constants.BackupKeyActive)).
+			Update("status", string(constants.BackupKeyRetired)).Error
+		if err != nil {
+			return err
+		}
+		key.Status = string(constants.BackupKeyActive)
+		return tx.Create(key).Error
+	})
+	return key, err
+}
+
+func (m *BRReadWrite) GetBackupKey(ctx context.Context, keyId string) (*BackupKey, error) {
+	if "" == keyId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup key id cannot be empty")
+	}
+	key := &BackupKey{}
+	err := m.DB(ctx).First(key, "id = ?", keyId).Error
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (m *BRReadWrite) QueryBackupKeys(ctx context.Context, clusterId string, status string) (keys []*BackupKey, err error) {
+	query := m.DB(ctx).Model(&BackupKey{}).Where("cluster_id = ?", clusterId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err = query.Order("created_at desc").Find(&keys).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (m *BRReadWrite) CreateBackupStorageTarget(ctx context.Context, target *BackupStorageTarget) (*BackupStorageTarget, error) {
+	return target, m.DB(ctx).Create(target).Error
+}
+
+func (m *BRReadWrite) UpdateBackupStorageTarget(ctx context.Context, target *BackupStorageTarget) (err error) {
+	if "" == target.ID {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup storage target id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["name"] = target.Name
+	columnMap["storage_type"] = target.StorageType
+	columnMap["file_path"] = target.FilePath
+	columnMap["endpoint"] = target.Endpoint
+	columnMap["access_key"] = target.AccessKey
+	columnMap["secret_access_key"] = target.SecretAccessKey
+	return m.DB(ctx).Model(&BackupStorageTarget{}).Where("id = ?", target.ID).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) GetBackupStorageTarget(ctx context.Context, targetId string) (target *BackupStorageTarget, err error) {
+	if "" == targetId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup storage target id cannot be empty")
+	}
+	target = &BackupStorageTarget{}
+	err = m.DB(ctx).First(target, "id = ?", targetId).Error
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+func (m *BRReadWrite) QueryBackupStorageTargets(ctx context.Context) (targets []*BackupStorageTarget, err error) {
+	err = m.DB(ctx).Model(&BackupStorageTarget{}).Order("name").Find(&targets).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return targets, nil
+}
+
+func (m *BRReadWrite) DeleteBackupStorageTarget(ctx context.Context, targetId string) (err error) {
+	if "" == targetId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup storage target id cannot be empty")
+	}
+	return m.DB(ctx).Where("id = ?", targetId).Unscoped().Delete(&BackupStorageTarget{}).Error
+}
+
+func (m *BRReadWrite) CountBackupStorageTargetUsage(ctx context.Context, targetId string) (count int64, err error) {
+	var strategies, locations, records int64
+	err = m.DB(ctx).Model(&BackupStrategy{}).Where("copy_target_id = ?", targetId).Count(&strategies).Error
+	if err != nil {
+		return 0, err
+	}
+	err = m.DB(ctx).Model(&BackupLocation{}).Where("target_id = ?", targetId).Count(&locations).Error
+	if err != nil {
+		return 0, err
+	}
+	err = m.DB(ctx).Model(&BackupRecord{}).Where("storage_target_id = ?", targetId).Count(&records).Error
+	return strategies + locations + records, err
+}
+
+func (m *BRReadWrite) CreateBackupLocation(ctx context.Context, location *BackupLocation) (*BackupLocation, error) {
+	return location, m.DB(ctx).Create(location).Error
+}
+
+func (m *BRReadWrite) UpdateBackupLocation(ctx context.Context, location *BackupLocation) (err error) {
+	if "" == location.ID {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup location id cannot be empty")
+	}
+	columnMap := make(map[string]interface{})
+	columnMap["status"] = location.Status
+	columnMap["size"] = location.Size
+	columnMap["checksum"] = location.Checksum
+	columnMap["message"] = location.Message
+	columnMap["finish_time"] = location.FinishTime
+	return m.DB(ctx).Model(&BackupLocation{}).Where("id = ?", location.ID).Updates(columnMap).Error
+}
+
+func (m *BRReadWrite) GetBackupLocation(ctx context.Context, locationId string) (location *BackupLocation, err error) {
+	if "" == locationId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup location id cannot be empty")
+	}
+	location = &BackupLocation{}
+	err = m.DB(ctx).First(location, "id = ?", locationId).Error
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (m *BRReadWrite) QueryBackupLocations(ctx context.Context, backupIds []string) (locations []*BackupLocation, err error) {
+	if len(backupIds) == 0 {
+		return locations, nil
+	}
+	err = m.DB(ctx).Model(&BackupLocation{}).Where("backup_id IN ?", backupIds).Order("created_at").Find(&locations).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func (m *BRReadWrite) DeleteBackupLocations(ctx context.Context, backupId string, locationId string) (err error) {
+	if "" == backupId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "backup id cannot be empty")
+	}
+	query := m.DB(ctx).Where("backup_id = ?", backupId)
+	if locationId != "" {
+		query = query.Where("id = ?", locationId)
+	}
+	return query.Unscoped().Delete(&BackupLocation{}).Error
+}
@@ -17,6 +17,7 @@ package backuprestore
 
 import (
 	"context"
+	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/models/common"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -132,6 +133,81 @@ func TestBRReadWrite_QueryBackupRecords(t *testing.T) {
 	assert.Equal(t, recordCreate.BackupMode, recordQuery[0].BackupMode)
 }
 
+func TestBRReadWrite_BackupVerifyStatus(t *testing.T) {
+	record := &BackupRecord{
+		Entity: common.Entity{
+			TenantId: "tenantId",
+			Status:   string(constants.ClusterBackupFinished),
+		},
+		ClusterID:    "verifyClusterId",
+		FilePath:     "/tmp/test",
+		StorageType:  "s3",
+		BackupType:   "full",
+		BackupMethod: "physics",
+		BackupMode:   "auto",
+		BackupTso:    42353454343234,
+		StartTime:    time.Now().Add(-time.Hour),
+		EndTime:      time.Now(),
+	}
+	recordCreate, errCreate := rw.CreateBackupRecord(context.TODO(), record)
+	assert.NoError(t, errCreate)
+
+	records, err := rw.QueryBackupRecordsByVerifyStatus(context.TODO(), "verifyClusterId", "", time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, recordCreate.ID, records[0].ID)
+
+	records, err = rw.QueryBackupRecordsByVerifyStatus(context.TODO(), "verifyClusterId", "", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(records))
+
+	verifyTime := time.Now()
+	err = rw.UpdateBackupVerifyStatus(context.TODO(), recordCreate.ID, string(constants.BackupVerifyFailed), "row count mismatch", verifyTime)
+	assert.NoError(t, err)
+	err = rw.UpdateBackupVerifyStatus(context.TODO(), "", string(constants.BackupVerifyFailed), "", verifyTime)
+	assert.Error(t, err)
+
+	records, err = rw.QueryBackupRecordsByVerifyStatus(context.TODO(), "verifyClusterId", "", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(records))
+	records, err = rw.QueryBackupRecordsByVerifyStatus(context.TODO(), "verifyClusterId", string(constants.BackupVerifyFailed), time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "row count mismatch", records[0].VerifyMessage)
+	assert.True(t, records[0].VerifyTime.Equal(verifyTime))
+}
+
+func TestBRReadWrite_UpdateBackupProgress(t *testing.T) {
+	record := &BackupRecord{
+		Entity: common.Entity{
+			TenantId: "tenantId",
+			Status:   string(constants.ClusterBackupProcessing),
+		},
+		ClusterID:    "progressClusterId",
+		FilePath:     "/tmp/test",
+		StorageType:  "nfs",
+		BackupType:   "full",
+		BackupMethod: "physics",
+		BackupMode:   "manual",
+		StartTime:    time.Now(),
+		EndTime:      time.Now(),
+	}
+	recordCreate, errCreate := rw.CreateBackupRecord(context.TODO(), record)
+	assert.NoError(t, errCreate)
+
+	estimatedEndTime := time.Now().Add(time.Hour)
+	err := rw.UpdateBackupProgress(context.TODO(), recordCreate.ID, 35.5, 1024, estimatedEndTime)
+	assert.NoError(t, err)
+	err = rw.UpdateBackupProgress(context.TODO(), "", 35.5, 1024, estimatedEndTime)
+	assert.Error(t, err)
+
+	recordGet, err := rw.GetBackupRecord(context.TODO(), recordCreate.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(35.5), recordGet.Progress)
+	assert.Equal(t, uint64(1024), recordGet.ProcessedSize)
+	assert.True(t, recordGet.EstimatedEndTime.Equal(estimatedEndTime))
+}
+
 func TestBRReadWrite_DeleteBackupRecord(t *testing.T) {
 	record := &BackupRecord{
 		Entity: common.Entity{
@@ -253,12 +329,14 @@ func TestBRReadWrite_GetBackupStrategy(t *testing.T) {
 	assert.NoError(t, errCreate)
 
 	strategyCreate.BackupDate = "Friday"
+	strategyCreate.Databases = "db1,db2"
 	errUpdate := rw.UpdateBackupStrategy(context.TODO(), strategyCreate)
 	assert.NoError(t, errUpdate)
 
 	strategyGet, errGet := rw.GetBackupStrategy(context.TODO(), strategyCreate.ClusterID)
 	assert.NoError(t, errGet)
 	assert.Equal(t, strategyCreate.BackupDate, strategyGet.BackupDate)
+	assert.Equal(t, "db1,db2", strategyGet.Databases)
 }
 
 func TestBRReadWrite_QueryBackupStrategy(t *testing.T) {
@@ -280,6 +358,44 @@ func TestBRReadWrite_QueryBackupStrategy(t *testing.T) {
 	assert.Equal(t, strategyCreate.BackupDate, strategyQuery[0].BackupDate)
 }
 
+func TestBRReadWrite_QueryRetentionBackupStrategies(t *testing.T) {
+	_, err := rw.CreateBackupStrategy(context.TODO(), &BackupStrategy{
+		Entity:     common.Entity{TenantId: "tenantId"},
+		ClusterID:  "clusterIdNoRetention",
+		BackupDate: "Monday",
+		StartHour:  11,
+		EndHour:    12,
+	})
+	assert.NoError(t, err)
+	_, err = rw.CreateBackupStrategy(context.TODO(), &BackupStrategy{
+		Entity:     common.Entity{TenantId: "tenantId"},
+		ClusterID:  "clusterIdRetention",
+		BackupDate: "Monday",
+		StartHour:  11,
+		EndHour:    12,
+		KeepDaily:  7,
+	})
+	assert.NoError(t, err)
+
+	strategies, err := rw.QueryRetentionBackupStrategies(context.TODO())
+	assert.NoError(t, err)
+	clusterIDs := make([]string, 0)
+	for _, strategy := range strategies {
+		assert.True(t, strategy.RetentionEnabled())
+		clusterIDs = append(clusterIDs, strategy.ClusterID)
+	}
+	assert.Contains(t, clusterIDs, "clusterIdRetention")
+	assert.NotContains(t, clusterIDs, "clusterIdNoRetention")
+
+	err = rw.UpdateBackupStrategy(context.TODO(), &BackupStrategy{ClusterID: "clusterIdRetention", BackupDate: "Monday", StartHour: 11, EndHour: 12})
+	assert.NoError(t, err)
+	strategies, err = rw.QueryRetentionBackupStrategies(context.TODO())
+	assert.NoError(t, err)
+	for _, strategy := range strategies {
+		assert.NotEqual(t, "clusterIdRetention", strategy.ClusterID)
+	}
+}
+
 func TestBRReadWrite_DeleteBackupStrategy(t *testing.T) {
 	strategy := &BackupStrategy{
 		Entity: common.Entity{
@@ -301,3 +417,259 @@ func TestBRReadWrite_DeleteBackupStrategy(t *testing.T) {
 	assert.Nil(t, errGet)
 	assert.Equal(t, "", strategyGet.ID)
 }
+
+func TestBRReadWrite_LogBackupTask(t *testing.T) {
+	task, err := rw.CreateLogBackupTask(context.TODO(), &LogBackupTask{
+		Entity: common.Entity{
+			TenantId: "tenantId",
+			Status:   "Running",
+		},
+		ClusterID:   "clusterIdLog",
+		TaskName:    "taskName",
+		StorageType: "nfs",
+		FilePath:    "/tmp/log",
+		StartTso:    100,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, task.ID)
+
+	tasks, err := rw.QueryLogBackupTasks(context.TODO(), "clusterIdLog", "Running")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, uint64(100), tasks[0].StartTso)
+
+	task.Status = "Stopped"
+	task.CheckpointTso = 200
+	task.LastError = "error"
+	err = rw.UpdateLogBackupTask(context.TODO(), task)
+	assert.NoError(t, err)
+	err = rw.UpdateLogBackupTask(context.TODO(), &LogBackupTask{})
+	assert.Error(t, err)
+
+	tasks, err = rw.QueryLogBackupTasks(context.TODO(), "clusterIdLog", "Running")
+	assert.NoError(t, err)
+	assert.Empty(t, tasks)
+	tasks, err = rw.QueryLogBackupTasks(context.TODO(), "clusterIdLog", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "Stopped", tasks[0].Status)
+	assert.Equal(t, uint64(200), tasks[0].CheckpointTso)
+	assert.Equal(t, "error", tasks[0].LastError)
+}
+
+func TestBRReadWrite_BackupSchedule(t *testing.T) {
+	schedule, err := rw.CreateBackupSchedule(context.TODO(), &BackupSchedule{
+		Entity: common.Entity{
+			TenantId: "tenantId",
+		},
+		ClusterID:       "clusterIdSchedule",
+		Name:            "daily",
+		CronSpec:        "0 2 * * *",
+		TimeZone:        "Asia/Shanghai",
+		MissedRunPolicy: "Run",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, schedule.ID)
+	_, err = rw.CreateBackupSchedule(context.TODO(), &BackupSchedule{
+		Entity:    common.Entity{TenantId: "tenantId"},
+		ClusterID: "clusterIdSchedule",
+		Name:      "weekly",
+		CronSpec:  "@weekly",
+	})
+	assert.NoError(t, err)
+
+	schedules, err := rw.QueryBackupSchedules(context.TODO(), "clusterIdSchedule")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(schedules))
+	schedules, err = rw.QueryBackupSchedules(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(schedules), 2)
+
+	lastScheduledTime := time.Now()
+	schedule.CronSpec = "30 3 * * *"
+	schedule.Paused = true
+	schedule.Databases = "db1"
+	schedule.LastScheduledTime = lastScheduledTime
+	assert.NoError(t, rw.UpdateBackupSchedule(context.TODO(), schedule))
+	assert.Error(t, rw.UpdateBackupSchedule(context.TODO(), &BackupSchedule{}))
+
+	schedule.MissedRuns = 2
+	schedule.LastMissedTime = lastScheduledTime
+	assert.NoError(t, rw.UpdateBackupScheduleRun(context.TODO(), schedule))
+	assert.Error(t, rw.UpdateBackupScheduleRun(context.TODO(), &BackupSchedule{}))
+	assert.NoError(t, rw.UpdateBackupScheduleLastBackup(context.TODO(), schedule.ID, "backup01"))
+	assert.Error(t, rw.UpdateBackupScheduleLastBackup(context.TODO(), "", "backup01"))
+
+	scheduleGet, err := rw.GetBackupSchedule(context.TODO(), schedule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "30 3 * * *", scheduleGet.CronSpec)
+	assert.True(t, scheduleGet.Paused)
+	assert.Equal(t, "db1", scheduleGet.Databases)
+	assert.Equal(t, "backup01", scheduleGet.LastBackupID)
+	assert.Equal(t, uint32(2), scheduleGet.MissedRuns)
+	assert.True(t, scheduleGet.LastScheduledTime.Equal(lastScheduledTime))
+	_, err = rw.GetBackupSchedule(context.TODO(), "")
+	assert.Error(t, err)
+
+	assert.NoError(t, rw.DeleteBackupSchedules(context.TODO(), "clusterIdSchedule", schedule.ID))
+	_, err = rw.GetBackupSchedule(context.TODO(), schedule.ID)
+	assert.Error(t, err)
+	assert.NoError(t, rw.DeleteBackupSchedules(context.TODO(), "clusterIdSchedule", ""))
+	schedules, err = rw.QueryBackupSchedules(context.TODO(), "clusterIdSchedule")
+	assert.NoError(t, err)
+	assert.Empty(t, schedules)
+	assert.Error(t, rw.DeleteBackupSchedules(context.TODO(), "", ""))
+}
+
+func TestBRReadWrite_CountBackupRecordsByStatus(t *testing.T) {
+	count, err := rw.CountBackupRecordsByStatus(context.TODO(), "CountStatus")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	_, err = rw.CreateBackupRecord(context.TODO(), &BackupRecord{
+		Entity:    common.Entity{TenantId: "tenantId", Status: "CountStatus"},
+		ClusterID: "countClusterId",
+		FilePath:  "/tmp/count",
+	})
+	assert.NoError(t, err)
+	count, err = rw.CountBackupRecordsByStatus(context.TODO(), "CountStatus")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestBRReadWrite_BackupKey(t *testing.T) {
+	key, err := rw.CreateBackupKey(context.TODO(), &BackupKey{
+		Entity:    common.Entity{TenantId: "tenantId", Status: string(constants.BackupKeyActive)},
+		ClusterID: "clusterIdKey",
+		Method:    "aes256-ctr",
+		DataKey:   "0123456789abcdef",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, key.ID)
+
+	keyGet, err := rw.GetBackupKey(context.TODO(), key.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Password("0123456789abcdef"), keyGet.DataKey)
+	_, err = rw.GetBackupKey(context.TODO(), "")
+	assert.Error(t, err)
+
+	rotated, err := rw.RotateBackupKey(context.TODO(), &BackupKey{
+		Entity:    common.Entity{TenantId: "tenantId"},
+		ClusterID: "clusterIdKey",
+		Method:    "aes128-ctr",
+		DataKey:   "fedcba9876543210",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, string(constants.BackupKeyActive), rotated.Status)
+	_, err = rw.RotateBackupKey(context.TODO(), &BackupKey{})
+	assert.Error(t, err)
+
+	keys, err := rw.QueryBackupKeys(context.TODO(), "clusterIdKey", string(constants.BackupKeyActive))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(keys))
+	assert.Equal(t, rotated.ID, keys[0].ID)
+	keys, err = rw.QueryBackupKeys(context.TODO(), "clusterIdKey", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(keys))
+	keyGet, err = rw.GetBackupKey(context.TODO(), key.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, string(constants.BackupKeyRetired), keyGet.Status)
+}
+
+func TestBRReadWrite_BackupStorageTarget(t *testing.T) {
+	target, err := rw.CreateBackupStorageTarget(context.TODO(), &BackupStorageTarget{
+		Entity:          common.Entity{TenantId: "tenantId"},
+		Name:            "offsite",
+		StorageType:     "s3",
+		FilePath:        "bucket/prefix",
+		Endpoint:        "http://127.0.0.1:9000",
+		AccessKey:       "ak",
+		SecretAccessKey: "sk",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, target.ID)
+	_, err = rw.CreateBackupStorageTarget(context.TODO(), &BackupStorageTarget{Name: "offsite", StorageType: "nfs", FilePath: "/tmp"})
+	assert.Error(t, err)
+
+	target.FilePath = "bucket/another"
+	target.SecretAccessKey = "sk2"
+	assert.NoError(t, rw.UpdateBackupStorageTarget(context.TODO(), target))
+	assert.Error(t, rw.UpdateBackupStorageTarget(context.TODO(), &BackupStorageTarget{}))
+	targetGet, err := rw.GetBackupStorageTarget(context.TODO(), target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket/another", targetGet.FilePath)
+	assert.Equal(t, common.Password("sk2"), targetGet.SecretAccessKey)
+
+	targets, err := rw.QueryBackupStorageTargets(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(targets))
+
+	count, err := rw.CountBackupStorageTargetUsage(context.TODO(), target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	_, err = rw.CreateBackupStrategy(context.TODO(), &BackupStrategy{Entity: common.Entity{TenantId: "tenantId"}, ClusterID: "clusterIdTarget", CopyTargetID: target.ID})
+	assert.NoError(t, err)
+	count, err = rw.CountBackupStorageTargetUsage(context.TODO(), target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = rw.CreateBackupRecord(context.TODO(), &BackupRecord{Entity: common.Entity{TenantId: "tenantId"}, StorageType: "s3", StorageTargetID: target.ID})
+	assert.NoError(t, err)
+	count, err = rw.CountBackupStorageTargetUsage(context.TODO(), target.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	assert.NoError(t, rw.DeleteBackupStorageTarget(context.TODO(), target.ID))
+	_, err = rw.GetBackupStorageTarget(context.TODO(), target.ID)
+	assert.Error(t, err)
+	assert.Error(t, rw.DeleteBackupStorageTarget(context.TODO(), ""))
+}
+
+func TestBRReadWrite_BackupLocation(t *testing.T) {
+	location, err := rw.CreateBackupLocation(context.TODO(), &BackupLocation{
+		Entity:      common.Entity{TenantId: "tenantId", Status: string(constants.BackupLocationCopying)},
+		BackupID:    "backupIdLocation",
+		TargetID:    "targetIdLocation",
+		StorageType: "nfs",
+		FilePath:    "/tmp/copy",
+	})
+	assert.NoError(t, err)
+	_, err = rw.CreateBackupLocation(context.TODO(), &BackupLocation{
+		Entity:   common.Entity{TenantId: "tenantId", Status: string(constants.BackupLocationFailed)},
+		BackupID: "backupIdLocation",
+		TargetID: "targetIdLocation2",
+	})
+	assert.NoError(t, err)
+
+	location.Status = string(constants.BackupLocationAvailable)
+	location.Size = 1024
+	location.Checksum = "checksum"
+	location.FinishTime = time.Now()
+	assert.NoError(t, rw.UpdateBackupLocation(context.TODO(), location))
+	assert.Error(t, rw.UpdateBackupLocation(context.TODO(), &BackupLocation{}))
+	locationGet, err := rw.GetBackupLocation(context.TODO(), location.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, string(constants.BackupLocationAvailable), locationGet.Status)
+	assert.Equal(t, uint64(1024), locationGet.Size)
+	assert.Equal(t, "checksum", locationGet.Checksum)
+
+	count, err := rw.CountBackupStorageTargetUsage(context.TODO(), "targetIdLocation")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	locations, err := rw.QueryBackupLocations(context.TODO(), []string{"backupIdLocation", "otherBackupId"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(locations))
+	locations, err = rw.QueryBackupLocations(context.TODO(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(locations))
+
+	assert.NoError(t, rw.DeleteBackupLocations(context.TODO(), "backupIdLocation", location.ID))
+	locations, err = rw.QueryBackupLocations(context.TODO(), []string{"backupIdLocation"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(locations))
+	assert.NoError(t, rw.DeleteBackupLocations(context.TODO(), "backupIdLocation", ""))
+	locations, err = rw.QueryBackupLocations(context.TODO(), []string{"backupIdLocation"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(locations))
+	assert.Error(t, rw.DeleteBackupLocations(context.TODO(), "", ""))
+}
@@ -0,0 +1,42 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"time"
+
+	"github.com/pingcap/tiunimanager/models/common"
+)
+
+// BackupSchedule cron schedule of auto backups, a cluster may have several schedules
+type BackupSchedule struct {
+	common.Entity
+	ClusterID string `gorm:"not null;type:varchar(22);default:null"`
+	Name      string
+	// standard 5-field cron expression or descriptor such as @daily, evaluated in TimeZone
+	CronSpec        string `gorm:"not null"`
+	TimeZone        string
+	Paused          bool `gorm:"default:false"`
+	MissedRunPolicy string
+	// databases or tables to backup, separated by comma, the whole cluster if both are empty
+	Databases string
+	Tables    string
+	// LastScheduledTime the latest scheduled time handled, runs after it are due
+	LastScheduledTime time.Time
+	LastBackupID      string
+	MissedRuns        uint32 `gorm:"default:0"`
+	LastMissedTime    time.Time
+}
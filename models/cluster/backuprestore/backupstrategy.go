@@ -24,4 +24,23 @@ type BackupStrategy struct {
 	BackupDate string `gorm:"default:null"`
 	StartHour  uint32
 	EndHour    uint32
+	// retention rules of backups, a backup is kept if any rule keeps it, all zero means keep all backups
+	KeepLast    uint32 `gorm:"default:0"`
+	KeepDays    uint32 `gorm:"default:0"`
+	KeepDaily   uint32 `gorm:"default:0"`
+	KeepWeekly  uint32 `gorm:"default:0"`
+	KeepMonthly uint32 `gorm:"default:0"`
+	// databases or tables to backup, separated by comma, the whole cluster if both are empty
+	Databases string
+	Tables    string
+	// storage target which finished backups are copied to, not copied if empty
+	CopyTargetID string
+}
+
+// RetentionEnabled
+// @Description: whether any retention rule is set on the strategy
+// @Receiver s
+// @return bool
+func (s *BackupStrategy) RetentionEnabled() bool {
+	return s.KeepLast > 0 || s.KeepDays > 0 || s.KeepDaily > 0 || s.KeepWeekly > 0 || s.KeepMonthly > 0
 }
@@ -0,0 +1,36 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"time"
+
+	"github.com/pingcap/tiunimanager/models/common"
+)
+
+// LogBackupTask continuous log backup task of cluster, logs between StartTso and CheckpointTso are backed up
+type LogBackupTask struct {
+	common.Entity
+	ClusterID      string `gorm:"not null;type:varchar(22);default:null"`
+	TaskName       string `gorm:"not null"`
+	StorageType    string `gorm:"not null"`
+	FilePath       string
+	StartTso       uint64
+	CheckpointTso  uint64
+	CheckpointTime time.Time
+	StopTime       time.Time
+	LastError      string
+}
@@ -48,6 +48,11 @@ func TestMain(m *testing.M) {
 			}
 			db.Migrator().CreateTable(BackupRecord{})
 			db.Migrator().CreateTable(BackupStrategy{})
+			db.Migrator().CreateTable(LogBackupTask{})
+			db.Migrator().CreateTable(BackupSchedule{})
+			db.Migrator().CreateTable(BackupKey{})
+			db.Migrator().CreateTable(BackupStorageTarget{})
+			db.Migrator().CreateTable(BackupLocation{})
 
 			rw = NewBRReadWrite(db)
 			return nil
@@ -67,6 +67,39 @@ type ReaderWriter interface {
 	// @Return error
 	QueryBackupRecords(ctx context.Context, clusterId, backupId, backupMode string, startTime, endTime int64, page int, pageSize int) (records []*BackupRecord, total int64, err error)
 
+	// UpdateBackupVerifyStatus
+	// @Description: update verification status of backup record
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter backupId
+	// @Parameter status
+	// @Parameter message
+	// @Parameter verifyTime
+	// @Return error
+	UpdateBackupVerifyStatus(ctx context.Context, backupId string, status string, message string, verifyTime time.Time) (err error)
+
+	// UpdateBackupProgress
+	// @Description: update progress of running backup
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter backupId
+	// @Parameter progress percent complete
+	// @Parameter processedSize bytes written to storage
+	// @Parameter estimatedEndTime
+	// @Return error
+	UpdateBackupProgress(ctx context.Context, backupId string, progress float32, processedSize uint64, estimatedEndTime time.Time) (err error)
+
+	// QueryBackupRecordsByVerifyStatus
+	// @Description: query finished backup records by verification status, latest finished first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId optional
+	// @Parameter verifyStatus empty for backups never verified
+	// @Parameter finishedAfter optional
+	// @Return []*BackupRecord
+	// @Return error
+	QueryBackupRecordsByVerifyStatus(ctx context.Context, clusterId string, verifyStatus string, finishedAfter time.Time) (records []*BackupRecord, err error)
+
 	// DeleteBackupRecord
 	// @Description: delete backup record by Id
 	// @Receiver m
@@ -128,6 +161,14 @@ type ReaderWriter interface {
 	// @Return error
 	QueryBackupStrategy(ctx context.Context, weekDay string, startHour uint32) (strategies []*BackupStrategy, err error)
 
+	// QueryRetentionBackupStrategies
+	// @Description: query backup strategies with any retention rule set
+	// @Receiver m
+	// @Parameter ctx
+	// @Return []*BackupStrategy
+	// @Return error
+	QueryRetentionBackupStrategies(ctx context.Context) (strategies []*BackupStrategy, err error)
+
 	// DeleteBackupStrategy
 	// @Description: delete backup strategy by clusterId
 	// @Receiver m
@@ -135,4 +176,233 @@ type ReaderWriter interface {
 	// @Parameter clusterId
 	// @Return error
 	DeleteBackupStrategy(ctx context.Context, clusterId string) (err error)
+
+	// CreateLogBackupTask
+	// @Description: create new log backup task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter task
+	// @Return *LogBackupTask
+	// @Return error
+	CreateLogBackupTask(ctx context.Context, task *LogBackupTask) (*LogBackupTask, error)
+
+	// UpdateLogBackupTask
+	// @Description: update status, checkpoint, stop time and last error of log backup task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter task
+	// @Return error
+	UpdateLogBackupTask(ctx context.Context, task *LogBackupTask) (err error)
+
+	// QueryLogBackupTasks
+	// @Description: query log backup tasks by clusterId and status, newest first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter status
+	// @Return []*LogBackupTask
+	// @Return error
+	QueryLogBackupTasks(ctx context.Context, clusterId string, status string) (tasks []*LogBackupTask, err error)
+
+	// CreateBackupSchedule
+	// @Description: create new backup schedule
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter schedule
+	// @Return *BackupSchedule
+	// @Return error
+	CreateBackupSchedule(ctx context.Context, schedule *BackupSchedule) (*BackupSchedule, error)
+
+	// UpdateBackupSchedule
+	// @Description: update settings of backup schedule, LastScheduledTime is updated too as the schedule restarts from it
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter schedule
+	// @Return error
+	UpdateBackupSchedule(ctx context.Context, schedule *BackupSchedule) (err error)
+
+	// UpdateBackupScheduleRun
+	// @Description: update last scheduled time and missed runs of backup schedule
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter schedule
+	// @Return error
+	UpdateBackupScheduleRun(ctx context.Context, schedule *BackupSchedule) (err error)
+
+	// UpdateBackupScheduleLastBackup
+	// @Description: update the latest backup started by backup schedule
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter scheduleId
+	// @Parameter backupId
+	// @Return error
+	UpdateBackupScheduleLastBackup(ctx context.Context, scheduleId string, backupId string) (err error)
+
+	// GetBackupSchedule
+	// @Description: get backup schedule by id
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter scheduleId
+	// @Return *BackupSchedule
+	// @Return error
+	GetBackupSchedule(ctx context.Context, scheduleId string) (schedule *BackupSchedule, err error)
+
+	// QueryBackupSchedules
+	// @Description: query backup schedules of cluster, schedules of all clusters if clusterId is empty
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Return []*BackupSchedule
+	// @Return error
+	QueryBackupSchedules(ctx context.Context, clusterId string) (schedules []*BackupSchedule, err error)
+
+	// DeleteBackupSchedules
+	// @Description: delete backup schedule by id, or all schedules of cluster if scheduleId is empty
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter scheduleId
+	// @Return error
+	DeleteBackupSchedules(ctx context.Context, clusterId string, scheduleId string) (err error)
+
+	// CountBackupRecordsByStatus
+	// @Description: count backup records of all clusters by status
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter status
+	// @Return int64
+	// @Return error
+	CountBackupRecordsByStatus(ctx context.Context, status string) (count int64, err error)
+
+	// CreateBackupKey
+	// @Description: create data key of encrypted backups
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter key
+	// @Return *BackupKey
+	// @Return error
+	CreateBackupKey(ctx context.Context, key *BackupKey) (*BackupKey, error)
+
+	// RotateBackupKey
+	// @Description: retire active keys of the cluster and create the new active key in one transaction
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter key
+	// @Return *BackupKey
+	// @Return error
+	RotateBackupKey(ctx context.Context, key *BackupKey) (*BackupKey, error)
+
+	// GetBackupKey
+	// @Description: get data key of encrypted backups by id
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter keyId
+	// @Return *BackupKey
+	// @Return error
+	GetBackupKey(ctx context.Context, keyId string) (*BackupKey, error)
+
+	// QueryBackupKeys
+	// @Description: query data keys of cluster, newest first, keys of all status if status is empty
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter status
+	// @Return []*BackupKey
+	// @Return error
+	QueryBackupKeys(ctx context.Context, clusterId string, status string) ([]*BackupKey, error)
+
+	// CreateBackupStorageTarget
+	// @Description: create storage target of backup copies
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter target
+	// @Return *BackupStorageTarget
+	// @Return error
+	CreateBackupStorageTarget(ctx context.Context, target *BackupStorageTarget) (*BackupStorageTarget, error)
+
+	// UpdateBackupStorageTarget
+	// @Description: update storage target of backup copies
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter target
+	// @Return error
+	UpdateBackupStorageTarget(ctx context.Context, target *BackupStorageTarget) (err error)
+
+	// GetBackupStorageTarget
+	// @Description: get storage target of backup copies by id
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter targetId
+	// @Return *BackupStorageTarget
+	// @Return error
+	GetBackupStorageTarget(ctx context.Context, targetId string) (*BackupStorageTarget, error)
+
+	// QueryBackupStorageTargets
+	// @Description: query all storage targets of backup copies, ordered by name
+	// @Receiver m
+	// @Parameter ctx
+	// @Return []*BackupStorageTarget
+	// @Return error
+	QueryBackupStorageTargets(ctx context.Context) ([]*BackupStorageTarget, error)
+
+	// DeleteBackupStorageTarget
+	// @Description: delete storage target of backup copies
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter targetId
+	// @Return error
+	DeleteBackupStorageTarget(ctx context.Context, targetId string) (err error)
+
+	// CountBackupStorageTargetUsage
+	// @Description: count backup strategies, backup locations and backup records referring to the storage target
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter targetId
+	// @Return int64
+	// @Return error
+	CountBackupStorageTargetUsage(ctx context.Context, targetId string) (count int64, err error)
+
+	// CreateBackupLocation
+	// @Description: create location of backup copy
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter location
+	// @Return *BackupLocation
+	// @Return error
+	CreateBackupLocation(ctx context.Context, location *BackupLocation) (*BackupLocation, error)
+
+	// UpdateBackupLocation
+	// @Description: update status, size, checksum, message and finish time of backup location
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter location
+	// @Return error
+	UpdateBackupLocation(ctx context.Context, location *BackupLocation) (err error)
+
+	// GetBackupLocation
+	// @Description: get backup location by id
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter locationId
+	// @Return *BackupLocation
+	// @Return error
+	GetBackupLocation(ctx context.Context, locationId string) (*BackupLocation, error)
+
+	// QueryBackupLocations
+	// @Description: query locations of backups, oldest first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter backupIds
+	// @Return []*BackupLocation
+	// @Return error
+	QueryBackupLocations(ctx context.Context, backupIds []string) ([]*BackupLocation, error)
+
+	// DeleteBackupLocations
+	// @Description: delete locations of backup, all locations of the backup if locationId is empty
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter backupId
+	// @Parameter locationId
+	// @Return error
+	DeleteBackupLocations(ctx context.Context, backupId string, locationId string) (err error)
 }
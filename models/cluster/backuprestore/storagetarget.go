@@ -0,0 +1,33 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package backuprestore
+
+import (
+	"github.com/pingcap/tiunimanager/models/common"
+)
+
+// BackupStorageTarget named storage where copies of backups are kept, besides the backup storage of system config
+type BackupStorageTarget struct {
+	common.Entity
+	Name        string `gorm:"not null;uniqueIndex;size:64"`
+	StorageType string `gorm:"not null"`
+	// base path of copies, bucket and prefix for s3
+	FilePath string `gorm:"not null"`
+	// only for s3
+	Endpoint        string
+	AccessKey       string
+	SecretAccessKey common.Password
+}
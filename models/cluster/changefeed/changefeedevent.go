@@ -0,0 +1,39 @@
+/******************************************************************************
+ * Copyright (c)  2021 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"github.com/pingcap/tiunimanager/common/constants"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/uuidutil"
+	"gorm.io/gorm"
+)
+
+// ChangeFeedEvent event raised by change feed watcher, such as lag threshold crossed or task auto resumed
+type ChangeFeedEvent struct {
+	dbCommon.Entity
+	TaskId    string                        `gorm:"not null;type:varchar(22);index"`
+	ClusterId string                        `gorm:"not null;type:varchar(22)"`
+	Type      constants.ChangeFeedEventType `gorm:"not null;type:varchar(32)"`
+	Message   string                        `gorm:"type:text"`
+}
+
+func (e *ChangeFeedEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if len(e.ID) == 0 {
+		e.ID = uuidutil.ShortId()
+	}
+	return nil
+}
@@ -21,9 +21,15 @@ import (
 	"fmt"
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/common/structs"
 	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	crypto "github.com/pingcap/tiunimanager/util/encrypt"
 	"github.com/pingcap/tiunimanager/util/uuidutil"
 	"gorm.io/gorm"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -41,6 +47,18 @@ type ChangeFeedTask struct {
 	Downstream        ChangeFeedDownStream       `gorm:"-"`
 	DownstreamConfig  string                     `gorm:"type:text"`
 	StatusLock        sql.NullTime               `gorm:"column:status_lock"`
+
+	// auto resume policy
+	AutoResume           bool `gorm:"default:false"`
+	AutoResumeMaxRetries int  `gorm:"default:0"`
+
+	// health maintained by change feed watcher
+	CheckpointLag  int64  `gorm:"default:0"` // milliseconds
+	LagLevel       string `gorm:"type:varchar(16)"`
+	CheckedTime    time.Time
+	LastError      string `gorm:"type:text"`
+	ResumeRetries  int    `gorm:"default:0"` // exceeds max retries once exhaustion has been reported
+	NextResumeTime time.Time
 }
 
 func (t *ChangeFeedTask) GetStatusLock() sql.NullTime {
@@ -61,13 +79,27 @@ func UnmarshalDownstream(dt constants.DownstreamType, cc string) (ChangeFeedDown
 		downstream := &MysqlDownstream{}
 		err := json.Unmarshal([]byte(cc), downstream)
 		return downstream, err
+	case constants.DownstreamTypePulsar:
+		downstream := &PulsarDownstream{}
+		err := json.Unmarshal([]byte(cc), downstream)
+		return downstream, err
+	case constants.DownstreamTypeStorage:
+		downstream := &StorageDownstream{}
+		err := json.Unmarshal([]byte(cc), downstream)
+		return downstream, err
 	}
 	return nil, errors.NewError(errors.TIUNIMANAGER_CHANGE_FEED_UNSUPPORTED_DOWNSTREAM, "")
 }
 
 func (t *ChangeFeedTask) BeforeSave(tx *gorm.DB) (err error) {
 	if t.Downstream != nil {
-		b, jsonErr := json.Marshal(t.Downstream)
+		downstream := t.Downstream
+		if encrypted, ok := downstream.(EncryptedDownstream); ok {
+			if downstream, err = encrypted.Encrypted(); err != nil {
+				return errors.WrapError(errors.TIUNIMANAGER_PARAMETER_INVALID, "encrypt downstream secrets failed", err)
+			}
+		}
+		b, jsonErr := json.Marshal(downstream)
 		if jsonErr == nil {
 			t.DownstreamConfig = string(b)
 		} else {
@@ -97,6 +129,11 @@ func (t *ChangeFeedTask) AfterFind(tx *gorm.DB) (err error) {
 		if err != nil {
 			return err
 		}
+		if encrypted, ok := downstream.(EncryptedDownstream); ok {
+			if err = encrypted.Decrypt(); err != nil {
+				return errors.WrapError(errors.TIUNIMANAGER_PARAMETER_INVALID, "decrypt downstream secrets failed", err)
+			}
+		}
 		t.Downstream = downstream
 	}
 	if len(t.FilterRulesConfig) > 0 {
@@ -135,6 +172,21 @@ type KafkaDownstream struct {
 	MaxBatchSize      int          `json:"maxBatchSize"`
 	Dispatchers       []Dispatcher `json:"dispatchers"`
 	Tls               bool         `json:"tls"`
+	// files on CDC hosts, cert and key are only required by mutual tls
+	CAPath   string `json:"caPath"`
+	CertPath string `json:"certPath"`
+	KeyPath  string `json:"keyPath"`
+	// plain, scram-sha-256 or scram-sha-512, empty means no SASL authentication
+	SaslMechanism string                `json:"saslMechanism"`
+	SaslUser      string                `json:"saslUser"`
+	SaslPassword  structs.SensitiveText `json:"saslPassword"`
+	// none, gzip, snappy, lz4 or zstd
+	Compression string `json:"compression"`
+	// url of schema registry, required by avro protocol
+	SchemaRegistry string `json:"schemaRegistry"`
+	// topic of tables not matched by dispatchers, such as {schema}_{table}
+	TopicExpression string           `json:"topicExpression"`
+	ColumnSelectors []ColumnSelector `json:"columnSelectors"`
 }
 
 type TiDBDownstream struct {
@@ -149,15 +201,79 @@ type TiDBDownstream struct {
 	TargetClusterId   string `json:"targetClusterId"`
 }
 
+type PulsarDownstream struct {
+	Ip string `json:"ip"`
+	// 6650 by default
+	Port int `json:"port"`
+	// short name or full name like persistent://tenant/namespace/topic
+	TopicName string `json:"topicName"`
+	// canal-json by default
+	Protocol string                `json:"protocol"`
+	Token    structs.SensitiveText `json:"token"`
+	Tls      bool                  `json:"tls"`
+}
+
+type StorageDownstream struct {
+	// s3 or local
+	StorageType string `json:"storageType"`
+	// bucket and prefix for s3, absolute directory on CDC hosts for local
+	Path string `json:"path"`
+	// only for s3, such as address of MinIO
+	Endpoint        string                `json:"endpoint"`
+	AccessKey       structs.SensitiveText `json:"accessKey"`
+	SecretAccessKey structs.SensitiveText `json:"secretAccessKey"`
+	// csv or canal-json, csv by default
+	Protocol string `json:"protocol"`
+	// seconds, 0 means TiCDC default
+	FlushInterval int `json:"flushInterval"`
+	// bytes, 0 means TiCDC default
+	FileSize int `json:"fileSize"`
+}
+
 type Dispatcher struct {
 	Matcher    string `json:"matcher"`
 	Dispatcher string `json:"dispatcher"`
+	// topic expression of matched tables, topic of downstream is used if empty
+	Topic string `json:"topic"`
+}
+
+type ColumnSelector struct {
+	Matcher string   `json:"matcher"`
+	Columns []string `json:"columns"`
 }
 
 type ChangeFeedDownStream interface {
 	GetSinkURI() string
 }
 
+// ValidatableDownstream downstream which checks its config before task is created or updated
+type ValidatableDownstream interface {
+	ChangeFeedDownStream
+	Validate() error
+}
+
+// SensitiveDownstream downstream with secrets, which are masked in responses
+type SensitiveDownstream interface {
+	ChangeFeedDownStream
+	// Masked
+	// @Description: copy of downstream with secrets masked
+	Masked() ChangeFeedDownStream
+	// KeepSecrets
+	// @Description: use secrets of previous config if they are masked, empty secrets are cleared
+	KeepSecrets(previous ChangeFeedDownStream)
+}
+
+// EncryptedDownstream downstream whose secrets are encrypted in task record
+type EncryptedDownstream interface {
+	ChangeFeedDownStream
+	// Encrypted
+	// @Description: copy of downstream with secrets encrypted, which is persisted instead of the downstream
+	Encrypted() (ChangeFeedDownStream, error)
+	// Decrypt
+	// @Description: decrypt secrets of downstream loaded from task record
+	Decrypt() error
+}
+
 func (p *MysqlDownstream) GetSinkURI() string {
 	p.Ip = strings.TrimPrefix(p.Ip, "http://")
 	return fmt.Sprintf("mysql://%s:%s@%s:%d/?worker-count=%d&max-txn-row=%d", p.Username, p.Password, p.Ip, p.Port, p.WorkerCount, p.MaxTxnRow)
@@ -168,9 +284,33 @@ func (p *TiDBDownstream) GetSinkURI() string {
 	return fmt.Sprintf("mysql://%s:%s@%s:%d/?worker-count=%d&max-txn-row=%d", p.Username, p.Password, p.Ip, p.Port, p.WorkerCount, p.MaxTxnRow)
 }
 
+func (p *MysqlDownstream) Masked() ChangeFeedDownStream {
+	masked := *p
+	masked.Password = string(maskSecret(structs.SensitiveText(p.Password)))
+	return &masked
+}
+
+func (p *MysqlDownstream) KeepSecrets(previous ChangeFeedDownStream) {
+	if old, ok := previous.(*MysqlDownstream); ok {
+		p.Password = string(keepSecret(structs.SensitiveText(p.Password), structs.SensitiveText(old.Password)))
+	}
+}
+
+func (p *TiDBDownstream) Masked() ChangeFeedDownStream {
+	masked := *p
+	masked.Password = string(maskSecret(structs.SensitiveText(p.Password)))
+	return &masked
+}
+
+func (p *TiDBDownstream) KeepSecrets(previous ChangeFeedDownStream) {
+	if old, ok := previous.(*TiDBDownstream); ok {
+		p.Password = string(keepSecret(structs.SensitiveText(p.Password), structs.SensitiveText(old.Password)))
+	}
+}
+
 func (p *KafkaDownstream) GetSinkURI() string {
 	p.Ip = strings.TrimPrefix(p.Ip, "http://")
-	return fmt.Sprintf("kafka://%s:%d/%s?kafka-version=%s&partition-num=%d&max-message-bytes=%d&replication-factor=%d&max-batch-size=%d&protocol=%s&kafka-client-id=%s",
+	uri := fmt.Sprintf("kafka://%s:%d/%s?kafka-version=%s&partition-num=%d&max-message-bytes=%d&replication-factor=%d&max-batch-size=%d&protocol=%s&kafka-client-id=%s",
 		p.Ip,
 		p.Port,
 		p.TopicName,
@@ -182,4 +322,303 @@ func (p *KafkaDownstream) GetSinkURI() string {
 		p.Protocol,
 		p.ClientId,
 	)
-}
\ No newline at end of file
+	params := url.Values{}
+	if p.Tls {
+		params.Set("enable-tls", "true")
+	}
+	for k, v := range map[string]string{
+		"ca":             p.CAPath,
+		"cert":           p.CertPath,
+		"key":            p.KeyPath,
+		"sasl-mechanism": p.SaslMechanism,
+		"sasl-user":      p.SaslUser,
+		"sasl-password":  string(p.SaslPassword),
+		"compression":    p.Compression,
+	} {
+		if len(v) > 0 {
+			params.Set(k, v)
+		}
+	}
+	if len(params) == 0 {
+		return uri
+	}
+	return uri + "&" + params.Encode()
+}
+
+var kafkaProtocols = []string{"default", "canal", "canal-json", "avro", "maxwell", "open-protocol"}
+var kafkaSaslMechanisms = []string{"plain", "scram-sha-256", "scram-sha-512"}
+var kafkaCompressions = []string{"none", "gzip", "snappy", "lz4", "zstd"}
+
+// same as the topic expression accepted by TiCDC
+var kafkaTopicExpression = regexp.MustCompile(`^[A-Za-z0-9\._\-]*(\{schema\})?([A-Za-z0-9\._\-]*\{table\})?[A-Za-z0-9\._\-]*$`)
+
+func (p *KafkaDownstream) Validate() error {
+	if len(p.Protocol) > 0 && !containsString(kafkaProtocols, p.Protocol) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported kafka protocol %s, expected one of %v", p.Protocol, kafkaProtocols)
+	}
+	if p.Protocol == "avro" {
+		if registry, err := url.Parse(p.SchemaRegistry); err != nil || len(registry.Scheme) == 0 || len(registry.Host) == 0 {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "valid schema registry url required by avro protocol, got %s", p.SchemaRegistry)
+		}
+	}
+	if len(p.SaslMechanism) > 0 {
+		if !containsString(kafkaSaslMechanisms, p.SaslMechanism) {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported sasl mechanism %s, expected one of %v", p.SaslMechanism, kafkaSaslMechanisms)
+		}
+		if len(p.SaslUser) == 0 || len(p.SaslPassword) == 0 {
+			return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "sasl user and password required by sasl mechanism")
+		}
+	} else if len(p.SaslUser) > 0 || len(p.SaslPassword) > 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "sasl mechanism required by sasl user and password")
+	}
+	if (len(p.CertPath) == 0) != (len(p.KeyPath) == 0) {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cert and key should be specified together")
+	}
+	if len(p.Compression) > 0 && !containsString(kafkaCompressions, p.Compression) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported compression %s, expected one of %v", p.Compression, kafkaCompressions)
+	}
+	if !kafkaTopicExpression.MatchString(p.TopicExpression) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid topic expression %s", p.TopicExpression)
+	}
+	for _, d := range p.Dispatchers {
+		if len(d.Matcher) == 0 {
+			return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "matcher of dispatcher required")
+		}
+		if !kafkaTopicExpression.MatchString(d.Topic) {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid topic expression %s of dispatcher %s", d.Topic, d.Matcher)
+		}
+	}
+	for _, c := range p.ColumnSelectors {
+		if len(c.Matcher) == 0 || len(c.Columns) == 0 {
+			return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "matcher and columns of column selector required")
+		}
+	}
+	return nil
+}
+
+func (p *KafkaDownstream) Masked() ChangeFeedDownStream {
+	masked := *p
+	masked.SaslPassword = maskSecret(p.SaslPassword)
+	return &masked
+}
+
+func (p *KafkaDownstream) KeepSecrets(previous ChangeFeedDownStream) {
+	if old, ok := previous.(*KafkaDownstream); ok {
+		p.SaslPassword = keepSecret(p.SaslPassword, old.SaslPassword)
+	}
+}
+
+func (p *KafkaDownstream) Encrypted() (ChangeFeedDownStream, error) {
+	encrypted := *p
+	password, err := encryptSecret(p.SaslPassword)
+	if err != nil {
+		return nil, err
+	}
+	encrypted.SaslPassword = password
+	return &encrypted, nil
+}
+
+func (p *KafkaDownstream) Decrypt() (err error) {
+	p.SaslPassword, err = decryptSecret(p.SaslPassword)
+	return
+}
+
+const defaultPulsarPort = 6650
+
+var pulsarProtocols = []string{"canal-json"}
+var storageProtocols = []string{"csv", "canal-json"}
+
+func (p *PulsarDownstream) GetSinkURI() string {
+	scheme := "pulsar"
+	if p.Tls {
+		scheme = "pulsar+ssl"
+	}
+	port := p.Port
+	if port == 0 {
+		port = defaultPulsarPort
+	}
+	params := url.Values{}
+	params.Set("protocol", defaultString(p.Protocol, pulsarProtocols[0]))
+	if len(p.Token) > 0 {
+		params.Set("authentication-token", string(p.Token))
+	}
+	return fmt.Sprintf("%s://%s:%d/%s?%s", scheme, strings.TrimPrefix(p.Ip, "http://"), port, p.TopicName, params.Encode())
+}
+
+func (p *PulsarDownstream) Validate() error {
+	if len(p.Ip) == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "pulsar ip required")
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid pulsar port %d", p.Port)
+	}
+	if len(p.TopicName) == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "pulsar topic required")
+	}
+	if len(p.Protocol) > 0 && !containsString(pulsarProtocols, p.Protocol) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported pulsar protocol %s, expected one of %v", p.Protocol, pulsarProtocols)
+	}
+	return nil
+}
+
+func (p *PulsarDownstream) Masked() ChangeFeedDownStream {
+	masked := *p
+	masked.Token = maskSecret(p.Token)
+	return &masked
+}
+
+func (p *PulsarDownstream) KeepSecrets(previous ChangeFeedDownStream) {
+	if old, ok := previous.(*PulsarDownstream); ok {
+		p.Token = keepSecret(p.Token, old.Token)
+	}
+}
+
+func (p *PulsarDownstream) Encrypted() (ChangeFeedDownStream, error) {
+	encrypted := *p
+	token, err := encryptSecret(p.Token)
+	if err != nil {
+		return nil, err
+	}
+	encrypted.Token = token
+	return &encrypted, nil
+}
+
+func (p *PulsarDownstream) Decrypt() (err error) {
+	p.Token, err = decryptSecret(p.Token)
+	return
+}
+
+func (p *StorageDownstream) GetSinkURI() string {
+	params := url.Values{}
+	params.Set("protocol", defaultString(p.Protocol, storageProtocols[0]))
+	if p.FlushInterval > 0 {
+		params.Set("flush-interval", fmt.Sprintf("%ds", p.FlushInterval))
+	}
+	if p.FileSize > 0 {
+		params.Set("file-size", strconv.Itoa(p.FileSize))
+	}
+
+	if p.StorageType == string(constants.StorageTypeLocal) {
+		return fmt.Sprintf("file://%s?%s", p.Path, params.Encode())
+	}
+	if len(p.Endpoint) > 0 {
+		params.Set("endpoint", p.Endpoint)
+	}
+	if len(p.AccessKey) > 0 {
+		params.Set("access-key", string(p.AccessKey))
+		params.Set("secret-access-key", string(p.SecretAccessKey))
+	}
+	return fmt.Sprintf("s3://%s?%s", strings.Trim(p.Path, "/"), params.Encode())
+}
+
+func (p *StorageDownstream) Validate() error {
+	switch p.StorageType {
+	case string(constants.StorageTypeLocal):
+		if !path.IsAbs(p.Path) {
+			return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "absolute path required for local storage, got %s", p.Path)
+		}
+	case string(constants.StorageTypeS3):
+		if len(strings.Trim(p.Path, "/")) == 0 {
+			return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "bucket required for s3 storage")
+		}
+		if (len(p.AccessKey) == 0) != (len(p.SecretAccessKey) == 0) {
+			return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "access key and secret access key should be specified together")
+		}
+		if len(p.Endpoint) > 0 {
+			if endpoint, err := url.Parse(p.Endpoint); err != nil || len(endpoint.Scheme) == 0 || len(endpoint.Host) == 0 {
+				return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "invalid s3 endpoint %s", p.Endpoint)
+			}
+		}
+	default:
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported storage type %s, expected %s or %s", p.StorageType, constants.StorageTypeS3, constants.StorageTypeLocal)
+	}
+	if len(p.Protocol) > 0 && !containsString(storageProtocols, p.Protocol) {
+		return errors.NewErrorf(errors.TIUNIMANAGER_PARAMETER_INVALID, "unsupported storage protocol %s, expected one of %v", p.Protocol, storageProtocols)
+	}
+	if p.FlushInterval < 0 || p.FileSize < 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "flush interval and file size should not be negative")
+	}
+	return nil
+}
+
+func (p *StorageDownstream) Masked() ChangeFeedDownStream {
+	masked := *p
+	masked.AccessKey = maskSecret(p.AccessKey)
+	masked.SecretAccessKey = maskSecret(p.SecretAccessKey)
+	return &masked
+}
+
+func (p *StorageDownstream) KeepSecrets(previous ChangeFeedDownStream) {
+	if old, ok := previous.(*StorageDownstream); ok {
+		p.AccessKey = keepSecret(p.AccessKey, old.AccessKey)
+		p.SecretAccessKey = keepSecret(p.SecretAccessKey, old.SecretAccessKey)
+	}
+}
+
+func (p *StorageDownstream) Encrypted() (ChangeFeedDownStream, error) {
+	encrypted := *p
+	accessKey, err := encryptSecret(p.AccessKey)
+	if err != nil {
+		return nil, err
+	}
+	secretAccessKey, err := encryptSecret(p.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	encrypted.AccessKey, encrypted.SecretAccessKey = accessKey, secretAccessKey
+	return &encrypted, nil
+}
+
+func (p *StorageDownstream) Decrypt() (err error) {
+	if p.AccessKey, err = decryptSecret(p.AccessKey); err != nil {
+		return
+	}
+	p.SecretAccessKey, err = decryptSecret(p.SecretAccessKey)
+	return
+}
+
+func maskSecret(secret structs.SensitiveText) structs.SensitiveText {
+	if len(secret) == 0 {
+		return secret
+	}
+	return structs.SensitiveTextMask
+}
+
+func keepSecret(secret structs.SensitiveText, previous structs.SensitiveText) structs.SensitiveText {
+	if secret == structs.SensitiveTextMask {
+		return previous
+	}
+	return secret
+}
+
+func encryptSecret(secret structs.SensitiveText) (structs.SensitiveText, error) {
+	if len(secret) == 0 {
+		return secret, nil
+	}
+	encrypted, err := crypto.AesEncryptCFB(string(secret))
+	return structs.SensitiveText(encrypted), err
+}
+
+func decryptSecret(secret structs.SensitiveText) (structs.SensitiveText, error) {
+	if len(secret) == 0 {
+		return secret, nil
+	}
+	decrypted, err := crypto.AesDecryptCFB(string(secret))
+	return structs.SensitiveText(decrypted), err
+}
+
+func defaultString(value string, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
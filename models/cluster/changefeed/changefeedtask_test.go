@@ -22,7 +22,9 @@ import (
 	"time"
 
 	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/structs"
 	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/uuidutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -329,6 +331,140 @@ func TestGormChangeFeedReadWrite_UpdateConfig(t *testing.T) {
 	}
 }
 
+func TestGormChangeFeedReadWrite_UpdateStartTS(t *testing.T) {
+	existed, _ := testRW.Create(context.TODO(), &ChangeFeedTask{Entity: common.Entity{TenantId: "111"}, StartTS: 1})
+	defer testRW.Delete(context.TODO(), existed.ID)
+
+	t.Run("normal", func(t *testing.T) {
+		err := testRW.UpdateStartTS(context.TODO(), existed.ID, 431106130950553601)
+		assert.NoError(t, err)
+		updated, _ := testRW.Get(context.TODO(), existed.ID)
+		assert.Equal(t, int64(431106130950553601), updated.StartTS)
+	})
+	t.Run("not existed", func(t *testing.T) {
+		err := testRW.UpdateStartTS(context.TODO(), "111", 431106130950553601)
+		assert.Error(t, err)
+	})
+}
+
+func TestGormChangeFeedReadWrite_QueryByStatus(t *testing.T) {
+	normal, _ := testRW.Create(context.TODO(), &ChangeFeedTask{Entity: common.Entity{TenantId: "111", Status: string(constants.ChangeFeedStatusNormal)}, ClusterId: "7777", Type: constants.DownstreamTypeTiDB})
+	finished, _ := testRW.Create(context.TODO(), &ChangeFeedTask{Entity: common.Entity{TenantId: "111", Status: string(constants.ChangeFeedStatusFinished)}, ClusterId: "8888", Type: constants.DownstreamTypeTiDB})
+	deleted, _ := testRW.Create(context.TODO(), &ChangeFeedTask{Entity: common.Entity{TenantId: "111", Status: string(constants.ChangeFeedStatusError)}, ClusterId: "8888", Type: constants.DownstreamTypeTiDB})
+	defer testRW.Delete(context.TODO(), normal.ID)
+	defer testRW.Delete(context.TODO(), finished.ID)
+	testRW.DB(context.TODO()).Delete(deleted)
+
+	tasks, err := testRW.QueryByStatus(context.TODO(), constants.UnfinishedChangeFeedStatus())
+	assert.NoError(t, err)
+	ids := make(map[string]bool)
+	for _, task := range tasks {
+		ids[task.ID] = true
+	}
+	assert.True(t, ids[normal.ID])
+	assert.False(t, ids[finished.ID])
+	assert.False(t, ids[deleted.ID])
+
+	tasks, err = testRW.QueryByStatus(context.TODO(), []constants.ChangeFeedStatus{})
+	assert.NoError(t, err)
+	ids = make(map[string]bool)
+	for _, task := range tasks {
+		ids[task.ID] = true
+	}
+	assert.True(t, ids[normal.ID])
+	assert.True(t, ids[finished.ID])
+}
+
+func TestGormChangeFeedReadWrite_UpdateHealth(t *testing.T) {
+	existed, _ := testRW.Create(context.TODO(), &ChangeFeedTask{
+		Entity:     common.Entity{TenantId: "111", Status: string(constants.ChangeFeedStatusNormal)},
+		Name:       "origin",
+		AutoResume: true,
+	})
+	defer testRW.Delete(context.TODO(), existed.ID)
+
+	checkedTime := time.Now().Round(time.Second)
+	t.Run("normal", func(t *testing.T) {
+		err := testRW.UpdateHealth(context.TODO(), &ChangeFeedTask{
+			Entity:         common.Entity{ID: existed.ID, Status: string(constants.ChangeFeedStatusError)},
+			Name:           "ignored",
+			CheckpointLag:  75000,
+			LagLevel:       string(constants.ChangeFeedLagWarning),
+			CheckedTime:    checkedTime,
+			LastError:      "error",
+			ResumeRetries:  2,
+			NextResumeTime: checkedTime.Add(time.Minute),
+		})
+		assert.NoError(t, err)
+
+		updated, _ := testRW.Get(context.TODO(), existed.ID)
+		assert.Equal(t, int64(75000), updated.CheckpointLag)
+		assert.Equal(t, string(constants.ChangeFeedLagWarning), updated.LagLevel)
+		assert.True(t, checkedTime.Equal(updated.CheckedTime))
+		assert.Equal(t, "error", updated.LastError)
+		assert.Equal(t, 2, updated.ResumeRetries)
+		assert.True(t, checkedTime.Add(time.Minute).Equal(updated.NextResumeTime))
+		// config and status are never changed
+		assert.Equal(t, "origin", updated.Name)
+		assert.True(t, updated.AutoResume)
+		assert.Equal(t, string(constants.ChangeFeedStatusNormal), updated.Status)
+	})
+	t.Run("not existed", func(t *testing.T) {
+		err := testRW.UpdateHealth(context.TODO(), &ChangeFeedTask{Entity: common.Entity{ID: "111"}})
+		assert.Error(t, err)
+	})
+	t.Run("kept by update config", func(t *testing.T) {
+		template, _ := testRW.Get(context.TODO(), existed.ID)
+		template.CheckpointLag = 0
+		template.ResumeRetries = 0
+		template.AutoResume = false
+		err := testRW.UpdateConfig(context.TODO(), template)
+		assert.NoError(t, err)
+
+		updated, _ := testRW.Get(context.TODO(), existed.ID)
+		assert.Equal(t, int64(75000), updated.CheckpointLag)
+		assert.Equal(t, 2, updated.ResumeRetries)
+		assert.False(t, updated.AutoResume)
+	})
+}
+
+func TestGormChangeFeedReadWrite_Events(t *testing.T) {
+	taskId := uuidutil.ShortId()
+	_, err := testRW.CreateEvent(context.TODO(), &ChangeFeedEvent{Type: constants.ChangeFeedEventLagWarning})
+	assert.Error(t, err)
+
+	for _, eventType := range []constants.ChangeFeedEventType{
+		constants.ChangeFeedEventLagWarning,
+		constants.ChangeFeedEventLagCritical,
+		constants.ChangeFeedEventLagRecovered,
+	} {
+		event, err := testRW.CreateEvent(context.TODO(), &ChangeFeedEvent{
+			Entity:    common.Entity{TenantId: "111"},
+			TaskId:    taskId,
+			ClusterId: "6666",
+			Type:      eventType,
+			Message:   string(eventType),
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, event.ID)
+		time.Sleep(10 * time.Millisecond)
+	}
+	testRW.CreateEvent(context.TODO(), &ChangeFeedEvent{Entity: common.Entity{TenantId: "111"}, TaskId: "anotherTask", Type: constants.ChangeFeedEventTaskError})
+
+	events, err := testRW.QueryEvents(context.TODO(), taskId, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, constants.ChangeFeedEventLagRecovered, events[0].Type)
+	assert.Equal(t, constants.ChangeFeedEventLagCritical, events[1].Type)
+
+	events, err = testRW.QueryEvents(context.TODO(), taskId, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(events))
+
+	_, err = testRW.QueryEvents(context.TODO(), "", 0)
+	assert.Error(t, err)
+}
+
 func TestConvertStatus(t *testing.T) {
 	type args struct {
 		s string
@@ -540,3 +676,296 @@ func TestKafkaDownstream_GetSinkURI(t *testing.T) {
 		})
 	}
 }
+
+func TestKafkaDownstream_GetSinkURIWithOptions(t *testing.T) {
+	downstream := KafkaDownstream{
+		Ip:            "127.0.0.1",
+		Port:          9092,
+		Version:       "2.4.0",
+		TopicName:     "myTopic",
+		Protocol:      "avro",
+		Tls:           true,
+		CAPath:        "/etc/kafka/ca.pem",
+		SaslMechanism: "scram-sha-256",
+		SaslUser:      "cdc",
+		SaslPassword:  "p&ss",
+		Compression:   "lz4",
+	}
+	assert.Equal(t, "kafka://127.0.0.1:9092/myTopic?kafka-version=2.4.0&partition-num=0&max-message-bytes=0&replication-factor=0&max-batch-size=0&protocol=avro&kafka-client-id="+
+		"&ca=%2Fetc%2Fkafka%2Fca.pem&compression=lz4&enable-tls=true&sasl-mechanism=scram-sha-256&sasl-password=p%26ss&sasl-user=cdc", downstream.GetSinkURI())
+}
+
+func TestKafkaDownstream_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		downstream KafkaDownstream
+		wantErr    bool
+	}{
+		{"normal", KafkaDownstream{Protocol: "default"}, false},
+		{"full", KafkaDownstream{Protocol: "avro", SchemaRegistry: "http://127.0.0.1:8081", SaslMechanism: "plain", SaslUser: "cdc", SaslPassword: "pass",
+			CertPath: "/etc/client.pem", KeyPath: "/etc/client-key.pem", Compression: "zstd", TopicExpression: "{schema}_{table}",
+			Dispatchers:     []Dispatcher{{Matcher: "test.*", Dispatcher: "ts", Topic: "test_{table}"}},
+			ColumnSelectors: []ColumnSelector{{Matcher: "test.t1", Columns: []string{"id"}}}}, false},
+		{"invalid protocol", KafkaDownstream{Protocol: "csv"}, true},
+		{"avro without schema registry", KafkaDownstream{Protocol: "avro"}, true},
+		{"invalid sasl mechanism", KafkaDownstream{SaslMechanism: "gssapi", SaslUser: "cdc", SaslPassword: "pass"}, true},
+		{"sasl without password", KafkaDownstream{SaslMechanism: "plain", SaslUser: "cdc"}, true},
+		{"sasl without mechanism", KafkaDownstream{SaslUser: "cdc", SaslPassword: "pass"}, true},
+		{"cert without key", KafkaDownstream{CertPath: "/etc/client.pem"}, true},
+		{"invalid compression", KafkaDownstream{Compression: "brotli"}, true},
+		{"invalid topic expression", KafkaDownstream{TopicExpression: "{table}_{schema}"}, true},
+		{"dispatcher without matcher", KafkaDownstream{Dispatchers: []Dispatcher{{Dispatcher: "ts"}}}, true},
+		{"invalid dispatcher topic", KafkaDownstream{Dispatchers: []Dispatcher{{Matcher: "test.*", Topic: "test/{table}"}}}, true},
+		{"column selector without columns", KafkaDownstream{ColumnSelectors: []ColumnSelector{{Matcher: "test.t1"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.downstream.Validate() != nil)
+		})
+	}
+}
+
+func TestKafkaDownstream_Secrets(t *testing.T) {
+	downstream := &KafkaDownstream{SaslUser: "cdc", SaslPassword: "pass"}
+	assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), downstream.Masked().(*KafkaDownstream).SaslPassword)
+	assert.Equal(t, structs.SensitiveText("pass"), downstream.SaslPassword)
+
+	updated := &KafkaDownstream{SaslUser: "cdc", SaslPassword: structs.SensitiveTextMask}
+	updated.KeepSecrets(downstream)
+	assert.Equal(t, structs.SensitiveText("pass"), updated.SaslPassword)
+
+	encrypted, err := downstream.Encrypted()
+	assert.NoError(t, err)
+	assert.NotEqual(t, structs.SensitiveText("pass"), encrypted.(*KafkaDownstream).SaslPassword)
+	assert.NoError(t, encrypted.(*KafkaDownstream).Decrypt())
+	assert.Equal(t, structs.SensitiveText("pass"), encrypted.(*KafkaDownstream).SaslPassword)
+}
+
+func TestChangeFeedTask_EncryptDownstream(t *testing.T) {
+	downstream := &KafkaDownstream{Ip: "127.0.0.1", Port: 9092, SaslMechanism: "plain", SaslUser: "cdc", SaslPassword: "pass"}
+	task, err := testRW.Create(context.TODO(), &ChangeFeedTask{
+		Entity:     common.Entity{TenantId: "111"},
+		ClusterId:  "9999",
+		Type:       constants.DownstreamTypeKafka,
+		Downstream: downstream,
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, task.DownstreamConfig, "pass")
+	assert.Equal(t, structs.SensitiveText("pass"), downstream.SaslPassword)
+
+	got, err := testRW.Get(context.TODO(), task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, downstream, got.Downstream)
+}
+
+func TestEncryptedDownstream(t *testing.T) {
+	t.Run("pulsar", func(t *testing.T) {
+		downstream := &PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic", Token: "abc"}
+		encrypted, err := downstream.Encrypted()
+		assert.NoError(t, err)
+		assert.NotEqual(t, structs.SensitiveText("abc"), encrypted.(*PulsarDownstream).Token)
+		assert.Equal(t, structs.SensitiveText("abc"), downstream.Token)
+		assert.NoError(t, encrypted.(*PulsarDownstream).Decrypt())
+		assert.Equal(t, downstream, encrypted)
+
+		empty := &PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic"}
+		encrypted, err = empty.Encrypted()
+		assert.NoError(t, err)
+		assert.Empty(t, encrypted.(*PulsarDownstream).Token)
+	})
+	t.Run("storage", func(t *testing.T) {
+		downstream := &StorageDownstream{StorageType: "s3", Path: "bucket", AccessKey: "ak", SecretAccessKey: "sk"}
+		encrypted, err := downstream.Encrypted()
+		assert.NoError(t, err)
+		assert.NotEqual(t, structs.SensitiveText("ak"), encrypted.(*StorageDownstream).AccessKey)
+		assert.NotEqual(t, structs.SensitiveText("sk"), encrypted.(*StorageDownstream).SecretAccessKey)
+		assert.NoError(t, encrypted.(*StorageDownstream).Decrypt())
+		assert.Equal(t, downstream, encrypted)
+	})
+	t.Run("persisted", func(t *testing.T) {
+		downstream := &StorageDownstream{StorageType: "s3", Path: "bucket", AccessKey: "minio-ak", SecretAccessKey: "minio-sk"}
+		task, err := testRW.Create(context.TODO(), &ChangeFeedTask{
+			Entity:     common.Entity{TenantId: "111"},
+			ClusterId:  "9999",
+			Type:       constants.DownstreamTypeStorage,
+			Downstream: downstream,
+		})
+		assert.NoError(t, err)
+		assert.NotContains(t, task.DownstreamConfig, "minio-ak")
+		assert.NotContains(t, task.DownstreamConfig, "minio-sk")
+
+		got, err := testRW.Get(context.TODO(), task.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, downstream, got.Downstream)
+	})
+}
+
+func TestPulsarDownstream_GetSinkURI(t *testing.T) {
+	tests := []struct {
+		name       string
+		downstream PulsarDownstream
+		want       string
+	}{
+		{"default", PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic"}, "pulsar://127.0.0.1:6650/topic?protocol=canal-json"},
+		{"full", PulsarDownstream{Ip: "http://127.0.0.1", Port: 6651, TopicName: "persistent://public/default/topic", Protocol: "canal-json", Token: "abc", Tls: true},
+			"pulsar+ssl://127.0.0.1:6651/persistent://public/default/topic?authentication-token=abc&protocol=canal-json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.downstream.GetSinkURI())
+		})
+	}
+}
+
+func TestPulsarDownstream_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		downstream PulsarDownstream
+		wantErr    bool
+	}{
+		{"normal", PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic"}, false},
+		{"without ip", PulsarDownstream{TopicName: "topic"}, true},
+		{"invalid port", PulsarDownstream{Ip: "127.0.0.1", Port: 70000, TopicName: "topic"}, true},
+		{"without topic", PulsarDownstream{Ip: "127.0.0.1"}, true},
+		{"invalid protocol", PulsarDownstream{Ip: "127.0.0.1", TopicName: "topic", Protocol: "avro"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.downstream.Validate() != nil)
+		})
+	}
+}
+
+func TestStorageDownstream_GetSinkURI(t *testing.T) {
+	tests := []struct {
+		name       string
+		downstream StorageDownstream
+		want       string
+	}{
+		{"local", StorageDownstream{StorageType: "local", Path: "/data/cdc", FlushInterval: 5, FileSize: 1024},
+			"file:///data/cdc?file-size=1024&flush-interval=5s&protocol=csv"},
+		{"minio", StorageDownstream{StorageType: "s3", Path: "/bucket/cdc/", Endpoint: "http://127.0.0.1:9000", AccessKey: "ak", SecretAccessKey: "sk", Protocol: "canal-json"},
+			"s3://bucket/cdc?access-key=ak&endpoint=http%3A%2F%2F127.0.0.1%3A9000&protocol=canal-json&secret-access-key=sk"},
+		{"s3 without keys", StorageDownstream{StorageType: "s3", Path: "bucket"}, "s3://bucket?protocol=csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.downstream.GetSinkURI())
+		})
+	}
+}
+
+func TestStorageDownstream_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		downstream StorageDownstream
+		wantErr    bool
+	}{
+		{"local", StorageDownstream{StorageType: "local", Path: "/data/cdc"}, false},
+		{"relative local path", StorageDownstream{StorageType: "local", Path: "data/cdc"}, true},
+		{"s3", StorageDownstream{StorageType: "s3", Path: "bucket/cdc", Endpoint: "http://127.0.0.1:9000", AccessKey: "ak", SecretAccessKey: "sk"}, false},
+		{"s3 without bucket", StorageDownstream{StorageType: "s3", Path: "/"}, true},
+		{"s3 without secret key", StorageDownstream{StorageType: "s3", Path: "bucket", AccessKey: "ak"}, true},
+		{"invalid endpoint", StorageDownstream{StorageType: "s3", Path: "bucket", Endpoint: "127.0.0.1:9000"}, true},
+		{"unsupported storage", StorageDownstream{StorageType: "nfs", Path: "/data/cdc"}, true},
+		{"invalid protocol", StorageDownstream{StorageType: "local", Path: "/data/cdc", Protocol: "avro"}, true},
+		{"negative file size", StorageDownstream{StorageType: "local", Path: "/data/cdc", FileSize: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.downstream.Validate() != nil)
+		})
+	}
+}
+
+func TestSensitiveDownstream(t *testing.T) {
+	t.Run("tidb", func(t *testing.T) {
+		downstream := &TiDBDownstream{Ip: "127.0.0.1", Username: "root", Password: "abc"}
+		masked := downstream.Masked().(*TiDBDownstream)
+		assert.Equal(t, structs.SensitiveTextMask, masked.Password)
+		assert.Equal(t, "abc", downstream.Password)
+
+		updated := &TiDBDownstream{Ip: "127.0.0.2", Username: "root", Password: structs.SensitiveTextMask}
+		updated.KeepSecrets(downstream)
+		assert.Equal(t, "abc", updated.Password)
+
+		// passwords of mysql downstream are ignored
+		updated = &TiDBDownstream{Ip: "127.0.0.2", Username: "root", Password: structs.SensitiveTextMask}
+		updated.KeepSecrets(&MysqlDownstream{Password: "abc"})
+		assert.Equal(t, structs.SensitiveTextMask, updated.Password)
+	})
+	t.Run("mysql", func(t *testing.T) {
+		downstream := &MysqlDownstream{Ip: "127.0.0.1", Username: "root", Password: "abc"}
+		masked := downstream.Masked().(*MysqlDownstream)
+		assert.Equal(t, structs.SensitiveTextMask, masked.Password)
+
+		empty := (&MysqlDownstream{Ip: "127.0.0.1", Username: "root"}).Masked().(*MysqlDownstream)
+		assert.Empty(t, empty.Password)
+
+		updated := &MysqlDownstream{Ip: "127.0.0.2", Username: "root", Password: structs.SensitiveTextMask}
+		updated.KeepSecrets(downstream)
+		assert.Equal(t, "abc", updated.Password)
+
+		updated = &MysqlDownstream{Ip: "127.0.0.2", Username: "root", Password: "new"}
+		updated.KeepSecrets(downstream)
+		assert.Equal(t, "new", updated.Password)
+	})
+	t.Run("pulsar", func(t *testing.T) {
+		downstream := &PulsarDownstream{Ip: "127.0.0.1", Token: "abc"}
+		masked := downstream.Masked().(*PulsarDownstream)
+		assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), masked.Token)
+		assert.Equal(t, structs.SensitiveText("abc"), downstream.Token)
+
+		updated := &PulsarDownstream{Ip: "127.0.0.2", Token: structs.SensitiveTextMask}
+		updated.KeepSecrets(downstream)
+		assert.Equal(t, structs.SensitiveText("abc"), updated.Token)
+
+		updated = &PulsarDownstream{Ip: "127.0.0.2", Token: "new"}
+		updated.KeepSecrets(downstream)
+		assert.Equal(t, structs.SensitiveText("new"), updated.Token)
+
+		// empty token clears the previous one
+		updated = &PulsarDownstream{Ip: "127.0.0.2"}
+		updated.KeepSecrets(downstream)
+		assert.Empty(t, updated.Token)
+	})
+	t.Run("storage", func(t *testing.T) {
+		downstream := &StorageDownstream{StorageType: "s3", Path: "bucket", AccessKey: "ak", SecretAccessKey: "sk"}
+		masked := downstream.Masked().(*StorageDownstream)
+		assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), masked.AccessKey)
+		assert.Equal(t, structs.SensitiveText(structs.SensitiveTextMask), masked.SecretAccessKey)
+		assert.Equal(t, "bucket", masked.Path)
+
+		empty := (&StorageDownstream{StorageType: "local", Path: "/data"}).Masked().(*StorageDownstream)
+		assert.Empty(t, empty.AccessKey)
+
+		updated := &StorageDownstream{StorageType: "s3", Path: "bucket", AccessKey: structs.SensitiveTextMask, SecretAccessKey: structs.SensitiveTextMask}
+		updated.KeepSecrets(downstream)
+		assert.Equal(t, structs.SensitiveText("ak"), updated.AccessKey)
+		assert.Equal(t, structs.SensitiveText("sk"), updated.SecretAccessKey)
+
+		// empty keys clear the previous ones
+		updated = &StorageDownstream{StorageType: "s3", Path: "bucket"}
+		updated.KeepSecrets(downstream)
+		assert.Empty(t, updated.AccessKey)
+		assert.Empty(t, updated.SecretAccessKey)
+
+		// secrets of another type are ignored
+		updated = &StorageDownstream{StorageType: "s3", Path: "bucket"}
+		updated.KeepSecrets(&PulsarDownstream{Token: "abc"})
+		assert.Empty(t, updated.AccessKey)
+	})
+}
+
+func TestUnmarshalDownstream(t *testing.T) {
+	downstream, err := UnmarshalDownstream(constants.DownstreamTypePulsar, `{"ip":"127.0.0.1","topicName":"topic","token":"abc"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.SensitiveText("abc"), downstream.(*PulsarDownstream).Token)
+
+	downstream, err = UnmarshalDownstream(constants.DownstreamTypeStorage, `{"storageType":"local","path":"/data/cdc"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/cdc", downstream.(*StorageDownstream).Path)
+
+	_, err = UnmarshalDownstream("unknown", `{}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"time"
+
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/uuidutil"
+	"gorm.io/gorm"
+)
+
+// ConsistencyCheck data consistency check between upstream cluster and downstream of change feed task
+type ConsistencyCheck struct {
+	dbCommon.Entity
+	TaskId    string `gorm:"not null;type:varchar(22);index"`
+	ClusterId string `gorm:"not null;type:varchar(22);index"`
+	// empty if downstream is not a cluster managed by us
+	DownstreamClusterId string `gorm:"type:varchar(22);index"`
+	Trigger             string `gorm:"type:varchar(16)"`
+	WorkFlowId          string `gorm:"type:varchar(22)"`
+
+	// Databases and Tables are joined by comma, all tables of change feed are checked if both are empty
+	Databases      string `gorm:"type:text"`
+	Tables         string `gorm:"type:text"`
+	GenerateFixSQL bool   `gorm:"default:false"`
+
+	SnapshotMode  string `gorm:"type:varchar(16)"`
+	UpstreamTSO   uint64 `gorm:"column:upstream_tso"`
+	DownstreamTSO uint64 `gorm:"column:downstream_tso"`
+
+	CheckedTables  int    `gorm:"default:0"`
+	MismatchTables int    `gorm:"default:0"`
+	MismatchChunks int    `gorm:"default:0"`
+	Result         string `gorm:"type:longtext"` // json of mismatched tables
+	FixSQL         string `gorm:"type:longtext"`
+	Message        string `gorm:"type:text"`
+	StartTime      time.Time
+	EndTime        time.Time
+}
+
+func (c *ConsistencyCheck) BeforeCreate(tx *gorm.DB) (err error) {
+	if len(c.ID) == 0 {
+		c.ID = uuidutil.ShortId()
+	}
+	return nil
+}
+
+// ConsistencySchedule periodic consistency check of a change feed task
+type ConsistencySchedule struct {
+	dbCommon.Entity
+	TaskId    string `gorm:"not null;type:varchar(22);index"`
+	ClusterId string `gorm:"not null;type:varchar(22)"`
+	// standard 5-field cron expression or descriptor, evaluated in TimeZone
+	CronSpec       string `gorm:"not null;type:varchar(64)"`
+	TimeZone       string `gorm:"type:varchar(64)"`
+	Paused         bool   `gorm:"default:false"`
+	Databases      string `gorm:"type:text"`
+	Tables         string `gorm:"type:text"`
+	GenerateFixSQL bool   `gorm:"default:false"`
+
+	LastScheduledTime time.Time
+	LastCheckId       string `gorm:"type:varchar(22)"`
+}
+
+func (s *ConsistencySchedule) BeforeCreate(tx *gorm.DB) (err error) {
+	if len(s.ID) == 0 {
+		s.ID = uuidutil.ShortId()
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package changefeed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/uuidutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGormChangeFeedReadWrite_ConsistencyChecks(t *testing.T) {
+	clusterId := uuidutil.ShortId()
+	taskId := uuidutil.ShortId()
+
+	_, err := testRW.CreateConsistencyCheck(context.TODO(), &ConsistencyCheck{ClusterId: clusterId})
+	assert.Error(t, err)
+
+	created := make([]*ConsistencyCheck, 0)
+	for _, downstream := range []string{"standby1", "standby2", "standby1"} {
+		check, err := testRW.CreateConsistencyCheck(context.TODO(), &ConsistencyCheck{
+			Entity:              common.Entity{TenantId: "111", Status: string(constants.ConsistencyCheckRunning)},
+			TaskId:              taskId,
+			ClusterId:           clusterId,
+			DownstreamClusterId: downstream,
+			Trigger:             string(constants.ConsistencyCheckTriggerManual),
+			StartTime:           time.Now(),
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, check.ID)
+		created = append(created, check)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Run("update", func(t *testing.T) {
+		endTime := time.Now().Round(time.Second)
+		err := testRW.UpdateConsistencyCheck(context.TODO(), &ConsistencyCheck{
+			Entity:         common.Entity{ID: created[0].ID, Status: string(constants.ConsistencyCheckInconsistent)},
+			ClusterId:      "ignored",
+			SnapshotMode:   string(constants.ConsistencySnapshotSyncPoint),
+			UpstreamTSO:    431434047157698561,
+			DownstreamTSO:  431434047157698562,
+			CheckedTables:  3,
+			MismatchTables: 1,
+			MismatchChunks: 2,
+			Result:         "[]",
+			FixSQL:         "DELETE FROM `db1`.`t1` WHERE `id` = '4' LIMIT 1;",
+			EndTime:        endTime,
+		})
+		assert.NoError(t, err)
+
+		check, err := testRW.GetConsistencyCheck(context.TODO(), created[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.ConsistencyCheckInconsistent), check.Status)
+		assert.Equal(t, clusterId, check.ClusterId)
+		assert.Equal(t, uint64(431434047157698561), check.UpstreamTSO)
+		assert.Equal(t, 2, check.MismatchChunks)
+		assert.Equal(t, "[]", check.Result)
+		assert.True(t, endTime.Equal(check.EndTime))
+
+		err = testRW.UpdateConsistencyCheck(context.TODO(), &ConsistencyCheck{Entity: common.Entity{ID: "111"}})
+		assert.Error(t, err)
+	})
+	t.Run("get", func(t *testing.T) {
+		_, err := testRW.GetConsistencyCheck(context.TODO(), "")
+		assert.Error(t, err)
+		_, err = testRW.GetConsistencyCheck(context.TODO(), "111")
+		assert.Error(t, err)
+	})
+	t.Run("query", func(t *testing.T) {
+		checks, total, err := testRW.QueryConsistencyChecks(context.TODO(), clusterId, "", "", nil, 0, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, 2, len(checks))
+		assert.Equal(t, created[2].ID, checks[0].ID)
+
+		checks, total, err = testRW.QueryConsistencyChecks(context.TODO(), "", "standby1", taskId, nil, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, 2, len(checks))
+
+		checks, total, err = testRW.QueryConsistencyChecks(context.TODO(), clusterId, "", "",
+			[]constants.ConsistencyCheckStatus{constants.ConsistencyCheckInconsistent}, 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, created[0].ID, checks[0].ID)
+		assert.Equal(t, 1, checks[0].MismatchTables)
+		assert.Empty(t, checks[0].FixSQL)
+	})
+}
+
+func TestGormChangeFeedReadWrite_ConsistencySchedules(t *testing.T) {
+	taskId := uuidutil.ShortId()
+	scheduledTime := time.Now().Round(time.Second)
+
+	_, err := testRW.SaveConsistencySchedule(context.TODO(), &ConsistencySchedule{CronSpec: "@daily"})
+	assert.Error(t, err)
+	_, err = testRW.GetConsistencySchedule(context.TODO(), taskId)
+	assert.Error(t, err)
+
+	created, err := testRW.SaveConsistencySchedule(context.TODO(), &ConsistencySchedule{
+		Entity:            common.Entity{TenantId: "111"},
+		TaskId:            taskId,
+		ClusterId:         "6666",
+		CronSpec:          "@daily",
+		LastScheduledTime: scheduledTime,
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	replaced, err := testRW.SaveConsistencySchedule(context.TODO(), &ConsistencySchedule{
+		Entity:            common.Entity{TenantId: "111"},
+		TaskId:            taskId,
+		ClusterId:         "6666",
+		CronSpec:          "0 2 * * *",
+		TimeZone:          "Asia/Shanghai",
+		Paused:            true,
+		Databases:         "db1,db2",
+		GenerateFixSQL:    true,
+		LastScheduledTime: scheduledTime,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, replaced.ID)
+	assert.Equal(t, "0 2 * * *", replaced.CronSpec)
+	assert.True(t, replaced.Paused)
+	assert.True(t, replaced.GenerateFixSQL)
+
+	err = testRW.UpdateConsistencyScheduleRun(context.TODO(), created.ID, scheduledTime.Add(time.Hour), "check1")
+	assert.NoError(t, err)
+	err = testRW.UpdateConsistencyScheduleRun(context.TODO(), created.ID, scheduledTime.Add(2*time.Hour), "")
+	assert.NoError(t, err)
+	schedule, err := testRW.GetConsistencySchedule(context.TODO(), taskId)
+	assert.NoError(t, err)
+	assert.Equal(t, "check1", schedule.LastCheckId)
+	assert.True(t, scheduledTime.Add(2*time.Hour).Equal(schedule.LastScheduledTime))
+	assert.Error(t, testRW.UpdateConsistencyScheduleRun(context.TODO(), "", scheduledTime, ""))
+
+	schedules, err := testRW.QueryConsistencySchedules(context.TODO())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, schedules)
+
+	assert.NoError(t, testRW.DeleteConsistencySchedule(context.TODO(), taskId))
+	assert.Error(t, testRW.DeleteConsistencySchedule(context.TODO(), taskId))
+	_, err = testRW.GetConsistencySchedule(context.TODO(), taskId)
+	assert.Error(t, err)
+}
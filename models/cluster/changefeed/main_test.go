@@ -44,6 +44,9 @@ func TestMain(m *testing.M) {
 				logins.Infof("open database successful, filepath: %s", dbFile)
 			}
 			db.Migrator().CreateTable(ChangeFeedTask{})
+			db.Migrator().CreateTable(ChangeFeedEvent{})
+			db.Migrator().CreateTable(ConsistencyCheck{})
+			db.Migrator().CreateTable(ConsistencySchedule{})
 
 			testRW = NewGormChangeFeedReadWrite(db)
 			return nil
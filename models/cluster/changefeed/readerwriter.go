@@ -17,6 +17,8 @@ package changefeed
 
 import (
 	"context"
+	"time"
+
 	"github.com/pingcap/tiunimanager/common/constants"
 )
 
@@ -98,4 +100,134 @@ type ReaderWriter interface {
 	// @Parameter updateTemplate skip fields below : ChangeFeedStatus、StatusLock、ClusterId, StartTS
 	// @return error if task non-existent
 	UpdateConfig(ctx context.Context, updateTemplate *ChangeFeedTask) error
+
+	// UpdateStartTS
+	// @Description: update start ts of task which is re-created from a new start point
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @Parameter startTS
+	// @return error if task non-existent
+	UpdateStartTS(ctx context.Context, taskId string, startTS int64) error
+
+	// QueryByStatus
+	// @Description: query tasks of all clusters
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter statuses
+	// @return tasks
+	// @return err
+	QueryByStatus(ctx context.Context, statuses []constants.ChangeFeedStatus) (tasks []*ChangeFeedTask, err error)
+
+	// UpdateHealth
+	// @Description: update fields maintained by change feed watcher, including lag and auto resume state
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter task
+	// @return error if task non-existent
+	UpdateHealth(ctx context.Context, task *ChangeFeedTask) error
+
+	// CreateEvent
+	// @Description: record an event of change feed task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter event
+	// @return *ChangeFeedEvent
+	// @return error
+	CreateEvent(ctx context.Context, event *ChangeFeedEvent) (*ChangeFeedEvent, error)
+
+	// QueryEvents
+	// @Description: query latest events of change feed task, newest first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @Parameter length
+	// @return events
+	// @return err
+	QueryEvents(ctx context.Context, taskId string, length int) (events []*ChangeFeedEvent, err error)
+
+	// CreateConsistencyCheck
+	// @Description: create a consistency check of change feed task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter check
+	// @return *ConsistencyCheck
+	// @return error
+	CreateConsistencyCheck(ctx context.Context, check *ConsistencyCheck) (*ConsistencyCheck, error)
+
+	// UpdateConsistencyCheck
+	// @Description: update status, snapshots and result of consistency check
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter check
+	// @return error if check non-existent
+	UpdateConsistencyCheck(ctx context.Context, check *ConsistencyCheck) error
+
+	// GetConsistencyCheck
+	// @Description: get consistency check from id
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter checkId
+	// @return *ConsistencyCheck
+	// @return error if check non-existent
+	GetConsistencyCheck(ctx context.Context, checkId string) (*ConsistencyCheck, error)
+
+	// QueryConsistencyChecks
+	// @Description: query consistency checks, newest first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId optional
+	// @Parameter downstreamClusterId optional
+	// @Parameter taskId optional
+	// @Parameter statuses optional
+	// @Parameter offset
+	// @Parameter length
+	// @return checks
+	// @return total
+	// @return err
+	QueryConsistencyChecks(ctx context.Context, clusterId string, downstreamClusterId string, taskId string, statuses []constants.ConsistencyCheckStatus, offset int, length int) (checks []*ConsistencyCheck, total int64, err error)
+
+	// SaveConsistencySchedule
+	// @Description: create or replace the consistency check schedule of change feed task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter schedule
+	// @return *ConsistencySchedule
+	// @return error
+	SaveConsistencySchedule(ctx context.Context, schedule *ConsistencySchedule) (*ConsistencySchedule, error)
+
+	// GetConsistencySchedule
+	// @Description: get consistency check schedule of change feed task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @return *ConsistencySchedule
+	// @return error if schedule non-existent
+	GetConsistencySchedule(ctx context.Context, taskId string) (*ConsistencySchedule, error)
+
+	// DeleteConsistencySchedule
+	// @Description: delete consistency check schedule of change feed task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @return error if schedule non-existent
+	DeleteConsistencySchedule(ctx context.Context, taskId string) error
+
+	// QueryConsistencySchedules
+	// @Description: query consistency check schedules of all change feed tasks
+	// @Receiver m
+	// @Parameter ctx
+	// @return schedules
+	// @return err
+	QueryConsistencySchedules(ctx context.Context) (schedules []*ConsistencySchedule, err error)
+
+	// UpdateConsistencyScheduleRun
+	// @Description: record the latest evaluation of consistency check schedule
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter scheduleId
+	// @Parameter scheduledTime
+	// @Parameter checkId unchanged if empty
+	// @return error
+	UpdateConsistencyScheduleRun(ctx context.Context, scheduleId string, scheduledTime time.Time, checkId string) error
 }
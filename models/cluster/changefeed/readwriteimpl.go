@@ -102,11 +102,22 @@ func (m *GormChangeFeedReadWrite) UpdateConfig(ctx context.Context, updateTempla
 		return err
 	}
 
-	err = m.DB(ctx).Omit("status_lock", "status", "cluster_id", "start_ts").
+	err = m.DB(ctx).Omit("status_lock", "status", "cluster_id", "start_ts",
+		"checkpoint_lag", "lag_level", "checked_time", "last_error", "resume_retries", "next_resume_time").
 		Save(updateTemplate).Error
 	return dbCommon.WrapDBError(err)
 }
 
+func (m *GormChangeFeedReadWrite) UpdateStartTS(ctx context.Context, taskId string, startTS int64) error {
+	existed, err := m.Get(ctx, taskId)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB(ctx).Model(existed).Update("start_ts", startTS).Error
+	return dbCommon.WrapDBError(err)
+}
+
 func (m *GormChangeFeedReadWrite) Get(ctx context.Context, taskId string) (*ChangeFeedTask, error) {
 	if "" == taskId {
 		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
@@ -152,3 +163,203 @@ func (m *GormChangeFeedReadWrite) Query(ctx context.Context, clusterId string, t
 
 	return tasks, total, dbCommon.WrapDBError(err)
 }
+
+func (m *GormChangeFeedReadWrite) QueryByStatus(ctx context.Context, statuses []constants.ChangeFeedStatus) (tasks []*ChangeFeedTask, err error) {
+	tasks = make([]*ChangeFeedTask, 0)
+
+	query := m.DB(ctx).Model(&ChangeFeedTask{}).Where("deleted_at is null")
+	if len(statuses) > 0 {
+		query = query.Where("status in ?", statuses)
+	}
+
+	err = query.Order("created_at").Find(&tasks).Error
+	return tasks, dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) UpdateHealth(ctx context.Context, task *ChangeFeedTask) error {
+	existed, err := m.Get(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB(ctx).Model(existed).
+		Updates(map[string]interface{}{
+			"checkpoint_lag":   task.CheckpointLag,
+			"lag_level":        task.LagLevel,
+			"checked_time":     task.CheckedTime,
+			"last_error":       task.LastError,
+			"resume_retries":   task.ResumeRetries,
+			"next_resume_time": task.NextResumeTime,
+		}).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) CreateEvent(ctx context.Context, event *ChangeFeedEvent) (*ChangeFeedEvent, error) {
+	if "" == event.TaskId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
+	}
+
+	err := m.DB(ctx).Create(event).Error
+	return event, dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) QueryEvents(ctx context.Context, taskId string, length int) (events []*ChangeFeedEvent, err error) {
+	if "" == taskId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
+	}
+
+	events = make([]*ChangeFeedEvent, 0)
+	query := m.DB(ctx).Model(&ChangeFeedEvent{}).Where("task_id = ?", taskId).Order("created_at desc")
+	if length > 0 {
+		query = query.Limit(length)
+	}
+	err = query.Find(&events).Error
+	return events, dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) CreateConsistencyCheck(ctx context.Context, check *ConsistencyCheck) (*ConsistencyCheck, error) {
+	if "" == check.TaskId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
+	}
+
+	err := m.DB(ctx).Create(check).Error
+	return check, dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) UpdateConsistencyCheck(ctx context.Context, check *ConsistencyCheck) error {
+	existed, err := m.GetConsistencyCheck(ctx, check.ID)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB(ctx).Model(existed).
+		Updates(map[string]interface{}{
+			"status":          check.Status,
+			"snapshot_mode":   check.SnapshotMode,
+			"upstream_tso":    check.UpstreamTSO,
+			"downstream_tso":  check.DownstreamTSO,
+			"checked_tables":  check.CheckedTables,
+			"mismatch_tables": check.MismatchTables,
+			"mismatch_chunks": check.MismatchChunks,
+			"result":          check.Result,
+			"fix_sql":         check.FixSQL,
+			"message":         check.Message,
+			"end_time":        check.EndTime,
+			"work_flow_id":    check.WorkFlowId,
+		}).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) GetConsistencyCheck(ctx context.Context, checkId string) (*ConsistencyCheck, error) {
+	if "" == checkId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "check id required")
+	}
+
+	check := &ConsistencyCheck{}
+	err := m.DB(ctx).First(check, "id = ?", checkId).Error
+	if err != nil {
+		return nil, errors.NewError(errors.TIUNIMANAGER_CONSISTENCY_CHECK_NOT_FOUND, fmt.Sprintf("check [%s]", checkId))
+	}
+	return check, nil
+}
+
+func (m *GormChangeFeedReadWrite) QueryConsistencyChecks(ctx context.Context, clusterId string, downstreamClusterId string, taskId string, statuses []constants.ConsistencyCheckStatus, offset int, length int) (checks []*ConsistencyCheck, total int64, err error) {
+	checks = make([]*ConsistencyCheck, 0)
+
+	query := m.DB(ctx).Model(&ConsistencyCheck{}).Where("deleted_at is null")
+	if clusterId != "" {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	if downstreamClusterId != "" {
+		query = query.Where("downstream_cluster_id = ?", downstreamClusterId)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	if len(statuses) > 0 {
+		query = query.Where("status in ?", statuses)
+	}
+
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, dbCommon.WrapDBError(err)
+	}
+	// result and fix sql may be large, they are only returned by GetConsistencyCheck
+	query = query.Omit("result", "fix_sql").Order("created_at desc").Offset(offset)
+	if length > 0 {
+		query = query.Limit(length)
+	}
+	err = query.Find(&checks).Error
+	return checks, total, dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) SaveConsistencySchedule(ctx context.Context, schedule *ConsistencySchedule) (*ConsistencySchedule, error) {
+	if "" == schedule.TaskId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
+	}
+
+	existed := &ConsistencySchedule{}
+	err := m.DB(ctx).Where("task_id = ?", schedule.TaskId).First(existed).Error
+	if err == gorm.ErrRecordNotFound {
+		err = m.DB(ctx).Create(schedule).Error
+		return schedule, dbCommon.WrapDBError(err)
+	} else if err != nil {
+		return nil, dbCommon.WrapDBError(err)
+	}
+
+	err = m.DB(ctx).Model(existed).
+		Updates(map[string]interface{}{
+			"cron_spec":           schedule.CronSpec,
+			"time_zone":           schedule.TimeZone,
+			"paused":              schedule.Paused,
+			"databases":           schedule.Databases,
+			"tables":              schedule.Tables,
+			"generate_fix_sql":    schedule.GenerateFixSQL,
+			"last_scheduled_time": schedule.LastScheduledTime,
+		}).Error
+	if err != nil {
+		return nil, dbCommon.WrapDBError(err)
+	}
+	return m.GetConsistencySchedule(ctx, schedule.TaskId)
+}
+
+func (m *GormChangeFeedReadWrite) GetConsistencySchedule(ctx context.Context, taskId string) (*ConsistencySchedule, error) {
+	if "" == taskId {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
+	}
+
+	schedule := &ConsistencySchedule{}
+	err := m.DB(ctx).First(schedule, "task_id = ?", taskId).Error
+	if err != nil {
+		return nil, errors.NewError(errors.TIUNIMANAGER_CONSISTENCY_SCHEDULE_NOT_FOUND, fmt.Sprintf("schedule of task [%s]", taskId))
+	}
+	return schedule, nil
+}
+
+func (m *GormChangeFeedReadWrite) DeleteConsistencySchedule(ctx context.Context, taskId string) error {
+	schedule, err := m.GetConsistencySchedule(ctx, taskId)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB(ctx).Delete(schedule).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) QueryConsistencySchedules(ctx context.Context) (schedules []*ConsistencySchedule, err error) {
+	schedules = make([]*ConsistencySchedule, 0)
+	err = m.DB(ctx).Model(&ConsistencySchedule{}).Where("deleted_at is null").Order("created_at").Find(&schedules).Error
+	return schedules, dbCommon.WrapDBError(err)
+}
+
+func (m *GormChangeFeedReadWrite) UpdateConsistencyScheduleRun(ctx context.Context, scheduleId string, scheduledTime time.Time, checkId string) error {
+	if "" == scheduleId {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "schedule id required")
+	}
+
+	fields := map[string]interface{}{"last_scheduled_time": scheduledTime}
+	if checkId != "" {
+		fields["last_check_id"] = checkId
+	}
+	err := m.DB(ctx).Model(&ConsistencySchedule{}).Where("id = ?", scheduleId).Updates(fields).Error
+	return dbCommon.WrapDBError(err)
+}
@@ -0,0 +1,62 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"time"
+
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+)
+
+// DMSource upstream MySQL instance registered in DM cluster
+type DMSource struct {
+	dbCommon.Entity
+	// DM cluster
+	ClusterId  string            `gorm:"not null;type:varchar(22);uniqueIndex:idx_dm_source_name"`
+	Name       string            `gorm:"not null;type:varchar(32);uniqueIndex:idx_dm_source_name"`
+	Host       string            `gorm:"not null;type:varchar(64)"`
+	Port       int               `gorm:"not null"`
+	User       string            `gorm:"type:varchar(64)"`
+	Password   dbCommon.Password `gorm:"size:256"`
+	EnableGTID bool              `gorm:"column:enable_gtid;default:false"`
+}
+
+// DMTask data migration task from sources of DM cluster to target cluster
+type DMTask struct {
+	dbCommon.Entity
+	// DM cluster
+	ClusterId       string `gorm:"not null;type:varchar(22);index"`
+	TargetClusterId string `gorm:"not null;type:varchar(22);index"`
+	// task name in dm-master, unique in unfinished tasks of DM cluster
+	Name     string `gorm:"not null;type:varchar(32)"`
+	TaskMode string `gorm:"not null;type:varchar(16)"`
+	// json of source names, block allow list and route rules
+	SourcesConfig        string `gorm:"type:text"`
+	BlockAllowListConfig string `gorm:"type:text"`
+	RoutesConfig         string `gorm:"type:text"`
+
+	// progress maintained by dm task watcher
+	Stage       string `gorm:"type:varchar(16)"`
+	Unit        string `gorm:"type:varchar(16)"`
+	Synced      bool   `gorm:"default:false"`
+	Lag         int64  `gorm:"default:0"` // seconds behind master, max of all sources
+	LastError   string `gorm:"type:text"`
+	CheckedTime time.Time
+
+	// replication which takes over after migration
+	HandoffType   string `gorm:"type:varchar(16)"`
+	HandoffTarget string `gorm:"type:varchar(64)"` // change feed task id or standby cluster id
+}
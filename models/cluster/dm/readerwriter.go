@@ -0,0 +1,119 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+)
+
+type ReaderWriter interface {
+	// CreateSource
+	// @Description: create a source of DM cluster, name of source is unique in DM cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter source
+	// @return *DMSource
+	// @return error
+	CreateSource(ctx context.Context, source *DMSource) (*DMSource, error)
+
+	// GetSource
+	// @Description: get source of DM cluster by name
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter name
+	// @return *DMSource
+	// @return error if source non-existent
+	GetSource(ctx context.Context, clusterId string, name string) (*DMSource, error)
+
+	// QuerySources
+	// @Description: query sources of DM cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @return sources
+	// @return err
+	QuerySources(ctx context.Context, clusterId string) (sources []*DMSource, err error)
+
+	// DeleteSource
+	// @Description: delete source of DM cluster by name
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter name
+	// @return error if source non-existent
+	DeleteSource(ctx context.Context, clusterId string, name string) error
+
+	// CreateTask
+	// @Description: create a data migration task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter task
+	// @return *DMTask
+	// @return error
+	CreateTask(ctx context.Context, task *DMTask) (*DMTask, error)
+
+	// GetTask
+	// @Description: get data migration task by id
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @return *DMTask
+	// @return error if task non-existent
+	GetTask(ctx context.Context, taskId string) (*DMTask, error)
+
+	// QueryTasks
+	// @Description: query data migration tasks, earliest first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId optional, tasks of all DM clusters if empty
+	// @Parameter statuses optional
+	// @Parameter offset
+	// @Parameter length all tasks if 0
+	// @return tasks
+	// @return total
+	// @return err
+	QueryTasks(ctx context.Context, clusterId string, statuses []constants.DMTaskStatus, offset int, length int) (tasks []*DMTask, total int64, err error)
+
+	// UpdateStatus
+	// @Description: update status of data migration task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @Parameter status
+	// @return error
+	UpdateStatus(ctx context.Context, taskId string, status constants.DMTaskStatus) error
+
+	// UpdateProgress
+	// @Description: update status, stage, unit, lag and error of data migration task
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter task
+	// @return error
+	UpdateProgress(ctx context.Context, task *DMTask) error
+
+	// UpdateHandoff
+	// @Description: record the replication which takes over after data migration
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter taskId
+	// @Parameter handoffType
+	// @Parameter handoffTarget
+	// @return error
+	UpdateHandoff(ctx context.Context, taskId string, handoffType constants.DMHandoffType, handoffTarget string) error
+}
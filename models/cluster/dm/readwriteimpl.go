@@ -0,0 +1,149 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"gorm.io/gorm"
+)
+
+type GormDMReadWrite struct {
+	dbCommon.GormDB
+}
+
+func NewGormDMReadWrite(db *gorm.DB) *GormDMReadWrite {
+	return &GormDMReadWrite{
+		dbCommon.WrapDB(db),
+	}
+}
+
+func (m *GormDMReadWrite) CreateSource(ctx context.Context, source *DMSource) (*DMSource, error) {
+	if len(source.ClusterId) == 0 || len(source.Name) == 0 {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster id and name of source are required")
+	}
+	err := m.DB(ctx).Create(source).Error
+	return source, dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) GetSource(ctx context.Context, clusterId string, name string) (*DMSource, error) {
+	source := &DMSource{}
+	err := m.DB(ctx).First(source, "cluster_id = ? AND name = ?", clusterId, name).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_DM_SOURCE_NOT_FOUND, "source %s of cluster %s", name, clusterId)
+	}
+	return source, dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) QuerySources(ctx context.Context, clusterId string) (sources []*DMSource, err error) {
+	sources = make([]*DMSource, 0)
+	err = m.DB(ctx).Model(&DMSource{}).Where("cluster_id = ?", clusterId).Order("created_at").Find(&sources).Error
+	return sources, dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) DeleteSource(ctx context.Context, clusterId string, name string) error {
+	// hard delete, so that the source can be registered again with the same name
+	result := m.DB(ctx).Unscoped().Where("cluster_id = ? AND name = ?", clusterId, name).Delete(&DMSource{})
+	if result.Error != nil {
+		return dbCommon.WrapDBError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewErrorf(errors.TIUNIMANAGER_DM_SOURCE_NOT_FOUND, "source %s of cluster %s", name, clusterId)
+	}
+	return nil
+}
+
+func (m *GormDMReadWrite) CreateTask(ctx context.Context, task *DMTask) (*DMTask, error) {
+	if len(task.ClusterId) == 0 || len(task.Name) == 0 {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster id and name of task are required")
+	}
+	err := m.DB(ctx).Create(task).Error
+	return task, dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) GetTask(ctx context.Context, taskId string) (*DMTask, error) {
+	if len(taskId) == 0 {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "task id required")
+	}
+	task := &DMTask{}
+	err := m.DB(ctx).First(task, "id = ?", taskId).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewErrorf(errors.TIUNIMANAGER_DM_TASK_NOT_FOUND, "task %s", taskId)
+	}
+	return task, dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) QueryTasks(ctx context.Context, clusterId string, statuses []constants.DMTaskStatus, offset int, length int) (tasks []*DMTask, total int64, err error) {
+	tasks = make([]*DMTask, 0)
+	query := m.DB(ctx).Model(&DMTask{})
+	if len(clusterId) > 0 {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	if len(statuses) > 0 {
+		query = query.Where("status in ?", statuses)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, dbCommon.WrapDBError(err)
+	}
+
+	query = query.Order("created_at").Offset(offset)
+	if length > 0 {
+		query = query.Limit(length)
+	}
+	err = query.Find(&tasks).Error
+	return tasks, total, dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) UpdateStatus(ctx context.Context, taskId string, status constants.DMTaskStatus) error {
+	task, err := m.GetTask(ctx, taskId)
+	if err != nil {
+		return err
+	}
+	err = m.DB(ctx).Model(task).Update("status", string(status)).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) UpdateProgress(ctx context.Context, task *DMTask) error {
+	existed, err := m.GetTask(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	err = m.DB(ctx).Model(existed).Updates(map[string]interface{}{
+		"status":       task.Status,
+		"stage":        task.Stage,
+		"unit":         task.Unit,
+		"synced":       task.Synced,
+		"lag":          task.Lag,
+		"last_error":   task.LastError,
+		"checked_time": task.CheckedTime,
+	}).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (m *GormDMReadWrite) UpdateHandoff(ctx context.Context, taskId string, handoffType constants.DMHandoffType, handoffTarget string) error {
+	task, err := m.GetTask(ctx, taskId)
+	if err != nil {
+		return err
+	}
+	err = m.DB(ctx).Model(task).Updates(map[string]interface{}{
+		"handoff_type":   string(handoffType),
+		"handoff_target": handoffTarget,
+	}).Error
+	return dbCommon.WrapDBError(err)
+}
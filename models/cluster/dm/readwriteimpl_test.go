@@ -0,0 +1,139 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/constants"
+	"github.com/pingcap/tiunimanager/common/errors"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGormDMReadWrite_Sources(t *testing.T) {
+	ctx := context.TODO()
+	created, err := testRW.CreateSource(ctx, &DMSource{Entity: dbCommon.Entity{TenantId: "tenant"}, ClusterId: "sourceCluster", Name: "mysql-01", Host: "127.0.0.1", Port: 3306, User: "root", Password: "my_password"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	_, err = testRW.CreateSource(ctx, &DMSource{Entity: dbCommon.Entity{TenantId: "tenant"}, ClusterId: "sourceCluster", Name: "mysql-02", Host: "127.0.0.2", Port: 3306})
+	assert.NoError(t, err)
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := testRW.CreateSource(ctx, &DMSource{Entity: dbCommon.Entity{TenantId: "tenant"}, ClusterId: "sourceCluster", Name: "mysql-01", Host: "127.0.0.3", Port: 3306})
+		assert.Error(t, err)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := testRW.CreateSource(ctx, &DMSource{ClusterId: "sourceCluster"})
+		assert.Error(t, err)
+	})
+	t.Run("get", func(t *testing.T) {
+		source, err := testRW.GetSource(ctx, "sourceCluster", "mysql-01")
+		assert.NoError(t, err)
+		assert.Equal(t, dbCommon.Password("my_password"), source.Password)
+
+		_, err = testRW.GetSource(ctx, "sourceCluster", "mysql-03")
+		assert.Equal(t, errors.TIUNIMANAGER_DM_SOURCE_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+	t.Run("query", func(t *testing.T) {
+		sources, err := testRW.QuerySources(ctx, "sourceCluster")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(sources))
+		assert.Equal(t, "mysql-01", sources[0].Name)
+	})
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, testRW.DeleteSource(ctx, "sourceCluster", "mysql-02"))
+		err := testRW.DeleteSource(ctx, "sourceCluster", "mysql-02")
+		assert.Equal(t, errors.TIUNIMANAGER_DM_SOURCE_NOT_FOUND, err.(errors.EMError).GetCode())
+
+		_, err = testRW.CreateSource(ctx, &DMSource{Entity: dbCommon.Entity{TenantId: "tenant"}, ClusterId: "sourceCluster", Name: "mysql-02", Host: "127.0.0.2", Port: 3306})
+		assert.NoError(t, err)
+	})
+}
+
+func TestGormDMReadWrite_Tasks(t *testing.T) {
+	ctx := context.TODO()
+	task, err := testRW.CreateTask(ctx, &DMTask{
+		Entity:          dbCommon.Entity{TenantId: "tenant", Status: string(constants.DMTaskStatusCreated)},
+		ClusterId:       "taskCluster",
+		TargetClusterId: "targetCluster",
+		Name:            "task01",
+		TaskMode:        string(constants.DMTaskModeAll),
+	})
+	assert.NoError(t, err)
+	_, err = testRW.CreateTask(ctx, &DMTask{
+		Entity:    dbCommon.Entity{TenantId: "tenant", Status: string(constants.DMTaskStatusStopped)},
+		ClusterId: "taskCluster", TargetClusterId: "targetCluster", Name: "task02", TaskMode: string(constants.DMTaskModeFull),
+	})
+	assert.NoError(t, err)
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := testRW.CreateTask(ctx, &DMTask{ClusterId: "taskCluster"})
+		assert.Error(t, err)
+	})
+	t.Run("get", func(t *testing.T) {
+		got, err := testRW.GetTask(ctx, task.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "task01", got.Name)
+
+		_, err = testRW.GetTask(ctx, "unknown")
+		assert.Equal(t, errors.TIUNIMANAGER_DM_TASK_NOT_FOUND, err.(errors.EMError).GetCode())
+		_, err = testRW.GetTask(ctx, "")
+		assert.Error(t, err)
+	})
+	t.Run("query", func(t *testing.T) {
+		tasks, total, err := testRW.QueryTasks(ctx, "taskCluster", nil, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, 2, len(tasks))
+
+		tasks, total, err = testRW.QueryTasks(ctx, "", constants.UnfinishedDMTaskStatus(), 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, task.ID, tasks[0].ID)
+
+		tasks, total, err = testRW.QueryTasks(ctx, "taskCluster", nil, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, "task02", tasks[0].Name)
+	})
+	t.Run("update", func(t *testing.T) {
+		assert.NoError(t, testRW.UpdateStatus(ctx, task.ID, constants.DMTaskStatusRunning))
+
+		checked := time.Now().Round(time.Second)
+		assert.NoError(t, testRW.UpdateProgress(ctx, &DMTask{
+			Entity: dbCommon.Entity{ID: task.ID, Status: string(constants.DMTaskStatusRunning)},
+			Stage:  "Running", Unit: constants.DMUnitSync, Synced: true, Lag: 3, CheckedTime: checked,
+		}))
+		assert.NoError(t, testRW.UpdateHandoff(ctx, task.ID, constants.DMHandoffChangeFeed, "changefeed01"))
+
+		got, err := testRW.GetTask(ctx, task.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, string(constants.DMTaskStatusRunning), got.Status)
+		assert.Equal(t, constants.DMUnitSync, got.Unit)
+		assert.True(t, got.Synced)
+		assert.Equal(t, int64(3), got.Lag)
+		assert.True(t, checked.Equal(got.CheckedTime))
+		assert.Equal(t, string(constants.DMHandoffChangeFeed), got.HandoffType)
+		assert.Equal(t, "changefeed01", got.HandoffTarget)
+
+		assert.Error(t, testRW.UpdateStatus(ctx, "unknown", constants.DMTaskStatusRunning))
+		assert.Error(t, testRW.UpdateProgress(ctx, &DMTask{Entity: dbCommon.Entity{ID: "unknown"}}))
+		assert.Error(t, testRW.UpdateHandoff(ctx, "unknown", constants.DMHandoffChangeFeed, ""))
+	})
+}
@@ -0,0 +1,60 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"time"
+
+	"github.com/pingcap/tiunimanager/util/uuidutil"
+	"gorm.io/gorm"
+)
+
+// GCHold keeps tidb_gc_life_time of cluster no less than GCLifeTime until it is released or expired
+type GCHold struct {
+	ID        string `gorm:"primarykey"`
+	ClusterId string `gorm:"not null;type:varchar(22);uniqueIndex:idx_gc_hold_name"`
+	// unique in cluster, holding with the same name renews the hold
+	Name string `gorm:"not null;type:varchar(64);uniqueIndex:idx_gc_hold_name"`
+	// who takes the hold, such as workflow id
+	Holder     string `gorm:"type:varchar(64)"`
+	GCLifeTime string `gorm:"not null;type:varchar(32)"`
+	ExpireTime time.Time
+	CreatedAt  time.Time `gorm:"<-:create"`
+	UpdatedAt  time.Time
+}
+
+func (h *GCHold) BeforeCreate(tx *gorm.DB) (err error) {
+	if len(h.ID) == 0 {
+		h.ID = uuidutil.ShortId()
+	}
+	return nil
+}
+
+// Expired
+// @Description: expired hold is ignored and removed by gc reconciler
+// @Receiver h
+// @Parameter now
+// @return bool
+func (h *GCHold) Expired(now time.Time) bool {
+	return !h.ExpireTime.After(now)
+}
+
+// GCBaseline tidb_gc_life_time of cluster before the first hold is taken, it is restored after all holds are released
+type GCBaseline struct {
+	ClusterId  string `gorm:"primarykey;type:varchar(22)"`
+	GCLifeTime string `gorm:"not null;type:varchar(32)"`
+	CreatedAt  time.Time `gorm:"<-:create"`
+}
@@ -0,0 +1,76 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+)
+
+type ReaderWriter interface {
+	// SaveHold
+	// @Description: create a gc hold, or renew the hold with the same cluster id and name
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter hold
+	// @return *GCHold
+	// @return error
+	SaveHold(ctx context.Context, hold *GCHold) (*GCHold, error)
+
+	// DeleteHold
+	// @Description: delete gc hold of cluster by name
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter name
+	// @return error if hold non-existent
+	DeleteHold(ctx context.Context, clusterId string, name string) error
+
+	// QueryHolds
+	// @Description: query gc holds, including expired ones, earliest first
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId optional, holds of all clusters if empty
+	// @return holds
+	// @return err
+	QueryHolds(ctx context.Context, clusterId string) (holds []*GCHold, err error)
+
+	// CreateBaselineIfAbsent
+	// @Description: record gc life time of cluster as baseline if there is no baseline of cluster
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @Parameter gcLifeTime
+	// @return *GCBaseline the existing baseline, or the created one
+	// @return error
+	CreateBaselineIfAbsent(ctx context.Context, clusterId string, gcLifeTime string) (*GCBaseline, error)
+
+	// QueryBaselines
+	// @Description: query gc baselines
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId optional, baselines of all clusters if empty
+	// @return baselines
+	// @return err
+	QueryBaselines(ctx context.Context, clusterId string) (baselines []*GCBaseline, err error)
+
+	// DeleteBaseline
+	// @Description: delete gc baseline of cluster after it is restored
+	// @Receiver m
+	// @Parameter ctx
+	// @Parameter clusterId
+	// @return error
+	DeleteBaseline(ctx context.Context, clusterId string) error
+}
@@ -0,0 +1,98 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+
+	"github.com/pingcap/tiunimanager/common/errors"
+	dbCommon "github.com/pingcap/tiunimanager/models/common"
+	"gorm.io/gorm"
+)
+
+type GormGCReadWrite struct {
+	dbCommon.GormDB
+}
+
+func NewGormGCReadWrite(db *gorm.DB) *GormGCReadWrite {
+	return &GormGCReadWrite{
+		dbCommon.WrapDB(db),
+	}
+}
+
+func (m *GormGCReadWrite) SaveHold(ctx context.Context, hold *GCHold) (*GCHold, error) {
+	if len(hold.ClusterId) == 0 || len(hold.Name) == 0 {
+		return nil, errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "cluster id and name of gc hold are required")
+	}
+
+	existed := &GCHold{}
+	err := m.DB(ctx).Where("cluster_id = ? AND name = ?", hold.ClusterId, hold.Name).First(existed).Error
+	if err == gorm.ErrRecordNotFound {
+		err = m.DB(ctx).Create(hold).Error
+		return hold, dbCommon.WrapDBError(err)
+	} else if err != nil {
+		return nil, dbCommon.WrapDBError(err)
+	}
+
+	err = m.DB(ctx).Model(existed).Updates(map[string]interface{}{
+		"holder":       hold.Holder,
+		"gc_life_time": hold.GCLifeTime,
+		"expire_time":  hold.ExpireTime,
+	}).Error
+	return existed, dbCommon.WrapDBError(err)
+}
+
+func (m *GormGCReadWrite) DeleteHold(ctx context.Context, clusterId string, name string) error {
+	result := m.DB(ctx).Where("cluster_id = ? AND name = ?", clusterId, name).Delete(&GCHold{})
+	if result.Error != nil {
+		return dbCommon.WrapDBError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewErrorf(errors.TIUNIMANAGER_GC_HOLD_NOT_FOUND, "gc hold %s of cluster %s", name, clusterId)
+	}
+	return nil
+}
+
+func (m *GormGCReadWrite) QueryHolds(ctx context.Context, clusterId string) (holds []*GCHold, err error) {
+	query := m.DB(ctx).Model(&GCHold{})
+	if len(clusterId) > 0 {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	err = query.Order("created_at").Find(&holds).Error
+	return holds, dbCommon.WrapDBError(err)
+}
+
+func (m *GormGCReadWrite) CreateBaselineIfAbsent(ctx context.Context, clusterId string, gcLifeTime string) (*GCBaseline, error) {
+	baseline := &GCBaseline{}
+	err := m.DB(ctx).Where(GCBaseline{ClusterId: clusterId}).
+		Attrs(GCBaseline{GCLifeTime: gcLifeTime}).
+		FirstOrCreate(baseline).Error
+	return baseline, dbCommon.WrapDBError(err)
+}
+
+func (m *GormGCReadWrite) QueryBaselines(ctx context.Context, clusterId string) (baselines []*GCBaseline, err error) {
+	query := m.DB(ctx).Model(&GCBaseline{})
+	if len(clusterId) > 0 {
+		query = query.Where("cluster_id = ?", clusterId)
+	}
+	err = query.Find(&baselines).Error
+	return baselines, dbCommon.WrapDBError(err)
+}
+
+func (m *GormGCReadWrite) DeleteBaseline(ctx context.Context, clusterId string) error {
+	err := m.DB(ctx).Where("cluster_id = ?", clusterId).Delete(&GCBaseline{}).Error
+	return dbCommon.WrapDBError(err)
+}
@@ -0,0 +1,101 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGCHold_Expired(t *testing.T) {
+	now := time.Now()
+	assert.True(t, (&GCHold{ExpireTime: now.Add(-time.Second)}).Expired(now))
+	assert.True(t, (&GCHold{ExpireTime: now}).Expired(now))
+	assert.False(t, (&GCHold{ExpireTime: now.Add(time.Second)}).Expired(now))
+}
+
+func TestGormGCReadWrite_Holds(t *testing.T) {
+	ctx := context.TODO()
+	expire := time.Now().Add(time.Hour).Round(time.Second)
+
+	created, err := testRW.SaveHold(ctx, &GCHold{ClusterId: "holdCluster", Name: "clone", Holder: "flow1", GCLifeTime: "24h", ExpireTime: expire})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	_, err = testRW.SaveHold(ctx, &GCHold{ClusterId: "holdCluster", Name: "backup", Holder: "flow2", GCLifeTime: "1h", ExpireTime: expire})
+	assert.NoError(t, err)
+	_, err = testRW.SaveHold(ctx, &GCHold{ClusterId: "otherCluster", Name: "clone", Holder: "flow3", GCLifeTime: "1h", ExpireTime: expire})
+	assert.NoError(t, err)
+
+	t.Run("renew", func(t *testing.T) {
+		renewed, err := testRW.SaveHold(ctx, &GCHold{ClusterId: "holdCluster", Name: "clone", Holder: "flow4", GCLifeTime: "48h", ExpireTime: expire.Add(time.Hour)})
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, renewed.ID)
+
+		holds, err := testRW.QueryHolds(ctx, "holdCluster")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(holds))
+		assert.Equal(t, "clone", holds[0].Name)
+		assert.Equal(t, "flow4", holds[0].Holder)
+		assert.Equal(t, "48h", holds[0].GCLifeTime)
+		assert.True(t, expire.Add(time.Hour).Equal(holds[0].ExpireTime))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := testRW.SaveHold(ctx, &GCHold{ClusterId: "holdCluster"})
+		assert.Error(t, err)
+	})
+	t.Run("query all", func(t *testing.T) {
+		holds, err := testRW.QueryHolds(ctx, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(holds))
+	})
+	t.Run("delete", func(t *testing.T) {
+		err := testRW.DeleteHold(ctx, "holdCluster", "backup")
+		assert.NoError(t, err)
+		holds, _ := testRW.QueryHolds(ctx, "holdCluster")
+		assert.Equal(t, 1, len(holds))
+
+		err = testRW.DeleteHold(ctx, "holdCluster", "backup")
+		assert.Error(t, err)
+		assert.Equal(t, errors.TIUNIMANAGER_GC_HOLD_NOT_FOUND, err.(errors.EMError).GetCode())
+	})
+}
+
+func TestGormGCReadWrite_Baselines(t *testing.T) {
+	ctx := context.TODO()
+
+	baseline, err := testRW.CreateBaselineIfAbsent(ctx, "baselineCluster", "10m0s")
+	assert.NoError(t, err)
+	assert.Equal(t, "10m0s", baseline.GCLifeTime)
+
+	// baseline is not overwritten by value modified by holds
+	baseline, err = testRW.CreateBaselineIfAbsent(ctx, "baselineCluster", "720h0m0s")
+	assert.NoError(t, err)
+	assert.Equal(t, "10m0s", baseline.GCLifeTime)
+
+	baselines, err := testRW.QueryBaselines(ctx, "baselineCluster")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(baselines))
+
+	err = testRW.DeleteBaseline(ctx, "baselineCluster")
+	assert.NoError(t, err)
+	baselines, err = testRW.QueryBaselines(ctx, "baselineCluster")
+	assert.NoError(t, err)
+	assert.Empty(t, baselines)
+}
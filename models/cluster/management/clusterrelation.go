@@ -27,4 +27,11 @@ type ClusterRelation struct {
 	SubjectClusterID     string                        `gorm:"not null;size:32"`
 	ObjectClusterID      string                        `gorm:"not null;size:32"`
 	SyncChangeFeedTaskID string                        `gorm:"not null;size:32;default:''"`
+
+	// automatic failover policy of standby relation, disarmed by switchover since relations are rebuilt
+	AutoFailover bool `gorm:"default:false"`
+	// consecutive confirmed failures of master before failover
+	FailoverThreshold int `gorm:"default:0"`
+	// seconds, standby lagging behind more than this is never chosen, 0 means unlimited
+	FailoverMaxLag int `gorm:"default:0"`
 }
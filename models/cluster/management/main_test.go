@@ -45,6 +45,7 @@ func TestMain(m *testing.M) {
 			}
 			db.Migrator().CreateTable(Cluster{})
 			db.Migrator().CreateTable(ClusterRelation{})
+			db.Migrator().CreateTable(FailoverEvent{})
 			db.Migrator().CreateTable(ClusterInstance{})
 			db.Migrator().CreateTable(ClusterTopologySnapshot{})
 			db.Migrator().CreateTable(DBUser{})
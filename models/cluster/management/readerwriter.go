@@ -17,6 +17,7 @@ package management
 
 import (
 	"context"
+	"time"
 
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/structs"
@@ -148,6 +149,48 @@ type ReaderWriter interface {
 	DeleteRelation(ctx context.Context, relationID uint) error
 	SwapMasterSlaveRelations(ctx context.Context, oldMasterClusterId, slaveToBeMasterClusterId string, newSlaveClusterIdMapToSyncCDCTaskId map[string]string) error
 
+	//
+	// QueryFailoverRelations
+	// @Description: get standby relations with automatic failover enabled
+	// @param ctx
+	// @return []*ClusterRelation
+	// @return error
+	//
+	QueryFailoverRelations(ctx context.Context) ([]*ClusterRelation, error)
+
+	//
+	// UpdateFailoverPolicy
+	// @Description: update automatic failover policy of relation
+	// @param ctx
+	// @param relation
+	// @return error
+	//
+	UpdateFailoverPolicy(ctx context.Context, relation *ClusterRelation) error
+
+	CreateFailoverEvent(ctx context.Context, event *FailoverEvent) error
+
+	//
+	// QueryFailoverEvents
+	// @Description: get latest failover events of cluster as master or standby, newest first
+	// @param ctx
+	// @param clusterID
+	// @param length, all events are returned if 0
+	// @return []*FailoverEvent
+	// @return error
+	//
+	QueryFailoverEvents(ctx context.Context, clusterID string, length int) ([]*FailoverEvent, error)
+
+	//
+	// CountFailovers
+	// @Description: count failovers triggered or failed since specified time, which involve any of clusters
+	// @param ctx
+	// @param clusterIDs
+	// @param since
+	// @return int64
+	// @return error
+	//
+	CountFailovers(ctx context.Context, clusterIDs []string, since time.Time) (int64, error)
+
 	CreateClusterTopologySnapshot(ctx context.Context, snapshot ClusterTopologySnapshot) error
 	GetCurrentClusterTopologySnapshot(ctx context.Context, clusterID string) (ClusterTopologySnapshot, error)
 	UpdateTopologySnapshotConfig(ctx context.Context, clusterID string, config string) error
@@ -18,6 +18,7 @@ package management
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
@@ -148,6 +149,13 @@ func (g *ClusterReadWrite) RelationsResetSyncChangeFeedTaskIDs(ctx context.Conte
 		if err != nil {
 			return err
 		}
+		oneWaySlaves := make([]*ClusterRelation, 0, len(slaves))
+		for _, rel := range slaves {
+			if rel.RelationType.IsOneWay() {
+				oneWaySlaves = append(oneWaySlaves, rel)
+			}
+		}
+		slaves = oneWaySlaves
 		if len(slaves) != len(slavesClusterIDMapToSyncTaskID) {
 			return fmt.Errorf("len(slaves) != len(slavesClusterIDMapToSyncTaskID): %d != %d", len(slaves), len(slavesClusterIDMapToSyncTaskID))
 		}
@@ -460,6 +468,57 @@ func (g *ClusterReadWrite) DeleteRelation(ctx context.Context, relationID uint)
 	return dbCommon.WrapDBError(err)
 }
 
+func (g *ClusterReadWrite) QueryFailoverRelations(ctx context.Context) ([]*ClusterRelation, error) {
+	relations := make([]*ClusterRelation, 0)
+	err := g.DB(ctx).Model(&ClusterRelation{}).
+		Where("relation_type = ?", string(constants.ClusterRelationStandBy)).
+		Where("auto_failover = ?", true).
+		Find(&relations).Error
+	return relations, dbCommon.WrapDBError(err)
+}
+
+func (g *ClusterReadWrite) UpdateFailoverPolicy(ctx context.Context, relation *ClusterRelation) error {
+	if relation.ID == 0 {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "relation id required")
+	}
+	err := g.DB(ctx).Model(&ClusterRelation{}).Where("id = ?", relation.ID).Updates(map[string]interface{}{
+		"auto_failover":      relation.AutoFailover,
+		"failover_threshold": relation.FailoverThreshold,
+		"failover_max_lag":   relation.FailoverMaxLag,
+	}).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (g *ClusterReadWrite) CreateFailoverEvent(ctx context.Context, event *FailoverEvent) error {
+	if "" == event.MasterClusterID {
+		return errors.NewError(errors.TIUNIMANAGER_PARAMETER_INVALID, "master cluster id required")
+	}
+	err := g.DB(ctx).Create(event).Error
+	return dbCommon.WrapDBError(err)
+}
+
+func (g *ClusterReadWrite) QueryFailoverEvents(ctx context.Context, clusterID string, length int) ([]*FailoverEvent, error) {
+	events := make([]*FailoverEvent, 0)
+	query := g.DB(ctx).Model(&FailoverEvent{}).
+		Where("master_cluster_id = ? OR standby_cluster_id = ?", clusterID, clusterID).
+		Order("id desc")
+	if length > 0 {
+		query = query.Limit(length)
+	}
+	err := query.Find(&events).Error
+	return events, dbCommon.WrapDBError(err)
+}
+
+func (g *ClusterReadWrite) CountFailovers(ctx context.Context, clusterIDs []string, since time.Time) (int64, error) {
+	var count int64
+	err := g.DB(ctx).Model(&FailoverEvent{}).
+		Where("type IN ?", []string{string(constants.FailoverEventTriggered), string(constants.FailoverEventFailed)}).
+		Where("created_at > ?", since).
+		Where("master_cluster_id IN ? OR standby_cluster_id IN ?", clusterIDs, clusterIDs).
+		Count(&count).Error
+	return count, dbCommon.WrapDBError(err)
+}
+
 func (g *ClusterReadWrite) SwapMasterSlaveRelations(ctx context.Context, oldMasterClusterId, slaveToBeMasterClusterId string, newSlaveClusterIdMapToSyncCDCTaskId map[string]string) error {
 	tx := g.DB(ctx).Begin()
 	if err := tx.Error; err != nil {
@@ -468,7 +527,7 @@ func (g *ClusterReadWrite) SwapMasterSlaveRelations(ctx context.Context, oldMast
 	relations := make([]*ClusterRelation, 0)
 	err := tx.Model(&ClusterRelation{}).
 		Where("subject_cluster_id  = ? ", oldMasterClusterId).
-		Where("relation_type  = ? ", string(constants.ClusterRelationStandBy)).
+		Where("relation_type IN ? ", []string{string(constants.ClusterRelationStandBy), string(constants.ClusterRelationCascade)}).
 		Find(&relations).Error
 	if err != nil {
 		err = dbCommon.WrapDBError(err)
@@ -533,6 +592,16 @@ func (g *ClusterReadWrite) SwapMasterSlaveRelations(ctx context.Context, oldMast
 			return err
 		}
 	}
+	// downstreams of new master were cascades of old master, now they are standbys of the root
+	err = tx.Model(&ClusterRelation{}).
+		Where("subject_cluster_id = ?", slaveToBeMasterClusterId).
+		Where("relation_type = ?", string(constants.ClusterRelationCascade)).
+		Update("relation_type", string(constants.ClusterRelationStandBy)).Error
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("gorm SwapMasterSlaveRelations update cascade relations failed, %s", err)
+		tx.Rollback()
+		return dbCommon.WrapDBError(err)
+	}
 
 	return dbCommon.WrapDBError(tx.Commit().Error)
 }
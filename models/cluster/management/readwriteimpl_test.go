@@ -19,12 +19,15 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/pingcap/tiunimanager/common/constants"
 	"github.com/pingcap/tiunimanager/common/errors"
 	"github.com/pingcap/tiunimanager/common/structs"
 	"github.com/pingcap/tiunimanager/models/common"
+	"github.com/pingcap/tiunimanager/util/uuidutil"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestGormClusterReadWrite_MaintenanceStatus(t *testing.T) {
@@ -815,6 +818,111 @@ func TestClusterReadWrite_Relations(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestClusterReadWrite_SwapCascadeRelations(t *testing.T) {
+	// cascade_a -> cascade_b -> cascade_c, cascade_a <-> cascade_d
+	relations := []*ClusterRelation{
+		{SubjectClusterID: "cascade_a", ObjectClusterID: "cascade_b", RelationType: constants.ClusterRelationStandBy, SyncChangeFeedTaskID: "task_ab"},
+		{SubjectClusterID: "cascade_b", ObjectClusterID: "cascade_c", RelationType: constants.ClusterRelationCascade, SyncChangeFeedTaskID: "task_bc"},
+		{SubjectClusterID: "cascade_a", ObjectClusterID: "cascade_d", RelationType: constants.ClusterRelationBidirectional, SyncChangeFeedTaskID: "task_ad"},
+		{SubjectClusterID: "cascade_d", ObjectClusterID: "cascade_a", RelationType: constants.ClusterRelationBidirectional, SyncChangeFeedTaskID: "task_da"},
+	}
+	for _, relation := range relations {
+		assert.NoError(t, testRW.CreateRelation(context.TODO(), relation))
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		err := testRW.RelationsResetSyncChangeFeedTaskIDs(context.TODO(), "cascade_a", map[string]string{"cascade_b": "task_ab2"})
+		assert.NoError(t, err)
+		r, err := testRW.GetMasters(context.TODO(), "cascade_b")
+		assert.NoError(t, err)
+		assert.Equal(t, "task_ab2", r[0].SyncChangeFeedTaskID)
+	})
+	t.Run("swap", func(t *testing.T) {
+		err := testRW.SwapMasterSlaveRelations(context.TODO(), "cascade_a", "cascade_b", map[string]string{"cascade_a": "task_ba"})
+		assert.NoError(t, err)
+
+		r, err := testRW.GetSlaves(context.TODO(), "cascade_b")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(r))
+		for _, relation := range r {
+			assert.Equal(t, constants.ClusterRelationStandBy, relation.RelationType)
+		}
+		// bidirectional relations are not touched
+		r, err = testRW.GetSlaves(context.TODO(), "cascade_a")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(r))
+		assert.Equal(t, constants.ClusterRelationBidirectional, r[0].RelationType)
+	})
+}
+
+func TestClusterReadWrite_FailoverPolicy(t *testing.T) {
+	relation := &ClusterRelation{
+		ObjectClusterID:      "failover_standby",
+		SubjectClusterID:     "failover_master",
+		RelationType:         constants.ClusterRelationStandBy,
+		SyncChangeFeedTaskID: "task01",
+	}
+	assert.NoError(t, testRW.CreateRelation(context.TODO(), relation))
+	defer testRW.DeleteRelation(context.TODO(), relation.ID)
+
+	assert.Error(t, testRW.UpdateFailoverPolicy(context.TODO(), &ClusterRelation{AutoFailover: true}))
+	assert.NoError(t, testRW.UpdateFailoverPolicy(context.TODO(), &ClusterRelation{
+		Model:             gorm.Model{ID: relation.ID},
+		AutoFailover:      true,
+		FailoverThreshold: 5,
+		FailoverMaxLag:    60,
+	}))
+
+	relations, err := testRW.QueryFailoverRelations(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(relations))
+	assert.Equal(t, "failover_standby", relations[0].ObjectClusterID)
+	assert.Equal(t, 5, relations[0].FailoverThreshold)
+	assert.Equal(t, 60, relations[0].FailoverMaxLag)
+
+	assert.NoError(t, testRW.UpdateFailoverPolicy(context.TODO(), &ClusterRelation{Model: gorm.Model{ID: relation.ID}}))
+	relations, err = testRW.QueryFailoverRelations(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, relations)
+}
+
+func TestClusterReadWrite_FailoverEvents(t *testing.T) {
+	assert.Error(t, testRW.CreateFailoverEvent(context.TODO(), &FailoverEvent{Type: constants.FailoverEventProbeFailed}))
+
+	master, standby := "master_"+uuidutil.ShortId(), "standby_"+uuidutil.ShortId()
+	start := time.Now().Add(-time.Second)
+	for _, event := range []*FailoverEvent{
+		{MasterClusterID: master, Type: constants.FailoverEventProbeFailed},
+		{MasterClusterID: master, StandbyClusterID: standby, Type: constants.FailoverEventTriggered, WorkFlowID: "flow01"},
+		{MasterClusterID: "another_" + master, StandbyClusterID: "another_" + standby, Type: constants.FailoverEventFailed},
+		{MasterClusterID: "another_" + master, StandbyClusterID: "another_" + standby, Type: constants.FailoverEventSkipped},
+	} {
+		assert.NoError(t, testRW.CreateFailoverEvent(context.TODO(), event))
+	}
+
+	events, err := testRW.QueryFailoverEvents(context.TODO(), master, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, constants.FailoverEventTriggered, events[0].Type)
+
+	events, err = testRW.QueryFailoverEvents(context.TODO(), standby, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "flow01", events[0].WorkFlowID)
+
+	count, err := testRW.CountFailovers(context.TODO(), []string{standby, "unknown"}, start)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = testRW.CountFailovers(context.TODO(), []string{master, "another_" + standby}, start)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = testRW.CountFailovers(context.TODO(), []string{master}, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
 func TestClusterReadWrite_QueryInstancesByHost(t *testing.T) {
 	got, _ := testRW.Create(context.TODO(), &Cluster{
 		Name: "testQueryInstance",
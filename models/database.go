@@ -23,6 +23,8 @@ import (
 	"github.com/pingcap/tiunimanager/library/framework"
 	"github.com/pingcap/tiunimanager/models/cluster/backuprestore"
 	"github.com/pingcap/tiunimanager/models/cluster/changefeed"
+	"github.com/pingcap/tiunimanager/models/cluster/dm"
+	"github.com/pingcap/tiunimanager/models/cluster/gc"
 	"github.com/pingcap/tiunimanager/models/cluster/management"
 	"github.com/pingcap/tiunimanager/models/cluster/parameter"
 	"github.com/pingcap/tiunimanager/models/cluster/upgrade"
@@ -57,6 +59,8 @@ type database struct {
 	importExportReaderWriter         importexport.ReaderWriter
 	brReaderWriter                   backuprestore.ReaderWriter
 	changeFeedReaderWriter           changefeed.ReaderWriter
+	gcReaderWriter                   gc.ReaderWriter
+	dmReaderWriter                   dm.ReaderWriter
 	upgradeReadWriter                upgrade.ReaderWriter
 	clusterReaderWriter              management.ReaderWriter
 	parameterGroupReaderWriter       parametergroup.ReaderWriter
@@ -188,17 +192,30 @@ func (p *database) migrateTables() (err error) {
 		new(system.SystemInfo),
 		new(system.VersionInfo),
 		new(changefeed.ChangeFeedTask),
+		new(changefeed.ChangeFeedEvent),
+		new(changefeed.ConsistencyCheck),
+		new(changefeed.ConsistencySchedule),
+		new(gc.GCHold),
+		new(gc.GCBaseline),
+		new(dm.DMSource),
+		new(dm.DMTask),
 		new(workflow.WorkFlow),
 		new(workflow.WorkFlowNode),
 		new(upgrade.ProductUpgradePath),
 		new(management.Cluster),
 		new(management.ClusterInstance),
 		new(management.ClusterRelation),
+		new(management.FailoverEvent),
 		new(management.ClusterTopologySnapshot),
 		new(management.DBUser),
 		new(importexport.DataTransportRecord),
 		new(backuprestore.BackupRecord),
 		new(backuprestore.BackupStrategy),
+		new(backuprestore.LogBackupTask),
+		new(backuprestore.BackupSchedule),
+		new(backuprestore.BackupKey),
+		new(backuprestore.BackupStorageTarget),
+		new(backuprestore.BackupLocation),
 		new(config.SystemConfig),
 		new(secondparty.SecondPartyOperation),
 		new(parametergroup.Parameter),
@@ -229,6 +246,8 @@ func (p *database) migrateTables() (err error) {
 
 func (p *database) initReaderWriters() {
 	defaultDb.changeFeedReaderWriter = changefeed.NewGormChangeFeedReadWrite(defaultDb.base)
+	defaultDb.gcReaderWriter = gc.NewGormGCReadWrite(defaultDb.base)
+	defaultDb.dmReaderWriter = dm.NewGormDMReadWrite(defaultDb.base)
 	defaultDb.workFlowReaderWriter = workflow.NewFlowReadWrite(defaultDb.base)
 	defaultDb.importExportReaderWriter = importexport.NewImportExportReadWrite(defaultDb.base)
 	defaultDb.brReaderWriter = backuprestore.NewBRReadWrite(defaultDb.base)
@@ -256,6 +275,22 @@ func SetChangeFeedReaderWriter(rw changefeed.ReaderWriter) {
 	defaultDb.changeFeedReaderWriter = rw
 }
 
+func GetGCReaderWriter() gc.ReaderWriter {
+	return defaultDb.gcReaderWriter
+}
+
+func SetGCReaderWriter(rw gc.ReaderWriter) {
+	defaultDb.gcReaderWriter = rw
+}
+
+func GetDMReaderWriter() dm.ReaderWriter {
+	return defaultDb.dmReaderWriter
+}
+
+func SetDMReaderWriter(rw dm.ReaderWriter) {
+	defaultDb.dmReaderWriter = rw
+}
+
 func GetWorkFlowReaderWriter() workflow.ReaderWriter {
 	return defaultDb.workFlowReaderWriter
 }
@@ -49,4 +49,7 @@ type VendorSpec struct {
 	Memory      int    `gorm:"comment: unit: GiB"`
 	DiskType    string `gorm:"comment:NVMeSSD/SSD/SATA"`
 	PurposeType string `gorm:"comment:Compute/Storage/Schedule"`
+	// UnitPrice and ResourceWeight are optional, 0 means the spec is not priced or weighted
+	UnitPrice      float64 `gorm:"default:0;comment: monthly price of an instance of the spec"`
+	ResourceWeight float64 `gorm:"default:0;comment: weight of the pool capacity consumed by an instance of the spec, 0 is treated as 1"`
 }
@@ -263,6 +263,7 @@ func (rw *GormResourceReadWrite) allocResourceInHost(ctx context.Context, tx *go
 	totalRequireMemory := reqMem * require.Count
 	exclusive := require.Require.Exclusive
 	isTakeOver := applicant.TakeoverOperation
+	reservedAllowed := isTakeOver || applicant.ReservedHostsAllowed
 
 	needDisk := require.Require.DiskReq.NeedDisk
 	diskSpecify := require.Require.DiskReq.DiskSpecify
@@ -276,8 +277,8 @@ func (rw *GormResourceReadWrite) allocResourceInHost(ctx context.Context, tx *go
 		db := tx.Order("disks.capacity").Limit(int(require.Count)).Model(&rp.Disk{}).Select(
 			"disks.host_id, hosts.host_name, hosts.region, hosts.az, hosts.rack, hosts.ip, hosts.user_name, hosts.passwd, ? as cpu_cores, ? as memory, disks.id as disk_id, disks.name as disk_name, disks.path, disks.capacity", reqCores, reqMem).Joins(
 			"left join hosts on disks.host_id = hosts.id").Where("hosts.ip = ?", hostIp).Count(&count)
-		// No Limit in Reserved == false in this strategy for a takeover operation
-		if !isTakeOver {
+		// No Limit in Reserved == false in this strategy for a takeover operation or a reserved hosts allowed applicant
+		if !reservedAllowed {
 			db = db.Where("hosts.reserved = 0").Count(&count)
 		}
 
@@ -314,8 +315,8 @@ func (rw *GormResourceReadWrite) allocResourceInHost(ctx context.Context, tx *go
 		}
 	} else {
 		db := tx.Model(&rp.Host{}).Select("id as host_id, host_name, region, az, rack, ip, user_name, passwd, ? as cpu_cores, ? as memory", reqCores, reqMem).Where("ip = ?", hostIp).Count(&count)
-		// No Limit in Reserved == false in this strategy for a takeover operation
-		if !isTakeOver {
+		// No Limit in Reserved == false in this strategy for a takeover operation or a reserved hosts allowed applicant
+		if !reservedAllowed {
 			db = db.Where("hosts.reserved = 0").Count(&count)
 		}
 		if count < 1 {
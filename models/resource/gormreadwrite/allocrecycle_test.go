@@ -548,6 +548,47 @@ func TestAllocResources_SpecifyHost_Strategy_TakeOver(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAllocResources_SpecifyHost_Strategy_ReservedHostsAllowed(t *testing.T) {
+	id1, _ := createTestHost("Test_Region1", "Test_Region1,Test_Zone4", "Test_Region1,Test_Zon4,Test_Rack1", "Test_Host2", "474.111.111.148",
+		string(constants.EMProductIDTiDB), string(constants.PurposeCompute), string(constants.SSD), 17, 64, 3)
+
+	err := GormRW.UpdateHostReserved(context.TODO(), id1, true)
+	assert.Equal(t, nil, err)
+
+	loc1 := structs.Location{}
+	loc1.Region = "Test_Region1"
+	loc1.Zone = "Test_Zone4"
+	loc1.HostIp = "474.111.111.148"
+
+	require1 := newRequirementForRequest(4, 8, true, 256, string(constants.SSD), 10000, 10015, 5)
+
+	var test_req resource_structs.AllocReq
+	test_req.Applicant.HolderId = "TestCluster1"
+	test_req.Applicant.RequestId = "TestRequestID2"
+	test_req.Requires = append(test_req.Requires, resource_structs.AllocRequirement{
+		Location: loc1,
+		Strategy: resource_structs.UserSpecifyHost,
+		Require:  *require1,
+		Count:    1,
+	})
+
+	var batchReq resource_structs.BatchAllocRequest
+	batchReq.BatchRequests = append(batchReq.BatchRequests, test_req)
+
+	rsp, err := GormRW.AllocResources(context.TODO(), &batchReq)
+	assert.True(t, nil == rsp && err != nil)
+
+	batchReq.BatchRequests[0].Applicant.ReservedHostsAllowed = true
+	rsp, err = GormRW.AllocResources(context.TODO(), &batchReq)
+	assert.Equal(t, nil, err)
+	assert.True(t, rsp.BatchResults[0].Results[0].HostId == id1[0])
+
+	err = recycleRequestResources("TestRequestID2")
+	assert.Nil(t, err)
+	err = GormRW.Delete(context.TODO(), id1)
+	assert.Nil(t, err)
+}
+
 func Test_AllocResources_ClusterPorts_Strategy(t *testing.T) {
 	var ids []string
 	id1, _ := createTestHost("Test_Region29", "Test_Region29,Zone5", "Test_Region29,Zone5,Rack1", "HostName1", "429.111.111.137", string(constants.EMProductIDTiDB), string(constants.PurposeCompute), string(constants.SSD), 17, 64, 3)
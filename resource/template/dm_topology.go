@@ -0,0 +1,90 @@
+/******************************************************************************
+ * Copyright (c)  2021 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package template
+
+var DMClusterTopology = `
+{{ if eq .Cluster.Status "Initializing" }}
+global:
+  user: {{ .GlobalUser }}
+  group: {{ .GlobalGroup }}
+  ssh_port: {{ .GlobalSSHPort }}
+  deploy_dir: {{ .Cluster.ID }}/dm-deploy
+  data_dir: {{ .Cluster.ID }}/dm-data
+  log_dir: {{ .Cluster.ID }}/dm-log
+  os: linux
+  {{ if eq .Cluster.CpuArchitecture "ARM64" }}
+  arch: arm64
+  {{ else }}
+  arch: amd64
+  {{ end }}
+{{ end }}
+{{ range $key, $instances := .Instances }}
+{{ if and (eq $key "DMMaster") (len $instances) }}
+master_servers:
+  {{ range $instances }}
+  {{ if eq .Status "Initializing" }}
+  - host: {{ index .HostIP 0 }}
+    port: {{ index .Ports 0 }}
+    peer_port: {{ index .Ports 1 }}
+    name: {{ .ID }}
+    deploy_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/dm-master-deploy
+    data_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/dm-master-data
+  {{ end }}
+  {{ end }}
+{{ else if and (eq $key "DMWorker") (len $instances) }}
+worker_servers:
+  {{ range $instances }}
+  {{ if eq .Status "Initializing" }}
+  - host: {{ index .HostIP 0 }}
+    port: {{ index .Ports 0 }}
+    name: {{ .ID }}
+    deploy_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/dm-worker-deploy
+    data_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/dm-worker-data
+  {{ end }}
+  {{ end }}
+{{ else if and (eq $key "Grafana") (len $instances) }}
+grafana_servers:
+  {{ range $instances }}
+  {{ if eq .Status "Initializing" }}
+  - host: {{ index .HostIP 0 }}
+    port: {{ index .Ports 0}}
+    deploy_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/grafana-deploy
+  {{ end }}
+  {{ end }}
+{{ else if and (eq $key "Prometheus") (len $instances) }}
+monitoring_servers:
+  {{ range $instances }}
+  {{ if eq .Status "Initializing" }}
+  - host: {{ index .HostIP 0 }}
+    port: {{ index .Ports 0}}
+    deploy_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/prometheus-deploy
+    data_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/prometheus-data
+  {{ end }}
+  {{ end }}
+{{ else if and (eq $key "AlertManger") (len $instances) }}
+alertmanager_servers:
+  {{ range $instances }}
+  {{ if eq .Status "Initializing" }}
+  - host: {{ index .HostIP 0 }}
+    web_port: {{ index .Ports 0}}
+    cluster_port: {{ index .Ports 1}}
+    deploy_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/alertmanager-deploy
+    data_dir: {{ .DiskPath }}/{{ $.Cluster.ID }}/alertmanager-data
+  {{ end }}
+  {{ end }}
+{{ end }}
+{{ end }}
+`
@@ -42,26 +42,47 @@ const (
 )
 
 type ChangeFeedCreateReq struct {
-	CDCAddress       string   `json:"-"`
-	ChangeFeedID     string   `json:"changefeed_id"`
-	SinkURI          string   `json:"sink_uri"`
-	StartTS          uint64   `json:"start_ts"`
-	TargetTS         uint64   `json:"target_ts"`
-	IgnoreTxnStartTS []uint64 `json:"ignore_txn_start_ts"`
-	FilterRules      []string `json:"filter_rules"`
-	SinkConfig       []string `json:"sink_config"`
-	MounterWorkerNum int      `json:"mounter_worker_num"`
+	CDCAddress       string      `json:"-"`
+	ChangeFeedID     string      `json:"changefeed_id"`
+	SinkURI          string      `json:"sink_uri"`
+	StartTS          uint64      `json:"start_ts"`
+	TargetTS         uint64      `json:"target_ts"`
+	IgnoreTxnStartTS []uint64    `json:"ignore_txn_start_ts"`
+	FilterRules      []string    `json:"filter_rules"`
+	SinkConfig       *SinkConfig `json:"sink_config,omitempty"`
+	MounterWorkerNum int         `json:"mounter_worker_num"`
+	// record sync points in TiDB downstream, which are consistent snapshots of upstream and downstream
+	SyncPointEnabled bool `json:"sync_point_enabled,omitempty"`
 }
 
 type ChangeFeedUpdateReq struct {
-	ChangeFeedID     string   `json:"-"`
-	CDCAddress       string   `json:"-"`
-	SinkURI          string   `json:"sink_uri"`
-	TargetTS         int64    `json:"target_ts"`
-	FilterRules      []string `json:"filter_rules"`
-	IgnoreTxnStartTS []uint64 `json:"ignore_txn_start_ts"`
-	SinkConfig       []string `json:"sink_config"`
-	MounterWorkerNum int      `json:"mounter_worker_num"`
+	ChangeFeedID     string      `json:"-"`
+	CDCAddress       string      `json:"-"`
+	SinkURI          string      `json:"sink_uri"`
+	TargetTS         int64       `json:"target_ts"`
+	FilterRules      []string    `json:"filter_rules"`
+	IgnoreTxnStartTS []uint64    `json:"ignore_txn_start_ts"`
+	SinkConfig       *SinkConfig `json:"sink_config,omitempty"`
+	MounterWorkerNum int         `json:"mounter_worker_num"`
+}
+
+// SinkConfig sink config of TiCDC, which replaces the whole sink config of change feed
+type SinkConfig struct {
+	Protocol        string           `json:"protocol"`
+	SchemaRegistry  string           `json:"schema-registry,omitempty"`
+	DispatchRules   []DispatchRule   `json:"dispatchers,omitempty"`
+	ColumnSelectors []ColumnSelector `json:"column-selectors,omitempty"`
+}
+
+type DispatchRule struct {
+	Matcher       []string `json:"matcher"`
+	PartitionRule string   `json:"partition,omitempty"`
+	TopicRule     string   `json:"topic,omitempty"`
+}
+
+type ColumnSelector struct {
+	Matcher []string `json:"matcher"`
+	Columns []string `json:"columns"`
 }
 
 type ChangeFeedPauseReq struct {
@@ -46,7 +46,7 @@ func TestChangeFeedServiceImpl_CreateChangeFeedTask(t *testing.T) {
 			name: "normal",
 			args: args{
 				ctx: context.TODO(),
-				req: ChangeFeedCreateReq{CDCAddress: cdcAddress, IgnoreTxnStartTS: []uint64{}, FilterRules: []string{}, SinkConfig: []string{}},
+				req: ChangeFeedCreateReq{CDCAddress: cdcAddress, IgnoreTxnStartTS: []uint64{}, FilterRules: []string{}, SinkConfig: &SinkConfig{}},
 			},
 			wantResp: ChangeFeedCmdAcceptResp{
 				Accepted: true,
@@ -92,7 +92,7 @@ func TestChangeFeedServiceImpl_UpdateChangeFeedTask(t *testing.T) {
 
 	cdcAddress := fmt.Sprintf("%s:%d", host, port)
 	t.Run("normal", func(t *testing.T) {
-		gotResp, err := CDCService.UpdateChangeFeedTask(context.TODO(), ChangeFeedUpdateReq {CDCAddress: cdcAddress, IgnoreTxnStartTS: []uint64{}, FilterRules: []string{}, SinkConfig: []string{}})
+		gotResp, err := CDCService.UpdateChangeFeedTask(context.TODO(), ChangeFeedUpdateReq {CDCAddress: cdcAddress, IgnoreTxnStartTS: []uint64{}, FilterRules: []string{}, SinkConfig: &SinkConfig{}})
 		asserts.NoError(t, err)
 		asserts.True(t, gotResp.Accepted)
 		asserts.True(t, gotResp.Succeed)
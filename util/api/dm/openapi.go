@@ -0,0 +1,215 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+	util "github.com/pingcap/tiunimanager/util/http"
+)
+
+const (
+	DMSourceApiUrl = "/api/v1/sources"
+	DMTaskApiUrl   = "/api/v1/tasks"
+)
+
+// Source upstream MySQL instance of DM cluster
+type Source struct {
+	SourceName string `json:"source_name"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	User       string `json:"user"`
+	Password   string `json:"password"`
+	EnableGTID bool   `json:"enable_gtid"`
+	Enable     bool   `json:"enable"`
+}
+
+type CreateSourceReq struct {
+	DMMasterAddress string `json:"-"`
+	Source          Source `json:"source"`
+}
+
+type DeleteSourceReq struct {
+	DMMasterAddress string
+	SourceName      string
+}
+
+type CreateTaskReq struct {
+	DMMasterAddress string
+	// task config file in yaml, it is converted to task of OpenAPI by dm-master
+	TaskConfigFile string
+}
+
+type TaskCmdReq struct {
+	DMMasterAddress string
+	TaskName        string
+}
+
+type QueryTaskStatusReq struct {
+	DMMasterAddress string
+	TaskName        string
+}
+
+type QueryTaskStatusResp struct {
+	Total int             `json:"total"`
+	Data  []SubTaskStatus `json:"data"`
+}
+
+// SubTaskStatus status of task on one source
+type SubTaskStatus struct {
+	Name       string      `json:"name"`
+	SourceName string      `json:"source_name"`
+	WorkerName string      `json:"worker_name"`
+	Stage      string      `json:"stage"`
+	Unit       string      `json:"unit"`
+	ErrorMsg   string      `json:"error_msg"`
+	SyncStatus *SyncStatus `json:"sync_status,omitempty"`
+}
+
+type SyncStatus struct {
+	MasterBinlog        string `json:"master_binlog"`
+	SyncerBinlog        string `json:"syncer_binlog"`
+	Synced              bool   `json:"synced"`
+	SecondsBehindMaster int64  `json:"seconds_behind_master"`
+}
+
+type errorResp struct {
+	ErrorCode int    `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+var DMService DMApiService
+
+// DMApiService OpenAPI of dm-master
+type DMApiService interface {
+	CreateSource(ctx context.Context, req CreateSourceReq) error
+	DeleteSource(ctx context.Context, req DeleteSourceReq) error
+	CreateTask(ctx context.Context, req CreateTaskReq) error
+	StartTask(ctx context.Context, req TaskCmdReq) error
+	StopTask(ctx context.Context, req TaskCmdReq) error
+	DeleteTask(ctx context.Context, req TaskCmdReq) error
+	QueryTaskStatus(ctx context.Context, req QueryTaskStatusReq) (QueryTaskStatusResp, error)
+}
+
+type DMApiServiceImpl struct{}
+
+func init() {
+	DMService = new(DMApiServiceImpl)
+}
+
+func (service *DMApiServiceImpl) CreateSource(ctx context.Context, req CreateSourceReq) error {
+	framework.LogWithContext(ctx).Infof("create dm source %s, dm-master = %s", req.Source.SourceName, req.DMMasterAddress)
+	data, err := toMap(req)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s%s", req.DMMasterAddress, DMSourceApiUrl)
+	httpResp, err := util.PostJSON(url, data, map[string]string{})
+	return handleResponse(ctx, httpResp, err, nil)
+}
+
+func (service *DMApiServiceImpl) DeleteSource(ctx context.Context, req DeleteSourceReq) error {
+	url := fmt.Sprintf("http://%s%s/%s?force=true", req.DMMasterAddress, DMSourceApiUrl, req.SourceName)
+	httpResp, err := util.Delete(url)
+	return handleResponse(ctx, httpResp, err, nil)
+}
+
+func (service *DMApiServiceImpl) CreateTask(ctx context.Context, req CreateTaskReq) error {
+	framework.LogWithContext(ctx).Infof("create dm task, dm-master = %s", req.DMMasterAddress)
+	url := fmt.Sprintf("http://%s%s/converters", req.DMMasterAddress, DMTaskApiUrl)
+	httpResp, err := util.PostJSON(url, map[string]interface{}{"task_config_file": req.TaskConfigFile}, map[string]string{})
+	converted := make(map[string]interface{})
+	if err = handleResponse(ctx, httpResp, err, &converted); err != nil {
+		return err
+	}
+
+	url = fmt.Sprintf("http://%s%s", req.DMMasterAddress, DMTaskApiUrl)
+	httpResp, err = util.PostJSON(url, map[string]interface{}{"task": converted["task"]}, map[string]string{})
+	return handleResponse(ctx, httpResp, err, nil)
+}
+
+func (service *DMApiServiceImpl) StartTask(ctx context.Context, req TaskCmdReq) error {
+	url := fmt.Sprintf("http://%s%s/%s/start", req.DMMasterAddress, DMTaskApiUrl, req.TaskName)
+	httpResp, err := util.PostJSON(url, map[string]interface{}{}, map[string]string{})
+	return handleResponse(ctx, httpResp, err, nil)
+}
+
+func (service *DMApiServiceImpl) StopTask(ctx context.Context, req TaskCmdReq) error {
+	url := fmt.Sprintf("http://%s%s/%s/stop", req.DMMasterAddress, DMTaskApiUrl, req.TaskName)
+	httpResp, err := util.PostJSON(url, map[string]interface{}{}, map[string]string{})
+	return handleResponse(ctx, httpResp, err, nil)
+}
+
+func (service *DMApiServiceImpl) DeleteTask(ctx context.Context, req TaskCmdReq) error {
+	url := fmt.Sprintf("http://%s%s/%s?force=true", req.DMMasterAddress, DMTaskApiUrl, req.TaskName)
+	httpResp, err := util.Delete(url)
+	return handleResponse(ctx, httpResp, err, nil)
+}
+
+func (service *DMApiServiceImpl) QueryTaskStatus(ctx context.Context, req QueryTaskStatusReq) (resp QueryTaskStatusResp, err error) {
+	url := fmt.Sprintf("http://%s%s/%s/status", req.DMMasterAddress, DMTaskApiUrl, req.TaskName)
+	httpResp, err := util.Get(url, map[string]string{}, map[string]string{})
+	err = handleResponse(ctx, httpResp, err, &resp)
+	return
+}
+
+func toMap(req interface{}) (map[string]interface{}, error) {
+	bytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_MARSHAL_ERROR, "", err)
+	}
+	data := make(map[string]interface{})
+	if err = json.Unmarshal(bytes, &data); err != nil {
+		return nil, errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, "", err)
+	}
+	return data, nil
+}
+
+// handleResponse
+// @Description: convert failed http response to error, and unmarshal body of succeeded response into result if it is not nil
+func handleResponse(ctx context.Context, httpResp *http.Response, err error, result interface{}) error {
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_DM_EXECUTE_ERROR, "", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return errors.WrapError(errors.TIUNIMANAGER_DM_EXECUTE_ERROR, "read http response failed", err)
+	}
+
+	if httpResp.StatusCode < http.StatusOK || httpResp.StatusCode >= http.StatusMultipleChoices {
+		dmErr := &errorResp{}
+		if json.Unmarshal(body, dmErr) != nil || len(dmErr.ErrorMsg) == 0 {
+			dmErr.ErrorMsg = string(body)
+		}
+		framework.LogWithContext(ctx).Errorf("dm-master responded %d, error code = %d, error msg = %s", httpResp.StatusCode, dmErr.ErrorCode, dmErr.ErrorMsg)
+		return errors.NewErrorf(errors.TIUNIMANAGER_DM_EXECUTE_ERROR, "dm error %d: %s", dmErr.ErrorCode, dmErr.ErrorMsg)
+	}
+
+	if result != nil && len(body) > 0 {
+		if err = json.Unmarshal(body, result); err != nil {
+			return errors.WrapError(errors.TIUNIMANAGER_UNMARSHAL_ERROR, "", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package dm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDMApiServiceImpl_CreateSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, DMSourceApiUrl, r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		req := CreateSourceReq{}
+		json.Unmarshal(body, &req)
+		if req.Source.SourceName == "conflict" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error_code": 46003, "error_msg": "source already exists"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	t.Run("normal", func(t *testing.T) {
+		err := DMService.CreateSource(context.TODO(), CreateSourceReq{
+			DMMasterAddress: address,
+			Source:          Source{SourceName: "mysql-01", Host: "127.0.0.1", Port: 3306, Enable: true},
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("error", func(t *testing.T) {
+		err := DMService.CreateSource(context.TODO(), CreateSourceReq{
+			DMMasterAddress: address,
+			Source:          Source{SourceName: "conflict"},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "source already exists")
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		err := DMService.CreateSource(context.TODO(), CreateSourceReq{DMMasterAddress: "127.0.0.1:1"})
+		assert.Error(t, err)
+	})
+}
+
+func TestDMApiServiceImpl_CreateTask(t *testing.T) {
+	created := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		data := make(map[string]interface{})
+		json.Unmarshal(body, &data)
+		switch r.URL.Path {
+		case DMTaskApiUrl + "/converters":
+			assert.Equal(t, "name: task01", data["task_config_file"])
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"task": {"name": "task01"}, "task_config_file": "name: task01"}`))
+		case DMTaskApiUrl:
+			assert.Equal(t, "task01", data["task"].(map[string]interface{})["name"])
+			created = true
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	err := DMService.CreateTask(context.TODO(), CreateTaskReq{
+		DMMasterAddress: strings.TrimPrefix(server.URL, "http://"),
+		TaskConfigFile:  "name: task01",
+	})
+	assert.NoError(t, err)
+	assert.True(t, created)
+}
+
+func TestDMApiServiceImpl_TaskCmd(t *testing.T) {
+	paths := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Method == "DELETE" {
+			assert.Equal(t, "true", r.URL.Query().Get("force"))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	req := TaskCmdReq{DMMasterAddress: strings.TrimPrefix(server.URL, "http://"), TaskName: "task01"}
+
+	assert.NoError(t, DMService.StartTask(context.TODO(), req))
+	assert.NoError(t, DMService.StopTask(context.TODO(), req))
+	assert.NoError(t, DMService.DeleteTask(context.TODO(), req))
+	assert.NoError(t, DMService.DeleteSource(context.TODO(), DeleteSourceReq{DMMasterAddress: req.DMMasterAddress, SourceName: "mysql-01"}))
+	assert.Equal(t, []string{
+		"POST /api/v1/tasks/task01/start",
+		"POST /api/v1/tasks/task01/stop",
+		"DELETE /api/v1/tasks/task01",
+		"DELETE /api/v1/sources/mysql-01",
+	}, paths)
+}
+
+func TestDMApiServiceImpl_QueryTaskStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, DMTaskApiUrl+"/task01/status", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"total": 1, "data": [{"name": "task01", "source_name": "mysql-01", "stage": "Running", "unit": "Sync",
+			"sync_status": {"synced": true, "seconds_behind_master": 3}}]}`))
+	}))
+	defer server.Close()
+
+	resp, err := DMService.QueryTaskStatus(context.TODO(), QueryTaskStatusReq{
+		DMMasterAddress: strings.TrimPrefix(server.URL, "http://"),
+		TaskName:        "task01",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resp.Total)
+	assert.Equal(t, "Sync", resp.Data[0].Unit)
+	assert.Equal(t, int64(3), resp.Data[0].SyncStatus.SecondsBehindMaster)
+}
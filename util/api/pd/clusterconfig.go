@@ -37,7 +37,9 @@ import (
 )
 
 const (
-	PdApiUrl = "/pd/api/v1/config"
+	PdApiUrl       = "/pd/api/v1/config"
+	PdStoresApiUrl = "/pd/api/v1/stores"
+	PdGCApiUrl     = "/pd/api/v1/gc/safepoint"
 )
 
 var ApiService PDApiService
@@ -45,6 +47,8 @@ var ApiService PDApiService
 type PDApiService interface {
 	EditConfig(ctx context.Context, editConfigReq cluster.ApiEditConfigReq) (bool, error)
 	ShowConfig(ctx context.Context, showConfigReq cluster.ApiShowConfigReq) ([]byte, error)
+	ShowStores(ctx context.Context, showStoresReq cluster.ApiShowConfigReq) ([]byte, error)
+	ShowGCSafePoint(ctx context.Context, showGCReq cluster.ApiShowConfigReq) ([]byte, error)
 }
 
 type PDApiServiceImpl struct{}
@@ -76,11 +80,24 @@ func (service *PDApiServiceImpl) EditConfig(ctx context.Context, editConfigReq c
 
 func (service *PDApiServiceImpl) ShowConfig(ctx context.Context, showConfigReq cluster.ApiShowConfigReq) ([]byte, error) {
 	framework.LogWithContext(ctx).Infof("request pd api show config, api req: %v", showConfigReq)
+	return show(ctx, showConfigReq, PdApiUrl)
+}
+
+func (service *PDApiServiceImpl) ShowStores(ctx context.Context, showStoresReq cluster.ApiShowConfigReq) ([]byte, error) {
+	framework.LogWithContext(ctx).Infof("request pd api show stores, api req: %v", showStoresReq)
+	return show(ctx, showStoresReq, PdStoresApiUrl)
+}
+
+func (service *PDApiServiceImpl) ShowGCSafePoint(ctx context.Context, showGCReq cluster.ApiShowConfigReq) ([]byte, error) {
+	framework.LogWithContext(ctx).Infof("request pd api show gc safepoint, api req: %v", showGCReq)
+	return show(ctx, showGCReq, PdGCApiUrl)
+}
 
-	url := fmt.Sprintf("http://%s:%d%s", showConfigReq.InstanceHost, showConfigReq.InstancePort, PdApiUrl)
-	resp, err := util.Get(url, showConfigReq.Params, showConfigReq.Headers)
+func show(ctx context.Context, showReq cluster.ApiShowConfigReq, apiUrl string) ([]byte, error) {
+	url := fmt.Sprintf("http://%s:%d%s", showReq.InstanceHost, showReq.InstancePort, apiUrl)
+	resp, err := util.Get(url, showReq.Params, showReq.Headers)
 	if err != nil {
-		framework.LogWithContext(ctx).Errorf("request pd api show config resp err: %v", err.Error())
+		framework.LogWithContext(ctx).Errorf("request pd api %s resp err: %v", apiUrl, err.Error())
 		return nil, err
 	}
 	b, err := ioutil.ReadAll(resp.Body)
@@ -89,7 +106,7 @@ func (service *PDApiServiceImpl) ShowConfig(ctx context.Context, showConfigReq c
 	}
 	if resp.StatusCode != http.StatusOK {
 		errMsg := fmt.Sprintf("request PD api response status code: %v, content: %v", resp.StatusCode, string(b))
-		framework.LogWithContext(ctx).Errorf("pd api show config, %s", errMsg)
+		framework.LogWithContext(ctx).Errorf("pd api %s, %s", apiUrl, errMsg)
 		return nil, errors.New(errMsg)
 	}
 	return b, nil
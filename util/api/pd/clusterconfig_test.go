@@ -292,3 +292,68 @@ func Test_ShowConfig_Error(t *testing.T) {
 		})
 	}
 }
+
+func Test_ShowStores(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PdStoresApiUrl {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("{\"count\": 0, \"stores\": []}"))
+	}))
+	defer server.Close()
+
+	ipAndPort := strings.TrimPrefix(server.URL, "http://")
+	host := strings.Split(ipAndPort, ":")[0]
+	port, err := strconv.Atoi(strings.Split(ipAndPort, ":")[1])
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	content, err := ApiService.ShowStores(context.TODO(), cluster.ApiShowConfigReq{
+		InstanceHost: host,
+		InstancePort: uint(port),
+		Headers:      map[string]string{},
+	})
+	if err != nil {
+		t.Errorf("ShowStores() error = %v", err)
+		return
+	}
+	if string(content) != "{\"count\": 0, \"stores\": []}" {
+		t.Errorf("ShowStores() got %s", string(content))
+	}
+}
+
+func Test_ShowGCSafePoint(t *testing.T) {
+	body := "{\"service_gc_safe_points\": [], \"gc_safe_point\": 0}"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PdGCApiUrl {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	ipAndPort := strings.TrimPrefix(server.URL, "http://")
+	host := strings.Split(ipAndPort, ":")[0]
+	port, err := strconv.Atoi(strings.Split(ipAndPort, ":")[1])
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+
+	content, err := ApiService.ShowGCSafePoint(context.TODO(), cluster.ApiShowConfigReq{
+		InstanceHost: host,
+		InstancePort: uint(port),
+		Headers:      map[string]string{},
+	})
+	if err != nil {
+		t.Errorf("ShowGCSafePoint() error = %v", err)
+		return
+	}
+	if string(content) != body {
+		t.Errorf("ShowGCSafePoint() got %s", string(content))
+	}
+}
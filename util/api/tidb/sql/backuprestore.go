@@ -26,8 +26,8 @@ import (
 
 type BackupSQLReq struct {
 	NodeID          string
-	DbName          string
-	TableName       string
+	DbNames         []string
+	TableNames      []string // in format of db.table, exclusive with DbNames
 	StorageAddress  string
 	DbConnParameter DbConnParam // only for SQL command, not used in br command
 	RateLimitM      string
@@ -45,8 +45,8 @@ type BRSQLResp struct {
 
 type RestoreSQLReq struct {
 	NodeID          string
-	DbName          string
-	TableName       string
+	DbNames         []string
+	TableNames      []string // in format of db.table, exclusive with DbNames
 	StorageAddress  string
 	DbConnParameter DbConnParam // only for SQL command, not used in br command
 	RateLimitM      string
@@ -54,6 +54,14 @@ type RestoreSQLReq struct {
 	CheckSum        string // only for SQL command, not used in br command
 }
 
+type RenameDatabaseTablesReq struct {
+	DbConnParameter DbConnParam
+	SourceDatabase  string
+	TargetDatabase  string
+	TableNames      []string // all tables of source database are renamed if empty
+	DropSource      bool     // drop source database after its tables renamed
+}
+
 type ShowBackupReq struct {
 	DbConnParameter DbConnParam
 	Destination     string
@@ -89,16 +97,7 @@ func ExecBackupSQL(ctx context.Context, request BackupSQLReq, workflowNodeId str
 
 	var args []string
 	args = append(args, "BACKUP")
-	if len(request.TableName) != 0 {
-		args = append(args, "TABLE", fmt.Sprintf("`%s`.`%s`", request.DbName, request.TableName))
-	} else {
-		args = append(args, "DATABASE")
-		if len(request.DbName) != 0 {
-			args = append(args, fmt.Sprintf("`%s`", request.DbName))
-		} else {
-			args = append(args, "*")
-		}
-	}
+	args = append(args, brSchemaArgs(request.DbNames, request.TableNames)...)
 	args = append(args, "TO", fmt.Sprintf("'%s'", request.StorageAddress))
 	if len(request.RateLimitM) != 0 {
 		args = append(args, "RATE_LIMIT", "=", request.RateLimitM, "MB/SECOND")
@@ -134,16 +133,7 @@ func ExecRestoreSQL(ctx context.Context, request RestoreSQLReq, workflowNodeId s
 
 	var args []string
 	args = append(args, "RESTORE")
-	if len(request.TableName) != 0 {
-		args = append(args, "TABLE", fmt.Sprintf("`%s`.`%s`", request.DbName, request.TableName))
-	} else {
-		args = append(args, "DATABASE")
-		if len(request.DbName) != 0 {
-			args = append(args, fmt.Sprintf("`%s`", request.DbName))
-		} else {
-			args = append(args, "*")
-		}
-	}
+	args = append(args, brSchemaArgs(request.DbNames, request.TableNames)...)
 	args = append(args, "FROM", fmt.Sprintf("'%s'", request.StorageAddress))
 	if len(request.RateLimitM) != 0 {
 		args = append(args, "RATE_LIMIT", "=", request.RateLimitM, "MB/SECOND")
@@ -165,8 +155,233 @@ func ExecRestoreSQL(ctx context.Context, request RestoreSQLReq, workflowNodeId s
 	return
 }
 
+// brSchemaArgs
+// @Description: build schema arguments of BACKUP and RESTORE statements, all databases if both are empty
+// @Parameter dbNames
+// @Parameter tableNames in format of db.table
+// @return []string
+func brSchemaArgs(dbNames []string, tableNames []string) []string {
+	if len(tableNames) != 0 {
+		tables := make([]string, 0)
+		for _, tableName := range tableNames {
+			names := strings.SplitN(tableName, ".", 2)
+			if len(names) == 2 {
+				tables = append(tables, fmt.Sprintf("`%s`.`%s`", names[0], names[1]))
+			} else {
+				tables = append(tables, fmt.Sprintf("`%s`", tableName))
+			}
+		}
+		return []string{"TABLE", strings.Join(tables, ", ")}
+	}
+	if len(dbNames) != 0 {
+		databases := make([]string, 0)
+		for _, dbName := range dbNames {
+			databases = append(databases, fmt.Sprintf("`%s`", dbName))
+		}
+		return []string{"DATABASE", strings.Join(databases, ", ")}
+	}
+	return []string{"DATABASE", "*"}
+}
+
+// DatabaseExists
+// @Description: check whether database exists in cluster
+// @Parameter ctx
+// @Parameter dbConnParam
+// @Parameter dbName
+// @return bool
+// @return error
+func DatabaseExists(ctx context.Context, dbConnParam DbConnParam, dbName string) (bool, error) {
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
+		dbConnParam.Password, dbConnParam.IP, dbConnParam.Port))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return false, err
+	}
+	defer db.Close()
+	return databaseExists(ctx, db, dbName)
+}
+
+func databaseExists(ctx context.Context, db *sql.DB, dbName string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = ?", dbName).Scan(&count)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query database %s failed %s", dbName, err.Error())
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ExistingTables
+// @Description: get tables of database which already exist in cluster
+// @Parameter ctx
+// @Parameter dbConnParam
+// @Parameter dbName
+// @Parameter tableNames
+// @return []string names of existing tables
+// @return error
+func ExistingTables(ctx context.Context, dbConnParam DbConnParam, dbName string, tableNames []string) ([]string, error) {
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
+		dbConnParam.Password, dbConnParam.IP, dbConnParam.Port))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return nil, err
+	}
+	defer db.Close()
+	return existingTables(ctx, db, dbName, tableNames)
+}
+
+func existingTables(ctx context.Context, db *sql.DB, dbName string, tableNames []string) ([]string, error) {
+	existing := make([]string, 0)
+	if len(tableNames) == 0 {
+		return existing, nil
+	}
+	args := []interface{}{dbName}
+	for _, tableName := range tableNames {
+		args = append(args, tableName)
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_name IN (?%s)",
+		strings.Repeat(", ?", len(tableNames)-1)), args...)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query tables of database %s failed %s", dbName, err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		if err = rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+		existing = append(existing, tableName)
+	}
+	return existing, rows.Err()
+}
+
+// RestoreTargetInfo facts of a cluster checked before restoring a backup into it
+type RestoreTargetInfo struct {
+	NewCollationEnabled bool
+	Tables              []string // user tables in format of db.table
+}
+
+// QueryRestoreTargetInfo
+// @Description: query new collation setting and existing user tables of a cluster
+// @Parameter ctx
+// @Parameter dbConnParam
+// @return RestoreTargetInfo
+// @return error
+func QueryRestoreTargetInfo(ctx context.Context, dbConnParam DbConnParam) (RestoreTargetInfo, error) {
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
+		dbConnParam.Password, dbConnParam.IP, dbConnParam.Port))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return RestoreTargetInfo{}, err
+	}
+	defer db.Close()
+	return queryRestoreTargetInfo(ctx, db)
+}
+
+func queryRestoreTargetInfo(ctx context.Context, db *sql.DB) (info RestoreTargetInfo, err error) {
+	var enabled string
+	err = db.QueryRowContext(ctx, "SELECT VARIABLE_VALUE FROM mysql.tidb WHERE VARIABLE_NAME = 'new_collation_enabled'").Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		framework.LogWithContext(ctx).Errorf("query new collation setting failed %s", err.Error())
+		return info, err
+	}
+	// clusters bootstrapped before new collation was introduced have no such variable
+	info.NewCollationEnabled = strings.EqualFold(enabled, "true")
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND LOWER(table_schema) NOT IN ('%s')",
+		strings.Join(systemSchemas, "','")))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query tables failed %s", err.Error())
+		return info, err
+	}
+	defer rows.Close()
+	info.Tables = make([]string, 0)
+	for rows.Next() {
+		var dbName, tableName string
+		if err = rows.Scan(&dbName, &tableName); err != nil {
+			return info, err
+		}
+		info.Tables = append(info.Tables, fmt.Sprintf("%s.%s", dbName, tableName))
+	}
+	return info, rows.Err()
+}
+
+// RenameDatabaseTables
+// @Description: move tables of source database into target database, target database is created if not exists
+// @Parameter ctx
+// @Parameter request
+// @return error
+func RenameDatabaseTables(ctx context.Context, request RenameDatabaseTablesReq) error {
+	framework.LogWithContext(ctx).Infof("begin rename database tables, source: %s, target: %s, tables: %v",
+		request.SourceDatabase, request.TargetDatabase, request.TableNames)
+
+	dbConnParam := request.DbConnParameter
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
+		dbConnParam.Password, dbConnParam.IP, dbConnParam.Port))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return err
+	}
+	defer db.Close()
+	return renameDatabaseTables(ctx, db, request)
+}
+
+func renameDatabaseTables(ctx context.Context, db *sql.DB, request RenameDatabaseTablesReq) error {
+	tableNames := request.TableNames
+	if len(tableNames) == 0 {
+		rows, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = ?", request.SourceDatabase)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("query tables of database %s failed %s", request.SourceDatabase, err.Error())
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var tableName string
+			if err = rows.Scan(&tableName); err != nil {
+				return err
+			}
+			tableNames = append(tableNames, tableName)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := ExecCommandThruSQL(ctx, db, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", request.TargetDatabase)); err != nil {
+		return err
+	}
+	for _, tableName := range tableNames {
+		if err := ExecCommandThruSQL(ctx, db, fmt.Sprintf("RENAME TABLE `%s`.`%s` TO `%s`.`%s`",
+			request.SourceDatabase, tableName, request.TargetDatabase, tableName)); err != nil {
+			return err
+		}
+	}
+	if request.DropSource {
+		if err := ExecCommandThruSQL(ctx, db, fmt.Sprintf("DROP DATABASE `%s`", request.SourceDatabase)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ExecShowBackupSQL(ctx context.Context, request ShowBackupReq) (resp ShowBackupResp, err error) {
-	framework.LogWithContext(ctx).Infof("begin exec show backup sql, request: %+v", request)
+	return execShowBRSQL(ctx, "BACKUPS", request)
+}
+
+// ExecShowRestoreSQL
+// @Description: show the running restore job of destination, columns of SHOW RESTORES are the same as SHOW BACKUPS
+// @Parameter ctx
+// @Parameter request
+// @return resp
+// @return err sql.ErrNoRows if the job is not found
+func ExecShowRestoreSQL(ctx context.Context, request ShowBackupReq) (resp ShowBackupResp, err error) {
+	return execShowBRSQL(ctx, "RESTORES", request)
+}
+
+func execShowBRSQL(ctx context.Context, jobs string, request ShowBackupReq) (resp ShowBackupResp, err error) {
+	framework.LogWithContext(ctx).Infof("begin exec show %s sql, request: %+v", strings.ToLower(jobs), request)
 
 	dbConnParam := request.DbConnParameter
 	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
@@ -176,19 +391,22 @@ func ExecShowBackupSQL(ctx context.Context, request ShowBackupReq) (resp ShowBac
 		return
 	}
 	defer db.Close()
+	return showBRJob(ctx, db, jobs, request.Destination)
+}
 
+func showBRJob(ctx context.Context, db *sql.DB, jobs string, destination string) (resp ShowBackupResp, err error) {
 	var args []string
-	args = append(args, "SHOW", "BACKUPS")
-	if request.Destination != "" {
-		args = append(args, "LIKE", fmt.Sprintf("'%%%s%%'", request.Destination))
+	args = append(args, "SHOW", jobs)
+	if destination != "" {
+		args = append(args, "LIKE", fmt.Sprintf("'%%%s%%'", destination))
 	}
 	showSQLCmd := strings.Join(args, " ")
 	err = db.QueryRow(showSQLCmd).Scan(&resp.Destination, &resp.State, &resp.Progress, &resp.QueueTime, &resp.ExecutionTime, &resp.FinishTime, &resp.Connection, &resp.Message)
 	if err != nil {
-		framework.LogWithContext(ctx).Errorf("query backup sql cmd failed %s", err.Error())
+		framework.LogWithContext(ctx).Errorf("query show sql cmd %s failed %s", showSQLCmd, err.Error())
 		return
 	}
-	framework.LogWithContext(ctx).Infof("do show backup sql cmd %s succeed, resp: %+v", showSQLCmd, resp)
+	framework.LogWithContext(ctx).Infof("do show sql cmd %s succeed, resp: %+v", showSQLCmd, resp)
 	return
 }
 
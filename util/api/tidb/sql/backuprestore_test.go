@@ -0,0 +1,189 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_brSchemaArgs(t *testing.T) {
+	assert.Equal(t, []string{"DATABASE", "*"}, brSchemaArgs(nil, nil))
+	assert.Equal(t, []string{"DATABASE", "`db1`, `db2`"}, brSchemaArgs([]string{"db1", "db2"}, nil))
+	assert.Equal(t, []string{"TABLE", "`db1`.`t1`, `db2`.`t.2`"}, brSchemaArgs(nil, []string{"db1.t1", "db2.t.2"}))
+}
+
+func Test_databaseExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = ?")).
+		WithArgs("db1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	exists, err := databaseExists(context.TODO(), db, "db1")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = ?")).
+		WithArgs("db2").WillReturnError(fmt.Errorf("some error"))
+	_, err = databaseExists(context.TODO(), db, "db2")
+	assert.Error(t, err)
+}
+
+func Test_existingTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	existing, err := existingTables(context.TODO(), db, "db1", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, existing)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_name IN (?, ?)")).
+		WithArgs("db1", "t1", "t2").WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("t2"))
+	existing, err = existingTables(context.TODO(), db, "db1", []string{"t1", "t2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"t2"}, existing)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_name IN (?)")).
+		WithArgs("db1", "t1").WillReturnError(fmt.Errorf("some error"))
+	_, err = existingTables(context.TODO(), db, "db1", []string{"t1"})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_queryRestoreTargetInfo(t *testing.T) {
+	collationSQL := "SELECT VARIABLE_VALUE FROM mysql.tidb WHERE VARIABLE_NAME = 'new_collation_enabled'"
+	tablesSQL := "SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE'"
+
+	t.Run("normal", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(collationSQL)).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}).AddRow("True"))
+		mock.ExpectQuery(regexp.QuoteMeta(tablesSQL)).
+			WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).AddRow("db1", "t1").AddRow("db2", "t2"))
+		info, err := queryRestoreTargetInfo(context.TODO(), db)
+		assert.NoError(t, err)
+		assert.True(t, info.NewCollationEnabled)
+		assert.Equal(t, []string{"db1.t1", "db2.t2"}, info.Tables)
+	})
+	t.Run("no collation variable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(collationSQL)).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}))
+		mock.ExpectQuery(regexp.QuoteMeta(tablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}))
+		info, err := queryRestoreTargetInfo(context.TODO(), db)
+		assert.NoError(t, err)
+		assert.False(t, info.NewCollationEnabled)
+		assert.Empty(t, info.Tables)
+	})
+	t.Run("query failed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(collationSQL)).WillReturnError(fmt.Errorf("some error"))
+		_, err = queryRestoreTargetInfo(context.TODO(), db)
+		assert.Error(t, err)
+	})
+}
+
+func Test_renameDatabaseTables(t *testing.T) {
+	t.Run("all tables", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT table_name FROM information_schema.tables WHERE table_schema = ?")).
+			WithArgs("db1").WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("t1").AddRow("t2"))
+		mock.ExpectExec(regexp.QuoteMeta("CREATE DATABASE IF NOT EXISTS `db1_restored`")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("RENAME TABLE `db1`.`t1` TO `db1_restored`.`t1`")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("RENAME TABLE `db1`.`t2` TO `db1_restored`.`t2`")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("DROP DATABASE `db1`")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err = renameDatabaseTables(context.TODO(), db, RenameDatabaseTablesReq{
+			SourceDatabase: "db1",
+			TargetDatabase: "db1_restored",
+			DropSource:     true,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("rename failed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(regexp.QuoteMeta("CREATE DATABASE IF NOT EXISTS `db1_restored`")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("RENAME TABLE `db1`.`t1` TO `db1_restored`.`t1`")).WillReturnError(fmt.Errorf("table exists"))
+
+		err = renameDatabaseTables(context.TODO(), db, RenameDatabaseTablesReq{
+			SourceDatabase: "db1",
+			TargetDatabase: "db1_restored",
+			TableNames:     []string{"t1"},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func Test_showBRJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	columns := []string{"Destination", "State", "Progress", "Queue_time", "Execution_time", "Finish_time", "Connection", "Message"}
+	t.Run("backup", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("SHOW BACKUPS LIKE '%/backup/c1%'")).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("local:///backup/c1", "Backup", 35.5, "2022-01-01 10:00:00", "2022-01-01 10:00:01", "0000-00-00 00:00:00", 12, nil))
+		resp, err := showBRJob(context.TODO(), db, "BACKUPS", "/backup/c1")
+		assert.NoError(t, err)
+		assert.Equal(t, float32(35.5), resp.Progress)
+		assert.Equal(t, 12, resp.Connection)
+		assert.Equal(t, "Backup", resp.State)
+	})
+	t.Run("restore not found", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta("SHOW RESTORES LIKE '%/backup/c1%'")).WillReturnRows(sqlmock.NewRows(columns))
+		_, err := showBRJob(context.TODO(), db, "RESTORES", "/backup/c1")
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,143 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pingcap/tiunimanager/library/framework"
+	"strings"
+)
+
+type ChecksumTablesReq struct {
+	DbConnParameter DbConnParam
+	SnapshotTSO     uint64   // read tables at the snapshot, current data if zero
+	DbNames         []string // all user tables are checked if both DbNames and TableNames are empty
+	TableNames      []string // in format of db.table, exclusive with DbNames
+}
+
+type TableChecksum struct {
+	Database   string
+	Table      string
+	Checksum   uint64
+	TotalKvs   uint64
+	TotalBytes uint64
+	RowCount   uint64
+}
+
+// Name
+// @Description: table name in format of db.table
+func (t TableChecksum) Name() string {
+	return fmt.Sprintf("%s.%s", t.Database, t.Table)
+}
+
+var systemSchemas = []string{"mysql", "information_schema", "performance_schema", "metrics_schema"}
+
+// ChecksumTables
+// @Description: run admin checksum and count rows of tables
+// @Parameter ctx
+// @Parameter request
+// @return []TableChecksum
+// @return error
+func ChecksumTables(ctx context.Context, request ChecksumTablesReq) ([]TableChecksum, error) {
+	framework.LogWithContext(ctx).Infof("begin checksum tables, snapshot: %d, databases: %v, tables: %v",
+		request.SnapshotTSO, request.DbNames, request.TableNames)
+
+	dbConnParam := request.DbConnParameter
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
+		dbConnParam.Password, dbConnParam.IP, dbConnParam.Port))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return nil, err
+	}
+	defer db.Close()
+	return checksumTables(ctx, db, request)
+}
+
+func checksumTables(ctx context.Context, db *sql.DB, request ChecksumTablesReq) ([]TableChecksum, error) {
+	// tidb_snapshot is a session variable, so all statements must be sent through the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if request.SnapshotTSO > 0 {
+		if _, err = conn.ExecContext(ctx, fmt.Sprintf("SET @@tidb_snapshot = '%d'", request.SnapshotTSO)); err != nil {
+			framework.LogWithContext(ctx).Errorf("set snapshot %d failed %s", request.SnapshotTSO, err.Error())
+			return nil, err
+		}
+	}
+
+	tables, err := listTables(ctx, conn, request.DbNames, request.TableNames)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tables {
+		table := &tables[i]
+		var dbName, tableName string
+		err = conn.QueryRowContext(ctx, fmt.Sprintf("ADMIN CHECKSUM TABLE `%s`.`%s`", table.Database, table.Table)).
+			Scan(&dbName, &tableName, &table.Checksum, &table.TotalKvs, &table.TotalBytes)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("checksum table %s failed %s", table.Name(), err.Error())
+			return nil, err
+		}
+		err = conn.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM `%s`.`%s`", table.Database, table.Table)).
+			Scan(&table.RowCount)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("count rows of table %s failed %s", table.Name(), err.Error())
+			return nil, err
+		}
+	}
+	framework.LogWithContext(ctx).Infof("checksum %d tables succeed", len(tables))
+	return tables, nil
+}
+
+func listTables(ctx context.Context, conn *sql.Conn, dbNames []string, tableNames []string) ([]TableChecksum, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(
+		"SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE' AND LOWER(table_schema) NOT IN ('%s') ORDER BY table_schema, table_name",
+		strings.Join(systemSchemas, "','")))
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("query tables failed %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	databases := make(map[string]bool)
+	for _, name := range dbNames {
+		databases[name] = true
+	}
+	names := make(map[string]bool)
+	for _, name := range tableNames {
+		names[name] = true
+	}
+	tables := make([]TableChecksum, 0)
+	for rows.Next() {
+		table := TableChecksum{}
+		if err = rows.Scan(&table.Database, &table.Table); err != nil {
+			return nil, err
+		}
+		if len(databases) > 0 && !databases[table.Database] {
+			continue
+		}
+		if len(names) > 0 && !names[table.Name()] {
+			continue
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
@@ -0,0 +1,106 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ *  Unless required by applicable law or agreed to in writing, software       *
+ *  distributed under the License is distributed on an "AS IS" BASIS,         *
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  *
+ *  See the License for the specific language governing permissions and       *
+ *  limitations under the License.                                            *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const listTablesSQL = "SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE'"
+
+func Test_checksumTables(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(regexp.QuoteMeta("SET @@tidb_snapshot = '431434047157698561'")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1").AddRow("db1", "t2").AddRow("db2", "t1"))
+		mock.ExpectQuery(regexp.QuoteMeta("ADMIN CHECKSUM TABLE `db1`.`t1`")).WillReturnRows(
+			sqlmock.NewRows([]string{"Db_name", "Table_name", "Checksum_crc64_xor", "Total_kvs", "Total_bytes"}).AddRow("db1", "t1", 123, 10, 1024))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `db1`.`t1`")).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+		mock.ExpectQuery(regexp.QuoteMeta("ADMIN CHECKSUM TABLE `db1`.`t2`")).WillReturnRows(
+			sqlmock.NewRows([]string{"Db_name", "Table_name", "Checksum_crc64_xor", "Total_kvs", "Total_bytes"}).AddRow("db1", "t2", 456, 0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `db1`.`t2`")).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		tables, err := checksumTables(context.TODO(), db, ChecksumTablesReq{
+			SnapshotTSO: 431434047157698561,
+			DbNames:     []string{"db1"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(tables))
+		assert.Equal(t, "db1.t1", tables[0].Name())
+		assert.Equal(t, uint64(123), tables[0].Checksum)
+		assert.Equal(t, uint64(10), tables[0].TotalKvs)
+		assert.Equal(t, uint64(1024), tables[0].TotalBytes)
+		assert.Equal(t, uint64(5), tables[0].RowCount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("table filter", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1").AddRow("db2", "t1"))
+		mock.ExpectQuery(regexp.QuoteMeta("ADMIN CHECKSUM TABLE `db2`.`t1`")).WillReturnRows(
+			sqlmock.NewRows([]string{"Db_name", "Table_name", "Checksum_crc64_xor", "Total_kvs", "Total_bytes"}).AddRow("db2", "t1", 1, 1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `db2`.`t1`")).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		tables, err := checksumTables(context.TODO(), db, ChecksumTablesReq{
+			TableNames: []string{"db2.t1"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(tables))
+		assert.Equal(t, "db2.t1", tables[0].Name())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("snapshot gc", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(regexp.QuoteMeta("SET @@tidb_snapshot = '1'")).WillReturnError(fmt.Errorf("snapshot is older than GC safe point"))
+		_, err = checksumTables(context.TODO(), db, ChecksumTablesReq{SnapshotTSO: 1})
+		assert.Error(t, err)
+	})
+	t.Run("checksum failed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1"))
+		mock.ExpectQuery(regexp.QuoteMeta("ADMIN CHECKSUM TABLE `db1`.`t1`")).WillReturnError(fmt.Errorf("checksum mismatch"))
+		_, err = checksumTables(context.TODO(), db, ChecksumTablesReq{})
+		assert.Error(t, err)
+	})
+}
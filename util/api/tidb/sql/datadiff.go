@@ -0,0 +1,555 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pingcap/tiunimanager/library/framework"
+)
+
+const (
+	defaultDiffChunkSize = 10000
+	defaultMaxFixSQL     = 1000
+)
+
+type DiffTablesReq struct {
+	Upstream   DbConnParam
+	Downstream DbConnParam
+	// read upstream tables at the snapshot, current data if zero
+	UpstreamSnapshotTSO uint64
+	// read downstream tables at the snapshot, current data if zero, which is required by MySQL downstream
+	DownstreamSnapshotTSO uint64
+	DbNames               []string
+	TableNames            []string // in format of db.table
+	FilterRules           []string // table filter rules of change feed, all user tables are compared if empty
+	ChunkSize             int      // rows of a chunk, defaultDiffChunkSize if zero
+	GenerateFixSQL        bool
+	MaxFixSQL             int // max statements generated for all tables, defaultMaxFixSQL if zero
+}
+
+type TableDiff struct {
+	Database string
+	Table    string
+	Equal    bool
+	Message  string
+	// number of compared chunks
+	Chunks         int
+	MismatchChunks []ChunkDiff
+	// statements making downstream the same as upstream
+	FixSQL []string
+}
+
+// Name
+// @Description: table name in format of db.table
+func (t TableDiff) Name() string {
+	return fmt.Sprintf("%s.%s", t.Database, t.Table)
+}
+
+type ChunkDiff struct {
+	Range              string
+	UpstreamRows       int64
+	DownstreamRows     int64
+	UpstreamChecksum   uint64
+	DownstreamChecksum uint64
+}
+
+// chunkRange key range of a chunk, all rows of table if there is no key
+type chunkRange struct {
+	where string
+	args  []interface{}
+	desc  string
+}
+
+type tableSchema struct {
+	database string
+	table    string
+	columns  []string
+	keys     []string // columns of primary key
+}
+
+func (t tableSchema) quotedName() string {
+	return quoteName(t.database) + "." + quoteName(t.table)
+}
+
+// DiffTables
+// @Description: compare data of tables between upstream and downstream chunk by chunk, tables are split into
+// chunks by primary key, and checksum of a chunk is bit xor of crc32 of all rows
+// @Parameter ctx
+// @Parameter request
+// @return []TableDiff compared tables
+// @return error
+func DiffTables(ctx context.Context, request DiffTablesReq) ([]TableDiff, error) {
+	framework.LogWithContext(ctx).Infof("begin diff tables, upstream snapshot: %d, downstream snapshot: %d, databases: %v, tables: %v",
+		request.UpstreamSnapshotTSO, request.DownstreamSnapshotTSO, request.DbNames, request.TableNames)
+
+	upstream, err := openDB(request.Upstream)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open upstream connection failed %s", err.Error())
+		return nil, err
+	}
+	defer upstream.Close()
+	downstream, err := openDB(request.Downstream)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open downstream connection failed %s", err.Error())
+		return nil, err
+	}
+	defer downstream.Close()
+	return diffTables(ctx, upstream, downstream, request)
+}
+
+// QuerySyncPoint
+// @Description: query the latest sync point of change feed recorded in TiDB downstream
+// @Parameter ctx
+// @Parameter dbConnParam downstream
+// @Parameter changeFeedID
+// @return primaryTS TSO of upstream
+// @return secondaryTS TSO of downstream, the data of downstream at it is the same as upstream at primaryTS
+// @return error zero TSO without error if sync point is not enabled
+func QuerySyncPoint(ctx context.Context, dbConnParam DbConnParam, changeFeedID string) (primaryTS uint64, secondaryTS uint64, err error) {
+	db, err := openDB(dbConnParam)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return 0, 0, err
+	}
+	defer db.Close()
+	return querySyncPoint(ctx, db, changeFeedID)
+}
+
+// MatchTableFilter
+// @Description: whether table is selected by table filter rules, such as db*.t? and !db1.*, the last matched rule wins
+// @Parameter rules all tables are selected if empty
+// @Parameter database
+// @Parameter table
+// @return bool
+func MatchTableFilter(rules []string, database string, table string) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	matched := false
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		selected := true
+		if strings.HasPrefix(rule, "!") {
+			selected, rule = false, rule[1:]
+		}
+		patterns := strings.SplitN(rule, ".", 2)
+		if len(patterns) != 2 {
+			continue
+		}
+		if matchPattern(patterns[0], database) && matchPattern(patterns[1], table) {
+			matched = selected
+		}
+	}
+	return matched
+}
+
+func matchPattern(pattern string, name string) bool {
+	matched, err := path.Match(strings.ToLower(strings.Trim(pattern, "`\"")), strings.ToLower(name))
+	return err == nil && matched
+}
+
+func openDB(dbConnParam DbConnParam) (*sql.DB, error) {
+	return sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/mysql", dbConnParam.Username,
+		dbConnParam.Password, dbConnParam.IP, dbConnParam.Port))
+}
+
+func querySyncPoint(ctx context.Context, db *sql.DB, changeFeedID string) (primaryTS uint64, secondaryTS uint64, err error) {
+	err = db.QueryRowContext(ctx, "SELECT primary_ts, secondary_ts FROM tidb_cdc.syncpoint_v1 WHERE cf = ? ORDER BY primary_ts DESC LIMIT 1",
+		changeFeedID).Scan(&primaryTS, &secondaryTS)
+	if err == sql.ErrNoRows || (err != nil && strings.Contains(err.Error(), "doesn't exist")) {
+		return 0, 0, nil
+	} else if err != nil {
+		framework.LogWithContext(ctx).Errorf("query sync point of change feed %s failed %s", changeFeedID, err.Error())
+		return 0, 0, err
+	}
+	return primaryTS, secondaryTS, nil
+}
+
+func diffTables(ctx context.Context, upstream *sql.DB, downstream *sql.DB, request DiffTablesReq) ([]TableDiff, error) {
+	// tidb_snapshot is a session variable, so all statements of one side must be sent through the same connection
+	upConn, err := openSnapshotConn(ctx, upstream, request.UpstreamSnapshotTSO)
+	if err != nil {
+		return nil, err
+	}
+	defer upConn.Close()
+	downConn, err := openSnapshotConn(ctx, downstream, request.DownstreamSnapshotTSO)
+	if err != nil {
+		return nil, err
+	}
+	defer downConn.Close()
+
+	if request.ChunkSize <= 0 {
+		request.ChunkSize = defaultDiffChunkSize
+	}
+	fixBudget := request.MaxFixSQL
+	if fixBudget <= 0 {
+		fixBudget = defaultMaxFixSQL
+	}
+
+	tables, err := listTables(ctx, upConn, request.DbNames, request.TableNames)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]TableDiff, 0, len(tables))
+	for _, table := range tables {
+		if !MatchTableFilter(request.FilterRules, table.Database, table.Table) {
+			continue
+		}
+		diff, err := diffTable(ctx, upConn, downConn, table.Database, table.Table, request, &fixBudget)
+		if err != nil {
+			framework.LogWithContext(ctx).Errorf("diff table %s failed %s", table.Name(), err.Error())
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+	framework.LogWithContext(ctx).Infof("diff %d tables succeed", len(diffs))
+	return diffs, nil
+}
+
+func openSnapshotConn(ctx context.Context, db *sql.DB, snapshotTSO uint64) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotTSO > 0 {
+		if _, err = conn.ExecContext(ctx, fmt.Sprintf("SET @@tidb_snapshot = '%d'", snapshotTSO)); err != nil {
+			framework.LogWithContext(ctx).Errorf("set snapshot %d failed %s", snapshotTSO, err.Error())
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func diffTable(ctx context.Context, upConn *sql.Conn, downConn *sql.Conn, database string, table string, request DiffTablesReq, fixBudget *int) (TableDiff, error) {
+	diff := TableDiff{Database: database, Table: table, Equal: true}
+
+	upColumns, err := queryColumns(ctx, upConn, database, table)
+	if err != nil {
+		return diff, err
+	}
+	downColumns, err := queryColumns(ctx, downConn, database, table)
+	if err != nil {
+		return diff, err
+	}
+	if len(downColumns) == 0 {
+		diff.Equal, diff.Message = false, "table does not exist in downstream"
+		return diff, nil
+	}
+	if strings.Join(upColumns, ",") != strings.Join(downColumns, ",") {
+		diff.Equal = false
+		diff.Message = fmt.Sprintf("columns differ, upstream: %v, downstream: %v", upColumns, downColumns)
+		return diff, nil
+	}
+
+	schema := tableSchema{database: database, table: table, columns: upColumns}
+	if schema.keys, err = queryPrimaryKey(ctx, upConn, database, table); err != nil {
+		return diff, err
+	}
+	ranges, err := splitChunks(ctx, upConn, schema, request.ChunkSize)
+	if err != nil {
+		return diff, err
+	}
+
+	for _, chunk := range ranges {
+		chunkDiff := ChunkDiff{Range: chunk.desc}
+		if chunkDiff.UpstreamRows, chunkDiff.UpstreamChecksum, err = checksumChunk(ctx, upConn, schema, chunk); err != nil {
+			return diff, err
+		}
+		if chunkDiff.DownstreamRows, chunkDiff.DownstreamChecksum, err = checksumChunk(ctx, downConn, schema, chunk); err != nil {
+			return diff, err
+		}
+		diff.Chunks++
+		if chunkDiff.UpstreamRows == chunkDiff.DownstreamRows && chunkDiff.UpstreamChecksum == chunkDiff.DownstreamChecksum {
+			continue
+		}
+		diff.Equal = false
+		diff.MismatchChunks = append(diff.MismatchChunks, chunkDiff)
+		if !request.GenerateFixSQL {
+			continue
+		}
+		if len(schema.keys) == 0 {
+			diff.Message = "fix SQL is not generated for table without primary key"
+			continue
+		}
+		if *fixBudget <= 0 {
+			diff.Message = "fix SQL is truncated"
+			continue
+		}
+		statements, err := generateFixSQL(ctx, upConn, downConn, schema, chunk)
+		if err != nil {
+			return diff, err
+		}
+		if len(statements) > *fixBudget {
+			statements = statements[:*fixBudget]
+			diff.Message = "fix SQL is truncated"
+		}
+		*fixBudget -= len(statements)
+		diff.FixSQL = append(diff.FixSQL, statements...)
+	}
+	return diff, nil
+}
+
+func queryColumns(ctx context.Context, conn *sql.Conn, database string, table string) ([]string, error) {
+	return queryNames(ctx, conn,
+		"SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ? ORDER BY ordinal_position",
+		database, table)
+}
+
+func queryPrimaryKey(ctx context.Context, conn *sql.Conn, database string, table string) ([]string, error) {
+	return queryNames(ctx, conn,
+		"SELECT column_name FROM information_schema.key_column_usage WHERE table_schema = ? AND table_name = ? AND constraint_name = 'PRIMARY' ORDER BY ordinal_position",
+		database, table)
+}
+
+func queryNames(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// splitChunks
+// @Description: split table into chunks of chunkSize rows by primary key, the table is one chunk if there is no primary key
+func splitChunks(ctx context.Context, conn *sql.Conn, schema tableSchema, chunkSize int) ([]chunkRange, error) {
+	if len(schema.keys) == 0 {
+		return []chunkRange{{where: "1 = 1", desc: "all rows"}}, nil
+	}
+	keyList := quoteColumns(schema.keys)
+
+	bounds := make([][]interface{}, 0)
+	var last []interface{}
+	for {
+		where, args := "1 = 1", []interface{}(nil)
+		if last != nil {
+			where, args = fmt.Sprintf("(%s) > (%s)", keyList, placeholders(len(schema.keys))), last
+		}
+		values := make([]sql.NullString, len(schema.keys))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT 1 OFFSET %d",
+			keyList, schema.quotedName(), where, keyList, chunkSize-1), args...).Scan(dest...)
+		if err == sql.ErrNoRows {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		last = make([]interface{}, len(values))
+		for i, value := range values {
+			last[i] = value.String
+		}
+		bounds = append(bounds, last)
+	}
+
+	ranges := make([]chunkRange, 0, len(bounds)+1)
+	lower := fmt.Sprintf("(%s) > (%s)", keyList, placeholders(len(schema.keys)))
+	upper := fmt.Sprintf("(%s) <= (%s)", keyList, placeholders(len(schema.keys)))
+	for i := 0; i <= len(bounds); i++ {
+		conditions, descs := make([]string, 0, 2), make([]string, 0, 2)
+		args := make([]interface{}, 0, 2*len(schema.keys))
+		if i > 0 {
+			conditions = append(conditions, lower)
+			descs = append(descs, fmt.Sprintf("(%s) > (%s)", keyList, quoteValues(bounds[i-1])))
+			args = append(args, bounds[i-1]...)
+		}
+		if i < len(bounds) {
+			conditions = append(conditions, upper)
+			descs = append(descs, fmt.Sprintf("(%s) <= (%s)", keyList, quoteValues(bounds[i])))
+			args = append(args, bounds[i]...)
+		}
+		if len(conditions) == 0 {
+			ranges = append(ranges, chunkRange{where: "1 = 1", desc: "all rows"})
+			continue
+		}
+		ranges = append(ranges, chunkRange{
+			where: strings.Join(conditions, " AND "),
+			args:  args,
+			desc:  strings.Join(descs, " AND "),
+		})
+	}
+	return ranges, nil
+}
+
+func checksumChunk(ctx context.Context, conn *sql.Conn, schema tableSchema, chunk chunkRange) (rows int64, checksum uint64, err error) {
+	columns := quoteColumns(schema.columns)
+	isNulls := make([]string, 0, len(schema.columns))
+	for _, column := range schema.columns {
+		isNulls = append(isNulls, fmt.Sprintf("ISNULL(%s)", quoteColumns([]string{column})))
+	}
+	query := fmt.Sprintf("SELECT COUNT(*), COALESCE(BIT_XOR(CAST(CRC32(CONCAT_WS(',', %s, CONCAT(%s))) AS UNSIGNED)), 0) FROM %s WHERE %s",
+		columns, strings.Join(isNulls, ", "), schema.quotedName(), chunk.where)
+	err = conn.QueryRowContext(ctx, query, chunk.args...).Scan(&rows, &checksum)
+	return rows, checksum, err
+}
+
+// generateFixSQL
+// @Description: compare rows of chunk one by one, rows different or missing in downstream are replaced and
+// rows only in downstream are deleted
+func generateFixSQL(ctx context.Context, upConn *sql.Conn, downConn *sql.Conn, schema tableSchema, chunk chunkRange) ([]string, error) {
+	upRows, err := queryChunkRows(ctx, upConn, schema, chunk)
+	if err != nil {
+		return nil, err
+	}
+	downRows, err := queryChunkRows(ctx, downConn, schema, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndexes := make([]int, 0, len(schema.keys))
+	for _, key := range schema.keys {
+		for i, column := range schema.columns {
+			if column == key {
+				keyIndexes = append(keyIndexes, i)
+			}
+		}
+	}
+	rowKey := func(row []sql.NullString) string {
+		values := make([]string, 0, len(keyIndexes))
+		for _, i := range keyIndexes {
+			values = append(values, row[i].String)
+		}
+		return strings.Join(values, "\x00")
+	}
+	upstreamRows := make(map[string]bool, len(upRows))
+	downstreamRows := make(map[string][]sql.NullString, len(downRows))
+	for _, row := range upRows {
+		upstreamRows[rowKey(row)] = true
+	}
+	for _, row := range downRows {
+		downstreamRows[rowKey(row)] = row
+	}
+
+	statements := make([]string, 0)
+	for _, row := range upRows {
+		if downRow, ok := downstreamRows[rowKey(row)]; ok && equalRow(row, downRow) {
+			continue
+		}
+		values := make([]interface{}, 0, len(row))
+		for _, value := range row {
+			values = append(values, value)
+		}
+		statements = append(statements, fmt.Sprintf("REPLACE INTO %s (%s) VALUES (%s);",
+			schema.quotedName(), quoteColumns(schema.columns), quoteValues(values)))
+	}
+	for _, row := range downRows {
+		if upstreamRows[rowKey(row)] {
+			continue
+		}
+		conditions := make([]string, 0, len(keyIndexes))
+		for _, i := range keyIndexes {
+			conditions = append(conditions, fmt.Sprintf("%s = %s", quoteColumns(schema.columns[i:i+1]), quoteValue(row[i])))
+		}
+		statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT 1;",
+			schema.quotedName(), strings.Join(conditions, " AND ")))
+	}
+	return statements, nil
+}
+
+func queryChunkRows(ctx context.Context, conn *sql.Conn, schema tableSchema, chunk chunkRange) ([][]sql.NullString, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s",
+		quoteColumns(schema.columns), schema.quotedName(), chunk.where, quoteColumns(schema.keys)), chunk.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([][]sql.NullString, 0)
+	for rows.Next() {
+		row := make([]sql.NullString, len(schema.columns))
+		dest := make([]interface{}, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func equalRow(a []sql.NullString, b []sql.NullString) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, quoteName(column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteName quotes an identifier with backticks, backticks in the identifier are escaped by doubling them
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+func quoteValues(values []interface{}) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, quoteValue(value))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteValue(value interface{}) string {
+	switch v := value.(type) {
+	case sql.NullString:
+		if !v.Valid {
+			return "NULL"
+		}
+		return quoteValue(v.String)
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\x00", `\0`).Replace(v) + "'"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,258 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	queryColumnsSQL    = "SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ?"
+	queryPrimaryKeySQL = "SELECT column_name FROM information_schema.key_column_usage WHERE table_schema = ? AND table_name = ?"
+	checksumChunkSQL   = "SELECT COUNT(*), COALESCE(BIT_XOR(CAST(CRC32(CONCAT_WS(',', `id`, `name`, CONCAT(ISNULL(`id`), ISNULL(`name`)))) AS UNSIGNED)), 0) FROM `db1`.`t1` WHERE "
+)
+
+func TestMatchTableFilter(t *testing.T) {
+	rules := []string{"*.*", "!__TiDB_BR_Temporary*.*", "!db1.t?", "db1.t1"}
+	assert.True(t, MatchTableFilter(rules, "db1", "t1"))
+	assert.True(t, MatchTableFilter(rules, "DB1", "T1"))
+	assert.False(t, MatchTableFilter(rules, "db1", "t2"))
+	assert.True(t, MatchTableFilter(rules, "db1", "t22"))
+	assert.False(t, MatchTableFilter(rules, "__TiDB_BR_Temporary_mysql", "user"))
+	assert.True(t, MatchTableFilter(nil, "db2", "t1"))
+	assert.False(t, MatchTableFilter([]string{"db1.*"}, "db2", "t1"))
+	assert.False(t, MatchTableFilter([]string{"invalid"}, "db2", "t1"))
+}
+
+func Test_quoteName(t *testing.T) {
+	schema := tableSchema{database: "db`1", table: "t`1"}
+	assert.Equal(t, "`db``1`.`t``1`", schema.quotedName())
+	assert.Equal(t, "`id`, `na``me`", quoteColumns([]string{"id", "na`me"}))
+}
+
+func Test_querySyncPoint(t *testing.T) {
+	const querySyncPointSQL = "SELECT primary_ts, secondary_ts FROM tidb_cdc.syncpoint_v1 WHERE cf = ?"
+	t.Run("normal", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(querySyncPointSQL)).WithArgs("cf1").
+			WillReturnRows(sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}).AddRow(431434047157698561, 431434047157698562))
+		primaryTS, secondaryTS, err := querySyncPoint(context.TODO(), db, "cf1")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(431434047157698561), primaryTS)
+		assert.Equal(t, uint64(431434047157698562), secondaryTS)
+	})
+	t.Run("not enabled", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(querySyncPointSQL)).
+			WillReturnError(fmt.Errorf("Error 1146: Table 'tidb_cdc.syncpoint_v1' doesn't exist"))
+		mock.ExpectQuery(regexp.QuoteMeta(querySyncPointSQL)).
+			WillReturnRows(sqlmock.NewRows([]string{"primary_ts", "secondary_ts"}))
+		primaryTS, _, err := querySyncPoint(context.TODO(), db, "cf1")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), primaryTS)
+		primaryTS, _, err = querySyncPoint(context.TODO(), db, "cf1")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), primaryTS)
+	})
+	t.Run("error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(querySyncPointSQL)).WillReturnError(fmt.Errorf("connection refused"))
+		_, _, err = querySyncPoint(context.TODO(), db, "cf1")
+		assert.Error(t, err)
+	})
+}
+
+func Test_diffTables(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		upstream, upMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer upstream.Close()
+		downstream, downMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer downstream.Close()
+
+		upMock.ExpectExec(regexp.QuoteMeta("SET @@tidb_snapshot = '431434047157698561'")).WillReturnResult(sqlmock.NewResult(0, 0))
+		upMock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1").AddRow("db1", "t2").AddRow("db1", "t3"))
+
+		// db1.t1 is split into 2 chunks, the second one mismatches
+		upMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id").AddRow("name"))
+		downMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id").AddRow("name"))
+		upMock.ExpectQuery(regexp.QuoteMeta(queryPrimaryKeySQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+		upMock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `db1`.`t1` WHERE 1 = 1 ORDER BY `id` LIMIT 1 OFFSET 1")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2"))
+		upMock.ExpectQuery(regexp.QuoteMeta("SELECT `id` FROM `db1`.`t1` WHERE (`id`) > (?) ORDER BY `id` LIMIT 1 OFFSET 1")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		upMock.ExpectQuery(regexp.QuoteMeta(checksumChunkSQL + "(`id`) <= (?)")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(2, 100))
+		downMock.ExpectQuery(regexp.QuoteMeta(checksumChunkSQL + "(`id`) <= (?)")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(2, 100))
+		upMock.ExpectQuery(regexp.QuoteMeta(checksumChunkSQL + "(`id`) > (?)")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(1, 200))
+		downMock.ExpectQuery(regexp.QuoteMeta(checksumChunkSQL + "(`id`) > (?)")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(2, 300))
+		upMock.ExpectQuery(regexp.QuoteMeta("SELECT `id`, `name` FROM `db1`.`t1` WHERE (`id`) > (?) ORDER BY `id`")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("3", "a'b"))
+		downMock.ExpectQuery(regexp.QuoteMeta("SELECT `id`, `name` FROM `db1`.`t1` WHERE (`id`) > (?) ORDER BY `id`")).WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("3", "b").AddRow("4", nil))
+
+		// db1.t2 is filtered, db1.t3 does not exist in downstream
+		upMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t3").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+		downMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t3").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}))
+
+		diffs, err := diffTables(context.TODO(), upstream, downstream, DiffTablesReq{
+			UpstreamSnapshotTSO: 431434047157698561,
+			FilterRules:         []string{"*.*", "!db1.t2"},
+			ChunkSize:           2,
+			GenerateFixSQL:      true,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(diffs))
+		assert.Equal(t, "db1.t1", diffs[0].Name())
+		assert.False(t, diffs[0].Equal)
+		assert.Equal(t, 2, diffs[0].Chunks)
+		assert.Equal(t, []ChunkDiff{{
+			Range:              "(`id`) > ('2')",
+			UpstreamRows:       1,
+			DownstreamRows:     2,
+			UpstreamChecksum:   200,
+			DownstreamChecksum: 300,
+		}}, diffs[0].MismatchChunks)
+		assert.Equal(t, []string{
+			"REPLACE INTO `db1`.`t1` (`id`, `name`) VALUES ('3', 'a\\'b');",
+			"DELETE FROM `db1`.`t1` WHERE `id` = '4' LIMIT 1;",
+		}, diffs[0].FixSQL)
+		assert.Equal(t, "db1.t3", diffs[1].Name())
+		assert.False(t, diffs[1].Equal)
+		assert.Equal(t, "table does not exist in downstream", diffs[1].Message)
+		assert.NoError(t, upMock.ExpectationsWereMet())
+		assert.NoError(t, downMock.ExpectationsWereMet())
+	})
+	t.Run("without primary key", func(t *testing.T) {
+		upstream, upMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer upstream.Close()
+		downstream, downMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer downstream.Close()
+
+		downMock.ExpectExec(regexp.QuoteMeta("SET @@tidb_snapshot = '431434047157698562'")).WillReturnResult(sqlmock.NewResult(0, 0))
+		upMock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1"))
+		upMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id").AddRow("name"))
+		downMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id").AddRow("name"))
+		upMock.ExpectQuery(regexp.QuoteMeta(queryPrimaryKeySQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}))
+		upMock.ExpectQuery(regexp.QuoteMeta(checksumChunkSQL + "1 = 1")).
+			WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(2, 100))
+		downMock.ExpectQuery(regexp.QuoteMeta(checksumChunkSQL + "1 = 1")).
+			WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(2, 101))
+
+		diffs, err := diffTables(context.TODO(), upstream, downstream, DiffTablesReq{
+			DownstreamSnapshotTSO: 431434047157698562,
+			GenerateFixSQL:        true,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(diffs))
+		assert.False(t, diffs[0].Equal)
+		assert.Equal(t, "all rows", diffs[0].MismatchChunks[0].Range)
+		assert.Equal(t, "fix SQL is not generated for table without primary key", diffs[0].Message)
+		assert.Empty(t, diffs[0].FixSQL)
+		assert.NoError(t, upMock.ExpectationsWereMet())
+		assert.NoError(t, downMock.ExpectationsWereMet())
+	})
+	t.Run("columns differ", func(t *testing.T) {
+		upstream, upMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer upstream.Close()
+		downstream, downMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer downstream.Close()
+
+		upMock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1"))
+		upMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id").AddRow("name"))
+		downMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WithArgs("db1", "t1").
+			WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+
+		diffs, err := diffTables(context.TODO(), upstream, downstream, DiffTablesReq{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(diffs))
+		assert.False(t, diffs[0].Equal)
+		assert.Contains(t, diffs[0].Message, "columns differ")
+	})
+	t.Run("query failed", func(t *testing.T) {
+		upstream, upMock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer upstream.Close()
+		downstream, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer downstream.Close()
+
+		upMock.ExpectQuery(regexp.QuoteMeta(listTablesSQL)).WillReturnRows(sqlmock.NewRows([]string{"table_schema", "table_name"}).
+			AddRow("db1", "t1"))
+		upMock.ExpectQuery(regexp.QuoteMeta(queryColumnsSQL)).WillReturnError(fmt.Errorf("connection refused"))
+
+		_, err = diffTables(context.TODO(), upstream, downstream, DiffTablesReq{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,116 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pingcap/tiunimanager/common/errors"
+	"github.com/pingcap/tiunimanager/library/framework"
+)
+
+// layout of tikv_gc_safe_point in mysql.tidb, such as 20220114-10:19:29.178 +0800
+const gcSafePointLayout = "20060102-15:04:05 -0700"
+
+// QueryGCSafePoint
+// @Description: query GC safe point of cluster, data before it may have been garbage collected
+// @Parameter ctx
+// @Parameter dbConnParam
+// @return time.Time zero if GC has never run
+// @return error
+func QueryGCSafePoint(ctx context.Context, dbConnParam DbConnParam) (time.Time, error) {
+	db, err := openDB(dbConnParam)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return time.Time{}, errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
+	}
+	defer db.Close()
+	return queryGCSafePoint(ctx, db)
+}
+
+func queryGCSafePoint(ctx context.Context, db *sql.DB) (time.Time, error) {
+	var value string
+	err := db.QueryRowContext(ctx, "SELECT VARIABLE_VALUE FROM mysql.tidb WHERE VARIABLE_NAME = 'tikv_gc_safe_point'").Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		framework.LogWithContext(ctx).Errorf("query gc safe point failed %s", err.Error())
+		return time.Time{}, errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
+	}
+	// fractional second is accepted even if it is not in layout
+	safePoint, err := time.Parse(gcSafePointLayout, value)
+	if err != nil {
+		return time.Time{}, errors.WrapError(errors.TIUNIMANAGER_UNRECOGNIZED_ERROR, "unrecognized gc safe point "+value, err)
+	}
+	return safePoint, nil
+}
+
+// QueryGCLifeTime
+// @Description: query tidb_gc_life_time of cluster
+// @Parameter ctx
+// @Parameter dbConnParam
+// @return string duration, such as 10m0s
+// @return error
+func QueryGCLifeTime(ctx context.Context, dbConnParam DbConnParam) (string, error) {
+	db, err := openDB(dbConnParam)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return "", errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
+	}
+	defer db.Close()
+	return queryGCLifeTime(ctx, db)
+}
+
+// UpdateGCLifeTime
+// @Description: set global tidb_gc_life_time of cluster
+// @Parameter ctx
+// @Parameter dbConnParam
+// @Parameter gcLifeTime duration, such as 720h
+// @return error
+func UpdateGCLifeTime(ctx context.Context, dbConnParam DbConnParam, gcLifeTime string) error {
+	db, err := openDB(dbConnParam)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("open tidb connection failed %s", err.Error())
+		return errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
+	}
+	defer db.Close()
+	return updateGCLifeTime(ctx, db, gcLifeTime)
+}
+
+func queryGCLifeTime(ctx context.Context, db *sql.DB) (string, error) {
+	var value sql.NullString
+	err := db.QueryRowContext(ctx, "SELECT VARIABLE_VALUE FROM mysql.GLOBAL_VARIABLES WHERE VARIABLE_NAME = 'tidb_gc_life_time'").Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		framework.LogWithContext(ctx).Errorf("query tidb_gc_life_time failed %s", err.Error())
+		return "", errors.WrapError(errors.TIUNIMANAGER_CONNECT_TIDB_ERROR, err.Error(), err)
+	}
+	if !value.Valid {
+		return "", errors.NewError(errors.TIUNIMANAGER_UNRECOGNIZED_ERROR, "tidb_gc_life_time not found")
+	}
+	return value.String, nil
+}
+
+func updateGCLifeTime(ctx context.Context, db *sql.DB, gcLifeTime string) error {
+	_, err := db.ExecContext(ctx, "SET GLOBAL tidb_gc_life_time = ?", gcLifeTime)
+	if err != nil {
+		framework.LogWithContext(ctx).Errorf("set tidb_gc_life_time to %s failed %s", gcLifeTime, err.Error())
+		return errors.WrapError(errors.TIUNIMANAGER_GC_LIFE_TIME_UPDATE_FAIL, err.Error(), err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/******************************************************************************
+ * Copyright (c)  2022 PingCAP                                                *
+ * Licensed under the Apache License, Version 2.0 (the "License");            *
+ * you may not use this file except in compliance with the License.           *
+ * You may obtain a copy of the License at                                    *
+ *                                                                            *
+ * http://www.apache.org/licenses/LICENSE-2.0                                 *
+ *                                                                            *
+ * Unless required by applicable law or agreed to in writing, software        *
+ * distributed under the License is distributed on an "AS IS" BASIS,          *
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.   *
+ * See the License for the specific language governing permissions and        *
+ * limitations under the License.                                             *
+ *                                                                            *
+ ******************************************************************************/
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_queryGCSafePoint(t *testing.T) {
+	querySQL := regexp.QuoteMeta("SELECT VARIABLE_VALUE FROM mysql.tidb WHERE VARIABLE_NAME = 'tikv_gc_safe_point'")
+	t.Run("normal", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectQuery(querySQL).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}).AddRow("20220114-10:19:29.178 +0800"))
+		safePoint, err := queryGCSafePoint(context.TODO(), db)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2022, 1, 14, 2, 19, 29, 178000000, time.UTC), safePoint.UTC())
+	})
+	t.Run("never gc", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectQuery(querySQL).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}))
+		safePoint, err := queryGCSafePoint(context.TODO(), db)
+		assert.NoError(t, err)
+		assert.True(t, safePoint.IsZero())
+	})
+	t.Run("unrecognized", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectQuery(querySQL).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}).AddRow("yesterday"))
+		_, err = queryGCSafePoint(context.TODO(), db)
+		assert.Error(t, err)
+	})
+}
+
+func Test_queryGCLifeTime(t *testing.T) {
+	querySQL := regexp.QuoteMeta("SELECT VARIABLE_VALUE FROM mysql.GLOBAL_VARIABLES WHERE VARIABLE_NAME = 'tidb_gc_life_time'")
+	t.Run("normal", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectQuery(querySQL).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}).AddRow("10m0s"))
+		gcLifeTime, err := queryGCLifeTime(context.TODO(), db)
+		assert.NoError(t, err)
+		assert.Equal(t, "10m0s", gcLifeTime)
+	})
+	t.Run("not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectQuery(querySQL).WillReturnRows(sqlmock.NewRows([]string{"VARIABLE_VALUE"}))
+		_, err = queryGCLifeTime(context.TODO(), db)
+		assert.Error(t, err)
+	})
+}
+
+func Test_updateGCLifeTime(t *testing.T) {
+	updateSQL := regexp.QuoteMeta("SET GLOBAL tidb_gc_life_time = ?")
+	t.Run("normal", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectExec(updateSQL).WithArgs("720h").WillReturnResult(sqlmock.NewResult(0, 0))
+		assert.NoError(t, updateGCLifeTime(context.TODO(), db, "720h"))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+		mock.ExpectExec(updateSQL).WithArgs("forever").WillReturnError(fmt.Errorf("invalid duration"))
+		err = updateGCLifeTime(context.TODO(), db, "forever")
+		assert.Error(t, err)
+	})
+}
@@ -18,7 +18,9 @@ package encrypt
 
 import (
 	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -106,3 +108,49 @@ func AesDecryptCFB(encryptedStr string) (decryptedStr string, err error) {
 	}
 	return string(decrypted), nil
 }
+
+// AesSealWithPassphrase encrypts plainStr using AES-GCM with a key derived from passphrase,
+// used to exchange secrets between platforms which have different keys
+func AesSealWithPassphrase(plainStr string, passphrase string) (sealedStr string, err error) {
+	gcm, err := newPassphraseGCM(passphrase)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", status.Errorf(codes.Internal, "init nonce err, %s", err)
+	}
+	return hex.EncodeToString(gcm.Seal(nonce, nonce, []byte(plainStr), nil)), nil
+}
+
+// AesOpenWithPassphrase decrypts sealedStr of AesSealWithPassphrase, it fails if passphrase is wrong or sealedStr is tampered
+func AesOpenWithPassphrase(sealedStr string, passphrase string) (plainStr string, err error) {
+	sealed, err := hex.DecodeString(sealedStr)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newPassphraseGCM(passphrase)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.Errorf("sealed data too short, %d < nonce size(%d)", len(sealed), gcm.NonceSize())
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.Errorf("open sealed data failed, wrong passphrase or corrupted data")
+	}
+	return string(plain), nil
+}
+
+func newPassphraseGCM(passphrase string) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+	derived := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
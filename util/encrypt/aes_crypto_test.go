@@ -96,3 +96,20 @@ func Test_DeCrypt_Failed(t *testing.T) {
 		assert.Equal(t, "", decrypt)
 	}
 }
+
+func Test_SealOpenWithPassphrase(t *testing.T) {
+	sealed, err := AesSealWithPassphrase("data key", "passphrase")
+	assert.NoError(t, err)
+	opened, err := AesOpenWithPassphrase(sealed, "passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, "data key", opened)
+
+	_, err = AesOpenWithPassphrase(sealed, "wrong passphrase")
+	assert.Error(t, err)
+	_, err = AesOpenWithPassphrase("00", "passphrase")
+	assert.Error(t, err)
+	_, err = AesOpenWithPassphrase("not hex", "passphrase")
+	assert.Error(t, err)
+	_, err = AesSealWithPassphrase("data key", "")
+	assert.Error(t, err)
+}